@@ -1,6 +1,6 @@
 // Command admin performs the operator tasks that previously required raw SQL:
-// creating accounts (including the very first admin) and changing a user's
-// role. It loads internal/config, so it reads the exact same DB_* environment
+// creating accounts (including the very first admin), changing a user's
+// role, and queueing the library to be processed again. It loads internal/config, so it reads the exact same DB_* environment
 // as the API and worker and cannot quietly point at a different database.
 package main
 
@@ -41,6 +41,10 @@ func run(args []string) error {
 		return promote(args[1:])
 	case "create":
 		return create(args[1:])
+	case "reprocess":
+		return reprocess(args[1:])
+	case "reprocess-status":
+		return reprocessStatus(args[1:])
 	case "version":
 		fmt.Println(version)
 		return nil
@@ -64,13 +68,25 @@ Subcommands:
       Create a user. The password may instead be supplied via the
       ADMIN_PASSWORD environment variable to keep it out of shell history.
 
+  reprocess [--created-after <time>] [--created-before <time>] [--status <status>]
+            [--missing-quality <name>] [--missing-hls] [--limit <n>] [--rate <n>]
+            [--dry-run]
+      Queue the videos the filters match to be processed again, newest first,
+      at most --limit of them (default 100) and no more than --rate a minute
+      (default 10). Times are RFC 3339 or YYYY-MM-DD. --dry-run only lists
+      what would be queued. Ready videos keep playing as they were until
+      their new output is published.
+
+  reprocess-status [--watch] <batch-id>
+      Show how far a reprocess batch is; --watch repeats until it is done.
+
   version
       Print the build version.
 
 Roles: guest, user, premium, moderator, admin
 
-Connection settings come from the same DB_* environment (and .env file) the
-API server reads.
+Connection settings come from the same DB_* and REDIS_* environment (and .env
+file) the API server reads.
 `)
 }
 
@@ -175,6 +191,16 @@ func create(args []string) error {
 // connect loads the shared configuration and opens a pool against the same
 // database the server uses. The caller owns closing the returned pool.
 func connect(ctx context.Context) (*postgres.UserRepository, *pgxpool.Pool, error) {
+	_, pool, err := openDatabase(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return postgres.NewUserRepository(pool), pool, nil
+}
+
+// openDatabase loads the shared configuration and opens a pool against the
+// database it names. The caller owns closing the returned pool.
+func openDatabase(ctx context.Context) (*config.Config, *pgxpool.Pool, error) {
 	cfg, err := config.Load()
 	if err != nil {
 		return nil, nil, err
@@ -189,5 +215,5 @@ func connect(ctx context.Context) (*postgres.UserRepository, *pgxpool.Pool, erro
 		return nil, nil, fmt.Errorf("pinging database at %s: %w", cfg.Database.Host, err)
 	}
 
-	return postgres.NewUserRepository(pool), pool, nil
+	return cfg, pool, nil
 }
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/internal/queue"
+	"github.com/Nuu-maan/video-streaming-service/internal/repository/postgres"
+	"github.com/Nuu-maan/video-streaming-service/internal/service"
+	"github.com/Nuu-maan/video-streaming-service/pkg/logger"
+	"github.com/Nuu-maan/video-streaming-service/pkg/validator"
+)
+
+// watchInterval is how often reprocess-status --watch checks a batch again.
+const watchInterval = 10 * time.Second
+
+func reprocess(args []string) error {
+	fs := flag.NewFlagSet("reprocess", flag.ContinueOnError)
+	createdAfter := fs.String("created-after", "", "only videos uploaded at or after this time")
+	createdBefore := fs.String("created-before", "", "only videos uploaded before this time")
+	status := fs.String("status", "", "only videos with this status: processing, ready or failed")
+	missingQuality := fs.String("missing-quality", "", "only videos without this rendition")
+	missingHLS := fs.Bool("missing-hls", false, "only videos with no HLS output")
+	limit := fs.Int("limit", service.DefaultReprocessLimit, "most videos to queue")
+	rate := fs.Int("rate", service.DefaultReprocessRate, "most videos to start a minute")
+	dryRun := fs.Bool("dry-run", false, "list the videos without queueing them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	filter := domain.ReprocessFilter{
+		MissingQuality: *missingQuality,
+		MissingHLS:     *missingHLS,
+	}
+	var err error
+	if filter.CreatedAfter, err = parseFlagTime("created-after", *createdAfter); err != nil {
+		return err
+	}
+	if filter.CreatedBefore, err = parseFlagTime("created-before", *createdBefore); err != nil {
+		return err
+	}
+	if *status != "" {
+		s := domain.VideoStatus(*status)
+		filter.Status = &s
+	}
+	// Zero would quietly take the default; the flags already show it.
+	if *limit <= 0 || *rate <= 0 {
+		return errors.New("--limit and --rate must be positive")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	cfg, pool, err := openDatabase(ctx)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	// Warnings only: the queue client would otherwise log every video queued.
+	log := logger.New(cfg.Server.Environment, "warn")
+	queueClient := queue.NewQueueClient(cfg.Redis.Address(), log)
+	defer queueClient.Close()
+
+	reprocessService := service.NewReprocessService(
+		postgres.NewPostgresVideoRepository(pool),
+		postgres.NewReprocessBatchRepository(pool),
+		queueClient,
+		log,
+	)
+	result, err := reprocessService.Reprocess(ctx, service.ReprocessRequest{
+		Filter:        filter,
+		Limit:         *limit,
+		RatePerMinute: *rate,
+		DryRun:        *dryRun,
+	})
+	if err != nil {
+		if result != nil && result.Batch != nil {
+			return fmt.Errorf("%w; reprocess-status %s shows what was queued", err, result.Batch.ID)
+		}
+		return err
+	}
+
+	printCandidates(result.Videos)
+	switch {
+	case len(result.Videos) == 0:
+		fmt.Println("no videos match")
+	case result.Batch == nil:
+		fmt.Printf("%d videos would be queued (dry run)\n", len(result.Videos))
+	default:
+		batch := result.Batch
+		fmt.Printf("queued batch %s: %d videos at %d a minute, the last due to start by %s\n",
+			batch.ID, batch.Total(), batch.RatePerMinute, batch.Schedule(batch.Total()-1).Format(time.RFC3339))
+	}
+	if result.More {
+		fmt.Printf("more videos match than --limit %d; run again once this batch is done for the rest\n", *limit)
+	}
+	return nil
+}
+
+func reprocessStatus(args []string) error {
+	fs := flag.NewFlagSet("reprocess-status", flag.ContinueOnError)
+	watch := fs.Bool("watch", false, "check again until the batch is done")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("reprocess-status requires a batch ID")
+	}
+	batchID, err := validator.ValidateUUID(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid batch ID %q", fs.Arg(0))
+	}
+
+	ctx := context.Background()
+	_, pool, err := openDatabase(ctx)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	batches := postgres.NewReprocessBatchRepository(pool)
+
+	batch, err := batches.GetByID(ctx, batchID)
+	if err != nil {
+		return fmt.Errorf("looking up batch %s: %w", batchID, err)
+	}
+	fmt.Printf("batch %s: queued %s, %d a minute, the last due to start by %s\n",
+		batch.ID, batch.CreatedAt.Format(time.RFC3339), batch.RatePerMinute,
+		batch.Schedule(batch.Total()-1).Format(time.RFC3339))
+
+	for {
+		progress, err := batches.Progress(ctx, batch)
+		if err != nil {
+			return fmt.Errorf("counting batch %s: %w", batchID, err)
+		}
+		fmt.Printf("%s  %d/%d published, %d failed, %d deleted, %d pending\n",
+			time.Now().Format(time.TimeOnly), progress.Published, progress.Total,
+			progress.Failed, progress.Deleted, progress.Pending)
+		if progress.Done() || !*watch {
+			return nil
+		}
+		time.Sleep(watchInterval)
+	}
+}
+
+// parseFlagTime parses a time flag given as RFC 3339 or as a date, taken as
+// midnight UTC. An empty flag is no time.
+func parseFlagTime(name, value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("--%s: %q is neither RFC 3339 nor YYYY-MM-DD", name, value)
+}
+
+func printCandidates(videos []*domain.Video) {
+	if len(videos) == 0 {
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATUS\tCREATED\tQUALITIES\tTITLE")
+	for _, v := range videos {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%v\t%s\n",
+			v.ID, v.Status, v.CreatedAt.Format(time.DateOnly), v.AvailableQualities, v.Title)
+	}
+	w.Flush()
+}
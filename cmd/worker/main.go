@@ -56,19 +56,29 @@ func main() {
 	log.Info(context.Background(), "Redis connection established", nil)
 
 	videoRepo := postgres.NewPostgresVideoRepository(dbPool)
+	audioTrackRepo := postgres.NewAudioTrackRepository(dbPool)
+	captionRepo := postgres.NewCaptionRepository(dbPool)
+	thumbnailRepo := postgres.NewThumbnailRepository(dbPool)
+	stageRepo := postgres.NewProcessingStageRepository(dbPool)
+	liveStreamRepo := postgres.NewLiveStreamRepository(dbPool)
 	ffmpegService := service.NewFFmpegService(log)
-	transcodingService := service.NewTranscodingService(videoRepo, ffmpegService, &cfg.Storage, log)
+	optimizer := service.NewVideoOptimizer("ffmpeg", "ffprobe")
+	transcodingService := service.NewTranscodingService(videoRepo, audioTrackRepo, captionRepo, thumbnailRepo, stageRepo, ffmpegService, optimizer, service.NewVideoProgressFeed(redisClient), &cfg.Storage, &cfg.Worker, log)
 
-	videoProcessingHandler := queue.NewVideoProcessingHandler(transcodingService, videoRepo, store, &cfg.Storage, log)
+	// Each stage of a video queues the stages that were waiting on it.
+	queueClient := queue.NewQueueClient(cfg.Redis.Address(), log)
+	defer queueClient.Close()
+
+	videoProcessingHandler := queue.NewVideoProcessingHandler(transcodingService, videoRepo, audioTrackRepo, captionRepo, thumbnailRepo, stageRepo, liveStreamRepo, store, &cfg.Storage, &cfg.Worker, &cfg.Live, redisClient, queueClient, log)
 
 	srv := asynq.NewServer(
 		asynq.RedisClientOpt{Addr: cfg.Redis.Address()},
 		asynq.Config{
 			Concurrency: cfg.Worker.MaxConcurrentJobs,
 			Queues: map[string]int{
-				"critical": 6,
-				"default":  3,
-				"low":      1,
+				config.TierCritical: 6,
+				config.TierDefault:  3,
+				config.TierLow:      1,
 			},
 			ErrorHandler: asynq.ErrorHandlerFunc(func(ctx context.Context, task *asynq.Task, err error) {
 				// The task ID comes from the context, not from ResultWriter().
@@ -100,6 +110,14 @@ func main() {
 
 	mux := asynq.NewServeMux()
 	mux.HandleFunc(queue.TypeVideoProcessing, videoProcessingHandler.ProcessTask)
+	mux.HandleFunc(queue.TypeVideoStage, videoProcessingHandler.ProcessStageTask)
+	mux.HandleFunc(queue.TypeAudioTrackProcessing, videoProcessingHandler.ProcessAudioTrackTask)
+	mux.HandleFunc(queue.TypeCaptionProcessing, videoProcessingHandler.ProcessCaptionTask)
+	mux.HandleFunc(queue.TypeThumbnailProcessing, videoProcessingHandler.ProcessThumbnailTask)
+	mux.HandleFunc(queue.TypeVideoCut, videoProcessingHandler.ProcessCutTask)
+	mux.HandleFunc(queue.TypeVideoImport, videoProcessingHandler.ProcessImportTask)
+	mux.HandleFunc(queue.TypeOutputRetire, videoProcessingHandler.ProcessOutputRetireTask)
+	mux.HandleFunc(queue.TypeLiveIngest, videoProcessingHandler.ProcessLiveIngestTask)
 
 	go func() {
 		log.Info(context.Background(), "Worker server starting", map[string]interface{}{
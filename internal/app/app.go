@@ -27,6 +27,7 @@ import (
 	"github.com/Nuu-maan/video-streaming-service/pkg/jwt"
 	"github.com/Nuu-maan/video-streaming-service/pkg/logger"
 	"github.com/Nuu-maan/video-streaming-service/pkg/mailer"
+	"github.com/Nuu-maan/video-streaming-service/pkg/playback"
 )
 
 // App owns every long-lived dependency of the API process.
@@ -47,10 +48,18 @@ type App struct {
 	authenticator *middleware.Authenticator
 	rateLimiter   *middleware.RateLimiter
 
+	// resumableUploads is held for the reaper Run starts; abandoned sessions
+	// otherwise keep their stored chunks forever.
+	resumableUploads *service.ResumableUploadService
+
 	authHandler       *handler.AuthHandler
 	accountHandler    *handler.AccountHandler
 	videoHandler      *handler.VideoHandler
 	streamingHandler  *handler.StreamingHandler
+	audioTrackHandler *handler.AudioTrackHandler
+	captionHandler    *handler.CaptionHandler
+	clipHandler       *handler.ClipHandler
+	thumbnailHandler  *handler.ThumbnailHandler
 	viewHandler       *handler.ViewHandler
 	socialHandler     *handler.SocialHandler
 	searchHandler     *handler.SearchHandler
@@ -59,6 +68,12 @@ type App struct {
 	analyticsHandler  *handler.AnalyticsHandler
 	moderationHandler *handler.ModerationHandler
 	monitoringHandler *handler.MonitoringHandler
+	reprocessHandler  *handler.ReprocessHandler
+	liveHandler       *handler.LiveHandler
+	premiereHandler   *handler.PremiereHandler
+
+	uploadSessionHandler *handler.UploadSessionHandler
+	directUploadHandler  *handler.DirectUploadHandler
 }
 
 // New builds the dependency graph. It returns a cleanly-closed App on error, so
@@ -76,6 +91,19 @@ func New(ctx context.Context, cfg *config.Config) (*App, error) {
 		app.Close()
 		return nil, fmt.Errorf("initialising storage: %w", err)
 	}
+	// Segments the API streams from MinIO go through a local disk cache.
+	// Only playback and the deletes that must drop what it holds read through
+	// it; the upload services keep the bare store, whose direct-upload
+	// methods the cache does not pass on.
+	playbackStore := store
+	if cfg.MinIO.Enabled && cfg.Storage.SegmentCacheDir != "" {
+		segments, err := storage.NewSegmentCache(store, cfg.Storage.SegmentCacheDir, cfg.Storage.SegmentCacheSize)
+		if err != nil {
+			app.Close()
+			return nil, fmt.Errorf("initialising segment cache: %w", err)
+		}
+		playbackStore = segments
+	}
 
 	db, err := openDatabase(ctx, cfg.Database)
 	if err != nil {
@@ -100,9 +128,11 @@ func New(ctx context.Context, cfg *config.Config) (*App, error) {
 	})
 	app.queueClient = queue.NewQueueClient(cfg.Redis.Address(), log)
 
-	// Two-tier cache (in-process L1 in front of Redis). Playlists are small,
-	// immutable once a video is ready, and requested once per viewer per few
-	// seconds, so they benefit from the local tier.
+	// Two-tier cache (in-process L1 in front of Redis). Media playlists are
+	// small, immutable once a video is ready, and requested once per viewer per
+	// few seconds, so they benefit from the local tier. Master playlists and
+	// DASH manifests gain a line with every dub and stay in Redis alone, where
+	// the worker can evict them.
 	app.cache = cache.NewCacheService(redisClient, localCacheEntries)
 
 	videoRepo := postgres.NewPostgresVideoRepository(db)
@@ -112,6 +142,13 @@ func New(ctx context.Context, cfg *config.Config) (*App, error) {
 	auditRepo := postgres.NewAuditLogRepository(db)
 	socialRepo := postgres.NewSocialRepository(db)
 	searchRepo := postgres.NewSearchRepository(db)
+	uploadSessionRepo := postgres.NewUploadSessionRepository(db)
+	audioTrackRepo := postgres.NewAudioTrackRepository(db)
+	captionRepo := postgres.NewCaptionRepository(db)
+	thumbnailRepo := postgres.NewThumbnailRepository(db)
+	stageRepo := postgres.NewProcessingStageRepository(db)
+	reprocessBatchRepo := postgres.NewReprocessBatchRepository(db)
+	liveStreamRepo := postgres.NewLiveStreamRepository(db)
 
 	tokens := jwt.NewTokenService(cfg.Auth.JWTSecret, cfg.Auth.AccessTokenTTL, cfg.Auth.RefreshTokenTTL, cfg.Auth.JWTIssuer)
 	// AccessTokenTTL bounds every denylist entry's lifetime: once the longest
@@ -134,7 +171,12 @@ func New(ctx context.Context, cfg *config.Config) (*App, error) {
 	// AuthService doubles as the SessionRevoker: a password reset or change
 	// must kill every outstanding session, exactly as logout-all does.
 	emailService := service.NewEmailService(userRepo, mail, cfg.Mail.FrontendBaseURL, cfg.Mail.PasswordResetTTL, authService, log)
-	uploadService := service.NewUploadService(videoRepo, ffmpeg, &cfg.Storage, store, log)
+	uploadService := service.NewUploadService(videoRepo, ffmpeg, &cfg.Storage, playbackStore, log)
+	app.resumableUploads = service.NewResumableUploadService(uploadSessionRepo, uploadService, store, redisClient, &cfg.Storage, log)
+	directUploads := service.NewDirectUploadService(uploadSessionRepo, uploadService, store, redisClient, &cfg.Storage, log)
+	audioTrackService := service.NewAudioTrackService(audioTrackRepo, store, &cfg.Storage, log)
+	captionService := service.NewCaptionService(captionRepo, store, &cfg.Storage, log)
+	thumbnailService := service.NewThumbnailService(thumbnailRepo, store, log)
 	auditService := service.NewAuditService(auditRepo)
 	analyticsService := service.NewAnalyticsService(analyticsRepo, redisClient)
 	// uploadService doubles as the VideoFileRemover: a moderator's delete_video
@@ -145,19 +187,39 @@ func New(ctx context.Context, cfg *config.Config) (*App, error) {
 	socialService := service.NewSocialService(socialRepo, videoRepo, userRepo, log)
 	searchService := service.NewSearchService(searchRepo)
 	viewTracker := service.NewViewTracker(analyticsRepo, redisClient, log)
+	reprocessService := service.NewReprocessService(videoRepo, reprocessBatchRepo, app.queueClient, log)
+	liveService := service.NewLiveService(liveStreamRepo, videoRepo, app.queueClient, &cfg.Live, log)
+	premiereService := service.NewPremiereService(videoRepo, log)
 
 	app.authHandler = handler.NewAuthHandler(authService, userRepo, log)
 	app.accountHandler = handler.NewAccountHandler(emailService, log)
-	app.videoHandler = handler.NewVideoHandler(uploadService, videoRepo, app.queueClient, log, cfg)
-	app.streamingHandler = handler.NewStreamingHandler(videoRepo, app.cache, store, log)
+	app.videoHandler = handler.NewVideoHandler(uploadService, videoRepo, app.queueClient, service.NewVideoProgressFeed(redisClient), log, cfg)
+	// Playback tokens are signed with the key the local store signs its
+	// part URLs with. The two MACs cover messages that start differently, so
+	// neither passes for the other.
+	playbackSigner := playback.NewSigner([]byte(cfg.Storage.SigningKey))
+	app.streamingHandler = handler.NewStreamingHandler(videoRepo, app.cache, playbackStore, playbackSigner, log, cfg)
+	app.audioTrackHandler = handler.NewAudioTrackHandler(audioTrackService, videoRepo, app.queueClient, log)
+	app.captionHandler = handler.NewCaptionHandler(captionService, videoRepo, app.queueClient, log)
+	app.clipHandler = handler.NewClipHandler(uploadService, videoRepo, app.queueClient, log)
+	app.thumbnailHandler = handler.NewThumbnailHandler(thumbnailService, videoRepo, store, app.queueClient, log)
 	app.viewHandler = handler.NewViewHandler(viewTracker, log)
 	app.socialHandler = handler.NewSocialHandler(socialService, log)
 	app.searchHandler = handler.NewSearchHandler(searchService, log)
-	app.adminHandler = handler.NewAdminHandler(videoRepo, app.queueClient, app.inspector, log)
-	app.pageHandler = handler.NewPageHandler(videoRepo, log)
+	app.adminHandler = handler.NewAdminHandler(videoRepo, stageRepo, app.queueClient, app.inspector, log)
+	app.pageHandler = handler.NewPageHandler(videoRepo, captionService, log)
 	app.analyticsHandler = handler.NewAnalyticsHandler(analyticsService, log)
 	app.moderationHandler = handler.NewModerationHandler(moderationService, log)
 	app.monitoringHandler = handler.NewMonitoringHandler(monitoringService, log)
+	app.reprocessHandler = handler.NewReprocessHandler(reprocessService, log)
+	app.liveHandler = handler.NewLiveHandler(liveService, log)
+	app.premiereHandler = handler.NewPremiereHandler(premiereService, videoRepo, log)
+	app.uploadSessionHandler = handler.NewUploadSessionHandler(
+		app.resumableUploads, app.queueClient, cfg.Storage.MaxFileSize, cfg.Storage.UploadChunkTimeout, log,
+	)
+	app.directUploadHandler = handler.NewDirectUploadHandler(
+		directUploads, app.queueClient, cfg.Storage.MaxFileSize, cfg.Storage.UploadChunkTimeout, log,
+	)
 
 	return app, nil
 }
@@ -173,6 +235,8 @@ func (a *App) Run(ctx context.Context) error {
 		ReadHeaderTimeout: 10 * time.Second,
 	}
 
+	go a.reapUploadSessions(ctx)
+
 	serveErr := make(chan error, 1)
 	go func() {
 		a.log.Info(ctx, "HTTP server listening", map[string]interface{}{
@@ -206,6 +270,35 @@ func (a *App) Run(ctx context.Context) error {
 	return nil
 }
 
+// uploadReapInterval is how often abandoned resumable uploads are swept. Every
+// API instance sweeps; the deletes are idempotent, so overlapping sweeps only
+// repeat work.
+const uploadReapInterval = time.Hour
+
+// reapUploadSessions deletes expired upload sessions and their chunks until
+// ctx is cancelled.
+func (a *App) reapUploadSessions(ctx context.Context) {
+	ticker := time.NewTicker(uploadReapInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := a.resumableUploads.PurgeExpired(ctx)
+			if err != nil {
+				a.log.Error(ctx, "failed to reap expired upload sessions", err, nil)
+			}
+			if purged > 0 {
+				a.log.Info(ctx, "reaped expired upload sessions", map[string]interface{}{
+					"count": purged,
+				})
+			}
+		}
+	}
+}
+
 // localCacheEntries bounds the in-process L1 cache.
 const localCacheEntries = 10_000
 
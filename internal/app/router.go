@@ -8,6 +8,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/internal/handler"
 	"github.com/Nuu-maan/video-streaming-service/internal/metrics"
 	"github.com/Nuu-maan/video-streaming-service/internal/middleware"
 )
@@ -35,6 +36,7 @@ func (a *App) Handler() http.Handler {
 		middleware.Recovery(a.log),
 		middleware.RequestID(),
 		middleware.Logger(a.log),
+		handler.TusOptions(a.cfg.Storage.MaxFileSize),
 		middleware.CORS(a.cfg.CORS),
 		metrics.MetricsMiddleware(),
 	)
@@ -129,6 +131,7 @@ func (a *App) registerAPIRoutes(api *gin.RouterGroup) {
 		videos.GET("/trending", a.searchHandler.Trending)
 		videos.GET("/:id", auth.OptionalAuth(), a.videoHandler.GetVideo)
 		videos.GET("/:id/status", auth.OptionalAuth(), a.videoHandler.GetVideoStatus)
+		videos.GET("/:id/status/stream", auth.OptionalAuth(), a.videoHandler.StreamVideoStatus)
 		videos.GET("/:id/related", a.searchHandler.Related)
 
 		// Writes require a caller. Upload was previously anonymous, so an
@@ -139,10 +142,66 @@ func (a *App) registerAPIRoutes(api *gin.RouterGroup) {
 			a.rateLimit("upload"),
 			a.videoHandler.Upload,
 		)
+		// An import is an upload the worker fetches; a drop-directory path
+		// is further limited to admins by the handler.
+		videos.POST("/import",
+			auth.RequireAuth(),
+			auth.RequirePermission(domain.PermissionUploadVideo),
+			a.rateLimit("upload"),
+			a.videoHandler.Import,
+		)
 		// Ownership is enforced inside the handler: an owner may delete their
 		// own video, and PermissionDeleteAnyVideo covers everyone else's.
 		videos.DELETE("/:id", auth.RequireAuth(), a.videoHandler.DeleteVideo)
 
+		// Anyone who can watch a video can list its audio tracks and captions;
+		// only its owner can add a dub or a caption, which the handlers
+		// enforce.
+		videos.GET("/:id/audio-tracks", auth.OptionalAuth(), a.audioTrackHandler.List)
+		videos.POST("/:id/audio-tracks",
+			auth.RequireAuth(),
+			auth.RequirePermission(domain.PermissionUploadVideo),
+			a.rateLimit("upload"),
+			a.audioTrackHandler.Upload,
+		)
+		videos.GET("/:id/captions", auth.OptionalAuth(), a.captionHandler.List)
+		videos.POST("/:id/captions",
+			auth.RequireAuth(),
+			auth.RequirePermission(domain.PermissionUploadVideo),
+			a.rateLimit("upload"),
+			a.captionHandler.Upload,
+		)
+
+		// Trimming and clipping cut a new source from the video's, so they
+		// spend the upload budget; the handler keeps them to the owner.
+		// Anyone who can watch the video can list its public clips.
+		videos.GET("/:id/clips", auth.OptionalAuth(), a.clipHandler.List)
+		videos.POST("/:id/clips",
+			auth.RequireAuth(),
+			auth.RequirePermission(domain.PermissionUploadVideo),
+			a.rateLimit("upload"),
+			a.clipHandler.Create,
+		)
+		videos.POST("/:id/trim",
+			auth.RequireAuth(),
+			auth.RequirePermission(domain.PermissionUploadVideo),
+			a.rateLimit("upload"),
+			a.clipHandler.Trim,
+		)
+
+		// Choosing a poster is for the owner alone, previews included: a
+		// candidate nobody picked is not part of the published video. The
+		// handler enforces it.
+		videos.GET("/:id/thumbnails", auth.RequireAuth(), a.thumbnailHandler.List)
+		videos.GET("/:id/thumbnails/:thumbnailId", auth.RequireAuth(), a.thumbnailHandler.Preview)
+		videos.PUT("/:id/thumbnail", auth.RequireAuth(), a.thumbnailHandler.Select)
+		videos.POST("/:id/thumbnails",
+			auth.RequireAuth(),
+			auth.RequirePermission(domain.PermissionUploadVideo),
+			a.rateLimit("upload"),
+			a.thumbnailHandler.Upload,
+		)
+
 		// A view may be anonymous — the handler then requires a session_id in
 		// the body — but resume progress only means something for an account.
 		videos.POST("/:id/view", auth.OptionalAuth(), a.viewHandler.RecordView)
@@ -154,11 +213,75 @@ func (a *App) registerAPIRoutes(api *gin.RouterGroup) {
 
 		videos.GET("/:id/comments", a.socialHandler.ListComments)
 		videos.POST("/:id/comments", auth.RequireAuth(), a.socialHandler.CreateComment)
+		// A premiere's chat is its comments, in the order they were posted
+		// into it; as public as the comments themselves.
+		videos.GET("/:id/chat", a.socialHandler.ListChat)
+
+		// Scheduling a premiere and setting chapters are for the owner
+		// alone, which the handler enforces.
+		videos.PUT("/:id/premiere", auth.RequireAuth(), a.premiereHandler.Schedule)
+		videos.DELETE("/:id/premiere", auth.RequireAuth(), a.premiereHandler.Cancel)
+		videos.PUT("/:id/chapters", auth.RequireAuth(), a.premiereHandler.SetChapters)
 
 		videos.PUT("/:id/watch-later", auth.RequireAuth(), a.socialHandler.AddWatchLater)
 		videos.DELETE("/:id/watch-later", auth.RequireAuth(), a.socialHandler.RemoveWatchLater)
 	}
 
+	// Resumable uploads (tus 1.0.0). Creating a session spends the same
+	// budget as a multipart upload; its chunks draw on their own, far larger
+	// one. Session ownership is checked in the service.
+	uploads := api.Group("/uploads")
+	uploads.Use(auth.RequireAuth())
+	{
+		uploads.POST("",
+			auth.RequirePermission(domain.PermissionUploadVideo),
+			a.rateLimit("upload"),
+			a.uploadSessionHandler.Create,
+		)
+		uploads.HEAD("/:id", a.rateLimit("upload_chunk"), a.uploadSessionHandler.Head)
+		uploads.GET("/:id", a.rateLimit("upload_chunk"), a.uploadSessionHandler.Get)
+		uploads.PATCH("/:id", a.rateLimit("upload_chunk"), a.uploadSessionHandler.Patch)
+		uploads.DELETE("/:id", a.rateLimit("upload_chunk"), a.uploadSessionHandler.Terminate)
+
+		// Direct uploads: the client PUTs parts to presigned storage URLs
+		// and only initiation and completion come through here.
+		uploads.POST("/direct",
+			auth.RequirePermission(domain.PermissionUploadVideo),
+			a.rateLimit("upload"),
+			a.directUploadHandler.Initiate,
+		)
+		uploads.POST("/direct/:id/complete", a.rateLimit("upload_chunk"), a.directUploadHandler.Complete)
+		uploads.DELETE("/direct/:id", a.rateLimit("upload_chunk"), a.directUploadHandler.Abort)
+	}
+
+	// The local store's stand-in for presigned part URLs. No RequireAuth:
+	// the URL's signature is the credential (see storage.LocalDirectPartPath).
+	api.PUT("/uploads/direct/parts/:uploadId/:part", a.rateLimit("upload_chunk"), a.directUploadHandler.Part)
+
+	// Live streams. Going live spends the upload budget, as a broadcast ends
+	// up an upload; a stream is its owner's alone, which the service checks.
+	if a.cfg.Live.Enabled {
+		live := api.Group("/live")
+		live.Use(a.rateLimit("user_api"), auth.RequireAuth())
+		{
+			live.POST("",
+				auth.RequirePermission(domain.PermissionUploadVideo),
+				a.rateLimit("upload"),
+				a.liveHandler.Create,
+			)
+			live.GET("", a.liveHandler.List)
+			live.GET("/:id", a.liveHandler.Get)
+			live.POST("/:id/chapters", a.liveHandler.MarkChapter)
+		}
+
+		// The ingest server's authentication hook, outside the group: every
+		// call comes from the one ingest server, which a per-client limit
+		// would soon refuse, and the credentials checked are the ones it
+		// forwards rather than a bearer token. nginx keeps the route from
+		// the outside.
+		api.POST("/live/ingest/auth", a.liveHandler.IngestAuth)
+	}
+
 	// Streaming. Kept in its own group with a far higher rate limit: a single
 	// viewer pulls one HLS segment every few seconds.
 	streaming := api.Group("/videos/:id")
@@ -167,11 +290,16 @@ func (a *App) registerAPIRoutes(api *gin.RouterGroup) {
 		streaming.GET("/hls/master.m3u8", a.streamingHandler.ServeMasterPlaylist)
 		streaming.GET("/hls/:quality/playlist.m3u8", a.streamingHandler.ServeQualityPlaylist)
 		streaming.GET("/hls/:quality/:segment", a.streamingHandler.ServeSegment)
+		streaming.GET("/dash/manifest.mpd", a.streamingHandler.ServeDASHManifest)
+		streaming.GET("/dash/:quality/:segment", a.streamingHandler.ServeSegment)
 		streaming.GET("/stream/:quality", a.streamingHandler.ServeMP4Fallback)
 
 		// A thumbnail is a frame of the video, so it is exactly as private as the
-		// video and is served under the same visibility check.
+		// video and is served under the same visibility check. So are the
+		// seek-bar preview sheets and the animated hover preview.
 		streaming.GET("/thumbnail", a.streamingHandler.ServeThumbnail)
+		streaming.GET("/preview", a.streamingHandler.ServePreview)
+		streaming.GET("/trickplay/:file", a.streamingHandler.ServeTrickplay)
 	}
 
 	// Comment edits address the comment, not the video, so they carry their
@@ -267,6 +395,8 @@ func (a *App) registerAPIRoutes(api *gin.RouterGroup) {
 	ops.Use(auth.RequirePermission(domain.PermissionModerateContent))
 	{
 		ops.POST("/videos/:id/retry", a.adminHandler.RetryVideo)
+		ops.GET("/videos/:id/encoding-ladder", a.adminHandler.GetEncodingLadder)
+		ops.GET("/videos/:id/stages", a.adminHandler.ListProcessingStages)
 		ops.GET("/queue/stats", a.adminHandler.GetQueueStats)
 		ops.GET("/workers", a.adminHandler.ListActiveWorkers)
 		ops.DELETE("/videos/:id/cache", a.streamingHandler.ClearPlaylistCache)
@@ -304,6 +434,15 @@ func (a *App) registerAPIRoutes(api *gin.RouterGroup) {
 		monitoring.GET("/database", a.monitoringHandler.GetDatabaseMetrics)
 		monitoring.GET("/redis", a.monitoringHandler.GetRedisMetrics)
 	}
+
+	// Reprocessing can put the whole library back through the worker, so it
+	// is admin-only too.
+	reprocess := admin.Group("/reprocess")
+	reprocess.Use(auth.RequirePermission(domain.PermissionManageUsers))
+	{
+		reprocess.POST("", a.reprocessHandler.Reprocess)
+		reprocess.GET("/:id", a.reprocessHandler.GetBatch)
+	}
 }
 
 // rateLimit returns the limiter middleware for a rule, or a no-op when rate
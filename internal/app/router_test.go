@@ -62,7 +62,19 @@ func TestHandlerRegistersVersionedAndAliasRoutes(t *testing.T) {
 		"GET /videos/trending",
 		"GET /videos/:id",
 		"GET /videos/:id/related",
+		"GET /videos/:id/status/stream",
 		"GET /videos/:id/hls/master.m3u8",
+		"GET /videos/:id/dash/manifest.mpd",
+		"GET /videos/:id/preview",
+		"GET /videos/:id/trickplay/:file",
+		"GET /videos/:id/audio-tracks",
+		"POST /videos/:id/audio-tracks",
+		"GET /videos/:id/captions",
+		"POST /videos/:id/captions",
+		"GET /videos/:id/thumbnails",
+		"GET /videos/:id/thumbnails/:thumbnailId",
+		"PUT /videos/:id/thumbnail",
+		"POST /videos/:id/thumbnails",
 		"PUT /videos/:id/like",
 		"POST /videos/:id/view",
 		"GET /videos/:id/comments",
@@ -77,6 +89,16 @@ func TestHandlerRegistersVersionedAndAliasRoutes(t *testing.T) {
 		"POST /me/notifications/:id/read",
 		"POST /me/change-password",
 		"POST /admin/users/:id/ban",
+		"GET /admin/videos/:id/encoding-ladder",
+		"GET /admin/videos/:id/stages",
+		"POST /uploads",
+		"HEAD /uploads/:id",
+		"PATCH /uploads/:id",
+		"DELETE /uploads/:id",
+		"POST /uploads/direct",
+		"POST /uploads/direct/:id/complete",
+		"DELETE /uploads/direct/:id",
+		"PUT /uploads/direct/parts/:uploadId/:part",
 	}
 	for _, want := range wanted {
 		method, path, _ := strings.Cut(want, " ")
@@ -88,6 +110,35 @@ func TestHandlerRegistersVersionedAndAliasRoutes(t *testing.T) {
 	}
 }
 
+// TestHandlerRegistersLiveRoutesOnlyWhenEnabled pins that live streaming is
+// off unless configured, and that its routes, including the ingest hook
+// beside /live/:id, register without conflict when it is on.
+func TestHandlerRegistersLiveRoutesOnlyWhenEnabled(t *testing.T) {
+	wanted := []string{
+		"POST /api/v1/live",
+		"GET /api/v1/live",
+		"GET /api/v1/live/:id",
+		"POST /api/v1/live/ingest/auth",
+		"POST /api/live/ingest/auth",
+	}
+
+	for _, enabled := range []bool{false, true} {
+		a := testApp()
+		a.cfg.Live.Enabled = enabled
+		router := a.Handler().(*gin.Engine)
+
+		registered := make(map[string]bool)
+		for _, r := range router.Routes() {
+			registered[r.Method+" "+r.Path] = true
+		}
+		for _, want := range wanted {
+			if registered[want] != enabled {
+				t.Errorf("with live enabled=%t, route %s registered = %t", enabled, want, registered[want])
+			}
+		}
+	}
+}
+
 // TestHandlerAnswersPreflight pins that OPTIONS is answered with the CORS
 // grant on every path — matched or not — because browsers preflight routes
 // that only register GET, and an unanswered preflight blocks the real call.
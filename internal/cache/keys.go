@@ -0,0 +1,35 @@
+package cache
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// Playlist names PlaylistKey accepts besides a rendition name.
+const (
+	MasterPlaylist = "master"
+	DASHManifest   = "dash"
+)
+
+// PlaylistKey is the cache key of one of a video's playlists: MasterPlaylist,
+// DASHManifest, or a rendition's media playlist by its name. The API caches
+// under these keys and the worker deletes the ones it rewrites.
+func PlaylistKey(videoID uuid.UUID, playlist string) string {
+	return fmt.Sprintf("playlist:%s:%s", videoID, playlist)
+}
+
+// MediaPlaylistKey is the cache key of a rendition's media playlist in one
+// output revision of a video. Revision 0 keeps the key it had before there
+// were revisions.
+func MediaPlaylistKey(videoID uuid.UUID, revision int, rendition string) string {
+	if revision == 0 {
+		return PlaylistKey(videoID, rendition)
+	}
+	return PlaylistKey(videoID, fmt.Sprintf("r%d/%s", revision, rendition))
+}
+
+// PlaylistPattern matches every cached playlist of a video.
+func PlaylistPattern(videoID uuid.UUID) string {
+	return PlaylistKey(videoID, "*")
+}
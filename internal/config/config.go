@@ -9,6 +9,9 @@ import (
 	"net"
 	"net/url"
 	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -20,6 +23,23 @@ import (
 // validation: secrets must be set explicitly and TLS must not be disabled.
 const EnvProduction = "production"
 
+// Worker packaging modes; see WorkerConfig.Packaging.
+const (
+	PackagingTS   = "ts"
+	PackagingCMAF = "cmaf"
+)
+
+// Priority tiers, each an asynq queue of its own: a job's priority decides
+// which one it is enqueued on, and the worker weights them 6:3:1.
+const (
+	TierCritical = "critical"
+	TierDefault  = "default"
+	TierLow      = "low"
+)
+
+// Tiers are the priority tiers from most to least urgent.
+var Tiers = []string{TierCritical, TierDefault, TierLow}
+
 // insecureDefaultJWTSecret is the development-only signing key. Validate
 // rejects it in production so a deploy can never silently sign tokens with a
 // value that is public in this repository.
@@ -95,6 +115,41 @@ type StorageConfig struct {
 	AllowedFormats []string
 	ThumbnailPath  string
 	TranscodedPath string
+	// UploadSessionTTL is how long a resumable upload may sit unfinished
+	// before its session and the chunks it already stored are reaped.
+	UploadSessionTTL time.Duration
+	// UploadChunkTimeout bounds a single resumable-upload chunk request. It
+	// replaces the server read timeout for that request only, since a large
+	// chunk over a slow link outlasts SERVER_READ_TIMEOUT by design.
+	UploadChunkTimeout time.Duration
+	// SigningKey authenticates URLs the API hands out for a client to use
+	// later without a bearer token, such as the local store's direct-upload
+	// part URLs. Empty outside production means a random per-process key.
+	SigningKey string
+	// PublicBaseURL is the origin clients reach the API at. URLs the local
+	// store signs are absolute under it, like the MinIO ones.
+	PublicBaseURL string
+	// ImportDropDir is the directory admins may import videos from by a path
+	// relative to it. Empty turns path imports off; URL imports don't need it.
+	ImportDropDir string
+	// ImportMaxRedirects caps the redirects a URL import follows.
+	ImportMaxRedirects int
+	// ImportTimeout bounds fetching one import source, download included.
+	ImportTimeout time.Duration
+	// AccelRedirectPrefix, when set, hands segment and MP4 bytes to nginx:
+	// once a request passes the access check it is answered with an
+	// X-Accel-Redirect to the object's key under this prefix, an internal
+	// nginx location that serves it from the uploads volume or MinIO. Empty
+	// means the API streams them itself.
+	AccelRedirectPrefix string
+	// SegmentCacheDir, when set with MinIO enabled, keeps copies of the
+	// media segments the API reads from MinIO in this directory, so a hot
+	// segment is fetched from the object store once rather than per viewer.
+	// Empty means every segment request reads from MinIO.
+	SegmentCacheDir string
+	// SegmentCacheSize caps the bytes the segment cache keeps; the least
+	// recently used segments are evicted to stay within it.
+	SegmentCacheSize int64
 }
 
 // AuthConfig governs token issuance and password handling. There was no auth
@@ -110,6 +165,11 @@ type AuthConfig struct {
 	// outage, because tokens get revoked precisely when they are presumed
 	// stolen.
 	RevocationFailOpen bool
+	// PlaybackTokenTTL is how long the tokens in a master playlist's URIs
+	// stay good past the length of the video, for a player to fetch the
+	// rest of its playlists and segments without a bearer token. They are
+	// signed with StorageConfig.SigningKey.
+	PlaybackTokenTTL time.Duration
 }
 
 // CORSConfig lists the origins permitted to make credentialed requests.
@@ -155,6 +215,15 @@ type MinIOConfig struct {
 	BucketRaw       string
 	BucketProcessed string
 	BucketThumbs    string
+	// PublicEndpoint is the host:port browsers reach MinIO at, for presigned
+	// upload URLs. Endpoint is often a name only the containers can resolve
+	// (minio:9000), and a presigned URL's signature covers the host, so the
+	// URLs must be signed for the public name from the start.
+	PublicEndpoint string
+	PublicUseSSL   bool
+	// Region is fixed rather than discovered so signing a URL never needs a
+	// round trip to the server.
+	Region string
 }
 
 // RateLimitConfig bounds request rates. Enforcement lives in the API process:
@@ -167,8 +236,126 @@ type RateLimitConfig struct {
 type WorkerConfig struct {
 	MaxConcurrentJobs int
 	JobTimeout        time.Duration
+	// Ladder is every rendition a video may be transcoded to. A job encodes
+	// the rungs it asks for, or all of them, skipping any taller than the
+	// source.
+	Ladder []Rendition
+	// JobThreads is the encoder threads one job may keep busy. Its rungs are
+	// encoded side by side, each by a stage of its own, and share them by
+	// frame area. The default shares the machine's CPUs evenly between
+	// WORKER_MAX_CONCURRENT_JOBS jobs.
+	JobThreads int
+	// ProgressiveMP4 additionally writes each rendition as a standalone MP4
+	// for /stream/:quality. HLS is always written; the MP4s are a remux of
+	// the same segments, so they cost disk but no encoding.
+	ProgressiveMP4 bool
+	// PerTitle analyses each video's complexity before encoding it and
+	// lowers the ladder's bitrates, or drops rungs, where the content does
+	// not need them. Off, every video gets the ladder as configured.
+	PerTitle bool
+	// TrickplayInterval is how far apart the seek-bar preview thumbnails are
+	// taken. Zero writes none.
+	TrickplayInterval time.Duration
+	// Packaging is how HLS output is segmented: PackagingTS for MPEG-TS
+	// segments, or PackagingCMAF for fragmented MP4 segments that an HLS
+	// playlist and a DASH manifest both reference.
+	Packaging string
+	// A source is rejected before anything is encoded when it runs longer
+	// than SourceMaxDuration, is larger than SourceMaxWidth by
+	// SourceMaxHeight in either orientation, is faster than
+	// SourceMaxFrameRate frames a second, or its video is in a codec
+	// SourceVideoCodecs, which holds ffprobe's codec names, does not list.
+	// A zero limit, or no codecs, does not limit.
+	SourceMaxDuration  time.Duration
+	SourceMaxWidth     int
+	SourceMaxHeight    int
+	SourceMaxFrameRate int
+	SourceVideoCodecs  []string
+	// HDRRendition adds, for an HDR source, a rung that keeps it HDR: HEVC
+	// Main 10 at the size and rates of the largest rung, beside the ladder,
+	// which is tone-mapped to SDR for H.264. HLS carries HEVC only in
+	// fragmented MP4, so it needs PackagingCMAF.
+	HDRRendition bool
+	// VideoCodecs are the codecs each video's ladder is encoded in. H.264 is
+	// always encoded, whether listed or not, so that every player has a
+	// rendition it can decode; CodecHEVC and CodecAV1 add a copy of the
+	// ladder in that codec, at lower bitrates for the same quality. Both are
+	// carried by HLS only in fragmented MP4, so they need PackagingCMAF.
+	// TierVideoCodecs overrides the list for the jobs of one priority tier,
+	// keyed by TierCritical, TierDefault or TierLow.
+	VideoCodecs     []string
+	TierVideoCodecs map[string][]string
+	// Loudnorm measures each video's audio in a stage of its own and, unless
+	// the uploader opted out, levels its renditions to LoudnessTarget LUFS
+	// integrated with true peaks at most LoudnessTruePeak dBTP: EBU R128's
+	// two-pass loudnorm. Off, audio is encoded at the level it came in.
+	Loudnorm         bool
+	LoudnessTarget   float64
+	LoudnessTruePeak float64
+	// OutputRetention is how long a video's output is kept after it is
+	// processed again and the new output replaces it, for viewers who were
+	// part way through the old one to finish.
+	OutputRetention time.Duration
+}
+
+// CodecsFor is the video codecs a job of the given priority tier is encoded
+// in: the tier's own list if it has one, else VideoCodecs.
+func (c WorkerConfig) CodecsFor(tier string) []string {
+	if codecs, ok := c.TierVideoCodecs[tier]; ok {
+		return codecs
+	}
+	return c.VideoCodecs
+}
+
+// defaultSourceVideoCodecs are the video codecs a source may be in unless
+// WORKER_SOURCE_VIDEO_CODECS says otherwise: those ffmpeg decodes well and
+// cameras, phones and editors export.
+var defaultSourceVideoCodecs = []string{
+	"h264", "hevc", "vp8", "vp9", "av1", "mpeg4", "mpeg2video", "prores", "dnxhd", "mjpeg",
 }
 
+// LiveConfig configures live streaming. Creators push RTMP or SRT to an
+// ingest server, MediaMTX in the compose file, which asks the API whether to
+// take each push and whom to let read it back; the worker reads the push
+// back over RTSP and transcodes it into the video's HLS output as it comes.
+// The output is plain HLS in whole segments; low-latency HLS partial
+// segments are not made, as ffmpeg's HLS muxer cannot write them.
+type LiveConfig struct {
+	Enabled bool
+	// IngestSecret is what the passwords the worker reads pushes back from
+	// the ingest server with are derived from, one per stream. Only the API
+	// and the worker know it; the ingest server and ffmpeg see only the
+	// derived passwords, which read one stream and publish none.
+	IngestSecret string
+	// RTMPURL and SRTURL are the ingest server's addresses as creators reach
+	// them, rtmp://host:1935 and srt://host:8890. They are only shown.
+	RTMPURL string
+	SRTURL  string
+	// PullURL is the ingest server's RTSP address as the worker reaches it.
+	PullURL string
+	// Ladder is the renditions a broadcast is transcoded to while it is on.
+	// Every rung is encoded at once, in real time, so it is kept shorter than
+	// the worker's; the video gets the full ladder when it is processed
+	// again from the recording afterwards.
+	Ladder []Rendition
+	// PlaylistSegments is how many of the newest segments a live media
+	// playlist lists when there is no DVR window.
+	PlaylistSegments int
+	// DVRWindow is how far back from the live edge a viewer can rewind a
+	// broadcast: its live media playlists list the segments of that much of
+	// it. One at least MaxDuration lists the whole broadcast, as an
+	// EXT-X-PLAYLIST-TYPE:EVENT playlist. Zero turns rewinding off, leaving
+	// PlaylistSegments.
+	DVRWindow time.Duration
+	// MaxDuration ends a broadcast that runs longer.
+	MaxDuration time.Duration
+}
+
+// defaultLiveLadder is the ladder broadcasts are transcoded to unless
+// LIVE_TRANSCODE_LADDER says otherwise.
+const defaultLiveLadder = "360p:640x360:800k:900k:1800k:30," +
+	"720p:1280x720:2800k:3000k:6000k:30"
+
 // MailConfig configures outgoing transactional email. An empty SMTPHost is a
 // valid state, not an error: the mailer then writes messages to the log, so an
 // environment with no mail server still boots.
@@ -198,6 +385,7 @@ type Config struct {
 	MinIO     MinIOConfig
 	RateLimit RateLimitConfig
 	Worker    WorkerConfig
+	Live      LiveConfig
 	Mail      MailConfig
 	LogLevel  string
 }
@@ -245,8 +433,18 @@ func Load() (*Config, error) {
 			AllowedFormats: getStringSliceEnv("STORAGE_ALLOWED_FORMATS", []string{
 				"video/mp4", "video/mpeg", "video/quicktime", "video/webm", "video/x-matroska",
 			}),
-			ThumbnailPath:  getEnv("STORAGE_THUMBNAIL_PATH", "./web/uploads/thumbnails"),
-			TranscodedPath: getEnv("STORAGE_TRANSCODED_PATH", "./web/uploads/transcoded"),
+			ThumbnailPath:       getEnv("STORAGE_THUMBNAIL_PATH", "./web/uploads/thumbnails"),
+			TranscodedPath:      getEnv("STORAGE_TRANSCODED_PATH", "./web/uploads/transcoded"),
+			UploadSessionTTL:    getDurationEnv("STORAGE_UPLOAD_SESSION_TTL", 24*time.Hour),
+			UploadChunkTimeout:  getDurationEnv("STORAGE_UPLOAD_CHUNK_TIMEOUT", 30*time.Minute),
+			SigningKey:          getEnv("STORAGE_SIGNING_KEY", ""),
+			PublicBaseURL:       getEnv("STORAGE_PUBLIC_BASE_URL", "http://localhost:8080"),
+			ImportDropDir:       getEnv("STORAGE_IMPORT_DROP_DIR", ""),
+			ImportMaxRedirects:  getIntEnv("STORAGE_IMPORT_MAX_REDIRECTS", 5),
+			ImportTimeout:       getDurationEnv("STORAGE_IMPORT_TIMEOUT", time.Hour),
+			AccelRedirectPrefix: getEnv("STORAGE_ACCEL_REDIRECT_PREFIX", ""),
+			SegmentCacheDir:     getEnv("STORAGE_SEGMENT_CACHE_DIR", ""),
+			SegmentCacheSize:    getInt64Env("STORAGE_SEGMENT_CACHE_SIZE", 10*1024*1024*1024),
 		},
 		Auth: AuthConfig{
 			JWTSecret:          getEnv("JWT_SECRET", insecureDefaultJWTSecret),
@@ -254,6 +452,7 @@ func Load() (*Config, error) {
 			AccessTokenTTL:     getDurationEnv("JWT_ACCESS_TOKEN_TTL", 15*time.Minute),
 			RefreshTokenTTL:    getDurationEnv("JWT_REFRESH_TOKEN_TTL", 7*24*time.Hour),
 			RevocationFailOpen: getBoolEnv("AUTH_REVOCATION_FAIL_OPEN", false),
+			PlaybackTokenTTL:   getDurationEnv("AUTH_PLAYBACK_TOKEN_TTL", time.Hour),
 		},
 		CORS: CORSConfig{
 			AllowedOrigins: getStringSliceEnv("CORS_ALLOWED_ORIGINS", []string{"http://localhost:8080"}),
@@ -274,13 +473,40 @@ func Load() (*Config, error) {
 			BucketRaw:       getEnv("MINIO_BUCKET_RAW", "videos-raw"),
 			BucketProcessed: getEnv("MINIO_BUCKET_PROCESSED", "videos-processed"),
 			BucketThumbs:    getEnv("MINIO_BUCKET_THUMBNAILS", "videos-thumbnails"),
+			Region:          getEnv("MINIO_REGION", "us-east-1"),
 		},
 		RateLimit: RateLimitConfig{
 			Enabled: getBoolEnv("RATE_LIMIT_ENABLED", true),
 		},
 		Worker: WorkerConfig{
-			MaxConcurrentJobs: getIntEnv("WORKER_MAX_CONCURRENT_JOBS", 4),
-			JobTimeout:        getDurationEnv("WORKER_JOB_TIMEOUT", 30*time.Minute),
+			MaxConcurrentJobs:  getIntEnv("WORKER_MAX_CONCURRENT_JOBS", 4),
+			JobTimeout:         getDurationEnv("WORKER_JOB_TIMEOUT", 30*time.Minute),
+			ProgressiveMP4:     getBoolEnv("WORKER_PROGRESSIVE_MP4", true),
+			PerTitle:           getBoolEnv("WORKER_PER_TITLE", true),
+			TrickplayInterval:  getDurationEnv("WORKER_TRICKPLAY_INTERVAL", 10*time.Second),
+			Packaging:          getEnv("WORKER_PACKAGING", PackagingTS),
+			SourceMaxDuration:  getDurationEnv("WORKER_SOURCE_MAX_DURATION", 4*time.Hour),
+			SourceMaxWidth:     getIntEnv("WORKER_SOURCE_MAX_WIDTH", 7680),
+			SourceMaxHeight:    getIntEnv("WORKER_SOURCE_MAX_HEIGHT", 4320),
+			SourceMaxFrameRate: getIntEnv("WORKER_SOURCE_MAX_FPS", 120),
+			SourceVideoCodecs:  getStringSliceEnv("WORKER_SOURCE_VIDEO_CODECS", defaultSourceVideoCodecs),
+			HDRRendition:       getBoolEnv("WORKER_HDR_RENDITION", false),
+			VideoCodecs:        getStringSliceEnv("WORKER_VIDEO_CODECS", []string{CodecH264}),
+			TierVideoCodecs:    getTierVideoCodecs(),
+			Loudnorm:           getBoolEnv("WORKER_LOUDNORM", true),
+			LoudnessTarget:     getFloatEnv("WORKER_LOUDNORM_TARGET", -23),
+			LoudnessTruePeak:   getFloatEnv("WORKER_LOUDNORM_TRUE_PEAK", -1),
+			OutputRetention:    getDurationEnv("WORKER_OUTPUT_RETENTION", 6*time.Hour),
+		},
+		Live: LiveConfig{
+			Enabled:          getBoolEnv("LIVE_ENABLED", false),
+			IngestSecret:     getEnv("LIVE_INGEST_SECRET", ""),
+			RTMPURL:          getEnv("LIVE_RTMP_URL", "rtmp://localhost:1935"),
+			SRTURL:           getEnv("LIVE_SRT_URL", "srt://localhost:8890"),
+			PullURL:          getEnv("LIVE_PULL_URL", "rtsp://localhost:8554"),
+			PlaylistSegments: getIntEnv("LIVE_PLAYLIST_SEGMENTS", 6),
+			DVRWindow:        getDurationEnv("LIVE_DVR_WINDOW", 12*time.Hour),
+			MaxDuration:      getDurationEnv("LIVE_MAX_DURATION", 12*time.Hour),
 		},
 		Mail: MailConfig{
 			SMTPHost:          getEnv("SMTP_HOST", ""),
@@ -295,6 +521,23 @@ func Load() (*Config, error) {
 		LogLevel: getEnv("LOG_LEVEL", "info"),
 	}
 
+	// The public endpoint defaults to the internal one, which is right
+	// whenever clients and the API reach MinIO by the same name.
+	cfg.MinIO.PublicEndpoint = getEnv("MINIO_PUBLIC_ENDPOINT", cfg.MinIO.Endpoint)
+	cfg.MinIO.PublicUseSSL = getBoolEnv("MINIO_PUBLIC_USE_SSL", cfg.MinIO.UseSSL)
+
+	ladder, err := ParseLadder(getEnv("WORKER_TRANSCODE_LADDER", defaultLadder))
+	if err != nil {
+		return nil, fmt.Errorf("WORKER_TRANSCODE_LADDER: %w", err)
+	}
+	cfg.Worker.Ladder = ladder
+	liveLadder, err := ParseLadder(getEnv("LIVE_TRANSCODE_LADDER", defaultLiveLadder))
+	if err != nil {
+		return nil, fmt.Errorf("LIVE_TRANSCODE_LADDER: %w", err)
+	}
+	cfg.Live.Ladder = liveLadder
+	cfg.Worker.JobThreads = getIntEnv("WORKER_JOB_THREADS", max(1, runtime.NumCPU()/max(1, cfg.Worker.MaxConcurrentJobs)))
+
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("config validation failed: %w", err)
 	}
@@ -317,18 +560,98 @@ func (c *Config) Validate() error {
 	if c.Storage.MaxFileSize <= 0 {
 		problems = append(problems, "STORAGE_MAX_FILE_SIZE must be positive")
 	}
+	if c.Storage.UploadSessionTTL <= 0 || c.Storage.UploadChunkTimeout <= 0 {
+		problems = append(problems, "STORAGE_UPLOAD_SESSION_TTL and STORAGE_UPLOAD_CHUNK_TIMEOUT must be positive")
+	}
+	if c.Storage.ImportDropDir != "" && !filepath.IsAbs(c.Storage.ImportDropDir) {
+		problems = append(problems, "STORAGE_IMPORT_DROP_DIR must be an absolute path")
+	}
+	if c.Storage.ImportMaxRedirects < 0 {
+		problems = append(problems, "STORAGE_IMPORT_MAX_REDIRECTS must not be negative")
+	}
+	// The import task's own timeout allows for this plus storing the file.
+	if c.Storage.ImportTimeout <= 0 || c.Storage.ImportTimeout > 2*time.Hour {
+		problems = append(problems, "STORAGE_IMPORT_TIMEOUT must be positive and at most 2h")
+	}
+	if p := c.Storage.AccelRedirectPrefix; p != "" && (!strings.HasPrefix(p, "/") || !strings.HasSuffix(p, "/")) {
+		problems = append(problems, "STORAGE_ACCEL_REDIRECT_PREFIX must start and end with /")
+	}
+	if c.Storage.SegmentCacheDir != "" && c.Storage.SegmentCacheSize <= 0 {
+		problems = append(problems, "STORAGE_SEGMENT_CACHE_SIZE must be positive when STORAGE_SEGMENT_CACHE_DIR is set")
+	}
+	// The cache fetches a segment when it is statted, which is also all
+	// the API does with one it hands to nginx, which then fetches it again.
+	if c.Storage.SegmentCacheDir != "" && c.Storage.AccelRedirectPrefix != "" {
+		problems = append(problems, "STORAGE_SEGMENT_CACHE_DIR and STORAGE_ACCEL_REDIRECT_PREFIX cannot both be set")
+	}
 	if c.Database.MaxIdleConns > c.Database.MaxOpenConns {
 		problems = append(problems, "DB_MAX_IDLE_CONNS must not exceed DB_MAX_OPEN_CONNS")
 	}
 	if c.Auth.AccessTokenTTL <= 0 {
 		problems = append(problems, "JWT_ACCESS_TOKEN_TTL must be positive")
 	}
+	if c.Auth.PlaybackTokenTTL <= 0 {
+		problems = append(problems, "AUTH_PLAYBACK_TOKEN_TTL must be positive")
+	}
 	if len(c.CORS.AllowedOrigins) == 0 {
 		problems = append(problems, "CORS_ALLOWED_ORIGINS must list at least one origin")
 	}
 	if c.MinIO.Enabled && (c.MinIO.AccessKeyID == "" || c.MinIO.SecretAccessKey == "") {
 		problems = append(problems, "MINIO_ACCESS_KEY and MINIO_SECRET_KEY are required when MINIO_ENABLED=true")
 	}
+	if len(c.Worker.Ladder) == 0 {
+		problems = append(problems, "WORKER_TRANSCODE_LADDER must list at least one rendition")
+	}
+	if c.Worker.JobThreads < 1 {
+		problems = append(problems, "WORKER_JOB_THREADS must be at least 1")
+	}
+	for _, rung := range c.Worker.Ladder {
+		if strings.HasPrefix(rung.Name, AudioRenditionPrefix) {
+			problems = append(problems, fmt.Sprintf("WORKER_TRANSCODE_LADDER must not name a rendition %q: names starting %q are audio tracks", rung.Name, AudioRenditionPrefix))
+		}
+		if strings.HasPrefix(rung.Name, CaptionRenditionPrefix) {
+			problems = append(problems, fmt.Sprintf("WORKER_TRANSCODE_LADDER must not name a rendition %q: names starting %q are captions", rung.Name, CaptionRenditionPrefix))
+		}
+	}
+	if c.Worker.TrickplayInterval < 0 {
+		problems = append(problems, "WORKER_TRICKPLAY_INTERVAL must not be negative")
+	}
+	switch c.Worker.Packaging {
+	case PackagingTS, PackagingCMAF:
+	default:
+		problems = append(problems, fmt.Sprintf("WORKER_PACKAGING must be %q or %q", PackagingTS, PackagingCMAF))
+	}
+	if c.Worker.HDRRendition && c.Worker.Packaging != PackagingCMAF {
+		problems = append(problems, fmt.Sprintf("WORKER_HDR_RENDITION needs WORKER_PACKAGING=%s: HLS carries HEVC only in fragmented MP4", PackagingCMAF))
+	}
+	problems = append(problems, validateVideoCodecs("WORKER_VIDEO_CODECS", c.Worker.VideoCodecs, c.Worker.Packaging)...)
+	for _, tier := range Tiers {
+		if codecs, ok := c.Worker.TierVideoCodecs[tier]; ok {
+			problems = append(problems, validateVideoCodecs(tierVideoCodecsEnv(tier), codecs, c.Worker.Packaging)...)
+		}
+	}
+	if c.Worker.SourceMaxDuration < 0 {
+		problems = append(problems, "WORKER_SOURCE_MAX_DURATION must not be negative")
+	}
+	if c.Worker.SourceMaxWidth < 0 || c.Worker.SourceMaxHeight < 0 {
+		problems = append(problems, "WORKER_SOURCE_MAX_WIDTH and WORKER_SOURCE_MAX_HEIGHT must not be negative")
+	}
+	if c.Worker.SourceMaxFrameRate < 0 {
+		problems = append(problems, "WORKER_SOURCE_MAX_FPS must not be negative")
+	}
+	// The ranges loudnorm accepts.
+	if c.Worker.LoudnessTarget < -70 || c.Worker.LoudnessTarget > -5 {
+		problems = append(problems, "WORKER_LOUDNORM_TARGET must be between -70 and -5 LUFS")
+	}
+	if c.Worker.LoudnessTruePeak < -9 || c.Worker.LoudnessTruePeak > 0 {
+		problems = append(problems, "WORKER_LOUDNORM_TRUE_PEAK must be between -9 and 0 dBTP")
+	}
+	if c.Worker.OutputRetention < 0 {
+		problems = append(problems, "WORKER_OUTPUT_RETENTION must not be negative")
+	}
+	if c.Live.Enabled {
+		problems = append(problems, c.Live.validate()...)
+	}
 	if c.Mail.PasswordResetTTL <= 0 {
 		problems = append(problems, "MAIL_PASSWORD_RESET_TTL must be positive")
 	}
@@ -350,6 +673,11 @@ func (c *Config) Validate() error {
 		if len(c.Auth.JWTSecret) < 32 {
 			problems = append(problems, "JWT_SECRET must be at least 32 characters in production")
 		}
+		// A random per-process key would make URLs signed by one replica
+		// unverifiable by the next.
+		if len(c.Storage.SigningKey) < 32 {
+			problems = append(problems, "STORAGE_SIGNING_KEY must be at least 32 characters in production")
+		}
 		if c.CORS.AllowsAnyOrigin() {
 			problems = append(problems, `CORS_ALLOWED_ORIGINS must not be "*" in production: credentialed requests require an explicit origin allowlist`)
 		}
@@ -371,6 +699,92 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// maxLiveDuration bounds LIVE_MAX_DURATION. A broadcast holds one of the
+// worker's job slots for as long as it lasts.
+const maxLiveDuration = 24 * time.Hour
+
+// validate checks the live settings, which only matter with live streaming
+// on.
+func (c LiveConfig) validate() []string {
+	var problems []string
+	// The secret is all that stands between the ingest server and anyone
+	// who would read a broadcast off it without going through the API.
+	if len(c.IngestSecret) < 16 {
+		problems = append(problems, "LIVE_INGEST_SECRET must be at least 16 characters when LIVE_ENABLED=true")
+	}
+	for _, setting := range []struct {
+		variable, value string
+		schemes         []string
+	}{
+		{"LIVE_RTMP_URL", c.RTMPURL, []string{"rtmp", "rtmps"}},
+		{"LIVE_SRT_URL", c.SRTURL, []string{"srt"}},
+		{"LIVE_PULL_URL", c.PullURL, []string{"rtsp", "rtsps"}},
+	} {
+		u, err := url.Parse(setting.value)
+		if err != nil || u.Host == "" || !slices.Contains(setting.schemes, u.Scheme) {
+			problems = append(problems, fmt.Sprintf("%s must be a %s URL with a host", setting.variable, strings.Join(setting.schemes, " or ")))
+		}
+	}
+	if len(c.Ladder) == 0 {
+		problems = append(problems, "LIVE_TRANSCODE_LADDER must list at least one rendition")
+	}
+	for _, rung := range c.Ladder {
+		if strings.HasPrefix(rung.Name, AudioRenditionPrefix) || strings.HasPrefix(rung.Name, CaptionRenditionPrefix) {
+			problems = append(problems, fmt.Sprintf("LIVE_TRANSCODE_LADDER must not name a rendition %q: names starting %q or %q are audio tracks and captions", rung.Name, AudioRenditionPrefix, CaptionRenditionPrefix))
+		}
+	}
+	// RFC 8216 has a player start three target durations from the end of a
+	// live playlist, so it must list at least that many.
+	if c.PlaylistSegments < 3 {
+		problems = append(problems, "LIVE_PLAYLIST_SEGMENTS must be at least 3")
+	}
+	if c.MaxDuration <= 0 || c.MaxDuration > maxLiveDuration {
+		problems = append(problems, "LIVE_MAX_DURATION must be positive and at most 24h")
+	}
+	// A window shorter than a minute is hardly a rewind, and is better had
+	// from LIVE_PLAYLIST_SEGMENTS.
+	if c.DVRWindow != 0 && (c.DVRWindow < time.Minute || c.DVRWindow > maxLiveDuration) {
+		problems = append(problems, "LIVE_DVR_WINDOW must be 0, or between 1m and 24h")
+	}
+	return problems
+}
+
+// validateVideoCodecs checks one list of video codecs, named after the
+// variable it was read from.
+func validateVideoCodecs(variable string, codecs []string, packaging string) []string {
+	var problems []string
+	for _, codec := range codecs {
+		switch codec {
+		case CodecH264:
+		case CodecHEVC, CodecAV1:
+			if packaging != PackagingCMAF {
+				problems = append(problems, fmt.Sprintf("%s lists %s, which needs WORKER_PACKAGING=%s: HLS carries it only in fragmented MP4", variable, codec, PackagingCMAF))
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("%s lists %q: codecs are %q, %q and %q", variable, codec, CodecH264, CodecHEVC, CodecAV1))
+		}
+	}
+	return problems
+}
+
+// tierVideoCodecsEnv is the variable that overrides WORKER_VIDEO_CODECS for
+// one priority tier: WORKER_VIDEO_CODECS_CRITICAL and so on.
+func tierVideoCodecsEnv(tier string) string {
+	return "WORKER_VIDEO_CODECS_" + strings.ToUpper(tier)
+}
+
+// getTierVideoCodecs reads the video codecs of each priority tier that has
+// its own, and leaves out the rest.
+func getTierVideoCodecs() map[string][]string {
+	tiers := make(map[string][]string)
+	for _, tier := range Tiers {
+		if codecs := getStringSliceEnv(tierVideoCodecsEnv(tier), nil); codecs != nil {
+			tiers[tier] = codecs
+		}
+	}
+	return tiers
+}
+
 func getEnv(key, defaultValue string) string {
 	if value, ok := os.LookupEnv(key); ok {
 		return value
@@ -396,6 +810,15 @@ func getInt64Env(key string, defaultValue int64) int64 {
 	return defaultValue
 }
 
+func getFloatEnv(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
 func getBoolEnv(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
 		if parsed, err := strconv.ParseBool(value); err == nil {
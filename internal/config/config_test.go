@@ -31,16 +31,40 @@ func validConfig() *Config {
 			MaxIdleConns: 5,
 		},
 		Storage: StorageConfig{
-			MaxFileSize: 2 * 1024 * 1024 * 1024,
+			MaxFileSize:        2 * 1024 * 1024 * 1024,
+			UploadSessionTTL:   24 * time.Hour,
+			UploadChunkTimeout: 30 * time.Minute,
+			ImportMaxRedirects: 5,
+			ImportTimeout:      time.Hour,
+			SegmentCacheSize:   10 * 1024 * 1024 * 1024,
 		},
 		Auth: AuthConfig{
-			JWTSecret:      insecureDefaultJWTSecret,
-			JWTIssuer:      "video-streaming-service",
-			AccessTokenTTL: 15 * time.Minute,
+			JWTSecret:        insecureDefaultJWTSecret,
+			JWTIssuer:        "video-streaming-service",
+			AccessTokenTTL:   15 * time.Minute,
+			PlaybackTokenTTL: time.Hour,
 		},
 		CORS: CORSConfig{
 			AllowedOrigins: []string{"http://localhost:8080"},
 		},
+		Worker: WorkerConfig{
+			MaxConcurrentJobs: 4,
+			Ladder:            []Rendition{{Name: "720p", Width: 1280, Height: 720, BitrateKbps: 2800, MaxRateKbps: 3000, BufSizeKbps: 6000, FPS: 30}},
+			JobThreads:        2,
+			Packaging:         PackagingTS,
+			LoudnessTarget:    -23,
+			LoudnessTruePeak:  -1,
+		},
+		Live: LiveConfig{
+			IngestSecret:     "ingest-secret-for-tests",
+			RTMPURL:          "rtmp://localhost:1935",
+			SRTURL:           "srt://localhost:8890",
+			PullURL:          "rtsp://mediamtx:8554",
+			Ladder:           []Rendition{{Name: "720p", Width: 1280, Height: 720, BitrateKbps: 2800, MaxRateKbps: 3000, BufSizeKbps: 6000, FPS: 30}},
+			PlaylistSegments: 6,
+			DVRWindow:        2 * time.Hour,
+			MaxDuration:      12 * time.Hour,
+		},
 		Mail: MailConfig{
 			PasswordResetTTL: time.Hour,
 		},
@@ -52,6 +76,7 @@ func productionConfig() *Config {
 	cfg := validConfig()
 	cfg.Server.Environment = EnvProduction
 	cfg.Auth.JWTSecret = productionSecret
+	cfg.Storage.SigningKey = productionSecret
 	cfg.Database.SSLMode = "require"
 	cfg.CORS.AllowedOrigins = []string{"https://videos.example.com"}
 	return cfg
@@ -215,6 +240,105 @@ func TestConfigValidateDevelopment(t *testing.T) {
 			mutate:  func(c *Config) { c.Storage.MaxFileSize = 0 },
 			wantErr: "STORAGE_MAX_FILE_SIZE",
 		},
+		{
+			name:    "non-positive upload session ttl rejected",
+			mutate:  func(c *Config) { c.Storage.UploadSessionTTL = 0 },
+			wantErr: "STORAGE_UPLOAD_SESSION_TTL",
+		},
+		{
+			name:    "relative import drop directory rejected",
+			mutate:  func(c *Config) { c.Storage.ImportDropDir = "imports" },
+			wantErr: "STORAGE_IMPORT_DROP_DIR",
+		},
+		{
+			name:    "negative import redirect cap rejected",
+			mutate:  func(c *Config) { c.Storage.ImportMaxRedirects = -1 },
+			wantErr: "STORAGE_IMPORT_MAX_REDIRECTS",
+		},
+		{
+			name:    "import timeout past the task's rejected",
+			mutate:  func(c *Config) { c.Storage.ImportTimeout = 3 * time.Hour },
+			wantErr: "STORAGE_IMPORT_TIMEOUT",
+		},
+		{
+			name:    "accel redirect prefix that is not a location rejected",
+			mutate:  func(c *Config) { c.Storage.AccelRedirectPrefix = "_media" },
+			wantErr: "STORAGE_ACCEL_REDIRECT_PREFIX",
+		},
+		{
+			name: "segment cache without a size rejected",
+			mutate: func(c *Config) {
+				c.Storage.SegmentCacheDir = "/var/cache/segments"
+				c.Storage.SegmentCacheSize = 0
+			},
+			wantErr: "STORAGE_SEGMENT_CACHE_SIZE",
+		},
+		{
+			name: "segment cache alongside nginx offload rejected",
+			mutate: func(c *Config) {
+				c.Storage.SegmentCacheDir = "/var/cache/segments"
+				c.Storage.AccelRedirectPrefix = "/_media/"
+			},
+			wantErr: "cannot both be set",
+		},
+		{
+			name:   "live streaming with its settings accepted",
+			mutate: func(c *Config) { c.Live.Enabled = true },
+		},
+		{
+			name: "live settings unchecked while live streaming is off",
+			mutate: func(c *Config) {
+				c.Live.IngestSecret = ""
+				c.Live.Ladder = nil
+			},
+		},
+		{
+			name: "live streaming without an ingest secret rejected",
+			mutate: func(c *Config) {
+				c.Live.Enabled = true
+				c.Live.IngestSecret = "short"
+			},
+			wantErr: "LIVE_INGEST_SECRET",
+		},
+		{
+			name: "live pull URL that is not RTSP rejected",
+			mutate: func(c *Config) {
+				c.Live.Enabled = true
+				c.Live.PullURL = "rtmp://mediamtx:1935"
+			},
+			wantErr: "LIVE_PULL_URL",
+		},
+		{
+			name: "live playlist shorter than three segments rejected",
+			mutate: func(c *Config) {
+				c.Live.Enabled = true
+				c.Live.PlaylistSegments = 2
+			},
+			wantErr: "LIVE_PLAYLIST_SEGMENTS",
+		},
+		{
+			name: "live broadcast longer than a day rejected",
+			mutate: func(c *Config) {
+				c.Live.Enabled = true
+				c.Live.MaxDuration = 48 * time.Hour
+			},
+			wantErr: "LIVE_MAX_DURATION",
+		},
+		{
+			name: "live DVR window turned off accepted",
+			mutate: func(c *Config) {
+				c.Live.Enabled = true
+				c.Live.DVRWindow = 0
+			},
+		},
+		{
+			name: "live DVR window under a minute rejected",
+			mutate: func(c *Config) {
+				c.Live.Enabled = true
+				c.Live.DVRWindow = 30 * time.Second
+			},
+			wantErr: "LIVE_DVR_WINDOW",
+		},
 		{
 			name:    "idle conns above open conns rejected",
 			mutate:  func(c *Config) { c.Database.MaxIdleConns = 50 },
@@ -225,6 +349,11 @@ func TestConfigValidateDevelopment(t *testing.T) {
 			mutate:  func(c *Config) { c.Auth.AccessTokenTTL = 0 },
 			wantErr: "JWT_ACCESS_TOKEN_TTL",
 		},
+		{
+			name:    "non-positive playback token TTL rejected",
+			mutate:  func(c *Config) { c.Auth.PlaybackTokenTTL = 0 },
+			wantErr: "AUTH_PLAYBACK_TOKEN_TTL",
+		},
 		{
 			name:    "empty CORS origins rejected",
 			mutate:  func(c *Config) { c.CORS.AllowedOrigins = nil },
@@ -252,6 +381,95 @@ func TestConfigValidateDevelopment(t *testing.T) {
 			mutate:  func(c *Config) { c.Mail.PasswordResetTTL = 0 },
 			wantErr: "MAIL_PASSWORD_RESET_TTL",
 		},
+		{
+			name:    "empty transcoding ladder rejected",
+			mutate:  func(c *Config) { c.Worker.Ladder = nil },
+			wantErr: "WORKER_TRANSCODE_LADDER",
+		},
+		{
+			name:    "job without encoder threads rejected",
+			mutate:  func(c *Config) { c.Worker.JobThreads = 0 },
+			wantErr: "WORKER_JOB_THREADS",
+		},
+		{
+			name:    "unknown packaging rejected",
+			mutate:  func(c *Config) { c.Worker.Packaging = "fmp4" },
+			wantErr: "WORKER_PACKAGING",
+		},
+		{
+			name:    "negative trickplay interval rejected",
+			mutate:  func(c *Config) { c.Worker.TrickplayInterval = -time.Second },
+			wantErr: "WORKER_TRICKPLAY_INTERVAL",
+		},
+		{
+			name:    "loudness target above what loudnorm accepts rejected",
+			mutate:  func(c *Config) { c.Worker.LoudnessTarget = -3 },
+			wantErr: "WORKER_LOUDNORM_TARGET",
+		},
+		{
+			name:    "negative source frame rate limit rejected",
+			mutate:  func(c *Config) { c.Worker.SourceMaxFrameRate = -1 },
+			wantErr: "WORKER_SOURCE_MAX_FPS",
+		},
+		{
+			name:   "cmaf packaging accepted",
+			mutate: func(c *Config) { c.Worker.Packaging = PackagingCMAF },
+		},
+		{
+			name:    "HDR rendition without cmaf packaging rejected",
+			mutate:  func(c *Config) { c.Worker.HDRRendition = true },
+			wantErr: "WORKER_HDR_RENDITION",
+		},
+		{
+			name: "HDR rendition with cmaf packaging accepted",
+			mutate: func(c *Config) {
+				c.Worker.Packaging = PackagingCMAF
+				c.Worker.HDRRendition = true
+			},
+		},
+		{
+			name:    "unknown video codec rejected",
+			mutate:  func(c *Config) { c.Worker.VideoCodecs = []string{CodecH264, "vp9"} },
+			wantErr: "WORKER_VIDEO_CODECS",
+		},
+		{
+			name:    "AV1 ladder without cmaf packaging rejected",
+			mutate:  func(c *Config) { c.Worker.VideoCodecs = []string{CodecH264, CodecAV1} },
+			wantErr: "WORKER_VIDEO_CODECS",
+		},
+		{
+			name: "HEVC ladder for one tier without cmaf packaging rejected",
+			mutate: func(c *Config) {
+				c.Worker.TierVideoCodecs = map[string][]string{TierCritical: {CodecHEVC}}
+			},
+			wantErr: "WORKER_VIDEO_CODECS_CRITICAL",
+		},
+		{
+			name: "HEVC and AV1 ladders with cmaf packaging accepted",
+			mutate: func(c *Config) {
+				c.Worker.Packaging = PackagingCMAF
+				c.Worker.VideoCodecs = []string{CodecH264, CodecHEVC}
+				c.Worker.TierVideoCodecs = map[string][]string{TierCritical: {CodecH264, CodecHEVC, CodecAV1}}
+			},
+		},
+		{
+			name:    "rendition named like an audio track rejected",
+			mutate:  func(c *Config) { c.Worker.Ladder[0].Name = AudioRenditionPrefix + "0" },
+			wantErr: "WORKER_TRANSCODE_LADDER",
+		},
+		{
+			name: "cmaf packaging refuses a rendition named like an audio track",
+			mutate: func(c *Config) {
+				c.Worker.Packaging = PackagingCMAF
+				c.Worker.Ladder[0].Name = AudioRenditionPrefix + "main"
+			},
+			wantErr: "WORKER_TRANSCODE_LADDER",
+		},
+		{
+			name:    "rendition named like a caption rejected",
+			mutate:  func(c *Config) { c.Worker.Ladder[0].Name = CaptionRenditionPrefix + "en" },
+			wantErr: "WORKER_TRANSCODE_LADDER",
+		},
 		{
 			name: "trusted proxies accept IPs and CIDR ranges",
 			mutate: func(c *Config) {
@@ -320,6 +538,11 @@ func TestConfigValidateProduction(t *testing.T) {
 			name:   "JWT secret of exactly 32 characters accepted",
 			mutate: func(c *Config) { c.Auth.JWTSecret = strings.Repeat("s", 32) },
 		},
+		{
+			name:    "missing storage signing key rejected",
+			mutate:  func(c *Config) { c.Storage.SigningKey = "" },
+			wantErr: "STORAGE_SIGNING_KEY must be at least 32 characters",
+		},
 		{
 			name:    "wildcard CORS origin rejected",
 			mutate:  func(c *Config) { c.CORS.AllowedOrigins = []string{"*"} },
@@ -510,6 +733,52 @@ func TestCORSConfigAllowsAnyOrigin(t *testing.T) {
 	}
 }
 
+func TestParseLadder(t *testing.T) {
+	ladder, err := ParseLadder(defaultLadder)
+	if err != nil {
+		t.Fatalf("ParseLadder(default) unexpected error: %v", err)
+	}
+	// The default must stay the ladder the worker encoded before it was
+	// configurable, or existing deployments change output silently.
+	want := []Rendition{
+		{Name: "360p", Width: 640, Height: 360, BitrateKbps: 800, MaxRateKbps: 900, BufSizeKbps: 1800, FPS: 30},
+		{Name: "480p", Width: 854, Height: 480, BitrateKbps: 1400, MaxRateKbps: 1500, BufSizeKbps: 3000, FPS: 30},
+		{Name: "720p", Width: 1280, Height: 720, BitrateKbps: 2800, MaxRateKbps: 3000, BufSizeKbps: 6000, FPS: 30},
+		{Name: "1080p", Width: 1920, Height: 1080, BitrateKbps: 5000, MaxRateKbps: 5500, BufSizeKbps: 11000, FPS: 60},
+	}
+	if len(ladder) != len(want) {
+		t.Fatalf("default ladder has %d rungs, want %d", len(ladder), len(want))
+	}
+	for i := range want {
+		if ladder[i] != want[i] {
+			t.Errorf("rung %d = %+v, want %+v", i, ladder[i], want[i])
+		}
+	}
+	if got := FormatLadder(ladder); got != defaultLadder {
+		t.Errorf("FormatLadder(default) = %q, want %q", got, defaultLadder)
+	}
+
+	bad := map[string]string{
+		"empty":             " , ",
+		"missing fields":    "720p:1280x720:2800k:3000k",
+		"bad size":          "720p:1280-720:2800k:3000k:6000k:30",
+		"odd width":         "720p:1279x720:2800k:3000k:6000k:30",
+		"zero fps":          "720p:1280x720:2800k:3000k:6000k:0",
+		"maxrate too low":   "720p:1280x720:2800k:2000k:6000k:30",
+		"unsafe name":       "../720p:1280x720:2800k:3000k:6000k:30",
+		"duplicate name":    "720p:1280x720:2800k:3000k:6000k:30,720p:1280x720:2000k:2200k:4400k:30",
+		"non-numeric rate":  "720p:1280x720:fast:3000k:6000k:30",
+		"uppercase in name": "HD:1280x720:2800k:3000k:6000k:30",
+	}
+	for name, value := range bad {
+		t.Run(name, func(t *testing.T) {
+			if ladder, err := ParseLadder(value); err == nil {
+				t.Errorf("ParseLadder(%q) = %+v, want an error", value, ladder)
+			}
+		})
+	}
+}
+
 func TestRedisConfigAddress(t *testing.T) {
 	c := RedisConfig{Host: "redis.internal", Port: "6379"}
 	if got, want := c.Address(), "redis.internal:6379"; got != want {
@@ -539,6 +808,9 @@ func TestLoad(t *testing.T) {
 		if cfg.Server.IsProduction() {
 			t.Error("IsProduction() = true, want false")
 		}
+		if cfg.Live.Enabled || len(cfg.Live.Ladder) != 2 {
+			t.Errorf("Live = enabled %v with %d rungs, want off with the default two", cfg.Live.Enabled, len(cfg.Live.Ladder))
+		}
 	})
 
 	t.Run("environment overrides are applied", func(t *testing.T) {
@@ -580,6 +852,63 @@ func TestLoad(t *testing.T) {
 		}
 	})
 
+	t.Run("MinIO public endpoint defaults to the internal one", func(t *testing.T) {
+		t.Setenv("MINIO_ENDPOINT", "minio:9000")
+		t.Setenv("MINIO_USE_SSL", "true")
+
+		cfg, err := Load()
+		if err != nil {
+			t.Fatalf("Load() unexpected error: %v", err)
+		}
+		if cfg.MinIO.PublicEndpoint != "minio:9000" || !cfg.MinIO.PublicUseSSL {
+			t.Errorf("public endpoint = %q (ssl %v), want minio:9000 (ssl true)", cfg.MinIO.PublicEndpoint, cfg.MinIO.PublicUseSSL)
+		}
+
+		t.Setenv("MINIO_PUBLIC_ENDPOINT", "media.example.com")
+		if cfg, err = Load(); err != nil {
+			t.Fatalf("Load() unexpected error: %v", err)
+		}
+		if cfg.MinIO.PublicEndpoint != "media.example.com" {
+			t.Errorf("PublicEndpoint = %q, want the configured one", cfg.MinIO.PublicEndpoint)
+		}
+	})
+
+	t.Run("transcoding ladder and job threads are configurable", func(t *testing.T) {
+		t.Setenv("ENVIRONMENT", "development")
+
+		cfg, err := Load()
+		if err != nil {
+			t.Fatalf("Load() unexpected error: %v", err)
+		}
+		if len(cfg.Worker.Ladder) != 4 || cfg.Worker.Ladder[3].Name != "1080p" || cfg.Worker.Ladder[3].FPS != 60 {
+			t.Errorf("default ladder = %+v, want 360p through 1080p", cfg.Worker.Ladder)
+		}
+		if cfg.Worker.JobThreads < 1 {
+			t.Errorf("default JobThreads = %d, want at least 1", cfg.Worker.JobThreads)
+		}
+
+		t.Setenv("WORKER_TRANSCODE_LADDER", "240p:426x240:400k:450k:900k:24, 2160p:3840x2160:16000:18000:32000:30")
+		t.Setenv("WORKER_JOB_THREADS", "12")
+		if cfg, err = Load(); err != nil {
+			t.Fatalf("Load() unexpected error: %v", err)
+		}
+		want := []Rendition{
+			{Name: "240p", Width: 426, Height: 240, BitrateKbps: 400, MaxRateKbps: 450, BufSizeKbps: 900, FPS: 24},
+			{Name: "2160p", Width: 3840, Height: 2160, BitrateKbps: 16000, MaxRateKbps: 18000, BufSizeKbps: 32000, FPS: 30},
+		}
+		if len(cfg.Worker.Ladder) != len(want) || cfg.Worker.Ladder[0] != want[0] || cfg.Worker.Ladder[1] != want[1] {
+			t.Errorf("Ladder = %+v, want %+v", cfg.Worker.Ladder, want)
+		}
+		if cfg.Worker.JobThreads != 12 {
+			t.Errorf("JobThreads = %d, want 12", cfg.Worker.JobThreads)
+		}
+
+		t.Setenv("WORKER_TRANSCODE_LADDER", "720p:1280x720:2800k")
+		if _, err := Load(); err == nil || !strings.Contains(err.Error(), "WORKER_TRANSCODE_LADDER") {
+			t.Errorf("Load() with a malformed ladder = %v, want an error naming WORKER_TRANSCODE_LADDER", err)
+		}
+	})
+
 	t.Run("production without a JWT secret fails to load", func(t *testing.T) {
 		t.Setenv("ENVIRONMENT", "production")
 		t.Setenv("DB_SSLMODE", "require")
@@ -597,6 +926,7 @@ func TestLoad(t *testing.T) {
 	t.Run("fully configured production loads", func(t *testing.T) {
 		t.Setenv("ENVIRONMENT", "production")
 		t.Setenv("JWT_SECRET", productionSecret)
+		t.Setenv("STORAGE_SIGNING_KEY", productionSecret)
 		t.Setenv("DB_SSLMODE", "require")
 		t.Setenv("CORS_ALLOWED_ORIGINS", "https://videos.example.com")
 
@@ -0,0 +1,169 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Rendition is one rung of the transcoding ladder: the frame size, rate
+// control and frame rate a single output is encoded at. Rates are in kbit/s.
+// FPS is a ceiling: a rung keeps the source's frame rate, or the largest
+// whole fraction of it, that does not exceed FPS.
+//
+// Codec and VideoRange are not part of the ladder syntax. A rung parsed from
+// it is H.264 in SDR, which is what their zero values mean; the worker sets
+// them on the rungs it adds of its own, such as the HDR rung.
+type Rendition struct {
+	Name        string
+	Width       int
+	Height      int
+	BitrateKbps int
+	MaxRateKbps int
+	BufSizeKbps int
+	FPS         int
+	Codec       string
+	VideoRange  string
+}
+
+// Video codecs a rendition is encoded in; see Rendition.Codec.
+const (
+	CodecH264 = "h264"
+	CodecHEVC = "hevc"
+	CodecAV1  = "av1"
+)
+
+// Dynamic ranges a rendition is mastered in, as HLS's VIDEO-RANGE names
+// them: SDR, or HDR with the PQ (HDR10) or HLG transfer function.
+const (
+	VideoRangeSDR = "SDR"
+	VideoRangePQ  = "PQ"
+	VideoRangeHLG = "HLG"
+)
+
+// HDR reports whether the rendition keeps the source's high dynamic range.
+func (r Rendition) HDR() bool {
+	return r.VideoRange == VideoRangePQ || r.VideoRange == VideoRangeHLG
+}
+
+// Pixels is the rendition's frame area, the usual proxy for how much work it
+// takes to encode relative to its siblings.
+func (r Rendition) Pixels() int {
+	return r.Width * r.Height
+}
+
+// defaultLadder is the ladder the worker has always encoded. It is written in
+// the WORKER_TRANSCODE_LADDER syntax so the default and an override read the
+// same way.
+const defaultLadder = "360p:640x360:800k:900k:1800k:30," +
+	"480p:854x480:1400k:1500k:3000k:30," +
+	"720p:1280x720:2800k:3000k:6000k:30," +
+	"1080p:1920x1080:5000k:5500k:11000k:60"
+
+// renditionNamePattern keeps rendition names usable as a path segment: they
+// name the output directory and appear in playlist and segment URLs.
+var renditionNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// AudioRenditionPrefix starts the name of every audio rendition: each audio
+// track is written to a directory of its own beside the video renditions, as
+// audio_0, audio_1 and so on. No video rendition may take such a name.
+const AudioRenditionPrefix = "audio_"
+
+// CaptionRenditionPrefix does the same for captions, which are written as
+// subs_0, subs_1 and so on.
+const CaptionRenditionPrefix = "subs_"
+
+// ValidRenditionName reports whether name could be the name of a rendition.
+// Handlers use it to reject a quality in a URL before it reaches a storage
+// key, whatever the worker's ladder happens to be.
+func ValidRenditionName(name string) bool {
+	return renditionNamePattern.MatchString(name)
+}
+
+// ParseLadder parses a comma-separated list of renditions, each written as
+// name:WIDTHxHEIGHT:bitrate:maxrate:bufsize:fps, with rates in kbit/s and an
+// optional "k" suffix as ffmpeg spells them:
+//
+//	360p:640x360:800k:900k:1800k:30,720p:1280x720:2800k:3000k:6000k:30
+//
+// Rungs may be listed in any order; names must be unique.
+func ParseLadder(value string) ([]Rendition, error) {
+	var ladder []Rendition
+	seen := make(map[string]bool)
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		r, err := parseRendition(entry)
+		if err != nil {
+			return nil, err
+		}
+		if seen[r.Name] {
+			return nil, fmt.Errorf("rendition %q is listed twice", r.Name)
+		}
+		seen[r.Name] = true
+		ladder = append(ladder, r)
+	}
+
+	if len(ladder) == 0 {
+		return nil, fmt.Errorf("ladder has no renditions")
+	}
+	return ladder, nil
+}
+
+// FormatLadder writes ladder in the syntax ParseLadder reads.
+func FormatLadder(ladder []Rendition) string {
+	entries := make([]string, 0, len(ladder))
+	for _, r := range ladder {
+		entries = append(entries, fmt.Sprintf("%s:%dx%d:%dk:%dk:%dk:%d",
+			r.Name, r.Width, r.Height, r.BitrateKbps, r.MaxRateKbps, r.BufSizeKbps, r.FPS))
+	}
+	return strings.Join(entries, ",")
+}
+
+func parseRendition(entry string) (Rendition, error) {
+	fields := strings.Split(entry, ":")
+	if len(fields) != 6 {
+		return Rendition{}, fmt.Errorf("rendition %q: want name:WIDTHxHEIGHT:bitrate:maxrate:bufsize:fps", entry)
+	}
+
+	r := Rendition{Name: fields[0]}
+	if !ValidRenditionName(r.Name) {
+		return Rendition{}, fmt.Errorf("rendition %q: name must be lowercase letters, digits, '-' or '_'", entry)
+	}
+
+	width, height, ok := strings.Cut(fields[1], "x")
+	if !ok {
+		return Rendition{}, fmt.Errorf("rendition %q: size must be WIDTHxHEIGHT", entry)
+	}
+
+	var err error
+	for _, f := range []struct {
+		dst   *int
+		value string
+		what  string
+	}{
+		{&r.Width, width, "width"},
+		{&r.Height, height, "height"},
+		{&r.BitrateKbps, strings.TrimSuffix(fields[2], "k"), "bitrate"},
+		{&r.MaxRateKbps, strings.TrimSuffix(fields[3], "k"), "maxrate"},
+		{&r.BufSizeKbps, strings.TrimSuffix(fields[4], "k"), "bufsize"},
+		{&r.FPS, fields[5], "fps"},
+	} {
+		if *f.dst, err = strconv.Atoi(f.value); err != nil || *f.dst <= 0 {
+			return Rendition{}, fmt.Errorf("rendition %q: %s must be a positive integer", entry, f.what)
+		}
+	}
+
+	// libx264 with 4:2:0 chroma refuses odd dimensions.
+	if r.Width%2 != 0 || r.Height%2 != 0 {
+		return Rendition{}, fmt.Errorf("rendition %q: width and height must be even", entry)
+	}
+	if r.MaxRateKbps < r.BitrateKbps {
+		return Rendition{}, fmt.Errorf("rendition %q: maxrate must not be below bitrate", entry)
+	}
+	return r, nil
+}
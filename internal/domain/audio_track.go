@@ -0,0 +1,84 @@
+package domain
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AudioTrackKind says where an audio track came from.
+type AudioTrackKind string
+
+const (
+	// AudioTrackSource tracks are the audio streams of the uploaded file
+	// itself, found when it is transcoded.
+	AudioTrackSource AudioTrackKind = "source"
+	// AudioTrackDub tracks were uploaded separately, after the video.
+	AudioTrackDub AudioTrackKind = "dub"
+)
+
+// AudioTrackStatus is how far an audio track is through transcoding. Source
+// tracks are recorded ready, by the job that encoded them; a dub waits for a
+// job of its own.
+type AudioTrackStatus string
+
+const (
+	AudioTrackPending AudioTrackStatus = "pending"
+	AudioTrackReady   AudioTrackStatus = "ready"
+	AudioTrackFailed  AudioTrackStatus = "failed"
+)
+
+// UndeterminedLanguage is the language tag of a track nobody labelled.
+const UndeterminedLanguage = "und"
+
+// AudioTrack is one selectable audio rendition of a video. Name is the
+// rendition's directory beside the video renditions, and so appears in its
+// playlist and segment URLs. FilePath is withheld for the same reason as
+// Video.FilePath; only a dub has one.
+type AudioTrack struct {
+	ID        uuid.UUID        `json:"id"`
+	VideoID   uuid.UUID        `json:"video_id"`
+	Name      string           `json:"name"`
+	Language  string           `json:"language"`
+	Label     string           `json:"label,omitempty"`
+	Kind      AudioTrackKind   `json:"kind"`
+	Status    AudioTrackStatus `json:"status"`
+	IsDefault bool             `json:"default"`
+	// StreamIndex orders source tracks as they were in the file.
+	StreamIndex int       `json:"-"`
+	FilePath    string    `json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// languageTagPattern accepts the shape of a BCP 47 tag — a two- or
+// three-letter language and optional subtags, "pt-BR", "zh-Hant" — which also
+// covers the ISO 639-2 codes containers carry, such as "eng". It checks shape
+// only; no registry is consulted.
+var languageTagPattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+
+// NormalizeLanguageTag validates tag and returns it in canonical case: the
+// language lowercase, a region uppercase, a script title-cased. An empty tag
+// is UndeterminedLanguage.
+func NormalizeLanguageTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimSpace(strings.ReplaceAll(tag, "_", "-")))
+	if tag == "" {
+		return UndeterminedLanguage, nil
+	}
+	if len(tag) > 35 || !languageTagPattern.MatchString(tag) {
+		return "", ErrInvalidLanguage
+	}
+
+	subtags := strings.Split(tag, "-")
+	for i := 1; i < len(subtags); i++ {
+		switch len(subtags[i]) {
+		case 2:
+			subtags[i] = strings.ToUpper(subtags[i])
+		case 4:
+			subtags[i] = strings.ToUpper(subtags[i][:1]) + subtags[i][1:]
+		}
+	}
+	return strings.Join(subtags, "-"), nil
+}
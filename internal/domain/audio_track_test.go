@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeLanguageTag(t *testing.T) {
+	tests := []struct {
+		tag     string
+		want    string
+		wantErr error
+	}{
+		{tag: "en", want: "en"},
+		{tag: "eng", want: "eng"},
+		{tag: "EN", want: "en"},
+		{tag: "pt-br", want: "pt-BR"},
+		{tag: "pt_BR", want: "pt-BR"},
+		{tag: "zh-hant", want: "zh-Hant"},
+		{tag: "zh-Hant-TW", want: "zh-Hant-TW"},
+		{tag: "es-419", want: "es-419"},
+		{tag: " fr ", want: "fr"},
+		{tag: "", want: UndeterminedLanguage},
+		{tag: "english", wantErr: ErrInvalidLanguage},
+		{tag: "e", wantErr: ErrInvalidLanguage},
+		{tag: "en-", wantErr: ErrInvalidLanguage},
+		{tag: "en/../x", wantErr: ErrInvalidLanguage},
+		{tag: `en",DEFAULT=YES`, wantErr: ErrInvalidLanguage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			got, err := NormalizeLanguageTag(tt.tag)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NormalizeLanguageTag(%q) error = %v, want %v", tt.tag, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeLanguageTag(%q) = %q, want %q", tt.tag, got, tt.want)
+			}
+		})
+	}
+}
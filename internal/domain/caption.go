@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CaptionKind is what a text track transcribes. HLS tells the two apart, and
+// players list captions for viewers who cannot hear the soundtrack.
+type CaptionKind string
+
+const (
+	// CaptionSubtitles translate or transcribe the dialogue.
+	CaptionSubtitles CaptionKind = "subtitles"
+	// CaptionCaptions also describe music and sound effects.
+	CaptionCaptions CaptionKind = "captions"
+)
+
+// Valid reports whether k is a kind the API accepts.
+func (k CaptionKind) Valid() bool {
+	return k == CaptionSubtitles || k == CaptionCaptions
+}
+
+// CaptionStatus is how far a caption is through publishing. Captions taken
+// from the uploaded file are recorded ready by the job that extracted them;
+// an uploaded caption waits for a job of its own.
+type CaptionStatus string
+
+const (
+	CaptionPending CaptionStatus = "pending"
+	CaptionReady   CaptionStatus = "ready"
+	CaptionFailed  CaptionStatus = "failed"
+)
+
+// Caption is one selectable text track of a video, published as segmented
+// WebVTT in the HLS master playlist's subtitles group. Name is the
+// rendition's directory beside the video renditions, as for an AudioTrack.
+// FilePath is where an uploaded caption's WebVTT is kept, and is withheld
+// like Video.FilePath.
+type Caption struct {
+	ID        uuid.UUID     `json:"id"`
+	VideoID   uuid.UUID     `json:"video_id"`
+	Name      string        `json:"name"`
+	Language  string        `json:"language"`
+	Label     string        `json:"label,omitempty"`
+	Kind      CaptionKind   `json:"kind"`
+	Status    CaptionStatus `json:"status"`
+	IsDefault bool          `json:"default"`
+	// Embedded captions were extracted from the uploaded file; the rest were
+	// uploaded on their own.
+	Embedded bool `json:"embedded"`
+	// StreamIndex is an embedded caption's stream in the uploaded file.
+	StreamIndex int       `json:"-"`
+	FilePath    string    `json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// MaxChapters is how many chapters a video may have.
+	MaxChapters = 100
+	// MaxChapterTitleLength is the longest a chapter's title may be, in
+	// characters.
+	MaxChapterTitleLength = 100
+)
+
+// Chapter is a titled section of a video, from Start seconds into it to the
+// start of the next.
+type Chapter struct {
+	Start float64 `json:"start"`
+	Title string  `json:"title"`
+}
+
+// ValidateChapters checks chapters against a video of duration whole
+// seconds, zero when its duration is not known yet, as a broadcast's is not.
+// They must start in order, each inside the video, and have titles.
+func ValidateChapters(chapters []Chapter, duration int) error {
+	if len(chapters) > MaxChapters {
+		return fmt.Errorf("%w: at most %d chapters", ErrInvalidChapters, MaxChapters)
+	}
+	for i, chapter := range chapters {
+		switch {
+		case math.IsNaN(chapter.Start) || math.IsInf(chapter.Start, 0):
+			return fmt.Errorf("%w: chapter %d must start at a number of seconds", ErrInvalidChapters, i+1)
+		case chapter.Start < 0:
+			return fmt.Errorf("%w: chapter %d must not start before the video", ErrInvalidChapters, i+1)
+		case duration > 0 && chapter.Start >= float64(duration):
+			return fmt.Errorf("%w: chapter %d starts past the video's %d seconds", ErrInvalidChapters, i+1, duration)
+		case i > 0 && chapter.Start <= chapters[i-1].Start:
+			return fmt.Errorf("%w: chapter %d must start after chapter %d", ErrInvalidChapters, i+1, i)
+		case strings.TrimSpace(chapter.Title) == "":
+			return fmt.Errorf("%w: chapter %d needs a title", ErrInvalidChapters, i+1)
+		case utf8.RuneCountInString(chapter.Title) > MaxChapterTitleLength:
+			return fmt.Errorf("%w: chapter %d's title is longer than %d characters", ErrInvalidChapters, i+1, MaxChapterTitleLength)
+		}
+	}
+	return nil
+}
+
+// TrimChapters is chapters as they fall in a video trimmed to r: moved back
+// by r.Start, less those starting outside it. The trimmed video's duration
+// is recorded in whole seconds, and a chapter must start inside that.
+func TrimChapters(chapters []Chapter, r ClipRange) []Chapter {
+	end := math.Floor(r.Duration())
+	trimmed := []Chapter{}
+	for _, chapter := range chapters {
+		start := chapter.Start - r.Start
+		if start >= 0 && start < end {
+			trimmed = append(trimmed, Chapter{Start: start, Title: chapter.Title})
+		}
+	}
+	return trimmed
+}
@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+)
+
+// MinClipSeconds is the shortest span a trim may leave or a clip may take:
+// under a second there is nothing to watch, and the pipeline would encode a
+// segment or two of nothing.
+const MinClipSeconds = 1
+
+// ClipRange is a span of a video's timeline, in seconds from its start. A
+// trim keeps it of the video; a clip is cut from it of its parent.
+type ClipRange struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// Duration is the span's length in seconds.
+func (r ClipRange) Duration() float64 {
+	return r.End - r.Start
+}
+
+// Validate checks r against a video of duration whole seconds, zero when the
+// video's duration is unknown. Durations are truncated when recorded, so the
+// span may run up to a second past it.
+func (r ClipRange) Validate(duration int) error {
+	switch {
+	case math.IsNaN(r.Start) || math.IsNaN(r.End) || math.IsInf(r.Start, 0) || math.IsInf(r.End, 0):
+		return fmt.Errorf("%w: start and end must be numbers of seconds", ErrInvalidClipRange)
+	case r.Start < 0:
+		return fmt.Errorf("%w: start must not be negative", ErrInvalidClipRange)
+	case r.Duration() < MinClipSeconds:
+		return fmt.Errorf("%w: end must be at least %d second after start", ErrInvalidClipRange, MinClipSeconds)
+	case duration > 0 && r.End > float64(duration+1):
+		return fmt.Errorf("%w: end is past the video's %d seconds", ErrInvalidClipRange, duration)
+	}
+	return nil
+}
+
+// VideoTrim is a trim of a published video in progress. The video is served
+// as it was until the trimmed output is published, and what that was made
+// from is kept here meanwhile: the source, and the duration and chapters
+// that go with it. Should the trim fail for good, the video goes back to
+// them; once it succeeds, the old source is deleted.
+type VideoTrim struct {
+	Range    ClipRange `json:"range"`
+	FilePath string    `json:"file_path"`
+	MimeType string    `json:"mime_type"`
+	FileSize int64     `json:"file_size"`
+	Duration int       `json:"duration"`
+	Chapters []Chapter `json:"chapters,omitempty"`
+}
+
+// NewVideoTrim is the trim of video to r, holding what video is now.
+func NewVideoTrim(video *Video, r ClipRange) *VideoTrim {
+	return &VideoTrim{
+		Range:    r,
+		FilePath: video.FilePath,
+		MimeType: video.MimeType,
+		FileSize: video.FileSize,
+		Duration: video.Duration,
+		Chapters: video.Chapters,
+	}
+}
@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestClipRangeValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		r        ClipRange
+		duration int
+		wantErr  bool
+	}{
+		{"within the video", ClipRange{Start: 5, End: 30}, 60, false},
+		{"to the truncated end", ClipRange{Start: 0, End: 60.8}, 60, false},
+		{"unknown duration", ClipRange{Start: 100, End: 200}, 0, false},
+		{"negative start", ClipRange{Start: -1, End: 30}, 60, true},
+		{"end before start", ClipRange{Start: 30, End: 5}, 60, true},
+		{"under a second", ClipRange{Start: 30, End: 30.5}, 60, true},
+		{"past the end", ClipRange{Start: 5, End: 62}, 60, true},
+		{"not a number", ClipRange{Start: math.NaN(), End: 30}, 60, true},
+		{"infinite", ClipRange{Start: 0, End: math.Inf(1)}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.r.Validate(tt.duration)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate(%d) = %v, wantErr %v", tt.duration, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidClipRange) {
+				t.Errorf("Validate(%d) = %v, want ErrInvalidClipRange", tt.duration, err)
+			}
+		})
+	}
+}
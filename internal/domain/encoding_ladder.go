@@ -0,0 +1,48 @@
+package domain
+
+import "time"
+
+// EncodingLadder is the ladder per-title encoding chose for a video, with the
+// measurements it was chosen from, kept so the choice can be audited and
+// reused when the video is transcoded again.
+type EncodingLadder struct {
+	// Basis is the configured ladder the choice was made from, in the
+	// WORKER_TRANSCODE_LADDER syntax. A stored choice is only reused while
+	// the rungs a job would encode are still exactly these.
+	Basis string `json:"basis"`
+	// Rungs are the renditions chosen, in ladder order.
+	Rungs []EncodingRung `json:"rungs"`
+	// Dropped names the rungs of Basis left out for adding no visible
+	// quality over the rung below them.
+	Dropped []string `json:"dropped,omitempty"`
+	// SampleStarts are where the analysed scenes begin, in seconds, each
+	// SampleSeconds long.
+	SampleStarts  []float64         `json:"sample_starts"`
+	SampleSeconds float64           `json:"sample_seconds"`
+	Probes        []ComplexityProbe `json:"probes"`
+	AnalyzedAt    time.Time         `json:"analyzed_at"`
+}
+
+// EncodingRung is one chosen rendition. Rates are in kbit/s.
+type EncodingRung struct {
+	Name        string `json:"name"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	BitrateKbps int    `json:"bitrate_kbps"`
+	MaxRateKbps int    `json:"maxrate_kbps"`
+	BufSizeKbps int    `json:"bufsize_kbps"`
+	FPS         int    `json:"fps"`
+}
+
+// ComplexityProbe is one probe encode of the sampled scenes: a rung at a
+// CRF, the bitrate that took, and the quality it reached.
+type ComplexityProbe struct {
+	Rendition   string `json:"rendition"`
+	CRF         int    `json:"crf"`
+	BitrateKbps int    `json:"bitrate_kbps"`
+	// PSNR compares the probe with the source scaled to the rung's size.
+	// DisplayPSNR scales the probe up to the largest rung first, as a
+	// player does full screen, so rungs can be compared with each other.
+	PSNR        float64 `json:"psnr"`
+	DisplayPSNR float64 `json:"display_psnr"`
+}
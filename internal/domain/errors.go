@@ -72,7 +72,76 @@ var (
 	// Watch history.
 	ErrWatchHistoryNotFound = errors.New("watch history entry not found")
 
+	// Resumable uploads.
+	ErrUploadSessionNotFound = errors.New("upload session not found")
+	ErrUploadSessionExpired  = errors.New("upload session has expired")
+	ErrUploadSessionLocked   = errors.New("upload session is busy with another request")
+	ErrUploadOffsetMismatch  = errors.New("upload offset does not match the session")
+	ErrUploadLengthExceeded  = errors.New("chunk runs past the declared upload length")
+	ErrUploadMethodMismatch  = errors.New("upload session does not support this operation")
+	ErrUploadIncomplete      = errors.New("uploaded object does not match the declared upload")
+
 	// Storage.
-	ErrStorageKeyInvalid     = errors.New("invalid storage key")
-	ErrStorageObjectNotFound = errors.New("storage object not found")
+	ErrStorageKeyInvalid       = errors.New("invalid storage key")
+	ErrStorageObjectNotFound   = errors.New("storage object not found")
+	ErrDirectUploadUnsupported = errors.New("storage backend does not support direct uploads")
+	ErrStorageSignatureInvalid = errors.New("storage URL signature is invalid or expired")
+	ErrDirectUploadPartInvalid = errors.New("direct upload part is invalid")
+
+	// Audio tracks.
+	ErrAudioTrackNotFound = errors.New("audio track not found")
+	ErrInvalidLanguage    = errors.New("invalid language tag")
+	ErrVideoNotReady      = errors.New("video is not ready")
+
+	// Captions.
+	ErrCaptionNotFound = errors.New("caption not found")
+	ErrInvalidCaption  = errors.New("invalid caption file")
+
+	// Thumbnails.
+	ErrThumbnailNotFound = errors.New("thumbnail not found")
+	ErrThumbnailNotReady = errors.New("thumbnail is not ready")
+	ErrInvalidThumbnail  = errors.New("invalid thumbnail image")
+
+	// Processing pipeline.
+	ErrStageNotFound = errors.New("processing stage not found")
+
+	// Trims and clips.
+	ErrInvalidClipRange = errors.New("invalid clip range")
+	ErrTrimInProgress   = errors.New("video is already being trimmed")
+
+	// Reprocessing.
+	ErrReprocessBatchNotFound = errors.New("reprocess batch not found")
+	ErrInvalidReprocessFilter = errors.New("invalid reprocess filter")
+
+	// Imports. ErrImportUnreachable may pass and is retried; the rest reject
+	// the import for good, like a source the validate stage rejects.
+	ErrInvalidImportSource    = errors.New("invalid import source")
+	ErrImportForbidden        = errors.New("import source is not a public http or https address")
+	ErrImportTooManyRedirects = errors.New("import source redirected too many times")
+	ErrImportTooLarge         = errors.New("import source is larger than allowed")
+	ErrImportNotFound         = errors.New("import source does not exist")
+	ErrImportRefused          = errors.New("import source refused the request")
+	ErrImportUnreachable      = errors.New("import source could not be reached")
+
+	// Live streams.
+	ErrLiveStreamNotFound = errors.New("live stream not found")
+	ErrLiveStreamEnded    = errors.New("live stream has ended")
+	ErrLiveStreamKey      = errors.New("live stream key does not match")
+	ErrLiveStreamNotLive  = errors.New("live stream is not on air")
+
+	// Premieres and chapters.
+	ErrInvalidPremiere = errors.New("invalid premiere time")
+	ErrPremiereStarted = errors.New("premiere has already started")
+	ErrInvalidChapters = errors.New("invalid chapters")
+
+	// Source validation. The validate stage rejects a source with one of
+	// these, for good: retrying cannot change what the file is.
+	ErrSourceUnrecognized      = errors.New("source is not a recognised video container")
+	ErrSourceContainerMismatch = errors.New("source contents do not match its container signature")
+	ErrSourceCorrupt           = errors.New("source could not be decoded")
+	ErrSourceNoVideo           = errors.New("source has no video stream")
+	ErrSourceTooLong           = errors.New("source is longer than allowed")
+	ErrSourceResolutionTooHigh = errors.New("source resolution is higher than allowed")
+	ErrSourceFrameRateTooHigh  = errors.New("source frame rate is higher than allowed")
+	ErrSourceCodecUnsupported  = errors.New("source video codec is not supported")
 )
@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ImportKind is where an imported video's source is fetched from.
+type ImportKind string
+
+const (
+	// ImportURL fetches the source over HTTP(S) from the public internet.
+	ImportURL ImportKind = "url"
+	// ImportDrop copies the source from the server's import drop directory,
+	// which only admins may import from.
+	ImportDrop ImportKind = "drop"
+)
+
+// VideoImport records where a video created by import gets its source from,
+// so the fetch can be retried. It is never shown through the API: a URL may
+// carry a token in its query.
+type VideoImport struct {
+	Kind   ImportKind `json:"kind"`
+	Source string     `json:"source"`
+}
+
+// CleanDropPath checks a path into the drop directory and returns it
+// cleaned. It must be relative and stay inside the directory; symbolic links
+// out of it are refused when the file is opened.
+func CleanDropPath(p string) (string, error) {
+	if p == "" || path.IsAbs(p) || strings.Contains(p, `\`) {
+		return "", fmt.Errorf("%w: path must be relative to the drop directory", ErrInvalidImportSource)
+	}
+	cleaned := path.Clean(p)
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("%w: path must name a file inside the drop directory", ErrInvalidImportSource)
+	}
+	return cleaned, nil
+}
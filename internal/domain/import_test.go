@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCleanDropPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{"talk.mp4", "talk.mp4", false},
+		{"2024/march/talk.mp4", "2024/march/talk.mp4", false},
+		{"./2024//talk.mp4", "2024/talk.mp4", false},
+		{"2024/../talk.mp4", "talk.mp4", false},
+		{"", "", true},
+		{".", "", true},
+		{"/etc/passwd", "", true},
+		{"../secrets/key.pem", "", true},
+		{"2024/../../talk.mp4", "", true},
+		{`..\talk.mp4`, "", true},
+	}
+	for _, tt := range tests {
+		got, err := CleanDropPath(tt.path)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("CleanDropPath(%q) = %v, wantErr %v", tt.path, err, tt.wantErr)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidImportSource) {
+			t.Errorf("CleanDropPath(%q) = %v, want ErrInvalidImportSource", tt.path, err)
+		}
+		if got != tt.want {
+			t.Errorf("CleanDropPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package domain
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LiveStreamStatus is where a live stream is in its one broadcast.
+type LiveStreamStatus string
+
+const (
+	// LiveStreamWaiting is a stream whose owner has yet to push to it.
+	LiveStreamWaiting LiveStreamStatus = "waiting"
+	// LiveStreamLive is a stream being pushed to and transcoded.
+	LiveStreamLive LiveStreamStatus = "live"
+	// LiveStreamEnded is a stream whose push has stopped. It does not take
+	// another: a stream is one broadcast, into one video.
+	LiveStreamEnded LiveStreamStatus = "ended"
+)
+
+// LiveIngestUser is the user name a creator pushes to the ingest server
+// with. The password is the stream key.
+const LiveIngestUser = "live"
+
+// liveIngestPrefix starts the ingest server path of every live stream,
+// live/<stream id>.
+const liveIngestPrefix = "live/"
+
+// LiveStream is a broadcast its owner pushes over RTMP or SRT to the ingest
+// server, which the worker transcodes into VideoID's HLS output as it goes.
+// When the push stops the video is ready, and is processed again from the
+// recording of the push like any upload.
+//
+// KeyHash is the SHA-256 of the stream key. The key itself is only known to
+// the owner, who was shown it once when the stream was created.
+type LiveStream struct {
+	ID        uuid.UUID        `json:"id"`
+	VideoID   uuid.UUID        `json:"video_id"`
+	UserID    uuid.UUID        `json:"user_id"`
+	KeyHash   string           `json:"-"`
+	Status    LiveStreamStatus `json:"status"`
+	StartedAt *time.Time       `json:"started_at,omitempty"`
+	EndedAt   *time.Time       `json:"ended_at,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// NewLiveStream returns a waiting stream into videoID for userID, and the
+// stream key to push to it with.
+func NewLiveStream(videoID, userID uuid.UUID) (*LiveStream, string, error) {
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, "", fmt.Errorf("generating stream key: %w", err)
+	}
+	key := base64.RawURLEncoding.EncodeToString(raw)
+	return &LiveStream{
+		ID:        uuid.New(),
+		VideoID:   videoID,
+		UserID:    userID,
+		KeyHash:   hashStreamKey(key),
+		Status:    LiveStreamWaiting,
+		CreatedAt: time.Now(),
+	}, key, nil
+}
+
+func hashStreamKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckKey reports whether key is the stream's key.
+func (s *LiveStream) CheckKey(key string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashStreamKey(key)), []byte(s.KeyHash)) == 1
+}
+
+// IngestPath is the path the stream is pushed to on the ingest server.
+func (s *LiveStream) IngestPath() string {
+	return liveIngestPrefix + s.ID.String()
+}
+
+// RecordingName is the name of the raw file the stream's push is recorded
+// to, which becomes its video's source once the stream ends.
+func (s *LiveStream) RecordingName() string {
+	return "live-" + s.ID.String() + ".mkv"
+}
+
+// ParseLiveIngestPath returns the stream an ingest server path names.
+func ParseLiveIngestPath(path string) (uuid.UUID, error) {
+	rest, ok := strings.CutPrefix(strings.Trim(path, "/"), liveIngestPrefix)
+	if !ok {
+		return uuid.Nil, ErrLiveStreamNotFound
+	}
+	id, err := uuid.Parse(rest)
+	if err != nil || id.String() != rest {
+		return uuid.Nil, ErrLiveStreamNotFound
+	}
+	return id, nil
+}
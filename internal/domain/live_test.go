@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestNewLiveStreamKey(t *testing.T) {
+	stream, key, err := NewLiveStream(uuid.New(), uuid.New())
+	if err != nil {
+		t.Fatalf("NewLiveStream: %v", err)
+	}
+	if stream.Status != LiveStreamWaiting {
+		t.Errorf("Status = %q, want waiting", stream.Status)
+	}
+	// The key travels in an RTMP URL's query and an SRT stream ID.
+	if url.QueryEscape(key) != key || len(key) < 32 {
+		t.Errorf("key %q is too short or needs escaping", key)
+	}
+	if stream.KeyHash == key || !stream.CheckKey(key) {
+		t.Fatalf("stream does not accept its own key, or stores it as is")
+	}
+	for _, wrong := range []string{"", key[1:], key + "x"} {
+		if stream.CheckKey(wrong) {
+			t.Errorf("CheckKey(%q) = true", wrong)
+		}
+	}
+
+	_, other, err := NewLiveStream(uuid.New(), uuid.New())
+	if err != nil || other == key {
+		t.Fatalf("two streams got the key %q (%v)", key, err)
+	}
+}
+
+func TestParseLiveIngestPath(t *testing.T) {
+	stream, _, err := NewLiveStream(uuid.New(), uuid.New())
+	if err != nil {
+		t.Fatalf("NewLiveStream: %v", err)
+	}
+	if id, err := ParseLiveIngestPath(stream.IngestPath()); err != nil || id != stream.ID {
+		t.Fatalf("ParseLiveIngestPath(%q) = %s, %v", stream.IngestPath(), id, err)
+	}
+	if id, err := ParseLiveIngestPath("/" + stream.IngestPath() + "/"); err != nil || id != stream.ID {
+		t.Errorf("with slashes = %s, %v", id, err)
+	}
+	for _, path := range []string{
+		"",
+		"live/",
+		stream.ID.String(),
+		"other/" + stream.ID.String(),
+		"live/not-a-uuid",
+		"live/" + stream.ID.String() + "/extra",
+		"live/{" + stream.ID.String() + "}",
+	} {
+		if _, err := ParseLiveIngestPath(path); err == nil {
+			t.Errorf("ParseLiveIngestPath(%q) accepted", path)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package domain
+
+// AudioLoudness is the EBU R128 loudness of a video's default audio track as
+// uploaded, measured by the loudness stage.
+//
+// When Normalized, the renditions were leveled to TargetLUFS and play at
+// about that. Otherwise they play as measured, and a player that levels
+// volume itself, replay-gain style, can apply TargetLUFS - IntegratedLUFS of
+// gain, keeping TruePeakDBTP plus the gain under 0 dBTP.
+type AudioLoudness struct {
+	IntegratedLUFS float64 `json:"integrated_lufs"`
+	TruePeakDBTP   float64 `json:"true_peak_dbtp"`
+	RangeLU        float64 `json:"range_lu"`
+	Normalized     bool    `json:"normalized"`
+	TargetLUFS     float64 `json:"target_lufs"`
+}
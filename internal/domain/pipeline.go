@@ -0,0 +1,132 @@
+package domain
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StageKind is what a processing stage does. A video is processed as a DAG
+// of stages: validate vets the source, probe decides the ladder and fans out
+// one encode per rendition, the thumbnails, and loudness, which measures the
+// audio for its encodes to level; package joins the encodes into the
+// playlists, and publish joins package, thumbnails and loudness and makes
+// the video ready.
+type StageKind string
+
+const (
+	StageValidate   StageKind = "validate"
+	StageProbe      StageKind = "probe"
+	StageEncode     StageKind = "encode"
+	StagePackage    StageKind = "package"
+	StageThumbnails StageKind = "thumbnails"
+	StageLoudness   StageKind = "loudness"
+	StagePublish    StageKind = "publish"
+)
+
+// StageStatus is how far a stage is. A pending stage waits for the stages it
+// depends on; once they are done it is queued, exactly once, as a task of its
+// own. A failed stage ran out of retries, and fails its video with it.
+type StageStatus string
+
+const (
+	StagePending StageStatus = "pending"
+	StageQueued  StageStatus = "queued"
+	StageRunning StageStatus = "running"
+	StageDone    StageStatus = "done"
+	StageFailed  StageStatus = "failed"
+)
+
+// Stage names. Encode stages are named after the rendition they write, see
+// EncodeStageName; the others are named after their kind.
+const (
+	ValidateStageName   = string(StageValidate)
+	ProbeStageName      = string(StageProbe)
+	PackageStageName    = string(StagePackage)
+	ThumbnailsStageName = string(StageThumbnails)
+	LoudnessStageName   = string(StageLoudness)
+	PublishStageName    = string(StagePublish)
+)
+
+// EncodeStageName names the stage that encodes rendition: a rung of the
+// ladder or an audio track.
+func EncodeStageName(rendition string) string {
+	return string(StageEncode) + ":" + rendition
+}
+
+// ProcessingStage is one step of processing a video. Checkpoint is what the
+// stage handed on when it finished, for the stages that depend on it; a
+// stage whose dependencies are done never needs to repeat them.
+type ProcessingStage struct {
+	VideoID   uuid.UUID   `json:"video_id"`
+	Name      string      `json:"name"`
+	Kind      StageKind   `json:"kind"`
+	DependsOn []string    `json:"depends_on"`
+	Status    StageStatus `json:"status"`
+	// Attempts counts the times the stage has started, retries included.
+	Attempts int `json:"attempts"`
+	// Progress is the fraction of its work a running stage reports done.
+	Progress   float64         `json:"progress"`
+	Error      string          `json:"error,omitempty"`
+	Checkpoint json.RawMessage `json:"checkpoint,omitempty"`
+	StartedAt  *time.Time      `json:"started_at,omitempty"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+}
+
+// Rendition returns the rendition an encode stage writes, or "" for any
+// other stage.
+func (s *ProcessingStage) Rendition() string {
+	if s.Kind != StageEncode {
+		return ""
+	}
+	return strings.TrimPrefix(s.Name, string(StageEncode)+":")
+}
+
+// stageWeights is each kind's share of a video's processing time, roughly.
+// Encoding dominates; every encode stage carries its own weight, so a ladder
+// of four rungs is most of the bar.
+var stageWeights = map[StageKind]float64{
+	StageValidate:   1,
+	StageProbe:      1,
+	StageEncode:     6,
+	StagePackage:    1,
+	StageThumbnails: 2,
+	StageLoudness:   1,
+	StagePublish:    1,
+}
+
+// PipelineProgress is the fraction of a video's processing that stages have
+// done: finished stages count whole, running ones by their own progress.
+// Until probe has planned the other stages there is nothing to measure it
+// against, and it is 0.
+func PipelineProgress(stages []*ProcessingStage) float64 {
+	planned := false
+	for _, stage := range stages {
+		if stage.Kind != StageValidate && stage.Kind != StageProbe {
+			planned = true
+			break
+		}
+	}
+	if !planned {
+		return 0
+	}
+	var total, done float64
+	for _, stage := range stages {
+		weight := stageWeights[stage.Kind]
+		total += weight
+		switch stage.Status {
+		case StageDone:
+			done += weight
+		case StageRunning:
+			done += weight * min(max(stage.Progress, 0), 1)
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return done / total
+}
@@ -0,0 +1,86 @@
+package domain
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPipelineProgress(t *testing.T) {
+	stage := func(kind StageKind, name string, status StageStatus, progress float64) *ProcessingStage {
+		return &ProcessingStage{Name: name, Kind: kind, Status: status, Progress: progress}
+	}
+
+	tests := []struct {
+		name   string
+		stages []*ProcessingStage
+		want   float64
+	}{
+		{
+			name: "nothing planned",
+			stages: []*ProcessingStage{
+				stage(StageValidate, ValidateStageName, StageDone, 0),
+				stage(StageProbe, ProbeStageName, StageRunning, 0.5),
+			},
+			want: 0,
+		},
+		{
+			name: "probed",
+			stages: []*ProcessingStage{
+				stage(StageProbe, ProbeStageName, StageDone, 0),
+				stage(StageEncode, EncodeStageName("720p"), StagePending, 0),
+				stage(StageThumbnails, ThumbnailsStageName, StagePending, 0),
+				stage(StagePackage, PackageStageName, StagePending, 0),
+				stage(StagePublish, PublishStageName, StagePending, 0),
+			},
+			want: 1.0 / 11,
+		},
+		{
+			name: "encoding halfway",
+			stages: []*ProcessingStage{
+				stage(StageProbe, ProbeStageName, StageDone, 0),
+				stage(StageEncode, EncodeStageName("720p"), StageRunning, 0.5),
+				stage(StageThumbnails, ThumbnailsStageName, StageQueued, 0),
+				stage(StagePackage, PackageStageName, StagePending, 0),
+				stage(StagePublish, PublishStageName, StagePending, 0),
+			},
+			want: 4.0 / 11,
+		},
+		{
+			name: "failed stage counts nothing",
+			stages: []*ProcessingStage{
+				stage(StageProbe, ProbeStageName, StageDone, 0),
+				stage(StageEncode, EncodeStageName("720p"), StageFailed, 0.9),
+				stage(StagePublish, PublishStageName, StagePending, 0),
+			},
+			want: 1.0 / 8,
+		},
+		{
+			name: "all done",
+			stages: []*ProcessingStage{
+				stage(StageProbe, ProbeStageName, StageDone, 0),
+				stage(StageEncode, EncodeStageName("720p"), StageDone, 1),
+				stage(StagePublish, PublishStageName, StageDone, 0),
+			},
+			want: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PipelineProgress(tt.stages); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("PipelineProgress() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessingStageRendition(t *testing.T) {
+	encode := &ProcessingStage{Name: EncodeStageName("audio_0"), Kind: StageEncode}
+	if got := encode.Rendition(); got != "audio_0" {
+		t.Errorf("Rendition() = %q, want audio_0", got)
+	}
+	probe := &ProcessingStage{Name: ProbeStageName, Kind: StageProbe}
+	if got := probe.Rendition(); got != "" {
+		t.Errorf("Rendition() of probe = %q, want empty", got)
+	}
+}
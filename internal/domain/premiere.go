@@ -0,0 +1,52 @@
+package domain
+
+import "time"
+
+// PremiereState is where a video is in its premiere.
+type PremiereState string
+
+const (
+	// PremiereNone is a video that was never scheduled to premiere.
+	PremiereNone PremiereState = ""
+	// PremiereScheduled is a video waiting for its premiere. Only its owner
+	// can play it.
+	PremiereScheduled PremiereState = "scheduled"
+	// PremiereLive is a video being premiered: it is played out to every
+	// viewer from its start at PremiereAt, as a broadcast would be, and
+	// nobody can get ahead of it.
+	PremiereLive PremiereState = "live"
+	// PremiereEnded is a video whose premiere has played to its end. It
+	// plays like any other from here on.
+	PremiereEnded PremiereState = "ended"
+)
+
+// MaxPremiereLead is how far ahead a premiere may be scheduled.
+const MaxPremiereLead = 30 * 24 * time.Hour
+
+// Premiere reports where v is in its premiere at now and, while it is live,
+// how far into the video it has played.
+func (v *Video) Premiere(now time.Time) (PremiereState, time.Duration) {
+	if v.PremiereAt == nil {
+		return PremiereNone, 0
+	}
+	elapsed := now.Sub(*v.PremiereAt)
+	switch {
+	case elapsed < 0:
+		return PremiereScheduled, 0
+	case elapsed < time.Duration(v.Duration)*time.Second:
+		return PremiereLive, elapsed
+	default:
+		return PremiereEnded, 0
+	}
+}
+
+// PremiereOffset is how far into v's premiere now is, in seconds, while it
+// is live. A comment posted then is stamped with it, so the premiere's chat
+// can be replayed alongside the video.
+func (v *Video) PremiereOffset(now time.Time) (float64, bool) {
+	state, elapsed := v.Premiere(now)
+	if state != PremiereLive {
+		return 0, false
+	}
+	return elapsed.Seconds(), true
+}
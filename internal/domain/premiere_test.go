@@ -0,0 +1,118 @@
+package domain
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVideoPremiere(t *testing.T) {
+	at := time.Date(2026, 3, 1, 20, 0, 0, 0, time.UTC)
+	video := &Video{Duration: 600, PremiereAt: &at}
+
+	tests := []struct {
+		name        string
+		now         time.Time
+		wantState   PremiereState
+		wantElapsed time.Duration
+	}{
+		{"before it starts", at.Add(-time.Minute), PremiereScheduled, 0},
+		{"as it starts", at, PremiereLive, 0},
+		{"part way through", at.Add(90 * time.Second), PremiereLive, 90 * time.Second},
+		{"once played to the end", at.Add(10 * time.Minute), PremiereEnded, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, elapsed := video.Premiere(tt.now)
+			if state != tt.wantState || elapsed != tt.wantElapsed {
+				t.Errorf("Premiere = %q, %s; want %q, %s", state, elapsed, tt.wantState, tt.wantElapsed)
+			}
+		})
+	}
+
+	if state, _ := (&Video{Duration: 600}).Premiere(at); state != PremiereNone {
+		t.Errorf("unscheduled video: Premiere = %q", state)
+	}
+	if offset, ok := video.PremiereOffset(at.Add(90 * time.Second)); !ok || offset != 90 {
+		t.Errorf("PremiereOffset during = %v, %v; want 90, true", offset, ok)
+	}
+	if _, ok := video.PremiereOffset(at.Add(-time.Second)); ok {
+		t.Error("PremiereOffset before the premiere reported one")
+	}
+}
+
+func TestTrimChapters(t *testing.T) {
+	chapters := []Chapter{{0, "Intro"}, {30, "Setup"}, {95.5, "Demo"}, {400, "Q&A"}}
+	tests := []struct {
+		name string
+		r    ClipRange
+		want []Chapter
+	}{
+		{"from the start", ClipRange{Start: 0, End: 120}, []Chapter{{0, "Intro"}, {30, "Setup"}, {95.5, "Demo"}}},
+		{"from a chapter", ClipRange{Start: 30, End: 600}, []Chapter{{0, "Setup"}, {65.5, "Demo"}, {370, "Q&A"}}},
+		{"between chapters", ClipRange{Start: 20, End: 420}, []Chapter{{10, "Setup"}, {75.5, "Demo"}, {380, "Q&A"}}},
+		{"ending on a chapter", ClipRange{Start: 0, End: 95.5}, []Chapter{{0, "Intro"}, {30, "Setup"}}},
+		// The trimmed video is recorded as 65 seconds, and a chapter 65.5
+		// seconds in would be past it.
+		{"inside the last part second", ClipRange{Start: 30, End: 95.7}, []Chapter{{0, "Setup"}}},
+		{"starting inside a chapter", ClipRange{Start: 30.3, End: 200}, []Chapter{{65.2, "Demo"}}},
+		{"none left", ClipRange{Start: 100, End: 300}, []Chapter{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TrimChapters(chapters, tt.r)
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("TrimChapters = %v, want %v", got, tt.want)
+			}
+			if err := ValidateChapters(got, int(tt.r.Duration())); err != nil {
+				t.Errorf("trimmed chapters are invalid: %v", err)
+			}
+		})
+	}
+	if got := TrimChapters(nil, ClipRange{Start: 5, End: 30}); got == nil || len(got) != 0 {
+		t.Errorf("TrimChapters(nil) = %#v, want an empty list", got)
+	}
+}
+
+func TestValidateChapters(t *testing.T) {
+	tests := []struct {
+		name     string
+		chapters []Chapter
+		duration int
+		wantErr  bool
+	}{
+		{"none", nil, 600, false},
+		{"in order", []Chapter{{0, "Intro"}, {95.5, "Setup"}, {400, "Q&A"}}, 600, false},
+		{"not starting at zero", []Chapter{{30, "Late start"}}, 600, false},
+		{"duration unknown", []Chapter{{0, "Intro"}, {7200, "Hour two"}}, 0, false},
+		{"out of order", []Chapter{{100, "B"}, {50, "A"}}, 600, true},
+		{"same start twice", []Chapter{{0, "A"}, {0, "B"}}, 600, true},
+		{"negative start", []Chapter{{-1, "Before"}}, 600, true},
+		{"past the end", []Chapter{{0, "A"}, {600, "After"}}, 600, true},
+		{"not a number", []Chapter{{math.NaN(), "NaN"}}, 600, true},
+		{"blank title", []Chapter{{0, "  "}}, 600, true},
+		{"title too long", []Chapter{{0, strings.Repeat("é", MaxChapterTitleLength+1)}}, 600, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateChapters(tt.chapters, tt.duration)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateChapters = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidChapters) {
+				t.Errorf("error %v is not ErrInvalidChapters", err)
+			}
+		})
+	}
+
+	tooMany := make([]Chapter, MaxChapters+1)
+	for i := range tooMany {
+		tooMany[i] = Chapter{Start: float64(i), Title: "Part"}
+	}
+	if err := ValidateChapters(tooMany, 0); !errors.Is(err, ErrInvalidChapters) {
+		t.Errorf("%d chapters: %v", len(tooMany), err)
+	}
+}
@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReprocessFilter selects the videos an admin queues to be processed again,
+// to re-encode a library after the ladder or codecs change or to backfill
+// what older videos never got. Every field that is set narrows the
+// selection. A video still uploading has no source to process yet and is
+// never selected.
+type ReprocessFilter struct {
+	// CreatedAfter and CreatedBefore bound when the video was uploaded, from
+	// CreatedAfter inclusive to CreatedBefore exclusive.
+	CreatedAfter  *time.Time `json:"created_after,omitempty"`
+	CreatedBefore *time.Time `json:"created_before,omitempty"`
+	// Status matches one status.
+	Status *VideoStatus `json:"status,omitempty"`
+	// MissingQuality matches videos without the named rendition.
+	MissingQuality string `json:"missing_quality,omitempty"`
+	// MissingHLS matches videos with no HLS output being served.
+	MissingHLS bool `json:"missing_hls,omitempty"`
+}
+
+// Validate checks the filter's fields against each other. Whether
+// MissingQuality names a rendition is the worker's configuration to say.
+func (f ReprocessFilter) Validate() error {
+	if f.Status != nil {
+		switch *f.Status {
+		case VideoStatusProcessing, VideoStatusReady, VideoStatusFailed:
+		default:
+			return fmt.Errorf("%w: status must be processing, ready or failed", ErrInvalidReprocessFilter)
+		}
+	}
+	if f.CreatedAfter != nil && f.CreatedBefore != nil && !f.CreatedAfter.Before(*f.CreatedBefore) {
+		return fmt.Errorf("%w: created_after must be before created_before", ErrInvalidReprocessFilter)
+	}
+	return nil
+}
+
+// ReprocessBatch is a set of videos queued to be processed again together,
+// no faster than RatePerMinute of them a minute. VideoIDs are the videos the
+// filter matched when the batch was queued.
+type ReprocessBatch struct {
+	ID            uuid.UUID       `json:"id"`
+	Filter        ReprocessFilter `json:"filter"`
+	VideoIDs      []uuid.UUID     `json:"-"`
+	RatePerMinute int             `json:"rate_per_minute"`
+	RequestedBy   *uuid.UUID      `json:"requested_by,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// Total is how many videos the batch queued.
+func (b *ReprocessBatch) Total() int {
+	return len(b.VideoIDs)
+}
+
+// Schedule is when the i-th video of the batch is due to start, for i from
+// 0: the videos are spread out from CreatedAt at RatePerMinute.
+func (b *ReprocessBatch) Schedule(i int) time.Time {
+	return b.CreatedAt.Add(time.Duration(i) * time.Minute / time.Duration(b.RatePerMinute))
+}
+
+// ReprocessProgress counts a batch's videos by how far they are: published
+// again since it was queued, failed since, deleted since, and the rest, still
+// waiting or being processed.
+type ReprocessProgress struct {
+	Total     int `json:"total"`
+	Published int `json:"published"`
+	Failed    int `json:"failed"`
+	Deleted   int `json:"deleted"`
+	Pending   int `json:"pending"`
+}
+
+// Done reports whether every video of the batch has been dealt with.
+func (p ReprocessProgress) Done() bool {
+	return p.Pending == 0
+}
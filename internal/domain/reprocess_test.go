@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReprocessFilterValidate(t *testing.T) {
+	status := func(s VideoStatus) *VideoStatus { return &s }
+	at := func(day int) *time.Time {
+		t := time.Date(2024, time.January, day, 0, 0, 0, 0, time.UTC)
+		return &t
+	}
+	tests := []struct {
+		name    string
+		f       ReprocessFilter
+		wantErr bool
+	}{
+		{"everything", ReprocessFilter{}, false},
+		{"ready", ReprocessFilter{Status: status(VideoStatusReady)}, false},
+		{"failed", ReprocessFilter{Status: status(VideoStatusFailed)}, false},
+		{"uploading", ReprocessFilter{Status: status(VideoStatusUploading)}, true},
+		{"unknown status", ReprocessFilter{Status: status("archived")}, true},
+		{"a range", ReprocessFilter{CreatedAfter: at(1), CreatedBefore: at(31)}, false},
+		{"an open range", ReprocessFilter{CreatedBefore: at(1)}, false},
+		{"an empty range", ReprocessFilter{CreatedAfter: at(5), CreatedBefore: at(5)}, true},
+		{"a backwards range", ReprocessFilter{CreatedAfter: at(31), CreatedBefore: at(1)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.f.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidReprocessFilter) {
+				t.Errorf("Validate() = %v, want ErrInvalidReprocessFilter", err)
+			}
+		})
+	}
+}
+
+func TestReprocessBatchSchedule(t *testing.T) {
+	start := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+	batch := &ReprocessBatch{RatePerMinute: 4, CreatedAt: start}
+	tests := []struct {
+		i    int
+		want time.Duration
+	}{
+		{0, 0},
+		{1, 15 * time.Second},
+		{2, 30 * time.Second},
+		{4, time.Minute},
+		{100, 25 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := batch.Schedule(tt.i).Sub(start); got != tt.want {
+			t.Errorf("Schedule(%d) = %v after the batch, want %v", tt.i, got, tt.want)
+		}
+	}
+}
+
+func TestReprocessProgressDone(t *testing.T) {
+	if (ReprocessProgress{Total: 3, Published: 1, Failed: 1, Pending: 1}).Done() {
+		t.Error("a batch with a video pending is done")
+	}
+	if !(ReprocessProgress{Total: 3, Published: 1, Failed: 1, Deleted: 1}).Done() {
+		t.Error("a batch with nothing pending is not done")
+	}
+}
@@ -53,6 +53,7 @@ type VideoSearchItem struct {
 	Title         string    `json:"title"`
 	Description   string    `json:"description"`
 	ThumbnailURL  string    `json:"thumbnail_url"`
+	PreviewURL    string    `json:"preview_url,omitempty"`
 	Duration      int32     `json:"duration"`
 	Views         int64     `json:"views"`
 	CreatedAt     time.Time `json:"created_at"`
@@ -54,10 +54,15 @@ type Comment struct {
 	LikeCount  int64      `json:"like_count"`
 	ReplyCount int64      `json:"reply_count"`
 	Pinned     bool       `json:"pinned"`
-	EditedAt   *time.Time `json:"edited_at,omitempty"`
-	CreatedAt  time.Time  `json:"created_at"`
-	UpdatedAt  time.Time  `json:"updated_at"`
-	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+	// EventOffset is how far into the video's premiere, in seconds, the
+	// comment was posted, when it was posted during one; see
+	// Video.PremiereOffset. The premiere's chat is its comments in this
+	// order.
+	EventOffset *float64   `json:"event_offset,omitempty"`
+	EditedAt    *time.Time `json:"edited_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
 
 	Username  string `json:"username,omitempty"`
 	AvatarURL string `json:"avatar_url,omitempty"`
@@ -0,0 +1,61 @@
+package domain
+
+import "errors"
+
+// ProcessingError tells an uploader why their video failed: Code is one of
+// the SOURCE_* codes below, stable for clients to switch on, and Message is
+// for people.
+type ProcessingError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Codes a source is rejected with, one per source validation error and one
+// per way fetching an imported source can fail for good.
+const (
+	SourceUnrecognized      = "SOURCE_UNRECOGNIZED"
+	SourceContainerMismatch = "SOURCE_CONTAINER_MISMATCH"
+	SourceCorrupt           = "SOURCE_CORRUPT"
+	SourceNoVideo           = "SOURCE_NO_VIDEO"
+	SourceTooLong           = "SOURCE_TOO_LONG"
+	SourceResolutionTooHigh = "SOURCE_RESOLUTION_TOO_HIGH"
+	SourceFrameRateTooHigh  = "SOURCE_FRAME_RATE_TOO_HIGH"
+	SourceCodecUnsupported  = "SOURCE_CODEC_UNSUPPORTED"
+
+	ImportForbidden        = "IMPORT_FORBIDDEN"
+	ImportTooManyRedirects = "IMPORT_TOO_MANY_REDIRECTS"
+	ImportTooLarge         = "IMPORT_TOO_LARGE"
+	ImportNotFound         = "IMPORT_NOT_FOUND"
+	ImportRefused          = "IMPORT_REFUSED"
+)
+
+var sourceRejections = []struct {
+	err  error
+	code string
+}{
+	{ErrSourceUnrecognized, SourceUnrecognized},
+	{ErrSourceContainerMismatch, SourceContainerMismatch},
+	{ErrSourceCorrupt, SourceCorrupt},
+	{ErrSourceNoVideo, SourceNoVideo},
+	{ErrSourceTooLong, SourceTooLong},
+	{ErrSourceResolutionTooHigh, SourceResolutionTooHigh},
+	{ErrSourceFrameRateTooHigh, SourceFrameRateTooHigh},
+	{ErrSourceCodecUnsupported, SourceCodecUnsupported},
+	{ErrImportForbidden, ImportForbidden},
+	{ErrImportTooManyRedirects, ImportTooManyRedirects},
+	{ErrImportTooLarge, ImportTooLarge},
+	{ErrImportNotFound, ImportNotFound},
+	{ErrImportRefused, ImportRefused},
+}
+
+// SourceRejection reports whether err rejects a video's source, and if so the
+// ProcessingError to show its uploader. The message is err's own, which says
+// what was wrong with the file in terms of the limit it broke.
+func SourceRejection(err error) (*ProcessingError, bool) {
+	for _, r := range sourceRejections {
+		if errors.Is(err, r.err) {
+			return &ProcessingError{Code: r.code, Message: err.Error()}, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestSourceRejection(t *testing.T) {
+	err := fmt.Errorf("%w: 8192x4320 exceeds 7680x4320", ErrSourceResolutionTooHigh)
+	perr, ok := SourceRejection(err)
+	if !ok {
+		t.Fatal("SourceRejection() = false for a resolution limit")
+	}
+	if perr.Code != SourceResolutionTooHigh {
+		t.Errorf("Code = %q, want %q", perr.Code, SourceResolutionTooHigh)
+	}
+	if perr.Message != err.Error() {
+		t.Errorf("Message = %q, want %q", perr.Message, err.Error())
+	}
+
+	if _, ok := SourceRejection(errors.New("ffmpeg exited with status 1")); ok {
+		t.Error("SourceRejection() = true for an ordinary failure")
+	}
+}
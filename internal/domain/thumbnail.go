@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ThumbnailKind is where a thumbnail came from.
+type ThumbnailKind string
+
+const (
+	// ThumbnailCandidate frames are picked from the video by the worker.
+	ThumbnailCandidate ThumbnailKind = "candidate"
+	// ThumbnailCustom images are uploaded by the video's owner.
+	ThumbnailCustom ThumbnailKind = "custom"
+)
+
+// ThumbnailStatus is how far a thumbnail is through rendering. Candidates
+// are recorded ready by the job that rendered them; an uploaded image waits
+// for a job of its own.
+type ThumbnailStatus string
+
+const (
+	ThumbnailPending ThumbnailStatus = "pending"
+	ThumbnailReady   ThumbnailStatus = "ready"
+	ThumbnailFailed  ThumbnailStatus = "failed"
+)
+
+// ThumbnailSize is one width a thumbnail is rendered at. Heights follow the
+// image's shape, and nothing is scaled up past the image's own width.
+type ThumbnailSize struct {
+	Name  string
+	Width int
+}
+
+// ThumbnailSizes are the sizes every thumbnail is rendered at, smallest
+// first. The first is what a thumbnail URL serves when no size is asked for.
+var ThumbnailSizes = []ThumbnailSize{
+	{Name: "small", Width: 320},
+	{Name: "medium", Width: 640},
+	{Name: "large", Width: 1280},
+}
+
+// ThumbnailSizeNamed returns the size called name.
+func ThumbnailSizeNamed(name string) (ThumbnailSize, bool) {
+	for _, size := range ThumbnailSizes {
+		if size.Name == name {
+			return size, true
+		}
+	}
+	return ThumbnailSize{}, false
+}
+
+// Thumbnail is one image a video's poster can be. Every size is rendered
+// as JPEG and as WebP under Key, as <size>.jpg and <size>.webp, and the
+// selected thumbnail's Key is the video's ThumbnailPath. Key is withheld
+// like Video.ThumbnailPath.
+type Thumbnail struct {
+	ID      uuid.UUID       `json:"id"`
+	VideoID uuid.UUID       `json:"video_id"`
+	Kind    ThumbnailKind   `json:"kind"`
+	Status  ThumbnailStatus `json:"status"`
+	// Offset is where in the video a candidate was taken, in seconds.
+	Offset float64 `json:"offset,omitempty"`
+	// Score is how good a poster a candidate was judged to be, from 0 to 1.
+	Score    float64 `json:"score,omitempty"`
+	Selected bool    `json:"selected"`
+	// URL is where the owner can preview a ready thumbnail.
+	URL       string    `json:"url,omitempty"`
+	Key       string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ThumbnailPreviewURL is where the owner of videoID previews one of its
+// thumbnails.
+func ThumbnailPreviewURL(videoID, thumbnailID uuid.UUID) string {
+	return "/api/v1/videos/" + videoID.String() + "/thumbnails/" + thumbnailID.String()
+}
@@ -0,0 +1,114 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UploadMethod says how an upload session's bytes reach storage.
+type UploadMethod string
+
+const (
+	// UploadMethodTus sessions receive chunks through the API.
+	UploadMethodTus UploadMethod = "tus"
+	// UploadMethodDirect sessions never see the bytes: the client PUTs parts
+	// straight to storage through presigned URLs and then asks the API to
+	// complete the upload.
+	UploadMethodDirect UploadMethod = "direct"
+)
+
+// UploadSession is a resumable upload in progress. The client declares the
+// total Length up front and then sends the file in chunks, each of which must
+// start exactly at the current Offset. Committed chunks live in storage as
+// separate parts; Parts lists the offset each one starts at, in order.
+//
+// A session is complete once Offset reaches Length and the assembled file has
+// been recorded as a video. It is kept until ExpiresAt so a client that lost
+// the final response can still ask where its upload went.
+//
+// Direct sessions do not track Offset: StorageKey is where the object will
+// land and MultipartID names the storage backend's multipart upload.
+type UploadSession struct {
+	ID          uuid.UUID       `json:"id"`
+	Method      UploadMethod    `json:"method"`
+	UserID      uuid.UUID       `json:"user_id"`
+	Filename    string          `json:"filename"`
+	MimeType    string          `json:"mime_type"`
+	Title       string          `json:"title"`
+	Description string          `json:"description,omitempty"`
+	Visibility  VideoVisibility `json:"visibility"`
+	// NormalizeAudio is carried over to the video; see Video.NormalizeAudio.
+	NormalizeAudio bool       `json:"normalize_audio"`
+	Length         int64      `json:"length"`
+	Offset         int64      `json:"offset"`
+	Parts          []int64    `json:"-"`
+	StorageKey     string     `json:"-"`
+	MultipartID    string     `json:"-"`
+	VideoID        *uuid.UUID `json:"video_id,omitempty"`
+	CompletedAt    *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// NewUploadSession starts a session for length bytes that expires ttl from now.
+// Title, description, and filename are expected to be validated already; this
+// only guards the invariants the upload_sessions table enforces.
+func NewUploadSession(userID uuid.UUID, filename, mimeType, title, description string, visibility VideoVisibility, length int64, ttl time.Duration) (*UploadSession, error) {
+	if userID == uuid.Nil {
+		return nil, ErrInvalidInput
+	}
+	if filename == "" {
+		return nil, ErrInvalidFilename
+	}
+	if title == "" {
+		return nil, ErrInvalidTitle
+	}
+	if length <= 0 {
+		return nil, ErrInvalidFileSize
+	}
+	if !visibility.IsValid() {
+		return nil, ErrInvalidInput
+	}
+
+	now := time.Now()
+	return &UploadSession{
+		ID:             uuid.New(),
+		Method:         UploadMethodTus,
+		UserID:         userID,
+		Filename:       filename,
+		MimeType:       mimeType,
+		Title:          title,
+		Description:    description,
+		Visibility:     visibility,
+		NormalizeAudio: true,
+		Length:         length,
+		Parts:          []int64{},
+		ExpiresAt:      now.Add(ttl),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}, nil
+}
+
+// IsDirect reports whether the session's parts go straight to storage.
+func (s *UploadSession) IsDirect() bool {
+	return s.Method == UploadMethodDirect
+}
+
+// Remaining is how many bytes are still to be sent.
+func (s *UploadSession) Remaining() int64 {
+	return s.Length - s.Offset
+}
+
+// IsComplete reports whether the assembled file has been recorded as a video.
+// A session whose Offset has reached Length but whose video was never created
+// (the assembly failed) is not complete; the next chunk request retries it.
+func (s *UploadSession) IsComplete() bool {
+	return s.CompletedAt != nil
+}
+
+// IsExpired reports whether the session is past its deadline at now.
+func (s *UploadSession) IsExpired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
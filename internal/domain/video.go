@@ -14,6 +14,9 @@ const (
 	VideoStatusProcessing VideoStatus = "processing"
 	VideoStatusReady      VideoStatus = "ready"
 	VideoStatusFailed     VideoStatus = "failed"
+	// VideoStatusLive is a video being broadcast: its output grows as the
+	// broadcast goes on, and it becomes ready when it ends; see LiveStream.
+	VideoStatusLive VideoStatus = "live"
 )
 
 // VideoVisibility mirrors the video_visibility enum introduced in migration 4.
@@ -35,6 +38,14 @@ func (v VideoVisibility) IsValid() bool {
 	}
 }
 
+// Streaming protocols, as recorded in Video.StreamingProtocol once a video has
+// been packaged. A CMAF video is HLS too: its fragmented MP4 segments are
+// listed by both the HLS playlists and a DASH manifest.
+const (
+	StreamingProtocolHLS  = "hls"
+	StreamingProtocolCMAF = "cmaf"
+)
+
 // Video is an uploaded video and its transcoding state.
 //
 // Fields carry explicit snake_case json tags so the serialized shape is part of
@@ -46,36 +57,78 @@ type Video struct {
 	// UserID is the owner. It is nil for videos uploaded before authentication
 	// existed, so ownership checks must treat nil as "no owner" rather than
 	// dereferencing it.
-	UserID              *uuid.UUID      `json:"user_id,omitempty"`
-	Title               string          `json:"title"`
-	Description         string          `json:"description"`
-	Filename            string          `json:"filename"`
-	FilePath            string          `json:"-"`
-	FileSize            int64           `json:"file_size"`
-	Duration            int             `json:"duration"`
-	Status              VideoStatus     `json:"status"`
-	Visibility          VideoVisibility `json:"visibility"`
-	MimeType            string          `json:"mime_type"`
-	OriginalResolution  string          `json:"original_resolution,omitempty"`
-	TranscodingProgress int             `json:"transcoding_progress"`
-	AvailableQualities  []string        `json:"available_qualities"`
-	HLSReady            bool            `json:"hls_ready"`
-	StreamingProtocol   string          `json:"streaming_protocol,omitempty"`
-
-	// ThumbnailPath and HLSMasterPath are storage keys, not URLs, and are
-	// withheld from the API for the same reason as FilePath: they describe where
-	// the bytes live on the server, which is nobody's business and is not
-	// fetchable anyway. Clients get thumbnail_url and hls_url instead — see
-	// MarshalJSON — which are real, access-controlled endpoints.
+	UserID      *uuid.UUID      `json:"user_id,omitempty"`
+	Title       string          `json:"title"`
+	Description string          `json:"description"`
+	Filename    string          `json:"filename"`
+	FilePath    string          `json:"-"`
+	FileSize    int64           `json:"file_size"`
+	Duration    int             `json:"duration"`
+	Status      VideoStatus     `json:"status"`
+	Visibility  VideoVisibility `json:"visibility"`
+	// NormalizeAudio is the uploader's choice to have the audio leveled to
+	// the worker's loudness target. It is on unless they turned it off.
+	NormalizeAudio bool `json:"normalize_audio"`
+	// Loudness is the soundtrack as measured before encoding, once it has
+	// been; see AudioLoudness.
+	Loudness            *AudioLoudness `json:"loudness,omitempty"`
+	MimeType            string         `json:"mime_type"`
+	OriginalResolution  string         `json:"original_resolution,omitempty"`
+	TranscodingProgress int            `json:"transcoding_progress"`
+	// TranscodingETA is when the worker expects processing to finish. It is
+	// only set while a video is encoding and the estimate has settled.
+	TranscodingETA     *time.Time `json:"transcoding_eta,omitempty"`
+	AvailableQualities []string   `json:"available_qualities"`
+	HLSReady           bool       `json:"hls_ready"`
+	StreamingProtocol  string     `json:"streaming_protocol,omitempty"`
+	// ProcessingError says why a failed video's source was rejected. It is
+	// nil for any other failure, which is the service's to fix, not the
+	// uploader's.
+	ProcessingError *ProcessingError `json:"processing_error,omitempty"`
+
+	// ThumbnailPath, PreviewPath and HLSMasterPath are storage keys, not URLs,
+	// and are withheld from the API for the same reason as FilePath: they
+	// describe where the bytes live on the server, which is nobody's business
+	// and is not fetchable anyway. Clients get thumbnail_url, preview_url and
+	// hls_url instead — see MarshalJSON — which are real, access-controlled
+	// endpoints. PreviewPath is the base of the animated preview, stored as
+	// <PreviewPath>.webp and <PreviewPath>.mp4.
 	ThumbnailPath *string `json:"-"`
+	PreviewPath   *string `json:"-"`
 	HLSMasterPath *string `json:"-"`
 
+	// OutputRevision is the revision of the transcoded output being served.
+	// Processing a published video again writes a new one beside it, which
+	// replaces it when done; see service.OutputKey.
+	OutputRevision int `json:"-"`
+
 	// Discovery metadata. Search filters on these, so they are part of the
 	// contract even though the upload endpoint leaves them empty by default.
 	Category string   `json:"category,omitempty"`
 	Tags     []string `json:"tags,omitempty"`
 	Language string   `json:"language,omitempty"`
 
+	// ParentID and Clip are set on a clip: the video it was cut from and the
+	// span of it, on the parent's timeline as it was then. ParentID becomes
+	// nil if the parent is deleted; the clip is a video of its own and stays.
+	ParentID *uuid.UUID `json:"parent_id,omitempty"`
+	Clip     *ClipRange `json:"clip,omitempty"`
+
+	// Trim is set while the video is being trimmed; see VideoTrim.
+	Trim *VideoTrim `json:"-"`
+
+	// Import is where an imported video's source is fetched from. FilePath
+	// is empty until the fetch has stored it.
+	Import *VideoImport `json:"-"`
+
+	// PremiereAt is when the video premieres, if it was scheduled to; see
+	// Premiere. It stays set once the premiere is over, as a record of it.
+	PremiereAt *time.Time `json:"premiere_at,omitempty"`
+	// Chapters are where the video's sections start, in order; see
+	// ValidateChapters. A broadcast's are marked while it is on air, and
+	// stay with the video it becomes.
+	Chapters []Chapter `json:"chapters,omitempty"`
+
 	// Engagement counters. Postgres triggers maintain LikeCount and
 	// CommentCount; ViewCount is incremented by the view tracker, which has no
 	// trigger behind it. They are denormalised onto videos so a listing does not
@@ -105,40 +158,65 @@ func (v Video) MarshalJSON() ([]byte, error) {
 	out := struct {
 		videoJSON
 		ThumbnailURL string `json:"thumbnail_url,omitempty"`
+		PreviewURL   string `json:"preview_url,omitempty"`
 		HLSURL       string `json:"hls_url,omitempty"`
+		DASHURL      string `json:"dash_url,omitempty"`
 	}{videoJSON: videoJSON(v)}
 
 	if v.ThumbnailPath != nil && *v.ThumbnailPath != "" {
 		out.ThumbnailURL = VideoThumbnailURL(v.ID)
 	}
+	if v.PreviewPath != nil && *v.PreviewPath != "" {
+		out.PreviewURL = VideoPreviewURL(v.ID)
+	}
 	if v.HLSReady {
 		out.HLSURL = VideoHLSURL(v.ID)
 	}
+	if v.HasDASH() {
+		out.DASHURL = VideoDASHURL(v.ID)
+	}
 
 	return json.Marshal(out)
 }
 
-// VideoThumbnailURL and VideoHLSURL are the canonical client-facing URLs for a
-// video's assets. They live here, next to the type they describe, so every
-// projection of a video (the full record, a search hit, a playlist entry) agrees
-// on one answer.
+// VideoThumbnailURL, VideoPreviewURL, VideoHLSURL and VideoDASHURL are the
+// canonical client-facing URLs for a video's assets. They live here, next to
+// the type they describe, so every projection of a video (the full record, a
+// search hit, a playlist entry) agrees on one answer.
 func VideoThumbnailURL(id uuid.UUID) string {
 	return "/api/v1/videos/" + id.String() + "/thumbnail"
 }
 
+// VideoPreviewURL serves the animated preview as WebP; ?format=mp4 asks for
+// the MP4.
+func VideoPreviewURL(id uuid.UUID) string {
+	return "/api/v1/videos/" + id.String() + "/preview"
+}
+
 func VideoHLSURL(id uuid.UUID) string {
 	return "/api/v1/videos/" + id.String() + "/hls/master.m3u8"
 }
 
+func VideoDASHURL(id uuid.UUID) string {
+	return "/api/v1/videos/" + id.String() + "/dash/manifest.mpd"
+}
+
+// HasDASH reports whether the video was packaged with a DASH manifest. Only
+// CMAF packaging writes one; MPEG-TS segments are HLS-only.
+func (v *Video) HasDASH() bool {
+	return v.HLSReady && v.StreamingProtocol == StreamingProtocolCMAF
+}
+
 // IsOwnedBy reports whether userID owns this video. An unowned (legacy) video
 // is owned by nobody.
 func (v *Video) IsOwnedBy(userID uuid.UUID) bool {
 	return v.UserID != nil && *v.UserID == userID
 }
 
-// IsPubliclyVisible reports whether the video may be listed to anonymous users.
+// IsPubliclyVisible reports whether the video may be listed to anonymous
+// users: a public one that is ready, or on air.
 func (v *Video) IsPubliclyVisible() bool {
-	return v.Visibility == VisibilityPublic && v.Status == VideoStatusReady
+	return v.Visibility == VisibilityPublic && (v.Status == VideoStatusReady || v.Status == VideoStatusLive)
 }
 
 func NewVideo(title, description, filename, filePath, mimeType string, fileSize int64) (*Video, error) {
@@ -152,6 +230,7 @@ func NewVideo(title, description, filename, filePath, mimeType string, fileSize
 		MimeType:            mimeType,
 		Status:              VideoStatusUploading,
 		Visibility:          VisibilityPublic,
+		NormalizeAudio:      true,
 		TranscodingProgress: 0,
 		AvailableQualities:  []string{},
 		CreatedAt:           time.Now(),
@@ -203,6 +282,38 @@ func (v *Video) UpdateProgress(percent int) error {
 	return nil
 }
 
+// VideoProgress is where a video's processing stands. The worker publishes one
+// each time progress moves, and the status stream relays them to clients.
+type VideoProgress struct {
+	VideoID  uuid.UUID   `json:"video_id"`
+	Status   VideoStatus `json:"status"`
+	Progress int         `json:"progress"`
+	ETA      *time.Time  `json:"eta,omitempty"`
+	// Error is set when the video failed because its source was rejected.
+	Error *ProcessingError `json:"error,omitempty"`
+}
+
+// Finished reports whether processing is over, one way or the other; nothing
+// further will be published for the video.
+func (p VideoProgress) Finished() bool {
+	return p.Status == VideoStatusReady || p.Status == VideoStatusFailed
+}
+
+// ProgressSnapshot is the video's processing state as stored. A rejection is
+// only reported while the video is failed: one being retried may yet pass.
+func (v *Video) ProgressSnapshot() VideoProgress {
+	p := VideoProgress{
+		VideoID:  v.ID,
+		Status:   v.Status,
+		Progress: v.TranscodingProgress,
+		ETA:      v.TranscodingETA,
+	}
+	if v.Status == VideoStatusFailed {
+		p.Error = v.ProcessingError
+	}
+	return p
+}
+
 func (v *Video) Validate() error {
 	if v.Title == "" {
 		return ErrInvalidTitle
@@ -216,6 +216,8 @@ func TestVideoIsPubliclyVisible(t *testing.T) {
 		{"public but uploading", VisibilityPublic, VideoStatusUploading, false},
 		{"public but processing", VisibilityPublic, VideoStatusProcessing, false},
 		{"public but failed", VisibilityPublic, VideoStatusFailed, false},
+		{"public and live", VisibilityPublic, VideoStatusLive, true},
+		{"unlisted and live", VisibilityUnlisted, VideoStatusLive, false},
 		{"private and ready", VisibilityPrivate, VideoStatusReady, false},
 		{"unlisted and ready", VisibilityUnlisted, VideoStatusReady, false},
 		{"private and processing", VisibilityPrivate, VideoStatusProcessing, false},
@@ -253,3 +255,26 @@ func TestVideoVisibilityIsValid(t *testing.T) {
 		})
 	}
 }
+
+func TestVideoHasDASH(t *testing.T) {
+	tests := []struct {
+		name     string
+		hlsReady bool
+		protocol string
+		want     bool
+	}{
+		{"cmaf and ready", true, StreamingProtocolCMAF, true},
+		{"cmaf not yet packaged", false, StreamingProtocolCMAF, false},
+		{"mpeg-ts hls", true, StreamingProtocolHLS, false},
+		{"progressive only", false, "progressive", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			video := &Video{HLSReady: tt.hlsReady, StreamingProtocol: tt.protocol}
+			if got := video.HasDASH(); got != tt.want {
+				t.Errorf("HasDASH() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
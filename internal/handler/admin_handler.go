@@ -7,6 +7,7 @@ import (
 	"github.com/Nuu-maan/video-streaming-service/internal/domain"
 	"github.com/Nuu-maan/video-streaming-service/internal/queue"
 	"github.com/Nuu-maan/video-streaming-service/internal/repository"
+	"github.com/Nuu-maan/video-streaming-service/internal/service"
 	"github.com/Nuu-maan/video-streaming-service/pkg/logger"
 	"github.com/Nuu-maan/video-streaming-service/pkg/response"
 	"github.com/Nuu-maan/video-streaming-service/pkg/validator"
@@ -16,6 +17,7 @@ import (
 
 type AdminHandler struct {
 	videoRepo   repository.VideoRepository
+	stages      service.ProcessingStageRepository
 	queueClient *queue.QueueClient
 	inspector   *asynq.Inspector
 	log         *logger.Logger
@@ -27,18 +29,23 @@ type AdminHandler struct {
 // against an authenticated Redis and leak its connection.
 func NewAdminHandler(
 	videoRepo repository.VideoRepository,
+	stages service.ProcessingStageRepository,
 	queueClient *queue.QueueClient,
 	inspector *asynq.Inspector,
 	log *logger.Logger,
 ) *AdminHandler {
 	return &AdminHandler{
 		videoRepo:   videoRepo,
+		stages:      stages,
 		queueClient: queueClient,
 		inspector:   inspector,
 		log:         log,
 	}
 }
 
+// RetryVideo resumes processing a video that failed, or that is stuck
+// processing because its worker died. The stages already done are kept; the
+// worker runs the rest again.
 func (h *AdminHandler) RetryVideo(c *gin.Context) {
 	ctx := c.Request.Context()
 
@@ -62,16 +69,24 @@ func (h *AdminHandler) RetryVideo(c *gin.Context) {
 		return
 	}
 
-	if video.Status != domain.VideoStatusFailed {
-		response.BadRequest(c, "Only failed videos can be retried")
+	if video.Status != domain.VideoStatusFailed && video.Status != domain.VideoStatusProcessing {
+		response.BadRequest(c, "Only failed or stuck videos can be retried")
 		return
 	}
 
-	if err := h.videoRepo.UpdateStatus(ctx, videoID, domain.VideoStatusUploading); err != nil {
-		h.log.Error(ctx, "failed to update video status", err, map[string]interface{}{
+	// An import that failed before its source was in is fetched again.
+	if video.Import != nil && video.FilePath == "" {
+		if err := h.queueClient.EnqueueVideoImport(ctx, videoID.String()); err != nil {
+			h.log.Error(ctx, "failed to enqueue video import", err, map[string]interface{}{
+				"video_id": videoID,
+			})
+			response.InternalError(c, "Failed to enqueue video import")
+			return
+		}
+		response.Success(c, http.StatusOK, gin.H{
+			"message":  "Video import resumed",
 			"video_id": videoID,
 		})
-		response.InternalError(c, "Failed to update video status")
 		return
 	}
 
@@ -84,8 +99,80 @@ func (h *AdminHandler) RetryVideo(c *gin.Context) {
 	}
 
 	response.Success(c, http.StatusOK, gin.H{
-		"message":  "Video processing retry initiated",
+		"message":  "Video processing resumed",
+		"video_id": videoID,
+	})
+}
+
+// GetEncodingLadder returns the ladder per-title encoding chose for a video
+// and the measurements it was chosen from.
+func (h *AdminHandler) GetEncodingLadder(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	videoID, err := validator.ValidateUUID(c.Param("id"))
+	if err != nil {
+		response.ValidationError(c, "Invalid video ID format")
+		return
+	}
+
+	ladder, err := h.videoRepo.GetEncodingLadder(ctx, videoID)
+	if err != nil {
+		if errors.Is(err, domain.ErrVideoNotFound) {
+			response.NotFound(c, "Video not found")
+			return
+		}
+		h.log.Error(ctx, "failed to get encoding ladder", err, map[string]interface{}{
+			"video_id": videoID,
+		})
+		response.InternalError(c, "Failed to retrieve encoding ladder")
+		return
+	}
+	if ladder == nil {
+		response.NotFound(c, "Video has not been analysed for per-title encoding")
+		return
+	}
+
+	response.Success(c, http.StatusOK, ladder)
+}
+
+// ListProcessingStages returns the stages a video is processed in, with how
+// far each got and why it last failed.
+func (h *AdminHandler) ListProcessingStages(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	videoID, err := validator.ValidateUUID(c.Param("id"))
+	if err != nil {
+		response.ValidationError(c, "Invalid video ID format")
+		return
+	}
+
+	if _, err := h.videoRepo.GetByID(ctx, videoID); err != nil {
+		if errors.Is(err, domain.ErrVideoNotFound) {
+			response.NotFound(c, "Video not found")
+			return
+		}
+		h.log.Error(ctx, "failed to get video", err, map[string]interface{}{
+			"video_id": videoID,
+		})
+		response.InternalError(c, "Failed to retrieve video")
+		return
+	}
+
+	stages, err := h.stages.ListByVideo(ctx, videoID)
+	if err != nil {
+		h.log.Error(ctx, "failed to list processing stages", err, map[string]interface{}{
+			"video_id": videoID,
+		})
+		response.InternalError(c, "Failed to retrieve processing stages")
+		return
+	}
+	if stages == nil {
+		stages = []*domain.ProcessingStage{}
+	}
+
+	response.Success(c, http.StatusOK, gin.H{
 		"video_id": videoID,
+		"stages":   stages,
 	})
 }
 
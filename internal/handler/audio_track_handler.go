@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/internal/queue"
+	"github.com/Nuu-maan/video-streaming-service/internal/repository"
+	"github.com/Nuu-maan/video-streaming-service/internal/service"
+	"github.com/Nuu-maan/video-streaming-service/pkg/appctx"
+	"github.com/Nuu-maan/video-streaming-service/pkg/logger"
+	"github.com/Nuu-maan/video-streaming-service/pkg/response"
+	"github.com/Nuu-maan/video-streaming-service/pkg/validator"
+)
+
+// AudioTrackHandler lists a video's audio tracks and accepts dubbed ones.
+type AudioTrackHandler struct {
+	audioTracks *service.AudioTrackService
+	videoRepo   repository.VideoRepository
+	queueClient *queue.QueueClient
+	log         *logger.Logger
+}
+
+func NewAudioTrackHandler(
+	audioTracks *service.AudioTrackService,
+	videoRepo repository.VideoRepository,
+	queueClient *queue.QueueClient,
+	log *logger.Logger,
+) *AudioTrackHandler {
+	return &AudioTrackHandler{
+		audioTracks: audioTracks,
+		videoRepo:   videoRepo,
+		queueClient: queueClient,
+		log:         log,
+	}
+}
+
+// List returns the audio tracks a player can offer for a video. Its owner also
+// sees dubs that are still encoding or that failed.
+func (h *AudioTrackHandler) List(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	video, ok := h.loadVideo(c)
+	if !ok {
+		return
+	}
+	if !canViewVideo(ctx, video) {
+		response.NotFound(c, "Video not found")
+		return
+	}
+
+	principal, ok := appctx.PrincipalFrom(ctx)
+	owner := ok && video.IsOwnedBy(principal.UserID)
+
+	tracks, err := h.audioTracks.ListTracks(ctx, video, owner)
+	if err != nil {
+		h.log.Error(ctx, "failed to list audio tracks", err, map[string]interface{}{"video_id": video.ID})
+		response.InternalError(c, "Failed to retrieve audio tracks")
+		return
+	}
+	response.Success(c, http.StatusOK, tracks)
+}
+
+// Upload accepts a dubbed audio track for a ready video and queues it for
+// encoding. Only the video's owner may add one.
+func (h *AudioTrackHandler) Upload(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	principal, ok := appctx.PrincipalFrom(ctx)
+	if !ok {
+		response.Unauthorized(c, "Authentication required to upload")
+		return
+	}
+
+	video, ok := h.loadVideo(c)
+	if !ok {
+		return
+	}
+	if !canViewVideo(ctx, video) {
+		response.NotFound(c, "Video not found")
+		return
+	}
+	if !video.IsOwnedBy(principal.UserID) {
+		response.Error(c, http.StatusForbidden, "FORBIDDEN", "You may only add audio tracks to your own videos")
+		return
+	}
+
+	file, header, err := c.Request.FormFile("audio")
+	if err != nil {
+		response.ValidationError(c, "An audio file is required")
+		return
+	}
+	defer file.Close()
+
+	track, err := h.audioTracks.AddDub(ctx, video, service.AddDubRequest{
+		File:     file,
+		Header:   header,
+		Language: c.PostForm("language"),
+		Label:    c.PostForm("label"),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrVideoNotReady):
+			response.Error(c, http.StatusConflict, "VIDEO_NOT_READY", "Audio tracks can only be added once the video is ready")
+		case errors.Is(err, validator.ErrFileTooLarge):
+			response.Error(c, http.StatusRequestEntityTooLarge, "FILE_TOO_LARGE", err.Error())
+		case errors.Is(err, validator.ErrInvalidFormat):
+			response.Error(c, http.StatusUnsupportedMediaType, "INVALID_FORMAT", err.Error())
+		case errors.Is(err, domain.ErrInvalidLanguage), errors.Is(err, domain.ErrInvalidInput):
+			response.ValidationError(c, err.Error())
+		default:
+			h.log.Error(ctx, "audio track upload failed", err, map[string]interface{}{
+				"video_id": video.ID,
+				"filename": header.Filename,
+			})
+			response.InternalError(c, "Failed to upload audio track")
+		}
+		return
+	}
+
+	// As with a video, the track is safely recorded and a failure to queue it
+	// is only logged.
+	if err := h.queueClient.EnqueueAudioTrackProcessing(ctx, video.ID.String(), track.ID.String()); err != nil {
+		h.log.Error(ctx, "audio track stored but could not be queued for processing", err, map[string]interface{}{
+			"video_id": video.ID,
+			"track_id": track.ID,
+		})
+	}
+
+	response.Success(c, http.StatusCreated, track)
+}
+
+func (h *AudioTrackHandler) loadVideo(c *gin.Context) (*domain.Video, bool) {
+	ctx := c.Request.Context()
+
+	videoID, err := validator.ValidateUUID(c.Param("id"))
+	if err != nil {
+		response.ValidationError(c, "Invalid video ID")
+		return nil, false
+	}
+
+	video, err := h.videoRepo.GetByID(ctx, videoID)
+	if err != nil {
+		if errors.Is(err, domain.ErrVideoNotFound) {
+			response.NotFound(c, "Video not found")
+			return nil, false
+		}
+		h.log.Error(ctx, "failed to load video", err, map[string]interface{}{"video_id": videoID})
+		response.InternalError(c, "Failed to retrieve video")
+		return nil, false
+	}
+	return video, true
+}
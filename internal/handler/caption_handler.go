@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/internal/queue"
+	"github.com/Nuu-maan/video-streaming-service/internal/repository"
+	"github.com/Nuu-maan/video-streaming-service/internal/service"
+	"github.com/Nuu-maan/video-streaming-service/pkg/appctx"
+	"github.com/Nuu-maan/video-streaming-service/pkg/logger"
+	"github.com/Nuu-maan/video-streaming-service/pkg/response"
+	"github.com/Nuu-maan/video-streaming-service/pkg/validator"
+)
+
+// CaptionHandler lists a video's captions and accepts uploaded ones.
+type CaptionHandler struct {
+	captions    *service.CaptionService
+	videoRepo   repository.VideoRepository
+	queueClient *queue.QueueClient
+	log         *logger.Logger
+}
+
+func NewCaptionHandler(
+	captions *service.CaptionService,
+	videoRepo repository.VideoRepository,
+	queueClient *queue.QueueClient,
+	log *logger.Logger,
+) *CaptionHandler {
+	return &CaptionHandler{
+		captions:    captions,
+		videoRepo:   videoRepo,
+		queueClient: queueClient,
+		log:         log,
+	}
+}
+
+// List returns the captions a player can offer for a video. Its owner also
+// sees uploads that are still being published or that failed.
+func (h *CaptionHandler) List(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	video, ok := h.loadVideo(c)
+	if !ok {
+		return
+	}
+	if !canViewVideo(ctx, video) {
+		response.NotFound(c, "Video not found")
+		return
+	}
+
+	principal, ok := appctx.PrincipalFrom(ctx)
+	owner := ok && video.IsOwnedBy(principal.UserID)
+
+	captions, err := h.captions.ListCaptions(ctx, video, owner)
+	if err != nil {
+		h.log.Error(ctx, "failed to list captions", err, map[string]interface{}{"video_id": video.ID})
+		response.InternalError(c, "Failed to retrieve captions")
+		return
+	}
+	response.Success(c, http.StatusOK, captions)
+}
+
+// Upload accepts an SRT or WebVTT caption for a ready video and queues it for
+// publishing. Only the video's owner may add one.
+func (h *CaptionHandler) Upload(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	principal, ok := appctx.PrincipalFrom(ctx)
+	if !ok {
+		response.Unauthorized(c, "Authentication required to upload")
+		return
+	}
+
+	video, ok := h.loadVideo(c)
+	if !ok {
+		return
+	}
+	if !canViewVideo(ctx, video) {
+		response.NotFound(c, "Video not found")
+		return
+	}
+	if !video.IsOwnedBy(principal.UserID) {
+		response.Error(c, http.StatusForbidden, "FORBIDDEN", "You may only add captions to your own videos")
+		return
+	}
+
+	isDefault := false
+	if value := c.PostForm("default"); value != "" {
+		var err error
+		if isDefault, err = strconv.ParseBool(value); err != nil {
+			response.ValidationError(c, "default must be true or false")
+			return
+		}
+	}
+
+	file, header, err := c.Request.FormFile("caption")
+	if err != nil {
+		response.ValidationError(c, "A caption file is required")
+		return
+	}
+	defer file.Close()
+
+	caption, err := h.captions.AddCaption(ctx, video, service.AddCaptionRequest{
+		File:      file,
+		Header:    header,
+		Language:  c.PostForm("language"),
+		Label:     c.PostForm("label"),
+		Kind:      domain.CaptionKind(c.PostForm("kind")),
+		IsDefault: isDefault,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrVideoNotReady):
+			response.Error(c, http.StatusConflict, "VIDEO_NOT_READY", "Captions can only be added once the video is ready")
+		case errors.Is(err, validator.ErrFileTooLarge):
+			response.Error(c, http.StatusRequestEntityTooLarge, "FILE_TOO_LARGE", err.Error())
+		case errors.Is(err, validator.ErrInvalidFormat), errors.Is(err, domain.ErrInvalidCaption):
+			response.Error(c, http.StatusUnsupportedMediaType, "INVALID_FORMAT", err.Error())
+		case errors.Is(err, domain.ErrInvalidLanguage), errors.Is(err, domain.ErrInvalidInput):
+			response.ValidationError(c, err.Error())
+		default:
+			h.log.Error(ctx, "caption upload failed", err, map[string]interface{}{
+				"video_id": video.ID,
+				"filename": header.Filename,
+			})
+			response.InternalError(c, "Failed to upload caption")
+		}
+		return
+	}
+
+	// As with a video, the caption is safely recorded and a failure to queue
+	// it is only logged.
+	if err := h.queueClient.EnqueueCaptionProcessing(ctx, video.ID.String(), caption.ID.String()); err != nil {
+		h.log.Error(ctx, "caption stored but could not be queued for processing", err, map[string]interface{}{
+			"video_id":   video.ID,
+			"caption_id": caption.ID,
+		})
+	}
+
+	response.Success(c, http.StatusCreated, caption)
+}
+
+func (h *CaptionHandler) loadVideo(c *gin.Context) (*domain.Video, bool) {
+	ctx := c.Request.Context()
+
+	videoID, err := validator.ValidateUUID(c.Param("id"))
+	if err != nil {
+		response.ValidationError(c, "Invalid video ID")
+		return nil, false
+	}
+
+	video, err := h.videoRepo.GetByID(ctx, videoID)
+	if err != nil {
+		if errors.Is(err, domain.ErrVideoNotFound) {
+			response.NotFound(c, "Video not found")
+			return nil, false
+		}
+		h.log.Error(ctx, "failed to load video", err, map[string]interface{}{"video_id": videoID})
+		response.InternalError(c, "Failed to retrieve video")
+		return nil, false
+	}
+	return video, true
+}
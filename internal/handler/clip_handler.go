@@ -0,0 +1,227 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/internal/queue"
+	"github.com/Nuu-maan/video-streaming-service/internal/repository"
+	"github.com/Nuu-maan/video-streaming-service/internal/service"
+	"github.com/Nuu-maan/video-streaming-service/pkg/appctx"
+	"github.com/Nuu-maan/video-streaming-service/pkg/logger"
+	"github.com/Nuu-maan/video-streaming-service/pkg/response"
+	"github.com/Nuu-maan/video-streaming-service/pkg/validator"
+)
+
+// ClipHandler trims videos and cuts clips from them. Both go back through
+// the worker: the cut becomes a source of its own, processed like an upload.
+type ClipHandler struct {
+	uploadService *service.UploadService
+	videoRepo     repository.VideoRepository
+	queueClient   *queue.QueueClient
+	log           *logger.Logger
+}
+
+func NewClipHandler(
+	uploadService *service.UploadService,
+	videoRepo repository.VideoRepository,
+	queueClient *queue.QueueClient,
+	log *logger.Logger,
+) *ClipHandler {
+	return &ClipHandler{
+		uploadService: uploadService,
+		videoRepo:     videoRepo,
+		queueClient:   queueClient,
+		log:           log,
+	}
+}
+
+// clipRangeRequest is a span of a video in seconds. Pointers, so that a
+// missing start is not taken for the very beginning.
+type clipRangeRequest struct {
+	Start *float64 `json:"start" binding:"required"`
+	End   *float64 `json:"end" binding:"required"`
+}
+
+func (r clipRangeRequest) clipRange() domain.ClipRange {
+	return domain.ClipRange{Start: *r.Start, End: *r.End}
+}
+
+type createClipRequest struct {
+	clipRangeRequest
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+// Trim cuts a ready video down to the span asked for and processes it again.
+// The video keeps playing as it was until the trimmed output is published.
+// One trim runs at a time: another asked for meanwhile is refused.
+func (h *ClipHandler) Trim(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	video, ok := loadOwnedVideo(c, h.videoRepo, h.log, "You may only trim your own videos")
+	if !ok {
+		return
+	}
+
+	var req clipRangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, "start and end are required, in seconds")
+		return
+	}
+	span := req.clipRange()
+	if err := service.CheckTrim(video, span); err != nil {
+		h.respondCutError(c, video, err)
+		return
+	}
+
+	if err := h.videoRepo.BeginTrim(ctx, video.ID, domain.NewVideoTrim(video, span)); err != nil {
+		h.respondCutError(c, video, err)
+		return
+	}
+	// Unlike an upload, a trim that cannot be queued has not happened.
+	if err := h.queueClient.EnqueueVideoCut(ctx, queue.VideoCutPayload{
+		VideoID:     video.ID.String(),
+		FromVideoID: video.ID.String(),
+		Start:       span.Start,
+		End:         span.End,
+		SourcePath:  video.FilePath,
+	}); err != nil {
+		h.log.Error(ctx, "failed to enqueue video trim", err, map[string]interface{}{"video_id": video.ID})
+		if err := h.videoRepo.FinishTrim(ctx, video.ID); err != nil {
+			h.log.Error(ctx, "failed to forget unqueued video trim", err, map[string]interface{}{"video_id": video.ID})
+		}
+		response.InternalError(c, "Failed to queue the trim")
+		return
+	}
+
+	response.Success(c, http.StatusAccepted, gin.H{
+		"message":  "Video trim queued",
+		"video_id": video.ID,
+		"clip":     span,
+	})
+}
+
+// Create cuts a clip from a ready video as a new video of the same owner,
+// and queues it for processing.
+func (h *ClipHandler) Create(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	parent, ok := loadOwnedVideo(c, h.videoRepo, h.log, "You may only clip your own videos")
+	if !ok {
+		return
+	}
+
+	var req createClipRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, "start and end are required, in seconds")
+		return
+	}
+
+	clip, err := h.uploadService.CreateClip(ctx, parent, service.ClipRequest{
+		Title:       req.Title,
+		Description: req.Description,
+		Range:       req.clipRange(),
+	})
+	if err != nil {
+		h.respondCutError(c, parent, err)
+		return
+	}
+
+	// As with an upload, the clip is safely recorded and a failure to queue
+	// it is only logged.
+	if err := h.queueClient.EnqueueVideoCut(ctx, queue.VideoCutPayload{
+		VideoID:     clip.ID.String(),
+		FromVideoID: parent.ID.String(),
+		Start:       clip.Clip.Start,
+		End:         clip.Clip.End,
+	}); err != nil {
+		h.log.Error(ctx, "clip recorded but could not be queued for cutting", err, map[string]interface{}{
+			"video_id":  clip.ID,
+			"parent_id": parent.ID,
+		})
+	}
+
+	response.Success(c, http.StatusCreated, clip)
+}
+
+// List returns the clips cut from a video. Its owner sees every one, however
+// far along; anyone else who can watch the video sees its public, ready
+// clips.
+func (h *ClipHandler) List(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	video, ok := h.loadVideo(c)
+	if !ok {
+		return
+	}
+	if !canViewVideo(ctx, video) {
+		response.NotFound(c, "Video not found")
+		return
+	}
+
+	page := parsePage(c)
+	parentID := video.ID
+	filter := repository.VideoFilter{ParentID: &parentID}
+	if principal, ok := appctx.PrincipalFrom(ctx); !ok || !video.IsOwnedBy(principal.UserID) {
+		visibility := domain.VisibilityPublic
+		status := domain.VideoStatusReady
+		filter.Visibility = &visibility
+		filter.Status = &status
+	}
+
+	clips, err := h.videoRepo.List(ctx, filter, page)
+	if err != nil {
+		h.log.Error(ctx, "failed to list clips", err, map[string]interface{}{"video_id": video.ID})
+		response.InternalError(c, "Failed to retrieve clips")
+		return
+	}
+	total, err := h.videoRepo.Count(ctx, filter)
+	if err != nil {
+		h.log.Error(ctx, "failed to count clips", err, map[string]interface{}{"video_id": video.ID})
+		response.InternalError(c, "Failed to retrieve clips")
+		return
+	}
+
+	response.SuccessWithList(c, clips, paginationMeta(total, page))
+}
+
+func (h *ClipHandler) respondCutError(c *gin.Context, video *domain.Video, err error) {
+	switch {
+	case errors.Is(err, domain.ErrVideoNotReady):
+		response.Error(c, http.StatusConflict, "VIDEO_NOT_READY", "Only a ready video can be trimmed or clipped")
+	case errors.Is(err, domain.ErrTrimInProgress):
+		response.Error(c, http.StatusConflict, "TRIM_IN_PROGRESS", "The video is already being trimmed")
+	case errors.Is(err, domain.ErrInvalidClipRange), errors.Is(err, domain.ErrInvalidTitle),
+		errors.Is(err, domain.ErrInvalidInput):
+		response.ValidationError(c, err.Error())
+	default:
+		h.log.Error(c.Request.Context(), "video cut failed", err, map[string]interface{}{"video_id": video.ID})
+		response.InternalError(c, "Failed to cut video")
+	}
+}
+
+func (h *ClipHandler) loadVideo(c *gin.Context) (*domain.Video, bool) {
+	ctx := c.Request.Context()
+
+	videoID, err := validator.ValidateUUID(c.Param("id"))
+	if err != nil {
+		response.ValidationError(c, "Invalid video ID")
+		return nil, false
+	}
+
+	video, err := h.videoRepo.GetByID(ctx, videoID)
+	if err != nil {
+		if errors.Is(err, domain.ErrVideoNotFound) {
+			response.NotFound(c, "Video not found")
+			return nil, false
+		}
+		h.log.Error(ctx, "failed to load video", err, map[string]interface{}{"video_id": videoID})
+		response.InternalError(c, "Failed to retrieve video")
+		return nil, false
+	}
+	return video, true
+}
@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/internal/queue"
+	"github.com/Nuu-maan/video-streaming-service/internal/service"
+	"github.com/Nuu-maan/video-streaming-service/internal/storage"
+	"github.com/Nuu-maan/video-streaming-service/pkg/appctx"
+	"github.com/Nuu-maan/video-streaming-service/pkg/logger"
+	"github.com/Nuu-maan/video-streaming-service/pkg/response"
+)
+
+// DirectUploadHandler serves uploads that bypass the API: the client asks for
+// presigned part URLs, PUTs the parts straight to storage, and reports back
+// to complete the upload. With MinIO the API never sees a byte of the video.
+// The local store has no URLs of its own to presign, so Part receives its
+// parts instead, which keeps the flow identical in development.
+type DirectUploadHandler struct {
+	uploads      *service.DirectUploadService
+	queueClient  *queue.QueueClient
+	maxSize      int64
+	chunkTimeout time.Duration
+	log          *logger.Logger
+}
+
+func NewDirectUploadHandler(
+	uploads *service.DirectUploadService,
+	queueClient *queue.QueueClient,
+	maxSize int64,
+	chunkTimeout time.Duration,
+	log *logger.Logger,
+) *DirectUploadHandler {
+	return &DirectUploadHandler{
+		uploads:      uploads,
+		queueClient:  queueClient,
+		maxSize:      maxSize,
+		chunkTimeout: chunkTimeout,
+		log:          log,
+	}
+}
+
+type initiateDirectUploadRequest struct {
+	Filename    string                 `json:"filename" binding:"required"`
+	Size        int64                  `json:"size" binding:"required"`
+	ContentType string                 `json:"content_type"`
+	Title       string                 `json:"title"`
+	Description string                 `json:"description"`
+	Visibility  domain.VideoVisibility `json:"visibility"`
+	// NormalizeAudio is on unless sent false.
+	NormalizeAudio *bool `json:"normalize_audio"`
+}
+
+type completeDirectUploadRequest struct {
+	Parts []storage.CompletedPart `json:"parts" binding:"required"`
+}
+
+// directUploadResponse is what a client needs to send its parts: where each
+// one goes, how large it must be, and until when the URLs are good.
+type directUploadResponse struct {
+	Upload    *domain.UploadSession   `json:"upload"`
+	PartSize  int64                   `json:"part_size"`
+	Parts     []storage.PresignedPart `json:"parts"`
+	ExpiresAt time.Time               `json:"expires_at"`
+}
+
+// Initiate opens a direct upload and returns one presigned URL per part.
+func (h *DirectUploadHandler) Initiate(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	principal, ok := appctx.PrincipalFrom(ctx)
+	if !ok {
+		response.Unauthorized(c, "Authentication required to upload")
+		return
+	}
+
+	var req initiateDirectUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, "filename and size are required")
+		return
+	}
+	if req.Size > h.maxSize {
+		response.Error(c, http.StatusRequestEntityTooLarge, "FILE_TOO_LARGE",
+			fmt.Sprintf("upload is %d bytes, maximum is %d bytes", req.Size, h.maxSize))
+		return
+	}
+
+	session, upload, err := h.uploads.Initiate(ctx, service.CreateUploadSessionRequest{
+		OwnerID:        principal.UserID,
+		Filename:       req.Filename,
+		MimeType:       req.ContentType,
+		Title:          req.Title,
+		Description:    req.Description,
+		Visibility:     req.Visibility,
+		NormalizeAudio: req.NormalizeAudio == nil || *req.NormalizeAudio,
+		Length:         req.Size,
+	})
+	if err != nil {
+		h.respondDirectError(c, err)
+		return
+	}
+
+	response.Success(c, http.StatusCreated, directUploadResponse{
+		Upload:    session,
+		PartSize:  upload.PartSize,
+		Parts:     upload.Parts,
+		ExpiresAt: session.ExpiresAt,
+	})
+}
+
+// Complete assembles the uploaded parts, records the video, and queues it for
+// processing. Repeating it for a finished upload returns the session again
+// with 200 instead of 201.
+func (h *DirectUploadHandler) Complete(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	principal, ok := appctx.PrincipalFrom(ctx)
+	if !ok {
+		response.Unauthorized(c, "Authentication required to upload")
+		return
+	}
+	id, ok := parseUploadID(c)
+	if !ok {
+		return
+	}
+
+	var req completeDirectUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, "parts is required")
+		return
+	}
+
+	session, video, err := h.uploads.Complete(ctx, id, principal.UserID, req.Parts)
+	if err != nil {
+		h.respondDirectError(c, err)
+		return
+	}
+	if video == nil {
+		response.Success(c, http.StatusOK, session)
+		return
+	}
+
+	// As with the other upload paths, the video is safely recorded; failing
+	// to queue it is recoverable via the admin retry endpoint.
+	if err := h.queueClient.EnqueueVideoProcessing(ctx, video.ID.String(), 0); err != nil {
+		h.log.Error(ctx, "video stored but could not be queued for processing", err, map[string]interface{}{
+			"video_id": video.ID,
+		})
+	}
+	response.Success(c, http.StatusCreated, session)
+}
+
+// Abort abandons a direct upload and discards any parts already sent.
+func (h *DirectUploadHandler) Abort(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	principal, ok := appctx.PrincipalFrom(ctx)
+	if !ok {
+		response.Unauthorized(c, "Authentication required")
+		return
+	}
+	id, ok := parseUploadID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.uploads.Abort(ctx, id, principal.UserID); err != nil {
+		h.respondDirectError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Part receives one part for the local store. It is deliberately outside
+// RequireAuth: the part URL's signature is the credential, exactly as it is
+// for a presigned MinIO URL, so a browser can PUT to either the same way.
+func (h *DirectUploadHandler) Part(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	part, err := strconv.Atoi(c.Param("part"))
+	if err != nil || part < 1 {
+		response.NotFound(c, "Upload part not found")
+		return
+	}
+
+	// Parts are large by design; see UploadSessionHandler.Patch.
+	if err := http.NewResponseController(c.Writer).SetReadDeadline(time.Now().Add(h.chunkTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		h.log.Warn(ctx, "could not extend read deadline for upload part", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	etag, err := h.uploads.ReceivePart(ctx, c.Param("uploadId"), part, c.Request.URL.Query(), c.Request.Body)
+	if err != nil {
+		h.respondDirectError(c, err)
+		return
+	}
+
+	c.Header("ETag", etag)
+	c.Status(http.StatusOK)
+}
+
+// respondDirectError maps direct-upload failures onto status codes, falling
+// back to the session and upload mappings the tus endpoints use.
+func (h *DirectUploadHandler) respondDirectError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrDirectUploadUnsupported):
+		response.NotFound(c, "Direct uploads are not available with this storage backend")
+	case errors.Is(err, domain.ErrStorageSignatureInvalid):
+		response.Error(c, http.StatusForbidden, "INVALID_SIGNATURE", err.Error())
+	case errors.Is(err, domain.ErrDirectUploadPartInvalid):
+		response.Error(c, http.StatusBadRequest, "INVALID_PART", err.Error())
+	case errors.Is(err, domain.ErrUploadIncomplete):
+		response.Error(c, http.StatusConflict, "UPLOAD_INCOMPLETE", err.Error())
+	default:
+		respondSessionError(c, h.log, err)
+	}
+}
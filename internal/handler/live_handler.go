@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/internal/service"
+	"github.com/Nuu-maan/video-streaming-service/pkg/appctx"
+	"github.com/Nuu-maan/video-streaming-service/pkg/logger"
+	"github.com/Nuu-maan/video-streaming-service/pkg/response"
+	"github.com/Nuu-maan/video-streaming-service/pkg/validator"
+)
+
+// LiveHandler creates live streams for their owners, and answers the ingest
+// server's questions about who may push to and read from them.
+type LiveHandler struct {
+	live *service.LiveService
+	log  *logger.Logger
+}
+
+func NewLiveHandler(live *service.LiveService, log *logger.Logger) *LiveHandler {
+	return &LiveHandler{
+		live: live,
+		log:  log,
+	}
+}
+
+type createLiveStreamRequest struct {
+	Title       string                 `json:"title"`
+	Description string                 `json:"description"`
+	Visibility  domain.VideoVisibility `json:"visibility"`
+}
+
+// Create creates a live stream and the video it broadcasts into. The stream
+// key is in the response and nowhere else: it is not stored, and is not
+// shown again.
+func (h *LiveHandler) Create(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	principal, ok := appctx.PrincipalFrom(ctx)
+	if !ok {
+		response.Unauthorized(c, "Authentication required to go live")
+		return
+	}
+
+	var req createLiveStreamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, "Invalid live stream request")
+		return
+	}
+
+	stream, video, ingest, err := h.live.CreateStream(ctx, service.LiveRequest{
+		Title:       req.Title,
+		Description: req.Description,
+		OwnerID:     principal.UserID,
+		Visibility:  req.Visibility,
+	})
+	if err != nil {
+		respondUploadError(c, h.log, err, "")
+		return
+	}
+
+	response.Success(c, http.StatusCreated, gin.H{
+		"stream": stream,
+		"video":  video,
+		"ingest": ingest,
+	})
+}
+
+// List returns the caller's live streams, newest first.
+func (h *LiveHandler) List(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	principal, ok := appctx.PrincipalFrom(ctx)
+	if !ok {
+		response.Unauthorized(c, "Authentication required")
+		return
+	}
+
+	streams, err := h.live.ListStreams(ctx, principal.UserID, parsePage(c))
+	if err != nil {
+		h.log.Error(ctx, "failed to list live streams", err, map[string]interface{}{
+			"user_id": principal.UserID,
+		})
+		response.InternalError(c, "Failed to retrieve live streams")
+		return
+	}
+	if streams == nil {
+		streams = []*domain.LiveStream{}
+	}
+	response.Success(c, http.StatusOK, streams)
+}
+
+// Get returns one of the caller's live streams. Anyone else's reads as not
+// found.
+func (h *LiveHandler) Get(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	principal, ok := appctx.PrincipalFrom(ctx)
+	if !ok {
+		response.Unauthorized(c, "Authentication required")
+		return
+	}
+
+	streamID, err := validator.ValidateUUID(c.Param("id"))
+	if err != nil {
+		response.ValidationError(c, "Invalid live stream ID format")
+		return
+	}
+
+	stream, err := h.live.GetStream(ctx, streamID, principal.UserID)
+	if err != nil {
+		if errors.Is(err, domain.ErrLiveStreamNotFound) {
+			response.NotFound(c, "Live stream not found")
+			return
+		}
+		h.log.Error(ctx, "failed to get live stream", err, map[string]interface{}{
+			"stream_id": streamID,
+		})
+		response.InternalError(c, "Failed to retrieve live stream")
+		return
+	}
+	response.Success(c, http.StatusOK, stream)
+}
+
+type markChapterRequest struct {
+	Title string `json:"title"`
+}
+
+// MarkChapter starts a chapter at the point the caller's broadcast has
+// reached. The chapters carry over to the video it is archived as.
+func (h *LiveHandler) MarkChapter(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	principal, ok := appctx.PrincipalFrom(ctx)
+	if !ok {
+		response.Unauthorized(c, "Authentication required")
+		return
+	}
+
+	streamID, err := validator.ValidateUUID(c.Param("id"))
+	if err != nil {
+		response.ValidationError(c, "Invalid live stream ID format")
+		return
+	}
+
+	var req markChapterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, "Invalid chapter request")
+		return
+	}
+
+	chapters, err := h.live.MarkChapter(ctx, streamID, principal.UserID, req.Title)
+	switch {
+	case err == nil:
+		response.Success(c, http.StatusCreated, chapters)
+	case errors.Is(err, domain.ErrLiveStreamNotFound):
+		response.NotFound(c, "Live stream not found")
+	case errors.Is(err, domain.ErrLiveStreamNotLive):
+		response.Error(c, http.StatusConflict, "LIVE_STREAM_NOT_LIVE", "Chapters can only be marked while the stream is on air")
+	case errors.Is(err, domain.ErrInvalidChapters):
+		response.ValidationError(c, err.Error())
+	default:
+		h.log.Error(ctx, "failed to mark chapter", err, map[string]interface{}{
+			"stream_id": streamID,
+		})
+		response.InternalError(c, "Failed to mark chapter")
+	}
+}
+
+// ingestAuthRequest is what MediaMTX posts to its HTTP authentication hook
+// for every connection: the credentials it was given, and what the
+// connection wants to do with which path.
+type ingestAuthRequest struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
+	IP       string `json:"ip"`
+	Action   string `json:"action"`
+	Path     string `json:"path"`
+	Protocol string `json:"protocol"`
+}
+
+// IngestAuth is the ingest server's authentication hook. A 200 lets the
+// connection through; anything else refuses it. It takes no bearer token:
+// the ingest server calls it, and it must only be reachable from there.
+func (h *LiveHandler) IngestAuth(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req ingestAuthRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, "Invalid ingest authentication request")
+		return
+	}
+
+	err := h.live.AuthorizeIngest(ctx, service.IngestAuthRequest{
+		User:     req.User,
+		Password: req.Password,
+		Action:   service.IngestAction(req.Action),
+		Path:     req.Path,
+	})
+	fields := map[string]interface{}{
+		"action":   req.Action,
+		"path":     req.Path,
+		"protocol": req.Protocol,
+		"ip":       req.IP,
+	}
+	switch {
+	case err == nil:
+		c.Status(http.StatusOK)
+	case errors.Is(err, domain.ErrLiveStreamKey):
+		// The ingest server asks without credentials first, and again with
+		// them once refused, so a refusal here is routine.
+		response.Unauthorized(c, "Ingest refused")
+	case errors.Is(err, domain.ErrLiveStreamEnded):
+		h.log.Warn(ctx, "push refused: live stream is not waiting for one", fields)
+		response.Error(c, http.StatusForbidden, "LIVE_STREAM_ENDED", "Live stream has already been used")
+	default:
+		h.log.Error(ctx, "failed to authorize ingest", err, fields)
+		response.InternalError(c, "Failed to authorize ingest")
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/Nuu-maan/video-streaming-service/internal/domain"
 	"github.com/Nuu-maan/video-streaming-service/internal/repository"
+	"github.com/Nuu-maan/video-streaming-service/internal/service"
 	"github.com/Nuu-maan/video-streaming-service/pkg/logger"
 	"github.com/Nuu-maan/video-streaming-service/pkg/validator"
 	"github.com/Nuu-maan/video-streaming-service/web/templates"
@@ -20,15 +21,18 @@ const pageListLimit = 50
 
 type PageHandler struct {
 	videoRepo repository.VideoRepository
+	captions  *service.CaptionService
 	log       *logger.Logger
 }
 
 func NewPageHandler(
 	videoRepo repository.VideoRepository,
+	captions *service.CaptionService,
 	log *logger.Logger,
 ) *PageHandler {
 	return &PageHandler{
 		videoRepo: videoRepo,
+		captions:  captions,
 		log:       log,
 	}
 }
@@ -83,7 +87,16 @@ func (h *PageHandler) VideoPlayerPage(c *gin.Context) {
 		return
 	}
 
-	component := templates.VideoPlayerPage(video)
+	// The player still plays without its captions, so failing to list them
+	// is only logged.
+	captions, err := h.captions.ListCaptions(ctx, video, false)
+	if err != nil {
+		h.log.Error(ctx, "failed to list captions for page", err, map[string]interface{}{
+			"video_id": videoID,
+		})
+	}
+
+	component := templates.VideoPlayerPage(video, captions)
 	component.Render(c.Request.Context(), c.Writer)
 }
 
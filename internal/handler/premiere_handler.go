@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/internal/repository"
+	"github.com/Nuu-maan/video-streaming-service/internal/service"
+	"github.com/Nuu-maan/video-streaming-service/pkg/logger"
+	"github.com/Nuu-maan/video-streaming-service/pkg/response"
+)
+
+// PremiereHandler lets owners schedule their ready videos to premiere, and
+// set their chapters.
+type PremiereHandler struct {
+	premieres *service.PremiereService
+	videoRepo repository.VideoRepository
+	log       *logger.Logger
+}
+
+func NewPremiereHandler(premieres *service.PremiereService, videoRepo repository.VideoRepository, log *logger.Logger) *PremiereHandler {
+	return &PremiereHandler{
+		premieres: premieres,
+		videoRepo: videoRepo,
+		log:       log,
+	}
+}
+
+// premiereForbidden is what a user who can see a video but does not own it
+// is told.
+const premiereForbidden = "You may only premiere and chapter your own videos"
+
+type schedulePremiereRequest struct {
+	PremiereAt time.Time `json:"premiere_at" binding:"required"`
+}
+
+// Schedule schedules the video's premiere, or moves one yet to start.
+func (h *PremiereHandler) Schedule(c *gin.Context) {
+	video, ok := loadOwnedVideo(c, h.videoRepo, h.log, premiereForbidden)
+	if !ok {
+		return
+	}
+
+	var req schedulePremiereRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, "premiere_at is required, as an RFC 3339 time")
+		return
+	}
+
+	if err := h.premieres.Schedule(c.Request.Context(), video, req.PremiereAt); err != nil {
+		h.respondError(c, video, err, "Failed to schedule the premiere")
+		return
+	}
+	response.Success(c, http.StatusOK, video)
+}
+
+// Cancel cancels the video's premiere if it is yet to start.
+func (h *PremiereHandler) Cancel(c *gin.Context) {
+	video, ok := loadOwnedVideo(c, h.videoRepo, h.log, premiereForbidden)
+	if !ok {
+		return
+	}
+
+	if err := h.premieres.Cancel(c.Request.Context(), video); err != nil {
+		h.respondError(c, video, err, "Failed to cancel the premiere")
+		return
+	}
+	response.Success(c, http.StatusOK, video)
+}
+
+type setChaptersRequest struct {
+	Chapters []domain.Chapter `json:"chapters"`
+}
+
+// SetChapters replaces the video's chapters. An empty list clears them.
+func (h *PremiereHandler) SetChapters(c *gin.Context) {
+	video, ok := loadOwnedVideo(c, h.videoRepo, h.log, premiereForbidden)
+	if !ok {
+		return
+	}
+
+	var req setChaptersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, "chapters must be a list of {start, title}")
+		return
+	}
+
+	if err := h.premieres.SetChapters(c.Request.Context(), video, req.Chapters); err != nil {
+		h.respondError(c, video, err, "Failed to set the chapters")
+		return
+	}
+	response.Success(c, http.StatusOK, video)
+}
+
+func (h *PremiereHandler) respondError(c *gin.Context, video *domain.Video, err error, message string) {
+	switch {
+	case errors.Is(err, domain.ErrVideoNotReady):
+		response.Error(c, http.StatusConflict, "VIDEO_NOT_READY", "Only a ready video can premiere or have its chapters set")
+	case errors.Is(err, domain.ErrPremiereStarted):
+		response.Error(c, http.StatusConflict, "PREMIERE_STARTED", "The premiere has already started")
+	case errors.Is(err, domain.ErrInvalidPremiere), errors.Is(err, domain.ErrInvalidChapters):
+		response.ValidationError(c, err.Error())
+	default:
+		h.log.Error(c.Request.Context(), "premiere request failed", err, map[string]interface{}{"video_id": video.ID})
+		response.InternalError(c, message)
+	}
+}
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/internal/service"
+	"github.com/Nuu-maan/video-streaming-service/pkg/appctx"
+	"github.com/Nuu-maan/video-streaming-service/pkg/logger"
+	"github.com/Nuu-maan/video-streaming-service/pkg/response"
+	"github.com/Nuu-maan/video-streaming-service/pkg/validator"
+)
+
+// ReprocessHandler lets admins queue videos of the library to be processed
+// again, and follow how far each batch has got.
+type ReprocessHandler struct {
+	reprocess *service.ReprocessService
+	log       *logger.Logger
+}
+
+func NewReprocessHandler(reprocess *service.ReprocessService, log *logger.Logger) *ReprocessHandler {
+	return &ReprocessHandler{
+		reprocess: reprocess,
+		log:       log,
+	}
+}
+
+// reprocessRequest is a domain.ReprocessFilter with the batch's limit and
+// rate, either 0 for the default, and whether it is only a dry run.
+type reprocessRequest struct {
+	domain.ReprocessFilter
+	Limit         int  `json:"limit"`
+	RatePerMinute int  `json:"rate_per_minute"`
+	DryRun        bool `json:"dry_run"`
+}
+
+// reprocessCandidate is a selected video, as much of it as an admin needs to
+// check the selection.
+type reprocessCandidate struct {
+	ID                 uuid.UUID          `json:"id"`
+	Title              string             `json:"title"`
+	Status             domain.VideoStatus `json:"status"`
+	AvailableQualities []string           `json:"available_qualities"`
+	CreatedAt          time.Time          `json:"created_at"`
+}
+
+type reprocessResponse struct {
+	DryRun  bool                 `json:"dry_run"`
+	Matched int                  `json:"matched"`
+	More    bool                 `json:"more"`
+	Videos  []reprocessCandidate `json:"videos"`
+	Batch   *reprocessBatchView  `json:"batch,omitempty"`
+}
+
+// reprocessBatchView is a batch with its size and when its last video is due
+// to start.
+type reprocessBatchView struct {
+	*domain.ReprocessBatch
+	Total      int       `json:"total"`
+	FinishesBy time.Time `json:"finishes_by"`
+}
+
+func newReprocessBatchView(batch *domain.ReprocessBatch) *reprocessBatchView {
+	return &reprocessBatchView{
+		ReprocessBatch: batch,
+		Total:          batch.Total(),
+		FinishesBy:     batch.Schedule(batch.Total() - 1),
+	}
+}
+
+// Reprocess selects the videos the filter in the body matches and queues
+// them to be processed again on the low queue, spread out at the batch's
+// rate. A ready video keeps playing as it was until its new output is
+// published. A dry run answers with the selection and queues nothing.
+func (h *ReprocessHandler) Reprocess(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req reprocessRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, "Invalid reprocess request; times are RFC 3339")
+		return
+	}
+
+	var requestedBy *uuid.UUID
+	if principal, ok := appctx.PrincipalFrom(ctx); ok {
+		requestedBy = &principal.UserID
+	}
+
+	result, err := h.reprocess.Reprocess(ctx, service.ReprocessRequest{
+		Filter:        req.ReprocessFilter,
+		Limit:         req.Limit,
+		RatePerMinute: req.RatePerMinute,
+		DryRun:        req.DryRun,
+		RequestedBy:   requestedBy,
+	})
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidReprocessFilter) {
+			response.ValidationError(c, err.Error())
+			return
+		}
+		fields := map[string]interface{}{}
+		message := "Failed to queue videos for reprocessing"
+		if result != nil && result.Batch != nil {
+			fields["batch_id"] = result.Batch.ID
+			message = fmt.Sprintf("Only part of reprocess batch %s was queued", result.Batch.ID)
+		}
+		h.log.Error(ctx, "failed to queue videos for reprocessing", err, fields)
+		response.InternalError(c, message)
+		return
+	}
+
+	body := reprocessResponse{
+		DryRun:  req.DryRun,
+		Matched: len(result.Videos),
+		More:    result.More,
+		Videos:  make([]reprocessCandidate, 0, len(result.Videos)),
+	}
+	for _, video := range result.Videos {
+		body.Videos = append(body.Videos, reprocessCandidate{
+			ID:                 video.ID,
+			Title:              video.Title,
+			Status:             video.Status,
+			AvailableQualities: video.AvailableQualities,
+			CreatedAt:          video.CreatedAt,
+		})
+	}
+	if result.Batch == nil {
+		response.Success(c, http.StatusOK, body)
+		return
+	}
+	body.Batch = newReprocessBatchView(result.Batch)
+	response.Success(c, http.StatusAccepted, body)
+}
+
+// GetBatch returns a reprocess batch and how far its videos are.
+func (h *ReprocessHandler) GetBatch(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	batchID, err := validator.ValidateUUID(c.Param("id"))
+	if err != nil {
+		response.ValidationError(c, "Invalid batch ID format")
+		return
+	}
+
+	batch, progress, err := h.reprocess.Progress(ctx, batchID)
+	if err != nil {
+		if errors.Is(err, domain.ErrReprocessBatchNotFound) {
+			response.NotFound(c, "Reprocess batch not found")
+			return
+		}
+		h.log.Error(ctx, "failed to get reprocess batch progress", err, map[string]interface{}{
+			"batch_id": batchID,
+		})
+		response.InternalError(c, "Failed to retrieve reprocess batch")
+		return
+	}
+
+	response.Success(c, http.StatusOK, gin.H{
+		"batch":    newReprocessBatchView(batch),
+		"progress": progress,
+		"done":     progress.Done(),
+	})
+}
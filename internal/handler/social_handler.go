@@ -2,7 +2,9 @@ package handler
 
 import (
 	"errors"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -173,6 +175,39 @@ func (h *SocialHandler) ListComments(c *gin.Context) {
 	response.SuccessWithList(c, comments, paginationMeta(total, page))
 }
 
+// ListChat returns a page of a video's premiere chat, from the from query
+// parameter's seconds into the premiere on, in the order it was posted.
+func (h *SocialHandler) ListChat(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	videoID, ok := h.pathUUID(c, "id", "video ID")
+	if !ok {
+		return
+	}
+	from := 0.0
+	if raw := c.Query("from"); raw != "" {
+		var err error
+		if from, err = strconv.ParseFloat(raw, 64); err != nil || from < 0 || math.IsInf(from, 0) {
+			response.ValidationError(c, "from must be a number of seconds, not negative")
+			return
+		}
+	}
+	page := parsePage(c)
+
+	comments, total, err := h.social.ListChat(ctx, videoID, from, page)
+	if err != nil {
+		if errors.Is(err, domain.ErrVideoNotFound) {
+			response.NotFound(c, "Video not found")
+			return
+		}
+		h.log.Error(ctx, "failed to list chat", err, map[string]interface{}{"video_id": videoID})
+		response.InternalError(c, "Failed to retrieve chat")
+		return
+	}
+
+	response.SuccessWithList(c, comments, paginationMeta(total, page))
+}
+
 // ListReplies returns a page of a comment's replies, oldest first.
 func (h *SocialHandler) ListReplies(c *gin.Context) {
 	ctx := c.Request.Context()
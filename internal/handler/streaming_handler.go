@@ -6,22 +6,31 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"path"
 	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Nuu-maan/video-streaming-service/internal/cache"
+	"github.com/Nuu-maan/video-streaming-service/internal/config"
 	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/internal/metrics"
 	"github.com/Nuu-maan/video-streaming-service/internal/repository"
+	"github.com/Nuu-maan/video-streaming-service/internal/service"
 	"github.com/Nuu-maan/video-streaming-service/internal/storage"
+	"github.com/Nuu-maan/video-streaming-service/pkg/appctx"
 	"github.com/Nuu-maan/video-streaming-service/pkg/logger"
+	"github.com/Nuu-maan/video-streaming-service/pkg/playback"
 	"github.com/Nuu-maan/video-streaming-service/pkg/response"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
-// playlistCacheTTL is how long a rendered HLS playlist stays cached. Playlists
-// are immutable once a video is ready, so this is bounded only by the desire to
+// playlistCacheTTL is how long a rendered HLS playlist stays cached. Media
+// playlists are immutable once a video is ready, and the worker evicts the
+// master playlist when it adds a dub, so this is bounded only by the desire to
 // eventually reclaim the space.
 const playlistCacheTTL = time.Hour
 
@@ -29,47 +38,528 @@ type StreamingHandler struct {
 	videoRepo repository.VideoRepository
 	cache     *cache.CacheService
 	store     storage.Store
+	signer    *playback.Signer
 	log       *logger.Logger
+	cfg       *config.Config
 }
 
 func NewStreamingHandler(
 	videoRepo repository.VideoRepository,
 	cacheService *cache.CacheService,
 	store storage.Store,
+	signer *playback.Signer,
 	log *logger.Logger,
+	cfg *config.Config,
 ) *StreamingHandler {
 	return &StreamingHandler{
 		videoRepo: videoRepo,
 		cache:     cacheService,
 		store:     store,
+		signer:    signer,
 		log:       log,
+		cfg:       cfg,
 	}
 }
 
-// transcodedKey addresses a file under a video's transcoded output in the
-// store. The worker writes under this same layout (the local store maps the
-// "transcoded" area onto StorageConfig.TranscodedPath), so writer and reader
-// agree by construction. They used to disagree — the worker wrote to
-// TranscodedPath while this handler read a hardcoded "processed" directory
-// nothing ever created, so every playlist and segment request 404'd.
-func transcodedKey(videoID uuid.UUID, parts ...string) string {
-	return storage.Key(append([]string{"transcoded", videoID.String()}, parts...)...)
+// transcodedKey addresses a file under revision of a video's transcoded
+// output in the store. The worker writes under this same layout (the local
+// store maps the "transcoded" area onto StorageConfig.TranscodedPath), so
+// writer and reader agree by construction. They used to disagree — the worker
+// wrote to TranscodedPath while this handler read a hardcoded "processed"
+// directory nothing ever created, so every playlist and segment request
+// 404'd.
+func transcodedKey(videoID uuid.UUID, revision int, parts ...string) string {
+	return service.OutputKey(videoID, revision, parts...)
 }
 
+// revisionQuery is the query parameter that names the output revision a
+// media playlist or segment belongs to.
+//
+// A video processed again is published as a new revision beside the old one,
+// under the same URLs; see service.OutputKey. The master playlist, the DASH
+// manifest, and the MP4s and trickplay are where a player starts, and are
+// served from the revision the video has now. Every URI in the playlists of
+// a revision after 0 carries it as ?r=<n>, so a player keeps fetching the
+// revision it started on until that is deleted, and a segment, cached for a
+// year as immutable, is never mistaken for the segment of the same name in
+// another revision. A URI without it is one of revision 0's, which listed
+// none.
+const revisionQuery = "r"
+
+// tokenQuery is the query parameter that carries a playback token.
+//
+// The master playlist and the DASH manifest are authorized as every other
+// route is, against the video's visibility and whoever is asking, and sign a
+// token opening the video to them for as long as it plays plus
+// AuthConfig.PlaybackTokenTTL. Every URI they list carries it, as does every
+// URI of a media playlist fetched with it, so a player that only follows the
+// URIs it is given needs no bearer token, which a native HLS player cannot
+// attach, and its media playlists and segments are authorized by checking
+// the token alone, without looking the video up. A request without a valid
+// token is authorized as it was before there were tokens.
+const tokenQuery = "token"
+
+// parseRevision reads the revision a media playlist or segment request
+// names. On a bad one the response is written and ok is false.
+func parseRevision(c *gin.Context) (revision int, ok bool) {
+	value, named := c.GetQuery(revisionQuery)
+	if !named {
+		return 0, true
+	}
+	revision, err := strconv.Atoi(value)
+	if err != nil || revision < 0 || strconv.Itoa(revision) != value {
+		response.ValidationError(c, "Invalid revision")
+		return 0, false
+	}
+	return revision, true
+}
+
+// playbackQuery is the query string every URI of a playlist of revision
+// carries: the revision unless it is 0, and token unless it is empty.
+func playbackQuery(revision int, token string) string {
+	query := url.Values{}
+	if revision > 0 {
+		query.Set(revisionQuery, strconv.Itoa(revision))
+	}
+	if token != "" {
+		query.Set(tokenQuery, token)
+	}
+	return query.Encode()
+}
+
+// tagPlaylistURIs adds query to every URI of an HLS playlist: the lines that
+// are URIs, and the URI attribute of any tag. An empty query leaves it as it
+// was written.
+func tagPlaylistURIs(playlist, query string) string {
+	if query == "" {
+		return playlist
+	}
+	lines := strings.Split(playlist, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+		case !strings.HasPrefix(trimmed, "#"):
+			lines[i] = withQuery(trimmed, query)
+		default:
+			lines[i] = tagAttribute(line, "URI", query)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// tagManifestURIs adds query to the segment templates of a DASH manifest. An
+// empty query leaves it as it was written.
+func tagManifestURIs(manifest, query string) string {
+	if query == "" {
+		return manifest
+	}
+	// The templates are XML attributes, in which an ampersand is escaped.
+	query = strings.ReplaceAll(query, "&", "&amp;")
+	return tagAttribute(tagAttribute(manifest, "initialization", query), "media", query)
+}
+
+// tagAttribute adds query to the value of every name="..." attribute in s.
+func tagAttribute(s, name, query string) string {
+	prefix := name + `="`
+	var b strings.Builder
+	for {
+		at := strings.Index(s, prefix)
+		// An attribute, not the end of a longer name.
+		for at > 0 && isAttributeNameByte(s[at-1]) {
+			next := strings.Index(s[at+1:], prefix)
+			if next < 0 {
+				at = -1
+				break
+			}
+			at += 1 + next
+		}
+		if at < 0 {
+			break
+		}
+		start := at + len(prefix)
+		end := strings.IndexByte(s[start:], '"')
+		if end < 0 {
+			break
+		}
+		end += start
+		b.WriteString(s[:start])
+		b.WriteString(withQuery(s[start:end], query))
+		s = s[end:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+func isAttributeNameByte(c byte) bool {
+	return c == '-' || c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9'
+}
+
+// withQuery adds query to uri's query string.
+func withQuery(uri, query string) string {
+	sep := "?"
+	if strings.Contains(uri, "?") {
+		sep = "&"
+	}
+	return uri + sep + query
+}
+
+// isLivePlaylist reports whether a media playlist is still being written by
+// a broadcast. One that is over ends with EXT-X-ENDLIST, as every video's
+// does.
+func isLivePlaylist(playlist string) bool {
+	return !strings.Contains(playlist, "#EXT-X-ENDLIST")
+}
+
+// mediaPlaylist is a media playlist taken apart: the tags that describe it,
+// its segments, each the tags belonging to it followed by its URI, and the
+// tags after the last segment, where EXT-X-ENDLIST is.
+type mediaPlaylist struct {
+	header   []string
+	segments [][]string
+	trailer  []string
+}
+
+func parseMediaPlaylist(playlist string) mediaPlaylist {
+	var p mediaPlaylist
+	var entry []string
+	for _, line := range strings.Split(strings.TrimRight(playlist, "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+		case !strings.HasPrefix(trimmed, "#"):
+			p.segments = append(p.segments, append(entry, trimmed))
+			entry = nil
+		case len(p.segments) == 0 && entry == nil && !startsSegment(trimmed):
+			p.header = append(p.header, trimmed)
+		default:
+			entry = append(entry, trimmed)
+		}
+	}
+	p.trailer = entry
+	return p
+}
+
+func (p mediaPlaylist) String() string {
+	out := make([]string, 0, len(p.header)+3*len(p.segments)+len(p.trailer))
+	out = append(out, p.header...)
+	for _, segment := range p.segments {
+		out = append(out, segment...)
+	}
+	out = append(out, p.trailer...)
+	return strings.Join(out, "\n") + "\n"
+}
+
+// withoutFirst cuts the oldest n segments from a live playlist. The media
+// sequence, and the discontinuity sequence if any were cut, count what was
+// cut, and the playlist stops claiming to be an event, which a player would
+// take to mean nothing is ever removed.
+func (p mediaPlaylist) withoutFirst(n int) mediaPlaylist {
+	n = min(max(n, 0), len(p.segments))
+	cut := p.segments[:n]
+	discontinuities := 0
+	for _, segment := range cut {
+		for _, line := range segment {
+			if line == "#EXT-X-DISCONTINUITY" {
+				discontinuities++
+			}
+		}
+	}
+
+	header := make([]string, 0, len(p.header)+1)
+	sawDiscontinuitySequence := false
+	for _, line := range p.header {
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-PLAYLIST-TYPE:"):
+			continue
+		case strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"):
+			line = "#EXT-X-MEDIA-SEQUENCE:" + strconv.Itoa(headerNumber(line)+n)
+		case strings.HasPrefix(line, "#EXT-X-DISCONTINUITY-SEQUENCE:"):
+			line = "#EXT-X-DISCONTINUITY-SEQUENCE:" + strconv.Itoa(headerNumber(line)+discontinuities)
+			sawDiscontinuitySequence = true
+		}
+		header = append(header, line)
+	}
+	if discontinuities > 0 && !sawDiscontinuitySequence {
+		header = append(header, "#EXT-X-DISCONTINUITY-SEQUENCE:"+strconv.Itoa(discontinuities))
+	}
+	return mediaPlaylist{header: header, segments: p.segments[n:], trailer: p.trailer}
+}
+
+// segmentDuration reads how long a segment plays from its EXTINF, in
+// seconds.
+func segmentDuration(segment []string) float64 {
+	for _, line := range segment {
+		if value, ok := strings.CutPrefix(line, "#EXTINF:"); ok {
+			value, _, _ = strings.Cut(value, ",")
+			seconds, _ := strconv.ParseFloat(value, 64)
+			return seconds
+		}
+	}
+	return 0
+}
+
+// liveWindow cuts a live media playlist down to its newest n segments. The
+// worker writes a broadcast's playlists as events, listing every segment
+// from the start, which is what the video keeps once the broadcast ends;
+// without a DVR window a viewer of the broadcast is given a window of it
+// near the live edge instead.
+func liveWindow(playlist string, n int) string {
+	if n < 1 {
+		return playlist
+	}
+	p := parseMediaPlaylist(playlist)
+	return p.withoutFirst(len(p.segments) - n).String()
+}
+
+// dvrWindow cuts a live media playlist down to the newest window of it, and
+// never to fewer than n segments, so a viewer can rewind that far behind the
+// live edge and no further. The window slides, so the playlist is no event.
+func dvrWindow(playlist string, window time.Duration, n int) string {
+	p := parseMediaPlaylist(playlist)
+	kept, seconds := 0, 0.0
+	for i := len(p.segments) - 1; i >= 0; i-- {
+		seconds += segmentDuration(p.segments[i])
+		if kept >= n && seconds > window.Seconds() {
+			break
+		}
+		kept++
+	}
+	return p.withoutFirst(len(p.segments) - kept).String()
+}
+
+// playoutWindow cuts a sealed media playlist down to the segments that have
+// played in full elapsed into a premiere of it, and leaves it open as an
+// EXT-X-PLAYLIST-TYPE:EVENT playlist: to a player it is a broadcast that
+// started when the premiere did, which can be rewound to its start but not
+// got ahead of. Once elapsed has covered every segment it is the playlist
+// as it was.
+func playoutWindow(playlist string, elapsed time.Duration) string {
+	p := parseMediaPlaylist(playlist)
+	played, seconds := 0, 0.0
+	for _, segment := range p.segments {
+		seconds += segmentDuration(segment)
+		if seconds > elapsed.Seconds() {
+			break
+		}
+		played++
+	}
+	if played == len(p.segments) {
+		return playlist
+	}
+
+	header := make([]string, 0, len(p.header)+1)
+	for _, line := range p.header {
+		if !strings.HasPrefix(line, "#EXT-X-PLAYLIST-TYPE:") {
+			header = append(header, line)
+		}
+	}
+	header = append(header, "#EXT-X-PLAYLIST-TYPE:EVENT")
+	return mediaPlaylist{header: header, segments: p.segments[:played]}.String()
+}
+
+// listsURI reports whether a playlist lists name: as a segment, or as the
+// URI of a tag, such as the initialization section of EXT-X-MAP.
+func listsURI(playlist, name string) bool {
+	for _, line := range strings.Split(playlist, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "#") {
+			uri, _, _ := strings.Cut(line, "?")
+			if uri == name {
+				return true
+			}
+		} else if strings.Contains(line, `URI="`+name+`"`) || strings.Contains(line, `URI="`+name+`?`) {
+			return true
+		}
+	}
+	return false
+}
+
+// startsSegment reports whether a tag belongs to the segment that follows it
+// rather than to the playlist.
+func startsSegment(tag string) bool {
+	for _, prefix := range []string{"#EXTINF:", "#EXT-X-PROGRAM-DATE-TIME:", "#EXT-X-DISCONTINUITY", "#EXT-X-BYTERANGE:", "#EXT-X-KEY:", "#EXT-X-MAP:"} {
+		if strings.HasPrefix(tag, prefix) && !strings.HasPrefix(tag, "#EXT-X-DISCONTINUITY-SEQUENCE:") {
+			return true
+		}
+	}
+	return false
+}
+
+// headerNumber reads the number of a #TAG:n line, or 0.
+func headerNumber(line string) int {
+	_, value, _ := strings.Cut(line, ":")
+	n, _ := strconv.Atoi(value)
+	return n
+}
+
+// liveEdge cuts a broadcast's media playlist down to what a viewer of it is
+// given: the DVR window, the whole broadcast as an event when the window is
+// at least as long as a broadcast may be, or without one the newest few
+// segments.
+func (h *StreamingHandler) liveEdge(playlist string) string {
+	live := h.cfg.Live
+	switch {
+	case live.DVRWindow <= 0:
+		return liveWindow(playlist, live.PlaylistSegments)
+	case live.DVRWindow >= live.MaxDuration:
+		// The worker writes it as an event already.
+		return playlist
+	default:
+		return dvrWindow(playlist, live.DVRWindow, live.PlaylistSegments)
+	}
+}
+
+// premiere reports where video is in its premiere, for whoever is asking
+// and how far into it it is. Its owner is not kept waiting for it, nor
+// behind it.
+func (h *StreamingHandler) premiere(ctx context.Context, video *domain.Video) (domain.PremiereState, time.Duration) {
+	if principal, ok := appctx.PrincipalFrom(ctx); ok && video.IsOwnedBy(principal.UserID) {
+		return domain.PremiereNone, 0
+	}
+	return video.Premiere(time.Now())
+}
+
+// refusePremiere refuses whoever is asking a video whose premiere they are
+// waiting for and, unless playout is true, one being premiered to them,
+// which only plays out through its HLS playlists: a download, a DASH
+// manifest or the seek-bar previews would show what is yet to come. If it
+// refuses, the response is written and it returns true.
+func (h *StreamingHandler) refusePremiere(c *gin.Context, video *domain.Video, playout bool) bool {
+	switch state, _ := h.premiere(c.Request.Context(), video); {
+	case state == domain.PremiereScheduled:
+		response.Error(c, http.StatusForbidden, "PREMIERE_NOT_STARTED",
+			"Video premieres at "+video.PremiereAt.UTC().Format(time.RFC3339))
+		return true
+	case state == domain.PremiereLive && !playout:
+		response.Error(c, http.StatusForbidden, "PREMIERE_IN_PROGRESS",
+			"Video is premiering, and only plays over HLS until the premiere is over")
+		return true
+	}
+	return false
+}
+
+// playoutStart is when the premiere video is being played out to whoever is
+// asking began, or zero when it is not being played out to them.
+func (h *StreamingHandler) playoutStart(ctx context.Context, video *domain.Video) time.Time {
+	if state, _ := h.premiere(ctx, video); state == domain.PremiereLive {
+		return *video.PremiereAt
+	}
+	return time.Time{}
+}
+
+// signPlayback signs a token opening video to whoever is asking until the
+// token TTL after it would have finished playing from now, so a viewer who
+// starts it near the end of the TTL can still watch to the end. A broadcast
+// has no length yet, and may last as long as the longest one does. A token
+// signed during a premiere carries when it started, and plays the video out
+// no further than the premiere has got; see playoutWindow.
+func (h *StreamingHandler) signPlayback(ctx context.Context, video *domain.Video) string {
+	viewer := uuid.Nil
+	if principal, ok := appctx.PrincipalFrom(ctx); ok {
+		viewer = principal.UserID
+	}
+	length := time.Duration(video.Duration) * time.Second
+	if video.Status == domain.VideoStatusLive {
+		length = h.cfg.Live.MaxDuration
+	}
+	expires := time.Now().Add(length + h.cfg.Auth.PlaybackTokenTTL)
+	return h.signer.SignPlayout(video.ID, viewer, expires, h.playoutStart(ctx, video))
+}
+
+// playbackToken returns the request's playback token when it opens videoID,
+// and what it says. ok is false when there is none, or it is not good for
+// this request, and the request is authorized as it would be without one.
+func (h *StreamingHandler) playbackToken(c *gin.Context, videoID uuid.UUID) (token string, claims playback.Claims, ok bool) {
+	token = c.Query(tokenQuery)
+	if token == "" {
+		return "", playback.Claims{}, false
+	}
+	claims, err := h.signer.Verify(token, videoID)
+	if err != nil {
+		return "", playback.Claims{}, false
+	}
+	// A token needs no bearer token beside it, but it is not taken from a
+	// viewer signed in as someone other than the one it was issued to.
+	if principal, signedIn := appctx.PrincipalFrom(c.Request.Context()); signedIn &&
+		claims.Viewer != uuid.Nil && claims.Viewer != principal.UserID {
+		return "", playback.Claims{}, false
+	}
+	return token, claims, true
+}
+
+// playedOut reports whether a premiere elapsed into the media playlist of
+// quality in revision has reached segment: whether the playlist as played
+// out so far lists it.
+func (h *StreamingHandler) playedOut(ctx context.Context, videoID uuid.UUID, revision int, quality, segment string, elapsed time.Duration) bool {
+	content, err := h.cache.Get(ctx, cache.MediaPlaylistKey(videoID, revision, quality))
+	if err != nil || len(content) == 0 {
+		if content, err = h.readObject(ctx, transcodedKey(videoID, revision, "hls", quality, "playlist.m3u8")); err != nil {
+			return false
+		}
+	}
+	return listsURI(playoutWindow(string(content), elapsed), segment)
+}
+
+// playableVideo looks up a video whose media playlists or segments are asked
+// for without a playback token, and checks they may be served. If not, the
+// response is written and ok is false.
+func (h *StreamingHandler) playableVideo(c *gin.Context, videoID uuid.UUID) (video *domain.Video, ok bool) {
+	video, err := h.videoRepo.GetByID(c.Request.Context(), videoID)
+	if err != nil {
+		if errors.Is(err, domain.ErrVideoNotFound) {
+			response.NotFound(c, "Video not found")
+			return nil, false
+		}
+		response.InternalError(c, "Failed to retrieve video")
+		return nil, false
+	}
+
+	if !canViewVideo(c.Request.Context(), video) {
+		response.NotFound(c, "Video not found")
+		return nil, false
+	}
+
+	if !video.HLSReady {
+		response.Error(c, http.StatusNotFound, "HLS_NOT_READY", "HLS streaming not available")
+		return nil, false
+	}
+
+	if h.refusePremiere(c, video, true) {
+		return nil, false
+	}
+	return video, true
+}
+
+// Manifest content types.
+const (
+	hlsPlaylistContentType  = "application/vnd.apple.mpegurl"
+	dashManifestContentType = "application/dash+xml"
+)
+
 // servePlaylist returns a cached playlist, falling back to reading it from the
 // store and populating the cache. Both playlist endpoints had their own copy of
-// this.
+// this; the DASH manifest is served the same way, under its own content type.
+//
+// A media playlist never changes and may sit in the in-process tier too. The
+// master playlist and DASH manifest gain an entry whenever a dub is added, and
+// the worker can only evict them from Redis, so they pass mutable and stay out
+// of it. The exception is a media playlist of a broadcast, which gains a
+// segment every few seconds until it ends and is not cached at all.
 //
 // A cache failure is not fatal: the stored file is the source of truth, so a
 // Redis outage degrades latency rather than availability.
-func (h *StreamingHandler) servePlaylist(c *gin.Context, cacheKey, key string, fields map[string]interface{}) {
+//
+// What is cached is the file as stored. A non-nil transform rewrites it for
+// this request alone on its way out.
+func (h *StreamingHandler) servePlaylist(c *gin.Context, cacheKey, key, contentType string, mutable bool, transform func(string) string, fields map[string]interface{}) {
 	ctx := c.Request.Context()
 
 	cached, err := h.cache.Get(ctx, cacheKey)
 	if err != nil {
 		h.log.Warn(ctx, "playlist cache unavailable; reading from storage", fields)
 	} else if len(cached) > 0 {
-		h.servePlaylistContent(c, string(cached))
+		h.servePlaylistContent(c, contentType, applyTransform(transform, string(cached)))
 		return
 	}
 
@@ -80,14 +570,23 @@ func (h *StreamingHandler) servePlaylist(c *gin.Context, cacheKey, key string, f
 		return
 	}
 
-	if err := h.cache.Set(ctx, cacheKey, content, cache.CacheOptions{
-		TTL:        playlistCacheTTL,
-		LocalCache: true,
-	}); err != nil {
-		h.log.Warn(ctx, "could not cache playlist", fields)
+	if mutable || !isLivePlaylist(string(content)) {
+		if err := h.cache.Set(ctx, cacheKey, content, cache.CacheOptions{
+			TTL:        playlistCacheTTL,
+			LocalCache: !mutable,
+		}); err != nil {
+			h.log.Warn(ctx, "could not cache playlist", fields)
+		}
 	}
 
-	h.servePlaylistContent(c, string(content))
+	h.servePlaylistContent(c, contentType, applyTransform(transform, string(content)))
+}
+
+func applyTransform(transform func(string) string, content string) string {
+	if transform == nil {
+		return content
+	}
+	return transform(content)
 }
 
 // readObject slurps a whole object from the store. Only suitable for
@@ -106,6 +605,9 @@ func (h *StreamingHandler) readObject(ctx context.Context, key string) ([]byte,
 	return content, nil
 }
 
+// ServeMasterPlaylist serves a video's HLS master playlist with the variants
+// the player said it can decode: H.264 always, and HEVC or AV1 when the
+// codecs query parameter lists them; see filterVariants.
 func (h *StreamingHandler) ServeMasterPlaylist(c *gin.Context) {
 	ctx := c.Request.Context()
 
@@ -115,6 +617,12 @@ func (h *StreamingHandler) ServeMasterPlaylist(c *gin.Context) {
 		return
 	}
 
+	codecs, err := parseCodecHints(c.Query("codecs"))
+	if err != nil {
+		response.ValidationError(c, err.Error())
+		return
+	}
+
 	video, err := h.videoRepo.GetByID(ctx, videoID)
 	if err != nil {
 		if errors.Is(err, domain.ErrVideoNotFound) {
@@ -138,16 +646,28 @@ func (h *StreamingHandler) ServeMasterPlaylist(c *gin.Context) {
 		return
 	}
 
-	masterKey := transcodedKey(videoID, "hls", "master.m3u8")
+	if h.refusePremiere(c, video, true) {
+		return
+	}
+
+	revision := video.OutputRevision
+	masterKey := transcodedKey(videoID, revision, "hls", "master.m3u8")
+	query := playbackQuery(revision, h.signPlayback(ctx, video))
 
 	h.servePlaylist(c,
-		fmt.Sprintf("playlist:%s:master", videoID),
+		cache.PlaylistKey(videoID, cache.MasterPlaylist),
 		masterKey,
+		hlsPlaylistContentType,
+		true,
+		func(master string) string { return tagPlaylistURIs(filterVariants(master, codecs), query) },
 		map[string]interface{}{"video_id": videoID, "key": masterKey},
 	)
 }
 
-func (h *StreamingHandler) ServeQualityPlaylist(c *gin.Context) {
+// ServeDASHManifest serves the MPEG-DASH manifest of a CMAF-packaged video.
+// Its segment URLs are relative, and resolve under /dash to the same segments
+// the HLS playlists list; see ServeSegment.
+func (h *StreamingHandler) ServeDASHManifest(c *gin.Context) {
 	ctx := c.Request.Context()
 
 	videoID, err := uuid.Parse(c.Param("id"))
@@ -156,18 +676,15 @@ func (h *StreamingHandler) ServeQualityPlaylist(c *gin.Context) {
 		return
 	}
 
-	quality := c.Param("quality")
-	if !isValidQuality(quality) {
-		response.ValidationError(c, "Invalid quality parameter")
-		return
-	}
-
 	video, err := h.videoRepo.GetByID(ctx, videoID)
 	if err != nil {
 		if errors.Is(err, domain.ErrVideoNotFound) {
 			response.NotFound(c, "Video not found")
 			return
 		}
+		h.log.Error(ctx, "failed to get video", err, map[string]interface{}{
+			"video_id": videoID,
+		})
 		response.InternalError(c, "Failed to retrieve video")
 		return
 	}
@@ -177,20 +694,93 @@ func (h *StreamingHandler) ServeQualityPlaylist(c *gin.Context) {
 		return
 	}
 
-	if !video.HLSReady {
-		response.Error(c, http.StatusNotFound, "HLS_NOT_READY", "HLS streaming not available")
+	if !video.HasDASH() {
+		response.Error(c, http.StatusNotFound, "DASH_NOT_AVAILABLE", "DASH streaming not available for this video")
+		return
+	}
+
+	if h.refusePremiere(c, video, false) {
+		return
+	}
+
+	revision := video.OutputRevision
+	manifestKey := transcodedKey(videoID, revision, "hls", "manifest.mpd")
+	query := playbackQuery(revision, h.signPlayback(ctx, video))
+
+	h.servePlaylist(c,
+		cache.PlaylistKey(videoID, cache.DASHManifest),
+		manifestKey,
+		dashManifestContentType,
+		true,
+		func(manifest string) string { return tagManifestURIs(manifest, query) },
+		map[string]interface{}{"video_id": videoID, "key": manifestKey},
+	)
+}
+
+// ServeQualityPlaylist serves one variant's media playlist, with the playback
+// token it was asked for with on every URI. Asked for without one, it is
+// authorized against the video and signs one; see tokenQuery.
+// A broadcast's is cut to what a viewer may rewind; see liveEdge. A
+// premiere's is cut to what has been played out; see playoutWindow.
+func (h *StreamingHandler) ServeQualityPlaylist(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	videoID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.ValidationError(c, "Invalid video ID")
 		return
 	}
 
-	playlistKey := transcodedKey(videoID, "hls", quality, "playlist.m3u8")
+	quality := c.Param("quality")
+	if !isValidQuality(quality) {
+		response.ValidationError(c, "Invalid quality parameter")
+		return
+	}
+
+	revision, ok := parseRevision(c)
+	if !ok {
+		return
+	}
+
+	token, claims, signed := h.playbackToken(c, videoID)
+	playout := claims.Playout
+	if !signed {
+		video, ok := h.playableVideo(c, videoID)
+		if !ok {
+			return
+		}
+		if revision > video.OutputRevision {
+			response.NotFound(c, "Revision not found")
+			return
+		}
+		token = h.signPlayback(ctx, video)
+		playout = h.playoutStart(ctx, video)
+	}
+	query := playbackQuery(revision, token)
+	playlistKey := transcodedKey(videoID, revision, "hls", quality, "playlist.m3u8")
 
 	h.servePlaylist(c,
-		fmt.Sprintf("playlist:%s:%s", videoID, quality),
+		cache.MediaPlaylistKey(videoID, revision, quality),
 		playlistKey,
-		map[string]interface{}{"video_id": videoID, "quality": quality, "key": playlistKey},
+		hlsPlaylistContentType,
+		false,
+		func(playlist string) string {
+			switch {
+			case !playout.IsZero():
+				playlist = playoutWindow(playlist, time.Since(playout))
+			case isLivePlaylist(playlist):
+				playlist = h.liveEdge(playlist)
+			}
+			return tagPlaylistURIs(playlist, query)
+		},
+		map[string]interface{}{"video_id": videoID, "quality": quality, "revision": revision, "key": playlistKey},
 	)
 }
 
+// ServeSegment serves one segment of a variant. It answers under /dash as
+// well as /hls: a CMAF video's DASH manifest lists the same files. With a
+// playback token it is served on the token alone; see tokenQuery. During a
+// premiere only the segments played out so far are, to anyone but its owner.
 func (h *StreamingHandler) ServeSegment(c *gin.Context) {
 	ctx := c.Request.Context()
 
@@ -212,28 +802,38 @@ func (h *StreamingHandler) ServeSegment(c *gin.Context) {
 		return
 	}
 
-	video, err := h.videoRepo.GetByID(ctx, videoID)
-	if err != nil {
-		if errors.Is(err, domain.ErrVideoNotFound) {
-			response.NotFound(c, "Video not found")
-			return
-		}
-		response.InternalError(c, "Failed to retrieve video")
+	revision, ok := parseRevision(c)
+	if !ok {
 		return
 	}
 
-	if !canViewVideo(ctx, video) {
-		response.NotFound(c, "Video not found")
-		return
+	// A segment fetched with a token is as private as the video may be, and
+	// is only the token holder's to keep; one fetched without keeps the
+	// shared caching it always had.
+	cacheControl := "private, max-age=31536000, immutable"
+	_, claims, signed := h.playbackToken(c, videoID)
+	playout := claims.Playout
+	if !signed {
+		video, ok := h.playableVideo(c, videoID)
+		if !ok {
+			return
+		}
+		if revision > video.OutputRevision {
+			response.NotFound(c, "Revision not found")
+			return
+		}
+		cacheControl = "public, max-age=31536000, immutable"
+		playout = h.playoutStart(ctx, video)
 	}
+	segmentKey := transcodedKey(videoID, revision, "hls", quality, segment)
 
-	if !video.HLSReady {
-		response.Error(c, http.StatusNotFound, "HLS_NOT_READY", "HLS streaming not available")
+	// A segment the premiere has yet to reach does not exist yet, as far as
+	// anyone watching it is concerned.
+	if !playout.IsZero() && !h.playedOut(ctx, videoID, revision, quality, segment, time.Since(playout)) {
+		response.Error(c, http.StatusNotFound, "SEGMENT_NOT_FOUND", "Segment file not found")
 		return
 	}
 
-	segmentKey := transcodedKey(videoID, "hls", quality, segment)
-
 	fileInfo, err := h.store.Stat(ctx, segmentKey)
 	if err != nil {
 		h.log.Error(ctx, "segment not found", err, map[string]interface{}{
@@ -246,6 +846,10 @@ func (h *StreamingHandler) ServeSegment(c *gin.Context) {
 		return
 	}
 
+	if h.accelRedirect(c, segmentKey, segmentContentType(segment), cacheControl, quality, fileInfo) {
+		return
+	}
+
 	obj, err := h.store.Open(ctx, segmentKey)
 	if err != nil {
 		h.log.Error(ctx, "failed to open segment", err, map[string]interface{}{
@@ -259,12 +863,13 @@ func (h *StreamingHandler) ServeSegment(c *gin.Context) {
 	}
 	defer obj.Close()
 
-	c.Header("Content-Type", "video/MP2T")
-	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	c.Header("Content-Type", segmentContentType(segment))
+	c.Header("Cache-Control", cacheControl)
 	c.Header("Accept-Ranges", "bytes")
 	c.Header("Content-Length", fmt.Sprintf("%d", fileInfo.Size))
 
 	http.ServeContent(c.Writer, c.Request, segment, fileInfo.ModTime, obj)
+	recordStreamed(c, quality)
 }
 
 func (h *StreamingHandler) ServeMP4Fallback(c *gin.Context) {
@@ -302,6 +907,10 @@ func (h *StreamingHandler) ServeMP4Fallback(c *gin.Context) {
 		return
 	}
 
+	if h.refusePremiere(c, video, false) {
+		return
+	}
+
 	qualityFound := false
 	for _, q := range video.AvailableQualities {
 		if q == quality {
@@ -314,7 +923,7 @@ func (h *StreamingHandler) ServeMP4Fallback(c *gin.Context) {
 		return
 	}
 
-	mp4Key := transcodedKey(videoID, quality+".mp4")
+	mp4Key := transcodedKey(videoID, video.OutputRevision, quality+".mp4")
 
 	fileInfo, err := h.store.Stat(ctx, mp4Key)
 	if err != nil {
@@ -327,6 +936,10 @@ func (h *StreamingHandler) ServeMP4Fallback(c *gin.Context) {
 		return
 	}
 
+	if h.accelRedirect(c, mp4Key, "video/mp4", "public, max-age=3600", quality, fileInfo) {
+		return
+	}
+
 	obj, err := h.store.Open(ctx, mp4Key)
 	if err != nil {
 		h.log.Error(ctx, "failed to open MP4 file", err, map[string]interface{}{
@@ -347,9 +960,78 @@ func (h *StreamingHandler) ServeMP4Fallback(c *gin.Context) {
 	// ServeContent needs only a ReadSeeker, so both backends can honour Range
 	// requests; a plain io.Copy here would break seeking in every player.
 	http.ServeContent(c.Writer, c.Request, quality+".mp4", fileInfo.ModTime, obj)
+	recordStreamed(c, quality)
 }
 
-// ServeThumbnail serves a video's poster image.
+// accelRedirect hands the object at key, which info describes, to nginx to
+// send when StorageConfig.AccelRedirectPrefix is set, and reports whether it
+// did. The response is empty but for the headers: X-Accel-Redirect names the
+// key under the prefix, an internal location nginx maps onto the uploads
+// volume or the MinIO upstream (see deploy/nginx/api.conf), so the access
+// check already made is still the only way to the file. nginx answers Range
+// and conditional requests itself, and passes on the Content-Type and
+// Cache-Control given here.
+//
+// The bytes are counted as they are handed over, from the size of the
+// object and the range asked for; nginx's access log has what it actually
+// sent, which is less when a client goes away mid-transfer.
+func (h *StreamingHandler) accelRedirect(c *gin.Context, key, contentType, cacheControl, quality string, info storage.FileInfo) bool {
+	prefix := h.cfg.Storage.AccelRedirectPrefix
+	if prefix == "" {
+		return false
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Cache-Control", cacheControl)
+	c.Header("X-Accel-Redirect", prefix+key)
+	c.Status(http.StatusOK)
+	metrics.RecordVideoStreaming(quality, requestedLength(c.GetHeader("Range"), info.Size))
+	return true
+}
+
+// recordStreamed counts what a media response wrote towards quality's
+// streaming total.
+func recordStreamed(c *gin.Context, quality string) {
+	if written := c.Writer.Size(); written > 0 {
+		metrics.RecordVideoStreaming(quality, int64(written))
+	}
+}
+
+// requestedLength is how many bytes of an object of size a Range header asks
+// for. Only a single byte range is worked out, as http.ServeContent would
+// serve it; any other header, or none, asks for the whole object.
+func requestedLength(header string, size int64) int64 {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return size
+	}
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return size
+	}
+	if first == "" {
+		// A suffix: the last n bytes.
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 {
+			return size
+		}
+		return min(n, size)
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return size
+	}
+	end := size - 1
+	if last != "" {
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+			return size
+		}
+		end = min(end, size-1)
+	}
+	return end - start + 1
+}
+
+// ServeThumbnail serves a video's poster image, in the size and format asked
+// for; see thumbnailImageKey.
 //
 // It exists because a thumbnail is not public data: it is a frame of the video,
 // so a private video's thumbnail must be as private as the video. Serving it
@@ -384,32 +1066,221 @@ func (h *StreamingHandler) ServeThumbnail(c *gin.Context) {
 		return
 	}
 
-	key := *video.ThumbnailPath
+	key, contentType, ok := thumbnailImageKey(c, *video.ThumbnailPath)
+	if !ok {
+		return
+	}
+	// The owner can pick another poster at any time, so unlike the images
+	// under a thumbnail's own URL this one is only cached briefly.
+	serveStoredImage(c, h.store, h.log, key, contentType, "public, max-age=300")
+}
 
-	fileInfo, err := h.store.Stat(ctx, key)
+// thumbnailImageKey picks the file of the thumbnail rendered under base that
+// the request asks for: ?size= names one of domain.ThumbnailSizes, the
+// smallest by default, and ?format= is jpeg or webp, by default WebP when
+// the client accepts it. A base with an extension is a single JPEG kept
+// from before thumbnails came in sizes, and is served whatever was asked.
+// On a bad parameter the response is written and ok is false.
+func thumbnailImageKey(c *gin.Context, base string) (key, contentType string, ok bool) {
+	size := domain.ThumbnailSizes[0]
+	if name := c.Query("size"); name != "" {
+		if size, ok = domain.ThumbnailSizeNamed(name); !ok {
+			response.ValidationError(c, "size must be small, medium or large")
+			return "", "", false
+		}
+	}
+
+	format := c.Query("format")
+	switch format {
+	case "":
+		format = "jpeg"
+		if strings.Contains(c.GetHeader("Accept"), "image/webp") {
+			format = "webp"
+		}
+		c.Header("Vary", "Accept")
+	case "jpeg", "webp":
+	default:
+		response.ValidationError(c, "format must be jpeg or webp")
+		return "", "", false
+	}
+
+	if path.Ext(base) != "" {
+		return base, "image/jpeg", true
+	}
+	if format == "webp" {
+		return base + "/" + size.Name + ".webp", "image/webp", true
+	}
+	return base + "/" + size.Name + ".jpg", "image/jpeg", true
+}
+
+// serveStoredImage serves the image at key, answering 404 when it is not in
+// the store.
+func serveStoredImage(c *gin.Context, store storage.Store, log *logger.Logger, key, contentType, cacheControl string) {
+	ctx := c.Request.Context()
+
+	fileInfo, err := store.Stat(ctx, key)
 	if err != nil {
 		response.NotFound(c, "Thumbnail not available")
 		return
 	}
 
+	obj, err := store.Open(ctx, key)
+	if err != nil {
+		log.Error(ctx, "failed to open thumbnail", err, map[string]interface{}{
+			"key": key,
+		})
+		response.NotFound(c, "Thumbnail not available")
+		return
+	}
+	defer obj.Close()
+
+	c.Header("Content-Type", contentType)
+	c.Header("Cache-Control", cacheControl)
+	http.ServeContent(c.Writer, c.Request, path.Base(key), fileInfo.ModTime, obj)
+}
+
+// ServePreview serves a video's animated hover preview: a looping WebP, or
+// with ?format=mp4 an MP4 for a muted <video>. Like the poster, it is a part
+// of the video and exactly as private as it is, and is replaced when the
+// video is processed again, so it is only cached briefly.
+func (h *StreamingHandler) ServePreview(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	videoID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.ValidationError(c, "Invalid video ID")
+		return
+	}
+
+	ext, contentType := ".webp", "image/webp"
+	switch c.Query("format") {
+	case "", "webp":
+	case "mp4":
+		ext, contentType = ".mp4", "video/mp4"
+	default:
+		response.ValidationError(c, "format must be webp or mp4")
+		return
+	}
+
+	video, err := h.videoRepo.GetByID(ctx, videoID)
+	if err != nil {
+		if errors.Is(err, domain.ErrVideoNotFound) {
+			response.NotFound(c, "Video not found")
+			return
+		}
+		response.InternalError(c, "Failed to retrieve video")
+		return
+	}
+
+	if !canViewVideo(ctx, video) {
+		response.NotFound(c, "Video not found")
+		return
+	}
+
+	if video.PreviewPath == nil || *video.PreviewPath == "" {
+		response.NotFound(c, "Preview not available")
+		return
+	}
+
+	key := *video.PreviewPath + ext
+	fileInfo, err := h.store.Stat(ctx, key)
+	if err != nil {
+		response.NotFound(c, "Preview not available")
+		return
+	}
+
 	obj, err := h.store.Open(ctx, key)
 	if err != nil {
-		h.log.Error(ctx, "failed to open thumbnail", err, map[string]interface{}{
+		h.log.Error(ctx, "failed to open preview", err, map[string]interface{}{
 			"video_id": videoID,
 			"key":      key,
 		})
-		response.NotFound(c, "Thumbnail not available")
+		response.NotFound(c, "Preview not available")
 		return
 	}
 	defer obj.Close()
 
-	c.Header("Content-Type", "image/jpeg")
-	// A thumbnail never changes once written: the worker generates it exactly
-	// once, and a re-transcode produces a new video ID.
-	c.Header("Cache-Control", "public, max-age=86400, immutable")
+	c.Header("Content-Type", contentType)
+	c.Header("Cache-Control", "public, max-age=300")
+	c.Header("Accept-Ranges", "bytes")
 	http.ServeContent(c.Writer, c.Request, path.Base(key), fileInfo.ModTime, obj)
 }
 
+// ServeTrickplay serves a video's seek-bar previews: thumbnails.vtt, which
+// maps time ranges to tiles of the sprite sheets, and the sheets themselves.
+// They are frames of the video, so they are exactly as private as it is.
+func (h *StreamingHandler) ServeTrickplay(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	videoID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.ValidationError(c, "Invalid video ID")
+		return
+	}
+
+	file := c.Param("file")
+	if !trickplayFilePattern.MatchString(file) {
+		response.ValidationError(c, "Invalid trickplay file name")
+		return
+	}
+
+	video, err := h.videoRepo.GetByID(ctx, videoID)
+	if err != nil {
+		if errors.Is(err, domain.ErrVideoNotFound) {
+			response.NotFound(c, "Video not found")
+			return
+		}
+		response.InternalError(c, "Failed to retrieve video")
+		return
+	}
+
+	if !canViewVideo(ctx, video) {
+		response.NotFound(c, "Video not found")
+		return
+	}
+
+	if video.Status != domain.VideoStatusReady {
+		response.Error(c, http.StatusNotFound, "VIDEO_NOT_READY", "Video not ready for streaming")
+		return
+	}
+
+	if h.refusePremiere(c, video, false) {
+		return
+	}
+
+	key := transcodedKey(videoID, video.OutputRevision, service.TrickplayDir, file)
+
+	fileInfo, err := h.store.Stat(ctx, key)
+	if err != nil {
+		// Videos transcoded before trickplay existed, or with it turned
+		// off, simply have none.
+		response.NotFound(c, "Trickplay thumbnails not available")
+		return
+	}
+
+	obj, err := h.store.Open(ctx, key)
+	if err != nil {
+		h.log.Error(ctx, "failed to open trickplay file", err, map[string]interface{}{
+			"video_id": videoID,
+			"key":      key,
+		})
+		response.NotFound(c, "Trickplay thumbnails not available")
+		return
+	}
+	defer obj.Close()
+
+	contentType := "image/jpeg"
+	if path.Ext(file) == ".vtt" {
+		contentType = "text/vtt"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Cache-Control", "public, max-age=86400")
+	http.ServeContent(c.Writer, c.Request, file, fileInfo.ModTime, obj)
+}
+
+// ClearPlaylistCache drops a video's cached playlists and manifests, and the
+// copies of its segments the segment cache keeps, if there is one, so the
+// next request for any of them reads the store.
 func (h *StreamingHandler) ClearPlaylistCache(c *gin.Context) {
 	ctx := c.Request.Context()
 
@@ -419,25 +1290,33 @@ func (h *StreamingHandler) ClearPlaylistCache(c *gin.Context) {
 		return
 	}
 
-	pattern := fmt.Sprintf("playlist:%s:*", videoID)
+	// Segments first: their cache is on local disk, and a Redis outage
+	// should not keep stale ones served.
+	segments := storage.PurgeCached(h.store, storage.Key("transcoded", videoID.String()))
+
+	pattern := cache.PlaylistPattern(videoID)
 
 	if err := h.cache.DeletePattern(ctx, pattern); err != nil {
 		h.log.Error(ctx, "failed to clear playlist cache", err, map[string]interface{}{
-			"video_id": videoID,
+			"video_id":        videoID,
+			"segments_purged": segments,
 		})
 		response.InternalError(c, "Failed to clear playlist cache")
 		return
 	}
 
 	response.Success(c, http.StatusOK, gin.H{
-		"message":  "Playlist cache cleared",
-		"video_id": videoID,
+		"message":         "Playlist cache cleared",
+		"video_id":        videoID,
+		"segments_purged": segments,
 	})
 }
 
-func (h *StreamingHandler) servePlaylistContent(c *gin.Context, content string) {
-	c.Header("Content-Type", "application/vnd.apple.mpegurl")
-	c.Header("Cache-Control", "public, max-age=3600")
+func (h *StreamingHandler) servePlaylistContent(c *gin.Context, contentType, content string) {
+	c.Header("Content-Type", contentType)
+	// Every playlist carries a playback token signed for whoever asked for
+	// it, so none may be kept for anyone else, nor past the token's expiry.
+	c.Header("Cache-Control", "private, no-store")
 	// The CORS headers are deliberately not set here. This used to send
 	// Access-Control-Allow-Origin: * on every playlist, silently overriding the
 	// configured origin allowlist for exactly the routes a player calls. The
@@ -445,17 +1324,120 @@ func (h *StreamingHandler) servePlaylistContent(c *gin.Context, content string)
 	c.String(http.StatusOK, content)
 }
 
-func isValidQuality(quality string) bool {
-	validQualities := map[string]bool{
-		"360p":  true,
-		"480p":  true,
-		"720p":  true,
-		"1080p": true,
+// codecFamilies maps the sample entry that starts a video codec string in
+// CODECS to the codec it is, as the codecs query parameter names it.
+var codecFamilies = map[string]string{
+	"avc1": config.CodecH264,
+	"avc3": config.CodecH264,
+	"hvc1": config.CodecHEVC,
+	"hev1": config.CodecHEVC,
+	"av01": config.CodecAV1,
+}
+
+// parseCodecHints reads the codecs query parameter, a comma-separated list
+// of the video codecs a player decodes beyond H.264, which every player
+// does and need not list.
+func parseCodecHints(value string) (map[string]bool, error) {
+	codecs := map[string]bool{config.CodecH264: true}
+	for _, codec := range strings.Split(value, ",") {
+		switch codec = strings.ToLower(strings.TrimSpace(codec)); codec {
+		case "":
+		case config.CodecH264, config.CodecHEVC, config.CodecAV1:
+			codecs[codec] = true
+		default:
+			return nil, fmt.Errorf("codecs must list %s, %s or %s", config.CodecH264, config.CodecHEVC, config.CodecAV1)
+		}
+	}
+	return codecs, nil
+}
+
+// filterVariants drops from a master playlist every variant whose video codec
+// is not among codecs, so a player that can decode HEVC or AV1 is offered
+// their smaller renditions and one that cannot never picks one it would fail
+// on. A variant whose CODECS names no codec this knows of is kept, as are all
+// of them if none would be left.
+func filterVariants(master string, codecs map[string]bool) string {
+	lines := strings.Split(master, "\n")
+	out := make([]string, 0, len(lines))
+	kept := 0
+	for i := 0; i < len(lines); i++ {
+		attrs, ok := strings.CutPrefix(strings.TrimSpace(lines[i]), "#EXT-X-STREAM-INF:")
+		if !ok {
+			out = append(out, lines[i])
+			continue
+		}
+		if family := variantCodec(attrs); family != "" && !codecs[family] {
+			// The variant's URI is the line after its tag.
+			i++
+			continue
+		}
+		kept++
+		out = append(out, lines[i])
 	}
-	return validQualities[quality]
+	if kept == 0 {
+		return master
+	}
+	return strings.Join(out, "\n")
 }
 
+// variantCodec is the codec of a variant's video as codecFamilies names it,
+// or "" when its CODECS names none that it knows.
+func variantCodec(attrs string) string {
+	// CODECS, not the end of SUPPLEMENTAL-CODECS.
+	from := 0
+	for {
+		at := strings.Index(attrs[from:], `CODECS="`)
+		if at < 0 {
+			return ""
+		}
+		at += from
+		if at == 0 || attrs[at-1] == ',' {
+			from = at + len(`CODECS="`)
+			break
+		}
+		from = at + 1
+	}
+	codecs, _, _ := strings.Cut(attrs[from:], `"`)
+	for _, codec := range strings.Split(codecs, ",") {
+		entry, _, _ := strings.Cut(strings.TrimSpace(codec), ".")
+		if family, ok := codecFamilies[entry]; ok {
+			return family
+		}
+	}
+	return ""
+}
+
+// isValidQuality checks the shape of a quality name, not membership of any
+// particular ladder: the ladder is the worker's configuration and can change
+// after a video was transcoded. A name that passes is safe in a storage key;
+// one the video was never encoded at simply is not found.
+func isValidQuality(quality string) bool {
+	return config.ValidRenditionName(quality)
+}
+
+// segmentNamePattern matches the files a variant directory holds besides its
+// playlist: MPEG-TS or CMAF media segments, and a CMAF init segment, which
+// the worker names after the variant. A caption's directory holds WebVTT
+// segments and the whole file as captions.vtt. Segments are numbered from
+// three digits up; a long broadcast runs past a thousand of them.
+var segmentNamePattern = regexp.MustCompile(`^(segment_\d{3,9}\.(ts|m4s|vtt)|init_[a-z0-9][a-z0-9_-]{0,31}\.mp4|captions\.vtt)$`)
+
+// trickplayFilePattern matches the files of a video's trickplay directory.
+var trickplayFilePattern = regexp.MustCompile(`^(thumbnails\.vtt|sprite_\d{3,}\.jpg)$`)
+
 func isValidSegmentName(segment string) bool {
-	matched, _ := regexp.MatchString(`^segment_\d{3}\.ts$`, segment)
-	return matched
+	return segmentNamePattern.MatchString(segment)
+}
+
+func segmentContentType(segment string) string {
+	switch path.Ext(segment) {
+	case ".m4s":
+		return "video/iso.segment"
+	case ".mp4":
+		return "video/mp4"
+	case ".vtt":
+		return "text/vtt"
+	default:
+		return "video/MP2T"
+	}
 }
@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestedLength(t *testing.T) {
+	const size = 1000
+	tests := []struct {
+		header string
+		want   int64
+	}{
+		{"", size},
+		{"bytes=0-", size},
+		{"bytes=0-99", 100},
+		{"bytes=900-", 100},
+		{"bytes=900-5000", 100},
+		{"bytes=-200", 200},
+		{"bytes=-5000", size},
+		{"bytes=0-99,200-299", size},
+		{"bytes=1000-", size},
+		{"bytes=99-0", size},
+		{"bytes=-0", size},
+		{"bytes=abc-", size},
+		{"items=0-99", size},
+	}
+	for _, tt := range tests {
+		if got := requestedLength(tt.header, size); got != tt.want {
+			t.Errorf("requestedLength(%q, %d) = %d, want %d", tt.header, size, got, tt.want)
+		}
+	}
+}
+
+func TestLiveWindow(t *testing.T) {
+	playlist := "#EXTM3U\n" +
+		"#EXT-X-VERSION:3\n" +
+		"#EXT-X-TARGETDURATION:2\n" +
+		"#EXT-X-MEDIA-SEQUENCE:0\n" +
+		"#EXT-X-PLAYLIST-TYPE:EVENT\n" +
+		"#EXT-X-PROGRAM-DATE-TIME:2026-10-16T12:00:00.000+0000\n" +
+		"#EXTINF:2.000000,\n" +
+		"segment_000.ts\n" +
+		"#EXT-X-DISCONTINUITY\n" +
+		"#EXTINF:2.000000,\n" +
+		"segment_001.ts\n" +
+		"#EXT-X-PROGRAM-DATE-TIME:2026-10-16T12:00:04.000+0000\n" +
+		"#EXTINF:2.000000,\n" +
+		"segment_002.ts\n" +
+		"#EXTINF:1.500000,\n" +
+		"segment_003.ts\n"
+
+	if !isLivePlaylist(playlist) {
+		t.Fatal("a playlist without EXT-X-ENDLIST is not live")
+	}
+	if isLivePlaylist(playlist + "#EXT-X-ENDLIST\n") {
+		t.Fatal("a sealed playlist is live")
+	}
+
+	want := "#EXTM3U\n" +
+		"#EXT-X-VERSION:3\n" +
+		"#EXT-X-TARGETDURATION:2\n" +
+		"#EXT-X-MEDIA-SEQUENCE:2\n" +
+		"#EXT-X-DISCONTINUITY-SEQUENCE:1\n" +
+		"#EXT-X-PROGRAM-DATE-TIME:2026-10-16T12:00:04.000+0000\n" +
+		"#EXTINF:2.000000,\n" +
+		"segment_002.ts\n" +
+		"#EXTINF:1.500000,\n" +
+		"segment_003.ts\n"
+	if got := liveWindow(playlist, 2); got != want {
+		t.Errorf("liveWindow(2) =\n%s\nwant\n%s", got, want)
+	}
+
+	// A window wider than the playlist keeps every segment.
+	got := liveWindow(playlist, 10)
+	if strings.Contains(got, "PLAYLIST-TYPE") || !strings.Contains(got, "#EXT-X-MEDIA-SEQUENCE:0\n") ||
+		strings.Count(got, "#EXTINF:") != 4 || strings.Contains(got, "DISCONTINUITY-SEQUENCE") {
+		t.Errorf("liveWindow(10) =\n%s", got)
+	}
+}
+
+func TestDVRWindow(t *testing.T) {
+	playlist := "#EXTM3U\n" +
+		"#EXT-X-TARGETDURATION:2\n" +
+		"#EXT-X-MEDIA-SEQUENCE:0\n" +
+		"#EXT-X-PLAYLIST-TYPE:EVENT\n"
+	for i := 0; i < 60; i++ {
+		playlist += fmt.Sprintf("#EXTINF:2.000000,\nsegment_%03d.ts\n", i)
+	}
+
+	// Two minutes of a two-minute broadcast: all of it, but no longer as an
+	// event, as the window will slide.
+	got := dvrWindow(playlist, 2*time.Minute, 3)
+	if strings.Count(got, "#EXTINF:") != 60 || strings.Contains(got, "PLAYLIST-TYPE") {
+		t.Errorf("dvrWindow(2m) =\n%s", got)
+	}
+
+	got = dvrWindow(playlist, 30*time.Second, 3)
+	if strings.Count(got, "#EXTINF:") != 15 || !strings.Contains(got, "#EXT-X-MEDIA-SEQUENCE:45\n") ||
+		!strings.HasPrefix(strings.Split(got, "segment_")[1], "045.ts") {
+		t.Errorf("dvrWindow(30s) =\n%s", got)
+	}
+
+	// A window narrower than the live playlist's minimum keeps the minimum.
+	if got := dvrWindow(playlist, time.Second, 3); strings.Count(got, "#EXTINF:") != 3 {
+		t.Errorf("dvrWindow(1s) =\n%s", got)
+	}
+}
+
+func TestPlayoutWindow(t *testing.T) {
+	playlist := "#EXTM3U\n" +
+		"#EXT-X-VERSION:7\n" +
+		"#EXT-X-TARGETDURATION:6\n" +
+		"#EXT-X-MEDIA-SEQUENCE:0\n" +
+		"#EXT-X-PLAYLIST-TYPE:VOD\n" +
+		"#EXT-X-MAP:URI=\"init.mp4\"\n" +
+		"#EXTINF:6.000000,\n" +
+		"segment_000.m4s\n" +
+		"#EXTINF:6.000000,\n" +
+		"segment_001.m4s\n" +
+		"#EXTINF:3.500000,\n" +
+		"segment_002.m4s\n" +
+		"#EXT-X-ENDLIST\n"
+
+	want := "#EXTM3U\n" +
+		"#EXT-X-VERSION:7\n" +
+		"#EXT-X-TARGETDURATION:6\n" +
+		"#EXT-X-MEDIA-SEQUENCE:0\n" +
+		"#EXT-X-PLAYLIST-TYPE:EVENT\n" +
+		"#EXT-X-MAP:URI=\"init.mp4\"\n" +
+		"#EXTINF:6.000000,\n" +
+		"segment_000.m4s\n"
+	got := playoutWindow(playlist, 11*time.Second)
+	if got != want {
+		t.Errorf("playoutWindow(11s) =\n%s\nwant\n%s", got, want)
+	}
+	if !isLivePlaylist(got) {
+		t.Error("a premiere under way is not played out as live")
+	}
+	if !listsURI(got, "init.mp4") || !listsURI(got, "segment_000.m4s") || listsURI(got, "segment_001.m4s") {
+		t.Errorf("listsURI disagrees with the playlist:\n%s", got)
+	}
+
+	// Before the first segment has played there is nothing to list.
+	if got := playoutWindow(playlist, 0); strings.Contains(got, "#EXTINF:") {
+		t.Errorf("playoutWindow(0) =\n%s", got)
+	}
+	// Once it has all played it is the video's playlist again.
+	if got := playoutWindow(playlist, 15500*time.Millisecond); got != playlist {
+		t.Errorf("playoutWindow(end) =\n%s", got)
+	}
+}
+
+func TestSegmentNames(t *testing.T) {
+	for _, name := range []string{"segment_000.ts", "segment_1000.ts", "segment_21600.m4s", "captions.vtt", "init_720p.mp4"} {
+		if !isValidSegmentName(name) {
+			t.Errorf("%q refused", name)
+		}
+	}
+	for _, name := range []string{"segment_00.ts", "segment_0000000000.ts", "segment_000.m3u8", "../segment_000.ts"} {
+		if isValidSegmentName(name) {
+			t.Errorf("%q accepted", name)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/internal/queue"
+	"github.com/Nuu-maan/video-streaming-service/internal/repository"
+	"github.com/Nuu-maan/video-streaming-service/internal/service"
+	"github.com/Nuu-maan/video-streaming-service/internal/storage"
+	"github.com/Nuu-maan/video-streaming-service/pkg/logger"
+	"github.com/Nuu-maan/video-streaming-service/pkg/response"
+	"github.com/Nuu-maan/video-streaming-service/pkg/validator"
+)
+
+// ThumbnailHandler lets a video's owner choose its poster: list the
+// candidates and their own uploads, preview them, pick one, or upload
+// another. Everyone else only ever sees the chosen one, through
+// StreamingHandler.ServeThumbnail.
+type ThumbnailHandler struct {
+	thumbnails  *service.ThumbnailService
+	videoRepo   repository.VideoRepository
+	store       storage.Store
+	queueClient *queue.QueueClient
+	log         *logger.Logger
+}
+
+func NewThumbnailHandler(
+	thumbnails *service.ThumbnailService,
+	videoRepo repository.VideoRepository,
+	store storage.Store,
+	queueClient *queue.QueueClient,
+	log *logger.Logger,
+) *ThumbnailHandler {
+	return &ThumbnailHandler{
+		thumbnails:  thumbnails,
+		videoRepo:   videoRepo,
+		store:       store,
+		queueClient: queueClient,
+		log:         log,
+	}
+}
+
+// thumbnailsForbidden is what a user who can see a video but does not own it
+// is told.
+const thumbnailsForbidden = "You may only manage the thumbnails of your own videos"
+
+// selectThumbnailRequest names the thumbnail to make a video's poster.
+type selectThumbnailRequest struct {
+	ThumbnailID string `json:"thumbnail_id" binding:"required"`
+}
+
+// List returns a video's candidate and uploaded thumbnails.
+func (h *ThumbnailHandler) List(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	video, ok := loadOwnedVideo(c, h.videoRepo, h.log, thumbnailsForbidden)
+	if !ok {
+		return
+	}
+
+	thumbnails, err := h.thumbnails.ListThumbnails(ctx, video)
+	if err != nil {
+		h.log.Error(ctx, "failed to list thumbnails", err, map[string]interface{}{"video_id": video.ID})
+		response.InternalError(c, "Failed to retrieve thumbnails")
+		return
+	}
+	response.Success(c, http.StatusOK, thumbnails)
+}
+
+// Preview serves one of a video's ready thumbnails, in the size and format
+// asked for, as ServeThumbnail does the selected one.
+func (h *ThumbnailHandler) Preview(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	video, ok := loadOwnedVideo(c, h.videoRepo, h.log, thumbnailsForbidden)
+	if !ok {
+		return
+	}
+	thumbnailID, err := validator.ValidateUUID(c.Param("thumbnailId"))
+	if err != nil {
+		response.ValidationError(c, "Invalid thumbnail ID")
+		return
+	}
+
+	thumbnail, err := h.thumbnails.GetThumbnail(ctx, video, thumbnailID)
+	if err != nil {
+		if errors.Is(err, domain.ErrThumbnailNotFound) {
+			response.NotFound(c, "Thumbnail not found")
+			return
+		}
+		h.log.Error(ctx, "failed to load thumbnail", err, map[string]interface{}{
+			"video_id":     video.ID,
+			"thumbnail_id": thumbnailID,
+		})
+		response.InternalError(c, "Failed to retrieve thumbnail")
+		return
+	}
+	if thumbnail.Status != domain.ThumbnailReady {
+		response.NotFound(c, "Thumbnail not available")
+		return
+	}
+
+	key, contentType, ok := thumbnailImageKey(c, thumbnail.Key)
+	if !ok {
+		return
+	}
+	// A thumbnail's images never change once rendered.
+	serveStoredImage(c, h.store, h.log, key, contentType, "private, max-age=86400, immutable")
+}
+
+// Select makes one of a video's ready thumbnails its poster.
+func (h *ThumbnailHandler) Select(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	video, ok := loadOwnedVideo(c, h.videoRepo, h.log, thumbnailsForbidden)
+	if !ok {
+		return
+	}
+
+	var req selectThumbnailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, "thumbnail_id is required")
+		return
+	}
+	thumbnailID, err := validator.ValidateUUID(req.ThumbnailID)
+	if err != nil {
+		response.ValidationError(c, "Invalid thumbnail ID")
+		return
+	}
+
+	thumbnail, err := h.thumbnails.SelectThumbnail(ctx, video, thumbnailID)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrThumbnailNotFound):
+			response.NotFound(c, "Thumbnail not found")
+		case errors.Is(err, domain.ErrThumbnailNotReady):
+			response.Error(c, http.StatusConflict, "THUMBNAIL_NOT_READY", "Only a ready thumbnail can be selected")
+		default:
+			h.log.Error(ctx, "failed to select thumbnail", err, map[string]interface{}{
+				"video_id":     video.ID,
+				"thumbnail_id": thumbnailID,
+			})
+			response.InternalError(c, "Failed to select thumbnail")
+		}
+		return
+	}
+	response.Success(c, http.StatusOK, thumbnail)
+}
+
+// Upload accepts a JPEG or PNG poster for a ready video and queues it for
+// rendering. Once rendered it becomes the video's poster.
+func (h *ThumbnailHandler) Upload(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	video, ok := loadOwnedVideo(c, h.videoRepo, h.log, thumbnailsForbidden)
+	if !ok {
+		return
+	}
+
+	file, header, err := c.Request.FormFile("image")
+	if err != nil {
+		response.ValidationError(c, "An image file is required")
+		return
+	}
+	defer file.Close()
+
+	thumbnail, err := h.thumbnails.AddCustomThumbnail(ctx, video, file, header)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrVideoNotReady):
+			response.Error(c, http.StatusConflict, "VIDEO_NOT_READY", "Thumbnails can only be added once the video is ready")
+		case errors.Is(err, validator.ErrFileTooLarge):
+			response.Error(c, http.StatusRequestEntityTooLarge, "FILE_TOO_LARGE", err.Error())
+		case errors.Is(err, validator.ErrInvalidFormat), errors.Is(err, domain.ErrInvalidThumbnail):
+			response.Error(c, http.StatusUnsupportedMediaType, "INVALID_FORMAT", err.Error())
+		case errors.Is(err, domain.ErrInvalidInput):
+			response.ValidationError(c, err.Error())
+		default:
+			h.log.Error(ctx, "thumbnail upload failed", err, map[string]interface{}{
+				"video_id": video.ID,
+				"filename": header.Filename,
+			})
+			response.InternalError(c, "Failed to upload thumbnail")
+		}
+		return
+	}
+
+	// As with a caption, the image is safely recorded and a failure to queue
+	// it is only logged.
+	if err := h.queueClient.EnqueueThumbnailProcessing(ctx, video.ID.String(), thumbnail.ID.String()); err != nil {
+		h.log.Error(ctx, "thumbnail stored but could not be queued for processing", err, map[string]interface{}{
+			"video_id":     video.ID,
+			"thumbnail_id": thumbnail.ID,
+		})
+	}
+
+	response.Success(c, http.StatusCreated, thumbnail)
+}
@@ -0,0 +1,368 @@
+package handler
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/internal/queue"
+	"github.com/Nuu-maan/video-streaming-service/internal/service"
+	"github.com/Nuu-maan/video-streaming-service/pkg/appctx"
+	"github.com/Nuu-maan/video-streaming-service/pkg/logger"
+	"github.com/Nuu-maan/video-streaming-service/pkg/response"
+	"github.com/Nuu-maan/video-streaming-service/pkg/validator"
+)
+
+// The tus resumable upload protocol, version 1.0.0, with the creation and
+// termination extensions. See https://tus.io/protocols/resumable-upload.
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,termination"
+
+	tusOffsetContentType = "application/offset+octet-stream"
+)
+
+// UploadSessionHandler serves resumable uploads over the tus protocol, so a
+// multi-gigabyte upload that drops halfway resumes from the last committed
+// chunk instead of starting over. Completed uploads become videos exactly like
+// a multipart upload to POST /videos does, and are queued the same way.
+type UploadSessionHandler struct {
+	uploads      *service.ResumableUploadService
+	queueClient  *queue.QueueClient
+	maxSize      int64
+	chunkTimeout time.Duration
+	log          *logger.Logger
+}
+
+func NewUploadSessionHandler(
+	uploads *service.ResumableUploadService,
+	queueClient *queue.QueueClient,
+	maxSize int64,
+	chunkTimeout time.Duration,
+	log *logger.Logger,
+) *UploadSessionHandler {
+	return &UploadSessionHandler{
+		uploads:      uploads,
+		queueClient:  queueClient,
+		maxSize:      maxSize,
+		chunkTimeout: chunkTimeout,
+		log:          log,
+	}
+}
+
+// TusOptions answers tus capability discovery. It runs as middleware ahead of
+// CORS because CORS ends every OPTIONS request before routing; this only adds
+// the tus headers to OPTIONS requests under an uploads path and lets CORS
+// finish the response as usual.
+func TusOptions(maxSize int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodOptions && isUploadsPath(c.Request.URL.Path) {
+			c.Header("Tus-Resumable", tusVersion)
+			c.Header("Tus-Version", tusVersion)
+			c.Header("Tus-Extension", tusExtensions)
+			c.Header("Tus-Max-Size", strconv.FormatInt(maxSize, 10))
+		}
+		c.Next()
+	}
+}
+
+func isUploadsPath(path string) bool {
+	return strings.HasSuffix(path, "/uploads") || strings.Contains(path, "/uploads/")
+}
+
+// Create starts a resumable upload (tus creation extension). The total size
+// comes from Upload-Length; the filename, title, description, visibility and
+// normalize_audio come from Upload-Metadata. The response's Location is where the chunks go.
+func (h *UploadSessionHandler) Create(c *gin.Context) {
+	if !h.requireTus(c) {
+		return
+	}
+	ctx := c.Request.Context()
+
+	principal, ok := appctx.PrincipalFrom(ctx)
+	if !ok {
+		response.Unauthorized(c, "Authentication required to upload")
+		return
+	}
+
+	if c.GetHeader("Upload-Defer-Length") != "" {
+		response.BadRequest(c, "Upload-Defer-Length is not supported; send Upload-Length")
+		return
+	}
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		response.BadRequest(c, "Upload-Length must be a non-negative integer")
+		return
+	}
+	if length > h.maxSize {
+		response.Error(c, http.StatusRequestEntityTooLarge, "FILE_TOO_LARGE",
+			fmt.Sprintf("upload is %d bytes, maximum is %d bytes", length, h.maxSize))
+		return
+	}
+
+	metadata, err := parseUploadMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	filename := metadata["filename"]
+	if filename == "" {
+		filename = metadata["name"]
+	}
+	if filename == "" {
+		response.ValidationError(c, "Upload-Metadata must include filename")
+		return
+	}
+
+	normalizeAudio, err := parseNormalizeAudio(metadata["normalize_audio"])
+	if err != nil {
+		response.ValidationError(c, err.Error())
+		return
+	}
+
+	session, err := h.uploads.CreateSession(ctx, service.CreateUploadSessionRequest{
+		OwnerID:        principal.UserID,
+		Filename:       filename,
+		MimeType:       metadata["filetype"],
+		Title:          metadata["title"],
+		Description:    metadata["description"],
+		Visibility:     domain.VideoVisibility(metadata["visibility"]),
+		NormalizeAudio: normalizeAudio,
+		Length:         length,
+	})
+	if err != nil {
+		respondUploadError(c, h.log, err, filename)
+		return
+	}
+
+	c.Header("Location", strings.TrimSuffix(c.Request.URL.Path, "/")+"/"+session.ID.String())
+	c.Header("Upload-Offset", "0")
+	c.Header("Upload-Expires", session.ExpiresAt.UTC().Format(http.TimeFormat))
+	c.Status(http.StatusCreated)
+}
+
+// Head reports how much of an upload the server holds, which is where the
+// client resumes from. It must not be cached: a stale offset makes the next
+// chunk fail with 409.
+func (h *UploadSessionHandler) Head(c *gin.Context) {
+	if !h.requireTus(c) {
+		return
+	}
+
+	session, ok := h.loadSession(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(session.Length, 10))
+	c.Header("Upload-Expires", session.ExpiresAt.UTC().Format(http.TimeFormat))
+	if session.VideoID != nil {
+		c.Header("X-Video-ID", session.VideoID.String())
+	}
+	c.Status(http.StatusOK)
+}
+
+// Get returns the session as JSON for clients that are not speaking tus, such
+// as a page polling for the video ID once an upload finishes.
+func (h *UploadSessionHandler) Get(c *gin.Context) {
+	session, ok := h.loadSession(c)
+	if !ok {
+		return
+	}
+	response.Success(c, http.StatusOK, session)
+}
+
+// Patch appends one chunk at Upload-Offset. When the chunk completes the
+// upload, the new video's ID is returned in X-Video-ID and the video is queued
+// for processing.
+func (h *UploadSessionHandler) Patch(c *gin.Context) {
+	if !h.requireTus(c) {
+		return
+	}
+	ctx := c.Request.Context()
+
+	principal, ok := appctx.PrincipalFrom(ctx)
+	if !ok {
+		response.Unauthorized(c, "Authentication required to upload")
+		return
+	}
+	id, ok := parseUploadID(c)
+	if !ok {
+		return
+	}
+
+	if !strings.EqualFold(strings.TrimSpace(strings.Split(c.GetHeader("Content-Type"), ";")[0]), tusOffsetContentType) {
+		response.Error(c, http.StatusUnsupportedMediaType, "INVALID_CONTENT_TYPE",
+			"Content-Type must be "+tusOffsetContentType)
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		response.BadRequest(c, "Upload-Offset must be a non-negative integer")
+		return
+	}
+	size := c.Request.ContentLength
+	if size < 0 {
+		response.Error(c, http.StatusLengthRequired, "LENGTH_REQUIRED", "Each chunk must be sent with Content-Length")
+		return
+	}
+
+	// A chunk is as large as the client makes it, and the server-wide read
+	// timeout is sized for ordinary API requests. Extend it for this request
+	// only; the default recorder in tests does not support deadlines, which
+	// is harmless.
+	if err := http.NewResponseController(c.Writer).SetReadDeadline(time.Now().Add(h.chunkTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		h.log.Warn(ctx, "could not extend read deadline for upload chunk", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	session, video, err := h.uploads.WriteChunk(ctx, id, principal.UserID, offset, size, c.Request.Body)
+	if err != nil {
+		respondSessionError(c, h.log, err)
+		return
+	}
+
+	if video != nil {
+		// As with multipart uploads, the video is safely recorded; failing to
+		// queue it is recoverable via the admin retry endpoint.
+		if err := h.queueClient.EnqueueVideoProcessing(ctx, video.ID.String(), 0); err != nil {
+			h.log.Error(ctx, "video stored but could not be queued for processing", err, map[string]interface{}{
+				"video_id": video.ID,
+			})
+		}
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	c.Header("Upload-Expires", session.ExpiresAt.UTC().Format(http.TimeFormat))
+	if session.VideoID != nil {
+		c.Header("X-Video-ID", session.VideoID.String())
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Terminate abandons an upload (tus termination extension) and frees the
+// chunks stored for it.
+func (h *UploadSessionHandler) Terminate(c *gin.Context) {
+	if !h.requireTus(c) {
+		return
+	}
+	ctx := c.Request.Context()
+
+	principal, ok := appctx.PrincipalFrom(ctx)
+	if !ok {
+		response.Unauthorized(c, "Authentication required")
+		return
+	}
+	id, ok := parseUploadID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.uploads.Terminate(ctx, id, principal.UserID); err != nil {
+		respondSessionError(c, h.log, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// requireTus rejects requests from a client speaking a tus version this server
+// does not, as the protocol requires. Every response carries Tus-Resumable.
+func (h *UploadSessionHandler) requireTus(c *gin.Context) bool {
+	c.Header("Tus-Resumable", tusVersion)
+	if c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		response.Error(c, http.StatusPreconditionFailed, "UNSUPPORTED_TUS_VERSION",
+			"Tus-Resumable "+tusVersion+" is required")
+		return false
+	}
+	return true
+}
+
+func (h *UploadSessionHandler) loadSession(c *gin.Context) (*domain.UploadSession, bool) {
+	ctx := c.Request.Context()
+
+	principal, ok := appctx.PrincipalFrom(ctx)
+	if !ok {
+		response.Unauthorized(c, "Authentication required")
+		return nil, false
+	}
+	id, ok := parseUploadID(c)
+	if !ok {
+		return nil, false
+	}
+
+	session, err := h.uploads.GetSession(ctx, id, principal.UserID)
+	if err != nil {
+		respondSessionError(c, h.log, err)
+		return nil, false
+	}
+	return session, true
+}
+
+// respondSessionError maps session failures onto the status codes tus clients
+// act on: 409 makes them re-read the offset with HEAD, 404 and 410 make them
+// start a new upload, and 423 makes them back off and retry.
+func respondSessionError(c *gin.Context, log *logger.Logger, err error) {
+	switch {
+	case errors.Is(err, domain.ErrUploadSessionNotFound):
+		response.NotFound(c, "Upload not found")
+	case errors.Is(err, domain.ErrUploadSessionExpired):
+		response.Error(c, http.StatusGone, "UPLOAD_EXPIRED", "Upload has expired; start a new one")
+	case errors.Is(err, domain.ErrUploadOffsetMismatch):
+		response.Error(c, http.StatusConflict, "OFFSET_MISMATCH", err.Error())
+	case errors.Is(err, domain.ErrUploadSessionLocked):
+		response.Error(c, http.StatusLocked, "UPLOAD_LOCKED", "Another request is writing to this upload")
+	case errors.Is(err, domain.ErrUploadLengthExceeded):
+		response.Error(c, http.StatusRequestEntityTooLarge, "FILE_TOO_LARGE", err.Error())
+	case errors.Is(err, domain.ErrUploadMethodMismatch):
+		response.Error(c, http.StatusConflict, "UPLOAD_METHOD_MISMATCH", err.Error())
+	default:
+		respondUploadError(c, log, err, c.Param("id"))
+	}
+}
+
+func parseUploadID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := validator.ValidateUUID(c.Param("id"))
+	if err != nil {
+		response.NotFound(c, "Upload not found")
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// parseUploadMetadata decodes a tus Upload-Metadata header: comma-separated
+// pairs of a key and a base64-encoded value, where the value may be omitted.
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		switch len(fields) {
+		case 1:
+			metadata[fields[0]] = ""
+		case 2:
+			value, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("Upload-Metadata value for %q is not valid base64", fields[0])
+			}
+			metadata[fields[0]] = string(value)
+		default:
+			return nil, fmt.Errorf("malformed Upload-Metadata pair %q", strings.TrimSpace(pair))
+		}
+	}
+	return metadata, nil
+}
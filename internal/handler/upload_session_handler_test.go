@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+)
+
+func TestParseUploadMetadata(t *testing.T) {
+	b64 := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+
+	tests := []struct {
+		name    string
+		header  string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "empty header", header: "", want: map[string]string{}},
+		{
+			name:   "pairs decoded",
+			header: "filename " + b64("holiday.mp4") + ",title " + b64("Summer, 2024"),
+			want:   map[string]string{"filename": "holiday.mp4", "title": "Summer, 2024"},
+		},
+		{
+			name:   "key without a value",
+			header: "filename " + b64("a.mp4") + ",is_draft",
+			want:   map[string]string{"filename": "a.mp4", "is_draft": ""},
+		},
+		{
+			name:   "whitespace around pairs tolerated",
+			header: " filename " + b64("a.mp4") + " , title " + b64("A") + " ",
+			want:   map[string]string{"filename": "a.mp4", "title": "A"},
+		},
+		{name: "value that is not base64", header: "filename not*base64", wantErr: true},
+		{name: "too many fields in a pair", header: "filename a b", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseUploadMetadata(tt.header)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseUploadMetadata(%q) error = %v, wantErr %v", tt.header, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseUploadMetadata(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
@@ -29,6 +30,7 @@ type VideoHandler struct {
 	uploadService *service.UploadService
 	videoRepo     repository.VideoRepository
 	queueClient   *queue.QueueClient
+	progressFeed  *service.VideoProgressFeed
 	log           *logger.Logger
 	cfg           *config.Config
 }
@@ -37,6 +39,7 @@ func NewVideoHandler(
 	uploadService *service.UploadService,
 	videoRepo repository.VideoRepository,
 	queueClient *queue.QueueClient,
+	progressFeed *service.VideoProgressFeed,
 	log *logger.Logger,
 	cfg *config.Config,
 ) *VideoHandler {
@@ -44,6 +47,7 @@ func NewVideoHandler(
 		uploadService: uploadService,
 		videoRepo:     videoRepo,
 		queueClient:   queueClient,
+		progressFeed:  progressFeed,
 		log:           log,
 		cfg:           cfg,
 	}
@@ -68,16 +72,23 @@ func (h *VideoHandler) Upload(c *gin.Context) {
 	}
 	defer file.Close()
 
+	normalizeAudio, err := parseNormalizeAudio(c.PostForm("normalize_audio"))
+	if err != nil {
+		response.ValidationError(c, err.Error())
+		return
+	}
+
 	video, err := h.uploadService.UploadVideo(ctx, service.UploadRequest{
-		File:        file,
-		Header:      header,
-		Title:       c.PostForm("title"),
-		Description: c.PostForm("description"),
-		OwnerID:     principal.UserID,
-		Visibility:  domain.VideoVisibility(c.PostForm("visibility")),
+		File:           file,
+		Header:         header,
+		Title:          c.PostForm("title"),
+		Description:    c.PostForm("description"),
+		OwnerID:        principal.UserID,
+		Visibility:     domain.VideoVisibility(c.PostForm("visibility")),
+		NormalizeAudio: normalizeAudio,
 	})
 	if err != nil {
-		h.respondUploadError(c, err, header.Filename)
+		respondUploadError(c, h.log, err, header.Filename)
 		return
 	}
 
@@ -92,9 +103,107 @@ func (h *VideoHandler) Upload(c *gin.Context) {
 	response.Success(c, http.StatusCreated, video)
 }
 
+// importRequest names the source of a video to import, a url or a path into
+// the drop directory, and describes the video as an upload's form does.
+// NormalizeAudio is a pointer so that leaving it out keeps the default.
+type importRequest struct {
+	URL            string                 `json:"url"`
+	Path           string                 `json:"path"`
+	Title          string                 `json:"title"`
+	Description    string                 `json:"description"`
+	Visibility     domain.VideoVisibility `json:"visibility"`
+	NormalizeAudio *bool                  `json:"normalize_audio"`
+}
+
+// Import records a video whose source the worker fetches from a URL, or, for
+// admins, copies from the server's import drop directory, and queues the
+// fetch. The video waits in uploading until the source is in; its status
+// says how the import is going.
+func (h *VideoHandler) Import(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	principal, ok := appctx.PrincipalFrom(ctx)
+	if !ok {
+		response.Unauthorized(c, "Authentication required to import")
+		return
+	}
+
+	var req importRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, "Invalid import request")
+		return
+	}
+	// The drop directory is the server's file system; only admins may name
+	// files in it.
+	if req.Path != "" && !principal.HasPermission(domain.PermissionManageUsers) {
+		response.Error(c, http.StatusForbidden, "FORBIDDEN", "Only admins may import from the drop directory")
+		return
+	}
+	normalizeAudio := true
+	if req.NormalizeAudio != nil {
+		normalizeAudio = *req.NormalizeAudio
+	}
+
+	video, err := h.uploadService.CreateImport(ctx, service.ImportRequest{
+		Title:          req.Title,
+		Description:    req.Description,
+		OwnerID:        principal.UserID,
+		Visibility:     req.Visibility,
+		NormalizeAudio: normalizeAudio,
+		URL:            req.URL,
+		Path:           req.Path,
+	})
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidImportSource) {
+			response.ValidationError(c, err.Error())
+			return
+		}
+		respondUploadError(c, h.log, err, "")
+		return
+	}
+
+	// As with an upload, the video is safely recorded; the admin retry
+	// endpoint queues the fetch again.
+	if err := h.queueClient.EnqueueVideoImport(ctx, video.ID.String()); err != nil {
+		h.log.Error(ctx, "import recorded but could not be queued for fetching", err, map[string]interface{}{
+			"video_id": video.ID,
+		})
+	}
+
+	response.Success(c, http.StatusCreated, video)
+}
+
+// importState says how far an imported video's fetch has got: fetching until
+// its source is in, failed if the video failed before that, and fetched
+// after.
+func importState(video *domain.Video) string {
+	switch {
+	case video.FilePath != "":
+		return "fetched"
+	case video.Status == domain.VideoStatusFailed:
+		return "failed"
+	default:
+		return "fetching"
+	}
+}
+
+// parseNormalizeAudio reads an upload's normalize_audio option, which is on
+// unless the client turns it off.
+func parseNormalizeAudio(value string) (bool, error) {
+	if value == "" {
+		return true, nil
+	}
+	normalize, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, errors.New("normalize_audio must be true or false")
+	}
+	return normalize, nil
+}
+
 // respondUploadError maps upload failures onto status codes. Client mistakes
-// (too large, wrong format, bad title) must not be reported as 500s.
-func (h *VideoHandler) respondUploadError(c *gin.Context, err error, filename string) {
+// (too large, wrong format, bad title) must not be reported as 500s. It serves
+// both the multipart and the resumable upload endpoints.
+func respondUploadError(c *gin.Context, log *logger.Logger, err error, filename string) {
 	ctx := c.Request.Context()
 
 	switch {
@@ -107,7 +216,7 @@ func (h *VideoHandler) respondUploadError(c *gin.Context, err error, filename st
 		errors.Is(err, domain.ErrTitleTooLong), errors.Is(err, domain.ErrInvalidInput):
 		response.ValidationError(c, err.Error())
 	default:
-		h.log.Error(ctx, "upload failed", err, map[string]interface{}{"filename": filename})
+		log.Error(ctx, "upload failed", err, map[string]interface{}{"filename": filename})
 		response.InternalError(c, "Failed to upload video")
 	}
 }
@@ -180,6 +289,46 @@ func canViewVideo(ctx context.Context, video *domain.Video) bool {
 	return video.IsOwnedBy(principal.UserID) || principal.HasPermission(domain.PermissionWatchPrivate)
 }
 
+// loadOwnedVideo resolves the :id path parameter to a video for its owner,
+// writing the error response itself and reporting false when it could not. A
+// video the caller cannot see answers 404, as canViewVideo has it, and one
+// they can see but do not own 403 with forbidden.
+func loadOwnedVideo(c *gin.Context, videoRepo repository.VideoRepository, log *logger.Logger, forbidden string) (*domain.Video, bool) {
+	ctx := c.Request.Context()
+
+	principal, ok := appctx.PrincipalFrom(ctx)
+	if !ok {
+		response.Unauthorized(c, "Authentication required")
+		return nil, false
+	}
+
+	videoID, err := validator.ValidateUUID(c.Param("id"))
+	if err != nil {
+		response.ValidationError(c, "Invalid video ID")
+		return nil, false
+	}
+
+	video, err := videoRepo.GetByID(ctx, videoID)
+	if err != nil {
+		if errors.Is(err, domain.ErrVideoNotFound) {
+			response.NotFound(c, "Video not found")
+			return nil, false
+		}
+		log.Error(ctx, "failed to load video", err, map[string]interface{}{"video_id": videoID})
+		response.InternalError(c, "Failed to retrieve video")
+		return nil, false
+	}
+	if !canViewVideo(ctx, video) {
+		response.NotFound(c, "Video not found")
+		return nil, false
+	}
+	if !video.IsOwnedBy(principal.UserID) {
+		response.Error(c, http.StatusForbidden, "FORBIDDEN", forbidden)
+		return nil, false
+	}
+	return video, true
+}
+
 // DeleteVideo removes a video. Only its owner, or a user holding
 // PermissionDeleteAnyVideo, may do so.
 func (h *VideoHandler) DeleteVideo(c *gin.Context) {
@@ -234,9 +383,27 @@ func (h *VideoHandler) GetVideoStatus(c *gin.Context) {
 		"available_qualities": video.AvailableQualities,
 		"message":             statusMessage(video.Status),
 	}
+	// An import's source URL is not shown: it may carry a token.
+	if video.Import != nil {
+		state := importState(video)
+		status["import"] = gin.H{"kind": video.Import.Kind, "state": state}
+		if state == "fetching" {
+			status["message"] = "Video source is being fetched"
+		}
+	}
 	if video.ThumbnailPath != nil {
 		status["thumbnail"] = *video.ThumbnailPath
 	}
+	// The ETA is only meaningful while encoding; a row left over from a
+	// worker that died mid-job would otherwise count down forever.
+	if video.Status == domain.VideoStatusProcessing && video.TranscodingETA != nil {
+		status["eta"] = *video.TranscodingETA
+		status["eta_seconds"] = max(0, int(time.Until(*video.TranscodingETA).Seconds()))
+	}
+	// Why the source was rejected, for the uploader to fix and upload again.
+	if video.Status == domain.VideoStatusFailed && video.ProcessingError != nil {
+		status["error"] = video.ProcessingError
+	}
 
 	response.Success(c, http.StatusOK, status)
 }
@@ -273,6 +440,8 @@ func statusMessage(status domain.VideoStatus) string {
 		return "Video is being processed"
 	case domain.VideoStatusReady:
 		return "Video is ready to stream"
+	case domain.VideoStatusLive:
+		return "Video is streaming live"
 	case domain.VideoStatusFailed:
 		return "Video processing failed"
 	default:
@@ -15,6 +15,7 @@ import (
 	"net/http/httptest"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -144,16 +145,50 @@ func (r *stubVideoRepo) Delete(_ context.Context, id uuid.UUID) error {
 func (r *stubVideoRepo) UpdateStatus(_ context.Context, _ uuid.UUID, _ domain.VideoStatus) error {
 	return nil
 }
-func (r *stubVideoRepo) UpdateProgress(_ context.Context, _ uuid.UUID, _ int) error      { return nil }
+func (r *stubVideoRepo) UpdateProgress(_ context.Context, _ uuid.UUID, _ int, _ *time.Time) error {
+	return nil
+}
 func (r *stubVideoRepo) UpdateDuration(_ context.Context, _ uuid.UUID, _ int) error      { return nil }
 func (r *stubVideoRepo) UpdateResolution(_ context.Context, _ uuid.UUID, _ string) error { return nil }
-func (r *stubVideoRepo) UpdateHLSInfo(_ context.Context, _ uuid.UUID, _ string, _ bool) error {
+func (r *stubVideoRepo) UpdateSource(_ context.Context, _ uuid.UUID, _, _ string, _ int64) error {
+	return nil
+}
+func (r *stubVideoRepo) BeginTrim(_ context.Context, _ uuid.UUID, _ *domain.VideoTrim) error {
+	return nil
+}
+func (r *stubVideoRepo) RecordTrim(_ context.Context, _ uuid.UUID, _ string, _ int64, _ []domain.Chapter) error {
+	return nil
+}
+func (r *stubVideoRepo) FinishTrim(_ context.Context, _ uuid.UUID) error { return nil }
+func (r *stubVideoRepo) RevertTrim(_ context.Context, _ uuid.UUID) error { return nil }
+func (r *stubVideoRepo) UpdateHLSInfo(_ context.Context, _ uuid.UUID, _ string, _ int, _ bool, _ string) error {
 	return nil
 }
 func (r *stubVideoRepo) MarkAsReady(_ context.Context, _ uuid.UUID, _ []string, _ string) error {
 	return nil
 }
+func (r *stubVideoRepo) UpdatePreviewPath(_ context.Context, _ uuid.UUID, _ string) error {
+	return nil
+}
 func (r *stubVideoRepo) MarkAsFailed(_ context.Context, _ uuid.UUID) error { return nil }
+func (r *stubVideoRepo) UpdateLoudness(_ context.Context, _ uuid.UUID, _ *domain.AudioLoudness) error {
+	return nil
+}
+func (r *stubVideoRepo) MarkAsRejected(_ context.Context, _ uuid.UUID, _ domain.ProcessingError) error {
+	return nil
+}
+func (r *stubVideoRepo) GetEncodingLadder(_ context.Context, _ uuid.UUID) (*domain.EncodingLadder, error) {
+	return nil, nil
+}
+func (r *stubVideoRepo) UpdateEncodingLadder(_ context.Context, _ uuid.UUID, _ *domain.EncodingLadder) error {
+	return nil
+}
+func (r *stubVideoRepo) UpdateChapters(_ context.Context, _ uuid.UUID, _ []domain.Chapter) error {
+	return nil
+}
+func (r *stubVideoRepo) SetPremiere(_ context.Context, _ uuid.UUID, _ *time.Time) (bool, error) {
+	return true, nil
+}
 
 // nullStore is a storage.Store for code paths that must tolerate storage but
 // never depend on its contents (best-effort file cleanup after a delete).
@@ -178,7 +213,7 @@ func newTestVideoHandler(repo *stubVideoRepo) *VideoHandler {
 	log := testLog()
 	cfg := &config.Config{}
 	uploadSvc := service.NewUploadService(repo, service.NewFFmpegService(log), &cfg.Storage, nullStore{}, log)
-	return NewVideoHandler(uploadSvc, repo, nil, log, cfg)
+	return NewVideoHandler(uploadSvc, repo, nil, nil, log, cfg)
 }
 
 // videoRouter mounts the handler's read/delete routes, optionally behind an
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/pkg/response"
+)
+
+// statusStreamKeepAlive is how often an idle status stream sends a comment
+// line, so proxies do not time the connection out and a client that went away
+// is noticed. Each write gets twice this long before the server gives up on it.
+const statusStreamKeepAlive = 15 * time.Second
+
+// StreamVideoStatus streams a video's processing progress as server-sent
+// events, so an upload page can follow it without polling /status. Every event
+// is a "progress" event carrying a domain.VideoProgress; the first is the
+// stored state, and the stream ends after the event that reports the video
+// ready or failed.
+func (h *VideoHandler) StreamVideoStatus(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	video, ok := h.loadVideo(c)
+	if !ok {
+		return
+	}
+	if !canViewVideo(ctx, video) {
+		response.NotFound(c, "Video not found")
+		return
+	}
+
+	current := video.ProgressSnapshot()
+	if !current.Finished() {
+		sub, err := h.progressFeed.Subscribe(ctx, video.ID)
+		if err != nil {
+			h.log.Error(ctx, "failed to subscribe to video progress", err, map[string]interface{}{
+				"video_id": video.ID,
+			})
+			response.Error(c, http.StatusServiceUnavailable, "PROGRESS_UNAVAILABLE",
+				"Live progress is unavailable; poll the status endpoint instead")
+			return
+		}
+		defer sub.Close()
+
+		// Read the row again now that the subscription is live: an update
+		// that landed between the first read and subscribing would otherwise
+		// be missed, and if it was the last one the stream would never end.
+		if fresh, err := h.videoRepo.GetByID(ctx, video.ID); err == nil {
+			current = fresh.ProgressSnapshot()
+		}
+
+		h.streamProgress(c, current, sub.C)
+		return
+	}
+
+	h.streamProgress(c, current, nil)
+}
+
+// streamProgress writes current, then everything from updates, until the
+// video finishes, updates closes, or the client goes away.
+func (h *VideoHandler) streamProgress(c *gin.Context, current domain.VideoProgress, updates <-chan domain.VideoProgress) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	// nginx buffers proxied responses by default, which would hold events
+	// back until the buffer fills.
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	rc := http.NewResponseController(c.Writer)
+	write := func(emit func()) bool {
+		// The server's write timeout is sized for ordinary responses and
+		// would cut the stream off; each write gets its own deadline instead.
+		if err := rc.SetWriteDeadline(time.Now().Add(2 * statusStreamKeepAlive)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return false
+		}
+		emit()
+		return rc.Flush() == nil
+	}
+	send := func(p domain.VideoProgress) bool {
+		return write(func() { c.SSEvent("progress", p) }) && !p.Finished()
+	}
+
+	if !send(current) {
+		return
+	}
+
+	keepAlive := time.NewTicker(statusStreamKeepAlive)
+	defer keepAlive.Stop()
+
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case p, ok := <-updates:
+			if !ok || !send(p) {
+				return
+			}
+		case <-keepAlive.C:
+			if !write(func() { _, _ = c.Writer.WriteString(": keep-alive\n\n") }) {
+				return
+			}
+		}
+	}
+}
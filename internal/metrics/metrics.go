@@ -147,6 +147,21 @@ var (
 		[]string{"cache_type"},
 	)
 
+	cacheEvictions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_evictions_total",
+			Help: "Total entries evicted to keep a cache within its size",
+		},
+		[]string{"cache_type"},
+	)
+
+	segmentCacheBytes = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "segment_cache_bytes",
+			Help: "Bytes of media segments held in the local segment cache",
+		},
+	)
+
 	cacheOperationDuration = promauto.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name:    "cache_operation_duration_seconds",
@@ -302,6 +317,14 @@ func RecordCacheMiss(cacheType string) {
 	cacheMisses.WithLabelValues(cacheType).Inc()
 }
 
+func RecordCacheEviction(cacheType string) {
+	cacheEvictions.WithLabelValues(cacheType).Inc()
+}
+
+func SetSegmentCacheBytes(bytes int64) {
+	segmentCacheBytes.Set(float64(bytes))
+}
+
 func RecordCacheOperation(operation string, duration time.Duration) {
 	cacheOperationDuration.WithLabelValues(operation).Observe(duration.Seconds())
 }
@@ -76,14 +76,23 @@ func Logger(log *logger.Logger) gin.HandlerFunc {
 // corsExposedHeaders are the response headers scripts on another origin may
 // read. Without Content-Range, Accept-Ranges, and Content-Length a cross-origin
 // video player cannot see the result of its own Range requests, so seeking in
-// hls.js and the MP4 fallback silently breaks from any other origin.
-const corsExposedHeaders = "Content-Length, Content-Range, Accept-Ranges, X-Request-ID"
+// hls.js and the MP4 fallback silently breaks from any other origin. Likewise a
+// browser tus client is blind without Location and the Upload-* and Tus-*
+// headers: it cannot find the session it created or the offset to resume from.
+// A direct upload to the local store reads each part's ETag, which completion
+// needs.
+const corsExposedHeaders = "Content-Length, Content-Range, Accept-Ranges, X-Request-ID, " +
+	"Location, Upload-Offset, Upload-Length, Tus-Resumable, Tus-Version, Tus-Extension, Tus-Max-Size, X-Video-ID, ETag"
 
 // corsRequiredRequestHeaders are always allowed in preflight, whatever the
 // configured allowlist says: without Authorization, Content-Type, and Range the
 // API cannot be used cross-origin at all, and this API exists to be consumed
-// from other origins.
-var corsRequiredRequestHeaders = []string{"Authorization", "Content-Type", "Range"}
+// from other origins. The tus headers are in the same position for resumable
+// uploads.
+var corsRequiredRequestHeaders = []string{
+	"Authorization", "Content-Type", "Range",
+	"Tus-Resumable", "Upload-Length", "Upload-Offset", "Upload-Metadata",
+}
 
 // CORS applies the configured cross-origin policy. Preflight requests are
 // answered here, before routing, so every route — including ones that only
@@ -235,7 +235,7 @@ func TestCORS(t *testing.T) {
 				// Range is not in testCORSConfig's allowlist; it must be
 				// granted anyway or cross-origin seeking breaks.
 				allowHeaders := header.Get("Access-Control-Allow-Headers")
-				for _, name := range []string{"Authorization", "Content-Type", "Range"} {
+				for _, name := range []string{"Authorization", "Content-Type", "Range", "Tus-Resumable", "Upload-Offset"} {
 					if !strings.Contains(allowHeaders, name) {
 						t.Errorf("Access-Control-Allow-Headers = %q, want it to include %q", allowHeaders, name)
 					}
@@ -243,7 +243,7 @@ func TestCORS(t *testing.T) {
 				// Without these a cross-origin script cannot read the response
 				// headers Range requests depend on.
 				exposeHeaders := header.Get("Access-Control-Expose-Headers")
-				for _, name := range []string{"Content-Length", "Content-Range", "Accept-Ranges", "X-Request-ID"} {
+				for _, name := range []string{"Content-Length", "Content-Range", "Accept-Ranges", "X-Request-ID", "Location", "Upload-Offset", "ETag"} {
 					if !strings.Contains(exposeHeaders, name) {
 						t.Errorf("Access-Control-Expose-Headers = %q, want it to include %q", exposeHeaders, name)
 					}
@@ -41,9 +41,12 @@ var defaultRateLimits = map[string]RateLimitRule{
 	"user_api":    {Requests: 60, Window: time.Minute, BurstSize: 20},
 	"premium_api": {Requests: 300, Window: time.Minute, BurstSize: 50},
 	"upload":      {Requests: 5, Window: time.Hour, BurstSize: 2},
-	"search":      {Requests: 30, Window: time.Minute, BurstSize: 10},
-	"streaming":   {Requests: 300, Window: time.Minute, BurstSize: 100},
-	"auth":        {Requests: 10, Window: time.Minute, BurstSize: 5},
+	// Resumable upload chunks: one upload is hundreds of requests, so the
+	// "upload" budget applies to creating sessions and this one to the rest.
+	"upload_chunk": {Requests: 1200, Window: time.Hour, BurstSize: 100},
+	"search":       {Requests: 30, Window: time.Minute, BurstSize: 10},
+	"streaming":    {Requests: 300, Window: time.Minute, BurstSize: 100},
+	"auth":         {Requests: 10, Window: time.Minute, BurstSize: 5},
 }
 
 // RateLimiter enforces fixed-window request limits backed by Redis.
@@ -0,0 +1,178 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/internal/service"
+	"github.com/Nuu-maan/video-streaming-service/internal/storage"
+)
+
+// ProcessAudioTrackTask encodes a dubbed audio track and adds it to its
+// video's master playlist and DASH manifest. Those are shared by every track
+// and caption of the video, so they are rewritten under a lock held for the
+// whole job: with a remote store each job stages the playlists, rewrites them
+// and uploads them back, and two at once would each drop the other's work.
+func (h *VideoProcessingHandler) ProcessAudioTrackTask(ctx context.Context, task *asynq.Task) error {
+	payload, err := ParseAudioTrackProcessingPayload(task)
+	if err != nil {
+		h.logger.Error(ctx, "failed to parse audio track processing payload", err, map[string]interface{}{})
+		return fmt.Errorf("parse payload: %w", err)
+	}
+
+	h.logger.Info(ctx, "processing audio track task", map[string]interface{}{
+		"video_id": payload.VideoID,
+		"track_id": payload.TrackID,
+		"task_id":  task.ResultWriter().TaskID(),
+	})
+
+	videoID, err := uuid.Parse(payload.VideoID)
+	if err != nil {
+		return fmt.Errorf("invalid video ID: %w", err)
+	}
+	trackID, err := uuid.Parse(payload.TrackID)
+	if err != nil {
+		return fmt.Errorf("invalid track ID: %w", err)
+	}
+
+	unlock, err := h.lockPlaylists(ctx, videoID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	track, err := h.audioTracks.GetByID(ctx, trackID)
+	if errors.Is(err, domain.ErrAudioTrackNotFound) {
+		// Deleted with its video while queued.
+		h.logger.Warn(ctx, "audio track no longer exists", map[string]interface{}{
+			"video_id": payload.VideoID,
+			"track_id": payload.TrackID,
+		})
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("load audio track: %w", err)
+	}
+	// A ready track was finished by an earlier attempt; a failed one was
+	// refused by EncodeDub and another attempt would refuse it again.
+	if track.Status != domain.AudioTrackPending {
+		return nil
+	}
+
+	// The lock also keeps a reprocessed video from swapping its output for
+	// another under the job; see runPublish.
+	video, err := h.videoRepo.GetByID(ctx, videoID)
+	if err != nil {
+		return fmt.Errorf("load video: %w", err)
+	}
+	remote := storage.IsRemote(h.store)
+	hlsDir := filepath.Join(service.OutputPath(h.storageCfg.TranscodedPath, videoID, video.OutputRevision), "hls")
+	hlsKey := service.OutputKey(videoID, video.OutputRevision, "hls")
+
+	if remote {
+		if err := h.stageAudioTrackInputs(ctx, track, hlsDir, hlsKey); err != nil {
+			h.logger.Error(ctx, "failed to stage audio track inputs", err, map[string]interface{}{
+				"video_id": payload.VideoID,
+				"track_id": payload.TrackID,
+			})
+			return fmt.Errorf("stage audio track: %w", err)
+		}
+	}
+
+	if err := h.transcodingService.EncodeDub(ctx, track); err != nil {
+		h.logger.Error(ctx, "audio track encoding failed", err, map[string]interface{}{
+			"video_id": payload.VideoID,
+			"track_id": payload.TrackID,
+			"task_id":  task.ResultWriter().TaskID(),
+		})
+		return fmt.Errorf("encode audio track: %w", err)
+	}
+	if err := h.transcodingService.PublishDub(ctx, track); err != nil {
+		return fmt.Errorf("publish audio track: %w", err)
+	}
+
+	if remote {
+		// The rendition goes up before the playlists that point at it.
+		if err := h.uploadDir(ctx, filepath.Join(hlsDir, track.Name), storage.Key(hlsKey, track.Name)); err != nil {
+			return fmt.Errorf("upload audio track: %w", err)
+		}
+		for _, name := range []string{"master.m3u8", "manifest.mpd"} {
+			path := filepath.Join(hlsDir, name)
+			if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err := h.uploadFile(ctx, path, storage.Key(hlsKey, name)); err != nil {
+				return fmt.Errorf("upload playlists: %w", err)
+			}
+		}
+	}
+
+	if err := h.transcodingService.MarkDubReady(ctx, track); err != nil {
+		return err
+	}
+
+	h.evictPlaylists(ctx, videoID)
+
+	if remote {
+		h.removeLocalCopies(ctx, videoID, filepath.Dir(hlsDir), track.FilePath)
+	}
+
+	h.logger.Info(ctx, "audio track processing completed", map[string]interface{}{
+		"video_id": payload.VideoID,
+		"track_id": payload.TrackID,
+		"task_id":  task.ResultWriter().TaskID(),
+	})
+	return nil
+}
+
+// stageAudioTrackInputs fetches what a dub is encoded and published against:
+// its raw file and the video's current master playlist and DASH manifest. The
+// playlists are fetched afresh every time, since another dub may have changed
+// them since this worker last saw them.
+func (h *VideoProcessingHandler) stageAudioTrackInputs(ctx context.Context, track *domain.AudioTrack, hlsDir, hlsKey string) error {
+	if _, err := os.Stat(track.FilePath); err != nil {
+		if err := h.stageFile(ctx, service.DubKey(track.VideoID, filepath.Base(track.FilePath)), track.FilePath); err != nil {
+			return err
+		}
+	}
+
+	if err := h.stageFile(ctx, storage.Key(hlsKey, "master.m3u8"), filepath.Join(hlsDir, "master.m3u8")); err != nil {
+		return err
+	}
+
+	manifestKey := storage.Key(hlsKey, "manifest.mpd")
+	manifest := filepath.Join(hlsDir, "manifest.mpd")
+	exists, err := h.store.Exists(ctx, manifestKey)
+	if err != nil {
+		return fmt.Errorf("checking for DASH manifest: %w", err)
+	}
+	if !exists {
+		// A stale copy would be uploaded over nothing.
+		if err := os.Remove(manifest); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("removing stale DASH manifest: %w", err)
+		}
+		return nil
+	}
+	return h.stageFile(ctx, manifestKey, manifest)
+}
+
+// removeLocalCopies removes the working files of a job once they are
+// uploaded: directories and files alike. Removal is best-effort: a leftover
+// file wastes disk, but failing the task over it would re-run nothing useful.
+func (h *VideoProcessingHandler) removeLocalCopies(ctx context.Context, videoID uuid.UUID, paths ...string) {
+	for _, path := range paths {
+		if err := os.RemoveAll(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			h.logger.Warn(ctx, "could not remove local working copy", map[string]interface{}{
+				"video_id": videoID,
+				"error":    err.Error(),
+			})
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/internal/service"
+	"github.com/Nuu-maan/video-streaming-service/internal/storage"
+)
+
+// ProcessCaptionTask segments an uploaded caption and adds it to its video's
+// master playlist, under the same lock as a dub: see ProcessAudioTrackTask.
+func (h *VideoProcessingHandler) ProcessCaptionTask(ctx context.Context, task *asynq.Task) error {
+	payload, err := ParseCaptionProcessingPayload(task)
+	if err != nil {
+		h.logger.Error(ctx, "failed to parse caption processing payload", err, map[string]interface{}{})
+		return fmt.Errorf("parse payload: %w", err)
+	}
+
+	h.logger.Info(ctx, "processing caption task", map[string]interface{}{
+		"video_id":   payload.VideoID,
+		"caption_id": payload.CaptionID,
+		"task_id":    task.ResultWriter().TaskID(),
+	})
+
+	videoID, err := uuid.Parse(payload.VideoID)
+	if err != nil {
+		return fmt.Errorf("invalid video ID: %w", err)
+	}
+	captionID, err := uuid.Parse(payload.CaptionID)
+	if err != nil {
+		return fmt.Errorf("invalid caption ID: %w", err)
+	}
+
+	unlock, err := h.lockPlaylists(ctx, videoID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	caption, err := h.captions.GetByID(ctx, captionID)
+	if errors.Is(err, domain.ErrCaptionNotFound) {
+		// Deleted with its video while queued.
+		h.logger.Warn(ctx, "caption no longer exists", map[string]interface{}{
+			"video_id":   payload.VideoID,
+			"caption_id": payload.CaptionID,
+		})
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("load caption: %w", err)
+	}
+	if caption.Status != domain.CaptionPending {
+		return nil
+	}
+
+	// The lock also keeps a reprocessed video from swapping its output for
+	// another under the job; see runPublish.
+	video, err := h.videoRepo.GetByID(ctx, videoID)
+	if err != nil {
+		return fmt.Errorf("load video: %w", err)
+	}
+	remote := storage.IsRemote(h.store)
+	hlsDir := filepath.Join(service.OutputPath(h.storageCfg.TranscodedPath, videoID, video.OutputRevision), "hls")
+	hlsKey := service.OutputKey(videoID, video.OutputRevision, "hls")
+
+	if remote {
+		if err := h.stageCaptionInputs(ctx, caption, hlsDir, hlsKey); err != nil {
+			h.logger.Error(ctx, "failed to stage caption inputs", err, map[string]interface{}{
+				"video_id":   payload.VideoID,
+				"caption_id": payload.CaptionID,
+			})
+			return fmt.Errorf("stage caption: %w", err)
+		}
+	}
+
+	if err := h.transcodingService.PublishCaption(ctx, caption); err != nil {
+		h.logger.Error(ctx, "caption publishing failed", err, map[string]interface{}{
+			"video_id":   payload.VideoID,
+			"caption_id": payload.CaptionID,
+			"task_id":    task.ResultWriter().TaskID(),
+		})
+		return fmt.Errorf("publish caption: %w", err)
+	}
+
+	if remote {
+		// The rendition goes up before the playlist that points at it.
+		if err := h.uploadDir(ctx, filepath.Join(hlsDir, caption.Name), storage.Key(hlsKey, caption.Name)); err != nil {
+			return fmt.Errorf("upload caption: %w", err)
+		}
+		if err := h.uploadFile(ctx, filepath.Join(hlsDir, "master.m3u8"), storage.Key(hlsKey, "master.m3u8")); err != nil {
+			return fmt.Errorf("upload playlists: %w", err)
+		}
+	}
+
+	if err := h.transcodingService.MarkCaptionReady(ctx, caption); err != nil {
+		return err
+	}
+
+	h.evictPlaylists(ctx, videoID)
+
+	if remote {
+		h.removeLocalCopies(ctx, videoID, filepath.Dir(hlsDir), caption.FilePath)
+	}
+
+	h.logger.Info(ctx, "caption processing completed", map[string]interface{}{
+		"video_id":   payload.VideoID,
+		"caption_id": payload.CaptionID,
+		"task_id":    task.ResultWriter().TaskID(),
+	})
+	return nil
+}
+
+// stageCaptionInputs fetches what a caption is published against: its WebVTT
+// file, the video's current master playlist, and the playlist and first
+// segment of the video rendition it is timed against.
+func (h *VideoProcessingHandler) stageCaptionInputs(ctx context.Context, caption *domain.Caption, hlsDir, hlsKey string) error {
+	video, err := h.videoRepo.GetByID(ctx, caption.VideoID)
+	if err != nil {
+		return fmt.Errorf("loading video: %w", err)
+	}
+	if len(video.AvailableQualities) == 0 {
+		return fmt.Errorf("video %s has no renditions", video.ID)
+	}
+	rung := video.AvailableQualities[0]
+
+	if _, err := os.Stat(caption.FilePath); err != nil {
+		if err := h.stageFile(ctx, service.CaptionKey(caption.VideoID, filepath.Base(caption.FilePath)), caption.FilePath); err != nil {
+			return err
+		}
+	}
+
+	names := []string{"master.m3u8", filepath.Join(rung, "playlist.m3u8")}
+	exists, err := h.store.Exists(ctx, storage.Key(hlsKey, rung, "segment_000.ts"))
+	if err != nil {
+		return fmt.Errorf("checking for first segment: %w", err)
+	}
+	if exists {
+		names = append(names, filepath.Join(rung, "segment_000.ts"))
+	} else {
+		names = append(names, filepath.Join(rung, "init_"+rung+".mp4"), filepath.Join(rung, "segment_000.m4s"))
+	}
+	for _, name := range names {
+		if err := h.stageFile(ctx, storage.Key(hlsKey, filepath.ToSlash(name)), filepath.Join(hlsDir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
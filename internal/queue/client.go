@@ -2,9 +2,13 @@ package queue
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/Nuu-maan/video-streaming-service/internal/config"
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/internal/service"
 	"github.com/Nuu-maan/video-streaming-service/pkg/logger"
 	"github.com/hibiken/asynq"
 )
@@ -26,11 +30,13 @@ func (q *QueueClient) Close() error {
 	return q.client.Close()
 }
 
+// EnqueueVideoProcessing queues videoID for transcoding to the worker's whole
+// ladder. The ladder is the worker's configuration, so the API leaves
+// Qualities empty rather than guess at it.
 func (q *QueueClient) EnqueueVideoProcessing(ctx context.Context, videoID string, priority int) error {
 	payload := VideoProcessingPayload{
-		VideoID:   videoID,
-		Qualities: []string{"360p", "480p", "720p", "1080p"},
-		Priority:  priority,
+		VideoID:  videoID,
+		Priority: priority,
 	}
 
 	task, err := NewVideoProcessingTask(payload)
@@ -64,11 +70,382 @@ func (q *QueueClient) EnqueueVideoProcessing(ctx context.Context, videoID string
 	return nil
 }
 
+// stageTaskTimeouts bound one attempt at each kind of stage. An encode is one
+// rendition, so it gets what a whole video used to.
+var stageTaskTimeouts = map[domain.StageKind]time.Duration{
+	domain.StageValidate:   30 * time.Minute,
+	domain.StageProbe:      30 * time.Minute,
+	domain.StageLoudness:   30 * time.Minute,
+	domain.StageEncode:     1 * time.Hour,
+	domain.StagePackage:    30 * time.Minute,
+	domain.StageThumbnails: 30 * time.Minute,
+	domain.StagePublish:    10 * time.Minute,
+}
+
+// EnqueueVideoStage queues one stage of a video's processing, of kind, on the
+// queue its video was given.
+func (q *QueueClient) EnqueueVideoStage(ctx context.Context, payload VideoStagePayload, kind domain.StageKind) error {
+	task, err := NewVideoStageTask(payload)
+	if err != nil {
+		q.logger.Error(ctx, "failed to create video stage task", err, map[string]interface{}{
+			"video_id": payload.VideoID,
+			"stage":    payload.Stage,
+		})
+		return fmt.Errorf("failed to create task: %w", err)
+	}
+
+	info, err := q.client.EnqueueContext(ctx, task,
+		asynq.MaxRetry(3),
+		asynq.Timeout(stageTaskTimeouts[kind]),
+		asynq.Queue(getQueueName(payload.Priority)),
+	)
+	if err != nil {
+		q.logger.Error(ctx, "failed to enqueue video stage task", err, map[string]interface{}{
+			"video_id": payload.VideoID,
+			"stage":    payload.Stage,
+		})
+		return fmt.Errorf("failed to enqueue task: %w", err)
+	}
+
+	q.logger.Info(ctx, "video stage task enqueued", map[string]interface{}{
+		"video_id": payload.VideoID,
+		"stage":    payload.Stage,
+		"task_id":  info.ID,
+		"queue":    info.Queue,
+	})
+
+	return nil
+}
+
+// audioTrackTaskTimeout bounds one attempt at a dubbed track. An audio-only
+// encode is short, so it is far tighter than a video's.
+const audioTrackTaskTimeout = 15 * time.Minute
+
+// EnqueueAudioTrackProcessing queues a dubbed audio track for encoding, on the
+// default queue.
+func (q *QueueClient) EnqueueAudioTrackProcessing(ctx context.Context, videoID, trackID string) error {
+	task, err := NewAudioTrackProcessingTask(AudioTrackProcessingPayload{
+		VideoID: videoID,
+		TrackID: trackID,
+	})
+	if err != nil {
+		q.logger.Error(ctx, "failed to create audio track processing task", err, map[string]interface{}{
+			"video_id": videoID,
+			"track_id": trackID,
+		})
+		return fmt.Errorf("failed to create task: %w", err)
+	}
+
+	info, err := q.client.EnqueueContext(ctx, task,
+		asynq.MaxRetry(3),
+		asynq.Timeout(audioTrackTaskTimeout),
+		asynq.Queue(getQueueName(0)),
+	)
+	if err != nil {
+		q.logger.Error(ctx, "failed to enqueue audio track processing task", err, map[string]interface{}{
+			"video_id": videoID,
+			"track_id": trackID,
+		})
+		return fmt.Errorf("failed to enqueue task: %w", err)
+	}
+
+	q.logger.Info(ctx, "audio track processing task enqueued", map[string]interface{}{
+		"video_id": videoID,
+		"track_id": trackID,
+		"task_id":  info.ID,
+	})
+
+	return nil
+}
+
+// captionTaskTimeout bounds one attempt at an uploaded caption, which only
+// rewrites text files.
+const captionTaskTimeout = 5 * time.Minute
+
+// EnqueueCaptionProcessing queues an uploaded caption for publishing, on the
+// default queue.
+func (q *QueueClient) EnqueueCaptionProcessing(ctx context.Context, videoID, captionID string) error {
+	task, err := NewCaptionProcessingTask(CaptionProcessingPayload{
+		VideoID:   videoID,
+		CaptionID: captionID,
+	})
+	if err != nil {
+		q.logger.Error(ctx, "failed to create caption processing task", err, map[string]interface{}{
+			"video_id":   videoID,
+			"caption_id": captionID,
+		})
+		return fmt.Errorf("failed to create task: %w", err)
+	}
+
+	info, err := q.client.EnqueueContext(ctx, task,
+		asynq.MaxRetry(3),
+		asynq.Timeout(captionTaskTimeout),
+		asynq.Queue(getQueueName(0)),
+	)
+	if err != nil {
+		q.logger.Error(ctx, "failed to enqueue caption processing task", err, map[string]interface{}{
+			"video_id":   videoID,
+			"caption_id": captionID,
+		})
+		return fmt.Errorf("failed to enqueue task: %w", err)
+	}
+
+	q.logger.Info(ctx, "caption processing task enqueued", map[string]interface{}{
+		"video_id":   videoID,
+		"caption_id": captionID,
+		"task_id":    info.ID,
+	})
+
+	return nil
+}
+
+// thumbnailTaskTimeout bounds one attempt at an uploaded thumbnail, which is
+// one still image.
+const thumbnailTaskTimeout = 5 * time.Minute
+
+// EnqueueThumbnailProcessing queues an uploaded thumbnail for rendering, on
+// the default queue.
+func (q *QueueClient) EnqueueThumbnailProcessing(ctx context.Context, videoID, thumbnailID string) error {
+	task, err := NewThumbnailProcessingTask(ThumbnailProcessingPayload{
+		VideoID:     videoID,
+		ThumbnailID: thumbnailID,
+	})
+	if err != nil {
+		q.logger.Error(ctx, "failed to create thumbnail processing task", err, map[string]interface{}{
+			"video_id":     videoID,
+			"thumbnail_id": thumbnailID,
+		})
+		return fmt.Errorf("failed to create task: %w", err)
+	}
+
+	info, err := q.client.EnqueueContext(ctx, task,
+		asynq.MaxRetry(3),
+		asynq.Timeout(thumbnailTaskTimeout),
+		asynq.Queue(getQueueName(0)),
+	)
+	if err != nil {
+		q.logger.Error(ctx, "failed to enqueue thumbnail processing task", err, map[string]interface{}{
+			"video_id":     videoID,
+			"thumbnail_id": thumbnailID,
+		})
+		return fmt.Errorf("failed to enqueue task: %w", err)
+	}
+
+	q.logger.Info(ctx, "thumbnail processing task enqueued", map[string]interface{}{
+		"video_id":     videoID,
+		"thumbnail_id": thumbnailID,
+		"task_id":      info.ID,
+	})
+
+	return nil
+}
+
+// videoCutTaskTimeout bounds one attempt at a trim or clip. The cut copies
+// streams rather than encoding them, so it takes about as long as reading
+// and writing the source.
+const videoCutTaskTimeout = 30 * time.Minute
+
+// EnqueueVideoCut queues a trim or a clip to be cut from its source, on the
+// default queue. The cut queues the video's processing when it is done.
+func (q *QueueClient) EnqueueVideoCut(ctx context.Context, payload VideoCutPayload) error {
+	task, err := NewVideoCutTask(payload)
+	if err != nil {
+		q.logger.Error(ctx, "failed to create video cut task", err, map[string]interface{}{
+			"video_id":      payload.VideoID,
+			"from_video_id": payload.FromVideoID,
+		})
+		return fmt.Errorf("failed to create task: %w", err)
+	}
+
+	info, err := q.client.EnqueueContext(ctx, task,
+		asynq.MaxRetry(3),
+		asynq.Timeout(videoCutTaskTimeout),
+		asynq.Queue(getQueueName(0)),
+	)
+	if err != nil {
+		q.logger.Error(ctx, "failed to enqueue video cut task", err, map[string]interface{}{
+			"video_id":      payload.VideoID,
+			"from_video_id": payload.FromVideoID,
+		})
+		return fmt.Errorf("failed to enqueue task: %w", err)
+	}
+
+	q.logger.Info(ctx, "video cut task enqueued", map[string]interface{}{
+		"video_id":      payload.VideoID,
+		"from_video_id": payload.FromVideoID,
+		"task_id":       info.ID,
+	})
+
+	return nil
+}
+
+// videoImportTaskTimeout bounds one attempt at fetching an import. It leaves
+// room over the longest STORAGE_IMPORT_TIMEOUT the config accepts to store
+// the file once fetched.
+const videoImportTaskTimeout = 3 * time.Hour
+
+// EnqueueVideoImport queues an imported video's source to be fetched, on the
+// default queue. The fetch queues the video's processing when it is done.
+func (q *QueueClient) EnqueueVideoImport(ctx context.Context, videoID string) error {
+	task, err := NewVideoImportTask(VideoImportPayload{VideoID: videoID})
+	if err != nil {
+		q.logger.Error(ctx, "failed to create video import task", err, map[string]interface{}{
+			"video_id": videoID,
+		})
+		return fmt.Errorf("failed to create task: %w", err)
+	}
+
+	info, err := q.client.EnqueueContext(ctx, task,
+		asynq.MaxRetry(3),
+		asynq.Timeout(videoImportTaskTimeout),
+		asynq.Queue(getQueueName(0)),
+	)
+	if err != nil {
+		q.logger.Error(ctx, "failed to enqueue video import task", err, map[string]interface{}{
+			"video_id": videoID,
+		})
+		return fmt.Errorf("failed to enqueue task: %w", err)
+	}
+
+	q.logger.Info(ctx, "video import task enqueued", map[string]interface{}{
+		"video_id": videoID,
+		"task_id":  info.ID,
+	})
+
+	return nil
+}
+
+var _ service.ReprocessQueue = (*QueueClient)(nil)
+
+// reprocessPriority is the priority reprocessing runs at: on the low queue,
+// behind every upload.
+const reprocessPriority = -1
+
+// EnqueueVideoReprocess queues videoID to be processed again from scratch, on
+// the low queue, no sooner than processAt.
+func (q *QueueClient) EnqueueVideoReprocess(ctx context.Context, videoID string, processAt time.Time) error {
+	task, err := NewVideoProcessingTask(VideoProcessingPayload{
+		VideoID:   videoID,
+		Priority:  reprocessPriority,
+		Reprocess: true,
+	})
+	if err != nil {
+		q.logger.Error(ctx, "failed to create video reprocess task", err, map[string]interface{}{
+			"video_id": videoID,
+		})
+		return fmt.Errorf("failed to create task: %w", err)
+	}
+
+	info, err := q.client.EnqueueContext(ctx, task,
+		asynq.MaxRetry(3),
+		asynq.Timeout(1*time.Hour),
+		asynq.Queue(getQueueName(reprocessPriority)),
+		asynq.ProcessAt(processAt),
+	)
+	if err != nil {
+		q.logger.Error(ctx, "failed to enqueue video reprocess task", err, map[string]interface{}{
+			"video_id": videoID,
+		})
+		return fmt.Errorf("failed to enqueue task: %w", err)
+	}
+
+	q.logger.Info(ctx, "video reprocess task enqueued", map[string]interface{}{
+		"video_id":   videoID,
+		"task_id":    info.ID,
+		"process_at": processAt,
+	})
+
+	return nil
+}
+
+// EnqueueOutputRetirement queues a replaced output revision to be deleted
+// after delay, on the low queue.
+func (q *QueueClient) EnqueueOutputRetirement(ctx context.Context, payload OutputRetirePayload, delay time.Duration) error {
+	task, err := NewOutputRetireTask(payload)
+	if err != nil {
+		q.logger.Error(ctx, "failed to create output retire task", err, map[string]interface{}{
+			"video_id": payload.VideoID,
+			"revision": payload.Revision,
+		})
+		return fmt.Errorf("failed to create task: %w", err)
+	}
+
+	info, err := q.client.EnqueueContext(ctx, task,
+		asynq.MaxRetry(3),
+		asynq.Timeout(10*time.Minute),
+		asynq.Queue(getQueueName(-1)),
+		asynq.ProcessIn(delay),
+	)
+	if err != nil {
+		q.logger.Error(ctx, "failed to enqueue output retire task", err, map[string]interface{}{
+			"video_id": payload.VideoID,
+			"revision": payload.Revision,
+		})
+		return fmt.Errorf("failed to enqueue task: %w", err)
+	}
+
+	q.logger.Info(ctx, "output retire task enqueued", map[string]interface{}{
+		"video_id": payload.VideoID,
+		"revision": payload.Revision,
+		"task_id":  info.ID,
+		"delay":    delay.String(),
+	})
+
+	return nil
+}
+
+var _ service.LiveQueue = (*QueueClient)(nil)
+
+// liveIngestPriority puts a broadcast on the critical queue: its creator is
+// pushing already, and every second it waits is a second viewers miss.
+const liveIngestPriority = 2
+
+// liveFinishTimeout is the time a broadcast's task is given past its longest
+// duration to seal, upload and record what it transcoded.
+const liveFinishTimeout = 30 * time.Minute
+
+// EnqueueLiveIngest queues the transcoding of a broadcast that has gone on
+// air, for at most maxDuration. The task ID is the stream's, so a stream is
+// queued once however often this is called for it. It is retried once, to
+// finish what an attempt that died left behind.
+func (q *QueueClient) EnqueueLiveIngest(ctx context.Context, streamID string, maxDuration time.Duration) error {
+	task, err := NewLiveIngestTask(LiveIngestPayload{StreamID: streamID})
+	if err != nil {
+		q.logger.Error(ctx, "failed to create live ingest task", err, map[string]interface{}{
+			"stream_id": streamID,
+		})
+		return fmt.Errorf("failed to create task: %w", err)
+	}
+
+	info, err := q.client.EnqueueContext(ctx, task,
+		asynq.TaskID(TypeLiveIngest+":"+streamID),
+		asynq.MaxRetry(1),
+		asynq.Timeout(maxDuration+liveFinishTimeout),
+		asynq.Queue(getQueueName(liveIngestPriority)),
+	)
+	if errors.Is(err, asynq.ErrTaskIDConflict) {
+		return nil
+	}
+	if err != nil {
+		q.logger.Error(ctx, "failed to enqueue live ingest task", err, map[string]interface{}{
+			"stream_id": streamID,
+		})
+		return fmt.Errorf("failed to enqueue task: %w", err)
+	}
+
+	q.logger.Info(ctx, "live ingest task enqueued", map[string]interface{}{
+		"stream_id": streamID,
+		"task_id":   info.ID,
+	})
+
+	return nil
+}
+
 func getQueueName(priority int) string {
 	if priority >= 2 {
-		return "critical"
+		return config.TierCritical
 	} else if priority <= -1 {
-		return "low"
+		return config.TierLow
 	}
-	return "default"
+	return config.TierDefault
 }
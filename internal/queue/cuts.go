@@ -0,0 +1,242 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/internal/storage"
+)
+
+// ProcessCutTask cuts a trim or a clip from its source and queues the video
+// to be processed from the cut. A clip is processed as though it had been
+// uploaded that way. A trimmed video is reprocessed: it stays ready, serving
+// what it had until the trimmed output is published, and a trim that fails
+// for good, here or in processing, leaves it as it was; see abandonTrim. A
+// clip that cannot be cut is failed.
+func (h *VideoProcessingHandler) ProcessCutTask(ctx context.Context, task *asynq.Task) error {
+	payload, err := ParseVideoCutPayload(task)
+	if err != nil {
+		h.logger.Error(ctx, "failed to parse video cut payload", err, map[string]interface{}{})
+		return fmt.Errorf("parse payload: %w", err)
+	}
+
+	h.logger.Info(ctx, "processing video cut task", map[string]interface{}{
+		"video_id":      payload.VideoID,
+		"from_video_id": payload.FromVideoID,
+		"start":         payload.Start,
+		"end":           payload.End,
+		"task_id":       task.ResultWriter().TaskID(),
+	})
+
+	id, err := uuid.Parse(payload.VideoID)
+	if err != nil {
+		return fmt.Errorf("invalid video ID: %w", err)
+	}
+	fromID, err := uuid.Parse(payload.FromVideoID)
+	if err != nil {
+		return fmt.Errorf("invalid source video ID: %w", err)
+	}
+
+	video, err := h.videoRepo.GetByID(ctx, id)
+	if errors.Is(err, domain.ErrVideoNotFound) {
+		// Deleted while queued.
+		h.logger.Warn(ctx, "video no longer exists", map[string]interface{}{
+			"video_id": payload.VideoID,
+		})
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("load video: %w", err)
+	}
+	trim := fromID == id
+	if trim && (video.Trim == nil || video.Trim.FilePath != payload.SourcePath) {
+		// The trim was given up on, or is done.
+		h.logger.Warn(ctx, "video is not being trimmed from this source; dropping the cut", map[string]interface{}{
+			"video_id": payload.VideoID,
+		})
+		return nil
+	}
+	if video.FilePath != payload.SourcePath {
+		// An earlier attempt recorded the cut but failed to queue the
+		// processing that follows it.
+		if trim {
+			return h.queueClient.EnqueueVideoReprocess(ctx, payload.VideoID, time.Now())
+		}
+		if video.Status == domain.VideoStatusUploading {
+			return h.queueClient.EnqueueVideoProcessing(ctx, payload.VideoID, 0)
+		}
+		return nil
+	}
+
+	from := video
+	if fromID != id {
+		from, err = h.videoRepo.GetByID(ctx, fromID)
+		if errors.Is(err, domain.ErrVideoNotFound) {
+			h.logger.Warn(ctx, "clip's parent no longer exists", map[string]interface{}{
+				"video_id":  payload.VideoID,
+				"parent_id": payload.FromVideoID,
+			})
+			h.failCut(ctx, video)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("load parent video: %w", err)
+		}
+	}
+
+	if err := h.cut(ctx, video, from, payload); err != nil {
+		retried, _ := asynq.GetRetryCount(ctx)
+		maxRetry, _ := asynq.GetMaxRetry(ctx)
+		final := retried >= maxRetry
+		h.logger.Error(ctx, "video cut failed", err, map[string]interface{}{
+			"video_id":      payload.VideoID,
+			"from_video_id": payload.FromVideoID,
+			"final":         final,
+			"task_id":       task.ResultWriter().TaskID(),
+		})
+		if final && trim {
+			h.abandonTrim(ctx, id)
+		} else if final {
+			h.failCut(ctx, video)
+		}
+		return fmt.Errorf("cut video: %w", err)
+	}
+
+	h.logger.Info(ctx, "video cut completed", map[string]interface{}{
+		"video_id":      payload.VideoID,
+		"from_video_id": payload.FromVideoID,
+		"task_id":       task.ResultWriter().TaskID(),
+	})
+	return nil
+}
+
+// cut writes the span of from's source that payload names to a new raw file,
+// records it as video's source, and queues video for processing. A trimmed
+// video's old source stays until the trimmed output replaces what it made;
+// see replaceOutput.
+func (h *VideoProcessingHandler) cut(ctx context.Context, video, from *domain.Video, payload *VideoCutPayload) error {
+	source, cleanup, err := h.stageSource(ctx, from)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	// Named as an upload's raw file is, in the same container as the source
+	// it is copied from.
+	name := uuid.New().String() + strings.ToLower(filepath.Ext(from.FilePath))
+	key := storage.Key("raw", name)
+	filePath := filepath.Join(h.storageCfg.UploadPath, "raw", name)
+
+	remote := storage.IsRemote(h.store)
+	output := filePath
+	if remote {
+		dir, err := os.MkdirTemp("", "cut-"+video.ID.String()+"-")
+		if err != nil {
+			return fmt.Errorf("creating cut directory: %w", err)
+		}
+		defer h.removeLocalCopies(ctx, video.ID, dir)
+		output = filepath.Join(dir, name)
+	} else if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return fmt.Errorf("creating raw directory: %w", err)
+	}
+
+	span := domain.ClipRange{Start: payload.Start, End: payload.End}
+	size, err := h.transcodingService.CutSource(ctx, source, output, span)
+	if err != nil {
+		h.removeLocalCopies(ctx, video.ID, output)
+		return err
+	}
+	if remote {
+		if err := h.uploadFile(ctx, output, key); err != nil {
+			return fmt.Errorf("upload cut: %w", err)
+		}
+	}
+
+	if video.ID == from.ID {
+		// Chapters marked on the untrimmed timeline would start in the wrong
+		// place, or past the end.
+		chapters := domain.TrimChapters(video.Chapters, span)
+		if err := h.videoRepo.RecordTrim(ctx, video.ID, filePath, size, chapters); err != nil {
+			h.discardRaw(ctx, video.ID, key)
+			return fmt.Errorf("record cut: %w", err)
+		}
+		// From here a retry finds the cut recorded and only queues this
+		// again. Reprocessing writes a new output revision beside the one
+		// being served.
+		if err := h.queueClient.EnqueueVideoReprocess(ctx, video.ID.String(), time.Now()); err != nil {
+			return fmt.Errorf("queue processing: %w", err)
+		}
+		return nil
+	}
+
+	if err := h.videoRepo.UpdateSource(ctx, video.ID, filePath, from.MimeType, size); err != nil {
+		h.discardRaw(ctx, video.ID, key)
+		return fmt.Errorf("record cut: %w", err)
+	}
+	// From here a retry finds the cut recorded and only queues this again.
+	if err := h.queueClient.EnqueueVideoProcessing(ctx, video.ID.String(), 0); err != nil {
+		return fmt.Errorf("queue processing: %w", err)
+	}
+	return nil
+}
+
+// abandonTrim gives up on video id's trim once it has failed for good. The
+// video goes back to the source its published output was made from, with
+// the duration and chapters that go with it, and a cut recorded for the trim
+// is deleted.
+func (h *VideoProcessingHandler) abandonTrim(ctx context.Context, id uuid.UUID) {
+	video, err := h.videoRepo.GetByID(ctx, id)
+	if err != nil {
+		h.logger.Error(ctx, "failed to load video to abandon its trim", err, map[string]interface{}{
+			"video_id": id,
+		})
+		return
+	}
+	if video.Trim == nil {
+		return
+	}
+	if video.FilePath == video.Trim.FilePath {
+		err = h.videoRepo.FinishTrim(ctx, id)
+	} else if err = h.videoRepo.RevertTrim(ctx, id); err == nil {
+		h.discardRaw(ctx, id, storage.Key("raw", filepath.Base(video.FilePath)))
+	}
+	if err != nil {
+		h.logger.Error(ctx, "failed to abandon video trim", err, map[string]interface{}{
+			"video_id": id,
+		})
+		return
+	}
+	h.logger.Warn(ctx, "video trim failed; keeping the video as it was", map[string]interface{}{
+		"video_id": id,
+	})
+}
+
+// discardRaw deletes a raw file nothing refers to any longer: a trim's cut
+// that was given up on, or a cut or import that could not be recorded.
+func (h *VideoProcessingHandler) discardRaw(ctx context.Context, videoID uuid.UUID, key string) {
+	if err := h.store.Delete(ctx, key); err != nil {
+		h.logger.Warn(ctx, "could not delete unused source", map[string]interface{}{
+			"video_id": videoID,
+			"key":      key,
+			"error":    err.Error(),
+		})
+	}
+}
+
+// failCut fails a clip that will never have a source.
+func (h *VideoProcessingHandler) failCut(ctx context.Context, clip *domain.Video) {
+	if err := h.videoRepo.MarkAsFailed(ctx, clip.ID); err != nil {
+		h.logger.Error(ctx, "failed to mark clip as failed", err, map[string]interface{}{
+			"video_id": clip.ID,
+		})
+	}
+}
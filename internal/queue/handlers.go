@@ -11,7 +11,7 @@ import (
 	"strings"
 
 	"github.com/google/uuid"
-	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
 
 	"github.com/Nuu-maan/video-streaming-service/internal/config"
 	"github.com/Nuu-maan/video-streaming-service/internal/domain"
@@ -24,177 +24,84 @@ import (
 type VideoProcessingHandler struct {
 	transcodingService *service.TranscodingService
 	videoRepo          repository.VideoRepository
+	audioTracks        service.AudioTrackRepository
+	captions           service.CaptionRepository
+	thumbnails         service.ThumbnailRepository
+	stages             service.ProcessingStageRepository
+	liveStreams        service.LiveStreamRepository
 	store              storage.Store
 	storageCfg         *config.StorageConfig
+	workerCfg          *config.WorkerConfig
+	liveCfg            *config.LiveConfig
+	redis              *redis.Client
+	queueClient        *QueueClient
 	logger             *logger.Logger
 }
 
 func NewVideoProcessingHandler(
 	transcodingService *service.TranscodingService,
 	videoRepo repository.VideoRepository,
+	audioTracks service.AudioTrackRepository,
+	captions service.CaptionRepository,
+	thumbnails service.ThumbnailRepository,
+	stages service.ProcessingStageRepository,
+	liveStreams service.LiveStreamRepository,
 	store storage.Store,
 	storageCfg *config.StorageConfig,
+	workerCfg *config.WorkerConfig,
+	liveCfg *config.LiveConfig,
+	redisClient *redis.Client,
+	queueClient *QueueClient,
 	logger *logger.Logger,
 ) *VideoProcessingHandler {
 	return &VideoProcessingHandler{
 		transcodingService: transcodingService,
 		videoRepo:          videoRepo,
+		audioTracks:        audioTracks,
+		captions:           captions,
+		thumbnails:         thumbnails,
+		stages:             stages,
+		liveStreams:        liveStreams,
 		store:              store,
 		storageCfg:         storageCfg,
+		workerCfg:          workerCfg,
+		liveCfg:            liveCfg,
+		redis:              redisClient,
+		queueClient:        queueClient,
 		logger:             logger,
 	}
 }
 
-// ProcessTask transcodes one video. ffmpeg reads and writes plain files, so
-// transcoding always happens against the local paths in StorageConfig; when
-// the store is remote, the raw file is staged from it first and the finished
-// renditions are uploaded back afterwards. With a local store neither transfer
-// happens — the working files already are the served files.
-func (h *VideoProcessingHandler) ProcessTask(ctx context.Context, task *asynq.Task) error {
-	payload, err := ParseVideoProcessingPayload(task)
-	if err != nil {
-		h.logger.Error(ctx, "failed to parse video processing payload", err, map[string]interface{}{})
-		return fmt.Errorf("parse payload: %w", err)
-	}
-
-	h.logger.Info(ctx, "processing video task", map[string]interface{}{
-		"video_id":  payload.VideoID,
-		"qualities": payload.Qualities,
-		"priority":  payload.Priority,
-		"task_id":   task.ResultWriter().TaskID(),
-	})
-
-	id, err := uuid.Parse(payload.VideoID)
-	if err != nil {
-		return fmt.Errorf("invalid video ID: %w", err)
-	}
-
-	remote := storage.IsRemote(h.store)
-
-	var video *domain.Video
-	if remote {
-		video, err = h.videoRepo.GetByID(ctx, id)
-		if err != nil {
-			h.logger.Error(ctx, "failed to load video", err, map[string]interface{}{
-				"video_id": payload.VideoID,
-			})
-			return fmt.Errorf("load video: %w", err)
-		}
-	}
-
-	// A retried task can find the video already transcoded because a previous
-	// attempt failed while uploading the outputs. ProcessVideo refuses a
-	// "ready" video, so resume at the upload step instead of failing forever.
-	if !remote || video.Status != domain.VideoStatusReady {
-		if remote {
-			if err := h.stageRawFile(ctx, video); err != nil {
-				h.logger.Error(ctx, "failed to stage raw video", err, map[string]interface{}{
-					"video_id": payload.VideoID,
-				})
-				return fmt.Errorf("stage raw video: %w", err)
-			}
-		}
-
-		if err := h.transcodingService.ProcessVideo(ctx, payload.VideoID); err != nil {
-			h.logger.Error(ctx, "video processing failed", err, map[string]interface{}{
-				"video_id": payload.VideoID,
-				"task_id":  task.ResultWriter().TaskID(),
-			})
-			return fmt.Errorf("process video: %w", err)
-		}
-	}
-
-	h.normalizeThumbnailPath(ctx, id)
-
-	if remote {
-		if err := h.syncOutputsToStore(ctx, video); err != nil {
-			h.logger.Error(ctx, "failed to upload transcoded outputs", err, map[string]interface{}{
-				"video_id": payload.VideoID,
-				"task_id":  task.ResultWriter().TaskID(),
-			})
-			return fmt.Errorf("upload outputs: %w", err)
-		}
-	}
-
-	h.logger.Info(ctx, "video processing completed", map[string]interface{}{
-		"video_id": payload.VideoID,
-		"task_id":  task.ResultWriter().TaskID(),
-	})
-
-	return nil
-}
-
-// stageRawFile materializes the raw object at the local FilePath recorded at
-// upload time, which is where ProcessVideo points ffmpeg. Already-present
-// files are reused so a retry does not re-download gigabytes.
-func (h *VideoProcessingHandler) stageRawFile(ctx context.Context, video *domain.Video) error {
-	if _, err := os.Stat(video.FilePath); err == nil {
-		return nil
-	}
-
-	key := storage.Key("raw", filepath.Base(video.FilePath))
+// stageFile downloads the object at key to path, replacing whatever is there.
+func (h *VideoProcessingHandler) stageFile(ctx context.Context, key, path string) error {
 	obj, err := h.store.Open(ctx, key)
 	if err != nil {
-		return fmt.Errorf("opening raw object %s: %w", key, err)
+		return fmt.Errorf("opening object %s: %w", key, err)
 	}
 	defer obj.Close()
 
-	if err := os.MkdirAll(filepath.Dir(video.FilePath), 0o755); err != nil {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return fmt.Errorf("creating staging directory: %w", err)
 	}
 
-	dest, err := os.Create(video.FilePath)
+	dest, err := os.Create(path)
 	if err != nil {
 		return fmt.Errorf("creating staging file: %w", err)
 	}
 	if _, err := io.Copy(dest, obj); err != nil {
 		dest.Close()
-		os.Remove(video.FilePath)
-		return fmt.Errorf("staging raw video: %w", err)
+		os.Remove(path)
+		return fmt.Errorf("staging %s: %w", key, err)
 	}
 	// Close before any Remove: Windows cannot delete an open file, and a
 	// deferred Close would swallow the flush error.
 	if err := dest.Close(); err != nil {
-		os.Remove(video.FilePath)
-		return fmt.Errorf("flushing staged raw video: %w", err)
+		os.Remove(path)
+		return fmt.Errorf("flushing staged %s: %w", key, err)
 	}
 	return nil
 }
 
-// syncOutputsToStore uploads everything the transcoder produced, then removes
-// the local working copies. Removal is best-effort: a leftover file wastes
-// disk, but failing the task over it would re-run nothing useful.
-func (h *VideoProcessingHandler) syncOutputsToStore(ctx context.Context, video *domain.Video) error {
-	videoID := video.ID.String()
-
-	outputDir := filepath.Join(h.storageCfg.TranscodedPath, videoID)
-	if err := h.uploadDir(ctx, outputDir, storage.Key("transcoded", videoID)); err != nil {
-		return err
-	}
-
-	thumbnail := filepath.Join(h.storageCfg.ThumbnailPath, videoID+".jpg")
-	if _, err := os.Stat(thumbnail); err == nil {
-		if err := h.uploadFile(ctx, thumbnail, storage.Key("thumbnails", videoID+".jpg")); err != nil {
-			return err
-		}
-	}
-
-	for _, remove := range []func() error{
-		func() error { return os.RemoveAll(outputDir) },
-		func() error { return os.Remove(thumbnail) },
-		func() error { return os.Remove(video.FilePath) },
-	} {
-		if err := remove(); err != nil && !errors.Is(err, os.ErrNotExist) {
-			h.logger.Warn(ctx, "could not remove local working copy", map[string]interface{}{
-				"video_id": videoID,
-				"error":    err.Error(),
-			})
-		}
-	}
-
-	return nil
-}
-
 func (h *VideoProcessingHandler) uploadDir(ctx context.Context, dir, keyPrefix string) error {
 	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
 		return nil
@@ -240,19 +147,27 @@ func contentTypeForFile(name string) string {
 	switch strings.ToLower(filepath.Ext(name)) {
 	case ".m3u8":
 		return "application/vnd.apple.mpegurl"
+	case ".mpd":
+		return "application/dash+xml"
 	case ".ts":
 		return "video/MP2T"
+	case ".m4s":
+		return "video/iso.segment"
 	case ".mp4":
 		return "video/mp4"
+	case ".vtt":
+		return "text/vtt"
 	case ".jpg", ".jpeg":
 		return "image/jpeg"
+	case ".webp":
+		return "image/webp"
 	default:
 		return "application/octet-stream"
 	}
 }
 
 // normalizeThumbnailPath rewrites the thumbnail path as a forward-slash key.
-// ProcessVideo records it with filepath.Join, so on Windows the database held
+// The worker used to record it with filepath.Join, so on Windows the database held
 // "thumbnails\<id>.jpg" and the backslash leaked into every video JSON
 // response. On Unix the path already uses "/" and nothing is written.
 func (h *VideoProcessingHandler) normalizeThumbnailPath(ctx context.Context, id uuid.UUID) {
@@ -0,0 +1,144 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/internal/storage"
+)
+
+// ProcessImportTask fetches an imported video's source into storage and
+// queues the video to be processed from it, as though it had been uploaded.
+// A source at fault fails the video at once with the reason, since fetching
+// it again would find the same; a source that could not be reached is
+// retried, and fails the video once the retries are spent.
+func (h *VideoProcessingHandler) ProcessImportTask(ctx context.Context, task *asynq.Task) error {
+	payload, err := ParseVideoImportPayload(task)
+	if err != nil {
+		h.logger.Error(ctx, "failed to parse video import payload", err, map[string]interface{}{})
+		return fmt.Errorf("parse payload: %w", err)
+	}
+
+	h.logger.Info(ctx, "processing video import task", map[string]interface{}{
+		"video_id": payload.VideoID,
+		"task_id":  task.ResultWriter().TaskID(),
+	})
+
+	id, err := uuid.Parse(payload.VideoID)
+	if err != nil {
+		return fmt.Errorf("invalid video ID: %w", err)
+	}
+
+	video, err := h.videoRepo.GetByID(ctx, id)
+	if errors.Is(err, domain.ErrVideoNotFound) {
+		// Deleted while queued.
+		h.logger.Warn(ctx, "video no longer exists", map[string]interface{}{
+			"video_id": payload.VideoID,
+		})
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("load video: %w", err)
+	}
+	if video.Import == nil {
+		h.logger.Warn(ctx, "video has no import source", map[string]interface{}{
+			"video_id": payload.VideoID,
+		})
+		return nil
+	}
+	if video.FilePath != "" {
+		// An earlier attempt recorded the source but failed to queue the
+		// processing that follows it.
+		if video.Status == domain.VideoStatusUploading {
+			return h.queueClient.EnqueueVideoProcessing(ctx, payload.VideoID, 0)
+		}
+		return nil
+	}
+	if video.Status == domain.VideoStatusFailed {
+		// An admin retrying an import that failed: it is fetching again.
+		if err := h.videoRepo.UpdateStatus(ctx, id, domain.VideoStatusUploading); err != nil {
+			return fmt.Errorf("reset import status: %w", err)
+		}
+	}
+
+	if err := h.importSource(ctx, video); err != nil {
+		retried, _ := asynq.GetRetryCount(ctx)
+		maxRetry, _ := asynq.GetMaxRetry(ctx)
+		_, rejected := domain.SourceRejection(err)
+		final := rejected || retried >= maxRetry
+		h.logger.Error(ctx, "video import failed", err, map[string]interface{}{
+			"video_id": payload.VideoID,
+			"kind":     video.Import.Kind,
+			"final":    final,
+			"task_id":  task.ResultWriter().TaskID(),
+		})
+		if final {
+			h.transcodingService.FailImport(ctx, id, err)
+			return fmt.Errorf("import video: %w: %w", err, asynq.SkipRetry)
+		}
+		return fmt.Errorf("import video: %w", err)
+	}
+
+	h.logger.Info(ctx, "video import completed", map[string]interface{}{
+		"video_id": payload.VideoID,
+		"task_id":  task.ResultWriter().TaskID(),
+	})
+	return nil
+}
+
+// importSource fetches video's source to a new raw file, records it as the
+// video's source, and queues the video for processing.
+func (h *VideoProcessingHandler) importSource(ctx context.Context, video *domain.Video) error {
+	// A local store keeps raw files in the raw directory, so the source is
+	// fetched there and renamed into place; a remote one gets a copy of it.
+	rawDir := filepath.Join(h.storageCfg.UploadPath, "raw")
+	remote := storage.IsRemote(h.store)
+	dir := rawDir
+	if remote {
+		tmp, err := os.MkdirTemp("", "import-"+video.ID.String()+"-")
+		if err != nil {
+			return fmt.Errorf("creating import directory: %w", err)
+		}
+		defer h.removeLocalCopies(ctx, video.ID, tmp)
+		dir = tmp
+	} else if err := os.MkdirAll(rawDir, 0o755); err != nil {
+		return fmt.Errorf("creating raw directory: %w", err)
+	}
+
+	fetched, err := h.transcodingService.FetchImport(ctx, video, dir)
+	if err != nil {
+		return err
+	}
+
+	// Named as an upload's raw file is, after the container it turned out
+	// to be.
+	name := uuid.New().String() + fetched.Ext
+	key := storage.Key("raw", name)
+	filePath := filepath.Join(rawDir, name)
+	if remote {
+		if err := h.uploadFile(ctx, fetched.Path, key); err != nil {
+			return fmt.Errorf("upload import: %w", err)
+		}
+	} else if err := os.Rename(fetched.Path, filePath); err != nil {
+		h.removeLocalCopies(ctx, video.ID, fetched.Path)
+		return fmt.Errorf("storing import: %w", err)
+	}
+
+	if err := h.videoRepo.UpdateSource(ctx, video.ID, filePath, fetched.MIMEType, fetched.Size); err != nil {
+		h.discardRaw(ctx, video.ID, key)
+		return fmt.Errorf("record import: %w", err)
+	}
+
+	// From here a retry finds the source recorded and only queues this again.
+	if err := h.queueClient.EnqueueVideoProcessing(ctx, video.ID.String(), 0); err != nil {
+		return fmt.Errorf("queue processing: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,407 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/config"
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/internal/service"
+	"github.com/Nuu-maan/video-streaming-service/internal/storage"
+)
+
+const (
+	// liveSyncInterval is how often a broadcast's new segments are uploaded
+	// to a remote store, and checked for going on air. It is well under a
+	// segment's length, so viewers see each one soon after it is encoded.
+	liveSyncInterval = time.Second
+	// liveFinishBudget bounds sealing and recording a broadcast once its push
+	// stops. It runs apart from the task's context, so a worker shutting
+	// down mid-broadcast still gets the chance to.
+	liveFinishBudget = 10 * time.Minute
+)
+
+// broadcast is a live stream being transcoded by this worker.
+type broadcast struct {
+	stream *domain.LiveStream
+	video  *domain.Video
+	rungs  []config.Rendition
+	remote bool
+	// outputDir holds revision 0 of the video's output, which the broadcast
+	// is transcoded into, and hlsKey is where that is in the store.
+	outputDir string
+	hlsDir    string
+	hlsKey    string
+	// recording is where the push is copied to, and rawPath what the
+	// video's source is recorded as once it is: the same file with a local
+	// store, and the key it is uploaded to with a remote one.
+	recording string
+	rawPath   string
+	// uploaded holds the keys of the segments already in a remote store.
+	uploaded map[string]bool
+	onAir    bool
+}
+
+// ProcessLiveIngestTask transcodes a live stream's push into its video's
+// HLS output for as long as the push lasts, putting the video on air as soon
+// as every rendition has a segment. When the push stops the playlists are
+// sealed, the video is ready as it was broadcast, and the recording of the
+// push becomes its source and is queued to be processed again into the full
+// ladder, replacing the broadcast's output as any reprocessing does.
+//
+// A push cannot be resumed into the output an attempt that died left behind,
+// so a retry, or an attempt that finds the video already on air, only
+// finishes what was stored.
+func (h *VideoProcessingHandler) ProcessLiveIngestTask(ctx context.Context, task *asynq.Task) error {
+	payload, err := ParseLiveIngestPayload(task)
+	if err != nil {
+		h.logger.Error(ctx, "failed to parse live ingest payload", err, map[string]interface{}{})
+		return fmt.Errorf("parse payload: %w", err)
+	}
+
+	h.logger.Info(ctx, "processing live ingest task", map[string]interface{}{
+		"stream_id": payload.StreamID,
+		"task_id":   task.ResultWriter().TaskID(),
+	})
+
+	id, err := uuid.Parse(payload.StreamID)
+	if err != nil {
+		return fmt.Errorf("invalid stream ID: %w", err)
+	}
+
+	stream, err := h.liveStreams.GetByID(ctx, id)
+	if errors.Is(err, domain.ErrLiveStreamNotFound) {
+		// Deleted with its video while queued.
+		h.logger.Warn(ctx, "live stream no longer exists", map[string]interface{}{
+			"stream_id": payload.StreamID,
+		})
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("load live stream: %w", err)
+	}
+	if stream.Status == domain.LiveStreamEnded {
+		return nil
+	}
+	video, err := h.videoRepo.GetByID(ctx, stream.VideoID)
+	if errors.Is(err, domain.ErrVideoNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("load video: %w", err)
+	}
+
+	b := h.newBroadcast(stream, video)
+	retried, _ := asynq.GetRetryCount(ctx)
+	if video.HLSReady || retried > 0 {
+		h.logger.Warn(ctx, "live stream was being transcoded by an attempt that died; finishing what it stored", map[string]interface{}{
+			"stream_id": payload.StreamID,
+			"video_id":  video.ID,
+		})
+		if err := h.resumeBroadcast(ctx, b); err != nil {
+			return fmt.Errorf("resume broadcast: %w", err)
+		}
+	} else if err := h.runBroadcast(ctx, b); err != nil {
+		// The push ending badly ends the broadcast all the same: what was
+		// transcoded of it is finished below.
+		h.logger.Warn(ctx, "live transcode stopped with an error", map[string]interface{}{
+			"stream_id": payload.StreamID,
+			"video_id":  video.ID,
+			"error":     err.Error(),
+		})
+	}
+
+	finishCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), liveFinishBudget)
+	defer cancel()
+	if err := h.finishBroadcast(finishCtx, b); err != nil {
+		h.logger.Error(ctx, "failed to finish live stream", err, map[string]interface{}{
+			"stream_id": payload.StreamID,
+			"video_id":  video.ID,
+			"task_id":   task.ResultWriter().TaskID(),
+		})
+		return fmt.Errorf("finish broadcast: %w", err)
+	}
+
+	h.logger.Info(ctx, "live stream completed", map[string]interface{}{
+		"stream_id": payload.StreamID,
+		"video_id":  video.ID,
+		"task_id":   task.ResultWriter().TaskID(),
+	})
+	return nil
+}
+
+func (h *VideoProcessingHandler) newBroadcast(stream *domain.LiveStream, video *domain.Video) *broadcast {
+	outputDir := service.OutputPath(h.storageCfg.TranscodedPath, video.ID, 0)
+	rawPath := filepath.Join(h.storageCfg.UploadPath, "raw", stream.RecordingName())
+	b := &broadcast{
+		stream:    stream,
+		video:     video,
+		rungs:     h.liveCfg.Ladder,
+		remote:    storage.IsRemote(h.store),
+		outputDir: outputDir,
+		hlsDir:    filepath.Join(outputDir, "hls"),
+		hlsKey:    service.OutputKey(video.ID, 0, "hls"),
+		recording: rawPath,
+		rawPath:   rawPath,
+		uploaded:  make(map[string]bool),
+		onAir:     video.HLSReady,
+	}
+	if b.remote {
+		// Beside the output, so that it is removed with it.
+		b.recording = filepath.Join(outputDir, stream.RecordingName())
+	}
+	return b
+}
+
+// runBroadcast transcodes the push until it stops, syncing what is encoded
+// as it goes.
+func (h *VideoProcessingHandler) runBroadcast(ctx context.Context, b *broadcast) error {
+	password := service.LiveReadPassword(h.liveCfg.IngestSecret, b.stream.ID)
+	input, err := service.LivePullURL(h.liveCfg.PullURL, b.stream.IngestPath(), password)
+	if err != nil {
+		return err
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(liveSyncInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			if err := h.syncBroadcast(ctx, b); err != nil {
+				h.logger.Warn(ctx, "failed to sync live stream; trying again", map[string]interface{}{
+					"stream_id": b.stream.ID,
+					"video_id":  b.video.ID,
+					"error":     err.Error(),
+				})
+			}
+		}
+	}()
+
+	err = h.transcodingService.BroadcastLive(ctx, input, b.hlsDir, b.recording, b.rungs, h.liveCfg.MaxDuration)
+	close(stop)
+	<-done
+	return err
+}
+
+// syncBroadcast uploads the segments encoded since it last ran to a remote
+// store, each rendition's before the playlist listing them, and puts the
+// video on air once every rendition has something to play.
+func (h *VideoProcessingHandler) syncBroadcast(ctx context.Context, b *broadcast) error {
+	if b.remote {
+		for _, rung := range b.rungs {
+			dir := filepath.Join(b.hlsDir, rung.Name)
+			playlist, err := service.ReadLivePlaylist(dir)
+			if err != nil {
+				return err
+			}
+			if len(playlist.Segments) == 0 {
+				continue
+			}
+			for _, segment := range playlist.Segments {
+				key := storage.Key(b.hlsKey, rung.Name, segment)
+				if b.uploaded[key] {
+					continue
+				}
+				if err := h.uploadFile(ctx, filepath.Join(dir, segment), key); err != nil {
+					return err
+				}
+				b.uploaded[key] = true
+			}
+			key := storage.Key(b.hlsKey, rung.Name, "playlist.m3u8")
+			if err := h.store.Save(ctx, key, bytes.NewReader(playlist.Content), int64(len(playlist.Content)), contentTypeForFile(key)); err != nil {
+				return fmt.Errorf("uploading %s: %w", key, err)
+			}
+		}
+	}
+
+	if b.onAir || !service.LiveOnAir(b.hlsDir, b.rungs) {
+		return nil
+	}
+	return h.goLive(ctx, b, b.rungs)
+}
+
+// goLive writes the master playlist listing rungs and puts the video on air.
+func (h *VideoProcessingHandler) goLive(ctx context.Context, b *broadcast, rungs []config.Rendition) error {
+	if err := service.WriteLiveMaster(b.hlsDir, rungs); err != nil {
+		return err
+	}
+	masterKey := storage.Key(b.hlsKey, "master.m3u8")
+	if b.remote {
+		if err := h.uploadFile(ctx, filepath.Join(b.hlsDir, "master.m3u8"), masterKey); err != nil {
+			return err
+		}
+	}
+	if err := h.transcodingService.GoLive(ctx, b.video.ID, masterKey); err != nil {
+		return err
+	}
+	b.onAir = true
+	return nil
+}
+
+// resumeBroadcast prepares to finish a broadcast an earlier attempt
+// transcoded. With a remote store, what it uploaded is what there is: each
+// rendition's stored playlist is taken as uploaded, and stands in for a
+// local one this worker does not have.
+func (h *VideoProcessingHandler) resumeBroadcast(ctx context.Context, b *broadcast) error {
+	if !b.remote {
+		return nil
+	}
+	for _, rung := range b.rungs {
+		dir := filepath.Join(b.hlsDir, rung.Name)
+		local := filepath.Join(dir, "playlist.m3u8")
+		key := storage.Key(b.hlsKey, rung.Name, "playlist.m3u8")
+		content, err := h.readStored(ctx, key)
+		if errors.Is(err, domain.ErrStorageObjectNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		stored, err := service.ParseLivePlaylist(content)
+		if err != nil {
+			return err
+		}
+		for _, segment := range stored.Segments {
+			b.uploaded[storage.Key(b.hlsKey, rung.Name, segment)] = true
+		}
+		if _, err := os.Stat(local); err == nil {
+			continue
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("creating rendition directory: %w", err)
+		}
+		if err := os.WriteFile(local, content, 0o644); err != nil {
+			return fmt.Errorf("staging %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// readStored reads a whole object of the store; it is only for playlists.
+func (h *VideoProcessingHandler) readStored(ctx context.Context, key string) ([]byte, error) {
+	obj, err := h.store.Open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(obj); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", key, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// finishBroadcast ends a broadcast whose push has stopped. Its playlists are
+// sealed and the video marked ready with the renditions that have segments,
+// or failed when none has. The recording, if there is one, becomes the
+// video's source and the video is queued to be processed again from it.
+func (h *VideoProcessingHandler) finishBroadcast(ctx context.Context, b *broadcast) error {
+	if err := h.syncBroadcast(ctx, b); err != nil {
+		return fmt.Errorf("sync broadcast: %w", err)
+	}
+	qualities, duration, err := service.SealLivePlaylists(b.hlsDir, b.rungs)
+	if err != nil {
+		return err
+	}
+
+	if len(qualities) == 0 {
+		h.logger.Warn(ctx, "live stream ended with nothing transcoded", map[string]interface{}{
+			"stream_id": b.stream.ID,
+			"video_id":  b.video.ID,
+		})
+		if err := h.liveStreams.End(ctx, b.stream, nil); err != nil {
+			return err
+		}
+		h.transcodingService.FailLive(ctx, b.video.ID)
+		h.removeLocalCopies(ctx, b.video.ID, b.outputDir, b.recording)
+		return nil
+	}
+
+	var live []config.Rendition
+	for _, rung := range b.rungs {
+		for _, q := range qualities {
+			if rung.Name == q {
+				live = append(live, rung)
+			}
+		}
+	}
+	if !b.onAir {
+		// Over before every rendition had a segment; it goes on air with
+		// those that have one, on its way to being ready.
+		if err := h.goLive(ctx, b, live); err != nil {
+			return err
+		}
+	}
+	for _, rung := range live {
+		dir := filepath.Join(b.hlsDir, rung.Name)
+		// Written only to build the master playlist from; see readVariant.
+		os.Remove(filepath.Join(dir, "variant.m3u8"))
+		if b.remote {
+			if err := h.uploadFile(ctx, filepath.Join(dir, "playlist.m3u8"), storage.Key(b.hlsKey, rung.Name, "playlist.m3u8")); err != nil {
+				return err
+			}
+		}
+	}
+	if err := h.transcodingService.FinishLive(ctx, b.video.ID, qualities, duration); err != nil {
+		return err
+	}
+
+	recording, err := h.storeRecording(ctx, b)
+	if err != nil {
+		return err
+	}
+	if err := h.liveStreams.End(ctx, b.stream, recording); err != nil {
+		return err
+	}
+	if recording != nil {
+		if err := h.queueClient.EnqueueVideoReprocess(ctx, b.video.ID.String(), time.Now()); err != nil {
+			h.logger.Error(ctx, "failed to queue live stream for processing; it keeps its broadcast renditions", err, map[string]interface{}{
+				"stream_id": b.stream.ID,
+				"video_id":  b.video.ID,
+			})
+		}
+	}
+	if b.remote {
+		h.removeLocalCopies(ctx, b.video.ID, b.outputDir)
+	}
+	return nil
+}
+
+// storeRecording returns the broadcast's recording as the video's source,
+// uploaded first to a remote store. It is nil when this worker has none,
+// which leaves the video with the renditions it was broadcast in.
+func (h *VideoProcessingHandler) storeRecording(ctx context.Context, b *broadcast) (*service.LiveRecording, error) {
+	info, err := os.Stat(b.recording)
+	if err != nil || info.Size() == 0 {
+		h.logger.Warn(ctx, "live stream has no recording; it keeps its broadcast renditions", map[string]interface{}{
+			"stream_id": b.stream.ID,
+			"video_id":  b.video.ID,
+		})
+		return nil, nil
+	}
+	if b.remote {
+		if err := h.uploadFile(ctx, b.recording, storage.Key("raw", filepath.Base(b.rawPath))); err != nil {
+			return nil, fmt.Errorf("upload recording: %w", err)
+		}
+	}
+	return &service.LiveRecording{
+		Path:     b.rawPath,
+		MIMEType: service.LiveRecordingMIMEType,
+		Size:     info.Size(),
+	}, nil
+}
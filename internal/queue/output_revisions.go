@@ -0,0 +1,127 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/internal/service"
+	"github.com/Nuu-maan/video-streaming-service/internal/storage"
+)
+
+// replaceOutput follows the publishing of a new output revision for a video
+// that was already published, given as it was before: the dubs and uploaded
+// captions published into its old output are queued to be published into the
+// new one, and the old output to be deleted once WorkerConfig.OutputRetention
+// has passed. A trim whose output this is is done, and the source the old
+// output was made from goes with it. The new output is being served whatever
+// happens here, so failures are only logged.
+func (h *VideoProcessingHandler) replaceOutput(ctx context.Context, old *domain.Video) {
+	videoID := old.ID.String()
+
+	dubs, captions, err := h.transcodingService.RequeueAddedTracks(ctx, old.ID)
+	if err != nil {
+		h.logger.Error(ctx, "failed to requeue dubs and captions for the new output", err, map[string]interface{}{
+			"video_id": videoID,
+		})
+	}
+	for _, dub := range dubs {
+		if err := h.queueClient.EnqueueAudioTrackProcessing(ctx, videoID, dub.ID.String()); err != nil {
+			h.logger.Error(ctx, "failed to queue dub for the new output", err, map[string]interface{}{
+				"video_id": videoID,
+				"track_id": dub.ID,
+			})
+		}
+	}
+	for _, caption := range captions {
+		if err := h.queueClient.EnqueueCaptionProcessing(ctx, videoID, caption.ID.String()); err != nil {
+			h.logger.Error(ctx, "failed to queue caption for the new output", err, map[string]interface{}{
+				"video_id":   videoID,
+				"caption_id": caption.ID,
+			})
+		}
+	}
+
+	retired := OutputRetirePayload{
+		VideoID:   videoID,
+		Revision:  old.OutputRevision,
+		Qualities: old.AvailableQualities,
+	}
+	// A trim's cut is recorded before its output is made; one that is not
+	// was asked for since, and is still to come.
+	if old.Trim != nil && old.FilePath != old.Trim.FilePath {
+		if err := h.videoRepo.FinishTrim(ctx, old.ID); err != nil {
+			h.logger.Error(ctx, "failed to finish video trim; its old source is left in storage", err, map[string]interface{}{
+				"video_id": videoID,
+			})
+		} else {
+			retired.Source = old.Trim.FilePath
+		}
+	}
+	if err := h.queueClient.EnqueueOutputRetirement(ctx, retired, h.workerCfg.OutputRetention); err != nil {
+		h.logger.Error(ctx, "failed to queue the replaced output for deletion; it is left in storage", err, map[string]interface{}{
+			"video_id": videoID,
+			"revision": old.OutputRevision,
+		})
+	}
+}
+
+// ProcessOutputRetireTask deletes an output revision another has replaced,
+// and the source it was made from if a trim replaced that. The revision a
+// video is being served from, and the source it is processed from, are
+// never deleted, whatever the task says.
+func (h *VideoProcessingHandler) ProcessOutputRetireTask(ctx context.Context, task *asynq.Task) error {
+	payload, err := ParseOutputRetirePayload(task)
+	if err != nil {
+		h.logger.Error(ctx, "failed to parse output retire payload", err, map[string]interface{}{})
+		return fmt.Errorf("parse payload: %w", err)
+	}
+
+	id, err := uuid.Parse(payload.VideoID)
+	if err != nil {
+		return fmt.Errorf("invalid video ID: %w", err)
+	}
+
+	video, err := h.videoRepo.GetByID(ctx, id)
+	if errors.Is(err, domain.ErrVideoNotFound) {
+		// Deleted since, and its output with it.
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("load video: %w", err)
+	}
+	if video.OutputRevision == payload.Revision {
+		h.logger.Warn(ctx, "not retiring the output revision being served", map[string]interface{}{
+			"video_id": payload.VideoID,
+			"revision": payload.Revision,
+		})
+		return nil
+	}
+
+	prefixes, keys := service.RetiredOutput(id, payload.Revision, payload.Qualities)
+	for _, prefix := range prefixes {
+		if err := h.store.DeletePrefix(ctx, prefix); err != nil {
+			return fmt.Errorf("delete %s: %w", prefix, err)
+		}
+	}
+	if payload.Source != "" && payload.Source != video.FilePath {
+		keys = append(keys, storage.Key("raw", filepath.Base(payload.Source)))
+	}
+	for _, key := range keys {
+		if err := h.store.Delete(ctx, key); err != nil {
+			return fmt.Errorf("delete %s: %w", key, err)
+		}
+	}
+
+	h.logger.Info(ctx, "retired output revision", map[string]interface{}{
+		"video_id": payload.VideoID,
+		"revision": payload.Revision,
+		"task_id":  task.ResultWriter().TaskID(),
+	})
+	return nil
+}
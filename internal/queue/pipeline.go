@@ -0,0 +1,519 @@
+package queue
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/internal/service"
+	"github.com/Nuu-maan/video-streaming-service/internal/storage"
+)
+
+// stageStaleAfter is how long a queued or running stage may go without a
+// word before resuming its video assumes its task is lost. It is well past
+// the longest stage timeout, so a stage still running is never run twice.
+const stageStaleAfter = 2 * time.Hour
+
+// ProcessTask begins, or resumes, processing one video: it readies the
+// video's stages and queues those that can run. The stages do the work, each
+// as a video:stage task of its own; see ProcessStageTask.
+func (h *VideoProcessingHandler) ProcessTask(ctx context.Context, task *asynq.Task) error {
+	payload, err := ParseVideoProcessingPayload(task)
+	if err != nil {
+		h.logger.Error(ctx, "failed to parse video processing payload", err, map[string]interface{}{})
+		return fmt.Errorf("parse payload: %w", err)
+	}
+
+	h.logger.Info(ctx, "processing video task", map[string]interface{}{
+		"video_id":  payload.VideoID,
+		"qualities": payload.Qualities,
+		"priority":  payload.Priority,
+		"reprocess": payload.Reprocess,
+		"task_id":   task.ResultWriter().TaskID(),
+	})
+
+	id, err := uuid.Parse(payload.VideoID)
+	if err != nil {
+		return fmt.Errorf("invalid video ID: %w", err)
+	}
+
+	pending, err := h.transcodingService.BeginProcessing(ctx, id, time.Now().Add(-stageStaleAfter), payload.Reprocess)
+	if errors.Is(err, domain.ErrVideoNotFound) {
+		// Deleted while queued.
+		h.logger.Warn(ctx, "video no longer exists", map[string]interface{}{
+			"video_id": payload.VideoID,
+		})
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("begin processing: %w", err)
+	}
+	if !pending {
+		return nil
+	}
+
+	return h.scheduleStages(ctx, id, VideoStagePayload{
+		VideoID:   payload.VideoID,
+		Qualities: payload.Qualities,
+		Priority:  payload.Priority,
+	})
+}
+
+// ProcessStageTask runs one stage of a video's processing, then queues the
+// stages that were waiting only on it. A stage that fails is retried by asynq
+// on its own; the stages already done are not repeated.
+//
+// ffmpeg reads and writes plain files, so every stage works against the local
+// paths in StorageConfig. With a remote store, a stage fetches what it reads
+// and uploads what it writes, so the stages of one video can run on as many
+// workers as there are; with a local store the working files already are the
+// served files.
+func (h *VideoProcessingHandler) ProcessStageTask(ctx context.Context, task *asynq.Task) error {
+	payload, err := ParseVideoStagePayload(task)
+	if err != nil {
+		h.logger.Error(ctx, "failed to parse video stage payload", err, map[string]interface{}{})
+		return fmt.Errorf("parse payload: %w", err)
+	}
+
+	id, err := uuid.Parse(payload.VideoID)
+	if err != nil {
+		return fmt.Errorf("invalid video ID: %w", err)
+	}
+
+	stage, err := h.stages.Start(ctx, id, payload.Stage)
+	if errors.Is(err, domain.ErrStageNotFound) {
+		// The video was deleted, or restarted, while the stage was queued.
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("start stage: %w", err)
+	}
+	switch stage.Status {
+	case domain.StageRunning:
+	case domain.StageDone:
+		// An attempt finished the stage but failed to queue what follows.
+		return h.scheduleStages(ctx, id, *payload)
+	default:
+		return nil
+	}
+
+	h.logger.Info(ctx, "processing video stage", map[string]interface{}{
+		"video_id": payload.VideoID,
+		"stage":    stage.Name,
+		"attempt":  stage.Attempts,
+		"task_id":  task.ResultWriter().TaskID(),
+	})
+
+	video, err := h.videoRepo.GetByID(ctx, id)
+	if errors.Is(err, domain.ErrVideoNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("load video: %w", err)
+	}
+
+	if err := h.runStage(ctx, video, stage, payload); err != nil {
+		retried, _ := asynq.GetRetryCount(ctx)
+		maxRetry, _ := asynq.GetMaxRetry(ctx)
+		// A rejected source stays rejected however often it is retried.
+		_, rejected := domain.SourceRejection(err)
+		final := rejected || retried >= maxRetry
+		h.logger.Error(ctx, "video stage failed", err, map[string]interface{}{
+			"video_id": payload.VideoID,
+			"stage":    stage.Name,
+			"final":    final,
+			"task_id":  task.ResultWriter().TaskID(),
+		})
+		h.transcodingService.FailStage(ctx, stage, err, final)
+		if final && video.Trim != nil {
+			h.abandonTrim(ctx, video.ID)
+		}
+		if rejected {
+			return fmt.Errorf("stage %s: %w: %w", stage.Name, err, asynq.SkipRetry)
+		}
+		return fmt.Errorf("stage %s: %w", stage.Name, err)
+	}
+
+	return h.scheduleStages(ctx, id, *payload)
+}
+
+// scheduleStages queues a task for each of the video's stages that is ready
+// to run. A stage whose task could not be queued is released, for the retry
+// of the caller's task to claim again.
+func (h *VideoProcessingHandler) scheduleStages(ctx context.Context, id uuid.UUID, payload VideoStagePayload) error {
+	ready, err := h.stages.ClaimReady(ctx, id)
+	if err != nil {
+		return fmt.Errorf("claim stages: %w", err)
+	}
+	for _, stage := range ready {
+		payload.Stage = stage.Name
+		if err := h.queueClient.EnqueueVideoStage(ctx, payload, stage.Kind); err != nil {
+			if releaseErr := h.stages.Release(ctx, id, stage.Name); releaseErr != nil {
+				h.logger.Error(ctx, "failed to release stage", releaseErr, map[string]interface{}{
+					"video_id": id,
+					"stage":    stage.Name,
+				})
+			}
+			return fmt.Errorf("queue stage %s: %w", stage.Name, err)
+		}
+	}
+	return nil
+}
+
+// runStage does the work of stage and records it done.
+func (h *VideoProcessingHandler) runStage(ctx context.Context, video *domain.Video, stage *domain.ProcessingStage, payload *VideoStagePayload) error {
+	switch stage.Kind {
+	case domain.StageValidate:
+		return h.runValidate(ctx, video, stage)
+	case domain.StageProbe:
+		return h.runProbe(ctx, video, stage, payload.Qualities, getQueueName(payload.Priority))
+	case domain.StageLoudness:
+		return h.runLoudness(ctx, video, stage)
+	case domain.StageEncode:
+		return h.runEncode(ctx, video, stage)
+	case domain.StageThumbnails:
+		return h.runThumbnails(ctx, video, stage)
+	case domain.StagePackage:
+		return h.runPackage(ctx, video, stage)
+	case domain.StagePublish:
+		return h.runPublish(ctx, video, stage)
+	default:
+		return fmt.Errorf("unknown stage kind %q", stage.Kind)
+	}
+}
+
+func (h *VideoProcessingHandler) runValidate(ctx context.Context, video *domain.Video, stage *domain.ProcessingStage) error {
+	source, cleanup, err := h.stageSource(ctx, video)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if err := h.transcodingService.ValidateSource(ctx, video, source); err != nil {
+		return err
+	}
+	return h.transcodingService.FinishStage(ctx, stage, nil)
+}
+
+func (h *VideoProcessingHandler) runProbe(ctx context.Context, video *domain.Video, stage *domain.ProcessingStage, qualities []string, tier string) error {
+	source, cleanup, err := h.stageSource(ctx, video)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	probe, err := h.transcodingService.Probe(ctx, video, source, qualities, tier)
+	if err != nil {
+		return err
+	}
+	// A new revision starts empty, whatever an earlier attempt at it
+	// uploaded. The one being served is never touched.
+	if storage.IsRemote(h.store) && probe.Revision != video.OutputRevision {
+		if err := h.store.DeletePrefix(ctx, service.OutputKey(video.ID, probe.Revision)); err != nil {
+			return fmt.Errorf("clear output revision: %w", err)
+		}
+	}
+	return h.transcodingService.FinishProbe(ctx, stage, probe)
+}
+
+func (h *VideoProcessingHandler) runLoudness(ctx context.Context, video *domain.Video, stage *domain.ProcessingStage) error {
+	source, cleanup, err := h.stageSource(ctx, video)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	result, err := h.transcodingService.MeasureLoudness(ctx, video, source)
+	if err != nil {
+		return err
+	}
+	return h.transcodingService.FinishStage(ctx, stage, result)
+}
+
+func (h *VideoProcessingHandler) runEncode(ctx context.Context, video *domain.Video, stage *domain.ProcessingStage) error {
+	source, cleanup, err := h.stageSource(ctx, video)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	result, err := h.transcodingService.EncodeRendition(ctx, video, stage, source)
+	if err != nil {
+		return err
+	}
+	probe, err := h.transcodingService.ProbeResult(ctx, video.ID)
+	if err != nil {
+		return err
+	}
+
+	remote := storage.IsRemote(h.store)
+	dir := filepath.Join(service.OutputPath(h.storageCfg.TranscodedPath, video.ID, probe.Revision), "hls", stage.Rendition())
+	if remote {
+		if err := h.uploadDir(ctx, dir, service.OutputKey(video.ID, probe.Revision, "hls", stage.Rendition())); err != nil {
+			return fmt.Errorf("upload rendition: %w", err)
+		}
+	}
+	if err := h.transcodingService.FinishStage(ctx, stage, result); err != nil {
+		return err
+	}
+	if remote {
+		h.removeLocalCopies(ctx, video.ID, dir)
+	}
+	return nil
+}
+
+func (h *VideoProcessingHandler) runThumbnails(ctx context.Context, video *domain.Video, stage *domain.ProcessingStage) error {
+	source, cleanup, err := h.stageSource(ctx, video)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	result, err := h.transcodingService.RenderImages(ctx, video, source)
+	if err != nil {
+		return err
+	}
+	probe, err := h.transcodingService.ProbeResult(ctx, video.ID)
+	if err != nil {
+		return err
+	}
+
+	remote := storage.IsRemote(h.store)
+	videoID := video.ID.String()
+	thumbnailDir := filepath.Join(h.storageCfg.ThumbnailPath, videoID)
+	trickplayDir := filepath.Join(service.OutputPath(h.storageCfg.TranscodedPath, video.ID, probe.Revision), service.TrickplayDir)
+	if remote {
+		if err := h.uploadDir(ctx, thumbnailDir, storage.Key("thumbnails", videoID)); err != nil {
+			return fmt.Errorf("upload thumbnails: %w", err)
+		}
+		if err := h.uploadDir(ctx, trickplayDir, service.OutputKey(video.ID, probe.Revision, service.TrickplayDir)); err != nil {
+			return fmt.Errorf("upload trickplay: %w", err)
+		}
+	}
+	if err := h.transcodingService.FinishStage(ctx, stage, result); err != nil {
+		return err
+	}
+	if remote {
+		h.removeLocalCopies(ctx, video.ID, thumbnailDir, trickplayDir)
+	}
+	return nil
+}
+
+// runPackage writes the playlists over the encoded renditions. With a remote
+// store the renditions are fetched back first, since the master playlist,
+// the DASH manifest and the progressive MP4s are all made from them, and the
+// master playlist is uploaded last: until it is, players see none of what it
+// names.
+func (h *VideoProcessingHandler) runPackage(ctx context.Context, video *domain.Video, stage *domain.ProcessingStage) error {
+	probe, err := h.transcodingService.ProbeResult(ctx, video.ID)
+	if err != nil {
+		return err
+	}
+
+	// The source is only read for its subtitles, which may be gigabytes of
+	// download for nothing.
+	var source string
+	if len(probe.Metadata.SubtitleStreams) > 0 {
+		path, cleanup, err := h.stageSource(ctx, video)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		source = path
+	}
+
+	remote := storage.IsRemote(h.store)
+	hlsDir := filepath.Join(service.OutputPath(h.storageCfg.TranscodedPath, video.ID, probe.Revision), "hls")
+	hlsKey := service.OutputKey(video.ID, probe.Revision, "hls")
+	if remote {
+		for _, name := range probe.Renditions() {
+			if err := h.stageRendition(ctx, hlsKey, hlsDir, name); err != nil {
+				return fmt.Errorf("stage rendition %s: %w", name, err)
+			}
+		}
+	}
+
+	result, err := h.transcodingService.Package(ctx, video, source)
+	if err != nil {
+		return err
+	}
+
+	outputDir := filepath.Dir(hlsDir)
+	var mp4s []string
+	for _, quality := range result.Qualities {
+		mp4s = append(mp4s, filepath.Join(outputDir, quality+".mp4"))
+	}
+	if remote {
+		entries, err := os.ReadDir(hlsDir)
+		if err != nil {
+			return fmt.Errorf("reading HLS directory: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() && strings.HasPrefix(entry.Name(), "subs_") {
+				if err := h.uploadDir(ctx, filepath.Join(hlsDir, entry.Name()), storage.Key(hlsKey, entry.Name())); err != nil {
+					return fmt.Errorf("upload captions: %w", err)
+				}
+			}
+		}
+		for _, path := range mp4s {
+			if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err := h.uploadFile(ctx, path, service.OutputKey(video.ID, probe.Revision, filepath.Base(path))); err != nil {
+				return fmt.Errorf("upload progressive MP4: %w", err)
+			}
+		}
+		for _, name := range []string{"manifest.mpd", "master.m3u8"} {
+			path := filepath.Join(hlsDir, name)
+			if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err := h.uploadFile(ctx, path, storage.Key(hlsKey, name)); err != nil {
+				return fmt.Errorf("upload playlists: %w", err)
+			}
+		}
+	}
+
+	if err := h.transcodingService.FinishStage(ctx, stage, result); err != nil {
+		return err
+	}
+	if remote {
+		h.removeLocalCopies(ctx, video.ID, append(mp4s, hlsDir)...)
+	}
+	return nil
+}
+
+// runPublish records the video ready. A video that was already published
+// swaps its output for the new revision, under the playlist lock: dub and
+// caption jobs write into the output being served, so the swap waits for any
+// that are running, and those that follow see the new output. What was
+// published into the old output is then queued to be published into the new,
+// and the old output to be deleted; see replaceOutput.
+func (h *VideoProcessingHandler) runPublish(ctx context.Context, video *domain.Video, stage *domain.ProcessingStage) error {
+	probe, err := h.transcodingService.ProbeResult(ctx, video.ID)
+	if err != nil {
+		return err
+	}
+	unlock, err := h.lockPlaylists(ctx, video.ID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := h.transcodingService.Publish(ctx, video); err != nil {
+		return err
+	}
+	h.normalizeThumbnailPath(ctx, video.ID)
+	// A reprocessed video may be cached with its old playlists.
+	h.evictPlaylists(ctx, video.ID)
+	if video.HLSReady && video.OutputRevision != probe.Revision {
+		h.replaceOutput(ctx, video)
+	}
+
+	if err := h.transcodingService.FinishStage(ctx, stage, nil); err != nil {
+		return err
+	}
+	if storage.IsRemote(h.store) {
+		// Whatever the stages on this worker left behind, and the raw file
+		// if the upload wrote one here.
+		videoID := video.ID.String()
+		h.removeLocalCopies(ctx, video.ID,
+			filepath.Join(h.storageCfg.TranscodedPath, videoID),
+			filepath.Join(h.storageCfg.ThumbnailPath, videoID),
+			video.FilePath,
+		)
+	}
+	return nil
+}
+
+// stageSource returns the path of video's raw file for a stage to read, and
+// a function that removes it once the stage is done. With a local store it is
+// the file recorded at upload, which stays. With a remote store each stage
+// downloads a private copy: stages of one video run side by side, and one
+// finishing must not remove the file from under the others.
+func (h *VideoProcessingHandler) stageSource(ctx context.Context, video *domain.Video) (string, func(), error) {
+	if !storage.IsRemote(h.store) {
+		return video.FilePath, func() {}, nil
+	}
+
+	dir, err := os.MkdirTemp("", "source-"+video.ID.String()+"-")
+	if err != nil {
+		return "", nil, fmt.Errorf("creating source directory: %w", err)
+	}
+	cleanup := func() {
+		h.removeLocalCopies(ctx, video.ID, dir)
+	}
+
+	name := filepath.Base(video.FilePath)
+	path := filepath.Join(dir, name)
+	if err := h.stageFile(ctx, storage.Key("raw", name), path); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("stage raw video: %w", err)
+	}
+	return path, cleanup, nil
+}
+
+// stageRendition fetches rendition name of a video from hlsKey into hlsDir:
+// its playlist, then every file the playlist names.
+func (h *VideoProcessingHandler) stageRendition(ctx context.Context, hlsKey, hlsDir, name string) error {
+	dir := filepath.Join(hlsDir, name)
+	playlist := filepath.Join(dir, "playlist.m3u8")
+	if err := h.stageFile(ctx, storage.Key(hlsKey, name, "playlist.m3u8"), playlist); err != nil {
+		return err
+	}
+
+	files, err := playlistFiles(playlist)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := h.stageFile(ctx, storage.Key(hlsKey, name, file), filepath.Join(dir, file)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// playlistFiles lists the files a media playlist names: its init segment, if
+// any, and its segments. The encoder only ever names files beside the
+// playlist, so anything else is refused rather than fetched.
+func playlistFiles(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var files []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		uri := line
+		if rest, ok := strings.CutPrefix(line, "#EXT-X-MAP:"); ok {
+			_, after, found := strings.Cut(rest, `URI="`)
+			if !found {
+				continue
+			}
+			uri, _, _ = strings.Cut(after, `"`)
+		} else if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.Contains(uri, "/") || strings.Contains(uri, `\`) || strings.Contains(uri, "..") {
+			return nil, fmt.Errorf("playlist %s names %q, outside its directory", path, uri)
+		}
+		files = append(files, uri)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return files, nil
+}
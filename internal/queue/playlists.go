@@ -0,0 +1,80 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/cache"
+)
+
+const (
+	playlistLockKeyPrefix = "video:playlist-lock:"
+	// playlistLockPoll is how often a job waiting on another job of the same
+	// video checks the lock again.
+	playlistLockPoll = 500 * time.Millisecond
+	// playlistLockTTL is the timeout of the longest job that takes the lock.
+	playlistLockTTL = audioTrackTaskTimeout
+)
+
+// releasePlaylistLockScript deletes the lock only if it still holds the
+// caller's token, so a job that outlived its lock cannot release the next
+// holder's.
+var releasePlaylistLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// lockPlaylists waits for the lock on a video's shared playlists, which dub
+// and caption jobs rewrite. It expires with the jobs' timeout, so a crashed
+// worker cannot hold it past the point its own attempt would have been
+// abandoned.
+func (h *VideoProcessingHandler) lockPlaylists(ctx context.Context, videoID uuid.UUID) (unlock func(), err error) {
+	key := playlistLockKeyPrefix + videoID.String()
+	token := uuid.NewString()
+
+	for {
+		acquired, err := h.redis.SetNX(ctx, key, token, playlistLockTTL).Result()
+		if err != nil {
+			return nil, fmt.Errorf("locking playlists: %w", err)
+		}
+		if acquired {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for playlist lock: %w", ctx.Err())
+		case <-time.After(playlistLockPoll):
+		}
+	}
+
+	return func() {
+		releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if err := releasePlaylistLockScript.Run(releaseCtx, h.redis, []string{key}, token).Err(); err != nil {
+			h.logger.Warn(ctx, "failed to release playlist lock", map[string]interface{}{
+				"video_id": videoID,
+				"error":    err.Error(),
+			})
+		}
+	}, nil
+}
+
+// evictPlaylists drops the cached copies of the playlists a job rewrote. A
+// failure only delays the change until the entries expire.
+func (h *VideoProcessingHandler) evictPlaylists(ctx context.Context, videoID uuid.UUID) {
+	keys := []string{
+		cache.PlaylistKey(videoID, cache.MasterPlaylist),
+		cache.PlaylistKey(videoID, cache.DASHManifest),
+	}
+	if err := h.redis.Del(ctx, keys...).Err(); err != nil {
+		h.logger.Warn(ctx, "failed to evict cached playlists", map[string]interface{}{
+			"video_id": videoID,
+			"error":    err.Error(),
+		})
+	}
+}
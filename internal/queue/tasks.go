@@ -10,9 +10,13 @@ import (
 const TypeVideoProcessing = "video:process"
 
 type VideoProcessingPayload struct {
-	VideoID   string   `json:"video_id"`
+	VideoID string `json:"video_id"`
+	// Qualities names the ladder rungs to encode; empty means all of them.
 	Qualities []string `json:"qualities"`
 	Priority  int      `json:"priority"`
+	// Reprocess processes the video again from scratch, even when it is
+	// ready; see service.TranscodingService.BeginProcessing.
+	Reprocess bool `json:"reprocess,omitempty"`
 }
 
 func NewVideoProcessingTask(payload VideoProcessingPayload) (*asynq.Task, error) {
@@ -30,3 +34,213 @@ func ParseVideoProcessingPayload(task *asynq.Task) (*VideoProcessingPayload, err
 	}
 	return &payload, nil
 }
+
+const TypeVideoStage = "video:stage"
+
+// VideoStagePayload names one stage of a video's processing to run. Qualities
+// and Priority are carried over from the video:process task that began it:
+// the probe reads Qualities, and every stage queues the next at Priority.
+type VideoStagePayload struct {
+	VideoID   string   `json:"video_id"`
+	Stage     string   `json:"stage"`
+	Qualities []string `json:"qualities,omitempty"`
+	Priority  int      `json:"priority"`
+}
+
+func NewVideoStageTask(payload VideoStagePayload) (*asynq.Task, error) {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal video stage payload: %w", err)
+	}
+	return asynq.NewTask(TypeVideoStage, payloadBytes), nil
+}
+
+func ParseVideoStagePayload(task *asynq.Task) (*VideoStagePayload, error) {
+	var payload VideoStagePayload
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal video stage payload: %w", err)
+	}
+	return &payload, nil
+}
+
+const TypeAudioTrackProcessing = "video:audio_track"
+
+// AudioTrackProcessingPayload names a dubbed audio track to encode and add to
+// its video.
+type AudioTrackProcessingPayload struct {
+	VideoID string `json:"video_id"`
+	TrackID string `json:"track_id"`
+}
+
+func NewAudioTrackProcessingTask(payload AudioTrackProcessingPayload) (*asynq.Task, error) {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal audio track processing payload: %w", err)
+	}
+	return asynq.NewTask(TypeAudioTrackProcessing, payloadBytes), nil
+}
+
+func ParseAudioTrackProcessingPayload(task *asynq.Task) (*AudioTrackProcessingPayload, error) {
+	var payload AudioTrackProcessingPayload
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal audio track processing payload: %w", err)
+	}
+	return &payload, nil
+}
+
+const TypeCaptionProcessing = "video:caption"
+
+// CaptionProcessingPayload names an uploaded caption to segment and add to its
+// video.
+type CaptionProcessingPayload struct {
+	VideoID   string `json:"video_id"`
+	CaptionID string `json:"caption_id"`
+}
+
+func NewCaptionProcessingTask(payload CaptionProcessingPayload) (*asynq.Task, error) {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal caption processing payload: %w", err)
+	}
+	return asynq.NewTask(TypeCaptionProcessing, payloadBytes), nil
+}
+
+func ParseCaptionProcessingPayload(task *asynq.Task) (*CaptionProcessingPayload, error) {
+	var payload CaptionProcessingPayload
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal caption processing payload: %w", err)
+	}
+	return &payload, nil
+}
+
+const TypeThumbnailProcessing = "video:thumbnail"
+
+// ThumbnailProcessingPayload names an uploaded thumbnail to render and make
+// its video's poster.
+type ThumbnailProcessingPayload struct {
+	VideoID     string `json:"video_id"`
+	ThumbnailID string `json:"thumbnail_id"`
+}
+
+func NewThumbnailProcessingTask(payload ThumbnailProcessingPayload) (*asynq.Task, error) {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal thumbnail processing payload: %w", err)
+	}
+	return asynq.NewTask(TypeThumbnailProcessing, payloadBytes), nil
+}
+
+func ParseThumbnailProcessingPayload(task *asynq.Task) (*ThumbnailProcessingPayload, error) {
+	var payload ThumbnailProcessingPayload
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal thumbnail processing payload: %w", err)
+	}
+	return &payload, nil
+}
+
+const TypeVideoCut = "video:cut"
+
+// VideoCutPayload names a span of FromVideoID's source to cut as VideoID's.
+// For a trim the two are the same video; for a clip FromVideoID is its
+// parent. SourcePath is VideoID's FilePath when the cut was asked for, which
+// tells a retry whether the cut has already been recorded.
+type VideoCutPayload struct {
+	VideoID     string  `json:"video_id"`
+	FromVideoID string  `json:"from_video_id"`
+	Start       float64 `json:"start"`
+	End         float64 `json:"end"`
+	SourcePath  string  `json:"source_path"`
+}
+
+func NewVideoCutTask(payload VideoCutPayload) (*asynq.Task, error) {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal video cut payload: %w", err)
+	}
+	return asynq.NewTask(TypeVideoCut, payloadBytes), nil
+}
+
+func ParseVideoCutPayload(task *asynq.Task) (*VideoCutPayload, error) {
+	var payload VideoCutPayload
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal video cut payload: %w", err)
+	}
+	return &payload, nil
+}
+
+const TypeVideoImport = "video:import"
+
+// VideoImportPayload names a video whose import source is to be fetched. The
+// source itself stays in the database, out of the queue.
+type VideoImportPayload struct {
+	VideoID string `json:"video_id"`
+}
+
+func NewVideoImportTask(payload VideoImportPayload) (*asynq.Task, error) {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal video import payload: %w", err)
+	}
+	return asynq.NewTask(TypeVideoImport, payloadBytes), nil
+}
+
+func ParseVideoImportPayload(task *asynq.Task) (*VideoImportPayload, error) {
+	var payload VideoImportPayload
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal video import payload: %w", err)
+	}
+	return &payload, nil
+}
+
+const TypeOutputRetire = "video:retire_output"
+
+// OutputRetirePayload names an output revision of a video that another has
+// replaced, to delete once nobody can still be playing it. Qualities are the
+// renditions it was published with. Source is the raw file it was made from
+// when a trim has replaced that too, to delete with it.
+type OutputRetirePayload struct {
+	VideoID   string   `json:"video_id"`
+	Revision  int      `json:"revision"`
+	Qualities []string `json:"qualities,omitempty"`
+	Source    string   `json:"source,omitempty"`
+}
+
+func NewOutputRetireTask(payload OutputRetirePayload) (*asynq.Task, error) {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal output retire payload: %w", err)
+	}
+	return asynq.NewTask(TypeOutputRetire, payloadBytes), nil
+}
+
+func ParseOutputRetirePayload(task *asynq.Task) (*OutputRetirePayload, error) {
+	var payload OutputRetirePayload
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal output retire payload: %w", err)
+	}
+	return &payload, nil
+}
+
+const TypeLiveIngest = "live:ingest"
+
+// LiveIngestPayload names a live stream gone on air, whose push the worker
+// is to read back and transcode until it stops.
+type LiveIngestPayload struct {
+	StreamID string `json:"stream_id"`
+}
+
+func NewLiveIngestTask(payload LiveIngestPayload) (*asynq.Task, error) {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal live ingest payload: %w", err)
+	}
+	return asynq.NewTask(TypeLiveIngest, payloadBytes), nil
+}
+
+func ParseLiveIngestPayload(task *asynq.Task) (*LiveIngestPayload, error) {
+	var payload LiveIngestPayload
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal live ingest payload: %w", err)
+	}
+	return &payload, nil
+}
@@ -0,0 +1,105 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/internal/service"
+	"github.com/Nuu-maan/video-streaming-service/internal/storage"
+)
+
+// ProcessThumbnailTask renders an uploaded thumbnail to every size and makes
+// it its video's poster. Unlike a dub or a caption it touches no playlist,
+// so it takes no lock.
+func (h *VideoProcessingHandler) ProcessThumbnailTask(ctx context.Context, task *asynq.Task) error {
+	payload, err := ParseThumbnailProcessingPayload(task)
+	if err != nil {
+		h.logger.Error(ctx, "failed to parse thumbnail processing payload", err, map[string]interface{}{})
+		return fmt.Errorf("parse payload: %w", err)
+	}
+
+	h.logger.Info(ctx, "processing thumbnail task", map[string]interface{}{
+		"video_id":     payload.VideoID,
+		"thumbnail_id": payload.ThumbnailID,
+		"task_id":      task.ResultWriter().TaskID(),
+	})
+
+	videoID, err := uuid.Parse(payload.VideoID)
+	if err != nil {
+		return fmt.Errorf("invalid video ID: %w", err)
+	}
+	thumbnailID, err := uuid.Parse(payload.ThumbnailID)
+	if err != nil {
+		return fmt.Errorf("invalid thumbnail ID: %w", err)
+	}
+
+	thumbnail, err := h.thumbnails.GetByID(ctx, thumbnailID)
+	if errors.Is(err, domain.ErrThumbnailNotFound) {
+		// Deleted with its video while queued.
+		h.logger.Warn(ctx, "thumbnail no longer exists", map[string]interface{}{
+			"video_id":     payload.VideoID,
+			"thumbnail_id": payload.ThumbnailID,
+		})
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("load thumbnail: %w", err)
+	}
+	if thumbnail.Status != domain.ThumbnailPending {
+		return nil
+	}
+
+	remote := storage.IsRemote(h.store)
+	sourceKey := service.ThumbnailSourceKey(videoID, thumbnailID)
+	source := filepath.Join(h.storageCfg.UploadPath, filepath.FromSlash(sourceKey))
+
+	if remote {
+		if _, err := os.Stat(source); err != nil {
+			if err := h.stageFile(ctx, sourceKey, source); err != nil {
+				h.logger.Error(ctx, "failed to stage thumbnail image", err, map[string]interface{}{
+					"video_id":     payload.VideoID,
+					"thumbnail_id": payload.ThumbnailID,
+				})
+				return fmt.Errorf("stage thumbnail: %w", err)
+			}
+		}
+	}
+
+	if err := h.transcodingService.RenderCustomThumbnail(ctx, thumbnail, source); err != nil {
+		h.logger.Error(ctx, "thumbnail rendering failed", err, map[string]interface{}{
+			"video_id":     payload.VideoID,
+			"thumbnail_id": payload.ThumbnailID,
+			"task_id":      task.ResultWriter().TaskID(),
+		})
+		return fmt.Errorf("render thumbnail: %w", err)
+	}
+
+	dir := h.transcodingService.ThumbnailDir(thumbnail)
+	if remote {
+		if err := h.uploadDir(ctx, dir, thumbnail.Key); err != nil {
+			return fmt.Errorf("upload thumbnail: %w", err)
+		}
+	}
+
+	if err := h.transcodingService.MarkThumbnailReady(ctx, thumbnail); err != nil {
+		return err
+	}
+
+	if remote {
+		h.removeLocalCopies(ctx, videoID, dir, source)
+	}
+
+	h.logger.Info(ctx, "thumbnail processing completed", map[string]interface{}{
+		"video_id":     payload.VideoID,
+		"thumbnail_id": payload.ThumbnailID,
+		"task_id":      task.ResultWriter().TaskID(),
+	})
+	return nil
+}
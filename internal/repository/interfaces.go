@@ -23,6 +23,17 @@ type VideoFilter struct {
 	Visibility *domain.VideoVisibility
 	// Search is a free-text query matched against title and description.
 	Search string
+	// ParentID restricts results to the clips of one video when set.
+	ParentID *uuid.UUID
+	// CreatedAfter and CreatedBefore bound when the video was uploaded, from
+	// CreatedAfter inclusive to CreatedBefore exclusive, when set.
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	// MissingQuality matches videos that were not encoded at the named
+	// rendition when set.
+	MissingQuality string
+	// MissingHLS matches videos with no HLS output being served.
+	MissingHLS bool
 }
 
 // Page is a limit/offset window over a result set.
@@ -45,12 +56,56 @@ type VideoRepository interface {
 	Delete(ctx context.Context, id uuid.UUID) error
 
 	UpdateStatus(ctx context.Context, id uuid.UUID, status domain.VideoStatus) error
-	UpdateProgress(ctx context.Context, id uuid.UUID, progress int) error
+	// UpdateProgress records transcoding progress and the estimated finish;
+	// a nil eta clears any earlier estimate.
+	UpdateProgress(ctx context.Context, id uuid.UUID, progress int, eta *time.Time) error
 	UpdateDuration(ctx context.Context, id uuid.UUID, duration int) error
 	UpdateResolution(ctx context.Context, id uuid.UUID, resolution string) error
-	UpdateHLSInfo(ctx context.Context, id uuid.UUID, hlsMasterPath string, hlsReady bool) error
+	// UpdateSource records a new source file for a video with nothing
+	// published, cut from its parent's for a clip or fetched for an import,
+	// and returns it to VideoStatusUploading to be processed from scratch.
+	UpdateSource(ctx context.Context, id uuid.UUID, filePath, mimeType string, fileSize int64) error
+	// BeginTrim records trim as in progress for the video, and returns
+	// domain.ErrTrimInProgress if another is, or the video's source is no
+	// longer the one trim was taken from.
+	BeginTrim(ctx context.Context, id uuid.UUID, trim *domain.VideoTrim) error
+	// RecordTrim records the source cut for the video's trim in progress,
+	// and its chapters moved to the cut's timeline. Its status is left
+	// alone: it is served as it was until the trimmed output is published.
+	RecordTrim(ctx context.Context, id uuid.UUID, filePath string, fileSize int64, chapters []domain.Chapter) error
+	// FinishTrim forgets the video's trim: its output is published, or it
+	// never got as far as a cut.
+	FinishTrim(ctx context.Context, id uuid.UUID) error
+	// RevertTrim puts back the source, duration and chapters the video's
+	// trim holds, and forgets the trim.
+	RevertTrim(ctx context.Context, id uuid.UUID) error
+	// UpdateHLSInfo records where the master playlist is, the output
+	// revision it belongs to and how the video was packaged, one of the
+	// domain.StreamingProtocol values.
+	UpdateHLSInfo(ctx context.Context, id uuid.UUID, hlsMasterPath string, revision int, hlsReady bool, protocol string) error
 	MarkAsReady(ctx context.Context, id uuid.UUID, qualities []string, thumbnailPath string) error
+	// UpdatePreviewPath records the storage key base of a video's animated
+	// preview; "" records that it has none.
+	UpdatePreviewPath(ctx context.Context, id uuid.UUID, previewPath string) error
 	MarkAsFailed(ctx context.Context, id uuid.UUID) error
+	// UpdateLoudness records the soundtrack's measured loudness; nil clears
+	// it.
+	UpdateLoudness(ctx context.Context, id uuid.UUID, loudness *domain.AudioLoudness) error
+	// MarkAsRejected fails the video because its source was rejected, and
+	// records why for its uploader.
+	MarkAsRejected(ctx context.Context, id uuid.UUID, perr domain.ProcessingError) error
+
+	// GetEncodingLadder returns the ladder per-title encoding chose for a
+	// video, or nil if it has never been analysed.
+	GetEncodingLadder(ctx context.Context, id uuid.UUID) (*domain.EncodingLadder, error)
+	UpdateEncodingLadder(ctx context.Context, id uuid.UUID, ladder *domain.EncodingLadder) error
+
+	// UpdateChapters replaces the video's chapters.
+	UpdateChapters(ctx context.Context, id uuid.UUID, chapters []domain.Chapter) error
+	// SetPremiere schedules the video's premiere at at, or cancels it with
+	// nil, and reports whether it could: a premiere that has started stays
+	// as it is.
+	SetPremiere(ctx context.Context, id uuid.UUID, at *time.Time) (bool, error)
 }
 
 // UserRepository persists users.
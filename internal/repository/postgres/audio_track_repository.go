@@ -0,0 +1,139 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+)
+
+const audioTrackColumns = `
+	id, video_id, name, language, label, kind, status, is_default, stream_index,
+	file_path, created_at, updated_at`
+
+// AudioTrackRepository stores the audio renditions of videos.
+type AudioTrackRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewAudioTrackRepository(pool *pgxpool.Pool) *AudioTrackRepository {
+	return &AudioTrackRepository{pool: pool}
+}
+
+func scanAudioTrack(row scanner) (*domain.AudioTrack, error) {
+	var t domain.AudioTrack
+	err := row.Scan(
+		&t.ID, &t.VideoID, &t.Name, &t.Language, &t.Label, &t.Kind, &t.Status, &t.IsDefault, &t.StreamIndex,
+		&t.FilePath, &t.CreatedAt, &t.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+const insertAudioTrack = `
+	INSERT INTO video_audio_tracks (
+		id, video_id, name, language, label, kind, status, is_default, stream_index,
+		file_path, created_at, updated_at
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+
+func audioTrackArgs(t *domain.AudioTrack) []any {
+	return []any{
+		t.ID, t.VideoID, t.Name, t.Language, t.Label, t.Kind, t.Status, t.IsDefault, t.StreamIndex,
+		t.FilePath, t.CreatedAt, t.UpdatedAt,
+	}
+}
+
+func (r *AudioTrackRepository) Create(ctx context.Context, t *domain.AudioTrack) error {
+	if _, err := r.pool.Exec(ctx, insertAudioTrack, audioTrackArgs(t)...); err != nil {
+		return fmt.Errorf("creating audio track: %w", err)
+	}
+	return nil
+}
+
+func (r *AudioTrackRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.AudioTrack, error) {
+	query := `SELECT` + audioTrackColumns + ` FROM video_audio_tracks WHERE id = $1`
+
+	t, err := scanAudioTrack(r.pool.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrAudioTrackNotFound
+		}
+		return nil, fmt.Errorf("getting audio track %s: %w", id, err)
+	}
+	return t, nil
+}
+
+// ListByVideo returns a video's tracks in the order a player should offer
+// them: the source tracks as they were in the file, then dubs as they were
+// added.
+func (r *AudioTrackRepository) ListByVideo(ctx context.Context, videoID uuid.UUID) ([]*domain.AudioTrack, error) {
+	query := `SELECT` + audioTrackColumns + `
+		FROM video_audio_tracks
+		WHERE video_id = $1
+		ORDER BY kind = 'dub', stream_index, created_at, name`
+
+	rows, err := r.pool.Query(ctx, query, videoID)
+	if err != nil {
+		return nil, fmt.Errorf("listing audio tracks of video %s: %w", videoID, err)
+	}
+	defer rows.Close()
+
+	var tracks []*domain.AudioTrack
+	for rows.Next() {
+		t, err := scanAudioTrack(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning audio track: %w", err)
+		}
+		tracks = append(tracks, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating audio tracks: %w", err)
+	}
+	return tracks, nil
+}
+
+// ReplaceSourceTracks swaps a video's source tracks for tracks in one
+// transaction, leaving its dubs alone. A re-encoded video is probed afresh,
+// and rows from the earlier encode must not outlive it.
+func (r *AudioTrackRepository) ReplaceSourceTracks(ctx context.Context, videoID uuid.UUID, tracks []*domain.AudioTrack) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx,
+		`DELETE FROM video_audio_tracks WHERE video_id = $1 AND kind = $2`,
+		videoID, domain.AudioTrackSource,
+	); err != nil {
+		return fmt.Errorf("clearing source audio tracks of video %s: %w", videoID, err)
+	}
+	for _, t := range tracks {
+		if _, err := tx.Exec(ctx, insertAudioTrack, audioTrackArgs(t)...); err != nil {
+			return fmt.Errorf("recording audio track %s of video %s: %w", t.Name, videoID, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("committing audio tracks: %w", err)
+	}
+	return nil
+}
+
+func (r *AudioTrackRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status domain.AudioTrackStatus) error {
+	tag, err := r.pool.Exec(ctx, `UPDATE video_audio_tracks SET status = $2 WHERE id = $1`, id, status)
+	if err != nil {
+		return fmt.Errorf("updating audio track %s: %w", id, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrAudioTrackNotFound
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+)
+
+const captionColumns = `
+	id, video_id, name, language, label, kind, status, is_default, embedded,
+	stream_index, file_path, created_at, updated_at`
+
+// CaptionRepository stores the text tracks of videos.
+type CaptionRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewCaptionRepository(pool *pgxpool.Pool) *CaptionRepository {
+	return &CaptionRepository{pool: pool}
+}
+
+func scanCaption(row scanner) (*domain.Caption, error) {
+	var c domain.Caption
+	err := row.Scan(
+		&c.ID, &c.VideoID, &c.Name, &c.Language, &c.Label, &c.Kind, &c.Status, &c.IsDefault, &c.Embedded,
+		&c.StreamIndex, &c.FilePath, &c.CreatedAt, &c.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+const insertCaption = `
+	INSERT INTO video_captions (
+		id, video_id, name, language, label, kind, status, is_default, embedded,
+		stream_index, file_path, created_at, updated_at
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+
+func captionArgs(c *domain.Caption) []any {
+	return []any{
+		c.ID, c.VideoID, c.Name, c.Language, c.Label, c.Kind, c.Status, c.IsDefault, c.Embedded,
+		c.StreamIndex, c.FilePath, c.CreatedAt, c.UpdatedAt,
+	}
+}
+
+func (r *CaptionRepository) Create(ctx context.Context, c *domain.Caption) error {
+	if _, err := r.pool.Exec(ctx, insertCaption, captionArgs(c)...); err != nil {
+		return fmt.Errorf("creating caption: %w", err)
+	}
+	return nil
+}
+
+func (r *CaptionRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Caption, error) {
+	query := `SELECT` + captionColumns + ` FROM video_captions WHERE id = $1`
+
+	c, err := scanCaption(r.pool.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrCaptionNotFound
+		}
+		return nil, fmt.Errorf("getting caption %s: %w", id, err)
+	}
+	return c, nil
+}
+
+// ListByVideo returns a video's captions in the order a player should offer
+// them: the embedded ones as they were in the file, then uploads as they were
+// added.
+func (r *CaptionRepository) ListByVideo(ctx context.Context, videoID uuid.UUID) ([]*domain.Caption, error) {
+	query := `SELECT` + captionColumns + `
+		FROM video_captions
+		WHERE video_id = $1
+		ORDER BY NOT embedded, stream_index, created_at, name`
+
+	rows, err := r.pool.Query(ctx, query, videoID)
+	if err != nil {
+		return nil, fmt.Errorf("listing captions of video %s: %w", videoID, err)
+	}
+	defer rows.Close()
+
+	var captions []*domain.Caption
+	for rows.Next() {
+		c, err := scanCaption(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning caption: %w", err)
+		}
+		captions = append(captions, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating captions: %w", err)
+	}
+	return captions, nil
+}
+
+// ReplaceEmbeddedCaptions swaps a video's embedded captions for captions in
+// one transaction, leaving uploaded ones alone, as ReplaceSourceTracks does
+// for audio.
+func (r *CaptionRepository) ReplaceEmbeddedCaptions(ctx context.Context, videoID uuid.UUID, captions []*domain.Caption) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx,
+		`DELETE FROM video_captions WHERE video_id = $1 AND embedded`,
+		videoID,
+	); err != nil {
+		return fmt.Errorf("clearing embedded captions of video %s: %w", videoID, err)
+	}
+	for _, c := range captions {
+		if _, err := tx.Exec(ctx, insertCaption, captionArgs(c)...); err != nil {
+			return fmt.Errorf("recording caption %s of video %s: %w", c.Name, videoID, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("committing captions: %w", err)
+	}
+	return nil
+}
+
+// MarkReady records a published caption as ready. A default caption takes
+// the default from every other caption of its video, so a video has at most
+// one.
+func (r *CaptionRepository) MarkReady(ctx context.Context, c *domain.Caption) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if c.IsDefault {
+		if _, err := tx.Exec(ctx,
+			`UPDATE video_captions SET is_default = FALSE WHERE video_id = $1 AND id <> $2 AND is_default`,
+			c.VideoID, c.ID,
+		); err != nil {
+			return fmt.Errorf("clearing default caption of video %s: %w", c.VideoID, err)
+		}
+	}
+	tag, err := tx.Exec(ctx, `UPDATE video_captions SET status = $2 WHERE id = $1`, c.ID, domain.CaptionReady)
+	if err != nil {
+		return fmt.Errorf("updating caption %s: %w", c.ID, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrCaptionNotFound
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("committing caption: %w", err)
+	}
+	return nil
+}
+
+func (r *CaptionRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status domain.CaptionStatus) error {
+	tag, err := r.pool.Exec(ctx, `UPDATE video_captions SET status = $2 WHERE id = $1`, id, status)
+	if err != nil {
+		return fmt.Errorf("updating caption %s: %w", id, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrCaptionNotFound
+	}
+	return nil
+}
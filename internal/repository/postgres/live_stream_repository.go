@@ -0,0 +1,136 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/internal/repository"
+	"github.com/Nuu-maan/video-streaming-service/internal/service"
+)
+
+const liveStreamColumns = `
+	id, video_id, user_id, key_hash, status, started_at, ended_at, created_at`
+
+// LiveStreamRepository stores live streams.
+type LiveStreamRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewLiveStreamRepository(pool *pgxpool.Pool) *LiveStreamRepository {
+	return &LiveStreamRepository{pool: pool}
+}
+
+func scanLiveStream(row scanner) (*domain.LiveStream, error) {
+	var s domain.LiveStream
+	err := row.Scan(&s.ID, &s.VideoID, &s.UserID, &s.KeyHash, &s.Status, &s.StartedAt, &s.EndedAt, &s.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *LiveStreamRepository) Create(ctx context.Context, s *domain.LiveStream) error {
+	_, err := r.pool.Exec(ctx,
+		`INSERT INTO live_streams (id, video_id, user_id, key_hash, status, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6)`,
+		s.ID, s.VideoID, s.UserID, s.KeyHash, s.Status, s.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("creating live stream: %w", err)
+	}
+	return nil
+}
+
+func (r *LiveStreamRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.LiveStream, error) {
+	query := `SELECT` + liveStreamColumns + ` FROM live_streams WHERE id = $1`
+
+	s, err := scanLiveStream(r.pool.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrLiveStreamNotFound
+		}
+		return nil, fmt.Errorf("getting live stream %s: %w", id, err)
+	}
+	return s, nil
+}
+
+// ListByUser returns a user's streams, newest first.
+func (r *LiveStreamRepository) ListByUser(ctx context.Context, userID uuid.UUID, page repository.Page) ([]*domain.LiveStream, error) {
+	query := `SELECT` + liveStreamColumns + `
+		FROM live_streams
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := r.pool.Query(ctx, query, userID, page.Limit, page.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("listing live streams of user %s: %w", userID, err)
+	}
+	defer rows.Close()
+
+	var streams []*domain.LiveStream
+	for rows.Next() {
+		s, err := scanLiveStream(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning live stream: %w", err)
+		}
+		streams = append(streams, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating live streams: %w", err)
+	}
+	return streams, nil
+}
+
+// Start moves a waiting stream on air. The status is checked in the update
+// itself, so of two pushes racing to start the stream one does.
+func (r *LiveStreamRepository) Start(ctx context.Context, id uuid.UUID) (bool, error) {
+	tag, err := r.pool.Exec(ctx,
+		`UPDATE live_streams SET status = 'live', started_at = NOW()
+		 WHERE id = $1 AND status = 'waiting'`,
+		id,
+	)
+	if err != nil {
+		return false, fmt.Errorf("starting live stream %s: %w", id, err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// End records that a stream is over and, with a recording, records it as
+// the source of the stream's video in the same transaction, leaving the
+// video's status alone.
+func (r *LiveStreamRepository) End(ctx context.Context, s *domain.LiveStream, recording *service.LiveRecording) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx,
+		`UPDATE live_streams SET status = 'ended', ended_at = NOW()
+		 WHERE id = $1 AND status <> 'ended'`,
+		s.ID,
+	); err != nil {
+		return fmt.Errorf("ending live stream %s: %w", s.ID, err)
+	}
+	if recording != nil {
+		if _, err := tx.Exec(ctx,
+			`UPDATE videos SET file_path = $2, mime_type = $3, file_size = $4, updated_at = NOW()
+			 WHERE id = $1`,
+			s.VideoID, recording.Path, recording.MIMEType, recording.Size,
+		); err != nil {
+			return fmt.Errorf("recording source of video %s: %w", s.VideoID, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("committing live stream end: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,269 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+)
+
+const processingStageColumns = `
+	video_id, name, kind, depends_on, status, attempts, progress,
+	COALESCE(last_error, ''), checkpoint, started_at, finished_at, created_at, updated_at`
+
+// ProcessingStageRepository stores the stages each video is processed in.
+type ProcessingStageRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewProcessingStageRepository(pool *pgxpool.Pool) *ProcessingStageRepository {
+	return &ProcessingStageRepository{pool: pool}
+}
+
+func scanProcessingStage(row scanner) (*domain.ProcessingStage, error) {
+	var s domain.ProcessingStage
+	err := row.Scan(
+		&s.VideoID, &s.Name, &s.Kind, &s.DependsOn, &s.Status, &s.Attempts, &s.Progress,
+		&s.Error, &s.Checkpoint, &s.StartedAt, &s.FinishedAt, &s.CreatedAt, &s.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func scanProcessingStages(rows pgx.Rows) ([]*domain.ProcessingStage, error) {
+	defer rows.Close()
+
+	var stages []*domain.ProcessingStage
+	for rows.Next() {
+		s, err := scanProcessingStage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning processing stage: %w", err)
+		}
+		stages = append(stages, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating processing stages: %w", err)
+	}
+	return stages, nil
+}
+
+const insertProcessingStage = `
+	INSERT INTO video_processing_stages (video_id, name, kind, position, depends_on, status)
+	VALUES ($1, $2, $3, $4, $5, $6)`
+
+// ListByVideo returns a video's stages in the order they were planned.
+func (r *ProcessingStageRepository) ListByVideo(ctx context.Context, videoID uuid.UUID) ([]*domain.ProcessingStage, error) {
+	query := `SELECT` + processingStageColumns + `
+		FROM video_processing_stages
+		WHERE video_id = $1
+		ORDER BY position, name`
+
+	rows, err := r.pool.Query(ctx, query, videoID)
+	if err != nil {
+		return nil, fmt.Errorf("listing processing stages of video %s: %w", videoID, err)
+	}
+	return scanProcessingStages(rows)
+}
+
+func (r *ProcessingStageRepository) GetByName(ctx context.Context, videoID uuid.UUID, name string) (*domain.ProcessingStage, error) {
+	query := `SELECT` + processingStageColumns + `
+		FROM video_processing_stages
+		WHERE video_id = $1 AND name = $2`
+
+	s, err := scanProcessingStage(r.pool.QueryRow(ctx, query, videoID, name))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrStageNotFound
+		}
+		return nil, fmt.Errorf("getting stage %s of video %s: %w", name, videoID, err)
+	}
+	return s, nil
+}
+
+// Restart deletes a video's stages and records a pending validate, and the
+// probe after it, in their place, in one transaction.
+func (r *ProcessingStageRepository) Restart(ctx context.Context, videoID uuid.UUID) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM video_processing_stages WHERE video_id = $1`, videoID); err != nil {
+		return fmt.Errorf("clearing processing stages of video %s: %w", videoID, err)
+	}
+	if _, err := tx.Exec(ctx, insertProcessingStage,
+		videoID, domain.ValidateStageName, domain.StageValidate, 0, []string{}, domain.StagePending,
+	); err != nil {
+		return fmt.Errorf("recording validation of video %s: %w", videoID, err)
+	}
+	if _, err := tx.Exec(ctx, insertProcessingStage,
+		videoID, domain.ProbeStageName, domain.StageProbe, 1, []string{domain.ValidateStageName}, domain.StagePending,
+	); err != nil {
+		return fmt.Errorf("recording probe of video %s: %w", videoID, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("committing processing restart: %w", err)
+	}
+	return nil
+}
+
+// Plan records the stages probe planned for a video, after the probe, in one
+// transaction. Whatever an earlier attempt at the probe planned is replaced:
+// nothing it planned can have run, since all of it depends on the probe.
+func (r *ProcessingStageRepository) Plan(ctx context.Context, videoID uuid.UUID, stages []*domain.ProcessingStage) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx,
+		`DELETE FROM video_processing_stages WHERE video_id = $1 AND kind NOT IN ($2, $3)`,
+		videoID, domain.StageValidate, domain.StageProbe,
+	); err != nil {
+		return fmt.Errorf("clearing planned stages of video %s: %w", videoID, err)
+	}
+	for i, s := range stages {
+		dependsOn := s.DependsOn
+		if dependsOn == nil {
+			dependsOn = []string{}
+		}
+		if _, err := tx.Exec(ctx, insertProcessingStage,
+			videoID, s.Name, s.Kind, i+2, dependsOn, domain.StagePending,
+		); err != nil {
+			return fmt.Errorf("recording stage %s of video %s: %w", s.Name, videoID, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("committing planned stages: %w", err)
+	}
+	return nil
+}
+
+// ClaimReady moves the video's pending stages whose dependencies are all done
+// to queued and returns them. The update re-checks each row's status once it
+// holds the row lock, so of two callers racing for a stage only one gets it.
+func (r *ProcessingStageRepository) ClaimReady(ctx context.Context, videoID uuid.UUID) ([]*domain.ProcessingStage, error) {
+	query := `
+		UPDATE video_processing_stages s SET status = $2
+		WHERE s.video_id = $1 AND s.status = $3
+			AND NOT EXISTS (
+				SELECT 1 FROM video_processing_stages d
+				WHERE d.video_id = s.video_id AND d.name = ANY(s.depends_on) AND d.status <> $4
+			)
+		RETURNING` + processingStageColumns
+
+	rows, err := r.pool.Query(ctx, query, videoID, domain.StageQueued, domain.StagePending, domain.StageDone)
+	if err != nil {
+		return nil, fmt.Errorf("claiming ready stages of video %s: %w", videoID, err)
+	}
+	return scanProcessingStages(rows)
+}
+
+func (r *ProcessingStageRepository) Release(ctx context.Context, videoID uuid.UUID, name string) error {
+	_, err := r.pool.Exec(ctx,
+		`UPDATE video_processing_stages SET status = $3 WHERE video_id = $1 AND name = $2 AND status = $4`,
+		videoID, name, domain.StagePending, domain.StageQueued,
+	)
+	if err != nil {
+		return fmt.Errorf("releasing stage %s of video %s: %w", name, videoID, err)
+	}
+	return nil
+}
+
+// Start marks a queued or running stage running and counts the attempt. A
+// running one is a retry of an attempt that died without saying so. A stage
+// in any other state is returned unchanged.
+func (r *ProcessingStageRepository) Start(ctx context.Context, videoID uuid.UUID, name string) (*domain.ProcessingStage, error) {
+	query := `
+		UPDATE video_processing_stages
+		SET status = $3, attempts = attempts + 1, progress = 0, started_at = NOW()
+		WHERE video_id = $1 AND name = $2 AND status IN ($3, $4)
+		RETURNING` + processingStageColumns
+
+	s, err := scanProcessingStage(r.pool.QueryRow(ctx, query, videoID, name, domain.StageRunning, domain.StageQueued))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return r.GetByName(ctx, videoID, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("starting stage %s of video %s: %w", name, videoID, err)
+	}
+	return s, nil
+}
+
+func (r *ProcessingStageRepository) UpdateProgress(ctx context.Context, videoID uuid.UUID, name string, progress float64) error {
+	_, err := r.pool.Exec(ctx,
+		`UPDATE video_processing_stages SET progress = $3 WHERE video_id = $1 AND name = $2 AND status = $4`,
+		videoID, name, progress, domain.StageRunning,
+	)
+	if err != nil {
+		return fmt.Errorf("updating progress of stage %s of video %s: %w", name, videoID, err)
+	}
+	return nil
+}
+
+func (r *ProcessingStageRepository) Finish(ctx context.Context, videoID uuid.UUID, name string, checkpoint json.RawMessage) error {
+	var value any
+	if len(checkpoint) > 0 {
+		value = checkpoint
+	}
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE video_processing_stages
+		SET status = $3, progress = 1, checkpoint = $4, last_error = NULL, finished_at = NOW()
+		WHERE video_id = $1 AND name = $2`,
+		videoID, name, domain.StageDone, value,
+	)
+	if err != nil {
+		return fmt.Errorf("finishing stage %s of video %s: %w", name, videoID, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrStageNotFound
+	}
+	return nil
+}
+
+// Fail records why an attempt at a stage failed. The stage goes back to
+// queued for asynq's next attempt, or to failed after the last.
+func (r *ProcessingStageRepository) Fail(ctx context.Context, videoID uuid.UUID, name, message string, final bool) error {
+	status := domain.StageQueued
+	if final {
+		status = domain.StageFailed
+	}
+	_, err := r.pool.Exec(ctx, `
+		UPDATE video_processing_stages SET status = $3, last_error = $4
+		WHERE video_id = $1 AND name = $2 AND status = $5`,
+		videoID, name, status, message, domain.StageRunning,
+	)
+	if err != nil {
+		return fmt.Errorf("failing stage %s of video %s: %w", name, videoID, err)
+	}
+	return nil
+}
+
+// Requeue returns a video's failed stages to pending, with those queued or
+// running that have not changed since staleBefore: their task is gone, lost
+// with a worker or archived before it could say so.
+func (r *ProcessingStageRepository) Requeue(ctx context.Context, videoID uuid.UUID, staleBefore time.Time) (int, error) {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE video_processing_stages SET status = $2, progress = 0
+		WHERE video_id = $1
+			AND (status = $3 OR (status IN ($4, $5) AND updated_at < $6))`,
+		videoID, domain.StagePending, domain.StageFailed, domain.StageQueued, domain.StageRunning, staleBefore,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("requeueing stages of video %s: %w", videoID, err)
+	}
+	return int(tag.RowsAffected()), nil
+}
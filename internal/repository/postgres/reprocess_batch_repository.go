@@ -0,0 +1,78 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+)
+
+// ReprocessBatchRepository stores the batches of videos admins queue to be
+// processed again.
+type ReprocessBatchRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewReprocessBatchRepository(pool *pgxpool.Pool) *ReprocessBatchRepository {
+	return &ReprocessBatchRepository{pool: pool}
+}
+
+func (r *ReprocessBatchRepository) Create(ctx context.Context, batch *domain.ReprocessBatch) error {
+	_, err := r.pool.Exec(ctx,
+		`INSERT INTO reprocess_batches (id, filter, video_ids, rate_per_minute, requested_by, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6)`,
+		batch.ID, batch.Filter, batch.VideoIDs, batch.RatePerMinute, batch.RequestedBy, batch.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("creating reprocess batch: %w", err)
+	}
+	return nil
+}
+
+func (r *ReprocessBatchRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.ReprocessBatch, error) {
+	var b domain.ReprocessBatch
+	err := r.pool.QueryRow(ctx,
+		`SELECT id, filter, video_ids, rate_per_minute, requested_by, created_at
+		 FROM reprocess_batches WHERE id = $1`,
+		id,
+	).Scan(&b.ID, &b.Filter, &b.VideoIDs, &b.RatePerMinute, &b.RequestedBy, &b.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrReprocessBatchNotFound
+		}
+		return nil, fmt.Errorf("getting reprocess batch %s: %w", id, err)
+	}
+	return &b, nil
+}
+
+// Progress counts the batch's videos. One is published once it has been
+// processed since the batch was queued, and failed once a stage of its has
+// failed for good since then: its stages from before were deleted when it
+// was restarted. One no longer in videos was deleted.
+func (r *ReprocessBatchRepository) Progress(ctx context.Context, batch *domain.ReprocessBatch) (*domain.ReprocessProgress, error) {
+	var found int
+	progress := domain.ReprocessProgress{Total: batch.Total()}
+	err := r.pool.QueryRow(ctx,
+		`SELECT
+			COUNT(*),
+			COUNT(*) FILTER (WHERE v.processed_at >= $2),
+			COUNT(*) FILTER (WHERE (v.processed_at IS NULL OR v.processed_at < $2) AND EXISTS (
+				SELECT 1 FROM video_processing_stages s
+				WHERE s.video_id = v.id AND s.status = 'failed' AND s.updated_at >= $2
+			))
+		 FROM videos v
+		 WHERE v.id = ANY($1)`,
+		batch.VideoIDs, batch.CreatedAt,
+	).Scan(&found, &progress.Published, &progress.Failed)
+	if err != nil {
+		return nil, fmt.Errorf("counting progress of reprocess batch %s: %w", batch.ID, err)
+	}
+	progress.Deleted = progress.Total - found
+	progress.Pending = found - progress.Published - progress.Failed
+	return &progress, nil
+}
@@ -24,9 +24,10 @@ const onlyPublicReady = `v.status = 'ready' AND v.visibility = 'public'`
 // searchItemColumns matches scanSearchItem field-for-field. Every query in this
 // file aliases videos as v and users as u. The COALESCEs cover legacy rows:
 // videos uploaded before authentication have a NULL user_id, and description,
-// thumbnail and avatar are all nullable.
+// thumbnail, preview and avatar are all nullable.
 const searchItemColumns = `
 	v.id, v.title, COALESCE(v.description, ''), COALESCE(v.thumbnail_path, ''),
+	COALESCE(v.preview_path, ''),
 	v.duration, v.view_count, v.created_at,
 	COALESCE(u.id, '00000000-0000-0000-0000-000000000000'::uuid),
 	COALESCE(u.username, ''), COALESCE(u.avatar_url, ''), COALESCE(u.email_verified, false)`
@@ -51,12 +52,13 @@ func NewSearchRepository(pool *pgxpool.Pool) *SearchRepository {
 // search, a literal 0 elsewhere) so a single scanner serves them all.
 func scanSearchItem(row scanner) (*domain.VideoSearchItem, error) {
 	var item domain.VideoSearchItem
-	var thumbnailKey string
+	var thumbnailKey, previewKey string
 	err := row.Scan(
 		&item.VideoID,
 		&item.Title,
 		&item.Description,
 		&thumbnailKey,
+		&previewKey,
 		&item.Duration,
 		&item.Views,
 		&item.CreatedAt,
@@ -72,10 +74,14 @@ func scanSearchItem(row scanner) (*domain.VideoSearchItem, error) {
 
 	// The column holds a storage key, which is not fetchable by a client. Search
 	// hands back the same URL the full video record does, so a result list and a
-	// video detail page never disagree about where the poster image lives.
+	// video detail page never disagree about where the poster image lives. The
+	// same goes for the hover preview.
 	if thumbnailKey != "" {
 		item.ThumbnailURL = domain.VideoThumbnailURL(item.VideoID)
 	}
+	if previewKey != "" {
+		item.PreviewURL = domain.VideoPreviewURL(item.VideoID)
+	}
 
 	item.Snippet = snippet(item.Description)
 	return &item, nil
@@ -20,7 +20,7 @@ import (
 // domain.User.GetAvatarURL.
 const commentColumns = `
 	c.id, c.video_id, c.user_id, c.parent_id, c.content, c.like_count,
-	c.reply_count, c.pinned, c.edited_at, c.created_at, c.updated_at,
+	c.reply_count, c.pinned, c.event_offset, c.edited_at, c.created_at, c.updated_at,
 	c.deleted_at, u.username, COALESCE(u.oauth_avatar_url, u.avatar_url, '')`
 
 const notificationColumns = `
@@ -53,6 +53,7 @@ func scanComment(row scanner) (*domain.Comment, error) {
 		&c.LikeCount,
 		&c.ReplyCount,
 		&c.Pinned,
+		&c.EventOffset,
 		&c.EditedAt,
 		&c.CreatedAt,
 		&c.UpdatedAt,
@@ -138,12 +139,12 @@ func (r *SocialRepository) GetLike(ctx context.Context, userID, videoID uuid.UUI
 
 func (r *SocialRepository) CreateComment(ctx context.Context, comment *domain.Comment) error {
 	query := `
-	INSERT INTO comments (id, video_id, user_id, parent_id, content, created_at, updated_at)
-	VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	INSERT INTO comments (id, video_id, user_id, parent_id, content, event_offset, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
 
 	_, err := r.pool.Exec(ctx, query,
 		comment.ID, comment.VideoID, comment.UserID, comment.ParentID,
-		comment.Content, comment.CreatedAt, comment.UpdatedAt,
+		comment.Content, comment.EventOffset, comment.CreatedAt, comment.UpdatedAt,
 	)
 	if err != nil {
 		return fmt.Errorf("creating comment: %w", err)
@@ -184,6 +185,30 @@ func (r *SocialRepository) CountComments(ctx context.Context, videoID uuid.UUID)
 	)
 }
 
+// ListChat returns the comments posted during a video's premiere, from
+// seconds into it on, replies among them, in the order they were
+// posted: a chat replays as it happened.
+func (r *SocialRepository) ListChat(ctx context.Context, videoID uuid.UUID, from float64, page repository.Page) ([]*domain.Comment, error) {
+	query := `SELECT` + commentColumns + `
+	FROM comments c JOIN users u ON u.id = c.user_id
+	WHERE c.video_id = $1 AND c.event_offset >= $2 AND c.deleted_at IS NULL
+	ORDER BY c.event_offset ASC, c.created_at ASC
+	LIMIT $3 OFFSET $4`
+
+	rows, err := r.pool.Query(ctx, query, videoID, from, page.Limit, page.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("listing chat of video %s: %w", videoID, err)
+	}
+	return collectComments(rows, page)
+}
+
+func (r *SocialRepository) CountChat(ctx context.Context, videoID uuid.UUID, from float64) (int, error) {
+	return r.count(ctx,
+		`SELECT COUNT(*) FROM comments WHERE video_id = $1 AND event_offset >= $2 AND deleted_at IS NULL`,
+		videoID, from,
+	)
+}
+
 // ListReplies is ordered oldest-first: a thread reads top-down, unlike the
 // top-level listing which surfaces the newest conversation.
 func (r *SocialRepository) ListReplies(ctx context.Context, parentID uuid.UUID, page repository.Page) ([]*domain.Comment, error) {
@@ -208,6 +233,11 @@ func (r *SocialRepository) listComments(ctx context.Context, query string, id uu
 	if err != nil {
 		return nil, fmt.Errorf("listing comments: %w", err)
 	}
+	return collectComments(rows, page)
+}
+
+// collectComments scans a page of comment rows and closes them.
+func collectComments(rows pgx.Rows, page repository.Page) ([]*domain.Comment, error) {
 	defer rows.Close()
 
 	comments := make([]*domain.Comment, 0, page.Limit)
@@ -0,0 +1,190 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+)
+
+const thumbnailColumns = `
+	id, video_id, kind, status, storage_key, offset_seconds, score, selected,
+	created_at, updated_at`
+
+// ThumbnailRepository stores the poster images of videos.
+type ThumbnailRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewThumbnailRepository(pool *pgxpool.Pool) *ThumbnailRepository {
+	return &ThumbnailRepository{pool: pool}
+}
+
+func scanThumbnail(row scanner) (*domain.Thumbnail, error) {
+	var t domain.Thumbnail
+	err := row.Scan(
+		&t.ID, &t.VideoID, &t.Kind, &t.Status, &t.Key, &t.Offset, &t.Score, &t.Selected,
+		&t.CreatedAt, &t.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+const insertThumbnail = `
+	INSERT INTO video_thumbnails (
+		id, video_id, kind, status, storage_key, offset_seconds, score, selected,
+		created_at, updated_at
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+
+func thumbnailArgs(t *domain.Thumbnail) []any {
+	return []any{
+		t.ID, t.VideoID, t.Kind, t.Status, t.Key, t.Offset, t.Score, t.Selected,
+		t.CreatedAt, t.UpdatedAt,
+	}
+}
+
+func (r *ThumbnailRepository) Create(ctx context.Context, t *domain.Thumbnail) error {
+	if _, err := r.pool.Exec(ctx, insertThumbnail, thumbnailArgs(t)...); err != nil {
+		return fmt.Errorf("creating thumbnail: %w", err)
+	}
+	return nil
+}
+
+func (r *ThumbnailRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Thumbnail, error) {
+	query := `SELECT` + thumbnailColumns + ` FROM video_thumbnails WHERE id = $1`
+
+	t, err := scanThumbnail(r.pool.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrThumbnailNotFound
+		}
+		return nil, fmt.Errorf("getting thumbnail %s: %w", id, err)
+	}
+	return t, nil
+}
+
+// ListByVideo returns a video's thumbnails: the candidates best first, then
+// uploads as they were added.
+func (r *ThumbnailRepository) ListByVideo(ctx context.Context, videoID uuid.UUID) ([]*domain.Thumbnail, error) {
+	query := `SELECT` + thumbnailColumns + `
+		FROM video_thumbnails
+		WHERE video_id = $1
+		ORDER BY kind = 'custom', score DESC, created_at, id`
+
+	rows, err := r.pool.Query(ctx, query, videoID)
+	if err != nil {
+		return nil, fmt.Errorf("listing thumbnails of video %s: %w", videoID, err)
+	}
+	defer rows.Close()
+
+	var thumbnails []*domain.Thumbnail
+	for rows.Next() {
+		t, err := scanThumbnail(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning thumbnail: %w", err)
+		}
+		thumbnails = append(thumbnails, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating thumbnails: %w", err)
+	}
+	return thumbnails, nil
+}
+
+// ReplaceCandidates swaps a video's candidates for candidates in one
+// transaction, leaving uploaded thumbnails alone. A selected candidate takes
+// the selection from an upload.
+func (r *ThumbnailRepository) ReplaceCandidates(ctx context.Context, videoID uuid.UUID, candidates []*domain.Thumbnail) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx,
+		`DELETE FROM video_thumbnails WHERE video_id = $1 AND kind = $2`,
+		videoID, domain.ThumbnailCandidate,
+	); err != nil {
+		return fmt.Errorf("clearing thumbnail candidates of video %s: %w", videoID, err)
+	}
+	for _, t := range candidates {
+		if !t.Selected {
+			continue
+		}
+		if _, err := tx.Exec(ctx,
+			`UPDATE video_thumbnails SET selected = FALSE WHERE video_id = $1 AND selected`,
+			videoID,
+		); err != nil {
+			return fmt.Errorf("clearing selected thumbnail of video %s: %w", videoID, err)
+		}
+		break
+	}
+	for _, t := range candidates {
+		if _, err := tx.Exec(ctx, insertThumbnail, thumbnailArgs(t)...); err != nil {
+			return fmt.Errorf("recording thumbnail candidate of video %s: %w", videoID, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("committing thumbnail candidates: %w", err)
+	}
+	return nil
+}
+
+// Select makes a ready thumbnail its video's poster: it alone is marked
+// selected, and its key becomes the video's thumbnail path, in one
+// transaction.
+func (r *ThumbnailRepository) Select(ctx context.Context, t *domain.Thumbnail) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx,
+		`UPDATE video_thumbnails SET selected = FALSE WHERE video_id = $1 AND id <> $2 AND selected`,
+		t.VideoID, t.ID,
+	); err != nil {
+		return fmt.Errorf("clearing selected thumbnail of video %s: %w", t.VideoID, err)
+	}
+	tag, err := tx.Exec(ctx,
+		`UPDATE video_thumbnails SET selected = TRUE WHERE id = $1 AND status = $2`,
+		t.ID, domain.ThumbnailReady,
+	)
+	if err != nil {
+		return fmt.Errorf("selecting thumbnail %s: %w", t.ID, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrThumbnailNotReady
+	}
+	tag, err = tx.Exec(ctx, `UPDATE videos SET thumbnail_path = $2 WHERE id = $1`, t.VideoID, t.Key)
+	if err != nil {
+		return fmt.Errorf("updating thumbnail of video %s: %w", t.VideoID, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrVideoNotFound
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("committing thumbnail selection: %w", err)
+	}
+	return nil
+}
+
+func (r *ThumbnailRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status domain.ThumbnailStatus) error {
+	tag, err := r.pool.Exec(ctx, `UPDATE video_thumbnails SET status = $2 WHERE id = $1`, id, status)
+	if err != nil {
+		return fmt.Errorf("updating thumbnail %s: %w", id, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrThumbnailNotFound
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+)
+
+const uploadSessionColumns = `
+	id, method, user_id, filename, mime_type, title, description, visibility, normalize_audio,
+	upload_length, upload_offset, parts, storage_key, multipart_id, video_id,
+	completed_at, expires_at, created_at, updated_at`
+
+// UploadSessionRepository stores resumable upload sessions.
+type UploadSessionRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewUploadSessionRepository(pool *pgxpool.Pool) *UploadSessionRepository {
+	return &UploadSessionRepository{pool: pool}
+}
+
+func scanUploadSession(row scanner) (*domain.UploadSession, error) {
+	var s domain.UploadSession
+	err := row.Scan(
+		&s.ID, &s.Method, &s.UserID, &s.Filename, &s.MimeType, &s.Title, &s.Description, &s.Visibility, &s.NormalizeAudio,
+		&s.Length, &s.Offset, &s.Parts, &s.StorageKey, &s.MultipartID, &s.VideoID,
+		&s.CompletedAt, &s.ExpiresAt, &s.CreatedAt, &s.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *UploadSessionRepository) Create(ctx context.Context, s *domain.UploadSession) error {
+	const query = `
+		INSERT INTO upload_sessions (
+			id, method, user_id, filename, mime_type, title, description, visibility, normalize_audio,
+			upload_length, upload_offset, parts, storage_key, multipart_id,
+			expires_at, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)`
+
+	_, err := r.pool.Exec(ctx, query,
+		s.ID, s.Method, s.UserID, s.Filename, s.MimeType, s.Title, s.Description, s.Visibility, s.NormalizeAudio,
+		s.Length, s.Offset, s.Parts, s.StorageKey, s.MultipartID,
+		s.ExpiresAt, s.CreatedAt, s.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("creating upload session: %w", err)
+	}
+	return nil
+}
+
+func (r *UploadSessionRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.UploadSession, error) {
+	query := `SELECT` + uploadSessionColumns + ` FROM upload_sessions WHERE id = $1`
+
+	s, err := scanUploadSession(r.pool.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrUploadSessionNotFound
+		}
+		return nil, fmt.Errorf("getting upload session %s: %w", id, err)
+	}
+	return s, nil
+}
+
+// AdvanceOffset records a part that starts at from and moves the session's
+// offset to to. The update is conditional on the offset still being from, so
+// two writers racing on one session cannot both commit: the loser gets
+// ErrUploadOffsetMismatch and its part is never referenced.
+func (r *UploadSessionRepository) AdvanceOffset(ctx context.Context, id uuid.UUID, from, to int64) error {
+	tag, err := r.pool.Exec(ctx,
+		`UPDATE upload_sessions
+		 SET upload_offset = $3, parts = array_append(parts, $2), updated_at = NOW()
+		 WHERE id = $1 AND upload_offset = $2`,
+		id, from, to,
+	)
+	if err != nil {
+		return fmt.Errorf("advancing upload session %s: %w", id, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrUploadOffsetMismatch
+	}
+	return nil
+}
+
+// MarkCompleted links the session to the video its assembled file became.
+func (r *UploadSessionRepository) MarkCompleted(ctx context.Context, id, videoID uuid.UUID) error {
+	tag, err := r.pool.Exec(ctx,
+		`UPDATE upload_sessions
+		 SET video_id = $2, completed_at = NOW(), updated_at = NOW()
+		 WHERE id = $1`,
+		id, videoID,
+	)
+	if err != nil {
+		return fmt.Errorf("completing upload session %s: %w", id, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrUploadSessionNotFound
+	}
+	return nil
+}
+
+func (r *UploadSessionRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	if _, err := r.pool.Exec(ctx, `DELETE FROM upload_sessions WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("deleting upload session %s: %w", id, err)
+	}
+	return nil
+}
+
+// ListExpired returns up to limit sessions whose deadline passed before
+// cutoff, oldest first.
+func (r *UploadSessionRepository) ListExpired(ctx context.Context, cutoff time.Time, limit int) ([]*domain.UploadSession, error) {
+	query := `SELECT` + uploadSessionColumns + `
+		FROM upload_sessions
+		WHERE expires_at < $1
+		ORDER BY expires_at
+		LIMIT $2`
+
+	rows, err := r.pool.Query(ctx, query, cutoff, limit)
+	if err != nil {
+		return nil, fmt.Errorf("listing expired upload sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []*domain.UploadSession
+	for rows.Next() {
+		s, err := scanUploadSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning upload session: %w", err)
+		}
+		sessions = append(sessions, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating upload sessions: %w", err)
+	}
+	return sessions, nil
+}
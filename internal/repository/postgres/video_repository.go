@@ -2,9 +2,11 @@ package postgres
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -24,10 +26,11 @@ import (
 // not 0, and scanning a NULL into an int64 fails.
 const videoColumns = `
 	id, user_id, title, description, filename, file_path, file_size, mime_type,
-	duration, original_resolution, thumbnail_path, status, visibility,
-	transcoding_progress, available_qualities, hls_master_path, hls_ready,
-	streaming_protocol,
-	COALESCE(category, ''), tags, COALESCE(language, ''),
+	duration, original_resolution, thumbnail_path, preview_path, status, visibility,
+	normalize_audio, audio_loudness, transcoding_progress, transcoding_eta, available_qualities, hls_master_path, hls_ready,
+	output_revision, streaming_protocol, processing_error,
+	COALESCE(category, ''), tags, COALESCE(language, ''), parent_id, clip, import_source,
+	trim_state, premiere_at, chapters,
 	COALESCE(view_count, 0), COALESCE(like_count, 0), COALESCE(comment_count, 0),
 	created_at, updated_at, processed_at`
 
@@ -73,16 +76,28 @@ func videoScanDest(v *domain.Video) []any {
 		&v.Duration,
 		&v.OriginalResolution,
 		&v.ThumbnailPath,
+		&v.PreviewPath,
 		&v.Status,
 		&v.Visibility,
+		&v.NormalizeAudio,
+		&v.Loudness,
 		&v.TranscodingProgress,
+		&v.TranscodingETA,
 		&v.AvailableQualities,
 		&v.HLSMasterPath,
 		&v.HLSReady,
+		&v.OutputRevision,
 		&v.StreamingProtocol,
+		&v.ProcessingError,
 		&v.Category,
 		&v.Tags,
 		&v.Language,
+		&v.ParentID,
+		&v.Clip,
+		&v.Import,
+		&v.Trim,
+		&v.PremiereAt,
+		&v.Chapters,
 		&v.ViewCount,
 		&v.LikeCount,
 		&v.CommentCount,
@@ -106,8 +121,8 @@ func (r *PostgresVideoRepository) Create(ctx context.Context, video *domain.Vide
 		INSERT INTO videos (
 			id, user_id, title, description, filename, file_path, file_size,
 			mime_type, duration, original_resolution, status, visibility,
-			created_at, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`
+			normalize_audio, category, parent_id, clip, import_source, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, NULLIF($14, ''), $15, $16, $17, $18, $19)`
 
 	_, err := r.pool.Exec(ctx, query,
 		video.ID,
@@ -122,6 +137,11 @@ func (r *PostgresVideoRepository) Create(ctx context.Context, video *domain.Vide
 		video.OriginalResolution,
 		video.Status,
 		video.Visibility,
+		video.NormalizeAudio,
+		video.Category,
+		video.ParentID,
+		video.Clip,
+		video.Import,
 		video.CreatedAt,
 		video.UpdatedAt,
 	)
@@ -166,6 +186,25 @@ func buildFilter(filter repository.VideoFilter) (where string, args []any) {
 		args = append(args, *filter.Visibility)
 		conditions = append(conditions, fmt.Sprintf("visibility = $%d", len(args)))
 	}
+	if filter.ParentID != nil {
+		args = append(args, *filter.ParentID)
+		conditions = append(conditions, fmt.Sprintf("parent_id = $%d", len(args)))
+	}
+	if filter.CreatedAfter != nil {
+		args = append(args, *filter.CreatedAfter)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if filter.CreatedBefore != nil {
+		args = append(args, *filter.CreatedBefore)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+	if filter.MissingQuality != "" {
+		args = append(args, filter.MissingQuality)
+		conditions = append(conditions, fmt.Sprintf("NOT ($%d = ANY(COALESCE(available_qualities, '{}')))", len(args)))
+	}
+	if filter.MissingHLS {
+		conditions = append(conditions, "NOT COALESCE(hls_ready, FALSE)")
+	}
 	if search := strings.TrimSpace(filter.Search); search != "" {
 		// Uses the search_vector GIN index added in migration 8. The old query
 		// was an unanchored ILIKE '%...%', which cannot use an index and forced
@@ -227,8 +266,11 @@ func (r *PostgresVideoRepository) UpdateStatus(ctx context.Context, id uuid.UUID
 	return r.exec(ctx, `UPDATE videos SET status = $2, updated_at = NOW() WHERE id = $1`, id, status)
 }
 
-func (r *PostgresVideoRepository) UpdateProgress(ctx context.Context, id uuid.UUID, progress int) error {
-	return r.exec(ctx, `UPDATE videos SET transcoding_progress = $2, updated_at = NOW() WHERE id = $1`, id, progress)
+func (r *PostgresVideoRepository) UpdateProgress(ctx context.Context, id uuid.UUID, progress int, eta *time.Time) error {
+	return r.exec(ctx,
+		`UPDATE videos SET transcoding_progress = $2, transcoding_eta = $3, updated_at = NOW() WHERE id = $1`,
+		id, progress, eta,
+	)
 }
 
 func (r *PostgresVideoRepository) UpdateDuration(ctx context.Context, id uuid.UUID, duration int) error {
@@ -239,12 +281,63 @@ func (r *PostgresVideoRepository) UpdateResolution(ctx context.Context, id uuid.
 	return r.exec(ctx, `UPDATE videos SET original_resolution = $2, updated_at = NOW() WHERE id = $1`, id, resolution)
 }
 
-func (r *PostgresVideoRepository) UpdateHLSInfo(ctx context.Context, id uuid.UUID, hlsMasterPath string, hlsReady bool) error {
+func (r *PostgresVideoRepository) UpdateSource(ctx context.Context, id uuid.UUID, filePath, mimeType string, fileSize int64) error {
 	return r.exec(ctx,
 		`UPDATE videos
-		 SET hls_master_path = $2, hls_ready = $3, streaming_protocol = 'hls', updated_at = NOW()
+		 SET file_path = $2, mime_type = $3, file_size = $4, status = 'uploading',
+		     processing_error = NULL, updated_at = NOW()
 		 WHERE id = $1`,
-		id, hlsMasterPath, hlsReady,
+		id, filePath, mimeType, fileSize,
+	)
+}
+
+func (r *PostgresVideoRepository) BeginTrim(ctx context.Context, id uuid.UUID, trim *domain.VideoTrim) error {
+	tag, err := r.pool.Exec(ctx,
+		`UPDATE videos SET trim_state = $2, updated_at = NOW()
+		 WHERE id = $1 AND trim_state IS NULL AND file_path = $3`,
+		id, trim, trim.FilePath,
+	)
+	if err != nil {
+		return fmt.Errorf("updating video %s: %w", id, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrTrimInProgress
+	}
+	return nil
+}
+
+func (r *PostgresVideoRepository) RecordTrim(ctx context.Context, id uuid.UUID, filePath string, fileSize int64, chapters []domain.Chapter) error {
+	if chapters == nil {
+		chapters = []domain.Chapter{}
+	}
+	return r.exec(ctx,
+		`UPDATE videos SET file_path = $2, file_size = $3, chapters = $4, updated_at = NOW()
+		 WHERE id = $1 AND trim_state IS NOT NULL`,
+		id, filePath, fileSize, chapters,
+	)
+}
+
+func (r *PostgresVideoRepository) FinishTrim(ctx context.Context, id uuid.UUID) error {
+	return r.exec(ctx, `UPDATE videos SET trim_state = NULL, updated_at = NOW() WHERE id = $1`, id)
+}
+
+func (r *PostgresVideoRepository) RevertTrim(ctx context.Context, id uuid.UUID) error {
+	return r.exec(ctx,
+		`UPDATE videos
+		 SET file_path = trim_state->>'file_path', mime_type = trim_state->>'mime_type',
+		     file_size = (trim_state->>'file_size')::BIGINT, duration = (trim_state->>'duration')::INT,
+		     chapters = COALESCE(trim_state->'chapters', '[]'), trim_state = NULL, updated_at = NOW()
+		 WHERE id = $1 AND trim_state IS NOT NULL`,
+		id,
+	)
+}
+
+func (r *PostgresVideoRepository) UpdateHLSInfo(ctx context.Context, id uuid.UUID, hlsMasterPath string, revision int, hlsReady bool, protocol string) error {
+	return r.exec(ctx,
+		`UPDATE videos
+		 SET hls_master_path = $2, output_revision = $3, hls_ready = $4, streaming_protocol = $5, updated_at = NOW()
+		 WHERE id = $1`,
+		id, hlsMasterPath, revision, hlsReady, protocol,
 	)
 }
 
@@ -252,14 +345,101 @@ func (r *PostgresVideoRepository) MarkAsReady(ctx context.Context, id uuid.UUID,
 	return r.exec(ctx,
 		`UPDATE videos
 		 SET status = $2, available_qualities = $3, thumbnail_path = $4,
-		     transcoding_progress = 100, processed_at = NOW(), updated_at = NOW()
+		     transcoding_progress = 100, transcoding_eta = NULL, processing_error = NULL,
+		     processed_at = NOW(), updated_at = NOW()
 		 WHERE id = $1`,
 		id, domain.VideoStatusReady, qualities, thumbnailPath,
 	)
 }
 
+// UpdateLoudness records the video's measured loudness; nil clears it.
+func (r *PostgresVideoRepository) UpdateLoudness(ctx context.Context, id uuid.UUID, loudness *domain.AudioLoudness) error {
+	return r.exec(ctx,
+		`UPDATE videos SET audio_loudness = $2, updated_at = NOW() WHERE id = $1`,
+		id, loudness,
+	)
+}
+
+func (r *PostgresVideoRepository) UpdatePreviewPath(ctx context.Context, id uuid.UUID, previewPath string) error {
+	return r.exec(ctx,
+		`UPDATE videos SET preview_path = NULLIF($2, ''), updated_at = NOW() WHERE id = $1`,
+		id, previewPath,
+	)
+}
+
 func (r *PostgresVideoRepository) MarkAsFailed(ctx context.Context, id uuid.UUID) error {
-	return r.exec(ctx, `UPDATE videos SET status = $2, updated_at = NOW() WHERE id = $1`, id, domain.VideoStatusFailed)
+	return r.exec(ctx,
+		`UPDATE videos
+		 SET status = $2, processing_error = NULL, transcoding_eta = NULL, updated_at = NOW()
+		 WHERE id = $1`,
+		id, domain.VideoStatusFailed,
+	)
+}
+
+func (r *PostgresVideoRepository) MarkAsRejected(ctx context.Context, id uuid.UUID, perr domain.ProcessingError) error {
+	return r.exec(ctx,
+		`UPDATE videos
+		 SET status = $2, processing_error = $3, transcoding_eta = NULL, updated_at = NOW()
+		 WHERE id = $1`,
+		id, domain.VideoStatusFailed, perr,
+	)
+}
+
+func (r *PostgresVideoRepository) GetEncodingLadder(ctx context.Context, id uuid.UUID) (*domain.EncodingLadder, error) {
+	var data []byte
+	err := r.pool.QueryRow(ctx, `SELECT encoding_ladder FROM videos WHERE id = $1`, id).Scan(&data)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrVideoNotFound
+		}
+		return nil, fmt.Errorf("getting encoding ladder of video %s: %w", id, err)
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	var ladder domain.EncodingLadder
+	if err := json.Unmarshal(data, &ladder); err != nil {
+		return nil, fmt.Errorf("decoding encoding ladder of video %s: %w", id, err)
+	}
+	return &ladder, nil
+}
+
+func (r *PostgresVideoRepository) UpdateEncodingLadder(ctx context.Context, id uuid.UUID, ladder *domain.EncodingLadder) error {
+	// A nil ladder clears the column rather than storing a JSON null.
+	var data []byte
+	if ladder != nil {
+		var err error
+		if data, err = json.Marshal(ladder); err != nil {
+			return fmt.Errorf("encoding ladder of video %s: %w", id, err)
+		}
+	}
+	return r.exec(ctx, `UPDATE videos SET encoding_ladder = $2, updated_at = NOW() WHERE id = $1`, id, data)
+}
+
+// UpdateChapters replaces the video's chapters; nil clears them.
+func (r *PostgresVideoRepository) UpdateChapters(ctx context.Context, id uuid.UUID, chapters []domain.Chapter) error {
+	// The column is NOT NULL: no chapters is an empty array, not a JSON null.
+	if chapters == nil {
+		chapters = []domain.Chapter{}
+	}
+	return r.exec(ctx, `UPDATE videos SET chapters = $2, updated_at = NOW() WHERE id = $1`, id, chapters)
+}
+
+// SetPremiere schedules the video's premiere at at, or cancels it with nil.
+// Whether one has started is checked in the update itself, against the
+// database's clock, so a premiere cannot be moved once viewers are watching
+// it.
+func (r *PostgresVideoRepository) SetPremiere(ctx context.Context, id uuid.UUID, at *time.Time) (bool, error) {
+	tag, err := r.pool.Exec(ctx,
+		`UPDATE videos SET premiere_at = $2, updated_at = NOW()
+		 WHERE id = $1 AND (premiere_at IS NULL OR premiere_at > NOW())`,
+		id, at,
+	)
+	if err != nil {
+		return false, fmt.Errorf("setting premiere of video %s: %w", id, err)
+	}
+	return tag.RowsAffected() == 1, nil
 }
 
 // exec runs a statement whose first argument is the video ID and reports
@@ -0,0 +1,314 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/config"
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+)
+
+const (
+	// audioGroupID is the HLS rendition group every audio track belongs to
+	// and every video variant names in its AUDIO attribute.
+	audioGroupID = "audio"
+	// aacLCCodec is the RFC 6381 codec of every audio rendition; see
+	// audioEncodeArgs.
+	aacLCCodec = "mp4a.40.2"
+	// maxLabelLength matches the label columns of video_audio_tracks and
+	// video_captions.
+	maxLabelLength = 100
+)
+
+// sourceAudioTracks describes the audio streams of a probed upload as the
+// tracks its encode stages write, named audio_0, audio_1 and so on in stream
+// order. The container's language tag and title carry over where they are
+// usable, and the stream it marks default is the default track; failing that,
+// the first one is.
+func sourceAudioTracks(videoID uuid.UUID, streams []AudioStream) []*domain.AudioTrack {
+	now := time.Now()
+	tracks := make([]*domain.AudioTrack, 0, len(streams))
+	defaultAt := 0
+	for i, stream := range streams {
+		language, err := domain.NormalizeLanguageTag(stream.Language)
+		if err != nil {
+			language = domain.UndeterminedLanguage
+		}
+		tracks = append(tracks, &domain.AudioTrack{
+			ID:          uuid.New(),
+			VideoID:     videoID,
+			Name:        config.AudioRenditionPrefix + strconv.Itoa(i),
+			Language:    language,
+			Label:       sanitizeLabel(stream.Title),
+			Kind:        domain.AudioTrackSource,
+			Status:      domain.AudioTrackReady,
+			StreamIndex: i,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		})
+		if stream.Default && !streams[defaultAt].Default {
+			defaultAt = i
+		}
+	}
+	if len(tracks) > 0 {
+		tracks[defaultAt].IsDefault = true
+	}
+	return tracks
+}
+
+// dubRenditionName names the rendition of a dubbed track after its ID, so it
+// cannot collide with a source track or another dub.
+func dubRenditionName(id uuid.UUID) string {
+	return config.AudioRenditionPrefix + id.String()[:8]
+}
+
+// trackNames lists the rendition names of tracks.
+func trackNames(tracks []*domain.AudioTrack) []string {
+	names := make([]string, len(tracks))
+	for i, track := range tracks {
+		names[i] = track.Name
+	}
+	return names
+}
+
+// defaultTrack returns the track a player starts with, or nil when there is
+// no audio at all.
+func defaultTrack(tracks []*domain.AudioTrack) *domain.AudioTrack {
+	for _, track := range tracks {
+		if track.IsDefault {
+			return track
+		}
+	}
+	if len(tracks) > 0 {
+		return tracks[0]
+	}
+	return nil
+}
+
+// sanitizeLabel makes label safe to quote in a playlist attribute and
+// fit its column: control characters and double quotes go, the rest is
+// trimmed and cut to length.
+func sanitizeLabel(label string) string {
+	label = strings.Map(func(r rune) rune {
+		if r == '"' || unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, label)
+	label = strings.TrimSpace(label)
+	if runes := []rune(label); len(runes) > maxLabelLength {
+		label = strings.TrimSpace(string(runes[:maxLabelLength]))
+	}
+	return label
+}
+
+// audioTrackLabels names each track for a player's menu; see menuLabels.
+func audioTrackLabels(tracks []*domain.AudioTrack) []string {
+	labels := make([]string, len(tracks))
+	languages := make([]string, len(tracks))
+	for i, track := range tracks {
+		labels[i], languages[i] = track.Label, track.Language
+	}
+	return menuLabels(labels, languages, "Audio")
+}
+
+// writeAudioGroup rewrites master.m3u8 in hlsDir to offer tracks as the audio
+// group of every video variant. ffmpeg's own renditions of the group carry no
+// language or label and its audio-only variants are not meant to be played
+// alone, so both are dropped and the group is written out afresh; the rewrite
+// is idempotent, and a dub is added by running it again with one more track.
+// A video that had no audio of its own gains the group's codec.
+func writeAudioGroup(hlsDir string, tracks []*domain.AudioTrack) error {
+	labels := audioTrackLabels(tracks)
+	media := make([]string, len(tracks))
+	for i, track := range tracks {
+		attrs := []string{
+			"TYPE=AUDIO",
+			fmt.Sprintf("GROUP-ID=%q", audioGroupID),
+			fmt.Sprintf("NAME=%q", labels[i]),
+		}
+		if track.Language != domain.UndeterminedLanguage {
+			attrs = append(attrs, fmt.Sprintf("LANGUAGE=%q", track.Language))
+		}
+		if track.IsDefault {
+			attrs = append(attrs, "DEFAULT=YES")
+		} else {
+			attrs = append(attrs, "DEFAULT=NO")
+		}
+		attrs = append(attrs,
+			"AUTOSELECT=YES",
+			`CHANNELS="2"`,
+			fmt.Sprintf("URI=%q", track.Name+"/playlist.m3u8"),
+		)
+		media[i] = "#EXT-X-MEDIA:" + strings.Join(attrs, ",")
+	}
+
+	return rewriteMasterGroup(hlsDir, "AUDIO", media, func(attrs string) string {
+		if len(tracks) == 0 {
+			return attrs
+		}
+		attrs = setAttribute(attrs, "AUDIO", strconv.Quote(audioGroupID))
+		if codecs := parseAttributes(attrs)["CODECS"]; codecs != "" {
+			if _, audioCodec := splitCodecs(codecs); audioCodec == "" {
+				attrs = setAttribute(attrs, "CODECS", strconv.Quote(codecs+","+aacLCCodec))
+			}
+		}
+		return attrs
+	})
+}
+
+// audioRenditionArgs builds the ffmpeg command line that encodes stream, a
+// -map specifier of inputPath, as the audio rendition name in dir, through
+// filter unless it is "". A length in seconds pads or cuts the audio to it;
+// 0 keeps it as it is.
+func audioRenditionArgs(inputPath, stream, dir, name, packaging string, length int, filter string) []string {
+	args := []string{"-i", inputPath, "-map", stream, "-vn", "-sn"}
+	args = append(args, audioEncodeArgs...)
+	args = append(args, strippedMetadataArgs...)
+	var filters []string
+	if filter != "" {
+		filters = append(filters, filter)
+	}
+	if length > 0 {
+		filters = append(filters, "apad")
+		args = append(args, "-t", strconv.Itoa(length))
+	}
+	if len(filters) > 0 {
+		args = append(args, "-af", strings.Join(filters, ","))
+	}
+	args = append(args, hlsMuxerArgs(packaging, "init_"+name+".mp4")...)
+	return append(args,
+		"-hls_segment_filename", filepath.Join(dir, "segment_%03d"+segmentExtension(packaging)),
+		"-progress", "pipe:1",
+		"-nostats",
+		"-y",
+		filepath.Join(dir, "playlist.m3u8"),
+	)
+}
+
+// EncodeDub encodes a dubbed track's file to an audio rendition beside its
+// video's renditions. It does not publish the rendition: see PublishDub. The
+// master playlist must be in place locally, since the rendition is packaged
+// to match it.
+//
+// A file that cannot be used fails the track.
+func (s *TranscodingService) EncodeDub(ctx context.Context, track *domain.AudioTrack) error {
+	video, err := s.videoRepo.GetByID(ctx, track.VideoID)
+	if err != nil {
+		return fmt.Errorf("failed to get video: %w", err)
+	}
+	if !video.HLSReady {
+		return fmt.Errorf("dub %s: %w", track.ID, domain.ErrVideoNotReady)
+	}
+
+	if _, err := s.ffmpegService.ExtractAudioMetadata(ctx, track.FilePath); err != nil {
+		s.markTrackFailed(ctx, track)
+		return fmt.Errorf("failed to probe dub: %w", err)
+	}
+
+	hlsDir := s.hlsDir(video.ID, video.OutputRevision)
+	packaging, err := masterPackaging(hlsDir)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(hlsDir, track.Name)
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("clearing audio rendition directory: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create audio rendition directory: %w", err)
+	}
+
+	// Padded or cut to the video's length, so the track ends with the
+	// picture and the DASH period's duration still holds.
+	args := audioRenditionArgs(track.FilePath, "0:a:0", dir, track.Name, packaging, video.Duration, "")
+	if err := s.runFFmpeg(ctx, args, 0, func(float64) {}); err != nil {
+		s.markTrackFailed(ctx, track)
+		return fmt.Errorf("failed to encode dub: %w", err)
+	}
+	if segments, err := filepath.Glob(filepath.Join(dir, "segment_*"+segmentExtension(packaging))); err != nil || len(segments) == 0 {
+		s.markTrackFailed(ctx, track)
+		return fmt.Errorf("no segments written for %s", track.Name)
+	}
+
+	s.log.Info(ctx, "encoded dubbed audio track", map[string]interface{}{
+		"video_id": video.ID,
+		"track_id": track.ID,
+		"name":     track.Name,
+		"language": track.Language,
+	})
+	return nil
+}
+
+// PublishDub adds an encoded dub to its video's master playlist and, for a
+// CMAF video, its DASH manifest, both of which must be in place locally. The
+// master lists every ready track besides this one, so publishing is safe to
+// repeat; it is not safe to run for two tracks of one video at once, and the
+// caller must serialize it. The track is left for the caller to mark ready
+// once the files are where they are served from.
+//
+// A manifest that cannot be updated costs the dub DASH, not HLS.
+func (s *TranscodingService) PublishDub(ctx context.Context, track *domain.AudioTrack) error {
+	video, err := s.videoRepo.GetByID(ctx, track.VideoID)
+	if err != nil {
+		return fmt.Errorf("failed to get video: %w", err)
+	}
+	existing, err := s.audioTracks.ListByVideo(ctx, track.VideoID)
+	if err != nil {
+		return fmt.Errorf("failed to list audio tracks: %w", err)
+	}
+
+	var tracks []*domain.AudioTrack
+	for _, t := range existing {
+		if t.ID == track.ID || t.Status == domain.AudioTrackReady {
+			tracks = append(tracks, t)
+		}
+	}
+
+	hlsDir := s.hlsDir(video.ID, video.OutputRevision)
+	if err := writeAudioGroup(hlsDir, tracks); err != nil {
+		return err
+	}
+
+	if video.HasDASH() {
+		labels := audioTrackLabels(tracks)
+		for i, t := range tracks {
+			if t.ID != track.ID {
+				continue
+			}
+			if err := addDASHAudio(hlsDir, track, labels[i]); err != nil {
+				s.log.Error(ctx, "failed to add dub to DASH manifest; serving it over HLS only", err, map[string]interface{}{
+					"video_id": video.ID,
+					"track_id": track.ID,
+				})
+			}
+		}
+	}
+	return nil
+}
+
+// MarkDubReady records that a published dub is being served.
+func (s *TranscodingService) MarkDubReady(ctx context.Context, track *domain.AudioTrack) error {
+	if err := s.audioTracks.UpdateStatus(ctx, track.ID, domain.AudioTrackReady); err != nil {
+		return fmt.Errorf("failed to mark audio track ready: %w", err)
+	}
+	return nil
+}
+
+func (s *TranscodingService) markTrackFailed(ctx context.Context, track *domain.AudioTrack) {
+	if err := s.audioTracks.UpdateStatus(ctx, track.ID, domain.AudioTrackFailed); err != nil {
+		s.log.Error(ctx, "failed to mark audio track as failed", err, map[string]interface{}{
+			"video_id": track.VideoID,
+			"track_id": track.ID,
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"mime/multipart"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/config"
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/internal/storage"
+	"github.com/Nuu-maan/video-streaming-service/pkg/logger"
+	"github.com/Nuu-maan/video-streaming-service/pkg/validator"
+)
+
+// maxAudioTracks bounds how many audio tracks one video may carry, source and
+// dubs together. Every one is a line in the master playlist and a rendition a
+// player may list.
+const maxAudioTracks = 16
+
+// AudioTrackRepository stores the audio renditions of videos.
+type AudioTrackRepository interface {
+	Create(ctx context.Context, track *domain.AudioTrack) error
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.AudioTrack, error)
+	// ListByVideo returns a video's tracks, source tracks first in stream
+	// order, then dubs in the order they were added.
+	ListByVideo(ctx context.Context, videoID uuid.UUID) ([]*domain.AudioTrack, error)
+	// ReplaceSourceTracks swaps a video's source tracks for tracks, leaving
+	// its dubs alone.
+	ReplaceSourceTracks(ctx context.Context, videoID uuid.UUID, tracks []*domain.AudioTrack) error
+	UpdateStatus(ctx context.Context, id uuid.UUID, status domain.AudioTrackStatus) error
+}
+
+// AudioTrackService lists a video's audio tracks and accepts dubbed ones. A
+// dub is stored raw under raw/dubs/<video>/ and recorded pending; encoding it
+// is the worker's job, as for the video itself.
+type AudioTrackService struct {
+	tracks     AudioTrackRepository
+	store      storage.Store
+	storageCfg *config.StorageConfig
+	log        *logger.Logger
+}
+
+func NewAudioTrackService(
+	tracks AudioTrackRepository,
+	store storage.Store,
+	storageCfg *config.StorageConfig,
+	log *logger.Logger,
+) *AudioTrackService {
+	return &AudioTrackService{
+		tracks:     tracks,
+		store:      store,
+		storageCfg: storageCfg,
+		log:        log,
+	}
+}
+
+// ListTracks returns video's audio tracks. Only ready ones are listed unless
+// all is set, which is for the video's owner, who wants to see a dub that is
+// still encoding or that failed.
+func (s *AudioTrackService) ListTracks(ctx context.Context, video *domain.Video, all bool) ([]*domain.AudioTrack, error) {
+	tracks, err := s.tracks.ListByVideo(ctx, video.ID)
+	if err != nil {
+		return nil, err
+	}
+	if all {
+		return tracks, nil
+	}
+
+	ready := make([]*domain.AudioTrack, 0, len(tracks))
+	for _, track := range tracks {
+		if track.Status == domain.AudioTrackReady {
+			ready = append(ready, track)
+		}
+	}
+	return ready, nil
+}
+
+// AddDubRequest describes a dubbed audio track uploaded for a video.
+type AddDubRequest struct {
+	File     multipart.File
+	Header   *multipart.FileHeader
+	Language string
+	Label    string
+}
+
+// AddDub validates a dub for video, stores its file, and records it pending.
+// The caller has established that it may modify video, and queues the track
+// for encoding.
+func (s *AudioTrackService) AddDub(ctx context.Context, video *domain.Video, req AddDubRequest) (track *domain.AudioTrack, err error) {
+	if video.Status != domain.VideoStatusReady || !video.HLSReady {
+		return nil, domain.ErrVideoNotReady
+	}
+	if strings.TrimSpace(req.Language) == "" {
+		return nil, fmt.Errorf("%w: a language is required", domain.ErrInvalidLanguage)
+	}
+	language, err := domain.NormalizeLanguageTag(req.Language)
+	if err != nil {
+		return nil, err
+	}
+	label := validator.SanitizeString(req.Label)
+	if len([]rune(label)) > maxLabelLength {
+		return nil, fmt.Errorf("%w: label cannot exceed %d characters", domain.ErrInvalidInput, maxLabelLength)
+	}
+	label = sanitizeLabel(label)
+	if err := validator.ValidateAudioFile(req.File, req.Header, s.storageCfg.MaxFileSize); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.tracks.ListByVideo(ctx, video.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxAudioTracks {
+		return nil, fmt.Errorf("%w: a video may have at most %d audio tracks", domain.ErrInvalidInput, maxAudioTracks)
+	}
+
+	now := time.Now()
+	track = &domain.AudioTrack{
+		ID:        uuid.New(),
+		VideoID:   video.ID,
+		Language:  language,
+		Label:     label,
+		Kind:      domain.AudioTrackDub,
+		Status:    domain.AudioTrackPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	track.Name = dubRenditionName(track.ID)
+
+	// As with a video's raw file, the stored name comes from the ID and only
+	// the extension from the client.
+	key := DubKey(video.ID, track.ID.String()+strings.ToLower(filepath.Ext(req.Header.Filename)))
+	track.FilePath = filepath.Join(s.storageCfg.UploadPath, filepath.FromSlash(key))
+
+	contentType := req.Header.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "audio/" + strings.TrimPrefix(strings.ToLower(filepath.Ext(req.Header.Filename)), ".")
+	}
+	if err := s.store.Save(ctx, key, req.File, req.Header.Size, contentType); err != nil {
+		return nil, fmt.Errorf("storing dub: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			s.discardDub(ctx, key)
+		}
+	}()
+
+	if err := s.tracks.Create(ctx, track); err != nil {
+		return nil, err
+	}
+
+	s.log.Info(ctx, "dubbed audio track added", map[string]interface{}{
+		"video_id": video.ID,
+		"track_id": track.ID,
+		"language": track.Language,
+		"size":     req.Header.Size,
+	})
+	return track, nil
+}
+
+// DubKey is the storage key of a dub's raw file, name being its base name.
+// The worker rebuilds it from the track's FilePath to stage the file.
+func DubKey(videoID uuid.UUID, name string) string {
+	return storage.Key("raw", "dubs", videoID.String(), path.Base(name))
+}
+
+// discardDub removes a dub whose track was never recorded.
+func (s *AudioTrackService) discardDub(ctx context.Context, key string) {
+	if err := s.store.Delete(ctx, key); err != nil {
+		s.log.Error(ctx, "failed to clean up orphaned dub", err, map[string]interface{}{
+			"key": key,
+		})
+	}
+}
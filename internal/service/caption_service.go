@@ -0,0 +1,227 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/config"
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/internal/storage"
+	"github.com/Nuu-maan/video-streaming-service/pkg/logger"
+	"github.com/Nuu-maan/video-streaming-service/pkg/validator"
+	"github.com/Nuu-maan/video-streaming-service/pkg/webvtt"
+)
+
+const (
+	// maxCaptions bounds how many text tracks one video may carry, embedded
+	// and uploaded together.
+	maxCaptions = 32
+	// maxCaptionFileSize bounds an uploaded caption file. A feature film's
+	// subtitles run to a few hundred kilobytes.
+	maxCaptionFileSize = 5 << 20
+)
+
+// CaptionRepository stores the text tracks of videos.
+type CaptionRepository interface {
+	Create(ctx context.Context, caption *domain.Caption) error
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.Caption, error)
+	// ListByVideo returns a video's captions, embedded ones first in stream
+	// order, then uploaded ones in the order they were added.
+	ListByVideo(ctx context.Context, videoID uuid.UUID) ([]*domain.Caption, error)
+	// ReplaceEmbeddedCaptions swaps a video's embedded captions for captions,
+	// leaving uploaded ones alone.
+	ReplaceEmbeddedCaptions(ctx context.Context, videoID uuid.UUID, captions []*domain.Caption) error
+	// MarkReady records a caption ready, and if it is the default, takes the
+	// default from the video's other captions.
+	MarkReady(ctx context.Context, caption *domain.Caption) error
+	UpdateStatus(ctx context.Context, id uuid.UUID, status domain.CaptionStatus) error
+}
+
+// CaptionService lists a video's captions and accepts uploaded ones. An
+// upload is converted to WebVTT, stored under raw/captions/<video>/ and
+// recorded pending; segmenting and publishing it is the worker's job.
+type CaptionService struct {
+	captions   CaptionRepository
+	store      storage.Store
+	storageCfg *config.StorageConfig
+	log        *logger.Logger
+}
+
+func NewCaptionService(
+	captions CaptionRepository,
+	store storage.Store,
+	storageCfg *config.StorageConfig,
+	log *logger.Logger,
+) *CaptionService {
+	return &CaptionService{
+		captions:   captions,
+		store:      store,
+		storageCfg: storageCfg,
+		log:        log,
+	}
+}
+
+// ListCaptions returns video's captions. Only ready ones are listed unless
+// all is set, which is for the video's owner.
+func (s *CaptionService) ListCaptions(ctx context.Context, video *domain.Video, all bool) ([]*domain.Caption, error) {
+	captions, err := s.captions.ListByVideo(ctx, video.ID)
+	if err != nil {
+		return nil, err
+	}
+	if all {
+		return captions, nil
+	}
+
+	ready := make([]*domain.Caption, 0, len(captions))
+	for _, caption := range captions {
+		if caption.Status == domain.CaptionReady {
+			ready = append(ready, caption)
+		}
+	}
+	return ready, nil
+}
+
+// AddCaptionRequest describes a caption file uploaded for a video.
+type AddCaptionRequest struct {
+	File     multipart.File
+	Header   *multipart.FileHeader
+	Language string
+	Label    string
+	// Kind defaults to subtitles.
+	Kind      domain.CaptionKind
+	IsDefault bool
+}
+
+// AddCaption validates a SubRip or WebVTT file for video, stores it as
+// WebVTT, and records the caption pending. The caller has established that
+// it may modify video, and queues the caption for publishing.
+func (s *CaptionService) AddCaption(ctx context.Context, video *domain.Video, req AddCaptionRequest) (caption *domain.Caption, err error) {
+	if video.Status != domain.VideoStatusReady || !video.HLSReady {
+		return nil, domain.ErrVideoNotReady
+	}
+	if strings.TrimSpace(req.Language) == "" {
+		return nil, fmt.Errorf("%w: a language is required", domain.ErrInvalidLanguage)
+	}
+	language, err := domain.NormalizeLanguageTag(req.Language)
+	if err != nil {
+		return nil, err
+	}
+	kind := req.Kind
+	if kind == "" {
+		kind = domain.CaptionSubtitles
+	}
+	if !kind.Valid() {
+		return nil, fmt.Errorf("%w: kind must be subtitles or captions", domain.ErrInvalidInput)
+	}
+	label := validator.SanitizeString(req.Label)
+	if len([]rune(label)) > maxLabelLength {
+		return nil, fmt.Errorf("%w: label cannot exceed %d characters", domain.ErrInvalidInput, maxLabelLength)
+	}
+	label = sanitizeLabel(label)
+	if err := validator.ValidateCaptionFile(req.Header, maxCaptionFileSize); err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(io.LimitReader(req.File, maxCaptionFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading caption file: %w", err)
+	}
+	cues, err := parseCaptionFile(req.Header.Filename, data)
+	if err != nil {
+		return nil, err
+	}
+	var vtt bytes.Buffer
+	if err := webvtt.Write(&vtt, nil, cues); err != nil {
+		return nil, fmt.Errorf("converting caption: %w", err)
+	}
+
+	existing, err := s.captions.ListByVideo(ctx, video.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxCaptions {
+		return nil, fmt.Errorf("%w: a video may have at most %d captions", domain.ErrInvalidInput, maxCaptions)
+	}
+
+	now := time.Now()
+	caption = &domain.Caption{
+		ID:        uuid.New(),
+		VideoID:   video.ID,
+		Language:  language,
+		Label:     label,
+		Kind:      kind,
+		Status:    domain.CaptionPending,
+		IsDefault: req.IsDefault,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	caption.Name = uploadedCaptionName(caption.ID)
+
+	key := CaptionKey(video.ID, caption.ID.String()+".vtt")
+	caption.FilePath = filepath.Join(s.storageCfg.UploadPath, filepath.FromSlash(key))
+
+	if err := s.store.Save(ctx, key, bytes.NewReader(vtt.Bytes()), int64(vtt.Len()), "text/vtt"); err != nil {
+		return nil, fmt.Errorf("storing caption: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			s.discardCaption(ctx, key)
+		}
+	}()
+
+	if err := s.captions.Create(ctx, caption); err != nil {
+		return nil, err
+	}
+
+	s.log.Info(ctx, "caption added", map[string]interface{}{
+		"video_id":   video.ID,
+		"caption_id": caption.ID,
+		"language":   caption.Language,
+		"cues":       len(cues),
+	})
+	return caption, nil
+}
+
+// parseCaptionFile parses data as the format its file name says it is in.
+func parseCaptionFile(filename string, data []byte) ([]webvtt.Cue, error) {
+	var cues []webvtt.Cue
+	var err error
+	if strings.EqualFold(filepath.Ext(filename), ".srt") {
+		cues, err = webvtt.ParseSRT(data)
+	} else {
+		cues, err = webvtt.ParseWebVTT(data)
+	}
+	switch {
+	case errors.Is(err, webvtt.ErrNoCues):
+		return nil, fmt.Errorf("%w: the file has no cues", domain.ErrInvalidCaption)
+	case err != nil:
+		return nil, fmt.Errorf("%w: %v", domain.ErrInvalidCaption, err)
+	}
+	return cues, nil
+}
+
+// CaptionKey is the storage key of an uploaded caption's WebVTT file, name
+// being its base name. The worker rebuilds it from the caption's FilePath to
+// stage the file.
+func CaptionKey(videoID uuid.UUID, name string) string {
+	return storage.Key("raw", "captions", videoID.String(), path.Base(name))
+}
+
+// discardCaption removes a caption file whose caption was never recorded.
+func (s *CaptionService) discardCaption(ctx context.Context, key string) {
+	if err := s.store.Delete(ctx, key); err != nil {
+		s.log.Error(ctx, "failed to clean up orphaned caption", err, map[string]interface{}{
+			"key": key,
+		})
+	}
+}
@@ -0,0 +1,406 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/config"
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/pkg/webvtt"
+)
+
+const (
+	// subtitlesGroupID is the HLS rendition group every caption belongs to
+	// and every video variant names in its SUBTITLES attribute.
+	subtitlesGroupID = "subs"
+	// captionsCharacteristics tells players a track of the captions kind is
+	// meant for viewers who cannot hear the soundtrack.
+	captionsCharacteristics = "public.accessibility.transcribes-spoken-dialog,public.accessibility.describes-music-and-sound"
+	// mpegTSClock is the 90 kHz clock of MPEG-TS timestamps, which a WebVTT
+	// segment's X-TIMESTAMP-MAP is written in.
+	mpegTSClock = 90000
+)
+
+// uploadedCaptionName names the rendition of an uploaded caption after its
+// ID, so it cannot collide with an embedded caption or another upload.
+func uploadedCaptionName(id uuid.UUID) string {
+	return config.CaptionRenditionPrefix + id.String()[:8]
+}
+
+// captionLabels names each caption for a player's menu; see menuLabels.
+func captionLabels(captions []*domain.Caption) []string {
+	labels := make([]string, len(captions))
+	languages := make([]string, len(captions))
+	for i, caption := range captions {
+		labels[i], languages[i] = caption.Label, caption.Language
+	}
+	return menuLabels(labels, languages, "Subtitles")
+}
+
+// writeSubtitlesGroup rewrites master.m3u8 in hlsDir to offer captions as
+// the subtitles group of every video variant, as writeAudioGroup does for
+// audio. Only the first default caption is written as the default. With no
+// captions, the group and the variants' references to it go.
+func writeSubtitlesGroup(hlsDir string, captions []*domain.Caption) error {
+	labels := captionLabels(captions)
+	media := make([]string, len(captions))
+	defaulted := false
+	for i, caption := range captions {
+		attrs := []string{
+			"TYPE=SUBTITLES",
+			fmt.Sprintf("GROUP-ID=%q", subtitlesGroupID),
+			fmt.Sprintf("NAME=%q", labels[i]),
+		}
+		if caption.Language != domain.UndeterminedLanguage {
+			attrs = append(attrs, fmt.Sprintf("LANGUAGE=%q", caption.Language))
+		}
+		if caption.IsDefault && !defaulted {
+			attrs = append(attrs, "DEFAULT=YES")
+			defaulted = true
+		} else {
+			attrs = append(attrs, "DEFAULT=NO")
+		}
+		attrs = append(attrs, "AUTOSELECT=YES")
+		if caption.Kind == domain.CaptionCaptions {
+			attrs = append(attrs, fmt.Sprintf("CHARACTERISTICS=%q", captionsCharacteristics))
+		}
+		attrs = append(attrs, fmt.Sprintf("URI=%q", caption.Name+"/playlist.m3u8"))
+		media[i] = "#EXT-X-MEDIA:" + strings.Join(attrs, ",")
+	}
+
+	return rewriteMasterGroup(hlsDir, "SUBTITLES", media, func(attrs string) string {
+		if len(captions) == 0 {
+			return removeAttribute(attrs, "SUBTITLES")
+		}
+		return setAttribute(attrs, "SUBTITLES", strconv.Quote(subtitlesGroupID))
+	})
+}
+
+// writeCaptionRendition writes cues to dir as a segmented WebVTT rendition:
+// one segment per video segment, each holding the cues showing during it, and
+// a playlist listing them. startPTS is the 90 kHz timestamp the video's first
+// frame carries, which every segment maps cue time zero to, so the cues keep
+// their times and still line up with the picture. The whole file is kept
+// beside the segments as captions.vtt for players that take a sidecar track.
+func writeCaptionRendition(dir string, cues []webvtt.Cue, segments []time.Duration, startPTS int64) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create caption rendition directory: %w", err)
+	}
+
+	header := []string{fmt.Sprintf("X-TIMESTAMP-MAP=MPEGTS:%d,LOCAL:00:00:00.000", startPTS)}
+	target := 1
+	var playlist bytes.Buffer
+	var from time.Duration
+	for i, length := range segments {
+		to := from + length
+		if i == len(segments)-1 {
+			// Cues running past the picture are kept rather than lost.
+			to = math.MaxInt64
+		}
+		var segment bytes.Buffer
+		if err := webvtt.Write(&segment, header, webvtt.Between(cues, from, to)); err != nil {
+			return err
+		}
+		name := fmt.Sprintf("segment_%03d.vtt", i)
+		if err := os.WriteFile(filepath.Join(dir, name), segment.Bytes(), 0644); err != nil {
+			return fmt.Errorf("writing caption segment: %w", err)
+		}
+		fmt.Fprintf(&playlist, "#EXTINF:%.3f,\n%s\n", length.Seconds(), name)
+		target = max(target, int(math.Ceil(length.Seconds())))
+		from += length
+	}
+
+	content := fmt.Sprintf("#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:%d\n#EXT-X-MEDIA-SEQUENCE:0\n#EXT-X-PLAYLIST-TYPE:VOD\n%s#EXT-X-ENDLIST\n",
+		target, playlist.String())
+	if err := os.WriteFile(filepath.Join(dir, "playlist.m3u8"), []byte(content), 0644); err != nil {
+		return fmt.Errorf("writing caption playlist: %w", err)
+	}
+
+	var whole bytes.Buffer
+	if err := webvtt.Write(&whole, nil, cues); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "captions.vtt"), whole.Bytes(), 0644); err != nil {
+		return fmt.Errorf("writing caption file: %w", err)
+	}
+	return nil
+}
+
+// segmentDurations reads the segment durations of the media playlist at
+// file, in order.
+func segmentDurations(file string) ([]time.Duration, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading rendition playlist: %w", err)
+	}
+	defer f.Close()
+
+	var durations []time.Duration
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "#EXTINF:")
+		if !ok {
+			continue
+		}
+		value, _, _ = strings.Cut(value, ",")
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("rendition playlist has a bad EXTINF %q", value)
+		}
+		durations = append(durations, time.Duration(seconds*float64(time.Second)))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading rendition playlist: %w", err)
+	}
+	if len(durations) == 0 {
+		return nil, fmt.Errorf("rendition playlist lists no segments")
+	}
+	return durations, nil
+}
+
+// captionTiming reads what captions are segmented against from the video
+// rendition rung in hlsDir: its segment durations, and the timestamp of its
+// first frame in the 90 kHz clock. Under CMAF the first segment only probes
+// with its init segment in front of it.
+func (s *TranscodingService) captionTiming(ctx context.Context, hlsDir, rung string) ([]time.Duration, int64, error) {
+	dir := filepath.Join(hlsDir, rung)
+	segments, err := segmentDurations(filepath.Join(dir, "playlist.m3u8"))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	first := filepath.Join(dir, "segment_000.ts")
+	if _, err := os.Stat(first); err != nil {
+		first, err = joinInitSegment(dir, rung)
+		if err != nil {
+			return nil, 0, err
+		}
+		defer os.Remove(first)
+	}
+
+	start, err := s.ffmpegService.StartTime(ctx, first)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to probe first segment of %s: %w", rung, err)
+	}
+	return segments, int64(math.Round(start.Seconds() * mpegTSClock)), nil
+}
+
+// joinInitSegment writes rung's init segment followed by its first media
+// segment to a temporary file, which the caller removes.
+func joinInitSegment(dir, rung string) (string, error) {
+	out, err := os.CreateTemp("", "first-segment-*.mp4")
+	if err != nil {
+		return "", fmt.Errorf("creating temporary segment: %w", err)
+	}
+	defer out.Close()
+
+	for _, name := range []string{"init_" + rung + ".mp4", "segment_000.m4s"} {
+		in, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			os.Remove(out.Name())
+			return "", fmt.Errorf("reading first segment of %s: %w", rung, err)
+		}
+		_, err = io.Copy(out, in)
+		in.Close()
+		if err != nil {
+			os.Remove(out.Name())
+			return "", fmt.Errorf("writing temporary segment: %w", err)
+		}
+	}
+	return out.Name(), nil
+}
+
+// extractCaptions turns the text subtitle streams of inputPath into caption
+// renditions in hlsDir, named subs_0, subs_1 and so on by their place among
+// the file's subtitle streams and timed against the video rendition rung.
+// Bitmap streams are skipped, and so is any stream that cannot be converted:
+// captions are worth having, not worth failing a video over. The stream the
+// container marks default is the default caption.
+func (s *TranscodingService) extractCaptions(ctx context.Context, id uuid.UUID, inputPath, hlsDir, rung string, streams []SubtitleStream) []*domain.Caption {
+	if len(streams) == 0 {
+		return nil
+	}
+	segments, startPTS, err := s.captionTiming(ctx, hlsDir, rung)
+	if err != nil {
+		s.log.Error(ctx, "cannot time embedded subtitles; skipping them", err, map[string]interface{}{
+			"video_id": id,
+		})
+		return nil
+	}
+
+	now := time.Now()
+	var captions []*domain.Caption
+	defaulted := false
+	for i, stream := range streams {
+		fields := map[string]interface{}{
+			"video_id": id,
+			"stream":   stream.Index,
+			"codec":    stream.Codec,
+		}
+		if !stream.IsText() {
+			s.log.Warn(ctx, "skipping subtitle stream that is not text", fields)
+			continue
+		}
+
+		language, err := domain.NormalizeLanguageTag(stream.Language)
+		if err != nil {
+			language = domain.UndeterminedLanguage
+		}
+		kind := domain.CaptionSubtitles
+		if stream.HearingImpaired {
+			kind = domain.CaptionCaptions
+		}
+		caption := &domain.Caption{
+			ID:          uuid.New(),
+			VideoID:     id,
+			Name:        config.CaptionRenditionPrefix + strconv.Itoa(i),
+			Language:    language,
+			Label:       sanitizeLabel(stream.Title),
+			Kind:        kind,
+			Status:      domain.CaptionReady,
+			Embedded:    true,
+			StreamIndex: stream.Index,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+
+		dir := filepath.Join(hlsDir, caption.Name)
+		if err := s.extractCaption(ctx, inputPath, dir, stream.Index, segments, startPTS); err != nil {
+			fields["error"] = err.Error()
+			s.log.Warn(ctx, "skipping subtitle stream that could not be converted", fields)
+			os.RemoveAll(dir)
+			continue
+		}
+		if stream.Default && !defaulted {
+			caption.IsDefault = true
+			defaulted = true
+		}
+		captions = append(captions, caption)
+	}
+	return captions
+}
+
+// extractCaption converts subtitle stream index of inputPath to WebVTT and
+// writes it to dir as a caption rendition.
+func (s *TranscodingService) extractCaption(ctx context.Context, inputPath, dir string, index int, segments []time.Duration, startPTS int64) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("clearing caption rendition directory: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create caption rendition directory: %w", err)
+	}
+
+	source := filepath.Join(dir, "captions.vtt")
+	if err := s.runFFmpeg(ctx, s.optimizer.GetSubtitleExtractArgs(inputPath, source, index), 0, func(float64) {}); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return fmt.Errorf("reading extracted subtitles: %w", err)
+	}
+	cues, err := webvtt.ParseWebVTT(data)
+	if err != nil {
+		return err
+	}
+	return writeCaptionRendition(dir, cues, segments, startPTS)
+}
+
+// PublishCaption segments an uploaded caption's WebVTT file beside its
+// video's renditions and adds it to the master playlist, which must be in
+// place locally along with the first video rendition's playlist and first
+// segment, which it is timed against. The master lists every ready caption
+// besides this one, so publishing is safe to repeat; like PublishDub, it is
+// not safe to run for two captions of one video at once. The caption is left
+// for the caller to mark ready once the files are where they are served
+// from.
+//
+// A file that no longer parses fails the caption.
+func (s *TranscodingService) PublishCaption(ctx context.Context, caption *domain.Caption) error {
+	video, err := s.videoRepo.GetByID(ctx, caption.VideoID)
+	if err != nil {
+		return fmt.Errorf("failed to get video: %w", err)
+	}
+	if !video.HLSReady || len(video.AvailableQualities) == 0 {
+		return fmt.Errorf("caption %s: %w", caption.ID, domain.ErrVideoNotReady)
+	}
+
+	data, err := os.ReadFile(caption.FilePath)
+	if err != nil {
+		return fmt.Errorf("reading caption file: %w", err)
+	}
+	cues, err := webvtt.ParseWebVTT(data)
+	if err != nil {
+		s.markCaptionFailed(ctx, caption)
+		return fmt.Errorf("failed to parse caption: %w", err)
+	}
+
+	hlsDir := s.hlsDir(video.ID, video.OutputRevision)
+	segments, startPTS, err := s.captionTiming(ctx, hlsDir, video.AvailableQualities[0])
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(hlsDir, caption.Name)
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("clearing caption rendition directory: %w", err)
+	}
+	if err := writeCaptionRendition(dir, cues, segments, startPTS); err != nil {
+		return err
+	}
+
+	existing, err := s.captions.ListByVideo(ctx, caption.VideoID)
+	if err != nil {
+		return fmt.Errorf("failed to list captions: %w", err)
+	}
+	var captions []*domain.Caption
+	for _, c := range existing {
+		if c.ID != caption.ID && c.Status != domain.CaptionReady {
+			continue
+		}
+		if caption.IsDefault && c.ID != caption.ID && c.IsDefault {
+			// MarkReady is about to take the default from it.
+			copied := *c
+			copied.IsDefault = false
+			c = &copied
+		}
+		captions = append(captions, c)
+	}
+	if err := writeSubtitlesGroup(hlsDir, captions); err != nil {
+		return err
+	}
+
+	s.log.Info(ctx, "published caption", map[string]interface{}{
+		"video_id":   video.ID,
+		"caption_id": caption.ID,
+		"name":       caption.Name,
+		"cues":       len(cues),
+	})
+	return nil
+}
+
+// MarkCaptionReady records that a published caption is being served.
+func (s *TranscodingService) MarkCaptionReady(ctx context.Context, caption *domain.Caption) error {
+	if err := s.captions.MarkReady(ctx, caption); err != nil {
+		return fmt.Errorf("failed to mark caption ready: %w", err)
+	}
+	return nil
+}
+
+func (s *TranscodingService) markCaptionFailed(ctx context.Context, caption *domain.Caption) {
+	if err := s.captions.UpdateStatus(ctx, caption.ID, domain.CaptionFailed); err != nil {
+		s.log.Error(ctx, "failed to mark caption as failed", err, map[string]interface{}{
+			"video_id":   caption.VideoID,
+			"caption_id": caption.ID,
+		})
+	}
+}
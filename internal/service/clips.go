@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+)
+
+// ClipRequest describes a clip to cut from a video.
+type ClipRequest struct {
+	Title       string
+	Description string
+	Range       domain.ClipRange
+}
+
+// CreateClip records a clip of parent as a video of its own, waiting in
+// VideoStatusUploading for the worker to cut its source from parent's and
+// process it like any upload. The clip belongs to parent's owner and takes
+// parent's visibility, category and loudness setting; its title and
+// description are its own.
+func (s *UploadService) CreateClip(ctx context.Context, parent *domain.Video, req ClipRequest) (*domain.Video, error) {
+	if err := checkEditable(parent); err != nil {
+		return nil, err
+	}
+	details, err := validateDetails(req.Title, req.Description, parent.Visibility, parent.NormalizeAudio)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Range.Validate(parent.Duration); err != nil {
+		return nil, err
+	}
+
+	// The clip has no file of its own until the worker cuts one; until then
+	// its size is its share of its parent's, which is all NewVideo needs.
+	size := parent.FileSize
+	if parent.Duration > 0 {
+		size = max(1, int64(float64(parent.FileSize)*req.Range.Duration()/float64(parent.Duration)))
+	}
+	clip, err := domain.NewVideo(details.title, details.description, parent.Filename, "", parent.MimeType, size)
+	if err != nil {
+		return nil, err
+	}
+	clip.Visibility = details.visibility
+	clip.NormalizeAudio = details.normalizeAudio
+	clip.Category = parent.Category
+	clip.UserID = parent.UserID
+	clip.Duration = int(req.Range.Duration())
+	clip.OriginalResolution = parent.OriginalResolution
+	parentID := parent.ID
+	clip.ParentID = &parentID
+	span := req.Range
+	clip.Clip = &span
+
+	if err := s.videoRepo.Create(ctx, clip); err != nil {
+		return nil, fmt.Errorf("recording clip: %w", err)
+	}
+
+	s.log.Info(ctx, "clip created", map[string]interface{}{
+		"video_id":  clip.ID,
+		"parent_id": parent.ID,
+		"start":     span.Start,
+		"end":       span.End,
+	})
+	return clip, nil
+}
+
+// CheckTrim reports whether video may be trimmed to r: it must be ready and
+// not being trimmed already, and r must lie within it and leave something
+// out.
+func CheckTrim(video *domain.Video, r domain.ClipRange) error {
+	if err := checkEditable(video); err != nil {
+		return err
+	}
+	if video.Trim != nil {
+		return domain.ErrTrimInProgress
+	}
+	if err := r.Validate(video.Duration); err != nil {
+		return err
+	}
+	if video.Duration > 0 && r.Start == 0 && r.End >= float64(video.Duration) {
+		return fmt.Errorf("%w: the range keeps the whole video", domain.ErrInvalidClipRange)
+	}
+	return nil
+}
+
+// checkEditable refuses to cut anything from a video that is not ready: its
+// source may still be rejected.
+func checkEditable(video *domain.Video) error {
+	if video.Status != domain.VideoStatusReady || !video.HLSReady {
+		return domain.ErrVideoNotReady
+	}
+	return nil
+}
+
+// CutSource copies the span r of the source at inputPath to outputPath, in
+// the container outputPath's extension names, and returns the size of what
+// it wrote. Nothing is re-encoded: the pipeline does that when it processes
+// the cut as a source of its own.
+func (s *TranscodingService) CutSource(ctx context.Context, inputPath, outputPath string, r domain.ClipRange) (int64, error) {
+	args := s.optimizer.GetTrimArgs(inputPath, outputPath,
+		strconv.FormatFloat(r.Start, 'f', 3, 64), strconv.FormatFloat(r.End, 'f', 3, 64))
+	if _, err := s.ffmpegOutput(ctx, args); err != nil {
+		return 0, fmt.Errorf("cutting source: %w", err)
+	}
+
+	info, err := os.Stat(outputPath)
+	if err != nil {
+		return 0, fmt.Errorf("cutting source: %w", err)
+	}
+	if info.Size() == 0 {
+		return 0, fmt.Errorf("cutting source: ffmpeg wrote nothing from %s to %s", formatOffset(r.Start), formatOffset(r.End))
+	}
+	return info.Size(), nil
+}
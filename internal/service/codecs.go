@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/config"
+)
+
+// codecBitrateScale is the share of an H.264 rung's bitrate a rung of the
+// same size takes in another codec for about the same quality.
+var codecBitrateScale = map[string]float64{
+	config.CodecHEVC: 0.7,
+	config.CodecAV1:  0.55,
+}
+
+// svtAV1Preset trades SVT-AV1's speed for efficiency: 8 encodes a ladder in
+// a few times H.264's medium, where the slower presets take tens of times.
+const svtAV1Preset = "8"
+
+// rungCodec is the codec rung is encoded in, an unset one being H.264.
+func rungCodec(rung config.Rendition) string {
+	if rung.Codec == "" {
+		return config.CodecH264
+	}
+	return rung.Codec
+}
+
+// codecLadders adds to rungs, which are H.264, a copy of each in every other
+// codec of codecs: named after it, 720p-hevc beside 720p, and at
+// codecBitrateScale of its rates. The copies come after rungs, a codec at a
+// time in the order codecs gives. A copy whose name would be unusable or
+// taken is left out.
+func codecLadders(rungs []config.Rendition, codecs []string) []config.Rendition {
+	ladder := append([]config.Rendition(nil), rungs...)
+	for _, codec := range codecs {
+		scale, ok := codecBitrateScale[codec]
+		if !ok {
+			continue
+		}
+		for _, rung := range rungs {
+			copied := rung
+			copied.Name = rung.Name + "-" + codec
+			copied.Codec = codec
+			copied.BitrateKbps = scaleKbps(rung.BitrateKbps, scale)
+			copied.MaxRateKbps = scaleKbps(rung.MaxRateKbps, scale)
+			copied.BufSizeKbps = scaleKbps(rung.BufSizeKbps, scale)
+			if _, taken := findRung(ladder, copied.Name); taken || !config.ValidRenditionName(copied.Name) {
+				continue
+			}
+			ladder = append(ladder, copied)
+		}
+	}
+	return ladder
+}
+
+func scaleKbps(kbps int, scale float64) int {
+	return max(1, int(math.Round(float64(kbps)*scale)))
+}
+
+// rungCodecArgs choose the rung's encoder. HEVC is tagged hvc1, the sample
+// entry Apple's players require of it in fragmented MP4. SVT-AV1 places
+// keyframes by its GOP length rather than reliably taking forced ones, so its
+// GOP is a segment's worth of frames at rate. A rung tone-mapped from an HDR
+// source is tagged BT.709.
+func rungCodecArgs(rung config.Rendition, rate frameRate, sourceRange string) []string {
+	var args []string
+	switch rungCodec(rung) {
+	case config.CodecHEVC:
+		args = []string{"-c:v", "libx265", "-tag:v", "hvc1", "-preset", "medium", "-crf", "23", "-x265-params", hevcParams(rung)}
+	case config.CodecAV1:
+		gop := hlsSegmentSeconds * 30
+		if rate.float() > 0 {
+			gop = int(math.Ceil(hlsSegmentSeconds * rate.float()))
+		}
+		args = []string{"-c:v", "libsvtav1", "-preset", svtAV1Preset, "-g", strconv.Itoa(gop)}
+	default:
+		args = []string{"-c:v", "libx264", "-preset", "medium", "-crf", "23"}
+	}
+	if !rung.HDR() && sourceRange != config.VideoRangeSDR {
+		args = append(args, bt709TagArgs...)
+	}
+	return args
+}
+
+// av1Profiles are the seq_profile of each AV1 profile as ffprobe names it.
+var av1Profiles = map[string]int{"Main": 0, "High": 1, "Professional": 2}
+
+// videoCodecString is the RFC 6381 codec string of the video a rung was
+// encoded to, as CODECS and DASH's codecs attribute give it, read from the
+// init segment's decoder configuration by ffprobe. ffmpeg's HLS muxer names
+// H.264 itself; for HEVC and AV1 it may write nothing, or too little for a
+// player to tell whether it can decode the rung.
+func (s *TranscodingService) videoCodecString(ctx context.Context, dir string, rung config.Rendition) (string, error) {
+	metadata, err := s.ffmpegService.ExtractMetadata(ctx, filepath.Join(dir, "init_"+rung.Name+".mp4"))
+	if err != nil {
+		return "", fmt.Errorf("probing %s: %w", rung.Name, err)
+	}
+	switch rungCodec(rung) {
+	case config.CodecHEVC:
+		// Main is general_profile_idc 1, compatible with Main and Main
+		// 10; Main 10 is 2, compatible with Main 10 alone. libx265
+		// encodes Main tier, and its level is level_idc, thirty times
+		// the level.
+		profile := "1.6"
+		if metadata.VideoProfile == "Main 10" {
+			profile = "2.4"
+		} else if metadata.VideoProfile != "Main" {
+			return "", fmt.Errorf("%s is HEVC of unexpected profile %q", rung.Name, metadata.VideoProfile)
+		}
+		if metadata.VideoLevel <= 0 {
+			return "", fmt.Errorf("%s is HEVC of unknown level", rung.Name)
+		}
+		return fmt.Sprintf("hvc1.%s.L%d.B0", profile, metadata.VideoLevel), nil
+	case config.CodecAV1:
+		profile, ok := av1Profiles[metadata.VideoProfile]
+		if !ok {
+			return "", fmt.Errorf("%s is AV1 of unexpected profile %q", rung.Name, metadata.VideoProfile)
+		}
+		if metadata.VideoLevel < 0 || metadata.VideoLevel > 31 {
+			return "", fmt.Errorf("%s is AV1 of unknown level", rung.Name)
+		}
+		// The level is seq_level_idx, in SVT-AV1's Main tier.
+		return fmt.Sprintf("av01.%d.%02dM.%02d", profile, metadata.VideoLevel, metadata.BitDepth), nil
+	default:
+		return "", fmt.Errorf("%s is H.264, which ffmpeg names", rung.Name)
+	}
+}
@@ -0,0 +1,443 @@
+package service
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/config"
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+)
+
+// dashManifestName is the DASH manifest CMAF packaging writes beside
+// master.m3u8. Its segment URLs are relative, so it lives in the HLS
+// directory and shares every segment with the HLS playlists.
+const dashManifestName = "manifest.mpd"
+
+// dashTimescale is the SegmentTimeline clock: milliseconds, which is as fine
+// as the EXTINF durations it is built from are worth.
+const dashTimescale = 1000
+
+// mediaPlaylist is what the manifest needs from one HLS media playlist.
+type mediaPlaylist struct {
+	initURI  string
+	segments []playlistSegment
+}
+
+type playlistSegment struct {
+	uri      string
+	duration float64
+}
+
+// duration is the playlist's total running time in seconds.
+func (p mediaPlaylist) duration() float64 {
+	var total float64
+	for _, seg := range p.segments {
+		total += seg.duration
+	}
+	return total
+}
+
+// readMediaPlaylist parses the parts of a VOD media playlist the manifest
+// mirrors: the init segment and each media segment with its duration.
+func readMediaPlaylist(file string) (mediaPlaylist, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return mediaPlaylist{}, err
+	}
+	defer f.Close()
+
+	var (
+		playlist mediaPlaylist
+		pending  = -1.0
+	)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-MAP:"):
+			playlist.initURI = parseAttributes(strings.TrimPrefix(line, "#EXT-X-MAP:"))["URI"]
+		case strings.HasPrefix(line, "#EXTINF:"):
+			value, _, _ := strings.Cut(strings.TrimPrefix(line, "#EXTINF:"), ",")
+			if pending, err = strconv.ParseFloat(value, 64); err != nil {
+				return mediaPlaylist{}, fmt.Errorf("%s: bad EXTINF %q", file, line)
+			}
+		case line == "" || strings.HasPrefix(line, "#"):
+		default:
+			if pending < 0 {
+				return mediaPlaylist{}, fmt.Errorf("%s: segment %q has no EXTINF", file, line)
+			}
+			playlist.segments = append(playlist.segments, playlistSegment{uri: line, duration: pending})
+			pending = -1
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return mediaPlaylist{}, err
+	}
+	if playlist.initURI == "" || len(playlist.segments) == 0 {
+		return mediaPlaylist{}, fmt.Errorf("%s is not a fragmented MP4 playlist", file)
+	}
+	return playlist, nil
+}
+
+// parseAttributes splits an HLS attribute list, whose quoted values may
+// themselves contain commas, into its names and unquoted values.
+func parseAttributes(list string) map[string]string {
+	attrs := make(map[string]string)
+	for list != "" {
+		name, rest, ok := strings.Cut(list, "=")
+		if !ok {
+			break
+		}
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				break
+			}
+			value, list = rest[1:end+1], strings.TrimPrefix(rest[end+2:], ",")
+		} else {
+			value, list, _ = strings.Cut(rest, ",")
+		}
+		attrs[strings.TrimSpace(name)] = value
+	}
+	return attrs
+}
+
+// masterCodecs reads the CODECS of each variant in master.m3u8, keyed by the
+// directory of the variant's playlist, which is its rendition name.
+func masterCodecs(file string) (map[string]string, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	codecs := make(map[string]string)
+	var pending string
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
+			pending = parseAttributes(strings.TrimPrefix(line, "#EXT-X-STREAM-INF:"))["CODECS"]
+		case line == "" || strings.HasPrefix(line, "#"):
+		default:
+			codecs[path.Dir(line)] = pending
+			pending = ""
+		}
+	}
+	return codecs, nil
+}
+
+// splitCodecs separates a variant's CODECS into its video and audio codecs.
+func splitCodecs(codecs string) (video, audio string) {
+	for _, codec := range strings.Split(codecs, ",") {
+		codec = strings.TrimSpace(codec)
+		switch {
+		case strings.HasPrefix(codec, "mp4a"), strings.HasPrefix(codec, "ac-3"),
+			strings.HasPrefix(codec, "ec-3"), strings.HasPrefix(codec, "opus"):
+			audio = codec
+		case codec != "":
+			video = codec
+		}
+	}
+	return video, audio
+}
+
+// cicpTransferCharacteristics are the ISO/IEC 23091-2 code points of the HDR
+// transfer functions, which an adaptation set of HDR video declares so a
+// client that cannot show it leaves it alone.
+var cicpTransferCharacteristics = map[string]string{
+	config.VideoRangePQ:  "16",
+	config.VideoRangeHLG: "18",
+}
+
+// writeDASHManifest writes a static MPEG-DASH manifest for the CMAF output
+// in hlsDir. It is built from the HLS playlists ffmpeg wrote rather than from
+// a second packaging run, so the two formats list exactly the same segments:
+// a video adaptation set with a representation per rung of each codec and
+// dynamic range, and one audio adaptation set per track in
+// audio. source is the frame rate of the source, which the rungs' are taken
+// from.
+func writeDASHManifest(hlsDir string, rungs []config.Rendition, audio []*domain.AudioTrack, source frameRate) error {
+	codecs, err := masterCodecs(filepath.Join(hlsDir, "master.m3u8"))
+	if err != nil {
+		return fmt.Errorf("reading master playlist: %w", err)
+	}
+
+	// A client switches between the representations of a set at will, so
+	// codecs and dynamic ranges, which it cannot, are kept apart.
+	var sets []mpdAdaptationSet
+	setOf := make(map[string]int)
+	var longest float64
+	for _, rung := range rungs {
+		videoCodec, _ := splitCodecs(codecs[rung.Name])
+		if videoCodec == "" {
+			return fmt.Errorf("master playlist gives no video codec for %s", rung.Name)
+		}
+
+		rep, duration, err := dashRepresentation(hlsDir, rung.Name, videoCodec)
+		if err != nil {
+			return err
+		}
+		rep.Width, rep.Height = rung.Width, rung.Height
+		rep.FrameRate = rungFrameRate(source, rung.FPS).String()
+
+		videoRange := videoRange(rung)
+		key := rungCodec(rung) + "/" + videoRange
+		at, ok := setOf[key]
+		if !ok {
+			at = len(sets)
+			setOf[key] = at
+			sets = append(sets, dashVideoAdaptationSet(at, videoRange))
+		}
+		sets[at].Representations = append(sets[at].Representations, rep)
+		longest = max(longest, duration)
+	}
+
+	period := mpdPeriod{ID: "0", Start: "PT0S", AdaptationSets: sets}
+
+	labels := audioTrackLabels(audio)
+	for i, track := range audio {
+		set, duration, err := dashAudioAdaptationSet(hlsDir, track, labels[i], len(sets)+i)
+		if err != nil {
+			return err
+		}
+		period.AdaptationSets = append(period.AdaptationSets, set)
+		longest = max(longest, duration)
+	}
+
+	mpd := mpdDocument{
+		XMLNS:                     "urn:mpeg:dash:schema:mpd:2011",
+		Profiles:                  "urn:mpeg:dash:profile:isoff-live:2011",
+		Type:                      "static",
+		MediaPresentationDuration: isoDuration(longest),
+		MinBufferTime:             isoDuration(hlsSegmentSeconds),
+		Periods:                   []mpdPeriod{period},
+	}
+	return saveDASHManifest(hlsDir, &mpd)
+}
+
+// dashVideoAdaptationSet is an empty video adaptation set for rungs in
+// videoRange. One of HDR video declares its transfer function as essential:
+// a client that does not know it must not play the set.
+func dashVideoAdaptationSet(id int, videoRange string) mpdAdaptationSet {
+	set := mpdAdaptationSet{
+		ID:               id,
+		ContentType:      "video",
+		MimeType:         "video/mp4",
+		SegmentAlignment: true,
+		StartWithSAP:     1,
+	}
+	if transfer, ok := cicpTransferCharacteristics[videoRange]; ok {
+		set.EssentialProperties = []mpdDescriptor{
+			{SchemeIDURI: "urn:mpeg:mpegB:cicp:ColourPrimaries", Value: "9"},
+			{SchemeIDURI: "urn:mpeg:mpegB:cicp:TransferCharacteristics", Value: transfer},
+			{SchemeIDURI: "urn:mpeg:mpegB:cicp:MatrixCoefficients", Value: "9"},
+		}
+	}
+	return set
+}
+
+// addDASHAudio adds track, already encoded under hlsDir, to the manifest
+// there as an audio adaptation set of its own, replacing any earlier one for
+// the same rendition so a retried job does not list it twice.
+func addDASHAudio(hlsDir string, track *domain.AudioTrack, label string) error {
+	content, err := os.ReadFile(filepath.Join(hlsDir, dashManifestName))
+	if err != nil {
+		return fmt.Errorf("reading DASH manifest: %w", err)
+	}
+	var mpd mpdDocument
+	if err := xml.Unmarshal(content, &mpd); err != nil {
+		return fmt.Errorf("parsing DASH manifest: %w", err)
+	}
+	if len(mpd.Periods) == 0 {
+		return fmt.Errorf("DASH manifest has no period")
+	}
+	// Decoding records the namespace in XMLName as well as XMLNS, and
+	// encoding would then write the attribute twice.
+	mpd.XMLName = xml.Name{}
+
+	period := &mpd.Periods[0]
+	sets := period.AdaptationSets[:0]
+	nextID := 0
+	for _, set := range period.AdaptationSets {
+		if len(set.Representations) == 1 && set.Representations[0].ID == track.Name {
+			continue
+		}
+		sets = append(sets, set)
+		nextID = max(nextID, set.ID+1)
+	}
+
+	set, _, err := dashAudioAdaptationSet(hlsDir, track, label, nextID)
+	if err != nil {
+		return err
+	}
+	period.AdaptationSets = append(sets, set)
+	return saveDASHManifest(hlsDir, &mpd)
+}
+
+// dashAudioAdaptationSet describes one audio track as an adaptation set of its
+// own, which is how DASH offers a choice of language. Every track is encoded
+// to AAC-LC, whatever the source carried.
+func dashAudioAdaptationSet(hlsDir string, track *domain.AudioTrack, label string, id int) (mpdAdaptationSet, float64, error) {
+	rep, duration, err := dashRepresentation(hlsDir, track.Name, aacLCCodec)
+	if err != nil {
+		return mpdAdaptationSet{}, 0, err
+	}
+	set := mpdAdaptationSet{
+		ID:               id,
+		ContentType:      "audio",
+		MimeType:         "audio/mp4",
+		SegmentAlignment: true,
+		StartWithSAP:     1,
+		Label:            label,
+		Representations:  []mpdRepresentation{rep},
+	}
+	if track.Language != domain.UndeterminedLanguage {
+		set.Lang = track.Language
+	}
+	if track.IsDefault {
+		set.Role = &mpdDescriptor{SchemeIDURI: "urn:mpeg:dash:role:2011", Value: "main"}
+	}
+	return set, duration, nil
+}
+
+func saveDASHManifest(hlsDir string, mpd *mpdDocument) error {
+	out, err := xml.MarshalIndent(mpd, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding DASH manifest: %w", err)
+	}
+	out = append([]byte(xml.Header), append(out, '\n')...)
+	if err := os.WriteFile(filepath.Join(hlsDir, dashManifestName), out, 0644); err != nil {
+		return fmt.Errorf("writing DASH manifest: %w", err)
+	}
+	return nil
+}
+
+// dashRepresentation describes the rendition in hlsDir/name from its media
+// playlist and segment files, returning it with its running time. Its
+// bandwidth is the peak bitrate of any one segment, which is what a DASH
+// client budgets for.
+func dashRepresentation(hlsDir, name, codec string) (mpdRepresentation, float64, error) {
+	playlist, err := readMediaPlaylist(filepath.Join(hlsDir, name, "playlist.m3u8"))
+	if err != nil {
+		return mpdRepresentation{}, 0, fmt.Errorf("reading %s playlist: %w", name, err)
+	}
+
+	var peak float64
+	timeline := &mpdSegmentTimeline{}
+	for i, seg := range playlist.segments {
+		// The template below numbers segments the way ffmpeg names them; a
+		// playlist that disagrees cannot be described by it.
+		if seg.uri != fmt.Sprintf("segment_%03d.m4s", i) {
+			return mpdRepresentation{}, 0, fmt.Errorf("%s segment %d is %q, not in sequence", name, i, seg.uri)
+		}
+		info, err := os.Stat(filepath.Join(hlsDir, name, seg.uri))
+		if err != nil {
+			return mpdRepresentation{}, 0, fmt.Errorf("%s segment: %w", name, err)
+		}
+		if seg.duration > 0 {
+			peak = max(peak, float64(info.Size())*8/seg.duration)
+		}
+		timeline.add(int64(math.Round(seg.duration * dashTimescale)))
+	}
+
+	rep := mpdRepresentation{
+		ID:        name,
+		Bandwidth: int64(math.Ceil(peak)),
+		Codecs:    codec,
+		SegmentTemplate: mpdSegmentTemplate{
+			Timescale:      dashTimescale,
+			Initialization: name + "/" + playlist.initURI,
+			Media:          name + "/segment_$Number%03d$.m4s",
+			StartNumber:    0,
+			Timeline:       timeline,
+		},
+	}
+	return rep, playlist.duration(), nil
+}
+
+// isoDuration formats seconds as the ISO 8601 duration DASH expects.
+func isoDuration(seconds float64) string {
+	return "PT" + strconv.FormatFloat(seconds, 'f', 3, 64) + "S"
+}
+
+// The MPD document, as much of the schema as a static on-demand manifest
+// uses.
+type mpdDocument struct {
+	XMLName                   xml.Name    `xml:"MPD"`
+	XMLNS                     string      `xml:"xmlns,attr"`
+	Profiles                  string      `xml:"profiles,attr"`
+	Type                      string      `xml:"type,attr"`
+	MediaPresentationDuration string      `xml:"mediaPresentationDuration,attr"`
+	MinBufferTime             string      `xml:"minBufferTime,attr"`
+	Periods                   []mpdPeriod `xml:"Period"`
+}
+
+type mpdPeriod struct {
+	ID             string             `xml:"id,attr"`
+	Start          string             `xml:"start,attr"`
+	AdaptationSets []mpdAdaptationSet `xml:"AdaptationSet"`
+}
+
+type mpdAdaptationSet struct {
+	ID                  int                 `xml:"id,attr"`
+	ContentType         string              `xml:"contentType,attr"`
+	MimeType            string              `xml:"mimeType,attr"`
+	Lang                string              `xml:"lang,attr,omitempty"`
+	SegmentAlignment    bool                `xml:"segmentAlignment,attr"`
+	StartWithSAP        int                 `xml:"startWithSAP,attr"`
+	Label               string              `xml:"Label,omitempty"`
+	EssentialProperties []mpdDescriptor     `xml:"EssentialProperty"`
+	Role                *mpdDescriptor      `xml:"Role"`
+	Representations     []mpdRepresentation `xml:"Representation"`
+}
+
+type mpdDescriptor struct {
+	SchemeIDURI string `xml:"schemeIdUri,attr"`
+	Value       string `xml:"value,attr"`
+}
+
+type mpdRepresentation struct {
+	ID              string             `xml:"id,attr"`
+	Bandwidth       int64              `xml:"bandwidth,attr"`
+	Codecs          string             `xml:"codecs,attr"`
+	Width           int                `xml:"width,attr,omitempty"`
+	Height          int                `xml:"height,attr,omitempty"`
+	FrameRate       string             `xml:"frameRate,attr,omitempty"`
+	SegmentTemplate mpdSegmentTemplate `xml:"SegmentTemplate"`
+}
+
+type mpdSegmentTemplate struct {
+	Timescale      int                 `xml:"timescale,attr"`
+	Initialization string              `xml:"initialization,attr"`
+	Media          string              `xml:"media,attr"`
+	StartNumber    int                 `xml:"startNumber,attr"`
+	Timeline       *mpdSegmentTimeline `xml:"SegmentTimeline"`
+}
+
+type mpdSegmentTimeline struct {
+	Segments []mpdTimelineEntry `xml:"S"`
+}
+
+// mpdTimelineEntry is a run of R+1 consecutive segments lasting D each.
+type mpdTimelineEntry struct {
+	D int64 `xml:"d,attr"`
+	R int   `xml:"r,attr,omitempty"`
+}
+
+// add appends a segment of duration d, extending the last run when it is the
+// same length, as all but the final segment usually are.
+func (t *mpdSegmentTimeline) add(d int64) {
+	if n := len(t.Segments); n > 0 && t.Segments[n-1].D == d {
+		t.Segments[n-1].R++
+		return
+	}
+	t.Segments = append(t.Segments, mpdTimelineEntry{D: d})
+}
@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/config"
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/internal/storage"
+	"github.com/Nuu-maan/video-streaming-service/pkg/logger"
+	"github.com/Nuu-maan/video-streaming-service/pkg/validator"
+)
+
+// directCompleteTimeout bounds the session lock while an upload is completed.
+// Completion moves no bytes through the API, but assembling a large local
+// upload still takes a while.
+const directCompleteTimeout = 10 * time.Minute
+
+// DirectUploadService lets clients upload straight to storage. Initiate opens
+// a multipart upload on the store and returns a presigned URL per part; the
+// client PUTs the parts itself and then calls Complete, which assembles the
+// object, checks that what landed is what was declared, and records the video
+// through UploadService like every other upload.
+//
+// Sessions share the upload_sessions table with tus uploads (Method tells
+// them apart), so they expire and are reaped the same way.
+type DirectUploadService struct {
+	sessions   UploadSessionRepository
+	uploads    *UploadService
+	store      storage.Store
+	redis      *redis.Client
+	storageCfg *config.StorageConfig
+	log        *logger.Logger
+}
+
+func NewDirectUploadService(
+	sessions UploadSessionRepository,
+	uploads *UploadService,
+	store storage.Store,
+	redisClient *redis.Client,
+	storageCfg *config.StorageConfig,
+	log *logger.Logger,
+) *DirectUploadService {
+	return &DirectUploadService{
+		sessions:   sessions,
+		uploads:    uploads,
+		store:      store,
+		redis:      redisClient,
+		storageCfg: storageCfg,
+		log:        log,
+	}
+}
+
+// Initiate validates an upload the same way CreateSession does and opens the
+// multipart upload it will land in. The session lives no longer than its part
+// URLs do.
+func (s *DirectUploadService) Initiate(ctx context.Context, req CreateUploadSessionRequest) (*domain.UploadSession, *storage.DirectUpload, error) {
+	uploader, ok := s.store.(storage.DirectUploader)
+	if !ok {
+		return nil, nil, domain.ErrDirectUploadUnsupported
+	}
+
+	details, err := validateDetails(req.Title, req.Description, req.Visibility, req.NormalizeAudio)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := validator.ValidateVideoUpload(req.Filename, req.Length, s.storageCfg.MaxFileSize); err != nil {
+		return nil, nil, err
+	}
+
+	ttl := min(s.storageCfg.UploadSessionTTL, storage.MaxDirectUploadExpiry)
+	session, err := domain.NewUploadSession(
+		req.OwnerID, req.Filename, mimeTypeFor(req.MimeType, req.Filename),
+		details.title, details.description, details.visibility,
+		req.Length, ttl,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	session.Method = domain.UploadMethodDirect
+	session.NormalizeAudio = details.normalizeAudio
+
+	key, _ := s.uploads.newRawLocation(req.Filename)
+	upload, err := uploader.CreateDirectUpload(ctx, key, req.Length, session.MimeType, ttl)
+	if err != nil {
+		return nil, nil, fmt.Errorf("starting direct upload: %w", err)
+	}
+	session.StorageKey = key
+	session.MultipartID = upload.UploadID
+
+	if err := s.sessions.Create(ctx, session); err != nil {
+		abortDirectParts(ctx, s.store, s.log, session)
+		return nil, nil, err
+	}
+
+	s.log.Info(ctx, "direct upload initiated", map[string]interface{}{
+		"upload_id": session.ID,
+		"length":    session.Length,
+		"parts":     len(upload.Parts),
+	})
+	return session, upload, nil
+}
+
+// Complete assembles ownerID's uploaded parts and records the video. The
+// object is checked with Stat before anything is recorded: a client that
+// skipped or truncated a part produces an object of the wrong size, which is
+// deleted and reported as domain.ErrUploadIncomplete.
+//
+// Completing a session that is already complete returns it with a nil video,
+// so a client that lost the first answer can simply ask again.
+func (s *DirectUploadService) Complete(ctx context.Context, id, ownerID uuid.UUID, parts []storage.CompletedPart) (*domain.UploadSession, *domain.Video, error) {
+	uploader, ok := s.store.(storage.DirectUploader)
+	if !ok {
+		return nil, nil, domain.ErrDirectUploadUnsupported
+	}
+
+	unlock, err := lockUploadSession(ctx, s.redis, directCompleteTimeout, s.log, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
+	session, err := ownedSession(ctx, s.sessions, id, ownerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !session.IsDirect() {
+		return nil, nil, fmt.Errorf("%w: a tus upload completes with its last chunk", domain.ErrUploadMethodMismatch)
+	}
+	if session.IsComplete() {
+		return session, nil, nil
+	}
+
+	// An unknown multipart upload most likely means an earlier attempt
+	// assembled the object and then failed to record it; Stat decides.
+	if err := uploader.CompleteDirectUpload(ctx, session.StorageKey, session.MultipartID, parts); err != nil && !errors.Is(err, domain.ErrStorageObjectNotFound) {
+		return nil, nil, err
+	}
+
+	video, err := s.record(ctx, session)
+	if err != nil {
+		return nil, nil, err
+	}
+	return session, video, nil
+}
+
+// record verifies the assembled object and records it as a video. Anything
+// that fails after the object exists removes it, and the video if one was
+// recorded: the multipart upload is spent, so the client has to start over
+// either way.
+func (s *DirectUploadService) record(ctx context.Context, session *domain.UploadSession) (video *domain.Video, err error) {
+	info, err := s.store.Stat(ctx, session.StorageKey)
+	if err != nil {
+		if errors.Is(err, domain.ErrStorageObjectNotFound) {
+			return nil, fmt.Errorf("%w: nothing was assembled", domain.ErrUploadIncomplete)
+		}
+		return nil, err
+	}
+	recorded := false
+	defer func() {
+		if err != nil && !recorded {
+			s.uploads.discardRaw(ctx, session.StorageKey)
+		}
+	}()
+
+	if info.Size != session.Length {
+		return nil, fmt.Errorf("%w: object is %d bytes, upload declared %d", domain.ErrUploadIncomplete, info.Size, session.Length)
+	}
+	container, err := s.sniff(ctx, session.StorageKey)
+	if err != nil {
+		return nil, err
+	}
+
+	details := videoDetails{
+		title:          session.Title,
+		description:    session.Description,
+		visibility:     session.Visibility,
+		normalizeAudio: session.NormalizeAudio,
+	}
+	video, err = s.uploads.recordVideo(ctx, details, session.UserID, s.uploads.rawFilePath(session.StorageKey), container.MIMEType, info.Size)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.sessions.MarkCompleted(ctx, session.ID, video.ID); err != nil {
+		recorded = !s.uploads.unrecordVideo(ctx, video)
+		return nil, err
+	}
+
+	now := time.Now()
+	session.VideoID = &video.ID
+	session.CompletedAt = &now
+	return video, nil
+}
+
+// sniff checks the head of the stored object, since the bytes never passed
+// through the API where the other upload paths check them.
+func (s *DirectUploadService) sniff(ctx context.Context, key string) (validator.VideoContainer, error) {
+	obj, err := s.store.Open(ctx, key)
+	if err != nil {
+		return validator.VideoContainer{}, err
+	}
+	defer obj.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(obj, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return validator.VideoContainer{}, fmt.Errorf("reading uploaded object: %w", err)
+	}
+	return validator.SniffVideoHeader(head[:n])
+}
+
+// Abort abandons ownerID's direct upload, deleting the session and discarding
+// any parts already sent. A completed upload's video is unaffected.
+func (s *DirectUploadService) Abort(ctx context.Context, id, ownerID uuid.UUID) error {
+	unlock, err := lockUploadSession(ctx, s.redis, directCompleteTimeout, s.log, id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	session, err := s.sessions.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if session.UserID != ownerID {
+		return domain.ErrUploadSessionNotFound
+	}
+	if !session.IsDirect() {
+		return fmt.Errorf("%w: end a tus upload with the tus termination request", domain.ErrUploadMethodMismatch)
+	}
+
+	if err := s.sessions.Delete(ctx, id); err != nil {
+		return err
+	}
+	abortDirectParts(ctx, s.store, s.log, session)
+	return nil
+}
+
+// ReceivePart stores one part sent to a part URL the store signed itself. It
+// exists for storage.Local, whose part URLs point back at the API; with MinIO
+// the parts never reach the API at all.
+func (s *DirectUploadService) ReceivePart(ctx context.Context, uploadID string, part int, query url.Values, body io.Reader) (string, error) {
+	receiver, ok := s.store.(storage.DirectPartReceiver)
+	if !ok {
+		return "", domain.ErrDirectUploadUnsupported
+	}
+	return receiver.PutDirectPart(ctx, uploadID, part, query, body)
+}
+
+// abortDirectParts discards the multipart upload behind an unfinished direct
+// session. Like removeParts it is best-effort: the session is settled, and an
+// orphaned multipart upload is only wasted space that the bucket's lifecycle
+// rules can also reclaim.
+func abortDirectParts(ctx context.Context, store storage.Store, log *logger.Logger, session *domain.UploadSession) {
+	uploader, ok := store.(storage.DirectUploader)
+	if !ok || session.IsComplete() || session.MultipartID == "" {
+		return
+	}
+	if err := uploader.AbortDirectUpload(ctx, session.StorageKey, session.MultipartID); err != nil {
+		log.Error(ctx, "failed to abort direct upload", err, map[string]interface{}{
+			"upload_id":    session.ID,
+			"multipart_id": session.MultipartID,
+		})
+	}
+}
@@ -5,23 +5,112 @@ import (
 	"encoding/json"
 	"fmt"
 	"os/exec"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/Nuu-maan/video-streaming-service/internal/config"
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
 	"github.com/Nuu-maan/video-streaming-service/pkg/logger"
 )
 
 type VideoMetadata struct {
-	Duration   float64
-	Width      int
-	Height     int
-	FrameRate  float64
-	Bitrate    int64
-	VideoCodec string
-	AudioCodec string
-	Format     string
+	Duration  float64
+	Width     int
+	Height    int
+	FrameRate float64
+	// AvgFrameRate is the frames the video stream averages a second, which
+	// for variable frame rate video is lower than FrameRate, the rate its
+	// timestamps are counted in. Zero when the container does not say.
+	AvgFrameRate float64
+	Bitrate      int64
+	VideoCodec   string
+	AudioCodec   string
+	Format       string
+	// ColorTransfer, ColorPrimaries and ColorSpace are the video stream's
+	// colour properties as ffprobe names them ("smpte2084", "bt2020nc"),
+	// "" where the stream does not say. PixelFormat is its pixel format and
+	// BitDepth the bits of each component, 8 when nothing says otherwise.
+	ColorTransfer  string
+	ColorPrimaries string
+	ColorSpace     string
+	PixelFormat    string
+	BitDepth       int
+	// VideoProfile and VideoLevel are the video stream's codec profile and
+	// level as ffprobe reports them ("Main 10", 120); the level is negative
+	// where the stream does not say.
+	VideoProfile string
+	VideoLevel   int
+	// AudioStreams lists every audio stream in the file, in stream order.
+	// AudioCodec is the first one's codec.
+	AudioStreams []AudioStream
+	// SubtitleStreams lists every subtitle stream in the file, in stream
+	// order, whether or not it can be turned into WebVTT.
+	SubtitleStreams []SubtitleStream
+}
+
+// Transfer functions, as ffprobe names them, of the video a source is
+// mastered in HDR with.
+const (
+	transferPQ  = "smpte2084"
+	transferHLG = "arib-std-b67"
+)
+
+// VideoRange is the dynamic range the video was mastered in, as one of
+// config's VideoRange constants. It goes by the transfer function alone:
+// that is what decides how the signal must be read, whatever the primaries.
+func (m *VideoMetadata) VideoRange() string {
+	switch m.ColorTransfer {
+	case transferPQ:
+		return config.VideoRangePQ
+	case transferHLG:
+		return config.VideoRangeHLG
+	default:
+		return config.VideoRangeSDR
+	}
+}
+
+// AudioStream is one audio stream of a probed file, with the language and
+// title its container tags it with, if any.
+type AudioStream struct {
+	Codec    string
+	Language string
+	Title    string
+	// Default is the container's default disposition: the stream a player
+	// picks when nothing else decides.
+	Default bool
+}
+
+// SubtitleStream is one subtitle stream of a probed file. Index is its index
+// among all the file's streams, as ffmpeg's -map takes it.
+type SubtitleStream struct {
+	Index    int
+	Codec    string
+	Language string
+	Title    string
+	Default  bool
+	// HearingImpaired is the container's hearing_impaired disposition: the
+	// stream describes sounds as well as dialogue.
+	HearingImpaired bool
+}
+
+// textSubtitleCodecs are the subtitle codecs ffmpeg can convert to WebVTT.
+// Bitmap subtitles (PGS, VobSub, DVB) would need OCR.
+var textSubtitleCodecs = map[string]bool{
+	"subrip":   true,
+	"srt":      true,
+	"ass":      true,
+	"ssa":      true,
+	"webvtt":   true,
+	"mov_text": true,
+	"text":     true,
+}
+
+// IsText reports whether the stream can be converted to WebVTT.
+func (s SubtitleStream) IsText() bool {
+	return textSubtitleCodecs[s.Codec]
 }
 
 type FFmpegService struct {
@@ -37,13 +126,77 @@ func NewFFmpegService(log *logger.Logger) *FFmpegService {
 }
 
 func (s *FFmpegService) ExtractMetadata(ctx context.Context, filePath string) (*VideoMetadata, error) {
+	metadata, err := s.probe(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if metadata.VideoCodec == "" {
+		return nil, domain.ErrSourceNoVideo
+	}
+
+	s.log.Info(ctx, "extracted video metadata", map[string]interface{}{
+		"file":         filePath,
+		"duration":     metadata.Duration,
+		"resolution":   fmt.Sprintf("%dx%d", metadata.Width, metadata.Height),
+		"codec":        metadata.VideoCodec,
+		"audio_tracks": len(metadata.AudioStreams),
+	})
+
+	return metadata, nil
+}
+
+// ExtractAudioMetadata probes a file that only has to carry audio, such as a
+// dubbed track. A video stream, if there is one, is ignored by its callers.
+func (s *FFmpegService) ExtractAudioMetadata(ctx context.Context, filePath string) (*VideoMetadata, error) {
+	metadata, err := s.probe(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	if len(metadata.AudioStreams) == 0 {
+		return nil, fmt.Errorf("no audio stream found in file")
+	}
+	return metadata, nil
+}
+
+// StartTime reports when filePath's presentation starts, as its container's
+// start_time.
+func (s *FFmpegService) StartTime(ctx context.Context, filePath string) (time.Duration, error) {
+	s.ensureFFprobePath()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, s.ffprobePath,
+		"-v", "quiet",
+		"-show_entries", "format=start_time",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		filePath,
+	).Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe execution failed: %w", err)
+	}
+
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe reported no start time for %s", filePath)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// probe runs ffprobe over filePath and collects what it reports, without
+// judging whether the file is of any use.
+func (s *FFmpegService) probe(ctx context.Context, filePath string) (*VideoMetadata, error) {
 	s.ensureFFprobePath()
 
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
+	// Only ever a local file: a playlist or reference movie in an upload
+	// must not get ffprobe fetching URLs or opening other files for it.
 	cmd := exec.CommandContext(ctx, s.ffprobePath,
 		"-v", "quiet",
+		"-protocol_whitelist", "file",
 		"-print_format", "json",
 		"-show_format",
 		"-show_streams",
@@ -53,7 +206,7 @@ func (s *FFmpegService) ExtractMetadata(ctx context.Context, filePath string) (*
 	output, err := cmd.Output()
 	if err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
-			return nil, fmt.Errorf("ffprobe timeout after 30 seconds")
+			return nil, fmt.Errorf("ffprobe timeout after 30 seconds: %w", ctx.Err())
 		}
 		return nil, fmt.Errorf("ffprobe execution failed: %w", err)
 	}
@@ -65,11 +218,29 @@ func (s *FFmpegService) ExtractMetadata(ctx context.Context, filePath string) (*
 			Format   string `json:"format_name"`
 		} `json:"format"`
 		Streams []struct {
-			CodecType  string `json:"codec_type"`
-			CodecName  string `json:"codec_name"`
-			Width      int    `json:"width"`
-			Height     int    `json:"height"`
-			RFrameRate string `json:"r_frame_rate"`
+			Index          int    `json:"index"`
+			CodecType      string `json:"codec_type"`
+			CodecName      string `json:"codec_name"`
+			Profile        string `json:"profile"`
+			Level          int    `json:"level"`
+			Width          int    `json:"width"`
+			Height         int    `json:"height"`
+			RFrameRate     string `json:"r_frame_rate"`
+			AvgFrameRate   string `json:"avg_frame_rate"`
+			PixFmt         string `json:"pix_fmt"`
+			BitsPerRaw     string `json:"bits_per_raw_sample"`
+			ColorTransfer  string `json:"color_transfer"`
+			ColorPrimaries string `json:"color_primaries"`
+			ColorSpace     string `json:"color_space"`
+			Tags           struct {
+				Language string `json:"language"`
+				Title    string `json:"title"`
+			} `json:"tags"`
+			Disposition struct {
+				Default         int `json:"default"`
+				HearingImpaired int `json:"hearing_impaired"`
+				AttachedPic     int `json:"attached_pic"`
+			} `json:"disposition"`
 		} `json:"streams"`
 	}
 
@@ -90,39 +261,79 @@ func (s *FFmpegService) ExtractMetadata(ctx context.Context, filePath string) (*
 	}
 
 	for _, stream := range probeData.Streams {
-		if stream.CodecType == "video" && metadata.VideoCodec == "" {
+		// Cover art is stored as a one-frame video stream; it is not the
+		// video.
+		if stream.CodecType == "video" && metadata.VideoCodec == "" && stream.Disposition.AttachedPic == 0 {
 			metadata.VideoCodec = stream.CodecName
 			metadata.Width = stream.Width
 			metadata.Height = stream.Height
-
-			if stream.RFrameRate != "" && strings.Contains(stream.RFrameRate, "/") {
-				parts := strings.Split(stream.RFrameRate, "/")
-				if len(parts) == 2 {
-					num, _ := strconv.ParseFloat(parts[0], 64)
-					den, _ := strconv.ParseFloat(parts[1], 64)
-					if den > 0 {
-						metadata.FrameRate = num / den
-					}
-				}
+			metadata.FrameRate = parseFrameRate(stream.RFrameRate)
+			metadata.AvgFrameRate = parseFrameRate(stream.AvgFrameRate)
+			metadata.ColorTransfer = stream.ColorTransfer
+			metadata.ColorPrimaries = stream.ColorPrimaries
+			metadata.ColorSpace = stream.ColorSpace
+			metadata.PixelFormat = stream.PixFmt
+			metadata.BitDepth = bitDepth(stream.BitsPerRaw, stream.PixFmt)
+			metadata.VideoProfile = stream.Profile
+			metadata.VideoLevel = stream.Level
+		}
+		if stream.CodecType == "audio" {
+			if metadata.AudioCodec == "" {
+				metadata.AudioCodec = stream.CodecName
 			}
+			metadata.AudioStreams = append(metadata.AudioStreams, AudioStream{
+				Codec:    stream.CodecName,
+				Language: stream.Tags.Language,
+				Title:    stream.Tags.Title,
+				Default:  stream.Disposition.Default == 1,
+			})
 		}
-		if stream.CodecType == "audio" && metadata.AudioCodec == "" {
-			metadata.AudioCodec = stream.CodecName
+		if stream.CodecType == "subtitle" {
+			metadata.SubtitleStreams = append(metadata.SubtitleStreams, SubtitleStream{
+				Index:           stream.Index,
+				Codec:           stream.CodecName,
+				Language:        stream.Tags.Language,
+				Title:           stream.Tags.Title,
+				Default:         stream.Disposition.Default == 1,
+				HearingImpaired: stream.Disposition.HearingImpaired == 1,
+			})
 		}
 	}
 
-	if metadata.VideoCodec == "" {
-		return nil, fmt.Errorf("no video stream found in file")
+	return metadata, nil
+}
+
+// parseFrameRate reads a rate as ffprobe writes it, "num/den", and returns
+// zero for one it cannot read or that is unknown, "0/0".
+func parseFrameRate(rate string) float64 {
+	num, den, ok := strings.Cut(rate, "/")
+	if !ok {
+		return 0
+	}
+	n, err1 := strconv.ParseFloat(num, 64)
+	d, err2 := strconv.ParseFloat(den, 64)
+	if err1 != nil || err2 != nil || d <= 0 {
+		return 0
 	}
+	return n / d
+}
 
-	s.log.Info(ctx, "extracted video metadata", map[string]interface{}{
-		"file":       filePath,
-		"duration":   metadata.Duration,
-		"resolution": fmt.Sprintf("%dx%d", metadata.Width, metadata.Height),
-		"codec":      metadata.VideoCodec,
-	})
+// pixelFormatDepth matches the bits per component at the end of a pixel
+// format's name: yuv420p10le, gbrp12.
+var pixelFormatDepth = regexp.MustCompile(`p(\d{1,2})(?:le|be)?$`)
 
-	return metadata, nil
+// bitDepth is the bits of each component of a video stream: what its codec
+// reports, or else what its pixel format's name says, or else 8.
+func bitDepth(bitsPerRawSample, pixFmt string) int {
+	if bits, err := strconv.Atoi(bitsPerRawSample); err == nil && bits > 0 {
+		return bits
+	}
+	if m := pixelFormatDepth.FindStringSubmatch(pixFmt); m != nil {
+		if bits, err := strconv.Atoi(m[1]); err == nil && bits >= 8 {
+			return bits
+		}
+	}
+	return 8
 }
 
 func (s *FFmpegService) ensureFFprobePath() {
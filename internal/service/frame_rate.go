@@ -0,0 +1,102 @@
+package service
+
+import (
+	"math"
+	"strconv"
+)
+
+// frameRate is a frame rate as a fraction, which is how ffmpeg's fps filter
+// and DASH take rates such as 30000/1001 that no decimal writes exactly.
+type frameRate struct {
+	num, den int
+}
+
+// standardFrameRates are the rates video is shot, edited and broadcast at.
+var standardFrameRates = []frameRate{
+	{24000, 1001}, {24, 1}, {25, 1}, {30000, 1001}, {30, 1},
+	{48, 1}, {50, 1}, {60000, 1001}, {60, 1},
+	{100, 1}, {120000, 1001}, {120, 1},
+}
+
+// standardRateTolerance is how far, as a fraction, a measured rate may be
+// from a standard one and still be taken for it: a phone's variable frame
+// rate averages 29.87 over a clip shot at 29.97.
+const standardRateTolerance = 0.01
+
+func (r frameRate) float() float64 {
+	if r.den <= 0 {
+		return 0
+	}
+	return float64(r.num) / float64(r.den)
+}
+
+// String writes the rate as the fps filter takes it: "24", "30000/1001".
+func (r frameRate) String() string {
+	if r.den == 1 {
+		return strconv.Itoa(r.num)
+	}
+	return strconv.Itoa(r.num) + "/" + strconv.Itoa(r.den)
+}
+
+// decimal writes the rate to three places, as HLS's FRAME-RATE wants it.
+func (r frameRate) decimal() string {
+	return strconv.FormatFloat(r.float(), 'f', 3, 64)
+}
+
+func (r frameRate) reduced() frameRate {
+	a, b := r.num, r.den
+	for b != 0 {
+		a, b = b, a%b
+	}
+	if a <= 1 {
+		return r
+	}
+	return frameRate{r.num / a, r.den / a}
+}
+
+// sourceFrameRate is the rate the source's video is shown at: its average
+// rate where the container gives one, since a variable frame rate video's
+// r_frame_rate is the finest step of its timestamps, taken for the nearest
+// standard rate it is within standardRateTolerance of, or rounded to a whole
+// rate. It is zero when the source gives no rate at all.
+func sourceFrameRate(metadata *VideoMetadata) frameRate {
+	rate := metadata.AvgFrameRate
+	if rate <= 0 {
+		rate = metadata.FrameRate
+	}
+	if rate <= 0 {
+		return frameRate{}
+	}
+	// The nearest, not the first: 30 is within a percent of 29.97 too.
+	nearest, distance := frameRate{}, math.Inf(1)
+	for _, standard := range standardFrameRates {
+		d := math.Abs(rate - standard.float())
+		if d <= standard.float()*standardRateTolerance && d < distance {
+			nearest, distance = standard, d
+		}
+	}
+	if nearest.den > 0 {
+		return nearest
+	}
+	return frameRate{max(1, int(math.Round(rate))), 1}
+}
+
+// rungFrameRate is the rate a rung whose ceiling is fps frames a second is
+// encoded at: the source's own rate, or the largest whole fraction of it at
+// or under the ceiling. Every frame shown is then a frame of the source, so
+// a 24 fps film is not given the judder of frames repeated to make 30, and
+// 60 fps comes down to 30 by dropping every other frame. A source without a
+// rate gets the ceiling.
+func rungFrameRate(source frameRate, fps int) frameRate {
+	if source.float() <= 0 {
+		return frameRate{fps, 1}
+	}
+	// A hair over, so rounding never takes a rate at the ceiling for one
+	// above it.
+	ceiling := float64(fps) + 0.01
+	divisor := 1
+	for source.float()/float64(divisor) > ceiling {
+		divisor++
+	}
+	return frameRate{source.num, source.den * divisor}.reduced()
+}
@@ -0,0 +1,79 @@
+package service
+
+import "testing"
+
+func TestSourceFrameRate(t *testing.T) {
+	tests := []struct {
+		name       string
+		rFrameRate float64
+		avg        float64
+		want       frameRate
+	}{
+		{"film", 24000.0 / 1001, 24000.0 / 1001, frameRate{24000, 1001}},
+		{"NTSC", 30000.0 / 1001, 30000.0 / 1001, frameRate{30000, 1001}},
+		{"NTSC at 59.94", 60000.0 / 1001, 60000.0 / 1001, frameRate{60000, 1001}},
+		{"PAL", 25, 25, frameRate{25, 1}},
+		{"PAL at 50", 50, 50, frameRate{50, 1}},
+		{"whole 24", 24, 24, frameRate{24, 1}},
+		{"whole 30", 30, 30, frameRate{30, 1}},
+		{"whole 60", 60, 60, frameRate{60, 1}},
+		// A phone's variable frame rate: timestamps counted in a fine step,
+		// averaging a little under the rate it was shot at.
+		{"variable, near a standard rate", 90000, 29.87, frameRate{30000, 1001}},
+		{"variable, near no standard rate", 1000, 27.3, frameRate{27, 1}},
+		{"no average rate", 25, 0, frameRate{25, 1}},
+		{"no rate at all", 0, 0, frameRate{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sourceFrameRate(&VideoMetadata{FrameRate: tt.rFrameRate, AvgFrameRate: tt.avg})
+			if got != tt.want {
+				t.Errorf("sourceFrameRate(r=%v, avg=%v) = %v, want %v", tt.rFrameRate, tt.avg, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRungFrameRate(t *testing.T) {
+	tests := []struct {
+		name   string
+		source frameRate
+		fps    int
+		want   string
+	}{
+		{"film under the ceiling", frameRate{24000, 1001}, 30, "24000/1001"},
+		{"NTSC at a ceiling of 30", frameRate{30000, 1001}, 30, "30000/1001"},
+		{"59.94 halved", frameRate{60000, 1001}, 30, "30000/1001"},
+		{"59.94 under a ceiling of 60", frameRate{60000, 1001}, 60, "60000/1001"},
+		{"50 halved", frameRate{50, 1}, 30, "25"},
+		{"50 under a ceiling of 24", frameRate{50, 1}, 24, "50/3"},
+		{"25 never raised", frameRate{25, 1}, 60, "25"},
+		{"120 quartered", frameRate{120, 1}, 30, "30"},
+		{"no source rate", frameRate{}, 30, "30"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rungFrameRate(tt.source, tt.fps).String(); got != tt.want {
+				t.Errorf("rungFrameRate(%v, %d) = %s, want %s", tt.source, tt.fps, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFrameRate(t *testing.T) {
+	tests := []struct {
+		rate string
+		want float64
+	}{
+		{"30000/1001", 30000.0 / 1001},
+		{"25/1", 25},
+		{"0/0", 0},
+		{"", 0},
+		{"N/A", 0},
+	}
+	for _, tt := range tests {
+		if got := parseFrameRate(tt.rate); got != tt.want {
+			t.Errorf("parseFrameRate(%q) = %v, want %v", tt.rate, got, tt.want)
+		}
+	}
+}
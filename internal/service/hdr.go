@@ -0,0 +1,82 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/config"
+)
+
+// hdrRenditionSuffix names the HDR rung after the rung it matches in size:
+// 2160p-hdr beside 2160p.
+const hdrRenditionSuffix = "-hdr"
+
+// sdrToneMapFilter maps an HDR picture, PQ or HLG, to 8-bit BT.709 SDR: to
+// linear light at 100 nits of nominal peak, into BT.709 primaries, through
+// the Hable curve, which rolls the highlights off rather than clipping them,
+// and back to BT.709's transfer function. zscale reads the input's transfer
+// and primaries from the stream's tags.
+const sdrToneMapFilter = "zscale=t=linear:npl=100,format=gbrpf32le,zscale=p=bt709," +
+	"tonemap=tonemap=hable:desat=0,zscale=t=bt709:m=bt709:r=tv,format=yuv420p"
+
+// bt709TagArgs tag an encode as BT.709 SDR, which a tone-mapped one is
+// whatever the source was tagged with.
+var bt709TagArgs = []string{"-color_primaries", "bt709", "-color_trc", "bt709", "-colorspace", "bt709"}
+
+// hdrRung is the rung that keeps an HDR source HDR: the largest of rungs, in
+// HEVC at the source's dynamic range, named after it. There is none for an
+// SDR source, or when the name it would get is unusable or taken.
+func hdrRung(rungs []config.Rendition, metadata *VideoMetadata) (config.Rendition, bool) {
+	videoRange := metadata.VideoRange()
+	if videoRange == config.VideoRangeSDR || len(rungs) == 0 {
+		return config.Rendition{}, false
+	}
+	top := rungs[0]
+	for _, rung := range rungs[1:] {
+		if rung.Pixels() > top.Pixels() {
+			top = rung
+		}
+	}
+
+	hdr := top
+	hdr.Name = top.Name + hdrRenditionSuffix
+	hdr.Codec = config.CodecHEVC
+	hdr.VideoRange = videoRange
+	if _, taken := findRung(rungs, hdr.Name); taken || !config.ValidRenditionName(hdr.Name) {
+		return config.Rendition{}, false
+	}
+	return hdr, true
+}
+
+// rungFilter is the video filter graph that makes a rung of the source: its
+// size and frame rate, then its pixel format. An HDR rung stays 10-bit; every
+// other rung is 8-bit 4:2:0, tone-mapped to SDR first when the source is HDR,
+// which an H.264 player would otherwise show washed out.
+func rungFilter(rung config.Rendition, rate frameRate, sourceRange string) string {
+	filters := []string{fmt.Sprintf("scale=%d:%d", rung.Width, rung.Height), "fps=" + rate.String()}
+	switch {
+	case rung.HDR():
+		filters = append(filters, "format=yuv420p10le")
+	case sourceRange != config.VideoRangeSDR:
+		filters = append(filters, sdrToneMapFilter)
+	default:
+		filters = append(filters, "format=yuv420p")
+	}
+	return strings.Join(filters, ",")
+}
+
+// hevcParams are the libx265 options of a rung in HEVC: parameter sets in
+// every keyframe and closed GOPs, so each segment decodes on its own, and for
+// an HDR rung the colour description of its range. ffmpeg 7 and later pass
+// the source's mastering display and light level metadata through on their
+// own.
+func hevcParams(rung config.Rendition) string {
+	params := []string{"repeat-headers=1", "no-open-gop=1"}
+	switch rung.VideoRange {
+	case config.VideoRangePQ:
+		params = append(params, "hdr10=1", "hdr10-opt=1", "colorprim=bt2020", "transfer=smpte2084", "colormatrix=bt2020nc")
+	case config.VideoRangeHLG:
+		params = append(params, "colorprim=bt2020", "transfer=arib-std-b67", "colormatrix=bt2020nc")
+	}
+	return strings.Join(params, ":")
+}
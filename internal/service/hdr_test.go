@@ -0,0 +1,92 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/config"
+)
+
+func TestHDRRung(t *testing.T) {
+	ladder := []config.Rendition{rung360, rung1080, rung720}
+	long := config.Rendition{Name: strings.Repeat("x", 29) + "p", Width: 3840, Height: 2160}
+
+	tests := []struct {
+		name     string
+		rungs    []config.Rendition
+		transfer string
+		want     string
+		wantOK   bool
+	}{
+		{"SDR source", ladder, "bt709", "", false},
+		{"untagged source", ladder, "", "", false},
+		{"PQ source", ladder, transferPQ, "1080p-hdr", true},
+		{"HLG source", ladder, transferHLG, "1080p-hdr", true},
+		{"no rungs", nil, transferPQ, "", false},
+		{"name too long to serve", append(ladder, long), transferPQ, "", false},
+		{"name taken", append(ladder, config.Rendition{Name: "1080p-hdr", Width: 640, Height: 360}), transferPQ, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hdr, ok := hdrRung(tt.rungs, &VideoMetadata{ColorTransfer: tt.transfer})
+			if ok != tt.wantOK || hdr.Name != tt.want {
+				t.Fatalf("hdrRung() = %q, %v; want %q, %v", hdr.Name, ok, tt.want, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			// The streaming handler refuses a quality it could not have
+			// written, so the name must pass the same check.
+			if !config.ValidRenditionName(hdr.Name) {
+				t.Errorf("%q is not a valid rendition name", hdr.Name)
+			}
+			want := rung1080
+			want.Name, want.Codec, want.VideoRange = "1080p-hdr", config.CodecHEVC, (&VideoMetadata{ColorTransfer: tt.transfer}).VideoRange()
+			if hdr != want {
+				t.Errorf("hdrRung() = %+v, want %+v", hdr, want)
+			}
+		})
+	}
+}
+
+func TestRungFilter(t *testing.T) {
+	hdr := rung1080
+	hdr.Name, hdr.Codec, hdr.VideoRange = "1080p-hdr", config.CodecHEVC, config.VideoRangePQ
+	rate := frameRate{30000, 1001}
+
+	tests := []struct {
+		name        string
+		rung        config.Rendition
+		sourceRange string
+		want        string
+	}{
+		{"SDR rung of an SDR source", rung720, config.VideoRangeSDR, "scale=1280:720,fps=30000/1001,format=yuv420p"},
+		{"SDR rung of a PQ source", rung720, config.VideoRangePQ, "scale=1280:720,fps=30000/1001," + sdrToneMapFilter},
+		{"SDR rung of an HLG source", rung720, config.VideoRangeHLG, "scale=1280:720,fps=30000/1001," + sdrToneMapFilter},
+		{"HDR rung", hdr, config.VideoRangePQ, "scale=1920:1080,fps=30000/1001,format=yuv420p10le"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rungFilter(tt.rung, rate, tt.sourceRange); got != tt.want {
+				t.Errorf("rungFilter() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHEVCParams(t *testing.T) {
+	sdr := rung1080
+	sdr.Codec = config.CodecHEVC
+	pq, hlg := sdr, sdr
+	pq.VideoRange, hlg.VideoRange = config.VideoRangePQ, config.VideoRangeHLG
+
+	if got := hevcParams(sdr); got != "repeat-headers=1:no-open-gop=1" {
+		t.Errorf("SDR params = %q", got)
+	}
+	if got := hevcParams(pq); !strings.Contains(got, "hdr10=1") || !strings.Contains(got, "transfer=smpte2084") {
+		t.Errorf("PQ params = %q, want HDR10 signalled", got)
+	}
+	if got := hevcParams(hlg); strings.Contains(got, "hdr10") || !strings.Contains(got, "transfer=arib-std-b67") {
+		t.Errorf("HLG params = %q, want HLG's transfer and no HDR10", got)
+	}
+}
@@ -0,0 +1,316 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/google/uuid"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/pkg/safehttp"
+	"github.com/Nuu-maan/video-streaming-service/pkg/validator"
+)
+
+// ImportRequest describes a video to import rather than upload. Exactly one
+// of URL and Path is set: an http(s) URL on the public internet, or a path
+// relative to the server's import drop directory.
+type ImportRequest struct {
+	Title          string
+	Description    string
+	OwnerID        uuid.UUID
+	Visibility     domain.VideoVisibility
+	NormalizeAudio bool
+	URL            string
+	Path           string
+}
+
+// CreateImport records a video whose source the worker is to fetch, waiting
+// in VideoStatusUploading with no FilePath until it has. Only what can be
+// told without fetching is checked here; whether the source exists, how
+// large it is and what it contains is the fetch's to find out.
+func (s *UploadService) CreateImport(ctx context.Context, req ImportRequest) (*domain.Video, error) {
+	details, err := validateDetails(req.Title, req.Description, req.Visibility, req.NormalizeAudio)
+	if err != nil {
+		return nil, err
+	}
+
+	var source domain.VideoImport
+	var filename string
+	switch {
+	case (req.URL == "") == (req.Path == ""):
+		return nil, fmt.Errorf("%w: give either a url or a path", domain.ErrInvalidImportSource)
+	case req.URL != "":
+		u, err := url.Parse(req.URL)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", domain.ErrInvalidImportSource, err)
+		}
+		if err := safehttp.CheckURL(u); err != nil {
+			return nil, fmt.Errorf("%w: %v", domain.ErrInvalidImportSource, err)
+		}
+		source = domain.VideoImport{Kind: domain.ImportURL, Source: u.String()}
+		filename = path.Base(u.Path)
+	default:
+		if s.storageCfg.ImportDropDir == "" {
+			return nil, fmt.Errorf("%w: importing from the drop directory is turned off", domain.ErrInvalidImportSource)
+		}
+		cleaned, err := domain.CleanDropPath(req.Path)
+		if err != nil {
+			return nil, err
+		}
+		source = domain.VideoImport{Kind: domain.ImportDrop, Source: cleaned}
+		filename = path.Base(cleaned)
+	}
+	if filename == "." || filename == "/" {
+		filename = "import"
+	}
+
+	// Like a clip's, the video has no file until the worker fetches one. Its
+	// size and type are placeholders NewVideo accepts, replaced by the real
+	// ones when the source is recorded.
+	video, err := domain.NewVideo(details.title, details.description, filename, "", "application/octet-stream", 1)
+	if err != nil {
+		return nil, err
+	}
+	video.Visibility = details.visibility
+	video.NormalizeAudio = details.normalizeAudio
+	if req.OwnerID != uuid.Nil {
+		owner := req.OwnerID
+		video.UserID = &owner
+	}
+	video.Import = &source
+
+	if err := s.videoRepo.Create(ctx, video); err != nil {
+		return nil, fmt.Errorf("recording import: %w", err)
+	}
+
+	s.log.Info(ctx, "video import created", map[string]interface{}{
+		"video_id": video.ID,
+		"kind":     source.Kind,
+	})
+	return video, nil
+}
+
+// FetchedSource is an imported video's source once fetched: a local file and
+// what it turned out to be.
+type FetchedSource struct {
+	Path     string
+	MIMEType string
+	// Ext is the extension the source's raw file is named with, after its
+	// container rather than whatever the URL or path said.
+	Ext  string
+	Size int64
+}
+
+// containerExts names raw files by the container DetectVideoContainer found.
+var containerExts = map[string]string{
+	"video/mp4":        ".mp4",
+	"video/quicktime":  ".mov",
+	"video/webm":       ".webm",
+	"video/x-matroska": ".mkv",
+	"video/x-msvideo":  ".avi",
+}
+
+// FetchImport copies video's import source into a new file in dir. A URL is
+// fetched with a client that only connects to public addresses and follows a
+// bounded number of redirects; a drop path is opened within the drop
+// directory, never outside it. Either way the source may be no larger than an
+// upload, and must be a container an upload may be.
+//
+// An error is one of the domain's import or source errors when the source is
+// at fault, and retrying will not help unless it is ErrImportUnreachable.
+func (s *TranscodingService) FetchImport(ctx context.Context, video *domain.Video, dir string) (*FetchedSource, error) {
+	if video.Import == nil {
+		return nil, fmt.Errorf("video %s is not an import", video.ID)
+	}
+
+	var src io.ReadCloser
+	var err error
+	switch video.Import.Kind {
+	case domain.ImportURL:
+		src, err = s.openImportURL(ctx, video.Import.Source)
+	case domain.ImportDrop:
+		src, err = s.openDropFile(video.Import.Source)
+	default:
+		return nil, fmt.Errorf("unknown import kind %q", video.Import.Kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	out, err := os.CreateTemp(dir, "import-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating import file: %w", err)
+	}
+	fetched := &FetchedSource{Path: out.Name()}
+	keep := false
+	defer func() {
+		if !keep {
+			os.Remove(fetched.Path)
+		}
+	}()
+
+	// One byte past the limit is enough to know the source is over it.
+	limit := s.storage.MaxFileSize
+	size, err := io.Copy(out, io.LimitReader(src, limit+1))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: reading the source: %v", domain.ErrImportUnreachable, err)
+	}
+	if size > limit {
+		return nil, fmt.Errorf("%w: more than %d bytes", domain.ErrImportTooLarge, limit)
+	}
+	if size == 0 {
+		return nil, fmt.Errorf("%w: the source is empty", domain.ErrSourceUnrecognized)
+	}
+
+	container, err := sniffFile(fetched.Path)
+	if err != nil {
+		return nil, err
+	}
+	fetched.MIMEType = container.MIMEType
+	fetched.Ext = containerExts[container.MIMEType]
+	fetched.Size = size
+	keep = true
+	return fetched, nil
+}
+
+// openImportURL starts fetching rawURL, turning the ways it can fail into
+// the domain's import errors.
+func (s *TranscodingService) openImportURL(ctx context.Context, rawURL string) (io.ReadCloser, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrImportForbidden, err)
+	}
+	if err := safehttp.CheckURL(u); err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrImportForbidden, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrImportForbidden, err)
+	}
+
+	resp, err := s.importClient.Do(req)
+	// The client wraps its errors with the URL, whose query may hold a
+	// token; what went wrong is enough.
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, safehttp.ErrForbiddenAddress), errors.Is(err, safehttp.ErrForbiddenURL):
+		return nil, fmt.Errorf("%w: %v", domain.ErrImportForbidden, err)
+	case errors.Is(err, safehttp.ErrTooManyRedirects):
+		return nil, fmt.Errorf("%w: %v", domain.ErrImportTooManyRedirects, err)
+	default:
+		return nil, fmt.Errorf("%w: %v", domain.ErrImportUnreachable, err)
+	}
+
+	status := resp.StatusCode
+	switch {
+	case status == http.StatusOK:
+	case status == http.StatusNotFound || status == http.StatusGone:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: the server answered %s", domain.ErrImportNotFound, resp.Status)
+	case status == http.StatusTooManyRequests || status >= 500:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: the server answered %s", domain.ErrImportUnreachable, resp.Status)
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: the server answered %s", domain.ErrImportRefused, resp.Status)
+	}
+	// A declared length over the limit is refused before any of it is read;
+	// the copy enforces the limit on the rest.
+	if resp.ContentLength > s.storage.MaxFileSize {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %d bytes, more than %d", domain.ErrImportTooLarge, resp.ContentLength, s.storage.MaxFileSize)
+	}
+	return resp.Body, nil
+}
+
+// openDropFile opens rel within the import drop directory. os.Root refuses
+// any path, symbolic links included, that would leave the directory.
+func (s *TranscodingService) openDropFile(rel string) (io.ReadCloser, error) {
+	if s.storage.ImportDropDir == "" {
+		return nil, fmt.Errorf("%w: importing from the drop directory is turned off", domain.ErrImportForbidden)
+	}
+	root, err := os.OpenRoot(s.storage.ImportDropDir)
+	if err != nil {
+		return nil, fmt.Errorf("opening the drop directory: %w", err)
+	}
+	defer root.Close()
+
+	f, err := root.Open(filepath.FromSlash(rel))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, fmt.Errorf("%w: %s", domain.ErrImportNotFound, rel)
+	case err != nil:
+		return nil, fmt.Errorf("%w: %v", domain.ErrImportForbidden, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("statting %s: %w", rel, err)
+	}
+	if !info.Mode().IsRegular() {
+		f.Close()
+		return nil, fmt.Errorf("%w: %s is not a file", domain.ErrImportNotFound, rel)
+	}
+	if info.Size() > s.storage.MaxFileSize {
+		f.Close()
+		return nil, fmt.Errorf("%w: %d bytes, more than %d", domain.ErrImportTooLarge, info.Size(), s.storage.MaxFileSize)
+	}
+	return f, nil
+}
+
+// sniffFile identifies the container of the file at path from its first
+// bytes, as an upload's is.
+func sniffFile(path string) (validator.VideoContainer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return validator.VideoContainer{}, fmt.Errorf("opening import file: %w", err)
+	}
+	defer f.Close()
+
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return validator.VideoContainer{}, fmt.Errorf("reading import file: %w", err)
+	}
+	container, ok := validator.DetectVideoContainer(buf[:n])
+	if !ok {
+		return validator.VideoContainer{}, domain.ErrSourceUnrecognized
+	}
+	return container, nil
+}
+
+// FailImport fails an imported video whose source could not be fetched. A
+// source at fault is shown to the uploader, as a rejected upload is; any
+// other failure is the service's and is not.
+func (s *TranscodingService) FailImport(ctx context.Context, id uuid.UUID, cause error) {
+	perr, rejected := domain.SourceRejection(cause)
+	if !rejected {
+		s.markFailed(ctx, id)
+		return
+	}
+	if err := s.videoRepo.MarkAsRejected(ctx, id, *perr); err != nil {
+		s.log.Error(ctx, "failed to mark import as rejected", err, map[string]interface{}{
+			"video_id": id,
+		})
+	}
+	s.announce(ctx, domain.VideoProgress{VideoID: id, Status: domain.VideoStatusFailed, Error: perr})
+}
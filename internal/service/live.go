@@ -0,0 +1,318 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/config"
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+)
+
+const (
+	// liveSegmentSeconds is the target segment length of a broadcast. It is
+	// shorter than hlsSegmentSeconds because a player starts three segments
+	// behind the live edge, so the segment length sets the latency. There
+	// are no low-latency HLS parts to go below it: ffmpeg's HLS muxer does
+	// not write them.
+	liveSegmentSeconds = 2
+	// liveConnectTimeout is how long the worker keeps trying to read a push
+	// back from the ingest server. The push is authorized, and this task
+	// queued, before the ingest server has the stream to give.
+	liveConnectTimeout = 30 * time.Second
+	liveConnectRetry   = 2 * time.Second
+)
+
+// liveArgs builds the ffmpeg command line that reads a broadcast from input
+// and encodes it to every rung at once, each an HLS event playlist in its
+// own directory of hlsDir with the audio muxed into its segments, while
+// copying the push as it came to recording. Keyframes are forced every
+// liveSegmentSeconds in every rung so their segments line up, and the
+// encoder is tuned for speed over size: it has to keep up with the push.
+func liveArgs(input, hlsDir, recording string, rungs []config.Rendition, threads int, maxDuration time.Duration) []string {
+	args := []string{
+		"-rtsp_transport", "tcp",
+		// Microseconds without a packet before the read gives up, which is
+		// how the end of a push that vanished rather than closed is noticed.
+		"-timeout", "10000000",
+		"-t", strconv.FormatFloat(maxDuration.Seconds(), 'f', -1, 64),
+		"-i", input,
+		"-progress", "pipe:1",
+		"-nostats",
+		"-y",
+	}
+	for _, rung := range rungs {
+		dir := filepath.Join(hlsDir, rung.Name)
+		args = append(args,
+			"-map", "0:v:0",
+			"-map", "0:a:0?",
+			"-vf", fmt.Sprintf("scale=%d:%d,format=yuv420p", rung.Width, rung.Height),
+			"-fpsmax", strconv.Itoa(rung.FPS),
+			"-c:v", "libx264",
+			"-preset", "veryfast",
+			"-tune", "zerolatency",
+			"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", liveSegmentSeconds),
+			"-b:v", fmt.Sprintf("%dk", rung.BitrateKbps),
+			"-maxrate", fmt.Sprintf("%dk", rung.MaxRateKbps),
+			"-bufsize", fmt.Sprintf("%dk", rung.BufSizeKbps),
+			"-threads", strconv.Itoa(threads),
+		)
+		args = append(args, audioEncodeArgs...)
+		args = append(args, strippedMetadataArgs...)
+		args = append(args,
+			"-f", "hls",
+			"-hls_time", strconv.Itoa(liveSegmentSeconds),
+			"-hls_playlist_type", "event",
+			// temp_file has every segment and playlist renamed into place
+			// once whole, so whatever reads the directory meanwhile never
+			// sees half of one.
+			"-hls_flags", "independent_segments+program_date_time+temp_file",
+			"-hls_segment_filename", filepath.Join(dir, "segment_%03d.ts"),
+			"-master_pl_name", variantPlaylistName,
+			filepath.Join(dir, "playlist.m3u8"),
+		)
+	}
+	return append(args,
+		"-map", "0:v:0",
+		"-map", "0:a:0?",
+		"-c", "copy",
+		"-f", "matroska",
+		recording,
+	)
+}
+
+// LivePullURL is where the worker reads the push at path back from the
+// ingest server at pullURL, with the stream's LiveReadPassword.
+func LivePullURL(pullURL, path, password string) (string, error) {
+	u, err := url.Parse(pullURL)
+	if err != nil {
+		return "", fmt.Errorf("parsing live pull URL: %w", err)
+	}
+	u.User = url.UserPassword(LiveWorkerUser, password)
+	u.Path = "/" + path
+	return u.String(), nil
+}
+
+// BroadcastLive transcodes the push at input into rungs in hlsDir, and
+// copies it to recording, until the push stops or maxDuration passes. A
+// push the ingest server does not have yet is waited for, for
+// liveConnectTimeout.
+//
+// input holds the stream's read password, and ffmpeg names its input in what
+// it writes to stderr, which a failure carries; the password is cut from the
+// error before it reaches a log.
+func (s *TranscodingService) BroadcastLive(ctx context.Context, input, hlsDir, recording string, rungs []config.Rendition, maxDuration time.Duration) error {
+	for _, rung := range rungs {
+		if err := os.MkdirAll(filepath.Join(hlsDir, rung.Name), 0o755); err != nil {
+			return fmt.Errorf("creating rendition directory: %w", err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(recording), 0o755); err != nil {
+		return fmt.Errorf("creating recording directory: %w", err)
+	}
+
+	args := liveArgs(input, hlsDir, recording, rungs, s.worker.JobThreads, maxDuration)
+	deadline := time.Now().Add(liveConnectTimeout)
+	for {
+		err := s.runFFmpeg(ctx, args, 0, func(float64) {})
+		if err == nil || ctx.Err() != nil || time.Now().After(deadline) || LiveOnAir(hlsDir, rungs[:1]) {
+			return redactSecret(err, input)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(liveConnectRetry):
+		}
+	}
+}
+
+// redactSecret returns err with the password of the URL input removed,
+// however ffmpeg wrote it.
+func redactSecret(err error, input string) error {
+	if err == nil {
+		return nil
+	}
+	u, parseErr := url.Parse(input)
+	if parseErr != nil || u.User == nil {
+		return err
+	}
+	secret, ok := u.User.Password()
+	if !ok || secret == "" {
+		return err
+	}
+	msg := strings.ReplaceAll(err.Error(), u.User.String(), u.User.Username()+":REDACTED")
+	msg = strings.ReplaceAll(msg, secret, "REDACTED")
+	return errors.New(msg)
+}
+
+// LivePlaylist is a live media playlist as it was read at one moment: the
+// bytes, the segments they list and whether they are sealed with
+// EXT-X-ENDLIST. ffmpeg rewrites the playlist as each segment is done, so
+// whatever is done with the segments is done with these bytes alongside.
+type LivePlaylist struct {
+	Content  []byte
+	Segments []string
+	Ended    bool
+}
+
+// ReadLivePlaylist reads the live media playlist in dir. One not written yet
+// lists no segments.
+func ReadLivePlaylist(dir string) (*LivePlaylist, error) {
+	content, err := os.ReadFile(filepath.Join(dir, "playlist.m3u8"))
+	if errors.Is(err, os.ErrNotExist) {
+		return &LivePlaylist{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading live playlist: %w", err)
+	}
+	return ParseLivePlaylist(content)
+}
+
+// ParseLivePlaylist reads a live media playlist's segments. They are only
+// ever named beside it, so anything else is refused.
+func ParseLivePlaylist(content []byte) (*LivePlaylist, error) {
+	p := &LivePlaylist{Content: content}
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "#EXT-X-ENDLIST":
+			p.Ended = true
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.ContainsAny(line, `/\`) || strings.Contains(line, ".."):
+			return nil, fmt.Errorf("live playlist names %q, outside its directory", line)
+		default:
+			p.Segments = append(p.Segments, line)
+		}
+	}
+	return p, nil
+}
+
+// LiveOnAir reports whether every rung in hlsDir has a segment to play and
+// the variant playlist ffmpeg writes once it has, which the master playlist
+// is built from.
+func LiveOnAir(hlsDir string, rungs []config.Rendition) bool {
+	for _, rung := range rungs {
+		dir := filepath.Join(hlsDir, rung.Name)
+		if _, err := os.Stat(filepath.Join(dir, variantPlaylistName)); err != nil {
+			return false
+		}
+		if playlist, err := ReadLivePlaylist(dir); err != nil || len(playlist.Segments) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteLiveMaster writes master.m3u8 in hlsDir listing rungs, as the variant
+// playlists ffmpeg wrote for them describe them. A broadcast's audio is
+// muxed into every rung's segments, so there is no audio group: each variant
+// already counts and names it. Broadcasts are encoded SDR.
+func WriteLiveMaster(hlsDir string, rungs []config.Rendition) error {
+	version := 3
+	lines := []string{"#EXTM3U", ""}
+	for _, rung := range rungs {
+		attrs, v, err := readVariant(filepath.Join(hlsDir, rung.Name, variantPlaylistName))
+		if err != nil {
+			return fmt.Errorf("%s: %w", rung.Name, err)
+		}
+		version = max(version, v)
+		attrs = setAttribute(attrs, "VIDEO-RANGE", config.VideoRangeSDR)
+		lines = append(lines, "#EXT-X-STREAM-INF:"+attrs, rung.Name+"/playlist.m3u8")
+	}
+	lines[1] = fmt.Sprintf("#EXT-X-VERSION:%d", version)
+
+	if err := os.WriteFile(filepath.Join(hlsDir, "master.m3u8"), []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("writing master playlist: %w", err)
+	}
+	return nil
+}
+
+// SealLivePlaylists ends the media playlist of each rung in hlsDir that has
+// segments with EXT-X-ENDLIST, where ffmpeg did not get to write it, and
+// returns the rungs that have segments and how long the first of them
+// plays, in seconds. A broadcast's playlists are sealed once it is over,
+// which turns them into a video's.
+func SealLivePlaylists(hlsDir string, rungs []config.Rendition) (qualities []string, duration float64, err error) {
+	for _, rung := range rungs {
+		dir := filepath.Join(hlsDir, rung.Name)
+		playlist, err := ReadLivePlaylist(dir)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", rung.Name, err)
+		}
+		if len(playlist.Segments) == 0 {
+			continue
+		}
+		content := playlist.Content
+		if !playlist.Ended {
+			content = append([]byte(strings.TrimRight(string(content), "\n")+"\n"), "#EXT-X-ENDLIST\n"...)
+			if err := os.WriteFile(filepath.Join(dir, "playlist.m3u8"), content, 0644); err != nil {
+				return nil, 0, fmt.Errorf("sealing %s playlist: %w", rung.Name, err)
+			}
+		}
+		if len(qualities) == 0 {
+			duration = playlistDuration(string(content))
+		}
+		qualities = append(qualities, rung.Name)
+	}
+	return qualities, duration, nil
+}
+
+// playlistDuration adds up the EXTINF durations of a media playlist.
+func playlistDuration(playlist string) float64 {
+	var total float64
+	for _, line := range strings.Split(playlist, "\n") {
+		value, ok := strings.CutPrefix(strings.TrimSpace(line), "#EXTINF:")
+		if !ok {
+			continue
+		}
+		value, _, _ = strings.Cut(value, ",")
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+			total += seconds
+		}
+	}
+	return total
+}
+
+// GoLive puts video id on air, its master playlist at masterKey in output
+// revision 0. Viewers can play it from here on, though it is not ready: it
+// has no length, and grows until the broadcast ends.
+func (s *TranscodingService) GoLive(ctx context.Context, id uuid.UUID, masterKey string) error {
+	if err := s.videoRepo.UpdateHLSInfo(ctx, id, masterKey, 0, true, domain.StreamingProtocolHLS); err != nil {
+		return fmt.Errorf("recording live playlists: %w", err)
+	}
+	if err := s.videoRepo.UpdateStatus(ctx, id, domain.VideoStatusLive); err != nil {
+		return fmt.Errorf("marking video live: %w", err)
+	}
+	s.announce(ctx, domain.VideoProgress{VideoID: id, Status: domain.VideoStatusLive})
+	s.log.Info(ctx, "video is live", map[string]interface{}{
+		"video_id": id,
+	})
+	return nil
+}
+
+// FinishLive marks video id ready once its broadcast is over and its
+// playlists sealed, as qualities lasting duration seconds. It has no
+// thumbnail until it is processed again from the recording.
+func (s *TranscodingService) FinishLive(ctx context.Context, id uuid.UUID, qualities []string, duration float64) error {
+	if err := s.videoRepo.UpdateDuration(ctx, id, int(duration+0.5)); err != nil {
+		return fmt.Errorf("recording broadcast duration: %w", err)
+	}
+	if err := s.videoRepo.MarkAsReady(ctx, id, qualities, ""); err != nil {
+		return fmt.Errorf("failed to mark video as ready: %w", err)
+	}
+	s.announce(ctx, domain.VideoProgress{VideoID: id, Status: domain.VideoStatusReady, Progress: 100})
+	return nil
+}
+
+// FailLive fails video id, whose broadcast ended with nothing to show.
+func (s *TranscodingService) FailLive(ctx context.Context, id uuid.UUID) {
+	s.markFailed(ctx, id)
+}
@@ -0,0 +1,311 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/config"
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/internal/repository"
+	"github.com/Nuu-maan/video-streaming-service/pkg/logger"
+)
+
+// LiveWorkerUser is the user name the worker reads broadcasts back from the
+// ingest server with. The password is LiveReadPassword.
+const LiveWorkerUser = "worker"
+
+// LiveReadPassword is the password the worker reads stream id back from the
+// ingest server with, derived from secret, LiveConfig.IngestSecret. It goes
+// on ffmpeg's command line, where anyone on the worker's host can see it, so
+// it is good for reading that one stream and nothing else, and the secret it
+// is derived from never leaves the API and the worker.
+func LiveReadPassword(secret string, id uuid.UUID) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("live-read:" + id.String()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// LiveRecordingMIMEType is the type of a broadcast's recording, which the
+// worker writes as Matroska: it takes any codec a push arrives in, and stays
+// readable up to where it stops should the worker die mid-broadcast.
+const LiveRecordingMIMEType = "video/x-matroska"
+
+// LiveStreamRepository stores live streams.
+type LiveStreamRepository interface {
+	Create(ctx context.Context, stream *domain.LiveStream) error
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.LiveStream, error)
+	ListByUser(ctx context.Context, userID uuid.UUID, page repository.Page) ([]*domain.LiveStream, error)
+	// Start moves a waiting stream on air, and reports whether it was
+	// waiting. Of two pushes racing to start one stream, one starts it.
+	Start(ctx context.Context, id uuid.UUID) (bool, error)
+	// End records that stream is over. A non-nil recording becomes its
+	// video's source, as an upload's raw file would, without changing the
+	// video's status.
+	End(ctx context.Context, stream *domain.LiveStream, recording *LiveRecording) error
+}
+
+// LiveRecording is the raw file a broadcast was recorded to.
+type LiveRecording struct {
+	Path     string
+	MIMEType string
+	Size     int64
+}
+
+// LiveQueue queues the transcoding of a broadcast that has gone on air,
+// for at most maxDuration. Satisfied by *queue.QueueClient, which imports
+// this package and so cannot be named here.
+type LiveQueue interface {
+	EnqueueLiveIngest(ctx context.Context, streamID string, maxDuration time.Duration) error
+}
+
+// LiveService creates live streams and decides, for the ingest server, who
+// may push to and read from them.
+type LiveService struct {
+	streams LiveStreamRepository
+	videos  repository.VideoRepository
+	queue   LiveQueue
+	cfg     *config.LiveConfig
+	log     *logger.Logger
+}
+
+func NewLiveService(
+	streams LiveStreamRepository,
+	videos repository.VideoRepository,
+	queue LiveQueue,
+	cfg *config.LiveConfig,
+	log *logger.Logger,
+) *LiveService {
+	return &LiveService{
+		streams: streams,
+		videos:  videos,
+		queue:   queue,
+		cfg:     cfg,
+		log:     log,
+	}
+}
+
+// LiveRequest describes a live stream to create, and the video it
+// broadcasts into.
+type LiveRequest struct {
+	Title       string
+	Description string
+	OwnerID     uuid.UUID
+	Visibility  domain.VideoVisibility
+}
+
+// LiveIngest is where and how a stream is pushed to. It holds the stream
+// key, and is only ever shown to the owner, once.
+type LiveIngest struct {
+	StreamKey string `json:"stream_key"`
+	// RTMPURL and RTMPStreamKey are the server and stream key fields of an
+	// RTMP encoder such as OBS.
+	RTMPURL       string `json:"rtmp_url"`
+	RTMPStreamKey string `json:"rtmp_stream_key"`
+	// SRTURL is the whole address an SRT encoder pushes to.
+	SRTURL string `json:"srt_url"`
+}
+
+// CreateStream records a stream and the video it broadcasts into, and
+// returns them with the ingest details holding the stream key. The video
+// waits in VideoStatusUploading, with no file, until the broadcast goes on
+// air.
+func (s *LiveService) CreateStream(ctx context.Context, req LiveRequest) (*domain.LiveStream, *domain.Video, *LiveIngest, error) {
+	details, err := validateDetails(req.Title, req.Description, req.Visibility, false)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Like an import's, the video has no file until the broadcast ends and
+	// its recording is recorded as the source; the size and type are
+	// placeholders NewVideo accepts.
+	video, err := domain.NewVideo(details.title, details.description, "live", "", LiveRecordingMIMEType, 1)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	video.Visibility = details.visibility
+	owner := req.OwnerID
+	video.UserID = &owner
+
+	stream, key, err := domain.NewLiveStream(video.ID, req.OwnerID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	video.Filename = stream.RecordingName()
+
+	if err := s.videos.Create(ctx, video); err != nil {
+		return nil, nil, nil, fmt.Errorf("recording live video: %w", err)
+	}
+	if err := s.streams.Create(ctx, stream); err != nil {
+		if delErr := s.videos.Delete(ctx, video.ID); delErr != nil {
+			s.log.Error(ctx, "failed to remove video of unrecorded live stream", delErr, map[string]interface{}{
+				"video_id": video.ID,
+			})
+		}
+		return nil, nil, nil, fmt.Errorf("recording live stream: %w", err)
+	}
+
+	s.log.Info(ctx, "live stream created", map[string]interface{}{
+		"stream_id": stream.ID,
+		"video_id":  video.ID,
+	})
+	return stream, video, s.ingest(stream, key), nil
+}
+
+// ingest returns the addresses stream is pushed to with key. MediaMTX takes
+// the credentials of an RTMP push from the query string of its stream name,
+// and those of an SRT push from the stream ID.
+func (s *LiveService) ingest(stream *domain.LiveStream, key string) *LiveIngest {
+	credentials := url.Values{"user": {domain.LiveIngestUser}, "pass": {key}}
+	srt := url.Values{"streamid": {"publish:" + stream.IngestPath() + ":" + domain.LiveIngestUser + ":" + key}}
+	return &LiveIngest{
+		StreamKey:     key,
+		RTMPURL:       strings.TrimSuffix(s.cfg.RTMPURL, "/") + "/live",
+		RTMPStreamKey: stream.ID.String() + "?" + credentials.Encode(),
+		SRTURL:        strings.TrimSuffix(s.cfg.SRTURL, "/") + "?" + srt.Encode(),
+	}
+}
+
+// GetStream returns stream id, if it is userID's.
+func (s *LiveService) GetStream(ctx context.Context, id, userID uuid.UUID) (*domain.LiveStream, error) {
+	stream, err := s.streams.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if stream.UserID != userID {
+		return nil, domain.ErrLiveStreamNotFound
+	}
+	return stream, nil
+}
+
+// ListStreams returns userID's streams, newest first.
+func (s *LiveService) ListStreams(ctx context.Context, userID uuid.UUID, page repository.Page) ([]*domain.LiveStream, error) {
+	return s.streams.ListByUser(ctx, userID, page)
+}
+
+// IngestAction is what the ingest server asks to do with a stream.
+type IngestAction string
+
+const (
+	IngestPublish IngestAction = "publish"
+	IngestRead    IngestAction = "read"
+)
+
+// IngestAuthRequest is the ingest server asking whether a connection may
+// take an action on a path, with the credentials it presented.
+type IngestAuthRequest struct {
+	User     string
+	Password string
+	Action   IngestAction
+	Path     string
+}
+
+// AuthorizeIngest answers the ingest server. A push is taken once per
+// stream, from its owner's key: taking it puts the stream on air and queues
+// the worker to transcode it, and a stream whose push dropped has ended and
+// takes no other. Only the worker reads a push back. Anything else is
+// refused with domain.ErrLiveStreamKey.
+func (s *LiveService) AuthorizeIngest(ctx context.Context, req IngestAuthRequest) error {
+	id, err := domain.ParseLiveIngestPath(req.Path)
+	if err != nil {
+		return domain.ErrLiveStreamKey
+	}
+
+	switch req.Action {
+	case IngestRead:
+		if req.User != LiveWorkerUser ||
+			subtle.ConstantTimeCompare([]byte(req.Password), []byte(LiveReadPassword(s.cfg.IngestSecret, id))) != 1 {
+			return domain.ErrLiveStreamKey
+		}
+		return nil
+	case IngestPublish:
+	default:
+		return domain.ErrLiveStreamKey
+	}
+
+	stream, err := s.streams.GetByID(ctx, id)
+	if errors.Is(err, domain.ErrLiveStreamNotFound) {
+		return domain.ErrLiveStreamKey
+	}
+	if err != nil {
+		return err
+	}
+	if req.User != domain.LiveIngestUser || !stream.CheckKey(req.Password) {
+		return domain.ErrLiveStreamKey
+	}
+	if stream.Status != domain.LiveStreamWaiting {
+		return domain.ErrLiveStreamEnded
+	}
+	started, err := s.streams.Start(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !started {
+		return domain.ErrLiveStreamEnded
+	}
+
+	if err := s.queue.EnqueueLiveIngest(ctx, id.String(), s.cfg.MaxDuration); err != nil {
+		// The push is refused, and nothing would ever transcode the stream
+		// it started, so it ends here; its video fails with it.
+		if endErr := s.streams.End(ctx, stream, nil); endErr != nil {
+			s.log.Error(ctx, "failed to end unqueued live stream", endErr, map[string]interface{}{
+				"stream_id": id,
+			})
+		}
+		if failErr := s.videos.UpdateStatus(ctx, stream.VideoID, domain.VideoStatusFailed); failErr != nil {
+			s.log.Error(ctx, "failed to fail video of unqueued live stream", failErr, map[string]interface{}{
+				"video_id": stream.VideoID,
+			})
+		}
+		return fmt.Errorf("queueing live ingest: %w", err)
+	}
+
+	s.log.Info(ctx, "live stream started", map[string]interface{}{
+		"stream_id": id,
+		"video_id":  stream.VideoID,
+	})
+	return nil
+}
+
+// MarkChapter starts a chapter titled title of userID's stream id where its
+// broadcast is now, and returns the video's chapters with it. They are the
+// video's, so they stay with it once the broadcast is over. The broadcast's
+// output starts a moment after its push does, once the worker has it, so a
+// chapter is marked that moment late at most.
+func (s *LiveService) MarkChapter(ctx context.Context, id, userID uuid.UUID, title string) ([]domain.Chapter, error) {
+	stream, err := s.GetStream(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if stream.Status != domain.LiveStreamLive || stream.StartedAt == nil {
+		return nil, domain.ErrLiveStreamNotLive
+	}
+	video, err := s.videos.GetByID(ctx, stream.VideoID)
+	if err != nil {
+		return nil, err
+	}
+
+	chapters := append(slices.Clone(video.Chapters), domain.Chapter{
+		Start: math.Floor(time.Since(*stream.StartedAt).Seconds()),
+		Title: strings.TrimSpace(title),
+	})
+	// The broadcast's length is not known until it ends.
+	if err := domain.ValidateChapters(chapters, 0); err != nil {
+		return nil, err
+	}
+	if err := s.videos.UpdateChapters(ctx, video.ID, chapters); err != nil {
+		return nil, fmt.Errorf("recording chapter: %w", err)
+	}
+	return chapters, nil
+}
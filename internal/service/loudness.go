@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+)
+
+const (
+	// loudnormRange is the loudness range, in LU, loudnorm is asked to keep
+	// within. It is wide enough that nearly every source already fits, so
+	// the second pass levels it with one linear gain instead of compressing
+	// its dynamics.
+	loudnormRange = 20
+	// loudnormSampleRate is what the second pass resamples to: loudnorm
+	// works, and writes, at 192kHz.
+	loudnormSampleRate = 48000
+)
+
+// LoudnessPlan is what the probe decides about a video's loudness: its audio
+// is measured whenever the worker has loudnorm on and the source has any,
+// and leveled to Target only when the upload asked for it.
+type LoudnessPlan struct {
+	Normalize bool    `json:"normalize"`
+	Target    float64 `json:"target"`
+	TruePeak  float64 `json:"true_peak"`
+}
+
+// LoudnessMeasurement is loudnorm's first pass over one audio track, kept as
+// loudnorm reports it, which is how its second pass takes it back. A silent
+// track measures "-inf".
+type LoudnessMeasurement struct {
+	InputI       string `json:"input_i"`
+	InputTP      string `json:"input_tp"`
+	InputLRA     string `json:"input_lra"`
+	InputThresh  string `json:"input_thresh"`
+	TargetOffset string `json:"target_offset"`
+}
+
+// silent reports whether the track measured no loudness at all, which no gain
+// can level.
+func (m LoudnessMeasurement) silent() bool {
+	i, err := strconv.ParseFloat(m.InputI, 64)
+	return err != nil || i < -70
+}
+
+// LoudnessResult is the loudness stage's checkpoint: a measurement of each of
+// the source's audio tracks, in the order of ProbeResult.Audio.
+type LoudnessResult struct {
+	Tracks []LoudnessMeasurement `json:"tracks"`
+}
+
+// MeasureLoudness runs the loudness stage of video against its source: the
+// first of loudnorm's two passes, over each of its audio tracks. The encode
+// stages of the tracks take the measurements into the second pass when the
+// probe planned normalization; either way the default track's is recorded on
+// the video.
+func (s *TranscodingService) MeasureLoudness(ctx context.Context, video *domain.Video, sourcePath string) (*LoudnessResult, error) {
+	probe, err := s.ProbeResult(ctx, video.ID)
+	if err != nil {
+		return nil, err
+	}
+	plan := probe.Loudness
+	if plan == nil {
+		return nil, fmt.Errorf("probe planned no loudness stage")
+	}
+
+	result := &LoudnessResult{Tracks: make([]LoudnessMeasurement, len(probe.Audio))}
+	for i := range probe.Audio {
+		args := []string{
+			"-i", sourcePath,
+			"-map", fmt.Sprintf("0:a:%d", i),
+			"-vn", "-sn",
+			"-af", fmt.Sprintf("loudnorm=I=%s:TP=%s:LRA=%d:print_format=json",
+				formatLevel(plan.Target), formatLevel(plan.TruePeak), loudnormRange),
+			"-f", "null", "-",
+		}
+		output, err := s.ffmpegOutput(ctx, args)
+		if err != nil {
+			return nil, fmt.Errorf("measuring loudness of %s: %w", probe.Audio[i], err)
+		}
+		if result.Tracks[i], err = parseLoudnorm(output); err != nil {
+			return nil, fmt.Errorf("measuring loudness of %s: %w", probe.Audio[i], err)
+		}
+	}
+
+	loudness := defaultLoudness(sourceAudioTracks(video.ID, probe.Metadata.AudioStreams), result, plan)
+	if err := s.videoRepo.UpdateLoudness(ctx, video.ID, loudness); err != nil {
+		return nil, fmt.Errorf("failed to record loudness: %w", err)
+	}
+
+	fields := map[string]interface{}{
+		"video_id":  video.ID,
+		"tracks":    len(result.Tracks),
+		"normalize": plan.Normalize,
+	}
+	if loudness != nil {
+		fields["integrated_lufs"] = loudness.IntegratedLUFS
+		fields["true_peak_dbtp"] = loudness.TruePeakDBTP
+	}
+	s.log.Info(ctx, "measured loudness", fields)
+	return result, nil
+}
+
+// defaultLoudness is the loudness recorded on a video: its default track's
+// measurement, or nil when that track is silent.
+func defaultLoudness(tracks []*domain.AudioTrack, result *LoudnessResult, plan *LoudnessPlan) *domain.AudioLoudness {
+	track := defaultTrack(tracks)
+	if track == nil || track.StreamIndex >= len(result.Tracks) {
+		return nil
+	}
+	m := result.Tracks[track.StreamIndex]
+	if m.silent() {
+		return nil
+	}
+	integrated, _ := strconv.ParseFloat(m.InputI, 64)
+	truePeak, _ := strconv.ParseFloat(m.InputTP, 64)
+	loudnessRange, _ := strconv.ParseFloat(m.InputLRA, 64)
+	return &domain.AudioLoudness{
+		IntegratedLUFS: integrated,
+		TruePeakDBTP:   truePeak,
+		RangeLU:        loudnessRange,
+		Normalized:     plan.Normalize,
+		TargetLUFS:     plan.Target,
+	}
+}
+
+// parseLoudnorm reads the measurement loudnorm prints, as JSON, at the end of
+// its first pass.
+func parseLoudnorm(output string) (LoudnessMeasurement, error) {
+	start, end := strings.LastIndex(output, "{"), strings.LastIndex(output, "}")
+	if start < 0 || end < start {
+		return LoudnessMeasurement{}, fmt.Errorf("loudnorm printed no measurement")
+	}
+	var m LoudnessMeasurement
+	if err := json.Unmarshal([]byte(output[start:end+1]), &m); err != nil {
+		return LoudnessMeasurement{}, fmt.Errorf("decoding loudnorm measurement: %w", err)
+	}
+	if m.InputI == "" || m.InputTP == "" || m.InputLRA == "" || m.InputThresh == "" || m.TargetOffset == "" {
+		return LoudnessMeasurement{}, fmt.Errorf("loudnorm measurement is incomplete")
+	}
+	return m, nil
+}
+
+// loudnormFilter is the second pass of loudnorm for a track measured as m:
+// one gain that brings it to plan's target, then back to a rate AAC encodes
+// at. A silent track is left alone.
+func loudnormFilter(plan *LoudnessPlan, m LoudnessMeasurement) string {
+	if m.silent() {
+		return ""
+	}
+	return fmt.Sprintf(
+		"loudnorm=I=%s:TP=%s:LRA=%d:measured_I=%s:measured_TP=%s:measured_LRA=%s:measured_thresh=%s:offset=%s:linear=true,aresample=%d",
+		formatLevel(plan.Target), formatLevel(plan.TruePeak), loudnormRange,
+		m.InputI, m.InputTP, m.InputLRA, m.InputThresh, m.TargetOffset, loudnormSampleRate,
+	)
+}
+
+// formatLevel writes a level in LUFS or dBTP as loudnorm takes it.
+func formatLevel(level float64) string {
+	return strconv.FormatFloat(level, 'f', -1, 64)
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/config"
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+)
+
+// writeMasterPlaylist writes master.m3u8 in hlsDir listing rungs, in ladder
+// order, as the variants their encode stages described; see readVariant. The
+// rungs were encoded video only, so with audio each variant's bandwidth gains
+// an audio rendition's; writeAudioGroup then adds the group itself. Each
+// variant states the frame rate it was encoded at from the source's, and its
+// VIDEO-RANGE, so a player that cannot show HDR passes over an HDR rung.
+func writeMasterPlaylist(hlsDir string, rungs []config.Rendition, variants map[string]EncodeResult, audio bool, source frameRate) error {
+	version := 3
+	for _, rung := range rungs {
+		version = max(version, variants[rung.Name].Version)
+	}
+
+	lines := []string{"#EXTM3U", fmt.Sprintf("#EXT-X-VERSION:%d", version)}
+	for _, rung := range rungs {
+		attrs := variants[rung.Name].StreamInf
+		if attrs == "" {
+			return fmt.Errorf("no variant attributes recorded for %s", rung.Name)
+		}
+		if audio {
+			for _, name := range []string{"BANDWIDTH", "AVERAGE-BANDWIDTH"} {
+				if value, err := strconv.Atoi(parseAttributes(attrs)[name]); err == nil {
+					attrs = setAttribute(attrs, name, strconv.Itoa(value+audioBitrateKbps*1000))
+				}
+			}
+		}
+		attrs = setAttribute(attrs, "FRAME-RATE", rungFrameRate(source, rung.FPS).decimal())
+		attrs = setAttribute(attrs, "VIDEO-RANGE", videoRange(rung))
+		lines = append(lines, "#EXT-X-STREAM-INF:"+attrs, rung.Name+"/playlist.m3u8")
+	}
+
+	if err := os.WriteFile(filepath.Join(hlsDir, "master.m3u8"), []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("writing master playlist: %w", err)
+	}
+	return nil
+}
+
+// videoRange is the VIDEO-RANGE of rung: its own, or SDR, which every rung
+// but an HDR one is, tone-mapped if need be.
+func videoRange(rung config.Rendition) string {
+	if rung.HDR() {
+		return rung.VideoRange
+	}
+	return config.VideoRangeSDR
+}
+
+// readVariant reads the master playlist ffmpeg wrote for a single rung: the
+// attribute list of its one variant and the playlist version.
+func readVariant(file string) (attrs string, version int, err error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return "", 0, fmt.Errorf("reading variant playlist: %w", err)
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if value, ok := strings.CutPrefix(line, "#EXT-X-VERSION:"); ok {
+			version, _ = strconv.Atoi(value)
+		}
+		if value, ok := strings.CutPrefix(line, "#EXT-X-STREAM-INF:"); ok && attrs == "" {
+			attrs = value
+		}
+	}
+	if attrs == "" {
+		return "", 0, fmt.Errorf("variant playlist lists no variant")
+	}
+	return attrs, version, nil
+}
+
+// rewriteMasterGroup replaces the EXT-X-MEDIA renditions of mediaType in
+// master.m3u8 in hlsDir with media, written just before the first variant,
+// and passes every variant's attribute list through variant. Renditions of
+// other types are kept where they are. ffmpeg's audio-only variants are
+// dropped on the way, since every writer would drop them: see writeAudioGroup.
+func rewriteMasterGroup(hlsDir, mediaType string, media []string, variant func(attrs string) string) error {
+	file := filepath.Join(hlsDir, "master.m3u8")
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("reading master playlist: %w", err)
+	}
+
+	lines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	out := make([]string, 0, len(lines)+len(media))
+	written := false
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-MEDIA:"):
+			if parseAttributes(strings.TrimPrefix(line, "#EXT-X-MEDIA:"))["TYPE"] == mediaType {
+				continue
+			}
+			out = append(out, line)
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
+			if i+1 < len(lines) && strings.HasPrefix(path.Dir(strings.TrimSpace(lines[i+1])), config.AudioRenditionPrefix) {
+				i++
+				continue
+			}
+			if !written {
+				out = append(out, media...)
+				written = true
+			}
+			out = append(out, "#EXT-X-STREAM-INF:"+variant(strings.TrimPrefix(line, "#EXT-X-STREAM-INF:")))
+		default:
+			out = append(out, line)
+		}
+	}
+	if !written {
+		return fmt.Errorf("master playlist lists no video variant")
+	}
+
+	if err := os.WriteFile(file, []byte(strings.Join(out, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("writing master playlist: %w", err)
+	}
+	return nil
+}
+
+// menuLabels names each of a group's renditions for a player's menu: its
+// label, else its language, else fallback and its position. HLS requires
+// names to be unique within a group, so repeats are numbered.
+func menuLabels(labels, languages []string, fallback string) []string {
+	names := make([]string, len(labels))
+	seen := make(map[string]int)
+	for i, label := range labels {
+		if label == "" && languages[i] != domain.UndeterminedLanguage {
+			label = languages[i]
+		}
+		if label == "" {
+			label = fmt.Sprintf("%s %d", fallback, i+1)
+		}
+		seen[label]++
+		if n := seen[label]; n > 1 {
+			label = fmt.Sprintf("%s (%d)", label, n)
+		}
+		names[i] = label
+	}
+	return names
+}
+
+// splitAttributes splits an HLS attribute list into its NAME=value items,
+// leaving quoted values, which may contain commas, whole.
+func splitAttributes(list string) []string {
+	var items []string
+	quoted := false
+	start := 0
+	for i, r := range list {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ',' && !quoted:
+			items = append(items, list[start:i])
+			start = i + 1
+		}
+	}
+	if start < len(list) {
+		items = append(items, list[start:])
+	}
+	return items
+}
+
+// setAttribute sets name to the already-quoted value in an HLS attribute
+// list, in place if it is there and at the end if not.
+func setAttribute(list, name, value string) string {
+	items := splitAttributes(list)
+	for i, item := range items {
+		if key, _, _ := strings.Cut(item, "="); key == name {
+			items[i] = name + "=" + value
+			return strings.Join(items, ",")
+		}
+	}
+	return strings.Join(append(items, name+"="+value), ",")
+}
+
+// removeAttribute drops name from an HLS attribute list.
+func removeAttribute(list, name string) string {
+	items := splitAttributes(list)
+	kept := items[:0]
+	for _, item := range items {
+		if key, _, _ := strings.Cut(item, "="); key != name {
+			kept = append(kept, item)
+		}
+	}
+	return strings.Join(kept, ",")
+}
+
+// masterPackaging tells the packaging a video was encoded with from its
+// master playlist, so a dub matches the renditions beside it whatever the
+// worker is configured with today. ffmpeg declares version 7 for fragmented
+// MP4 and never for MPEG-TS, and writeMasterPlaylist keeps the version the
+// rungs were written with.
+func masterPackaging(hlsDir string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(hlsDir, "master.m3u8"))
+	if err != nil {
+		return "", fmt.Errorf("reading master playlist: %w", err)
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), "#EXT-X-VERSION:"); ok {
+			if version, err := strconv.Atoi(value); err == nil && version >= 7 {
+				return config.PackagingCMAF, nil
+			}
+			break
+		}
+	}
+	return config.PackagingTS, nil
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/google/uuid"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/internal/storage"
+)
+
+// A video's transcoded output is kept in revisions. Revision 0 is the layout
+// every video had before there were any: transcoded/<id>/. Processing a video
+// that is already published writes the next revision beside the one being
+// served, under transcoded/<id>/r<n>/, and publishing it swaps the two, so
+// viewers keep watching the old renditions until the new ones are all there.
+// The old revision is deleted a while later; see RetiredOutput.
+
+// revisionDir is the directory revision is kept in under a video's output,
+// "" for revision 0.
+func revisionDir(revision int) string {
+	if revision == 0 {
+		return ""
+	}
+	return "r" + strconv.Itoa(revision)
+}
+
+// OutputKey addresses a file of revision of video id's transcoded output in
+// the store.
+func OutputKey(id uuid.UUID, revision int, parts ...string) string {
+	return storage.Key(append([]string{"transcoded", id.String(), revisionDir(revision)}, parts...)...)
+}
+
+// OutputPath is the directory the worker writes revision of video id's output
+// to under root, its TranscodedPath: the local counterpart of OutputKey.
+func OutputPath(root string, id uuid.UUID, revision int) string {
+	return filepath.Join(root, id.String(), revisionDir(revision))
+}
+
+// nextRevision is the revision processing video writes: a new one when it
+// has output being served, else the one it has.
+func nextRevision(video *domain.Video) int {
+	if video.HLSReady {
+		return video.OutputRevision + 1
+	}
+	return video.OutputRevision
+}
+
+// RetiredOutput names what revision of video id's output is made of, to be
+// deleted once another has replaced it: prefixes holding whole directories,
+// and single keys. A later revision is a directory of its own. Revision 0
+// shares its directory with every later one, so it is its HLS and trickplay
+// directories and a progressive MP4 for each of qualities, the renditions it
+// was published with.
+func RetiredOutput(id uuid.UUID, revision int, qualities []string) (prefixes, keys []string) {
+	if revision > 0 {
+		return []string{OutputKey(id, revision)}, nil
+	}
+	prefixes = []string{OutputKey(id, 0, "hls"), OutputKey(id, 0, TrickplayDir)}
+	for _, quality := range qualities {
+		keys = append(keys, OutputKey(id, 0, quality+".mp4"))
+	}
+	return prefixes, keys
+}
+
+// RequeueAddedTracks returns to pending the dubs and uploaded captions of
+// video id that were published with the output it has just replaced, and
+// returns them for the caller to queue: the new master playlist lists only
+// what came with the source, and each has to be published into it again.
+func (s *TranscodingService) RequeueAddedTracks(ctx context.Context, id uuid.UUID) ([]*domain.AudioTrack, []*domain.Caption, error) {
+	tracks, err := s.audioTracks.ListByVideo(ctx, id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list audio tracks: %w", err)
+	}
+	var dubs []*domain.AudioTrack
+	for _, track := range tracks {
+		if track.Kind != domain.AudioTrackDub || track.Status != domain.AudioTrackReady {
+			continue
+		}
+		if err := s.audioTracks.UpdateStatus(ctx, track.ID, domain.AudioTrackPending); err != nil {
+			return nil, nil, fmt.Errorf("failed to requeue audio track: %w", err)
+		}
+		dubs = append(dubs, track)
+	}
+
+	existing, err := s.captions.ListByVideo(ctx, id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list captions: %w", err)
+	}
+	var captions []*domain.Caption
+	for _, caption := range existing {
+		if caption.Embedded || caption.Status != domain.CaptionReady {
+			continue
+		}
+		if err := s.captions.UpdateStatus(ctx, caption.ID, domain.CaptionPending); err != nil {
+			return nil, nil, fmt.Errorf("failed to requeue caption: %w", err)
+		}
+		captions = append(captions, caption)
+	}
+	return dubs, captions, nil
+}
@@ -0,0 +1,455 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/config"
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+)
+
+const (
+	// complexitySamples scenes of complexitySampleSeconds each are analysed,
+	// spread over the video, so neither a title card nor one busy scene
+	// speaks for all of it.
+	complexitySamples       = 3
+	complexitySampleSeconds = 4.0
+	// perTitleMinDuration is the shortest video worth analysing, in seconds.
+	// Below it the samples would be most of the video, and so would the cost.
+	perTitleMinDuration = 10.0
+	// perTitleTargetPSNR is the quality, in dB, each rung is given bitrate
+	// for: the PSNR against the source at the rung's own size above which
+	// x264's artefacts stop showing on most content.
+	perTitleTargetPSNR = 40.0
+	// perTitleHeadroom is added to the bitrate the samples needed, since the
+	// scenes left out may be harder than the ones measured.
+	perTitleHeadroom = 1.15
+	// minVisibleGain is how much better, in dB of display PSNR, a rung must
+	// look than the rung below it to be worth encoding.
+	minVisibleGain = 0.5
+	// maxPSNR stands in for the infinite PSNR of identical frames.
+	maxPSNR = 100.0
+)
+
+// complexityCRFs are the CRFs every rung is probe-encoded at, from better than
+// any rung needs to worse than any should get.
+var complexityCRFs = []int{18, 23, 28}
+
+// perTitleLadder adapts rungs, the renditions a job is about to encode, to the
+// video: its scenes are probe-encoded and measured (see analyseComplexity)
+// and the ladder chosen from the measurements (see chooseLadder) is recorded
+// on the video. A ladder chosen earlier from the same rungs is reused rather
+// than measured again. Per-title encoding only saves bits, so when the
+// analysis fails the job goes on with rungs as configured.
+func (s *TranscodingService) perTitleLadder(ctx context.Context, id uuid.UUID, inputPath string, metadata *VideoMetadata, rungs []config.Rendition) []config.Rendition {
+	basis := config.FormatLadder(rungs)
+
+	stored, err := s.videoRepo.GetEncodingLadder(ctx, id)
+	if err != nil {
+		s.log.Warn(ctx, "failed to load the stored encoding ladder, analysing again", map[string]interface{}{
+			"video_id": id,
+			"error":    err.Error(),
+		})
+	} else if stored != nil && stored.Basis == basis {
+		s.log.Info(ctx, "reusing per-title ladder", map[string]interface{}{
+			"video_id":    id,
+			"analyzed_at": stored.AnalyzedAt,
+		})
+		return ladderRenditions(stored.Rungs)
+	}
+
+	if metadata.Duration < perTitleMinDuration {
+		return rungs
+	}
+
+	ladder, err := s.analyseComplexity(ctx, inputPath, metadata.Duration, rungs)
+	if err != nil {
+		s.log.Error(ctx, "complexity analysis failed, encoding the configured ladder", err, map[string]interface{}{
+			"video_id": id,
+		})
+		return rungs
+	}
+	ladder.Basis = basis
+
+	if err := s.videoRepo.UpdateEncodingLadder(ctx, id, ladder); err != nil {
+		s.log.Error(ctx, "failed to record per-title ladder", err, map[string]interface{}{
+			"video_id": id,
+		})
+	}
+
+	chosen := ladderRenditions(ladder.Rungs)
+	bitrates := make(map[string]int, len(chosen))
+	for _, rung := range chosen {
+		bitrates[rung.Name] = rung.BitrateKbps
+	}
+	s.log.Info(ctx, "per-title ladder chosen", map[string]interface{}{
+		"video_id": id,
+		"bitrates": bitrates,
+		"dropped":  ladder.Dropped,
+	})
+	return chosen
+}
+
+// analyseComplexity probe-encodes sampled scenes of the source at each rung
+// and each of complexityCRFs with a fast preset, measures what every probe
+// cost and how it looks, and chooses the ladder from that. The rungs are
+// probed side by side, each with the share of the job's threads its encode
+// will have, so the analysis keeps to WORKER_JOB_THREADS as the encodes do.
+func (s *TranscodingService) analyseComplexity(ctx context.Context, inputPath string, duration float64, rungs []config.Rendition) (*domain.EncodingLadder, error) {
+	dir, err := os.MkdirTemp("", "complexity-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating probe directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The first rung to fail stops the others: the analysis is lost anyway.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	starts, length := sampleWindows(duration)
+	display := rungs[0]
+	for _, rung := range rungs[1:] {
+		if rung.Pixels() > display.Pixels() {
+			display = rung
+		}
+	}
+
+	threads := threadShares(rungs, s.worker.JobThreads)
+	measured := make([][]domain.ComplexityProbe, len(rungs))
+	failures := make([]error, len(rungs))
+	var wg sync.WaitGroup
+	for i, rung := range rungs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, crf := range complexityCRFs {
+				probe, err := s.probeEncode(ctx, inputPath, dir, starts, length, rung, display, crf, threads[i])
+				if err != nil {
+					failures[i] = fmt.Errorf("probing %s at CRF %d: %w", rung.Name, crf, err)
+					cancel()
+					return
+				}
+				measured[i] = append(measured[i], probe)
+			}
+		}()
+	}
+	wg.Wait()
+	// A rung stopped by another's failure reports a cancellation; report the
+	// failure that stopped it instead.
+	for _, err := range failures {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+	}
+	for _, err := range failures {
+		if err != nil {
+			return nil, err
+		}
+	}
+	probes := slices.Concat(measured...)
+
+	chosen, dropped := chooseLadder(rungs, probes)
+	return &domain.EncodingLadder{
+		Rungs:         ladderRungs(chosen),
+		Dropped:       dropped,
+		SampleStarts:  starts,
+		SampleSeconds: length,
+		Probes:        probes,
+		AnalyzedAt:    time.Now().UTC(),
+	}, nil
+}
+
+// sampleWindows returns where the sampled scenes of a duration-second video
+// start, centred on equal parts of it, and how long each one is.
+func sampleWindows(duration float64) (starts []float64, length float64) {
+	length = min(complexitySampleSeconds, duration/complexitySamples)
+	for i := 0; i < complexitySamples; i++ {
+		centre := duration * float64(2*i+1) / (2 * complexitySamples)
+		starts = append(starts, math.Round(max(centre-length/2, 0)*1000)/1000)
+	}
+	return starts, length
+}
+
+// probeEncode encodes the samples at rung's size with crf, then measures the
+// result against the source twice: at the rung's size, and scaled up to
+// display's, as a player shows it full screen. Both passes keep to threads.
+func (s *TranscodingService) probeEncode(ctx context.Context, inputPath, dir string, starts []float64, length float64, rung, display config.Rendition, crf, threads int) (domain.ComplexityProbe, error) {
+	probe := domain.ComplexityProbe{Rendition: rung.Name, CRF: crf}
+	probePath := filepath.Join(dir, fmt.Sprintf("%s_crf%d.mkv", rung.Name, crf))
+
+	// veryfast spends somewhat more bits than the real encode's preset for
+	// the same CRF, which errs on the side of enough.
+	args := sampleInputs(inputPath, starts, length)
+	args = append(args,
+		"-filter_complex", fmt.Sprintf("%s,scale=%d:%d,format=yuv420p[v]", concatSamples(0, len(starts)), rung.Width, rung.Height),
+		"-map", "[v]",
+		"-an",
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-crf", strconv.Itoa(crf),
+		"-threads", strconv.Itoa(threads),
+		"-y",
+		probePath,
+	)
+	if _, err := s.ffmpegOutput(ctx, args); err != nil {
+		return probe, err
+	}
+	info, err := os.Stat(probePath)
+	if err != nil {
+		return probe, fmt.Errorf("probe encode not written: %w", err)
+	}
+	seconds := length * float64(len(starts))
+	probe.BitrateKbps = max(int(math.Round(float64(info.Size())*8/1000/seconds)), 1)
+
+	// Both sides are renumbered frame by frame, so the comparison pairs the
+	// same frames whatever timestamps the probe's container rounded to.
+	var graph strings.Builder
+	fmt.Fprintf(&graph, "%s,settb=1/1000,setpts=N,split=2[r0][r1];", concatSamples(1, len(starts)))
+	fmt.Fprintf(&graph, "[r0]scale=%d:%d,format=yuv420p[ref];", rung.Width, rung.Height)
+	fmt.Fprintf(&graph, "[r1]scale=%d:%d,format=yuv420p[refd];", display.Width, display.Height)
+	fmt.Fprintf(&graph, "[0:v]settb=1/1000,setpts=N,split=2[p0][p1];")
+	fmt.Fprintf(&graph, "[p1]scale=%d:%d,format=yuv420p[up];", display.Width, display.Height)
+	graph.WriteString("[p0][ref]psnr[own];[up][refd]psnr[disp]")
+
+	args = append([]string{"-i", probePath}, sampleInputs(inputPath, starts, length)...)
+	args = append(args,
+		"-filter_complex_threads", strconv.Itoa(threads),
+		"-filter_complex", graph.String(),
+		"-map", "[own]",
+		"-map", "[disp]",
+		"-f", "null",
+		"-",
+	)
+	output, err := s.ffmpegOutput(ctx, args)
+	if err != nil {
+		return probe, err
+	}
+	scores := parsePSNR(output)
+	if len(scores) != 2 {
+		return probe, fmt.Errorf("expected 2 PSNR measurements, got %d", len(scores))
+	}
+	probe.PSNR, probe.DisplayPSNR = scores[0], scores[1]
+	return probe, nil
+}
+
+// sampleInputs opens each sampled scene of inputPath as an input of its own.
+func sampleInputs(inputPath string, starts []float64, length float64) []string {
+	var args []string
+	for _, start := range starts {
+		args = append(args,
+			"-ss", strconv.FormatFloat(start, 'f', 3, 64),
+			"-t", strconv.FormatFloat(length, 'f', 3, 64),
+			"-i", inputPath,
+		)
+	}
+	return args
+}
+
+// concatSamples joins the video of n sample inputs, starting at input first,
+// into one stream.
+func concatSamples(first, n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "[%d:v]", first+i)
+	}
+	fmt.Fprintf(&b, "concat=n=%d:v=1:a=0", n)
+	return b.String()
+}
+
+// psnrSummary matches the line the psnr filter logs when it finishes, which
+// names the filter instance it comes from.
+var psnrSummary = regexp.MustCompile(`Parsed_psnr_(\d+) @ [^\]]*\] PSNR .*average:(inf|[0-9.]+)`)
+
+// parsePSNR returns the average PSNR each psnr filter of a graph reported, in
+// the order the filters appear in the graph.
+func parsePSNR(output string) []float64 {
+	type score struct {
+		filter int
+		psnr   float64
+	}
+	var scores []score
+	for _, m := range psnrSummary.FindAllStringSubmatch(output, -1) {
+		filter, _ := strconv.Atoi(m[1])
+		psnr := maxPSNR
+		if m[2] != "inf" {
+			psnr, _ = strconv.ParseFloat(m[2], 64)
+		}
+		scores = append(scores, score{filter, min(psnr, maxPSNR)})
+	}
+	sort.Slice(scores, func(i, j int) bool { return scores[i].filter < scores[j].filter })
+
+	out := make([]float64, len(scores))
+	for i, s := range scores {
+		out[i] = s.psnr
+	}
+	return out
+}
+
+// ffmpegOutput runs ffmpeg with args and returns what it logged, which is
+// where filters such as psnr report.
+func (s *TranscodingService) ffmpegOutput(ctx context.Context, args []string) (string, error) {
+	s.ensureFFmpegPath()
+
+	cmd := exec.CommandContext(ctx, s.ffmpegPath, append([]string{"-nostdin", "-hide_banner", "-nostats"}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("ffmpeg cancelled or timed out: %w", ctx.Err())
+		}
+		return "", fmt.Errorf("ffmpeg failed: %w, output: %s", err, stderr.String())
+	}
+	return stderr.String(), nil
+}
+
+// chooseLadder picks every rung's bitrate from its probes and drops the rungs
+// that look no better than the rung below them.
+//
+// A rung gets the bitrate at which it reaches perTitleTargetPSNR, plus
+// perTitleHeadroom, but never more than configured; its maxrate and bufsize
+// keep their configured ratio to it. Going up from the smallest rung, which is
+// always kept, a rung is dropped when at its bitrate it gains less than
+// minVisibleGain of display PSNR over the last rung kept: an upscaled soft
+// source, say, looks no sharper at 1080p than at 720p.
+func chooseLadder(rungs []config.Rendition, probes []domain.ComplexityProbe) ([]config.Rendition, []string) {
+	order := make([]int, len(rungs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return rungs[order[a]].Pixels() < rungs[order[b]].Pixels() })
+
+	chosen := make(map[string]config.Rendition, len(rungs))
+	var dropped []string
+	var lastQuality float64
+	for _, i := range order {
+		rung := rungs[i]
+		curve := curveFor(rung.Name, probes)
+		bitrate := min(curve.bitrateFor(perTitleTargetPSNR)*perTitleHeadroom, float64(rung.BitrateKbps))
+		quality := curve.displayPSNRAt(bitrate)
+
+		if len(chosen) > 0 && quality-lastQuality < minVisibleGain {
+			dropped = append(dropped, rung.Name)
+			continue
+		}
+		chosen[rung.Name] = withBitrate(rung, bitrate)
+		lastQuality = quality
+	}
+
+	out := make([]config.Rendition, 0, len(chosen))
+	for _, rung := range rungs {
+		if r, ok := chosen[rung.Name]; ok {
+			out = append(out, r)
+		}
+	}
+	return out, dropped
+}
+
+// withBitrate returns rung at kbps, its maxrate and bufsize scaled with it.
+func withBitrate(rung config.Rendition, kbps float64) config.Rendition {
+	scale := kbps / float64(rung.BitrateKbps)
+	rung.BitrateKbps = max(int(math.Round(kbps)), 1)
+	rung.MaxRateKbps = max(int(math.Round(float64(rung.MaxRateKbps)*scale)), rung.BitrateKbps)
+	rung.BufSizeKbps = max(int(math.Round(float64(rung.BufSizeKbps)*scale)), 1)
+	return rung
+}
+
+// rateCurve is one rung's probes by ascending bitrate. Between probes, quality
+// is taken to be linear in the logarithm of bitrate, which is close to how
+// encoders behave.
+type rateCurve []domain.ComplexityProbe
+
+func curveFor(rendition string, probes []domain.ComplexityProbe) rateCurve {
+	var c rateCurve
+	for _, p := range probes {
+		if p.Rendition == rendition {
+			c = append(c, p)
+		}
+	}
+	sort.Slice(c, func(i, j int) bool { return c[i].BitrateKbps < c[j].BitrateKbps })
+	return c
+}
+
+// bitrateFor returns the bitrate at which the curve first reaches psnr, the
+// lowest bitrate measured if even that is enough, or +Inf if nothing measured
+// is.
+func (c rateCurve) bitrateFor(psnr float64) float64 {
+	for i, p := range c {
+		if p.PSNR < psnr {
+			continue
+		}
+		if i == 0 {
+			return float64(p.BitrateKbps)
+		}
+		lo := c[i-1]
+		t := (psnr - lo.PSNR) / (p.PSNR - lo.PSNR)
+		return math.Exp(math.Log(float64(lo.BitrateKbps)) + t*(math.Log(float64(p.BitrateKbps))-math.Log(float64(lo.BitrateKbps))))
+	}
+	return math.Inf(1)
+}
+
+// displayPSNRAt returns the display PSNR the curve has at kbps, holding the
+// end values beyond the bitrates measured.
+func (c rateCurve) displayPSNRAt(kbps float64) float64 {
+	if len(c) == 0 {
+		return 0
+	}
+	if kbps <= float64(c[0].BitrateKbps) {
+		return c[0].DisplayPSNR
+	}
+	for i := 1; i < len(c); i++ {
+		lo, hi := c[i-1], c[i]
+		if kbps > float64(hi.BitrateKbps) {
+			continue
+		}
+		t := (math.Log(kbps) - math.Log(float64(lo.BitrateKbps))) / (math.Log(float64(hi.BitrateKbps)) - math.Log(float64(lo.BitrateKbps)))
+		return lo.DisplayPSNR + t*(hi.DisplayPSNR-lo.DisplayPSNR)
+	}
+	return c[len(c)-1].DisplayPSNR
+}
+
+func ladderRungs(renditions []config.Rendition) []domain.EncodingRung {
+	rungs := make([]domain.EncodingRung, len(renditions))
+	for i, r := range renditions {
+		rungs[i] = domain.EncodingRung{
+			Name:        r.Name,
+			Width:       r.Width,
+			Height:      r.Height,
+			BitrateKbps: r.BitrateKbps,
+			MaxRateKbps: r.MaxRateKbps,
+			BufSizeKbps: r.BufSizeKbps,
+			FPS:         r.FPS,
+		}
+	}
+	return rungs
+}
+
+func ladderRenditions(rungs []domain.EncodingRung) []config.Rendition {
+	renditions := make([]config.Rendition, len(rungs))
+	for i, r := range rungs {
+		renditions[i] = config.Rendition{
+			Name:        r.Name,
+			Width:       r.Width,
+			Height:      r.Height,
+			BitrateKbps: r.BitrateKbps,
+			MaxRateKbps: r.MaxRateKbps,
+			BufSizeKbps: r.BufSizeKbps,
+			FPS:         r.FPS,
+		}
+	}
+	return renditions
+}
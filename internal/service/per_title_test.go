@@ -0,0 +1,152 @@
+package service
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/config"
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+)
+
+var (
+	rung360  = config.Rendition{Name: "360p", Width: 640, Height: 360, BitrateKbps: 800, MaxRateKbps: 900, BufSizeKbps: 1800, FPS: 30}
+	rung720  = config.Rendition{Name: "720p", Width: 1280, Height: 720, BitrateKbps: 2800, MaxRateKbps: 3000, BufSizeKbps: 6000, FPS: 30}
+	rung1080 = config.Rendition{Name: "1080p", Width: 1920, Height: 1080, BitrateKbps: 5000, MaxRateKbps: 5500, BufSizeKbps: 11000, FPS: 60}
+)
+
+// probesOf makes a rung's probes from {kbps, psnr, display psnr} points, one
+// per CRF.
+func probesOf(rendition string, points ...[3]float64) []domain.ComplexityProbe {
+	probes := make([]domain.ComplexityProbe, len(points))
+	for i, p := range points {
+		probes[i] = domain.ComplexityProbe{
+			Rendition:   rendition,
+			CRF:         complexityCRFs[len(complexityCRFs)-1-i],
+			BitrateKbps: int(p[0]),
+			PSNR:        p[1],
+			DisplayPSNR: p[2],
+		}
+	}
+	return probes
+}
+
+func TestChooseLadder(t *testing.T) {
+	rungs := []config.Rendition{rung360, rung720, rung1080}
+	probes360 := probesOf("360p", [3]float64{200, 36, 30}, [3]float64{400, 42, 33}, [3]float64{800, 46, 35})
+	probes720 := probesOf("720p", [3]float64{1000, 38, 35}, [3]float64{2000, 41, 38}, [3]float64{4000, 44, 39})
+
+	// 360p reaches 40 dB two thirds of the way from 200 to 400 kbps in
+	// log-bitrate, 720p two thirds of the way from 1000 to 2000 and 1080p a
+	// third of the way from 2000 to 4000; each then gets the headroom.
+	fitted360 := config.Rendition{Name: "360p", Width: 640, Height: 360, BitrateKbps: 365, MaxRateKbps: 411, BufSizeKbps: 821, FPS: 30}
+	fitted720 := config.Rendition{Name: "720p", Width: 1280, Height: 720, BitrateKbps: 1826, MaxRateKbps: 1956, BufSizeKbps: 3912, FPS: 30}
+	fitted1080 := config.Rendition{Name: "1080p", Width: 1920, Height: 1080, BitrateKbps: 2898, MaxRateKbps: 3188, BufSizeKbps: 6375, FPS: 60}
+
+	tests := []struct {
+		name        string
+		rungs       []config.Rendition
+		probes      [][]domain.ComplexityProbe
+		want        []config.Rendition
+		wantDropped []string
+	}{
+		{
+			name:  "bitrates interpolated to the target",
+			rungs: rungs,
+			probes: [][]domain.ComplexityProbe{probes360, probes720,
+				probesOf("1080p", [3]float64{2000, 39, 38.2}, [3]float64{4000, 42, 40}, [3]float64{8000, 45, 41})},
+			want: []config.Rendition{fitted360, fitted720, fitted1080},
+		},
+		{
+			// At its bitrate 1080p shows about 37.66 dB full screen, under
+			// 0.5 dB better than 720p's 37.6.
+			name:  "a rung that looks no better is dropped",
+			rungs: rungs,
+			probes: [][]domain.ComplexityProbe{probes360, probes720,
+				probesOf("1080p", [3]float64{2000, 39, 37.5}, [3]float64{4000, 42, 37.8}, [3]float64{8000, 45, 38})},
+			want:        []config.Rendition{fitted360, fitted720},
+			wantDropped: []string{"1080p"},
+		},
+		{
+			name:  "a target out of reach keeps the configured bitrate",
+			rungs: []config.Rendition{rung360},
+			probes: [][]domain.ComplexityProbe{
+				probesOf("360p", [3]float64{200, 30, 28}, [3]float64{400, 32, 29}, [3]float64{800, 34, 30})},
+			want: []config.Rendition{rung360},
+		},
+		{
+			name:  "a target met by the cheapest probe takes its bitrate",
+			rungs: []config.Rendition{rung360},
+			probes: [][]domain.ComplexityProbe{
+				probesOf("360p", [3]float64{300, 41, 31}, [3]float64{600, 44, 33}, [3]float64{1200, 47, 34})},
+			want: []config.Rendition{{Name: "360p", Width: 640, Height: 360, BitrateKbps: 345, MaxRateKbps: 388, BufSizeKbps: 776, FPS: 30}},
+		},
+		{
+			// However it is ordered in the ladder, the smallest rung is the
+			// one always kept, and the one the others are compared with.
+			name:  "ladder order is kept",
+			rungs: []config.Rendition{rung720, rung360},
+			probes: [][]domain.ComplexityProbe{probes720,
+				probesOf("360p", [3]float64{200, 36, 37.5}, [3]float64{400, 42, 37.6}, [3]float64{800, 46, 37.7})},
+			want:        []config.Rendition{{Name: "360p", Width: 640, Height: 360, BitrateKbps: 365, MaxRateKbps: 411, BufSizeKbps: 821, FPS: 30}},
+			wantDropped: []string{"720p"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, dropped := chooseLadder(tt.rungs, slices.Concat(tt.probes...))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("chooseLadder() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+			if !slices.Equal(dropped, tt.wantDropped) {
+				t.Errorf("dropped = %v, want %v", dropped, tt.wantDropped)
+			}
+		})
+	}
+}
+
+func TestRateCurve(t *testing.T) {
+	curve := curveFor("720p", probesOf("720p", [3]float64{4000, 44, 39}, [3]float64{1000, 38, 35}, [3]float64{2000, 41, 38}))
+
+	if got := curve.bitrateFor(41); math.Abs(got-2000) > 1e-9 {
+		t.Errorf("bitrateFor(41) = %v, want the probe that measured it", got)
+	}
+	if got := curve.bitrateFor(39.5); math.Abs(got-1000*math.Sqrt2) > 1e-9 {
+		t.Errorf("bitrateFor(39.5) = %v, want halfway in log-bitrate, %v", got, 1000*math.Sqrt2)
+	}
+	if got := curve.bitrateFor(50); !math.IsInf(got, 1) {
+		t.Errorf("bitrateFor(50) = %v, want +Inf", got)
+	}
+	if got := curve.displayPSNRAt(500); got != 35 {
+		t.Errorf("displayPSNRAt(500) = %v, want the cheapest probe's", got)
+	}
+	if got := curve.displayPSNRAt(8000); got != 39 {
+		t.Errorf("displayPSNRAt(8000) = %v, want the dearest probe's", got)
+	}
+}
+
+func TestSampleWindows(t *testing.T) {
+	tests := []struct {
+		name       string
+		duration   float64
+		wantStarts []float64
+		wantLength float64
+	}{
+		{"long video", 60, []float64{8, 28, 48}, complexitySampleSeconds},
+		{"as long as the minimum", perTitleMinDuration, []float64{0, 3.333, 6.667}, perTitleMinDuration / complexitySamples},
+		{"shorter than three samples", 6, []float64{0, 2, 4}, 2},
+		{"a second and a half", 1.5, []float64{0, 0.5, 1}, 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			starts, length := sampleWindows(tt.duration)
+			if !slices.Equal(starts, tt.wantStarts) || length != tt.wantLength {
+				t.Errorf("sampleWindows(%v) = %v, %v; want %v, %v", tt.duration, starts, length, tt.wantStarts, tt.wantLength)
+			}
+			// The samples must fit the video.
+			if end := starts[len(starts)-1] + length; end > tt.duration+0.001 {
+				t.Errorf("last sample ends at %v, past the %v-second video", end, tt.duration)
+			}
+		})
+	}
+}
@@ -0,0 +1,752 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/config"
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+)
+
+// ProcessingStageRepository stores the stages each video is processed in.
+type ProcessingStageRepository interface {
+	// ListByVideo returns a video's stages in the order they were planned.
+	ListByVideo(ctx context.Context, videoID uuid.UUID) ([]*domain.ProcessingStage, error)
+	GetByName(ctx context.Context, videoID uuid.UUID, name string) (*domain.ProcessingStage, error)
+	// Restart deletes a video's stages and records a pending validate and
+	// probe, to process it from scratch.
+	Restart(ctx context.Context, videoID uuid.UUID) error
+	// Plan records the stages probe planned for a video, replacing any that
+	// an earlier attempt at the probe planned.
+	Plan(ctx context.Context, videoID uuid.UUID, stages []*domain.ProcessingStage) error
+	// ClaimReady marks queued, and returns, the video's pending stages whose
+	// dependencies are all done. A stage is claimed once, however many
+	// callers race for it.
+	ClaimReady(ctx context.Context, videoID uuid.UUID) ([]*domain.ProcessingStage, error)
+	// Release returns a claimed stage to pending when its task could not be
+	// queued.
+	Release(ctx context.Context, videoID uuid.UUID, name string) error
+	// Start marks a queued or running stage running and counts the attempt.
+	// A stage in any other state is returned as it is.
+	Start(ctx context.Context, videoID uuid.UUID, name string) (*domain.ProcessingStage, error)
+	UpdateProgress(ctx context.Context, videoID uuid.UUID, name string, progress float64) error
+	// Finish marks a stage done with the checkpoint it hands on.
+	Finish(ctx context.Context, videoID uuid.UUID, name string, checkpoint json.RawMessage) error
+	// Fail records why an attempt at a running stage failed. It is queued
+	// for the next attempt, or failed when final.
+	Fail(ctx context.Context, videoID uuid.UUID, name, message string, final bool) error
+	// Requeue returns a video's failed stages, and those queued or running
+	// that have not changed since staleBefore, to pending, and says how many
+	// it returned.
+	Requeue(ctx context.Context, videoID uuid.UUID, staleBefore time.Time) (int, error)
+}
+
+// ProbeResult is the probe stage's checkpoint: what the source is, the ladder
+// it gets, the packaging every stage after it writes and what is done about
+// its loudness, whatever the worker is configured with by the time they run.
+// Loudness is nil when there is no loudness stage. Revision is the output
+// revision every stage writes to; see OutputKey. Threads is each rung's share
+// of the job's encoder threads, in ladder order; see RungThreads.
+type ProbeResult struct {
+	Metadata  VideoMetadata      `json:"metadata"`
+	Rungs     []config.Rendition `json:"rungs"`
+	Threads   []int              `json:"threads,omitempty"`
+	Audio     []string           `json:"audio,omitempty"`
+	Packaging string             `json:"packaging"`
+	Loudness  *LoudnessPlan      `json:"loudness,omitempty"`
+	Revision  int                `json:"revision,omitempty"`
+}
+
+// Renditions names every rendition an encode stage writes: the rungs in
+// ladder order, then the audio tracks.
+func (p *ProbeResult) Renditions() []string {
+	names := make([]string, 0, len(p.Rungs)+len(p.Audio))
+	for _, rung := range p.Rungs {
+		names = append(names, rung.Name)
+	}
+	return append(names, p.Audio...)
+}
+
+// RungThreads is how many encoder threads the encode of the rung named name
+// may use: its share of budget, the job's threads, since the rung encodes run
+// side by side. A probe recorded before the shares were has them worked out
+// again.
+func (p *ProbeResult) RungThreads(name string, budget int) int {
+	i := slices.IndexFunc(p.Rungs, func(rung config.Rendition) bool { return rung.Name == name })
+	switch {
+	case i < 0:
+		return budget
+	case i < len(p.Threads):
+		return p.Threads[i]
+	default:
+		return threadShares(p.Rungs, budget)[i]
+	}
+}
+
+// EncodeResult is an encode stage's checkpoint. For a rung, StreamInf and
+// Version are what ffmpeg wrote for it as a master playlist of its own,
+// which the package stage builds the real one from.
+type EncodeResult struct {
+	StreamInf string `json:"stream_inf,omitempty"`
+	Version   int    `json:"version,omitempty"`
+	Segments  int    `json:"segments"`
+}
+
+// PackageResult is the package stage's checkpoint: the qualities the master
+// playlist offers and the protocols they are served over.
+type PackageResult struct {
+	Qualities []string `json:"qualities"`
+	Protocol  string   `json:"protocol"`
+}
+
+// ImagesResult is the thumbnails stage's checkpoint: the keys of the video's
+// poster and animated preview, either "" when there is none.
+type ImagesResult struct {
+	ThumbnailPath string `json:"thumbnail_path"`
+	PreviewPath   string `json:"preview_path"`
+}
+
+// planStages is the DAG the probe lays out for the rest of a video's
+// processing: an encode per rendition and the thumbnails, all from the
+// source, then package once every encode is done and publish once package
+// and the thumbnails are. A loudness stage, when planned, also runs from the
+// source; the audio encodes wait for it when they are to be leveled, and
+// publish, which the measurement goes out with, always does.
+func planStages(probe *ProbeResult) []*domain.ProcessingStage {
+	after := []string{domain.ProbeStageName}
+	audioAfter := after
+	var stages []*domain.ProcessingStage
+	publishAfter := []string{domain.PackageStageName, domain.ThumbnailsStageName}
+	if probe.Loudness != nil {
+		stages = append(stages, &domain.ProcessingStage{Name: domain.LoudnessStageName, Kind: domain.StageLoudness, DependsOn: after})
+		if probe.Loudness.Normalize {
+			audioAfter = []string{domain.ProbeStageName, domain.LoudnessStageName}
+		}
+		publishAfter = append(publishAfter, domain.LoudnessStageName)
+	}
+
+	var encodes []string
+	for _, name := range probe.Renditions() {
+		dependsOn := after
+		if slices.Contains(probe.Audio, name) {
+			dependsOn = audioAfter
+		}
+		stage := &domain.ProcessingStage{Name: domain.EncodeStageName(name), Kind: domain.StageEncode, DependsOn: dependsOn}
+		stages = append(stages, stage)
+		encodes = append(encodes, stage.Name)
+	}
+	return append(stages,
+		&domain.ProcessingStage{Name: domain.ThumbnailsStageName, Kind: domain.StageThumbnails, DependsOn: after},
+		&domain.ProcessingStage{Name: domain.PackageStageName, Kind: domain.StagePackage, DependsOn: encodes},
+		&domain.ProcessingStage{Name: domain.PublishStageName, Kind: domain.StagePublish, DependsOn: publishAfter},
+	)
+}
+
+// BeginProcessing readies video id's stages for its video:process task and
+// reports whether any are left to run. A video just uploaded starts afresh at
+// validate. A failed video, or one stuck processing, resumes where it stopped:
+// the stages that failed, and those queued or running that have not been
+// heard from since staleBefore, are run again, and everything done is kept.
+// A ready video has nothing left to run.
+//
+// With reprocess, any video is processed again from scratch, ready or not,
+// unless its stages are being run right now. A ready video stays ready
+// meanwhile, and is served as it was until the new output is published.
+func (s *TranscodingService) BeginProcessing(ctx context.Context, id uuid.UUID, staleBefore time.Time, reprocess bool) (bool, error) {
+	video, err := s.videoRepo.GetByID(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	switch {
+	case reprocess && video.Status != domain.VideoStatusUploading:
+		busy, err := s.stagesInFlight(ctx, id, staleBefore)
+		if err != nil {
+			return false, err
+		}
+		if busy {
+			s.log.Info(ctx, "video is being processed; not reprocessing it", map[string]interface{}{
+				"video_id": id,
+			})
+			return false, nil
+		}
+		if err := s.stages.Restart(ctx, id); err != nil {
+			return false, err
+		}
+		s.log.Info(ctx, "reprocessing video", map[string]interface{}{
+			"video_id": id,
+			"status":   video.Status,
+		})
+	case video.Status == domain.VideoStatusReady:
+		return false, nil
+	case video.Status == domain.VideoStatusUploading:
+		if err := s.stages.Restart(ctx, id); err != nil {
+			return false, err
+		}
+	default:
+		stages, err := s.stages.ListByVideo(ctx, id)
+		if err != nil {
+			return false, err
+		}
+		if len(stages) == 0 {
+			// Processed before it had stages, or never got as far.
+			if err := s.stages.Restart(ctx, id); err != nil {
+				return false, err
+			}
+			break
+		}
+		requeued, err := s.stages.Requeue(ctx, id, staleBefore)
+		if err != nil {
+			return false, err
+		}
+		s.log.Info(ctx, "resuming video processing", map[string]interface{}{
+			"video_id": id,
+			"stages":   len(stages),
+			"requeued": requeued,
+		})
+	}
+
+	if video.Status != domain.VideoStatusProcessing && video.Status != domain.VideoStatusReady {
+		if err := s.videoRepo.UpdateStatus(ctx, id, domain.VideoStatusProcessing); err != nil {
+			return false, fmt.Errorf("failed to update status to processing: %w", err)
+		}
+		s.announce(ctx, domain.VideoProgress{VideoID: id, Status: domain.VideoStatusProcessing})
+	}
+	return true, nil
+}
+
+// stagesInFlight reports whether any of video id's stages is queued or
+// running and has been heard from since staleBefore.
+func (s *TranscodingService) stagesInFlight(ctx context.Context, id uuid.UUID, staleBefore time.Time) (bool, error) {
+	stages, err := s.stages.ListByVideo(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	for _, stage := range stages {
+		if (stage.Status == domain.StageQueued || stage.Status == domain.StageRunning) && stage.UpdatedAt.After(staleBefore) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// FinishStage records stage done with checkpoint, which the stages after it
+// read back, and moves the video's progress on.
+func (s *TranscodingService) FinishStage(ctx context.Context, stage *domain.ProcessingStage, checkpoint any) error {
+	var data json.RawMessage
+	if checkpoint != nil {
+		var err error
+		if data, err = json.Marshal(checkpoint); err != nil {
+			return fmt.Errorf("encoding checkpoint of %s: %w", stage.Name, err)
+		}
+	}
+	if err := s.stages.Finish(ctx, stage.VideoID, stage.Name, data); err != nil {
+		return fmt.Errorf("failed to finish stage %s: %w", stage.Name, err)
+	}
+	// Publish has already announced the video ready.
+	if stage.Kind != domain.StagePublish {
+		s.pipelineProgress(ctx, stage.VideoID, nil)
+	}
+	return nil
+}
+
+// FinishProbe plans the rest of the video's stages from probe and records the
+// probe done.
+func (s *TranscodingService) FinishProbe(ctx context.Context, stage *domain.ProcessingStage, probe *ProbeResult) error {
+	if err := s.stages.Plan(ctx, stage.VideoID, planStages(probe)); err != nil {
+		return fmt.Errorf("failed to plan stages: %w", err)
+	}
+	return s.FinishStage(ctx, stage, probe)
+}
+
+// FailStage records why an attempt at stage failed. After the last attempt
+// the stage is failed, and the video with it, until an admin resumes it. A
+// video whose source was rejected is told why; see domain.SourceRejection.
+// A ready video that was being processed again stays ready, serving the
+// output it had.
+func (s *TranscodingService) FailStage(ctx context.Context, stage *domain.ProcessingStage, cause error, final bool) {
+	if err := s.stages.Fail(ctx, stage.VideoID, stage.Name, cause.Error(), final); err != nil {
+		s.log.Error(ctx, "failed to record stage failure", err, map[string]interface{}{
+			"video_id": stage.VideoID,
+			"stage":    stage.Name,
+		})
+	}
+	if !final {
+		return
+	}
+	if video, err := s.videoRepo.GetByID(ctx, stage.VideoID); err == nil && video.Status == domain.VideoStatusReady {
+		s.log.Warn(ctx, "reprocessing failed; keeping the published output", map[string]interface{}{
+			"video_id": stage.VideoID,
+			"stage":    stage.Name,
+		})
+		return
+	}
+	perr, rejected := domain.SourceRejection(cause)
+	if !rejected {
+		s.markFailed(ctx, stage.VideoID)
+		return
+	}
+	if err := s.videoRepo.MarkAsRejected(ctx, stage.VideoID, *perr); err != nil {
+		s.log.Error(ctx, "failed to mark video as rejected", err, map[string]interface{}{
+			"video_id": stage.VideoID,
+		})
+	}
+	s.announce(ctx, domain.VideoProgress{VideoID: stage.VideoID, Status: domain.VideoStatusFailed, Error: perr})
+}
+
+// ProbeResult reads back the checkpoint of video id's probe.
+func (s *TranscodingService) ProbeResult(ctx context.Context, id uuid.UUID) (*ProbeResult, error) {
+	var probe ProbeResult
+	if err := s.checkpoint(ctx, id, domain.ProbeStageName, &probe); err != nil {
+		return nil, err
+	}
+	return &probe, nil
+}
+
+// checkpoint decodes the checkpoint of video id's stage name into v. The
+// stage must be done.
+func (s *TranscodingService) checkpoint(ctx context.Context, id uuid.UUID, name string, v any) error {
+	stage, err := s.stages.GetByName(ctx, id, name)
+	if err != nil {
+		return fmt.Errorf("failed to load stage %s: %w", name, err)
+	}
+	if stage.Status != domain.StageDone {
+		return fmt.Errorf("stage %s is %s, not done", name, stage.Status)
+	}
+	if err := json.Unmarshal(stage.Checkpoint, v); err != nil {
+		return fmt.Errorf("decoding checkpoint of %s: %w", name, err)
+	}
+	return nil
+}
+
+// Probe runs the probe stage of video against its source: it measures the
+// source, records its duration and resolution, and picks the rungs to encode
+// from qualities, or the whole ladder when qualities is empty. Names the
+// ladder does not know are logged and ignored; rungs taller than the source
+// are skipped, since upscaling only spends bits. With per-title encoding on,
+// the remaining rungs are fitted to the video first; see perTitleLadder. They
+// are then copied into every other codec the worker encodes jobs of tier in;
+// see codecLadders. An HDR source gains an HDR rung when the worker keeps
+// one; see hdrRung.
+func (s *TranscodingService) Probe(ctx context.Context, video *domain.Video, sourcePath string, qualities []string, tier string) (*ProbeResult, error) {
+	id := video.ID
+	metadata, err := s.ffmpegService.ExtractMetadata(ctx, sourcePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract metadata: %w", err)
+	}
+
+	if err := s.videoRepo.UpdateDuration(ctx, id, int(metadata.Duration)); err != nil {
+		s.log.Error(ctx, "failed to update duration", err, map[string]interface{}{
+			"video_id": id,
+		})
+	}
+	if err := s.videoRepo.UpdateResolution(ctx, id, fmt.Sprintf("%dx%d", metadata.Width, metadata.Height)); err != nil {
+		s.log.Error(ctx, "failed to update resolution", err, map[string]interface{}{
+			"video_id": id,
+		})
+	}
+
+	// Processing starts from nothing: renditions an earlier run left behind
+	// would otherwise sit beside the new ones. A published video is written
+	// to a revision of its own, leaving the one being served alone.
+	revision := nextRevision(video)
+	if err := os.RemoveAll(s.hlsDir(id, revision)); err != nil {
+		return nil, fmt.Errorf("clearing HLS directory: %w", err)
+	}
+
+	rungs := s.selectRenditions(ctx, id.String(), qualities, metadata.Height)
+	if len(rungs) == 0 {
+		return nil, fmt.Errorf("no requested quality fits a %dp source", metadata.Height)
+	}
+	if s.worker.PerTitle {
+		rungs = s.perTitleLadder(ctx, id, sourcePath, metadata, rungs)
+	}
+	// The HDR rung is sized from the H.264 rungs, and listed after every
+	// codec's copy of them.
+	var hdr config.Rendition
+	hasHDR := false
+	if s.worker.HDRRendition {
+		hdr, hasHDR = hdrRung(rungs, metadata)
+	}
+	rungs = codecLadders(rungs, s.worker.CodecsFor(tier))
+	if hasHDR {
+		rungs = append(rungs, hdr)
+	}
+
+	probe := &ProbeResult{
+		Metadata:  *metadata,
+		Rungs:     rungs,
+		Threads:   threadShares(rungs, s.worker.JobThreads),
+		Audio:     trackNames(sourceAudioTracks(id, metadata.AudioStreams)),
+		Packaging: s.worker.Packaging,
+		Revision:  revision,
+	}
+	if s.worker.Loudnorm && len(probe.Audio) > 0 {
+		probe.Loudness = &LoudnessPlan{
+			Normalize: video.NormalizeAudio,
+			Target:    s.worker.LoudnessTarget,
+			TruePeak:  s.worker.LoudnessTruePeak,
+		}
+	} else if err := s.videoRepo.UpdateLoudness(ctx, id, nil); err != nil {
+		// What an earlier run measured no longer describes the renditions.
+		s.log.Error(ctx, "failed to clear loudness", err, map[string]interface{}{
+			"video_id": id,
+		})
+	}
+	s.log.Info(ctx, "probed video", map[string]interface{}{
+		"video_id":    id,
+		"renditions":  probe.Renditions(),
+		"frame_rate":  sourceFrameRate(metadata).String(),
+		"video_range": metadata.VideoRange(),
+		"bit_depth":   metadata.BitDepth,
+		"tier":        tier,
+		"revision":    revision,
+	})
+	return probe, nil
+}
+
+// EncodeRendition runs an encode stage, writing its rendition under the HLS
+// directory of the probe's output revision: a rung of the ladder, or one of
+// the source's audio streams. Every rung forces keyframes on the same
+// boundaries, so a player can switch between rungs at any segment however
+// far apart they were encoded.
+//
+// Each rung is encoded from the source by an ffmpeg run of its own, so the
+// source is decoded once per rung rather than once for the whole ladder.
+// That is the price of rungs that resume, retry and spread across workers
+// one by one. The rung encodes run side by side, each with its share of the
+// job's threads, so together they keep to WORKER_JOB_THREADS.
+func (s *TranscodingService) EncodeRendition(ctx context.Context, video *domain.Video, stage *domain.ProcessingStage, sourcePath string) (*EncodeResult, error) {
+	probe, err := s.ProbeResult(ctx, video.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	name := stage.Rendition()
+	dir := filepath.Join(s.hlsDir(video.ID, probe.Revision), name)
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, fmt.Errorf("clearing rendition directory: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create rendition directory: %w", err)
+	}
+
+	var args []string
+	rung, isRung := findRung(probe.Rungs, name)
+	switch {
+	case isRung:
+		args = rungArgs(sourcePath, dir, rung, probe.RungThreads(name, s.worker.JobThreads), probe.Packaging, &probe.Metadata)
+	case slices.Contains(probe.Audio, name):
+		index := slices.Index(probe.Audio, name)
+		filter, err := s.audioLevelFilter(ctx, video.ID, probe, index)
+		if err != nil {
+			return nil, err
+		}
+		args = audioRenditionArgs(sourcePath, fmt.Sprintf("0:a:%d", index), dir, name, probe.Packaging, 0, filter)
+	default:
+		return nil, fmt.Errorf("probe planned no rendition %s", name)
+	}
+
+	progress := &encodeProgress{started: time.Now()}
+	stop := make(chan struct{})
+	reporterDone := make(chan struct{})
+	go func() {
+		defer close(reporterDone)
+		s.reportStageProgress(ctx, stage, progress, stop)
+	}()
+
+	err = s.runFFmpeg(ctx, args, probe.Metadata.Duration, progress.set)
+	close(stop)
+	<-reporterDone
+	if err != nil {
+		return nil, err
+	}
+
+	segments, err := filepath.Glob(filepath.Join(dir, "segment_*"+segmentExtension(probe.Packaging)))
+	if err != nil || len(segments) == 0 {
+		return nil, fmt.Errorf("no segments written for %s", name)
+	}
+	result := &EncodeResult{Segments: len(segments)}
+	if isRung {
+		variant := filepath.Join(dir, variantPlaylistName)
+		if result.StreamInf, result.Version, err = readVariant(variant); err != nil {
+			return nil, err
+		}
+		if rungCodec(rung) != config.CodecH264 {
+			codec, err := s.videoCodecString(ctx, dir, rung)
+			if err != nil {
+				return nil, err
+			}
+			result.StreamInf = setAttribute(result.StreamInf, "CODECS", strconv.Quote(codec))
+		}
+		// The master playlist the package stage writes replaces it.
+		os.Remove(variant)
+	}
+
+	s.log.Info(ctx, "encoded rendition", map[string]interface{}{
+		"video_id":      video.ID,
+		"rendition":     name,
+		"segment_count": len(segments),
+	})
+	return result, nil
+}
+
+// audioLevelFilter is the filter that levels the source's audio track at
+// index, or "" when the probe planned no leveling.
+func (s *TranscodingService) audioLevelFilter(ctx context.Context, id uuid.UUID, probe *ProbeResult, index int) (string, error) {
+	if probe.Loudness == nil || !probe.Loudness.Normalize {
+		return "", nil
+	}
+	var loudness LoudnessResult
+	if err := s.checkpoint(ctx, id, domain.LoudnessStageName, &loudness); err != nil {
+		return "", err
+	}
+	if index >= len(loudness.Tracks) {
+		return "", fmt.Errorf("loudness stage measured no track %d", index)
+	}
+	return loudnormFilter(probe.Loudness, loudness.Tracks[index]), nil
+}
+
+// findRung looks name up among rungs.
+func findRung(rungs []config.Rendition, name string) (config.Rendition, bool) {
+	for _, rung := range rungs {
+		if rung.Name == name {
+			return rung, true
+		}
+	}
+	return config.Rendition{}, false
+}
+
+// RenderImages runs the thumbnails stage of video against its source: the
+// poster candidates, the animated preview and the seek-bar previews. Only
+// the poster is more than a nicety, and chooseThumbnail already settles for
+// none rather than fail, so the stage never fails.
+func (s *TranscodingService) RenderImages(ctx context.Context, video *domain.Video, sourcePath string) (*ImagesResult, error) {
+	probe, err := s.ProbeResult(ctx, video.ID)
+	if err != nil {
+		return nil, err
+	}
+	id := video.ID
+	metadata := &probe.Metadata
+
+	result := &ImagesResult{ThumbnailPath: s.chooseThumbnail(ctx, id, sourcePath, metadata)}
+
+	// A preview that fails clears any earlier one, when the video is
+	// published, rather than leave it over a video that has since been
+	// reprocessed.
+	if result.PreviewPath, err = s.generatePreview(ctx, id, sourcePath, metadata); err != nil {
+		s.log.Error(ctx, "failed to generate animated preview", err, map[string]interface{}{
+			"video_id": id,
+		})
+	}
+
+	if interval := s.worker.TrickplayInterval; interval > 0 {
+		outputDir := OutputPath(s.storage.TranscodedPath, id, probe.Revision)
+		if err := s.generateTrickplay(ctx, sourcePath, outputDir, metadata, interval); err != nil {
+			s.log.Error(ctx, "failed to generate trickplay thumbnails", err, map[string]interface{}{
+				"video_id": id,
+			})
+		}
+	}
+	return result, nil
+}
+
+// Package runs the package stage of video once every rendition is encoded
+// under its HLS directory: it writes the master playlist with its audio and
+// subtitles groups, the DASH manifest of a CMAF video, and the progressive
+// MP4s. sourcePath is only read for embedded subtitles, and may be "" when
+// the probe found none.
+func (s *TranscodingService) Package(ctx context.Context, video *domain.Video, sourcePath string) (*PackageResult, error) {
+	id := video.ID
+	probe, err := s.ProbeResult(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	variants := make(map[string]EncodeResult, len(probe.Rungs))
+	for _, rung := range probe.Rungs {
+		var variant EncodeResult
+		if err := s.checkpoint(ctx, id, domain.EncodeStageName(rung.Name), &variant); err != nil {
+			return nil, err
+		}
+		variants[rung.Name] = variant
+	}
+
+	hlsDir := s.hlsDir(id, probe.Revision)
+	audio := sourceAudioTracks(id, probe.Metadata.AudioStreams)
+	source := sourceFrameRate(&probe.Metadata)
+	if err := writeMasterPlaylist(hlsDir, probe.Rungs, variants, len(audio) > 0, source); err != nil {
+		return nil, err
+	}
+
+	// Dubs are only accepted once a video is ready, so a video being encoded
+	// for the first time has none yet, and one encoded again has its dubs
+	// published into the new output after this; see RequeueAddedTracks.
+	// Either way its source tracks are the whole audio group here.
+	if err := s.audioTracks.ReplaceSourceTracks(ctx, id, audio); err != nil {
+		return nil, fmt.Errorf("failed to record audio tracks: %w", err)
+	}
+	if len(audio) > 0 {
+		if err := writeAudioGroup(hlsDir, audio); err != nil {
+			return nil, fmt.Errorf("failed to write audio renditions to the master playlist: %w", err)
+		}
+	}
+
+	// Likewise captions are only uploaded to a ready video, so the embedded
+	// ones are the whole subtitles group here.
+	captions := s.extractCaptions(ctx, id, sourcePath, hlsDir, probe.Rungs[0].Name, probe.Metadata.SubtitleStreams)
+	if err := s.captions.ReplaceEmbeddedCaptions(ctx, id, captions); err != nil {
+		return nil, fmt.Errorf("failed to record captions: %w", err)
+	}
+	if len(captions) > 0 {
+		if err := writeSubtitlesGroup(hlsDir, captions); err != nil {
+			return nil, fmt.Errorf("failed to write captions to the master playlist: %w", err)
+		}
+	}
+
+	// A CMAF video without its DASH manifest still plays over HLS, so a
+	// manifest that could not be written costs DASH, not the job.
+	result := &PackageResult{Protocol: domain.StreamingProtocolHLS}
+	if probe.Packaging == config.PackagingCMAF {
+		if err := writeDASHManifest(hlsDir, probe.Rungs, audio, source); err != nil {
+			s.log.Error(ctx, "failed to write DASH manifest; serving HLS only", err, map[string]interface{}{
+				"video_id": id,
+			})
+		} else {
+			result.Protocol = domain.StreamingProtocolCMAF
+		}
+	}
+
+	for _, rung := range probe.Rungs {
+		result.Qualities = append(result.Qualities, rung.Name)
+	}
+
+	if s.worker.ProgressiveMP4 {
+		var soundtrack string
+		if track := defaultTrack(audio); track != nil {
+			soundtrack = track.Name
+		}
+		outputDir := filepath.Dir(hlsDir)
+		for _, quality := range result.Qualities {
+			if err := s.remuxToMP4(ctx, outputDir, quality, soundtrack, probe.Packaging); err != nil {
+				s.log.Error(ctx, "failed to write progressive MP4", err, map[string]interface{}{
+					"video_id": id,
+					"quality":  quality,
+				})
+			}
+		}
+	}
+	return result, nil
+}
+
+// Publish runs the last stage of video: once it is packaged and its images
+// rendered, it is recorded ready with what they produced, and the output
+// revision they were written to is the one served from then on.
+func (s *TranscodingService) Publish(ctx context.Context, video *domain.Video) error {
+	id := video.ID
+	probe, err := s.ProbeResult(ctx, id)
+	if err != nil {
+		return err
+	}
+	var packaged PackageResult
+	if err := s.checkpoint(ctx, id, domain.PackageStageName, &packaged); err != nil {
+		return err
+	}
+	var images ImagesResult
+	if err := s.checkpoint(ctx, id, domain.ThumbnailsStageName, &images); err != nil {
+		return err
+	}
+
+	// The storage key the master playlist was written to — where the bytes
+	// are, not how a client reaches them. This column used to hold
+	// "/uploads/processed/<id>/hls/master.m3u8", a URL under a directory
+	// nothing has ever written to (the worker writes to
+	// STORAGE_TRANSCODED_PATH), and it was served straight to clients as
+	// hls_master_path, so every API response advertised a guaranteed 404.
+	// The client-facing URL is now derived from the video ID at
+	// serialisation time; see domain.VideoHLSURL.
+	hlsMasterPath := OutputKey(id, probe.Revision, "hls", "master.m3u8")
+	if err := s.videoRepo.UpdateHLSInfo(ctx, id, hlsMasterPath, probe.Revision, true, packaged.Protocol); err != nil {
+		s.log.Error(ctx, "failed to update HLS info", err, map[string]interface{}{
+			"video_id": id,
+		})
+	}
+	if err := s.videoRepo.UpdatePreviewPath(ctx, id, images.PreviewPath); err != nil {
+		s.log.Error(ctx, "failed to record animated preview", err, map[string]interface{}{
+			"video_id": id,
+		})
+	}
+
+	if err := s.videoRepo.MarkAsReady(ctx, id, packaged.Qualities, images.ThumbnailPath); err != nil {
+		return fmt.Errorf("failed to mark video as ready: %w", err)
+	}
+	s.announce(ctx, domain.VideoProgress{VideoID: id, Status: domain.VideoStatusReady, Progress: 100})
+
+	s.log.Info(ctx, "video processing completed", map[string]interface{}{
+		"video_id":  id,
+		"qualities": packaged.Qualities,
+	})
+	return nil
+}
+
+// hlsDir is where revision of video id's HLS output is written.
+func (s *TranscodingService) hlsDir(id uuid.UUID, revision int) string {
+	return filepath.Join(OutputPath(s.storage.TranscodedPath, id, revision), "hls")
+}
+
+// reportStageProgress records an encode stage's progress, and the video's
+// across all its stages, whenever the stage's whole percentage has moved
+// since the last report, until stop is closed. The ETA is the stage's own:
+// the other rungs encode beside it or after it, so it is the soonest the
+// video can be ready.
+func (s *TranscodingService) reportStageProgress(ctx context.Context, stage *domain.ProcessingStage, progress *encodeProgress, stop <-chan struct{}) {
+	ticker := time.NewTicker(progressReportInterval)
+	defer ticker.Stop()
+
+	last := -1
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		fraction, eta := progress.estimate(time.Now())
+		percent := int(fraction * 100)
+		if percent <= last {
+			continue
+		}
+		if err := s.stages.UpdateProgress(ctx, stage.VideoID, stage.Name, fraction); err != nil {
+			s.log.Error(ctx, "failed to update stage progress", err, map[string]interface{}{
+				"video_id": stage.VideoID,
+				"stage":    stage.Name,
+			})
+			continue
+		}
+		if s.pipelineProgress(ctx, stage.VideoID, eta) {
+			last = percent
+		}
+	}
+}
+
+// pipelineProgress records how far video id is through its stages; see
+// domain.PipelineProgress. 100 is left for publish to announce.
+func (s *TranscodingService) pipelineProgress(ctx context.Context, id uuid.UUID, eta *time.Time) bool {
+	stages, err := s.stages.ListByVideo(ctx, id)
+	if err != nil {
+		s.log.Error(ctx, "failed to list processing stages", err, map[string]interface{}{
+			"video_id": id,
+		})
+		return false
+	}
+	return s.setProgress(ctx, id, int(domain.PipelineProgress(stages)*99), eta)
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/internal/repository"
+	"github.com/Nuu-maan/video-streaming-service/pkg/logger"
+)
+
+// PremiereService schedules premieres of ready videos, and keeps their
+// chapters. A premiere plays a video out to its viewers as a broadcast
+// would, from the time it was scheduled for; the streaming handler does the
+// playing out, from Video.PremiereAt alone.
+type PremiereService struct {
+	videos repository.VideoRepository
+	log    *logger.Logger
+}
+
+func NewPremiereService(videos repository.VideoRepository, log *logger.Logger) *PremiereService {
+	return &PremiereService{
+		videos: videos,
+		log:    log,
+	}
+}
+
+// Schedule schedules video's premiere at at, or moves the one it has if it
+// has not started. Only a ready video premieres, and at a time in the
+// future no further off than domain.MaxPremiereLead. Playback tokens its
+// viewers already hold keep playing it until they expire.
+func (s *PremiereService) Schedule(ctx context.Context, video *domain.Video, at time.Time) error {
+	if video.Status != domain.VideoStatusReady || !video.HLSReady {
+		return domain.ErrVideoNotReady
+	}
+	now := time.Now()
+	if state, _ := video.Premiere(now); state == domain.PremiereLive || state == domain.PremiereEnded {
+		return domain.ErrPremiereStarted
+	}
+	// Playback tokens carry the start to the second; see playback.Claims.
+	at = at.Truncate(time.Second)
+	if !at.After(now) || at.Sub(now) > domain.MaxPremiereLead {
+		return fmt.Errorf("%w: a premiere must be in the future, and no more than %d days off",
+			domain.ErrInvalidPremiere, int(domain.MaxPremiereLead.Hours()/24))
+	}
+
+	scheduled, err := s.videos.SetPremiere(ctx, video.ID, &at)
+	if err != nil {
+		return fmt.Errorf("scheduling premiere: %w", err)
+	}
+	if !scheduled {
+		return domain.ErrPremiereStarted
+	}
+	video.PremiereAt = &at
+
+	s.log.Info(ctx, "premiere scheduled", map[string]interface{}{
+		"video_id":    video.ID,
+		"premiere_at": at,
+	})
+	return nil
+}
+
+// Cancel cancels video's premiere if it has not started, leaving it to play
+// as any other. A video with none has nothing to cancel.
+func (s *PremiereService) Cancel(ctx context.Context, video *domain.Video) error {
+	switch state, _ := video.Premiere(time.Now()); state {
+	case domain.PremiereNone:
+		return nil
+	case domain.PremiereLive, domain.PremiereEnded:
+		return domain.ErrPremiereStarted
+	}
+
+	cancelled, err := s.videos.SetPremiere(ctx, video.ID, nil)
+	if err != nil {
+		return fmt.Errorf("cancelling premiere: %w", err)
+	}
+	if !cancelled {
+		return domain.ErrPremiereStarted
+	}
+	video.PremiereAt = nil
+
+	s.log.Info(ctx, "premiere cancelled", map[string]interface{}{
+		"video_id": video.ID,
+	})
+	return nil
+}
+
+// SetChapters replaces a ready video's chapters. A broadcast's are marked
+// as it goes, through its live stream; see LiveService.MarkChapter.
+func (s *PremiereService) SetChapters(ctx context.Context, video *domain.Video, chapters []domain.Chapter) error {
+	if video.Status != domain.VideoStatusReady {
+		return domain.ErrVideoNotReady
+	}
+	chapters = trimChapterTitles(chapters)
+	if err := domain.ValidateChapters(chapters, video.Duration); err != nil {
+		return err
+	}
+	if err := s.videos.UpdateChapters(ctx, video.ID, chapters); err != nil {
+		return fmt.Errorf("recording chapters: %w", err)
+	}
+	video.Chapters = chapters
+	return nil
+}
+
+// trimChapterTitles returns chapters with the space around their titles cut.
+func trimChapterTitles(chapters []domain.Chapter) []domain.Chapter {
+	trimmed := make([]domain.Chapter, len(chapters))
+	for i, chapter := range chapters {
+		trimmed[i] = domain.Chapter{Start: chapter.Start, Title: strings.TrimSpace(chapter.Title)}
+	}
+	return trimmed
+}
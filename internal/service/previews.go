@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/internal/storage"
+)
+
+// Animated preview. Up to previewClips clips of previewClipSeconds each are
+// cut around the video's highlights and stitched together, previewWidth
+// pixels wide: about six seconds, enough for a listing's hover preview.
+const (
+	previewClips       = 4
+	previewClipSeconds = 1.5
+	previewWidth       = 320
+	// previewName is the preview's file name beside the thumbnails, and so
+	// the last segment of its key.
+	previewName = "preview"
+)
+
+// generatePreview writes the animated preview of video id to
+// ThumbnailPath/<id>/ as preview.webp and preview.mp4, where they are
+// uploaded with the thumbnails, and returns its key; see PreviewKey. The
+// highlights are the thumbnail candidates, so chooseThumbnail must have run.
+func (s *TranscodingService) generatePreview(ctx context.Context, id uuid.UUID, inputPath string, metadata *VideoMetadata) (string, error) {
+	dir := filepath.Join(s.storage.ThumbnailPath, id.String())
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create preview directory: %w", err)
+	}
+
+	clips := previewClipsAt(metadata.Duration, s.previewHighlights(ctx, id))
+	args := s.optimizer.GetAnimatedThumbnailArgs(inputPath,
+		filepath.Join(dir, previewName+".webp"), filepath.Join(dir, previewName+".mp4"),
+		clips, previewWidth)
+	if _, err := s.ffmpegOutput(ctx, args); err != nil {
+		return "", err
+	}
+
+	s.log.Info(ctx, "generated animated preview", map[string]interface{}{
+		"video_id": id,
+		"clips":    len(clips),
+	})
+	return PreviewKey(id), nil
+}
+
+// previewHighlights returns the offsets of video id's best thumbnail
+// candidates: the frames judged most worth looking at are the moments most
+// worth a clip. A video without candidates has no highlights.
+func (s *TranscodingService) previewHighlights(ctx context.Context, id uuid.UUID) []float64 {
+	thumbnails, err := s.thumbnails.ListByVideo(ctx, id)
+	if err != nil {
+		s.log.Warn(ctx, "could not list thumbnail candidates for the preview", map[string]interface{}{
+			"video_id": id,
+			"error":    err.Error(),
+		})
+		return nil
+	}
+	var highlights []float64
+	for _, t := range thumbnails {
+		if t.Kind == domain.ThumbnailCandidate && len(highlights) < previewClips {
+			highlights = append(highlights, t.Offset)
+		}
+	}
+	return highlights
+}
+
+// previewClipsAt places the preview's clips of a duration-second video,
+// centred on highlights and in the order they appear. A clip overlapping the
+// one before it is dropped. Without highlights the clips are spread evenly
+// through the video, and a video too short to cut from is used whole, up to
+// the length of the preview.
+func previewClipsAt(duration float64, highlights []float64) []PreviewClip {
+	length := previewClips * previewClipSeconds
+	if duration <= 2*length {
+		if duration > 0 {
+			length = min(length, duration)
+		}
+		return []PreviewClip{{Start: 0, Duration: length}}
+	}
+
+	if len(highlights) == 0 {
+		for i := 0; i < previewClips; i++ {
+			highlights = append(highlights, duration*(0.05+0.9*(float64(i)+0.5)/previewClips))
+		}
+	}
+	highlights = append([]float64(nil), highlights...)
+	sort.Float64s(highlights)
+
+	var clips []PreviewClip
+	for _, offset := range highlights {
+		start := min(max(offset-previewClipSeconds/2, 0), duration-previewClipSeconds)
+		if n := len(clips); n > 0 && start < clips[n-1].Start+clips[n-1].Duration {
+			continue
+		}
+		clips = append(clips, PreviewClip{Start: start, Duration: previewClipSeconds})
+	}
+	return clips
+}
+
+// PreviewKey is the storage key base of video id's animated preview, beside
+// its thumbnails: <key>.webp and <key>.mp4.
+func PreviewKey(id uuid.UUID) string {
+	return storage.Key("thumbnails", id.String(), previewName)
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/config"
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/internal/repository"
+	"github.com/Nuu-maan/video-streaming-service/pkg/logger"
+)
+
+// Bounds on a reprocess request. A batch is capped so that a filter broader
+// than meant cannot queue the whole library at once, and its rate so that
+// the low queue it runs on drains between uploads rather than burying them.
+const (
+	DefaultReprocessLimit = 100
+	MaxReprocessLimit     = 10000
+	DefaultReprocessRate  = 10
+	MaxReprocessRate      = 600
+)
+
+// reprocessPageSize is how many videos are read at a time while selecting a
+// batch.
+const reprocessPageSize = 500
+
+// ReprocessBatchRepository stores reprocess batches.
+type ReprocessBatchRepository interface {
+	Create(ctx context.Context, batch *domain.ReprocessBatch) error
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.ReprocessBatch, error)
+	// Progress counts the batch's videos by how far they are; see
+	// domain.ReprocessProgress.
+	Progress(ctx context.Context, batch *domain.ReprocessBatch) (*domain.ReprocessProgress, error)
+}
+
+// ReprocessQueue queues a video to be processed again from scratch, no
+// sooner than processAt. Satisfied by *queue.QueueClient, which imports this
+// package and so cannot be named here.
+type ReprocessQueue interface {
+	EnqueueVideoReprocess(ctx context.Context, videoID string, processAt time.Time) error
+}
+
+// ReprocessService selects videos of the library and queues them to be
+// processed again, in batches whose progress can be followed. A ready video
+// keeps being served as it was until its new output is published; see
+// TranscodingService.BeginProcessing.
+type ReprocessService struct {
+	videos  repository.VideoRepository
+	batches ReprocessBatchRepository
+	queue   ReprocessQueue
+	log     *logger.Logger
+}
+
+func NewReprocessService(
+	videos repository.VideoRepository,
+	batches ReprocessBatchRepository,
+	queue ReprocessQueue,
+	log *logger.Logger,
+) *ReprocessService {
+	return &ReprocessService{
+		videos:  videos,
+		batches: batches,
+		queue:   queue,
+		log:     log,
+	}
+}
+
+// ReprocessRequest asks for the videos Filter matches to be processed again,
+// at most Limit of them, newest first, started no faster than RatePerMinute.
+// A zero Limit or RatePerMinute takes the default. A dry run only selects.
+type ReprocessRequest struct {
+	Filter        domain.ReprocessFilter
+	Limit         int
+	RatePerMinute int
+	DryRun        bool
+	RequestedBy   *uuid.UUID
+}
+
+// ReprocessResult is what a request selected and, unless it was a dry run or
+// selected nothing, the batch it queued. More reports that the filter
+// matched videos beyond the limit, for another request to pick up once this
+// one is done.
+type ReprocessResult struct {
+	Videos []*domain.Video
+	More   bool
+	Batch  *domain.ReprocessBatch
+}
+
+// Reprocess selects the videos req asks for and, unless it is a dry run,
+// records them as a batch and queues each at its place in the batch's
+// schedule. The batch is recorded first, so that its progress counts from
+// before any of its videos could be published. Should queueing fail part way
+// the batch is returned with the error: the videos before the failure are
+// queued, and the rest are left for the same filter to select again.
+func (s *ReprocessService) Reprocess(ctx context.Context, req ReprocessRequest) (*ReprocessResult, error) {
+	if req.Limit == 0 {
+		req.Limit = DefaultReprocessLimit
+	}
+	if req.RatePerMinute == 0 {
+		req.RatePerMinute = DefaultReprocessRate
+	}
+	if req.Limit < 0 || req.Limit > MaxReprocessLimit {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", domain.ErrInvalidReprocessFilter, MaxReprocessLimit)
+	}
+	if req.RatePerMinute < 0 || req.RatePerMinute > MaxReprocessRate {
+		return nil, fmt.Errorf("%w: rate must be between 1 and %d a minute", domain.ErrInvalidReprocessFilter, MaxReprocessRate)
+	}
+	if err := req.Filter.Validate(); err != nil {
+		return nil, err
+	}
+	if q := req.Filter.MissingQuality; q != "" && !config.ValidRenditionName(q) {
+		return nil, fmt.Errorf("%w: %q is not a rendition name", domain.ErrInvalidReprocessFilter, q)
+	}
+
+	videos, more, err := s.selectVideos(ctx, req.Filter, req.Limit)
+	if err != nil {
+		return nil, err
+	}
+	result := &ReprocessResult{Videos: videos, More: more}
+	if req.DryRun || len(videos) == 0 {
+		return result, nil
+	}
+
+	batch := &domain.ReprocessBatch{
+		ID:            uuid.New(),
+		Filter:        req.Filter,
+		RatePerMinute: req.RatePerMinute,
+		RequestedBy:   req.RequestedBy,
+		CreatedAt:     time.Now().UTC(),
+	}
+	for _, video := range videos {
+		batch.VideoIDs = append(batch.VideoIDs, video.ID)
+	}
+	if err := s.batches.Create(ctx, batch); err != nil {
+		return nil, fmt.Errorf("recording reprocess batch: %w", err)
+	}
+	result.Batch = batch
+
+	for i, video := range videos {
+		if err := s.queue.EnqueueVideoReprocess(ctx, video.ID.String(), batch.Schedule(i)); err != nil {
+			return result, fmt.Errorf("queued %d of %d videos of reprocess batch %s: %w", i, len(videos), batch.ID, err)
+		}
+	}
+
+	s.log.Info(ctx, "reprocess batch queued", map[string]interface{}{
+		"batch_id":        batch.ID,
+		"videos":          batch.Total(),
+		"rate_per_minute": batch.RatePerMinute,
+		"finishes_by":     batch.Schedule(batch.Total() - 1),
+	})
+	return result, nil
+}
+
+// selectVideos returns up to limit videos filter matches, newest first, and
+// whether there were more. Videos still uploading, and broadcasts still on
+// air, are passed over: neither has a source to re-encode yet.
+func (s *ReprocessService) selectVideos(ctx context.Context, filter domain.ReprocessFilter, limit int) ([]*domain.Video, bool, error) {
+	query := repository.VideoFilter{
+		Status:         filter.Status,
+		CreatedAfter:   filter.CreatedAfter,
+		CreatedBefore:  filter.CreatedBefore,
+		MissingQuality: filter.MissingQuality,
+		MissingHLS:     filter.MissingHLS,
+	}
+
+	var selected []*domain.Video
+	for offset := 0; ; offset += reprocessPageSize {
+		page, err := s.videos.List(ctx, query, repository.Page{Limit: reprocessPageSize, Offset: offset})
+		if err != nil {
+			return nil, false, fmt.Errorf("selecting videos to reprocess: %w", err)
+		}
+		for _, video := range page {
+			if video.Status == domain.VideoStatusUploading || video.Status == domain.VideoStatusLive {
+				continue
+			}
+			if len(selected) == limit {
+				return selected, true, nil
+			}
+			selected = append(selected, video)
+		}
+		if len(page) < reprocessPageSize {
+			return selected, false, nil
+		}
+	}
+}
+
+// Progress returns batch id and how far its videos are.
+func (s *ReprocessService) Progress(ctx context.Context, id uuid.UUID) (*domain.ReprocessBatch, *domain.ReprocessProgress, error) {
+	batch, err := s.batches.GetByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	progress, err := s.batches.Progress(ctx, batch)
+	if err != nil {
+		return nil, nil, err
+	}
+	return batch, progress, nil
+}
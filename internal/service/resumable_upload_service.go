@@ -0,0 +1,445 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/config"
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/internal/storage"
+	"github.com/Nuu-maan/video-streaming-service/pkg/logger"
+	"github.com/Nuu-maan/video-streaming-service/pkg/validator"
+)
+
+const uploadLockKeyPrefix = "upload:lock:"
+
+// releaseUploadLockScript deletes the lock only if it still holds the caller's
+// token. A plain DEL would release a lock that had already expired and been
+// taken by the next request, letting two writers into one session.
+var releaseUploadLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// UploadSessionRepository is the slice of session storage the resumable upload
+// service uses.
+type UploadSessionRepository interface {
+	Create(ctx context.Context, session *domain.UploadSession) error
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.UploadSession, error)
+	AdvanceOffset(ctx context.Context, id uuid.UUID, from, to int64) error
+	MarkCompleted(ctx context.Context, id, videoID uuid.UUID) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	ListExpired(ctx context.Context, cutoff time.Time, limit int) ([]*domain.UploadSession, error)
+}
+
+// ResumableUploadService implements the storage side of resumable uploads.
+//
+// Each chunk is saved as its own object under raw/uploads/<session>/ and only
+// then counted: the session's offset advances in Postgres after the chunk is
+// safely stored, so a connection that drops mid-chunk leaves the offset where
+// it was and the client resumes from there. Once the offset reaches the
+// declared length the parts are stitched into a single raw file and handed to
+// UploadService, which records the video exactly as a single-request upload
+// would.
+//
+// Chunk writes to one session are serialized with a short-lived Redis lock, so
+// a client retrying a chunk it believes failed cannot race the original.
+type ResumableUploadService struct {
+	sessions   UploadSessionRepository
+	uploads    *UploadService
+	store      storage.Store
+	redis      *redis.Client
+	storageCfg *config.StorageConfig
+	log        *logger.Logger
+}
+
+func NewResumableUploadService(
+	sessions UploadSessionRepository,
+	uploads *UploadService,
+	store storage.Store,
+	redisClient *redis.Client,
+	storageCfg *config.StorageConfig,
+	log *logger.Logger,
+) *ResumableUploadService {
+	return &ResumableUploadService{
+		sessions:   sessions,
+		uploads:    uploads,
+		store:      store,
+		redis:      redisClient,
+		storageCfg: storageCfg,
+		log:        log,
+	}
+}
+
+// CreateUploadSessionRequest describes a resumable upload before any of its
+// bytes have been sent.
+type CreateUploadSessionRequest struct {
+	OwnerID     uuid.UUID
+	Filename    string
+	MimeType    string
+	Title       string
+	Description string
+	Visibility  domain.VideoVisibility
+	// NormalizeAudio is as UploadRequest.NormalizeAudio.
+	NormalizeAudio bool
+	Length         int64
+}
+
+// CreateSession validates everything that can be known up front — the video
+// details, the declared length, and the filename's extension — so a doomed
+// upload is refused before the client spends bandwidth on it.
+func (s *ResumableUploadService) CreateSession(ctx context.Context, req CreateUploadSessionRequest) (*domain.UploadSession, error) {
+	details, err := validateDetails(req.Title, req.Description, req.Visibility, req.NormalizeAudio)
+	if err != nil {
+		return nil, err
+	}
+	if err := validator.ValidateVideoUpload(req.Filename, req.Length, s.storageCfg.MaxFileSize); err != nil {
+		return nil, err
+	}
+
+	session, err := domain.NewUploadSession(
+		req.OwnerID, req.Filename, mimeTypeFor(req.MimeType, req.Filename),
+		details.title, details.description, details.visibility,
+		req.Length, s.storageCfg.UploadSessionTTL,
+	)
+	if err != nil {
+		return nil, err
+	}
+	session.NormalizeAudio = details.normalizeAudio
+	if err := s.sessions.Create(ctx, session); err != nil {
+		return nil, err
+	}
+
+	s.log.Info(ctx, "upload session created", map[string]interface{}{
+		"upload_id": session.ID,
+		"length":    session.Length,
+	})
+	return session, nil
+}
+
+// GetSession returns ownerID's session. Another user's session reports
+// ErrUploadSessionNotFound rather than a permission error, so session IDs
+// cannot be probed.
+func (s *ResumableUploadService) GetSession(ctx context.Context, id, ownerID uuid.UUID) (*domain.UploadSession, error) {
+	return ownedSession(ctx, s.sessions, id, ownerID)
+}
+
+// ownedSession loads a live session of ownerID's; see GetSession.
+func ownedSession(ctx context.Context, sessions UploadSessionRepository, id, ownerID uuid.UUID) (*domain.UploadSession, error) {
+	session, err := sessions.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if session.UserID != ownerID {
+		return nil, domain.ErrUploadSessionNotFound
+	}
+	if session.IsExpired(time.Now()) {
+		return nil, domain.ErrUploadSessionExpired
+	}
+	return session, nil
+}
+
+// WriteChunk stores size bytes from body as the part starting at offset and
+// advances the session. size must be known: the client sends Content-Length
+// with every chunk, and a chunk that runs past the declared upload length is
+// refused before anything is read.
+//
+// When the chunk completes the upload, the file is assembled and recorded and
+// the new video is returned alongside the session; otherwise the video is nil.
+// A request at the final offset of a session whose assembly failed earlier
+// retries the assembly, so a client that saw an error can simply resend.
+func (s *ResumableUploadService) WriteChunk(ctx context.Context, id, ownerID uuid.UUID, offset, size int64, body io.Reader) (*domain.UploadSession, *domain.Video, error) {
+	unlock, err := s.lock(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
+	session, err := s.GetSession(ctx, id, ownerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if session.IsDirect() {
+		return nil, nil, fmt.Errorf("%w: parts of a direct upload go to storage, not here", domain.ErrUploadMethodMismatch)
+	}
+	if offset != session.Offset {
+		return nil, nil, fmt.Errorf("%w: chunk starts at %d, session is at %d", domain.ErrUploadOffsetMismatch, offset, session.Offset)
+	}
+	if session.IsComplete() {
+		// The final chunk was already committed and recorded; the client
+		// just never saw the answer.
+		return session, nil, nil
+	}
+	if size > session.Remaining() {
+		return nil, nil, fmt.Errorf("%w: %d bytes at offset %d, upload is %d bytes", domain.ErrUploadLengthExceeded, size, offset, session.Length)
+	}
+
+	if size > 0 {
+		if err := s.storePart(ctx, session, size, body); err != nil {
+			return nil, nil, err
+		}
+		if err := s.sessions.AdvanceOffset(ctx, session.ID, offset, offset+size); err != nil {
+			return nil, nil, err
+		}
+		session.Parts = append(session.Parts, offset)
+		session.Offset += size
+	}
+
+	if session.Offset < session.Length {
+		return session, nil, nil
+	}
+
+	video, err := s.complete(ctx, session)
+	if err != nil {
+		return nil, nil, err
+	}
+	return session, video, nil
+}
+
+// storePart saves one chunk. The first chunk is sniffed before it is stored so
+// a file that is not a video is refused at the first request, not after the
+// whole thing has been sent; complete sniffs again on the assembled file.
+func (s *ResumableUploadService) storePart(ctx context.Context, session *domain.UploadSession, size int64, body io.Reader) error {
+	body = io.LimitReader(body, size)
+	if session.Offset == 0 {
+		buffered := bufio.NewReaderSize(body, 512)
+		head, _ := buffered.Peek(512)
+		if len(head) >= 12 {
+			if err := validator.ValidateVideoHeader(head); err != nil {
+				return err
+			}
+		}
+		body = buffered
+	}
+
+	key := uploadPartKey(session.ID, session.Offset)
+	if err := s.store.Save(ctx, key, body, size, "application/octet-stream"); err != nil {
+		return fmt.Errorf("storing upload chunk: %w", err)
+	}
+	return nil
+}
+
+// complete stitches the parts into one raw file and records the video. On
+// failure the video and the assembled file are removed but the parts are
+// kept, so the completion can be retried.
+func (s *ResumableUploadService) complete(ctx context.Context, session *domain.UploadSession) (video *domain.Video, err error) {
+	details := videoDetails{
+		title:          session.Title,
+		description:    session.Description,
+		visibility:     session.Visibility,
+		normalizeAudio: session.NormalizeAudio,
+	}
+
+	parts := &partReader{ctx: ctx, store: s.store, session: session}
+	defer parts.Close()
+
+	assembled := bufio.NewReaderSize(parts, 512)
+	head, err := assembled.Peek(512)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("reading assembled upload: %w", err)
+	}
+	container, err := validator.SniffVideoHeader(head)
+	if err != nil {
+		return nil, err
+	}
+
+	key, filePath := s.uploads.newRawLocation(session.Filename)
+	if err := s.store.Save(ctx, key, assembled, session.Length, container.MIMEType); err != nil {
+		return nil, fmt.Errorf("assembling upload: %w", err)
+	}
+	recorded := false
+	defer func() {
+		if err != nil && !recorded {
+			s.uploads.discardRaw(ctx, key)
+		}
+	}()
+
+	video, err = s.uploads.recordVideo(ctx, details, session.UserID, filePath, container.MIMEType, session.Length)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.sessions.MarkCompleted(ctx, session.ID, video.ID); err != nil {
+		recorded = !s.uploads.unrecordVideo(ctx, video)
+		return nil, err
+	}
+
+	now := time.Now()
+	session.VideoID = &video.ID
+	session.CompletedAt = &now
+
+	s.removeParts(ctx, session.ID)
+	return video, nil
+}
+
+// Terminate abandons ownerID's upload, deleting its session and any chunks
+// already stored, whichever way they were sent. A completed upload's video is
+// unaffected.
+func (s *ResumableUploadService) Terminate(ctx context.Context, id, ownerID uuid.UUID) error {
+	unlock, err := s.lock(ctx, id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	session, err := s.sessions.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if session.UserID != ownerID {
+		return domain.ErrUploadSessionNotFound
+	}
+
+	if err := s.sessions.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.discardParts(ctx, session)
+	return nil
+}
+
+// PurgeExpired deletes sessions whose deadline has passed, together with any
+// chunks they left in storage, and reports how many it removed. Finished
+// sessions expire too; by then their parts are already gone.
+func (s *ResumableUploadService) PurgeExpired(ctx context.Context) (int, error) {
+	const batch = 100
+
+	purged := 0
+	for {
+		expired, err := s.sessions.ListExpired(ctx, time.Now(), batch)
+		if err != nil {
+			return purged, err
+		}
+		for _, session := range expired {
+			if err := s.sessions.Delete(ctx, session.ID); err != nil {
+				return purged, err
+			}
+			s.discardParts(ctx, session)
+			purged++
+		}
+		if len(expired) < batch {
+			return purged, nil
+		}
+	}
+}
+
+// discardParts frees whatever storage holds for an abandoned session: the
+// chunks of a tus upload, or the backend's multipart upload of a direct one.
+func (s *ResumableUploadService) discardParts(ctx context.Context, session *domain.UploadSession) {
+	if session.IsDirect() {
+		abortDirectParts(ctx, s.store, s.log, session)
+		return
+	}
+	s.removeParts(ctx, session.ID)
+}
+
+// removeParts deletes a session's stored chunks. It is best-effort: the
+// session row is already settled, and a leftover part is only wasted space.
+func (s *ResumableUploadService) removeParts(ctx context.Context, id uuid.UUID) {
+	prefix := uploadPartsPrefix(id)
+	if err := s.store.DeletePrefix(ctx, prefix); err != nil {
+		s.log.Error(ctx, "failed to remove upload chunks", err, map[string]interface{}{
+			"upload_id": id,
+			"prefix":    prefix,
+		})
+	}
+}
+
+// lock takes the session's write lock for the length of one chunk request.
+// The TTL matches the chunk timeout, so a crashed holder releases it no later
+// than its request would have been cut off anyway.
+func (s *ResumableUploadService) lock(ctx context.Context, id uuid.UUID) (unlock func(), err error) {
+	return lockUploadSession(ctx, s.redis, s.storageCfg.UploadChunkTimeout, s.log, id)
+}
+
+// lockUploadSession takes a session's write lock for ttl. Every request that
+// changes a session holds it, whichever service serves the request.
+func lockUploadSession(ctx context.Context, rdb *redis.Client, ttl time.Duration, log *logger.Logger, id uuid.UUID) (unlock func(), err error) {
+	key := uploadLockKeyPrefix + id.String()
+	token := uuid.NewString()
+
+	acquired, err := rdb.SetNX(ctx, key, token, ttl).Result()
+	if err != nil {
+		return nil, fmt.Errorf("locking upload session: %w", err)
+	}
+	if !acquired {
+		return nil, domain.ErrUploadSessionLocked
+	}
+
+	return func() {
+		// The request context may already be cancelled by a disconnect; the
+		// lock must be released regardless.
+		releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if err := releaseUploadLockScript.Run(releaseCtx, rdb, []string{key}, token).Err(); err != nil {
+			log.Warn(ctx, "failed to release upload session lock", map[string]interface{}{
+				"upload_id": id,
+				"error":     err.Error(),
+			})
+		}
+	}, nil
+}
+
+func uploadPartsPrefix(id uuid.UUID) string {
+	return storage.Key("raw", "uploads", id.String())
+}
+
+// uploadPartKey names the part starting at offset. The offset is zero-padded
+// so a listing of the prefix sorts in upload order, which makes a session's
+// parts easy to inspect by hand.
+func uploadPartKey(id uuid.UUID, offset int64) string {
+	return storage.Key("raw", "uploads", id.String(), fmt.Sprintf("%020d", offset))
+}
+
+// partReader reads a session's parts back to back as one stream, opening each
+// only when the previous one is exhausted.
+type partReader struct {
+	ctx     context.Context
+	store   storage.Store
+	session *domain.UploadSession
+	next    int
+	current io.ReadCloser
+}
+
+func (r *partReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if r.next >= len(r.session.Parts) {
+				return 0, io.EOF
+			}
+			key := uploadPartKey(r.session.ID, r.session.Parts[r.next])
+			part, err := r.store.Open(r.ctx, key)
+			if err != nil {
+				return 0, fmt.Errorf("opening upload chunk %s: %w", key, err)
+			}
+			r.current = part
+			r.next++
+		}
+
+		n, err := r.current.Read(p)
+		if errors.Is(err, io.EOF) {
+			r.current.Close()
+			r.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (r *partReader) Close() error {
+	if r.current == nil {
+		return nil
+	}
+	err := r.current.Close()
+	r.current = nil
+	return err
+}
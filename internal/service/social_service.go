@@ -26,6 +26,10 @@ type SocialRepository interface {
 	CountComments(ctx context.Context, videoID uuid.UUID) (int, error)
 	ListReplies(ctx context.Context, parentID uuid.UUID, page repository.Page) ([]*domain.Comment, error)
 	CountReplies(ctx context.Context, parentID uuid.UUID) (int, error)
+	// ListChat returns the comments posted during a video's premiere, from
+	// seconds into it on, in the order they were posted.
+	ListChat(ctx context.Context, videoID uuid.UUID, from float64, page repository.Page) ([]*domain.Comment, error)
+	CountChat(ctx context.Context, videoID uuid.UUID, from float64) (int, error)
 	UpdateCommentContent(ctx context.Context, id uuid.UUID, content string) error
 	SoftDeleteComment(ctx context.Context, id uuid.UUID) error
 
@@ -140,6 +144,25 @@ func (s *SocialService) ListComments(ctx context.Context, videoID uuid.UUID, pag
 	return comments, total, nil
 }
 
+// ListChat returns a video's premiere chat from seconds into the
+// premiere on: the comments posted while it was being played out, as they
+// came, for a player to show beside the video as it reaches them.
+func (s *SocialService) ListChat(ctx context.Context, videoID uuid.UUID, from float64, page repository.Page) ([]*domain.Comment, int, error) {
+	if _, err := s.videos.GetByID(ctx, videoID); err != nil {
+		return nil, 0, err
+	}
+
+	comments, err := s.repo.ListChat(ctx, videoID, from, page)
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := s.repo.CountChat(ctx, videoID, from)
+	if err != nil {
+		return nil, 0, err
+	}
+	return comments, total, nil
+}
+
 func (s *SocialService) ListReplies(ctx context.Context, parentID uuid.UUID, page repository.Page) ([]*domain.Comment, int, error) {
 	parent, err := s.repo.GetCommentByID(ctx, parentID)
 	if err != nil {
@@ -190,6 +213,10 @@ func (s *SocialService) CreateComment(ctx context.Context, userID, videoID uuid.
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
+	// A comment on a video being premiered is part of the premiere's chat.
+	if offset, ok := video.PremiereOffset(now); ok {
+		comment.EventOffset = &offset
+	}
 	if err := comment.Validate(); err != nil {
 		return nil, err
 	}
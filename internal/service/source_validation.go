@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/pkg/validator"
+)
+
+// decodeSampleFrames is how many frames of the video stream the validate
+// stage decodes at each point it samples: enough to cross a keyframe
+// interval or two, which is where a damaged stream usually gives out.
+const decodeSampleFrames = 120
+
+// strippedMetadataArgs keep an encode from copying the source's global
+// metadata and chapters into what it writes. Uploads carry whatever the
+// camera or editor put there, down to where the video was shot, and none of
+// it is ours to publish.
+var strippedMetadataArgs = []string{"-map_metadata", "-1", "-map_chapters", "-1"}
+
+// ValidateSource runs the validate stage of video against its source at
+// path, before anything is spent encoding it. The source must be a container
+// its first bytes identify, that ffprobe reads with the demuxer those bytes
+// promise and nothing else, holding a video stream in a codec, size, frame
+// rate and length the worker accepts, which decodes cleanly at the start and
+// the middle.
+//
+// A source that falls short is rejected with one of the domain.ErrSource*
+// errors, wrapped with what exactly was wrong with it; see
+// domain.SourceRejection. Any other error is the worker's own, and worth a
+// retry.
+func (s *TranscodingService) ValidateSource(ctx context.Context, video *domain.Video, path string) error {
+	container, err := s.sniffSource(path)
+	if err != nil {
+		return err
+	}
+
+	metadata, err := s.ffmpegService.ExtractMetadata(ctx, path)
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return fmt.Errorf("%w: ffprobe could not read it", domain.ErrSourceCorrupt)
+		}
+		return err
+	}
+	if metadata.Format != container.Demuxer {
+		return fmt.Errorf("%w: it begins as %s but reads as %s", domain.ErrSourceContainerMismatch, container.MIMEType, metadata.Format)
+	}
+	if err := s.checkSourceLimits(metadata); err != nil {
+		return err
+	}
+
+	// The middle as well as the start: a file cut short, or spliced onto
+	// another, decodes fine for its first few seconds.
+	for _, at := range []float64{0, metadata.Duration / 2} {
+		if err := s.decodeSample(ctx, path, container.Demuxer, at); err != nil {
+			return err
+		}
+	}
+
+	s.log.Info(ctx, "validated source", map[string]interface{}{
+		"video_id":   video.ID,
+		"container":  container.MIMEType,
+		"codec":      metadata.VideoCodec,
+		"resolution": fmt.Sprintf("%dx%d", metadata.Width, metadata.Height),
+		"duration":   metadata.Duration,
+	})
+	return nil
+}
+
+// sniffSource identifies the container the source at path is, from its first
+// bytes, and for MP4 and QuickTime checks that its boxes account for the
+// whole file.
+func (s *TranscodingService) sniffSource(path string) (validator.VideoContainer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return validator.VideoContainer{}, fmt.Errorf("opening source: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return validator.VideoContainer{}, fmt.Errorf("reading source size: %w", err)
+	}
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return validator.VideoContainer{}, fmt.Errorf("reading source: %w", err)
+	}
+
+	container, ok := validator.DetectVideoContainer(head[:n])
+	if !ok {
+		return validator.VideoContainer{}, domain.ErrSourceUnrecognized
+	}
+	if container.IsISOBMFF() {
+		err := validator.ValidateISOBMFFBoxes(f, info.Size())
+		switch {
+		case errors.Is(err, validator.ErrCorruptVideo):
+			return validator.VideoContainer{}, fmt.Errorf("%w: %w", domain.ErrSourceCorrupt, err)
+		case errors.Is(err, validator.ErrInvalidFormat):
+			return validator.VideoContainer{}, fmt.Errorf("%w: %w", domain.ErrSourceContainerMismatch, err)
+		case err != nil:
+			return validator.VideoContainer{}, err
+		}
+	}
+	return container, nil
+}
+
+// checkSourceLimits holds the probed source to the worker's source limits.
+// The frame size is checked in either orientation, so a portrait video is
+// held to the same limits as a landscape one.
+func (s *TranscodingService) checkSourceLimits(metadata *VideoMetadata) error {
+	limits := s.worker
+	if len(limits.SourceVideoCodecs) > 0 && !slices.Contains(limits.SourceVideoCodecs, metadata.VideoCodec) {
+		return fmt.Errorf("%w: %s", domain.ErrSourceCodecUnsupported, metadata.VideoCodec)
+	}
+
+	if metadata.Width <= 0 || metadata.Height <= 0 {
+		return fmt.Errorf("%w: its video has no frame size", domain.ErrSourceCorrupt)
+	}
+	if limits.SourceMaxWidth > 0 && limits.SourceMaxHeight > 0 {
+		long, short := max(metadata.Width, metadata.Height), min(metadata.Width, metadata.Height)
+		if long > max(limits.SourceMaxWidth, limits.SourceMaxHeight) || short > min(limits.SourceMaxWidth, limits.SourceMaxHeight) {
+			return fmt.Errorf("%w: %dx%d exceeds %dx%d", domain.ErrSourceResolutionTooHigh,
+				metadata.Width, metadata.Height, limits.SourceMaxWidth, limits.SourceMaxHeight)
+		}
+	}
+
+	// The average rate, where the container gives one: a variable frame
+	// rate video's r_frame_rate is the finest its timestamps step in, often
+	// far above the frames it actually shows.
+	rate := metadata.AvgFrameRate
+	if rate <= 0 {
+		rate = metadata.FrameRate
+	}
+	// A hair over, for 119.88 and the like measured a little high.
+	if limits.SourceMaxFrameRate > 0 && rate > float64(limits.SourceMaxFrameRate)+0.01 {
+		return fmt.Errorf("%w: %s fps exceeds %d", domain.ErrSourceFrameRateTooHigh,
+			strconv.FormatFloat(rate, 'f', -1, 64), limits.SourceMaxFrameRate)
+	}
+
+	if metadata.Duration <= 0 {
+		return fmt.Errorf("%w: it reports no duration", domain.ErrSourceCorrupt)
+	}
+	duration := time.Duration(metadata.Duration * float64(time.Second))
+	if limits.SourceMaxDuration > 0 && duration > limits.SourceMaxDuration {
+		return fmt.Errorf("%w: %s exceeds %s", domain.ErrSourceTooLong,
+			duration.Truncate(time.Second), limits.SourceMaxDuration)
+	}
+	return nil
+}
+
+// decodeSample decodes decodeSampleFrames frames of the source's video from
+// at seconds in, reading it with demuxer alone and from the local file
+// alone, and rejects the source as corrupt if ffmpeg meets an error doing
+// so.
+func (s *TranscodingService) decodeSample(ctx context.Context, path, demuxer string, at float64) error {
+	args := []string{
+		"-v", "error",
+		"-xerror",
+		"-protocol_whitelist", "file",
+		"-format_whitelist", demuxer,
+		"-ss", strconv.FormatFloat(at, 'f', 3, 64),
+		"-i", path,
+		"-map", "0:V:0",
+		"-frames:v", strconv.Itoa(decodeSampleFrames),
+		"-f", "null", "-",
+	}
+	if _, err := s.ffmpegOutput(ctx, args); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return fmt.Errorf("%w: decoding failed %s into it", domain.ErrSourceCorrupt, formatOffset(at))
+		}
+		return err
+	}
+	return nil
+}
+
+// formatOffset writes seconds into a video the way a person would read it.
+func formatOffset(seconds float64) string {
+	return time.Duration(seconds * float64(time.Second)).Truncate(time.Second).String()
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/jpeg" // registers the decoder DecodeConfig needs
+	_ "image/png"
+	"io"
+	"mime/multipart"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/internal/storage"
+	"github.com/Nuu-maan/video-streaming-service/pkg/logger"
+	"github.com/Nuu-maan/video-streaming-service/pkg/validator"
+)
+
+const (
+	// maxCustomThumbnails bounds how many images an owner may upload for one
+	// video.
+	maxCustomThumbnails = 10
+	// maxThumbnailFileSize bounds an uploaded image.
+	maxThumbnailFileSize = 10 << 20
+	// An uploaded image must be at least as large as the smallest rendered
+	// size, and small enough that rendering it cannot exhaust the worker.
+	minThumbnailWidth     = 320
+	minThumbnailHeight    = 180
+	maxThumbnailDimension = 8192
+)
+
+// ThumbnailRepository stores the poster images of videos.
+type ThumbnailRepository interface {
+	Create(ctx context.Context, thumbnail *domain.Thumbnail) error
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.Thumbnail, error)
+	// ListByVideo returns a video's thumbnails, candidates best first, then
+	// uploads in the order they were added.
+	ListByVideo(ctx context.Context, videoID uuid.UUID) ([]*domain.Thumbnail, error)
+	// ReplaceCandidates swaps a video's candidates for candidates, leaving
+	// uploads alone. A selected candidate takes the selection from them.
+	ReplaceCandidates(ctx context.Context, videoID uuid.UUID, candidates []*domain.Thumbnail) error
+	// Select makes a ready thumbnail the only selected one of its video and
+	// sets the video's thumbnail path to its key.
+	Select(ctx context.Context, thumbnail *domain.Thumbnail) error
+	UpdateStatus(ctx context.Context, id uuid.UUID, status domain.ThumbnailStatus) error
+}
+
+// ThumbnailService lets a video's owner choose its poster: one of the
+// candidate frames the worker picked, or an image of their own. An upload is
+// stored under raw/thumbnails/<video>/ and recorded pending; rendering it is
+// the worker's job, and a rendered upload is selected.
+type ThumbnailService struct {
+	thumbnails ThumbnailRepository
+	store      storage.Store
+	log        *logger.Logger
+}
+
+func NewThumbnailService(thumbnails ThumbnailRepository, store storage.Store, log *logger.Logger) *ThumbnailService {
+	return &ThumbnailService{
+		thumbnails: thumbnails,
+		store:      store,
+		log:        log,
+	}
+}
+
+// ListThumbnails returns video's thumbnails, each ready one with the URL its
+// owner can preview it at.
+func (s *ThumbnailService) ListThumbnails(ctx context.Context, video *domain.Video) ([]*domain.Thumbnail, error) {
+	thumbnails, err := s.thumbnails.ListByVideo(ctx, video.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range thumbnails {
+		if t.Status == domain.ThumbnailReady {
+			t.URL = domain.ThumbnailPreviewURL(video.ID, t.ID)
+		}
+	}
+	return thumbnails, nil
+}
+
+// GetThumbnail returns thumbnail id of video.
+func (s *ThumbnailService) GetThumbnail(ctx context.Context, video *domain.Video, id uuid.UUID) (*domain.Thumbnail, error) {
+	thumbnail, err := s.thumbnails.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if thumbnail.VideoID != video.ID {
+		return nil, domain.ErrThumbnailNotFound
+	}
+	return thumbnail, nil
+}
+
+// SelectThumbnail makes thumbnail id video's poster. The caller has
+// established that it may modify video.
+func (s *ThumbnailService) SelectThumbnail(ctx context.Context, video *domain.Video, id uuid.UUID) (*domain.Thumbnail, error) {
+	thumbnail, err := s.GetThumbnail(ctx, video, id)
+	if err != nil {
+		return nil, err
+	}
+	if thumbnail.Status != domain.ThumbnailReady {
+		return nil, domain.ErrThumbnailNotReady
+	}
+	if err := s.thumbnails.Select(ctx, thumbnail); err != nil {
+		return nil, err
+	}
+	thumbnail.Selected = true
+	thumbnail.URL = domain.ThumbnailPreviewURL(video.ID, thumbnail.ID)
+
+	s.log.Info(ctx, "thumbnail selected", map[string]interface{}{
+		"video_id":     video.ID,
+		"thumbnail_id": thumbnail.ID,
+		"kind":         thumbnail.Kind,
+	})
+	return thumbnail, nil
+}
+
+// AddCustomThumbnail validates a JPEG or PNG image for video, stores it, and
+// records the thumbnail pending. The caller has established that it may
+// modify video, and queues the thumbnail for rendering.
+func (s *ThumbnailService) AddCustomThumbnail(ctx context.Context, video *domain.Video, file multipart.File, header *multipart.FileHeader) (thumbnail *domain.Thumbnail, err error) {
+	if video.Status != domain.VideoStatusReady {
+		return nil, domain.ErrVideoNotReady
+	}
+	if err := validator.ValidateImageFile(file, header, maxThumbnailFileSize); err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, maxThumbnailFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading thumbnail image: %w", err)
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrInvalidThumbnail, err)
+	}
+	if config.Width < minThumbnailWidth || config.Height < minThumbnailHeight {
+		return nil, fmt.Errorf("%w: the image must be at least %dx%d", domain.ErrInvalidThumbnail, minThumbnailWidth, minThumbnailHeight)
+	}
+	if config.Width > maxThumbnailDimension || config.Height > maxThumbnailDimension {
+		return nil, fmt.Errorf("%w: the image may be at most %d pixels on a side", domain.ErrInvalidThumbnail, maxThumbnailDimension)
+	}
+
+	existing, err := s.thumbnails.ListByVideo(ctx, video.ID)
+	if err != nil {
+		return nil, err
+	}
+	custom := 0
+	for _, t := range existing {
+		if t.Kind == domain.ThumbnailCustom {
+			custom++
+		}
+	}
+	if custom >= maxCustomThumbnails {
+		return nil, fmt.Errorf("%w: a video may have at most %d uploaded thumbnails", domain.ErrInvalidInput, maxCustomThumbnails)
+	}
+
+	now := time.Now()
+	thumbnail = &domain.Thumbnail{
+		ID:        uuid.New(),
+		VideoID:   video.ID,
+		Kind:      domain.ThumbnailCustom,
+		Status:    domain.ThumbnailPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	thumbnail.Key = ThumbnailKey(video.ID, thumbnail.ID)
+
+	key := ThumbnailSourceKey(video.ID, thumbnail.ID)
+	if err := s.store.Save(ctx, key, bytes.NewReader(data), int64(len(data)), "image/"+format); err != nil {
+		return nil, fmt.Errorf("storing thumbnail image: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if err := s.store.Delete(ctx, key); err != nil {
+				s.log.Error(ctx, "failed to clean up orphaned thumbnail image", err, map[string]interface{}{
+					"key": key,
+				})
+			}
+		}
+	}()
+
+	if err := s.thumbnails.Create(ctx, thumbnail); err != nil {
+		return nil, err
+	}
+
+	s.log.Info(ctx, "custom thumbnail added", map[string]interface{}{
+		"video_id":     video.ID,
+		"thumbnail_id": thumbnail.ID,
+		"width":        config.Width,
+		"height":       config.Height,
+	})
+	return thumbnail, nil
+}
+
+// ThumbnailKey is the storage prefix a thumbnail's sizes are rendered under.
+func ThumbnailKey(videoID, thumbnailID uuid.UUID) string {
+	return storage.Key("thumbnails", videoID.String(), thumbnailID.String())
+}
+
+// ThumbnailSourceKey is the storage key of an uploaded thumbnail's original
+// image. It carries no extension: ffmpeg tells JPEG from PNG by content.
+func ThumbnailSourceKey(videoID, thumbnailID uuid.UUID) string {
+	return storage.Key("raw", "thumbnails", videoID.String(), thumbnailID.String())
+}
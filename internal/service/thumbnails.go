@@ -0,0 +1,313 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+	"github.com/Nuu-maan/video-streaming-service/pkg/framescore"
+)
+
+// Candidate frames. thumbnailSamples frames are taken evenly through the
+// middle of the video, skipping its opening and closing 5%, and scored on a
+// thumbnailScoreWidth copy; the best thumbnailCandidates are rendered.
+const (
+	thumbnailSamples    = 12
+	thumbnailCandidates = 4
+	thumbnailScoreWidth = 320
+	// thumbnailLookback is how long before a sample the frame it is
+	// compared with to tell a fresh shot from a still one is taken.
+	thumbnailLookback = 0.5
+)
+
+// thumbnailSample is one scored frame of a video.
+type thumbnailSample struct {
+	offset float64
+	score  float64
+}
+
+// chooseThumbnail makes the poster of video id. The best frames of
+// inputPath are rendered as candidates under ThumbnailPath/<id>/, replacing
+// the video's previous candidates, and the best is selected unless the owner
+// uploaded a poster of their own, which is kept. It returns the key of the
+// video's poster, or "" when there is none.
+func (s *TranscodingService) chooseThumbnail(ctx context.Context, id uuid.UUID, inputPath string, metadata *VideoMetadata) string {
+	existing, err := s.thumbnails.ListByVideo(ctx, id)
+	if err != nil {
+		s.log.Error(ctx, "failed to list thumbnails", err, map[string]interface{}{"video_id": id})
+	}
+	var kept string
+	for _, t := range existing {
+		if t.Selected && t.Kind == domain.ThumbnailCustom {
+			kept = t.Key
+		}
+	}
+
+	candidates, err := s.generateThumbnails(ctx, id, inputPath, metadata)
+	if err != nil {
+		s.log.Error(ctx, "failed to generate thumbnails", err, map[string]interface{}{"video_id": id})
+		return kept
+	}
+	if kept == "" {
+		candidates[0].Selected = true
+	}
+	if err := s.thumbnails.ReplaceCandidates(ctx, id, candidates); err != nil {
+		s.log.Error(ctx, "failed to record thumbnail candidates", err, map[string]interface{}{"video_id": id})
+		return kept
+	}
+
+	// The previous candidates' files go with their rows. Against object
+	// storage only the local copies are here; what was uploaded stays until
+	// the video is deleted.
+	for _, t := range existing {
+		if t.Kind == domain.ThumbnailCandidate {
+			if err := os.RemoveAll(s.ThumbnailDir(t)); err != nil {
+				s.log.Warn(ctx, "could not remove old thumbnail candidate", map[string]interface{}{
+					"video_id": id,
+					"error":    err.Error(),
+				})
+			}
+		}
+	}
+
+	if kept != "" {
+		return kept
+	}
+	return candidates[0].Key
+}
+
+// generateThumbnails scores thumbnailSamples frames of inputPath with
+// framescore and renders the best thumbnailCandidates, best first. Frames
+// that must not be used — fades, blank slates — are only rendered when no
+// frame is better, so that every video has a poster.
+func (s *TranscodingService) generateThumbnails(ctx context.Context, id uuid.UUID, inputPath string, metadata *VideoMetadata) ([]*domain.Thumbnail, error) {
+	dir, err := os.MkdirTemp("", "thumbnails-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating thumbnail sample directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	samples := make([]thumbnailSample, 0, thumbnailSamples)
+	for i, offset := range thumbnailOffsets(metadata.Duration) {
+		sample, err := s.scoreFrame(ctx, inputPath, dir, i, offset)
+		if err != nil {
+			s.log.Warn(ctx, "could not score thumbnail sample", map[string]interface{}{
+				"video_id": id,
+				"offset":   offset,
+				"error":    err.Error(),
+			})
+			continue
+		}
+		samples = append(samples, sample)
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no frame could be sampled")
+	}
+
+	now := time.Now()
+	var thumbnails []*domain.Thumbnail
+	for _, sample := range bestSamples(samples, thumbnailCandidates) {
+		t := &domain.Thumbnail{
+			ID:        uuid.New(),
+			VideoID:   id,
+			Kind:      domain.ThumbnailCandidate,
+			Status:    domain.ThumbnailReady,
+			Offset:    sample.offset,
+			Score:     sample.score,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		t.Key = ThumbnailKey(id, t.ID)
+		if err := s.renderThumbnail(ctx, inputPath, sample.offset, s.ThumbnailDir(t)); err != nil {
+			s.log.Warn(ctx, "could not render thumbnail candidate", map[string]interface{}{
+				"video_id": id,
+				"offset":   sample.offset,
+				"error":    err.Error(),
+			})
+			continue
+		}
+		thumbnails = append(thumbnails, t)
+	}
+	if len(thumbnails) == 0 {
+		return nil, fmt.Errorf("no thumbnail candidate could be rendered")
+	}
+
+	s.log.Info(ctx, "generated thumbnail candidates", map[string]interface{}{
+		"video_id":   id,
+		"sampled":    len(samples),
+		"candidates": len(thumbnails),
+		"best_score": thumbnails[0].Score,
+	})
+	return thumbnails, nil
+}
+
+// thumbnailOffsets are where the samples of a duration-second video are
+// taken, in seconds.
+func thumbnailOffsets(duration float64) []float64 {
+	if duration <= 0 {
+		return []float64{0}
+	}
+	offsets := make([]float64, thumbnailSamples)
+	for i := range offsets {
+		offsets[i] = duration * (0.05 + 0.9*(float64(i)+0.5)/thumbnailSamples)
+	}
+	return offsets
+}
+
+// bestSamples returns up to n of samples, highest score first. Samples that
+// scored nothing are left out unless none scored anything, when the first is
+// returned alone.
+func bestSamples(samples []thumbnailSample, n int) []thumbnailSample {
+	sorted := append([]thumbnailSample(nil), samples...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].score > sorted[j].score })
+
+	var best []thumbnailSample
+	for _, sample := range sorted {
+		if sample.score <= 0 || len(best) == n {
+			break
+		}
+		best = append(best, sample)
+	}
+	if len(best) == 0 {
+		return samples[:1]
+	}
+	return best
+}
+
+// scoreFrame scores the frame of inputPath at offset, comparing it with the
+// frame thumbnailLookback earlier. Both are written to dir as small
+// grayscale PNGs, sample_<i>_0.png and sample_<i>_1.png.
+func (s *TranscodingService) scoreFrame(ctx context.Context, inputPath, dir string, i int, offset float64) (thumbnailSample, error) {
+	start := max(offset-thumbnailLookback, 0)
+	args := []string{
+		"-ss", fmt.Sprintf("%.3f", start),
+		"-i", inputPath,
+		"-an", "-sn",
+		"-vf", fmt.Sprintf("fps=%g,scale=%d:-2,format=gray", 1/thumbnailLookback, thumbnailScoreWidth),
+		"-frames:v", "2",
+		"-start_number", "0",
+		"-y",
+		filepath.Join(dir, fmt.Sprintf("sample_%02d_%%d.png", i)),
+	}
+	if _, err := s.ffmpegOutput(ctx, args); err != nil {
+		return thumbnailSample{}, err
+	}
+
+	before, err := decodePNG(filepath.Join(dir, fmt.Sprintf("sample_%02d_0.png", i)))
+	if err != nil {
+		return thumbnailSample{}, err
+	}
+	// Near the end of the video only one frame may be left, which then is
+	// the sample, and there is no change to measure.
+	frame, err := decodePNG(filepath.Join(dir, fmt.Sprintf("sample_%02d_1.png", i)))
+	if err != nil {
+		return thumbnailSample{offset: start, score: framescore.Score(framescore.Measure(before), 0)}, nil
+	}
+	return thumbnailSample{
+		offset: start + thumbnailLookback,
+		score:  framescore.Score(framescore.Measure(frame), framescore.Change(before, frame)),
+	}, nil
+}
+
+func decodePNG(file string) (image.Image, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", filepath.Base(file), err)
+	}
+	return img, nil
+}
+
+// renderThumbnail writes one frame of input, at offset seconds or, for a
+// still image, its only one, to dir in every domain.ThumbnailSizes as
+// <size>.jpg and <size>.webp, in a single ffmpeg run.
+func (s *TranscodingService) renderThumbnail(ctx context.Context, input string, offset float64, dir string) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("clearing thumbnail directory: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create thumbnail directory: %w", err)
+	}
+
+	sizes := domain.ThumbnailSizes
+	branches := make([]string, len(sizes))
+	filters := make([]string, len(sizes))
+	for i, size := range sizes {
+		branches[i] = fmt.Sprintf("[s%d]", i)
+		filters[i] = fmt.Sprintf("[s%d]scale='min(%d,iw)':-2,split[j%d][w%d]", i, size.Width, i, i)
+	}
+	graph := fmt.Sprintf("[0:v]split=%d%s;%s", len(sizes), strings.Join(branches, ""), strings.Join(filters, ";"))
+
+	var args []string
+	if offset > 0 {
+		args = append(args, "-ss", fmt.Sprintf("%.3f", offset))
+	}
+	args = append(args, "-i", input, "-filter_complex", graph, "-y")
+	for i, size := range sizes {
+		args = append(args,
+			"-map", fmt.Sprintf("[j%d]", i), "-frames:v", "1", "-q:v", "3",
+			filepath.Join(dir, size.Name+".jpg"),
+			"-map", fmt.Sprintf("[w%d]", i), "-frames:v", "1", "-c:v", "libwebp", "-quality", "80",
+			filepath.Join(dir, size.Name+".webp"),
+		)
+	}
+	if _, err := s.ffmpegOutput(ctx, args); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ThumbnailDir is where the worker renders t: its key under ThumbnailPath.
+func (s *TranscodingService) ThumbnailDir(t *domain.Thumbnail) string {
+	return filepath.Join(s.storage.ThumbnailPath, filepath.FromSlash(strings.TrimPrefix(t.Key, "thumbnails/")))
+}
+
+// RenderCustomThumbnail renders an uploaded image, which must be in place
+// locally at sourcePath, to every size. An image ffmpeg cannot read fails
+// the thumbnail. The thumbnail is left for the caller to mark ready once the
+// files are where they are served from.
+func (s *TranscodingService) RenderCustomThumbnail(ctx context.Context, t *domain.Thumbnail, sourcePath string) error {
+	if err := s.renderThumbnail(ctx, sourcePath, 0, s.ThumbnailDir(t)); err != nil {
+		s.markThumbnailFailed(ctx, t)
+		return fmt.Errorf("failed to render thumbnail: %w", err)
+	}
+	s.log.Info(ctx, "rendered custom thumbnail", map[string]interface{}{
+		"video_id":     t.VideoID,
+		"thumbnail_id": t.ID,
+	})
+	return nil
+}
+
+// MarkThumbnailReady records that a rendered upload is being served and
+// makes it the video's poster: the owner uploaded it to use it.
+func (s *TranscodingService) MarkThumbnailReady(ctx context.Context, t *domain.Thumbnail) error {
+	if err := s.thumbnails.UpdateStatus(ctx, t.ID, domain.ThumbnailReady); err != nil {
+		return fmt.Errorf("failed to mark thumbnail ready: %w", err)
+	}
+	if err := s.thumbnails.Select(ctx, t); err != nil {
+		return fmt.Errorf("failed to select thumbnail: %w", err)
+	}
+	return nil
+}
+
+func (s *TranscodingService) markThumbnailFailed(ctx context.Context, t *domain.Thumbnail) {
+	if err := s.thumbnails.UpdateStatus(ctx, t.ID, domain.ThumbnailFailed); err != nil {
+		s.log.Error(ctx, "failed to mark thumbnail as failed", err, map[string]interface{}{
+			"video_id":     t.VideoID,
+			"thumbnail_id": t.ID,
+		})
+	}
+}
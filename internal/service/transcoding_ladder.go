@@ -0,0 +1,298 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/config"
+)
+
+const (
+	// progressReportInterval bounds how often a job writes its progress
+	// while it encodes. ffmpeg reports several times a second; the database
+	// needs to hear about it far less often than that.
+	progressReportInterval = 2 * time.Second
+	// hlsSegmentSeconds is the target segment length. Keyframes are forced
+	// on the same boundaries in every variant, so a player can switch
+	// between them at any segment.
+	hlsSegmentSeconds = 6
+)
+
+// rendition looks quality up in the ladder.
+func (s *TranscodingService) rendition(quality string) (config.Rendition, bool) {
+	for _, rung := range s.worker.Ladder {
+		if rung.Name == quality {
+			return rung, true
+		}
+	}
+	return config.Rendition{}, false
+}
+
+// selectRenditions resolves the qualities a job asked for against the ladder,
+// in ladder order, dropping names the ladder does not know and rungs taller
+// than the source. No qualities means the whole ladder.
+func (s *TranscodingService) selectRenditions(ctx context.Context, videoID string, qualities []string, sourceHeight int) []config.Rendition {
+	requested := s.worker.Ladder
+	if len(qualities) > 0 {
+		requested = nil
+		for _, rung := range s.worker.Ladder {
+			if slices.Contains(qualities, rung.Name) {
+				requested = append(requested, rung)
+			}
+		}
+		for _, quality := range qualities {
+			if _, ok := s.rendition(quality); !ok {
+				s.log.Warn(ctx, "requested quality is not in the ladder, ignoring", map[string]interface{}{
+					"video_id": videoID,
+					"quality":  quality,
+				})
+			}
+		}
+	}
+
+	var rungs []config.Rendition
+	for _, rung := range requested {
+		if sourceHeight < rung.Height {
+			s.log.Info(ctx, "skipping quality (would upscale)", map[string]interface{}{
+				"video_id":        videoID,
+				"quality":         rung.Name,
+				"original_height": sourceHeight,
+				"target_height":   rung.Height,
+			})
+			continue
+		}
+		rungs = append(rungs, rung)
+	}
+	return rungs
+}
+
+// threadShares divides budget encoder threads between rungs in proportion to
+// their frame area, for encodes of them that run side by side. Every rung gets
+// at least one thread and none more than the whole budget, so the shares add
+// up to more than budget only when there are more rungs than threads.
+func threadShares(rungs []config.Rendition, budget int) []int {
+	total := 0
+	for _, rung := range rungs {
+		total += rung.Pixels()
+	}
+
+	shares := make([]int, len(rungs))
+	for i, rung := range rungs {
+		share := 1
+		if total > 0 {
+			share = budget * rung.Pixels() / total
+		}
+		shares[i] = min(max(share, 1), budget)
+	}
+	return shares
+}
+
+// segmentExtension is the file extension of a media segment in packaging.
+func segmentExtension(packaging string) string {
+	if packaging == config.PackagingCMAF {
+		return ".m4s"
+	}
+	return ".ts"
+}
+
+// audioBitrateKbps is the rate every audio rendition is encoded at; see
+// audioEncodeArgs.
+const audioBitrateKbps = 128
+
+// audioEncodeArgs is how every audio rendition is encoded, from the source or
+// from a dub: AAC-LC at a fixed rate, downmixed to stereo, so the renditions
+// of one group are interchangeable mid-stream.
+var audioEncodeArgs = []string{"-c:a", "aac", "-b:a", strconv.Itoa(audioBitrateKbps) + "k", "-ac", "2"}
+
+// variantPlaylistName is the master playlist ffmpeg writes beside a rung's
+// media playlist, listing the rung alone. It is read for the rung's variant
+// attributes and removed; see readVariant.
+const variantPlaylistName = "variant.m3u8"
+
+// rungArgs builds the ffmpeg command line that encodes rung of inputPath, video
+// only, as an HLS rendition in dir, at the frame rate rungFrameRate gives it
+// and in the pixel format rungFilter does. source is the probe's measure of
+// inputPath. Keyframes are forced every hlsSegmentSeconds of source time, the
+// same in every rung, so the segments of all rungs line up. Nothing of the
+// source's metadata is carried over; see strippedMetadataArgs.
+func rungArgs(inputPath, dir string, rung config.Rendition, threads int, packaging string, source *VideoMetadata) []string {
+	rate := rungFrameRate(sourceFrameRate(source), rung.FPS)
+	args := []string{
+		"-i", inputPath,
+		"-map", "0:V:0",
+		"-an", "-sn",
+		"-vf", rungFilter(rung, rate, source.VideoRange()),
+	}
+	args = append(args, rungCodecArgs(rung, rate, source.VideoRange())...)
+	args = append(args,
+		"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", hlsSegmentSeconds),
+		"-b:v", fmt.Sprintf("%dk", rung.BitrateKbps),
+		"-maxrate", fmt.Sprintf("%dk", rung.MaxRateKbps),
+		"-bufsize", fmt.Sprintf("%dk", rung.BufSizeKbps),
+		"-threads", strconv.Itoa(threads),
+	)
+	args = append(args, strippedMetadataArgs...)
+	args = append(args, hlsMuxerArgs(packaging, "init_"+rung.Name+".mp4")...)
+	return append(args,
+		"-hls_segment_filename", filepath.Join(dir, "segment_%03d"+segmentExtension(packaging)),
+		"-master_pl_name", variantPlaylistName,
+		"-progress", "pipe:1",
+		"-nostats",
+		"-y",
+		filepath.Join(dir, "playlist.m3u8"),
+	)
+}
+
+// hlsMuxerArgs are the HLS muxer options every encode shares. initName names
+// the init segment under CMAF packaging.
+func hlsMuxerArgs(packaging, initName string) []string {
+	args := []string{
+		"-f", "hls",
+		"-hls_time", strconv.Itoa(hlsSegmentSeconds),
+		"-hls_playlist_type", "vod",
+		"-hls_list_size", "0",
+	}
+	if packaging == config.PackagingCMAF {
+		args = append(args,
+			"-hls_segment_type", "fmp4",
+			"-hls_fmp4_init_filename", initName,
+			"-hls_flags", "independent_segments",
+		)
+	}
+	return args
+}
+
+// remuxToMP4 writes quality's segments, in packaging, out again as a
+// progressive MP4 for clients that cannot play HLS, with the audio rendition
+// audio, if any, as its sound. It copies the encoded streams, so it costs
+// disk and I/O but no encoding.
+func (s *TranscodingService) remuxToMP4(ctx context.Context, outputDir, quality, audio, packaging string) error {
+	hlsDir := filepath.Join(outputDir, "hls")
+	args := []string{"-i", filepath.Join(hlsDir, quality, "playlist.m3u8")}
+	if audio != "" {
+		args = append(args,
+			"-i", filepath.Join(hlsDir, audio, "playlist.m3u8"),
+			"-map", "0:v", "-map", "1:a",
+		)
+		if packaging != config.PackagingCMAF {
+			// MPEG-TS carries AAC with ADTS headers, which MP4 does not.
+			args = append(args, "-bsf:a", "aac_adtstoasc")
+		}
+	}
+	args = append(args,
+		"-c", "copy",
+		"-movflags", "+faststart",
+		"-progress", "pipe:1",
+		"-nostats",
+		"-y",
+		filepath.Join(outputDir, quality+".mp4"),
+	)
+	return s.runFFmpeg(ctx, args, 0, func(float64) {})
+}
+
+// runFFmpeg runs ffmpeg with args, which must include -progress pipe:1, and
+// calls report with the fraction of duration seconds processed so far.
+func (s *TranscodingService) runFFmpeg(ctx context.Context, args []string, duration float64, report func(float64)) error {
+	s.ensureFFmpegPath()
+
+	cmd := exec.CommandContext(ctx, s.ffmpegPath, args...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("ffmpeg progress pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("ffmpeg failed to start: %w", err)
+	}
+
+	readFFmpegProgress(stdout, duration, report)
+
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("ffmpeg cancelled or timed out: %w", ctx.Err())
+		}
+		return fmt.Errorf("ffmpeg failed: %w, output: %s", err, stderr.String())
+	}
+	return nil
+}
+
+// encodeProgress is the fraction of the source ffmpeg has processed, shared
+// between the goroutine reading ffmpeg's output and the one reporting it.
+type encodeProgress struct {
+	mu       sync.Mutex
+	started  time.Time
+	fraction float64
+}
+
+// set records that fraction of the source is done. Progress never goes
+// backwards.
+func (p *encodeProgress) set(fraction float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fraction = max(p.fraction, min(fraction, 1))
+}
+
+const (
+	// etaMinElapsed and etaMinFraction hold the ETA back until the encode
+	// has run long enough to judge its speed; the first seconds are spent
+	// probing and filling encoder lookahead and extrapolate wildly.
+	etaMinElapsed  = 5 * time.Second
+	etaMinFraction = 0.01
+)
+
+// estimate returns the fraction done and, once there is enough to go on,
+// when the encode will finish at its average speed so far.
+func (p *encodeProgress) estimate(now time.Time) (float64, *time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	elapsed := now.Sub(p.started)
+	if elapsed < etaMinElapsed || p.fraction < etaMinFraction || p.fraction >= 1 {
+		return p.fraction, nil
+	}
+	remaining := time.Duration(float64(elapsed) * (1 - p.fraction) / p.fraction)
+	eta := now.Add(remaining).Truncate(time.Second)
+	return p.fraction, &eta
+}
+
+// readFFmpegProgress consumes the key=value stream ffmpeg writes with
+// -progress and reports how far through the source it is. It reads until r is
+// exhausted, so ffmpeg never blocks on a full pipe.
+func readFFmpegProgress(r io.Reader, duration float64, report func(float64)) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+
+		switch key {
+		// out_time_ms is in microseconds too, despite the name; older
+		// ffmpeg builds only write that one.
+		case "out_time_us", "out_time_ms":
+			us, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || duration <= 0 {
+				continue
+			}
+			report(float64(us) / 1e6 / duration)
+		case "progress":
+			if value == "end" {
+				report(1)
+			}
+		}
+	}
+	// A scan error means the pipe broke, which cmd.Wait will report.
+	io.Copy(io.Discard, r)
+}
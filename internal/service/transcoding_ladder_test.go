@@ -0,0 +1,56 @@
+package service
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/config"
+)
+
+func TestThreadShares(t *testing.T) {
+	ladder := []config.Rendition{
+		{Name: "360p", Width: 640, Height: 360},
+		{Name: "720p", Width: 1280, Height: 720},
+		{Name: "1080p", Width: 1920, Height: 1080},
+	}
+	tests := []struct {
+		name   string
+		rungs  []config.Rendition
+		budget int
+		want   []int
+	}{
+		{"by frame area", ladder, 14, []int{1, 4, 9}},
+		{"never under one thread", ladder, 4, []int{1, 1, 2}},
+		{"a lone rung has the whole budget", ladder[2:], 8, []int{8}},
+		{"more rungs than threads", ladder, 1, []int{1, 1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := threadShares(tt.rungs, tt.budget)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("threadShares(%d) = %v, want %v", tt.budget, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProbeResultRungThreads(t *testing.T) {
+	rungs := []config.Rendition{
+		{Name: "360p", Width: 640, Height: 360},
+		{Name: "1080p", Width: 1920, Height: 1080},
+	}
+
+	recorded := &ProbeResult{Rungs: rungs, Threads: []int{2, 6}}
+	if got := recorded.RungThreads("1080p", 16); got != 6 {
+		t.Errorf("recorded share = %d, want 6 whatever the budget is now", got)
+	}
+
+	// A probe from before the shares were recorded works them out again.
+	old := &ProbeResult{Rungs: rungs}
+	if got := old.RungThreads("1080p", 10); got != 9 {
+		t.Errorf("worked-out share = %d, want 9", got)
+	}
+	if got := old.RungThreads("audio_0", 10); got != 10 {
+		t.Errorf("share of a rendition that is no rung = %d, want the budget", got)
+	}
+}
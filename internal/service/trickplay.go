@@ -0,0 +1,113 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/Nuu-maan/video-streaming-service/pkg/webvtt"
+)
+
+// Trickplay layout. Thumbnails are trickplayWidth pixels wide and as tall as
+// the source's shape makes them, tiled trickplayColumns by trickplayRows to a
+// JPEG sheet; a WebVTT file maps each interval of the video to its tile.
+const (
+	// TrickplayDir is the directory beside the HLS output that holds the
+	// sheets and the WebVTT file.
+	TrickplayDir   = "trickplay"
+	trickplayTrack = "thumbnails.vtt"
+
+	trickplayWidth   = 160
+	trickplayColumns = 10
+	trickplayRows    = 10
+)
+
+// generateTrickplay writes the seek-bar previews of inputPath to
+// <outputDir>/trickplay: a thumbnail every interval, tiled into sprite sheets
+// sprite_000.jpg, sprite_001.jpg and so on, and thumbnails.vtt, whose cues
+// name a sheet and the tile in it as a media fragment:
+//
+//	00:00:10.000 --> 00:00:20.000
+//	sprite_000.jpg#xywh=160,0,160,90
+func (s *TranscodingService) generateTrickplay(ctx context.Context, inputPath, outputDir string, metadata *VideoMetadata, interval time.Duration) error {
+	dir := filepath.Join(outputDir, TrickplayDir)
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("clearing trickplay directory: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create trickplay directory: %w", err)
+	}
+
+	height := trickplayHeight(metadata.Width, metadata.Height)
+	args := []string{
+		"-i", inputPath,
+		"-an", "-sn",
+		"-vf", fmt.Sprintf("fps=%g,scale=%d:%d,tile=%dx%d",
+			1/interval.Seconds(), trickplayWidth, height, trickplayColumns, trickplayRows),
+		"-q:v", "5",
+		"-threads", strconv.Itoa(s.worker.JobThreads),
+		"-progress", "pipe:1",
+		"-nostats",
+		"-start_number", "0",
+		"-y",
+		filepath.Join(dir, "sprite_%03d.jpg"),
+	}
+	if err := s.runFFmpeg(ctx, args, 0, func(float64) {}); err != nil {
+		return err
+	}
+
+	sheets, err := filepath.Glob(filepath.Join(dir, "sprite_*.jpg"))
+	if err != nil || len(sheets) == 0 {
+		return fmt.Errorf("no sprite sheets written")
+	}
+
+	// A sheet short of what the duration promises means ffmpeg stopped
+	// early; the track only covers what was written.
+	duration := time.Duration(metadata.Duration * float64(time.Second))
+	count := min(int(math.Ceil(metadata.Duration/interval.Seconds())), len(sheets)*trickplayColumns*trickplayRows)
+
+	var track bytes.Buffer
+	if err := webvtt.Write(&track, nil, trickplayCues(count, interval, duration, height)); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, trickplayTrack), track.Bytes(), 0644); err != nil {
+		return fmt.Errorf("writing trickplay track: %w", err)
+	}
+	return nil
+}
+
+// trickplayHeight is the height of a thumbnail trickplayWidth wide of a
+// width by height source, rounded to even as the JPEG encoder wants it.
+func trickplayHeight(width, height int) int {
+	if width <= 0 || height <= 0 {
+		return trickplayWidth * 9 / 16
+	}
+	return max(int(math.Round(float64(trickplayWidth*height)/float64(width)/2))*2, 2)
+}
+
+// trickplayCues maps count thumbnails interval apart, the last ending at
+// duration, onto their tiles in the sprite sheets.
+func trickplayCues(count int, interval, duration time.Duration, height int) []webvtt.Cue {
+	perSheet := trickplayColumns * trickplayRows
+	cues := make([]webvtt.Cue, 0, count)
+	for i := 0; i < count; i++ {
+		start := time.Duration(i) * interval
+		end := min(start+interval, duration)
+		if end <= start {
+			break
+		}
+		tile := i % perSheet
+		cues = append(cues, webvtt.Cue{
+			Start: start,
+			End:   end,
+			Text: fmt.Sprintf("sprite_%03d.jpg#xywh=%d,%d,%d,%d", i/perSheet,
+				tile%trickplayColumns*trickplayWidth, tile/trickplayColumns*height, trickplayWidth, height),
+		})
+	}
+	return cues
+}
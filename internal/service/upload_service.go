@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"mime/multipart"
+	"path"
 	"path/filepath"
 	"strings"
 
@@ -52,6 +53,9 @@ type UploadRequest struct {
 	Description string
 	OwnerID     uuid.UUID
 	Visibility  domain.VideoVisibility
+	// NormalizeAudio levels the video's audio to the worker's loudness
+	// target, when the worker does that at all.
+	NormalizeAudio bool
 }
 
 // UploadVideo validates the request, streams the file into storage, probes it
@@ -59,49 +63,78 @@ type UploadRequest struct {
 // storage, the file is removed: a half-written upload with no database row is
 // a leak.
 func (s *UploadService) UploadVideo(ctx context.Context, req UploadRequest) (video *domain.Video, err error) {
-	title := validator.SanitizeString(req.Title)
-	description := validator.SanitizeString(req.Description)
-
-	if err := validator.ValidateTitle(title); err != nil {
-		return nil, fmt.Errorf("%w: %v", domain.ErrInvalidTitle, err)
-	}
-	if err := validator.ValidateDescription(description); err != nil {
-		return nil, fmt.Errorf("%w: %v", domain.ErrInvalidInput, err)
-	}
-	if err := validator.ValidateVideoFile(req.File, req.Header, s.storageCfg.MaxFileSize); err != nil {
+	details, err := validateDetails(req.Title, req.Description, req.Visibility, req.NormalizeAudio)
+	if err != nil {
 		return nil, err
 	}
-
-	visibility := req.Visibility
-	if visibility == "" {
-		visibility = domain.VisibilityPublic
+	if err := validator.ValidateVideoUpload(req.Header.Filename, req.Header.Size, s.storageCfg.MaxFileSize); err != nil {
+		return nil, err
 	}
-	if !visibility.IsValid() {
-		return nil, fmt.Errorf("%w: unknown visibility %q", domain.ErrInvalidInput, visibility)
+	// The browser's Content-Type is a guess from the extension at best; the
+	// video is labelled with what its bytes are.
+	container, err := validator.SniffVideoFile(req.File)
+	if err != nil {
+		return nil, err
 	}
 
-	key, filePath, err := s.persistFile(ctx, req.File, req.Header)
+	key, filePath, err := s.persistFile(ctx, req.File, req.Header, container.MIMEType)
 	if err != nil {
 		return nil, err
 	}
 	// Any failure past this point must not leave an orphaned file behind.
 	defer func() {
 		if err != nil {
-			if removeErr := s.store.Delete(ctx, key); removeErr != nil {
-				s.log.Error(ctx, "failed to clean up orphaned upload", removeErr, map[string]interface{}{
-					"key": key,
-				})
-			}
+			s.discardRaw(ctx, key)
 		}
 	}()
 
-	video, err = domain.NewVideo(title, description, filepath.Base(filePath), filePath, mimeTypeOf(req.Header), req.Header.Size)
+	return s.recordVideo(ctx, details, req.OwnerID, filePath, container.MIMEType, req.Header.Size)
+}
+
+// videoDetails is the validated, user-supplied description of a new video.
+type videoDetails struct {
+	title          string
+	description    string
+	visibility     domain.VideoVisibility
+	normalizeAudio bool
+}
+
+// validateDetails sanitizes and checks everything about an upload except the
+// file itself. A missing visibility defaults to public.
+func validateDetails(title, description string, visibility domain.VideoVisibility, normalizeAudio bool) (videoDetails, error) {
+	title = validator.SanitizeString(title)
+	description = validator.SanitizeString(description)
+
+	if err := validator.ValidateTitle(title); err != nil {
+		return videoDetails{}, fmt.Errorf("%w: %v", domain.ErrInvalidTitle, err)
+	}
+	if err := validator.ValidateDescription(description); err != nil {
+		return videoDetails{}, fmt.Errorf("%w: %v", domain.ErrInvalidInput, err)
+	}
+
+	if visibility == "" {
+		visibility = domain.VisibilityPublic
+	}
+	if !visibility.IsValid() {
+		return videoDetails{}, fmt.Errorf("%w: unknown visibility %q", domain.ErrInvalidInput, visibility)
+	}
+	return videoDetails{title: title, description: description, visibility: visibility, normalizeAudio: normalizeAudio}, nil
+}
+
+// recordVideo records a raw file that is already in storage as a new video,
+// probing it first when the store is local. Both the single-request upload and
+// the resumable upload finish here, so a video is created the same way no
+// matter how its bytes arrived. The caller owns cleanup of the raw file if
+// this fails.
+func (s *UploadService) recordVideo(ctx context.Context, details videoDetails, ownerID uuid.UUID, filePath, mimeType string, size int64) (*domain.Video, error) {
+	video, err := domain.NewVideo(details.title, details.description, filepath.Base(filePath), filePath, mimeType, size)
 	if err != nil {
 		return nil, err
 	}
-	video.Visibility = visibility
-	if req.OwnerID != uuid.Nil {
-		owner := req.OwnerID
+	video.Visibility = details.visibility
+	video.NormalizeAudio = details.normalizeAudio
+	if ownerID != uuid.Nil {
+		owner := ownerID
 		video.UserID = &owner
 	}
 
@@ -121,7 +154,7 @@ func (s *UploadService) UploadVideo(ctx context.Context, req UploadRequest) (vid
 		}
 	}
 
-	if err = s.videoRepo.Create(ctx, video); err != nil {
+	if err := s.videoRepo.Create(ctx, video); err != nil {
 		return nil, fmt.Errorf("recording video: %w", err)
 	}
 
@@ -135,33 +168,66 @@ func (s *UploadService) UploadVideo(ctx context.Context, req UploadRequest) (vid
 }
 
 // persistFile streams the upload into the store under a generated name and
-// returns its key along with the video's FilePath. The stored name is derived
-// from a fresh UUID, never from user input, so a crafted filename cannot
-// escape the upload area.
+// returns its key along with the video's FilePath.
+func (s *UploadService) persistFile(ctx context.Context, file multipart.File, header *multipart.FileHeader, mimeType string) (key, filePath string, err error) {
+	key, filePath = s.newRawLocation(header.Filename)
+	if err := s.store.Save(ctx, key, file, header.Size, mimeType); err != nil {
+		return "", "", fmt.Errorf("storing upload: %w", err)
+	}
+	return key, filePath, nil
+}
+
+// newRawLocation picks the storage key and FilePath for a new raw upload. The
+// stored name is derived from a fresh UUID, never from user input, so a
+// crafted filename cannot escape the upload area; only the extension is kept.
 //
 // FilePath is where the transcoding worker expects a local working copy of the
 // raw file: the local store writes it there directly, and when the store is
 // remote the worker re-materializes it from the raw object before running
 // ffmpeg.
-func (s *UploadService) persistFile(ctx context.Context, file multipart.File, header *multipart.FileHeader) (key, filePath string, err error) {
-	ext := strings.ToLower(filepath.Ext(header.Filename))
-	name := uuid.New().String() + ext
-
+func (s *UploadService) newRawLocation(filename string) (key, filePath string) {
+	name := uuid.New().String() + strings.ToLower(filepath.Ext(filename))
 	key = storage.Key("raw", name)
-	if err := s.store.Save(ctx, key, file, header.Size, mimeTypeOf(header)); err != nil {
-		return "", "", fmt.Errorf("storing upload: %w", err)
+	return key, s.rawFilePath(key)
+}
+
+// rawFilePath is the FilePath for the raw object at key.
+func (s *UploadService) rawFilePath(key string) string {
+	return filepath.Join(s.storageCfg.UploadPath, "raw", path.Base(key))
+}
+
+// unrecordVideo deletes a video recordVideo created for an upload whose
+// completion then failed, since a retry records the file again and would
+// leave two. It reports whether the video is gone: while it is not, its raw
+// file has to stay.
+func (s *UploadService) unrecordVideo(ctx context.Context, video *domain.Video) bool {
+	// The request's context may be what failed the completion.
+	if err := s.videoRepo.Delete(context.WithoutCancel(ctx), video.ID); err != nil {
+		s.log.Error(ctx, "failed to remove the video of a failed upload completion", err, map[string]interface{}{
+			"video_id": video.ID,
+		})
+		return false
 	}
+	return true
+}
 
-	return key, filepath.Join(s.storageCfg.UploadPath, "raw", name), nil
+// discardRaw removes a raw file whose video was never recorded.
+func (s *UploadService) discardRaw(ctx context.Context, key string) {
+	if err := s.store.Delete(ctx, key); err != nil {
+		s.log.Error(ctx, "failed to clean up orphaned upload", err, map[string]interface{}{
+			"key": key,
+		})
+	}
 }
 
 // RemoveVideoFiles deletes everything storage holds for a video: the raw
-// upload, the transcoded directory, and the thumbnail. It belongs beside every
-// hard delete of a videos row — without it the files sit in storage forever,
-// still fetchable through the static /uploads mount or the public MinIO
-// buckets. It is best-effort by design: callers run it after the row is gone,
-// when failing their request would only report a delete that did happen as one
-// that did not, so failures are logged for manual reaping instead.
+// upload and any dubs, captions and thumbnail images, the transcoded
+// directory, and the thumbnails. It belongs beside every hard delete of a
+// videos row — without it the files sit in storage forever, still fetchable
+// through the static /uploads mount or the public MinIO buckets. It is
+// best-effort by design: callers run it after the row is gone, when failing
+// their request would only report a delete that did happen as one that did
+// not, so failures are logged for manual reaping instead.
 //
 // Keys are rebuilt the same way their writers built them (persistFile for raw,
 // the queue worker for the rest), and both backends treat deleting an absent
@@ -181,19 +247,40 @@ func (s *UploadService) RemoveVideoFiles(ctx context.Context, video *domain.Vide
 		report(s.store.Delete(ctx, rawKey), rawKey)
 	}
 
+	// A trim whose new revision never published still holds the pre-trim
+	// source, which only output retirement would otherwise have deleted.
+	if video.Trim != nil && video.Trim.FilePath != video.FilePath {
+		trimKey := storage.Key("raw", filepath.Base(video.Trim.FilePath))
+		report(s.store.Delete(ctx, trimKey), trimKey)
+	}
+
+	dubsPrefix := storage.Key("raw", "dubs", video.ID.String())
+	report(s.store.DeletePrefix(ctx, dubsPrefix), dubsPrefix)
+
+	captionsPrefix := storage.Key("raw", "captions", video.ID.String())
+	report(s.store.DeletePrefix(ctx, captionsPrefix), captionsPrefix)
+
 	transcodedPrefix := storage.Key("transcoded", video.ID.String())
 	report(s.store.DeletePrefix(ctx, transcodedPrefix), transcodedPrefix)
 
+	thumbnailsPrefix := storage.Key("raw", "thumbnails", video.ID.String())
+	report(s.store.DeletePrefix(ctx, thumbnailsPrefix), thumbnailsPrefix)
+
+	renderedPrefix := storage.Key("thumbnails", video.ID.String())
+	report(s.store.DeletePrefix(ctx, renderedPrefix), renderedPrefix)
+
+	// Videos transcoded before thumbnails had candidates kept a single JPEG.
 	thumbnailKey := storage.Key("thumbnails", video.ID.String()+".jpg")
 	report(s.store.Delete(ctx, thumbnailKey), thumbnailKey)
 }
 
-// mimeTypeOf trusts the browser-supplied content type only as a fallback label;
-// validator.ValidateVideoFile has already sniffed the magic bytes.
-func mimeTypeOf(header *multipart.FileHeader) string {
-	if mimeType := header.Header.Get("Content-Type"); mimeType != "" {
-		return mimeType
+// mimeTypeFor labels an upload session with the type the client declared, or
+// else one derived from the filename's extension, until its bytes arrive. The
+// video it becomes is labelled with the type its bytes are sniffed as.
+func mimeTypeFor(declared, filename string) string {
+	if declared != "" {
+		return declared
 	}
-	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(filename)), ".")
 	return "video/" + ext
 }
@@ -299,19 +299,50 @@ func (o *VideoOptimizer) GetWebPThumbnailArgs(inputFile, outputFile string, time
 	}
 }
 
-func (o *VideoOptimizer) GetAnimatedThumbnailArgs(inputFile, outputFile string, startTime string, duration int, width, height int) []string {
-	return []string{
-		"-i", inputFile,
-		"-ss", startTime,
-		"-t", fmt.Sprintf("%d", duration),
-		"-vf", fmt.Sprintf("fps=10,scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2",
-			width, height, width, height),
+// PreviewClip is one stretch of a video, in seconds, that goes into its
+// animated preview.
+type PreviewClip struct {
+	Start    float64
+	Duration float64
+}
+
+// GetAnimatedThumbnailArgs stitches clips of inputFile, in order, into a
+// muted animated preview at most width pixels wide: a looping WebP at 10 fps
+// for an <img>, and an H.264 MP4 for a <video>, from one ffmpeg run. Only
+// the stitched video is mapped, so neither carries sound. Each clip is its
+// own input so ffmpeg seeks to it rather than decoding its way there.
+func (o *VideoOptimizer) GetAnimatedThumbnailArgs(inputFile, webpFile, mp4File string, clips []PreviewClip, width int) []string {
+	var args []string
+	scaled := make([]string, len(clips))
+	filters := make([]string, 0, len(clips)+1)
+	for i, clip := range clips {
+		args = append(args,
+			"-ss", fmt.Sprintf("%.3f", clip.Start),
+			"-t", fmt.Sprintf("%.3f", clip.Duration),
+			"-i", inputFile,
+		)
+		scaled[i] = fmt.Sprintf("[c%d]", i)
+		filters = append(filters, fmt.Sprintf("[%d:v]scale='min(%d,iw)':-2,setsar=1[c%d]", i, width, i))
+	}
+	filters = append(filters, fmt.Sprintf("%sconcat=n=%d:v=1:a=0,split[p0][p1];[p0]fps=10[webp]",
+		strings.Join(scaled, ""), len(clips)))
+
+	return append(args,
+		"-filter_complex", strings.Join(filters, ";"),
+		"-y",
+		"-map", "[webp]",
 		"-c:v", "libwebp",
 		"-loop", "0",
 		"-quality", "60",
-		"-y",
-		outputFile,
-	}
+		webpFile,
+		"-map", "[p1]",
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-crf", "30",
+		"-pix_fmt", "yuv420p",
+		"-movflags", "+faststart",
+		mp4File,
+	)
 }
 
 func (o *VideoOptimizer) GetProbeArgs(inputFile string) []string {
@@ -356,11 +387,19 @@ func (o *VideoOptimizer) GetConcatArgs(listFile, outputFile string) []string {
 	}
 }
 
+// GetTrimArgs copies the span of inputFile from startTime to endTime into
+// outputFile without re-encoding it. Seeking on the input keeps the frames
+// from the keyframe before startTime that its first frames need, and MP4
+// and QuickTime mark them with an edit list so that decoding starts where
+// the span does. Data streams, such as a camera's timecode track, are left
+// out: most muxers cannot copy them.
 func (o *VideoOptimizer) GetTrimArgs(inputFile, outputFile string, startTime, endTime string) []string {
 	return []string{
-		"-i", inputFile,
 		"-ss", startTime,
 		"-to", endTime,
+		"-i", inputFile,
+		"-map", "0",
+		"-map", "-0:d?",
 		"-c", "copy",
 		"-y",
 		outputFile,
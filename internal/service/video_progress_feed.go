@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+)
+
+const videoProgressChannelPrefix = "video:progress:"
+
+// VideoProgressFeed carries processing progress from the worker to the API
+// over Redis pub/sub, one channel per video. It is a live feed and nothing
+// more: a message nobody is subscribed to is dropped, and PostgreSQL stays the
+// record of where a video stands.
+type VideoProgressFeed struct {
+	redis *redis.Client
+}
+
+func NewVideoProgressFeed(redisClient *redis.Client) *VideoProgressFeed {
+	return &VideoProgressFeed{redis: redisClient}
+}
+
+func videoProgressChannel(id uuid.UUID) string {
+	return videoProgressChannelPrefix + id.String()
+}
+
+// Publish announces p to everyone watching its video.
+func (f *VideoProgressFeed) Publish(ctx context.Context, p domain.VideoProgress) error {
+	payload, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("encoding progress: %w", err)
+	}
+	if err := f.redis.Publish(ctx, videoProgressChannel(p.VideoID), payload).Err(); err != nil {
+		return fmt.Errorf("publishing progress for video %s: %w", p.VideoID, err)
+	}
+	return nil
+}
+
+// Subscribe starts watching id. The subscription is confirmed before it is
+// returned, so anything published afterwards is delivered; the caller must
+// Close it.
+func (f *VideoProgressFeed) Subscribe(ctx context.Context, id uuid.UUID) (*VideoProgressSubscription, error) {
+	pubsub := f.redis.Subscribe(ctx, videoProgressChannel(id))
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("subscribing to progress for video %s: %w", id, err)
+	}
+
+	out := make(chan domain.VideoProgress)
+	sub := &VideoProgressSubscription{C: out, pubsub: pubsub, done: make(chan struct{})}
+	go sub.relay(pubsub.Channel(), out)
+	return sub, nil
+}
+
+// VideoProgressSubscription delivers one video's progress on C until it is
+// closed.
+type VideoProgressSubscription struct {
+	C <-chan domain.VideoProgress
+
+	pubsub *redis.PubSub
+	done   chan struct{}
+	once   sync.Once
+}
+
+// relay decodes messages onto out. Malformed ones are skipped: they can only
+// come from something other than the worker publishing on our channel.
+func (s *VideoProgressSubscription) relay(messages <-chan *redis.Message, out chan<- domain.VideoProgress) {
+	defer close(out)
+	for msg := range messages {
+		var p domain.VideoProgress
+		if err := json.Unmarshal([]byte(msg.Payload), &p); err != nil {
+			continue
+		}
+		select {
+		case out <- p:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *VideoProgressSubscription) Close() error {
+	var err error
+	s.once.Do(func() {
+		close(s.done)
+		err = s.pubsub.Close()
+	})
+	return err
+}
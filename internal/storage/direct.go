@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"time"
+)
+
+// DirectUploader is implemented by stores that can take an object straight
+// from the client, so upload bytes never pass through the API process. The
+// shape is S3 multipart: create an upload and get one URL per part, let the
+// client PUT each part and collect the ETags, then complete with those ETags
+// to assemble the object under key.
+//
+// It is optional: callers type-assert a Store and refuse direct uploads, with
+// domain.ErrDirectUploadUnsupported, when the backend cannot do them.
+type DirectUploader interface {
+	CreateDirectUpload(ctx context.Context, key string, size int64, contentType string, expiry time.Duration) (*DirectUpload, error)
+	CompleteDirectUpload(ctx context.Context, key, uploadID string, parts []CompletedPart) error
+	// AbortDirectUpload discards the parts of an upload that will never be
+	// completed. Aborting an upload that is already gone is not an error.
+	AbortDirectUpload(ctx context.Context, key, uploadID string) error
+}
+
+// DirectPartReceiver is implemented by stores whose part URLs point back at
+// the API rather than at a separate object store: the handler behind those
+// URLs hands each request to PutDirectPart, which checks the signature the
+// store put in the URL and returns the part's ETag.
+type DirectPartReceiver interface {
+	PutDirectPart(ctx context.Context, uploadID string, part int, query url.Values, r io.Reader) (etag string, err error)
+}
+
+// DirectUpload is a started multipart upload: the backend's ID for it and a
+// presigned URL for each part. Every part but the last is PartSize bytes.
+type DirectUpload struct {
+	UploadID  string
+	PartSize  int64
+	Parts     []PresignedPart
+	ExpiresAt time.Time
+}
+
+// PresignedPart is where the client PUTs part Number, exactly Size bytes.
+type PresignedPart struct {
+	Number int    `json:"number"`
+	URL    string `json:"url"`
+	Size   int64  `json:"size"`
+}
+
+// CompletedPart is a part the client uploaded, with the ETag the PUT
+// returned.
+type CompletedPart struct {
+	Number int    `json:"number"`
+	ETag   string `json:"etag"`
+}
+
+const (
+	// directPartSize is the default part size: large enough that a typical
+	// video needs few requests, small enough that a failed part is cheap to
+	// resend.
+	directPartSize int64 = 16 << 20
+	// maxDirectParts is S3's limit on parts per multipart upload.
+	maxDirectParts = 10000
+)
+
+// MaxDirectUploadExpiry is the longest a presigned URL may live; S3 signature
+// version 4 refuses anything beyond seven days.
+const MaxDirectUploadExpiry = 7 * 24 * time.Hour
+
+// planParts splits size bytes into parts of directPartSize, growing the part
+// size in whole MiB when the default would need more than maxDirectParts.
+func planParts(size int64) (partSize int64, sizes []int64) {
+	partSize = directPartSize
+	if size > partSize*maxDirectParts {
+		partSize = (size + maxDirectParts - 1) / maxDirectParts
+		partSize = (partSize + 1<<20 - 1) &^ (1<<20 - 1)
+	}
+
+	for remaining := size; remaining > 0; remaining -= partSize {
+		sizes = append(sizes, min(partSize, remaining))
+	}
+	return partSize, sizes
+}
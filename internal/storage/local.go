@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"crypto/rand"
 	"errors"
 	"fmt"
 	"io"
@@ -23,15 +24,32 @@ import (
 type Local struct {
 	root   string
 	mounts map[string]string
+
+	// signingKey and baseURL make the direct-upload part URLs; see
+	// local_direct.go.
+	signingKey []byte
+	baseURL    string
 }
 
 func NewLocal(cfg config.StorageConfig) *Local {
+	signingKey := []byte(cfg.SigningKey)
+	if len(signingKey) == 0 {
+		// Config validation insists on a key in production. Elsewhere a
+		// random one only means URLs die with the process that signed them.
+		signingKey = make([]byte, 32)
+		if _, err := rand.Read(signingKey); err != nil {
+			panic(fmt.Sprintf("storage: generating signing key: %v", err))
+		}
+	}
+
 	return &Local{
 		root: cfg.UploadPath,
 		mounts: map[string]string{
 			"transcoded": cfg.TranscodedPath,
 			"thumbnails": cfg.ThumbnailPath,
 		},
+		signingKey: signingKey,
+		baseURL:    strings.TrimSuffix(cfg.PublicBaseURL, "/"),
 	}
 }
 
@@ -0,0 +1,233 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+)
+
+// LocalDirectPartPath is where the router mounts the handler that receives
+// the local store's direct-upload parts, followed by /<uploadID>/<part>.
+// There is no object store to presign for in development, so the API stands
+// in for one: part URLs point back here, carry an HMAC over everything the
+// PUT may do, and need no bearer token, just as a presigned S3 URL does not.
+const LocalDirectPartPath = "/api/v1/uploads/direct/parts/"
+
+// directPartsKey is the area parts wait in until completion stitches them
+// together. Parts are keyed by upload ID, not by the final key, so a part URL
+// never names a location the client could choose.
+func directPartsKey(uploadID string) string {
+	return Key("raw", "direct", uploadID)
+}
+
+func directPartKey(uploadID string, part int) string {
+	return Key(directPartsKey(uploadID), fmt.Sprintf("%05d", part))
+}
+
+func (l *Local) CreateDirectUpload(ctx context.Context, key string, size int64, contentType string, expiry time.Duration) (*DirectUpload, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	uploadID := uuid.NewString()
+	expiresAt := time.Now().Add(min(expiry, MaxDirectUploadExpiry))
+	partSize, sizes := planParts(size)
+
+	upload := &DirectUpload{
+		UploadID:  uploadID,
+		PartSize:  partSize,
+		Parts:     make([]PresignedPart, len(sizes)),
+		ExpiresAt: expiresAt,
+	}
+	for i, partLen := range sizes {
+		number := i + 1
+		query := url.Values{
+			"expires":   {strconv.FormatInt(expiresAt.Unix(), 10)},
+			"size":      {strconv.FormatInt(partLen, 10)},
+			"signature": {l.signPart(uploadID, number, partLen, expiresAt.Unix())},
+		}
+		upload.Parts[i] = PresignedPart{
+			Number: number,
+			URL:    l.baseURL + LocalDirectPartPath + uploadID + "/" + strconv.Itoa(number) + "?" + query.Encode(),
+			Size:   partLen,
+		}
+	}
+	return upload, nil
+}
+
+// PutDirectPart stores one part sent to a URL from CreateDirectUpload. The
+// part must be exactly the size that was signed, and its ETag is the hex MD5
+// of its bytes, quoted, as S3 reports it for a single-part PUT.
+func (l *Local) PutDirectPart(ctx context.Context, uploadID string, part int, query url.Values, r io.Reader) (string, error) {
+	size, err := l.verifyPart(uploadID, part, query)
+	if err != nil {
+		return "", err
+	}
+
+	path, err := l.resolve(directPartKey(uploadID, part))
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("creating directory for upload %s: %w", uploadID, err)
+	}
+
+	// Write beside the final name and rename into place, so a retried PUT
+	// racing a dropped one never leaves a half-written part under the name
+	// completion reads.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".part-*")
+	if err != nil {
+		return "", fmt.Errorf("creating part %d of upload %s: %w", part, uploadID, err)
+	}
+	hash := md5.New()
+	written, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(r, size+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("writing part %d of upload %s: %w", part, uploadID, err)
+	}
+	if written != size {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("%w: part %d must be %d bytes", domain.ErrDirectUploadPartInvalid, part, size)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("storing part %d of upload %s: %w", part, uploadID, err)
+	}
+
+	return `"` + hex.EncodeToString(hash.Sum(nil)) + `"`, nil
+}
+
+// verifyPart checks a part URL's signature and expiry and returns the size it
+// was signed for.
+func (l *Local) verifyPart(uploadID string, part int, query url.Values) (int64, error) {
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return 0, domain.ErrStorageSignatureInvalid
+	}
+	size, err := strconv.ParseInt(query.Get("size"), 10, 64)
+	if err != nil || size <= 0 {
+		return 0, domain.ErrStorageSignatureInvalid
+	}
+	want, err := hex.DecodeString(query.Get("signature"))
+	if err != nil {
+		return 0, domain.ErrStorageSignatureInvalid
+	}
+	got, _ := hex.DecodeString(l.signPart(uploadID, part, size, expires))
+	if !hmac.Equal(got, want) || time.Now().Unix() >= expires {
+		return 0, domain.ErrStorageSignatureInvalid
+	}
+	return size, nil
+}
+
+func (l *Local) signPart(uploadID string, part int, size, expires int64) string {
+	mac := hmac.New(sha256.New, l.signingKey)
+	fmt.Fprintf(mac, "PUT\n%s\n%d\n%d\n%d", uploadID, part, size, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// CompleteDirectUpload concatenates the listed parts into key, checking each
+// against the ETag the client reported, and removes them. Parts must be listed
+// in ascending order. An upload with no parts stored at all is reported as
+// domain.ErrStorageObjectNotFound, as S3 reports an unknown upload ID.
+func (l *Local) CompleteDirectUpload(ctx context.Context, key, uploadID string, parts []CompletedPart) error {
+	dest, err := l.resolve(key)
+	if err != nil {
+		return err
+	}
+	partsDir, err := l.resolve(directPartsKey(uploadID))
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(partsDir); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w: direct upload %s", domain.ErrStorageObjectNotFound, uploadID)
+		}
+		return fmt.Errorf("statting upload %s: %w", uploadID, err)
+	}
+	if len(parts) == 0 {
+		return fmt.Errorf("%w: no parts listed", domain.ErrDirectUploadPartInvalid)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return fmt.Errorf("creating directory for %s: %w", key, err)
+	}
+	out, err := os.CreateTemp(filepath.Dir(dest), ".assemble-*")
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", key, err)
+	}
+	assembled := false
+	defer func() {
+		if !assembled {
+			out.Close()
+			os.Remove(out.Name())
+		}
+	}()
+
+	for i, part := range parts {
+		if part.Number < 1 || (i > 0 && part.Number <= parts[i-1].Number) {
+			return fmt.Errorf("%w: parts must be listed in ascending order", domain.ErrDirectUploadPartInvalid)
+		}
+		if err := l.appendPart(out, uploadID, part); err != nil {
+			return err
+		}
+	}
+
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("flushing %s: %w", key, err)
+	}
+	if err := os.Rename(out.Name(), dest); err != nil {
+		return fmt.Errorf("storing %s: %w", key, err)
+	}
+	assembled = true
+
+	if err := os.RemoveAll(partsDir); err != nil {
+		return fmt.Errorf("removing parts of upload %s: %w", uploadID, err)
+	}
+	return nil
+}
+
+func (l *Local) appendPart(out io.Writer, uploadID string, part CompletedPart) error {
+	path, err := l.resolve(directPartKey(uploadID, part.Number))
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w: part %d was never uploaded", domain.ErrDirectUploadPartInvalid, part.Number)
+		}
+		return fmt.Errorf("opening part %d of upload %s: %w", part.Number, uploadID, err)
+	}
+	defer f.Close()
+
+	hash := md5.New()
+	if _, err := io.Copy(io.MultiWriter(out, hash), f); err != nil {
+		return fmt.Errorf("copying part %d of upload %s: %w", part.Number, uploadID, err)
+	}
+	if strings.Trim(part.ETag, `"`) != hex.EncodeToString(hash.Sum(nil)) {
+		return fmt.Errorf("%w: ETag for part %d does not match", domain.ErrDirectUploadPartInvalid, part.Number)
+	}
+	return nil
+}
+
+func (l *Local) AbortDirectUpload(ctx context.Context, key, uploadID string) error {
+	return l.DeletePrefix(ctx, directPartsKey(uploadID))
+}
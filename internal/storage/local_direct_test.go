@@ -0,0 +1,228 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+)
+
+func TestPlanParts(t *testing.T) {
+	tests := []struct {
+		name      string
+		size      int64
+		wantSize  int64
+		wantParts int
+		wantLast  int64
+	}{
+		{name: "smaller than one part", size: 1000, wantSize: directPartSize, wantParts: 1, wantLast: 1000},
+		{name: "exact multiple", size: 3 * directPartSize, wantSize: directPartSize, wantParts: 3, wantLast: directPartSize},
+		{name: "short last part", size: 2*directPartSize + 5, wantSize: directPartSize, wantParts: 3, wantLast: 5},
+		// 200 GiB at 16 MiB would be 12800 parts; the part size grows to
+		// fit S3's 10000-part limit, rounded up to a whole MiB.
+		{name: "grows past the part limit", size: 200 << 30, wantSize: 21 << 20, wantParts: 9753, wantLast: (200 << 30) - 9752*(21<<20)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			partSize, sizes := planParts(tt.size)
+			if partSize != tt.wantSize {
+				t.Errorf("part size = %d, want %d", partSize, tt.wantSize)
+			}
+			if len(sizes) != tt.wantParts || len(sizes) > maxDirectParts {
+				t.Fatalf("%d parts, want %d", len(sizes), tt.wantParts)
+			}
+			if last := sizes[len(sizes)-1]; last != tt.wantLast {
+				t.Errorf("last part = %d bytes, want %d", last, tt.wantLast)
+			}
+			var total int64
+			for _, size := range sizes {
+				total += size
+			}
+			if total != tt.size {
+				t.Errorf("parts add up to %d, want %d", total, tt.size)
+			}
+		})
+	}
+}
+
+// putPart sends body to a part URL the way the router's part handler would.
+func putPart(t *testing.T, store *Local, part PresignedPart, body []byte) (string, error) {
+	t.Helper()
+	u, err := url.Parse(part.URL)
+	if err != nil {
+		t.Fatalf("part URL %q: %v", part.URL, err)
+	}
+	rest, ok := strings.CutPrefix(u.Path, LocalDirectPartPath)
+	if !ok {
+		t.Fatalf("part URL %q is not under %s", part.URL, LocalDirectPartPath)
+	}
+	uploadID, number, _ := strings.Cut(rest, "/")
+	n, err := strconv.Atoi(number)
+	if err != nil {
+		t.Fatalf("part URL %q has no part number", part.URL)
+	}
+	return store.PutDirectPart(context.Background(), uploadID, n, u.Query(), bytes.NewReader(body))
+}
+
+func TestLocalDirectUploadRoundTrip(t *testing.T) {
+	store, root := newTestLocal(t)
+	store.baseURL = "https://api.example.com"
+	ctx := context.Background()
+
+	content := bytes.Repeat([]byte("0123456789abcdef"), int(directPartSize/16)+1)
+	key := Key("raw", "direct.mp4")
+
+	upload, err := store.CreateDirectUpload(ctx, key, int64(len(content)), "video/mp4", time.Hour)
+	if err != nil {
+		t.Fatalf("CreateDirectUpload: %v", err)
+	}
+	if len(upload.Parts) != 2 {
+		t.Fatalf("%d parts, want 2", len(upload.Parts))
+	}
+	if !strings.HasPrefix(upload.Parts[0].URL, "https://api.example.com"+LocalDirectPartPath) {
+		t.Fatalf("part URL %q is not absolute under the public base URL", upload.Parts[0].URL)
+	}
+
+	var completed []CompletedPart
+	offset := int64(0)
+	for _, part := range upload.Parts {
+		etag, err := putPart(t, store, part, content[offset:offset+part.Size])
+		if err != nil {
+			t.Fatalf("PutDirectPart(%d): %v", part.Number, err)
+		}
+		completed = append(completed, CompletedPart{Number: part.Number, ETag: etag})
+		offset += part.Size
+	}
+
+	if err := store.CompleteDirectUpload(ctx, key, upload.UploadID, completed); err != nil {
+		t.Fatalf("CompleteDirectUpload: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(root, "raw", "direct.mp4"))
+	if err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("assembled object differs from what was sent (err=%v, %d bytes)", err, len(got))
+	}
+	if _, err := os.Stat(filepath.Join(root, "raw", "direct", upload.UploadID)); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("parts left behind after completion: %v", err)
+	}
+
+	// Completing again finds no upload, which is how S3 reports it too.
+	if err := store.CompleteDirectUpload(ctx, key, upload.UploadID, completed); !errors.Is(err, domain.ErrStorageObjectNotFound) {
+		t.Fatalf("repeated CompleteDirectUpload = %v, want ErrStorageObjectNotFound", err)
+	}
+}
+
+func TestLocalDirectPartRejectsTampering(t *testing.T) {
+	store, _ := newTestLocal(t)
+	ctx := context.Background()
+
+	upload, err := store.CreateDirectUpload(ctx, Key("raw", "v.mp4"), 10, "video/mp4", time.Hour)
+	if err != nil {
+		t.Fatalf("CreateDirectUpload: %v", err)
+	}
+	part := upload.Parts[0]
+
+	tamper := func(mutate func(q url.Values)) PresignedPart {
+		u, _ := url.Parse(part.URL)
+		q := u.Query()
+		mutate(q)
+		u.RawQuery = q.Encode()
+		return PresignedPart{Number: part.Number, URL: u.String(), Size: part.Size}
+	}
+
+	cases := map[string]PresignedPart{
+		"size raised":       tamper(func(q url.Values) { q.Set("size", "1000000") }),
+		"expiry extended":   tamper(func(q url.Values) { q.Set("expires", strconv.FormatInt(time.Now().Add(48*time.Hour).Unix(), 10)) }),
+		"signature missing": tamper(func(q url.Values) { q.Del("signature") }),
+		"other part":        {Number: 2, URL: strings.Replace(part.URL, "/1?", "/2?", 1), Size: part.Size},
+	}
+	for name, p := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := putPart(t, store, p, []byte("0123456789")); !errors.Is(err, domain.ErrStorageSignatureInvalid) {
+				t.Fatalf("PutDirectPart = %v, want ErrStorageSignatureInvalid", err)
+			}
+		})
+	}
+
+	t.Run("expired", func(t *testing.T) {
+		expired, err := store.CreateDirectUpload(ctx, Key("raw", "v.mp4"), 10, "video/mp4", -time.Second)
+		if err != nil {
+			t.Fatalf("CreateDirectUpload: %v", err)
+		}
+		if _, err := putPart(t, store, expired.Parts[0], []byte("0123456789")); !errors.Is(err, domain.ErrStorageSignatureInvalid) {
+			t.Fatalf("PutDirectPart = %v, want ErrStorageSignatureInvalid", err)
+		}
+	})
+
+	t.Run("wrong size body", func(t *testing.T) {
+		for _, body := range []string{"short", "much too long for the part"} {
+			if _, err := putPart(t, store, part, []byte(body)); !errors.Is(err, domain.ErrDirectUploadPartInvalid) {
+				t.Fatalf("PutDirectPart(%q) = %v, want ErrDirectUploadPartInvalid", body, err)
+			}
+		}
+	})
+}
+
+func TestLocalDirectCompleteChecksETags(t *testing.T) {
+	store, root := newTestLocal(t)
+	ctx := context.Background()
+	key := Key("raw", "v.mp4")
+
+	upload, err := store.CreateDirectUpload(ctx, key, 10, "video/mp4", time.Hour)
+	if err != nil {
+		t.Fatalf("CreateDirectUpload: %v", err)
+	}
+	if _, err := putPart(t, store, upload.Parts[0], []byte("0123456789")); err != nil {
+		t.Fatalf("PutDirectPart: %v", err)
+	}
+
+	err = store.CompleteDirectUpload(ctx, key, upload.UploadID, []CompletedPart{{Number: 1, ETag: `"00000000000000000000000000000000"`}})
+	if !errors.Is(err, domain.ErrDirectUploadPartInvalid) {
+		t.Fatalf("CompleteDirectUpload with a wrong ETag = %v, want ErrDirectUploadPartInvalid", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "raw", "v.mp4")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("a failed completion left an object behind: %v", err)
+	}
+
+	if err := store.AbortDirectUpload(ctx, key, upload.UploadID); err != nil {
+		t.Fatalf("AbortDirectUpload: %v", err)
+	}
+	if err := store.CompleteDirectUpload(ctx, key, upload.UploadID, nil); !errors.Is(err, domain.ErrStorageObjectNotFound) {
+		t.Fatalf("CompleteDirectUpload after abort = %v, want ErrStorageObjectNotFound", err)
+	}
+}
+
+// The part handler reads the body straight off the request; make sure a
+// reader that errors midway leaves no part behind for completion to pick up.
+func TestLocalDirectPartDiscardsFailedWrite(t *testing.T) {
+	store, root := newTestLocal(t)
+	upload, err := store.CreateDirectUpload(context.Background(), Key("raw", "v.mp4"), 10, "video/mp4", time.Hour)
+	if err != nil {
+		t.Fatalf("CreateDirectUpload: %v", err)
+	}
+
+	u, _ := url.Parse(upload.Parts[0].URL)
+	body := io.MultiReader(strings.NewReader("01234"), errReader{})
+	if _, err := store.PutDirectPart(context.Background(), upload.UploadID, 1, u.Query(), body); err == nil {
+		t.Fatal("PutDirectPart with a failing body succeeded")
+	}
+
+	entries, _ := os.ReadDir(filepath.Join(root, "raw", "direct", upload.UploadID))
+	if len(entries) != 0 {
+		t.Fatalf("failed part write left %d files behind", len(entries))
+	}
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) { return 0, errors.New("connection reset") }
@@ -4,7 +4,11 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
@@ -18,21 +22,43 @@ import (
 // stays the three-bucket one the config describes, with the processed and
 // thumbnail buckets world-readable for players and <img> tags.
 type MinIO struct {
-	client  *minio.Client
-	buckets map[string]string
+	client *minio.Client
+	// core exposes the multipart calls direct uploads are built from.
+	core *minio.Core
+	// presigner signs URLs for the endpoint clients use, which need not be
+	// the one the API talks to; it never makes a request itself.
+	presigner *minio.Client
+	buckets   map[string]string
 }
 
 func NewMinIO(cfg config.MinIOConfig) (*MinIO, error) {
+	creds := credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey, "")
 	client, err := minio.New(cfg.Endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
+		Creds:  creds,
 		Secure: cfg.UseSSL,
+		Region: cfg.Region,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("creating MinIO client: %w", err)
 	}
 
+	publicEndpoint := cfg.PublicEndpoint
+	if publicEndpoint == "" {
+		publicEndpoint = cfg.Endpoint
+	}
+	presigner, err := minio.New(publicEndpoint, &minio.Options{
+		Creds:  creds,
+		Secure: cfg.PublicUseSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating MinIO presigning client: %w", err)
+	}
+
 	store := &MinIO{
-		client: client,
+		client:    client,
+		core:      &minio.Core{Client: client},
+		presigner: presigner,
 		buckets: map[string]string{
 			"raw":        cfg.BucketRaw,
 			"transcoded": cfg.BucketProcessed,
@@ -207,6 +233,80 @@ func (s *MinIO) Exists(ctx context.Context, key string) (bool, error) {
 	return true, nil
 }
 
+func (s *MinIO) CreateDirectUpload(ctx context.Context, key string, size int64, contentType string, expiry time.Duration) (*DirectUpload, error) {
+	bucket, object, err := s.locate(key)
+	if err != nil {
+		return nil, err
+	}
+	expiry = min(expiry, MaxDirectUploadExpiry)
+
+	uploadID, err := s.core.NewMultipartUpload(ctx, bucket, object, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("starting multipart upload of %s: %w", key, err)
+	}
+
+	partSize, sizes := planParts(size)
+	upload := &DirectUpload{
+		UploadID:  uploadID,
+		PartSize:  partSize,
+		Parts:     make([]PresignedPart, len(sizes)),
+		ExpiresAt: time.Now().Add(expiry),
+	}
+	for i, partLen := range sizes {
+		number := i + 1
+		u, err := s.presigner.Presign(ctx, http.MethodPut, bucket, object, expiry, url.Values{
+			"partNumber": {strconv.Itoa(number)},
+			"uploadId":   {uploadID},
+		})
+		if err != nil {
+			s.core.AbortMultipartUpload(ctx, bucket, object, uploadID)
+			return nil, fmt.Errorf("presigning part %d of %s: %w", number, key, err)
+		}
+		upload.Parts[i] = PresignedPart{Number: number, URL: u.String(), Size: partLen}
+	}
+	return upload, nil
+}
+
+func (s *MinIO) CompleteDirectUpload(ctx context.Context, key, uploadID string, parts []CompletedPart) error {
+	bucket, object, err := s.locate(key)
+	if err != nil {
+		return err
+	}
+	if len(parts) == 0 {
+		return fmt.Errorf("%w: no parts listed", domain.ErrDirectUploadPartInvalid)
+	}
+
+	completed := make([]minio.CompletePart, len(parts))
+	for i, part := range parts {
+		completed[i] = minio.CompletePart{PartNumber: part.Number, ETag: part.ETag}
+	}
+
+	if _, err := s.core.CompleteMultipartUpload(ctx, bucket, object, uploadID, completed, minio.PutObjectOptions{}); err != nil {
+		switch minio.ToErrorResponse(err).Code {
+		case "NoSuchUpload":
+			return fmt.Errorf("%w: multipart upload %s", domain.ErrStorageObjectNotFound, uploadID)
+		case "InvalidPart", "InvalidPartOrder", "EntityTooSmall":
+			return fmt.Errorf("%w: %v", domain.ErrDirectUploadPartInvalid, err)
+		}
+		return fmt.Errorf("completing multipart upload of %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *MinIO) AbortDirectUpload(ctx context.Context, key, uploadID string) error {
+	bucket, object, err := s.locate(key)
+	if err != nil {
+		return err
+	}
+
+	if err := s.core.AbortMultipartUpload(ctx, bucket, object, uploadID); err != nil && minio.ToErrorResponse(err).Code != "NoSuchUpload" {
+		return fmt.Errorf("aborting multipart upload of %s: %w", key, err)
+	}
+	return nil
+}
+
 func isNoSuchKey(err error) bool {
 	return minio.ToErrorResponse(err).Code == "NoSuchKey"
 }
@@ -0,0 +1,323 @@
+package storage
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/metrics"
+)
+
+// segmentCacheType labels the segment cache's hits, misses and evictions.
+const segmentCacheType = "segment"
+
+// segmentFillTimeout bounds one backend read into the cache. The read is
+// shared by every request waiting on it, so it runs apart from any one
+// request's context, and this is what stops a stuck backend holding them all.
+const segmentFillTimeout = time.Minute
+
+// cacheableSegment matches the objects SegmentCache keeps: a variant's media
+// segments and init segment. They are written once under a key and never
+// change, since a video processed again is written to a new output revision.
+// Playlists are not among them, as the master playlist gains entries, and
+// CacheService keeps them anyway; nor are progressive MP4s, which are
+// large, read in ranges, and rarely hot.
+var cacheableSegment = regexp.MustCompile(`^(segment_\d+\.(ts|m4s)|init_[a-z0-9][a-z0-9_-]*\.mp4)$`)
+
+// SegmentCache is a Store that keeps copies of the media segments read
+// through it on local disk, up to a size, evicting the least recently used.
+// It is meant to sit in front of MinIO, where every segment request would
+// otherwise be a round trip to the object store.
+//
+// A segment not yet cached is read from the backend once however many
+// requests want it at the same time: the first starts the read, and the rest
+// wait for it and are served from the copy it leaves.
+//
+// Everything but segments passes straight through. Writes and deletes through
+// the cache drop what it holds for the keys they touch; a process writing to
+// the backend directly, like the worker, cannot, and Purge is for dropping
+// what it has made stale.
+type SegmentCache struct {
+	Store
+	dir string
+	max int64
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// lru holds *segmentEntry, the most recently used at the front.
+	lru   *list.List
+	size  int64
+	fills map[string]*segmentFill
+}
+
+type segmentEntry struct {
+	key  string
+	path string
+	info FileInfo
+}
+
+// segmentFill is a backend read in progress. done is closed when it is over,
+// leaving the entry it cached, or err, or for an object too large to cache
+// only its info.
+//
+// stale, guarded by SegmentCache.mu, marks a fill whose key was dropped
+// while it read: what it read may be what the drop was for, so it is not
+// cached, and its waiters go to the backend.
+type segmentFill struct {
+	done  chan struct{}
+	entry *segmentEntry
+	info  FileInfo
+	err   error
+	stale bool
+}
+
+// NewSegmentCache puts a cache of at most maxBytes in dir in front of
+// backend. The cache keeps its files in a directory of its own under dir,
+// emptied here: what a previous process cached is not known to this one.
+func NewSegmentCache(backend Store, dir string, maxBytes int64) (*SegmentCache, error) {
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("segment cache size must be positive, got %d", maxBytes)
+	}
+	dir = filepath.Join(dir, "segments")
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, fmt.Errorf("clearing segment cache: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating segment cache: %w", err)
+	}
+	metrics.SetSegmentCacheBytes(0)
+	return &SegmentCache{
+		Store:   backend,
+		dir:     dir,
+		max:     maxBytes,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		fills:   make(map[string]*segmentFill),
+	}, nil
+}
+
+func isCacheableSegment(key string) bool {
+	return strings.HasPrefix(key, "transcoded/") && cacheableSegment.MatchString(path.Base(key))
+}
+
+func (s *SegmentCache) Open(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	if !isCacheableSegment(key) {
+		return s.Store.Open(ctx, key)
+	}
+	entry, _, err := s.get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return s.Store.Open(ctx, key)
+	}
+	f, err := os.Open(entry.path)
+	if err != nil {
+		// Evicted between the lookup and here; the backend still has it.
+		return s.Store.Open(ctx, key)
+	}
+	return f, nil
+}
+
+// Stat fetches a segment into the cache, not just its metadata: a segment is
+// statted by a request that is about to read it.
+func (s *SegmentCache) Stat(ctx context.Context, key string) (FileInfo, error) {
+	if !isCacheableSegment(key) {
+		return s.Store.Stat(ctx, key)
+	}
+	_, info, err := s.get(ctx, key)
+	return info, err
+}
+
+func (s *SegmentCache) Save(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	defer s.drop(func(k string) bool { return k == key })
+	return s.Store.Save(ctx, key, r, size, contentType)
+}
+
+func (s *SegmentCache) Delete(ctx context.Context, key string) error {
+	defer s.drop(func(k string) bool { return k == key })
+	return s.Store.Delete(ctx, key)
+}
+
+func (s *SegmentCache) DeletePrefix(ctx context.Context, prefix string) error {
+	defer s.Purge(prefix)
+	return s.Store.DeletePrefix(ctx, prefix)
+}
+
+// Purge drops every cached segment under prefix, a key prefix such as
+// "transcoded/<video id>", and returns how many it dropped. The next request
+// for one reads it from the backend again.
+func (s *SegmentCache) Purge(prefix string) int {
+	prefix = strings.TrimSuffix(prefix, "/") + "/"
+	return s.drop(func(k string) bool { return strings.HasPrefix(k, prefix) })
+}
+
+// get returns the cached entry for key, reading it from the backend first if
+// need be. An object too large to cache comes back with a nil entry and only
+// its info.
+func (s *SegmentCache) get(ctx context.Context, key string) (*segmentEntry, FileInfo, error) {
+	s.mu.Lock()
+	if el, ok := s.entries[key]; ok {
+		s.lru.MoveToFront(el)
+		entry := el.Value.(*segmentEntry)
+		s.mu.Unlock()
+		metrics.RecordCacheHit(segmentCacheType)
+		return entry, entry.info, nil
+	}
+	fill, waiting := s.fills[key]
+	if !waiting {
+		fill = &segmentFill{done: make(chan struct{})}
+		s.fills[key] = fill
+	}
+	s.mu.Unlock()
+	metrics.RecordCacheMiss(segmentCacheType)
+
+	if !waiting {
+		// Not the request's context: the read is every waiter's.
+		fillCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), segmentFillTimeout)
+		s.fill(fillCtx, key, fill)
+		cancel()
+	}
+
+	select {
+	case <-fill.done:
+	case <-ctx.Done():
+		return nil, FileInfo{}, ctx.Err()
+	}
+	if fill.err != nil {
+		return nil, FileInfo{}, fill.err
+	}
+	if fill.entry != nil {
+		return fill.entry, fill.entry.info, nil
+	}
+	return nil, fill.info, nil
+}
+
+// fill reads key from the backend into the cache and finishes fill.
+func (s *SegmentCache) fill(ctx context.Context, key string, fill *segmentFill) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.fills, key)
+		if fill.entry != nil && fill.stale {
+			os.Remove(fill.entry.path)
+			fill.entry = nil
+		}
+		if fill.entry != nil {
+			s.entries[key] = s.lru.PushFront(fill.entry)
+			s.size += fill.entry.info.Size
+			s.evict()
+		}
+		s.mu.Unlock()
+		close(fill.done)
+	}()
+
+	info, err := s.Store.Stat(ctx, key)
+	if err != nil {
+		fill.err = err
+		return
+	}
+	fill.info = info
+	// One object may not take more than a small share of the cache, or a
+	// few would flush everything else.
+	if info.Size > s.max/8 {
+		return
+	}
+
+	src, err := s.Store.Open(ctx, key)
+	if err != nil {
+		fill.err = err
+		return
+	}
+	defer src.Close()
+
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	tmp, err := os.CreateTemp(s.dir, name+".*.tmp")
+	if err != nil {
+		fill.err = fmt.Errorf("caching %s: %w", key, err)
+		return
+	}
+	written, err := io.Copy(tmp, src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && written != info.Size {
+		err = fmt.Errorf("read %d bytes of %d", written, info.Size)
+	}
+	// Each fill gets a file of its own, so one that replaces an entry being
+	// read never truncates it under its reader.
+	if err == nil {
+		err = os.Rename(tmp.Name(), strings.TrimSuffix(tmp.Name(), ".tmp"))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		fill.err = fmt.Errorf("caching %s: %w", key, err)
+		return
+	}
+	fill.entry = &segmentEntry{key: key, path: strings.TrimSuffix(tmp.Name(), ".tmp"), info: info}
+}
+
+// evict removes the least recently used entries until the cache fits. The
+// caller holds s.mu. A file removed while a request is reading it stays
+// readable to that request.
+func (s *SegmentCache) evict() {
+	for s.size > s.max {
+		el := s.lru.Back()
+		if el == nil {
+			break
+		}
+		s.remove(el)
+		metrics.RecordCacheEviction(segmentCacheType)
+	}
+	metrics.SetSegmentCacheBytes(s.size)
+}
+
+// drop removes every entry whose key matches, and returns how many. A fill
+// of a matching key still reading is marked stale, so it does not put back
+// what was just dropped.
+func (s *SegmentCache) drop(match func(key string) bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, fill := range s.fills {
+		if match(key) {
+			fill.stale = true
+		}
+	}
+	dropped := 0
+	for key, el := range s.entries {
+		if match(key) {
+			s.remove(el)
+			dropped++
+		}
+	}
+	metrics.SetSegmentCacheBytes(s.size)
+	return dropped
+}
+
+func (s *SegmentCache) remove(el *list.Element) {
+	entry := s.lru.Remove(el).(*segmentEntry)
+	delete(s.entries, entry.key)
+	s.size -= entry.info.Size
+	// A file that cannot be removed costs only disk space; the entry is gone
+	// either way.
+	os.Remove(entry.path)
+}
+
+// PurgeCached drops what s caches under prefix, when s is a SegmentCache,
+// and returns how many objects it dropped.
+func PurgeCached(s Store, prefix string) int {
+	if cache, ok := s.(*SegmentCache); ok {
+		return cache.Purge(prefix)
+	}
+	return 0
+}
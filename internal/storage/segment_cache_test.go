@@ -0,0 +1,244 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Nuu-maan/video-streaming-service/internal/domain"
+)
+
+// countingStore counts the objects opened on a backend, and can hold the
+// opens until released.
+type countingStore struct {
+	Store
+	opens atomic.Int32
+	gate  chan struct{}
+}
+
+func (s *countingStore) Open(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	s.opens.Add(1)
+	if s.gate != nil {
+		<-s.gate
+	}
+	return s.Store.Open(ctx, key)
+}
+
+func newTestSegmentCache(t *testing.T, maxBytes int64) (*SegmentCache, *countingStore) {
+	t.Helper()
+	local, _ := newTestLocal(t)
+	backend := &countingStore{Store: local}
+	cache, err := NewSegmentCache(backend, t.TempDir(), maxBytes)
+	if err != nil {
+		t.Fatalf("NewSegmentCache: %v", err)
+	}
+	return cache, backend
+}
+
+func put(t *testing.T, s Store, key, body string) {
+	t.Helper()
+	if err := s.Save(context.Background(), key, strings.NewReader(body), int64(len(body)), "video/mp2t"); err != nil {
+		t.Fatalf("Save(%q): %v", key, err)
+	}
+}
+
+func read(t *testing.T, s Store, key string) string {
+	t.Helper()
+	r, err := s.Open(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Open(%q): %v", key, err)
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading %q: %v", key, err)
+	}
+	return string(b)
+}
+
+func TestSegmentCacheServesRepeatReadsLocally(t *testing.T) {
+	cache, backend := newTestSegmentCache(t, 1<<20)
+	key := "transcoded/v1/720p/segment_000.ts"
+	put(t, backend.Store, key, "segment bytes")
+
+	for range 3 {
+		if got := read(t, cache, key); got != "segment bytes" {
+			t.Fatalf("Open = %q", got)
+		}
+	}
+	info, err := cache.Stat(context.Background(), key)
+	if err != nil || info.Size != int64(len("segment bytes")) {
+		t.Fatalf("Stat = %+v, %v", info, err)
+	}
+	if n := backend.opens.Load(); n != 1 {
+		t.Fatalf("backend opened %d times, want 1", n)
+	}
+}
+
+func TestSegmentCachePassesOtherObjectsThrough(t *testing.T) {
+	cache, backend := newTestSegmentCache(t, 1<<20)
+	keys := []string{
+		"transcoded/v1/720p/playlist.m3u8",
+		"transcoded/v1/master.m3u8",
+		"transcoded/v1/720p.mp4",
+		"transcoded/v1/captions/en.vtt",
+		"raw/segment_000.ts",
+	}
+	for _, key := range keys {
+		put(t, backend.Store, key, "x")
+		read(t, cache, key)
+		read(t, cache, key)
+	}
+	if n := backend.opens.Load(); n != int32(2*len(keys)) {
+		t.Fatalf("backend opened %d times, want %d", n, 2*len(keys))
+	}
+}
+
+func TestSegmentCacheCoalescesConcurrentMisses(t *testing.T) {
+	cache, backend := newTestSegmentCache(t, 1<<20)
+	key := "transcoded/v1/720p/segment_007.m4s"
+	put(t, backend.Store, key, "hot segment")
+	backend.gate = make(chan struct{})
+
+	const viewers = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, viewers)
+	for range viewers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, err := cache.Open(context.Background(), key)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer r.Close()
+			if b, _ := io.ReadAll(r); string(b) != "hot segment" {
+				errs <- errors.New("read " + string(b))
+			}
+		}()
+	}
+	close(backend.gate)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if n := backend.opens.Load(); n != 1 {
+		t.Fatalf("backend opened %d times for %d viewers, want 1", n, viewers)
+	}
+}
+
+func TestSegmentCachePurgeDuringFill(t *testing.T) {
+	cache, backend := newTestSegmentCache(t, 1<<20)
+	key := "transcoded/v1/720p/segment_000.ts"
+	put(t, backend.Store, key, "old")
+	backend.gate = make(chan struct{})
+
+	got := make(chan string)
+	go func() { got <- read(t, cache, key) }()
+	// The fill has statted the old segment and is held opening it.
+	for backend.opens.Load() == 0 {
+		runtime.Gosched()
+	}
+	// The video is processed again and its segments purged before the
+	// fill finishes.
+	put(t, backend.Store, key, "new")
+	cache.Purge("transcoded/v1")
+	close(backend.gate)
+	<-got
+
+	if len(cache.entries) != 0 {
+		t.Fatalf("the fill cached %d entries after the purge", len(cache.entries))
+	}
+	if body := read(t, cache, key); body != "new" {
+		t.Fatalf("Open after the purge = %q, want the new segment", body)
+	}
+	if files, _ := os.ReadDir(cache.dir); len(files) != 1 {
+		t.Fatalf("cache directory holds %d files, want the new segment's alone", len(files))
+	}
+}
+
+func TestSegmentCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	// Room for eight eight-byte segments, each the most one may take.
+	cache, backend := newTestSegmentCache(t, 64)
+	seg := func(i int) string { return fmt.Sprintf("transcoded/v1/720p/segment_%03d.ts", i) }
+	for i := range 9 {
+		put(t, backend.Store, seg(i), "8 bytes!")
+	}
+	for i := range 8 {
+		read(t, cache, seg(i))
+	}
+	// Segment 0 is used again, leaving segment 1 the least recently used
+	// when segment 8 needs room.
+	read(t, cache, seg(0))
+	read(t, cache, seg(8))
+
+	before := backend.opens.Load()
+	read(t, cache, seg(0))
+	read(t, cache, seg(2))
+	if n := backend.opens.Load() - before; n != 0 {
+		t.Fatalf("backend opened %d times for segments still cached", n)
+	}
+	read(t, cache, seg(1))
+	if n := backend.opens.Load() - before; n != 1 {
+		t.Fatalf("backend opened %d times for the evicted segment, want 1", n)
+	}
+	if cache.size > cache.max {
+		t.Fatalf("cache holds %d bytes, more than its %d", cache.size, cache.max)
+	}
+}
+
+func TestSegmentCacheSkipsLargeObjects(t *testing.T) {
+	cache, backend := newTestSegmentCache(t, 64)
+	key := "transcoded/v1/720p/segment_000.ts"
+	put(t, backend.Store, key, "nine byte")
+
+	for range 2 {
+		if got := read(t, cache, key); got != "nine byte" {
+			t.Fatalf("Open = %q", got)
+		}
+	}
+	if n := backend.opens.Load(); n != 2 {
+		t.Fatalf("backend opened %d times, want every read to reach it", n)
+	}
+	if len(cache.entries) != 0 {
+		t.Fatalf("cache kept %d entries", len(cache.entries))
+	}
+}
+
+func TestSegmentCachePurgeAndDelete(t *testing.T) {
+	cache, backend := newTestSegmentCache(t, 1<<20)
+	seg := func(video, n string) string { return "transcoded/" + video + "/720p/segment_" + n + ".ts" }
+	for _, key := range []string{seg("v1", "000"), seg("v1", "001"), seg("v10", "000"), seg("v2", "000")} {
+		put(t, backend.Store, key, "x")
+		read(t, cache, key)
+	}
+
+	if n := cache.Purge("transcoded/v1"); n != 2 {
+		t.Fatalf("Purge dropped %d segments, want 2", n)
+	}
+	before := backend.opens.Load()
+	read(t, cache, seg("v10", "000"))
+	read(t, cache, seg("v1", "000"))
+	if n := backend.opens.Load() - before; n != 1 {
+		t.Fatalf("backend opened %d times after the purge, want 1 for v1 alone", n)
+	}
+
+	if err := cache.Delete(context.Background(), seg("v2", "000")); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := cache.Open(context.Background(), seg("v2", "000")); !errors.Is(err, domain.ErrStorageObjectNotFound) {
+		t.Fatalf("Open after Delete = %v, want not found", err)
+	}
+	if n := PurgeCached(backend, "transcoded/v1"); n != 0 {
+		t.Fatalf("PurgeCached on an uncached store = %d", n)
+	}
+}
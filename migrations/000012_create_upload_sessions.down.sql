@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS update_upload_sessions_updated_at ON upload_sessions;
+DROP INDEX IF EXISTS idx_upload_sessions_expires_at;
+DROP INDEX IF EXISTS idx_upload_sessions_user;
+DROP TABLE IF EXISTS upload_sessions;
@@ -0,0 +1,30 @@
+-- Resumable (tus) upload sessions. A session tracks how many bytes of a
+-- declared upload_length have been committed to storage; each committed chunk
+-- is stored as its own object, and parts records the offset every one of them
+-- starts at so completion can stitch them back together in order.
+CREATE TABLE IF NOT EXISTS upload_sessions (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    filename VARCHAR(500) NOT NULL,
+    mime_type VARCHAR(100) NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    visibility video_visibility NOT NULL DEFAULT 'public',
+    upload_length BIGINT NOT NULL CHECK (upload_length > 0),
+    upload_offset BIGINT NOT NULL DEFAULT 0,
+    parts BIGINT[] NOT NULL DEFAULT '{}',
+    video_id UUID REFERENCES videos(id) ON DELETE SET NULL,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CHECK (upload_offset >= 0 AND upload_offset <= upload_length)
+);
+
+CREATE INDEX idx_upload_sessions_user ON upload_sessions(user_id);
+CREATE INDEX idx_upload_sessions_expires_at ON upload_sessions(expires_at);
+
+CREATE TRIGGER update_upload_sessions_updated_at
+    BEFORE UPDATE ON upload_sessions
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,6 @@
+-- Rollback: Remove direct-upload columns from upload_sessions
+
+ALTER TABLE upload_sessions
+DROP COLUMN IF EXISTS multipart_id,
+DROP COLUMN IF EXISTS storage_key,
+DROP COLUMN IF EXISTS method;
@@ -0,0 +1,8 @@
+-- Direct-to-storage uploads reuse upload_sessions. A direct session's bytes
+-- never pass through the API: the client PUTs parts to presigned URLs, and the
+-- session only remembers where the object will land (storage_key) and the
+-- backend's multipart upload that the parts belong to (multipart_id).
+ALTER TABLE upload_sessions
+ADD COLUMN IF NOT EXISTS method VARCHAR(10) NOT NULL DEFAULT 'tus' CHECK (method IN ('tus', 'direct')),
+ADD COLUMN IF NOT EXISTS storage_key TEXT NOT NULL DEFAULT '',
+ADD COLUMN IF NOT EXISTS multipart_id TEXT NOT NULL DEFAULT '';
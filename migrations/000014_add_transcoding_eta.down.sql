@@ -0,0 +1,4 @@
+-- Rollback: Remove the transcoding ETA from videos
+
+ALTER TABLE videos
+DROP COLUMN IF EXISTS transcoding_eta;
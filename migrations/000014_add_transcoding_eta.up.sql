@@ -0,0 +1,6 @@
+-- When the worker expects the video to finish processing, extrapolated from
+-- how fast ffmpeg has got through the source so far. NULL whenever nothing is
+-- encoding: before the encode has run long enough to judge, and once the
+-- video is ready or failed.
+ALTER TABLE videos
+ADD COLUMN IF NOT EXISTS transcoding_eta TIMESTAMPTZ;
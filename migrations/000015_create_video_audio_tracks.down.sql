@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS update_video_audio_tracks_updated_at ON video_audio_tracks;
+DROP INDEX IF EXISTS idx_video_audio_tracks_video;
+DROP TABLE IF EXISTS video_audio_tracks;
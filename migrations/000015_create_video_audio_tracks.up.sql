@@ -0,0 +1,26 @@
+-- Audio renditions. Every audio stream found in an upload becomes a 'source'
+-- track when the video is transcoded, and owners may add 'dub' tracks later
+-- from separate files. name is the rendition's directory beside the video
+-- renditions (audio_0, audio_1, ...), so it is unique within a video.
+CREATE TABLE IF NOT EXISTS video_audio_tracks (
+    id UUID PRIMARY KEY,
+    video_id UUID NOT NULL REFERENCES videos(id) ON DELETE CASCADE,
+    name VARCHAR(32) NOT NULL,
+    language VARCHAR(35) NOT NULL DEFAULT 'und',
+    label VARCHAR(100) NOT NULL DEFAULT '',
+    kind VARCHAR(10) NOT NULL CHECK (kind IN ('source', 'dub')),
+    status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'ready', 'failed')),
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    stream_index INTEGER NOT NULL DEFAULT 0,
+    file_path TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE (video_id, name)
+);
+
+CREATE INDEX idx_video_audio_tracks_video ON video_audio_tracks(video_id);
+
+CREATE TRIGGER update_video_audio_tracks_updated_at
+    BEFORE UPDATE ON video_audio_tracks
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
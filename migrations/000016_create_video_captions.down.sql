@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS update_video_captions_updated_at ON video_captions;
+DROP INDEX IF EXISTS idx_video_captions_video;
+DROP TABLE IF EXISTS video_captions;
@@ -0,0 +1,27 @@
+-- Text tracks. Text subtitle streams found in an upload become 'embedded'
+-- captions when the video is transcoded; owners may upload more as SRT or
+-- WebVTT. name is the rendition's directory beside the video renditions
+-- (subs_0, subs_1, ...), so it is unique within a video.
+CREATE TABLE IF NOT EXISTS video_captions (
+    id UUID PRIMARY KEY,
+    video_id UUID NOT NULL REFERENCES videos(id) ON DELETE CASCADE,
+    name VARCHAR(32) NOT NULL,
+    language VARCHAR(35) NOT NULL DEFAULT 'und',
+    label VARCHAR(100) NOT NULL DEFAULT '',
+    kind VARCHAR(10) NOT NULL DEFAULT 'subtitles' CHECK (kind IN ('subtitles', 'captions')),
+    status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'ready', 'failed')),
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    embedded BOOLEAN NOT NULL DEFAULT FALSE,
+    stream_index INTEGER NOT NULL DEFAULT 0,
+    file_path TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE (video_id, name)
+);
+
+CREATE INDEX idx_video_captions_video ON video_captions(video_id);
+
+CREATE TRIGGER update_video_captions_updated_at
+    BEFORE UPDATE ON video_captions
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,5 @@
+-- The ladder per-title encoding chose for a video and the probe measurements
+-- it was chosen from (domain.EncodingLadder), so the choice can be audited and
+-- reused when the video is transcoded again. NULL until a video is analysed.
+-- Listings never read it, so it stays out of the columns they select.
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS encoding_ladder JSONB;
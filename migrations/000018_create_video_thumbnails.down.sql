@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS update_video_thumbnails_updated_at ON video_thumbnails;
+DROP INDEX IF EXISTS idx_video_thumbnails_selected;
+DROP INDEX IF EXISTS idx_video_thumbnails_video;
+DROP TABLE IF EXISTS video_thumbnails;
@@ -0,0 +1,25 @@
+-- Poster images. The worker picks the best few frames of a video as
+-- 'candidate' thumbnails; owners may upload 'custom' ones. storage_key is
+-- the prefix each is rendered under, and the selected thumbnail's key is
+-- copied to videos.thumbnail_path, which is what the thumbnail URL serves.
+CREATE TABLE IF NOT EXISTS video_thumbnails (
+    id UUID PRIMARY KEY,
+    video_id UUID NOT NULL REFERENCES videos(id) ON DELETE CASCADE,
+    kind VARCHAR(10) NOT NULL CHECK (kind IN ('candidate', 'custom')),
+    status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'ready', 'failed')),
+    storage_key TEXT NOT NULL,
+    offset_seconds DOUBLE PRECISION NOT NULL DEFAULT 0,
+    score DOUBLE PRECISION NOT NULL DEFAULT 0,
+    selected BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_video_thumbnails_video ON video_thumbnails(video_id);
+
+-- A video has at most one selected thumbnail.
+CREATE UNIQUE INDEX idx_video_thumbnails_selected ON video_thumbnails(video_id) WHERE selected;
+
+CREATE TRIGGER update_video_thumbnails_updated_at
+    BEFORE UPDATE ON video_thumbnails
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,4 @@
+-- The storage key base of a video's animated preview: the worker writes
+-- <preview_path>.webp and <preview_path>.mp4 beside its thumbnails. NULL for
+-- videos processed before previews existed, or whose preview failed.
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS preview_path TEXT;
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS update_video_processing_stages_updated_at ON video_processing_stages;
+DROP TABLE IF EXISTS video_processing_stages;
@@ -0,0 +1,27 @@
+-- The stages a video is processed in, a DAG per video: 'probe', one
+-- 'encode:<rendition>' per rung and audio track, 'thumbnails', 'package' and
+-- 'publish'. depends_on names the stages that must be done before a stage is
+-- queued. checkpoint is what a finished stage hands to the stages after it,
+-- so a retry resumes from the last good stage instead of starting over.
+-- position is the stage's place in its video's plan, for listing in order.
+CREATE TABLE IF NOT EXISTS video_processing_stages (
+    video_id UUID NOT NULL REFERENCES videos(id) ON DELETE CASCADE,
+    name VARCHAR(64) NOT NULL,
+    kind VARCHAR(16) NOT NULL CHECK (kind IN ('probe', 'encode', 'package', 'thumbnails', 'publish')),
+    position INTEGER NOT NULL DEFAULT 0,
+    depends_on TEXT[] NOT NULL DEFAULT '{}',
+    status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'queued', 'running', 'done', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    progress DOUBLE PRECISION NOT NULL DEFAULT 0,
+    last_error TEXT,
+    checkpoint JSONB,
+    started_at TIMESTAMP WITH TIME ZONE,
+    finished_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (video_id, name)
+);
+
+CREATE TRIGGER update_video_processing_stages_updated_at
+    BEFORE UPDATE ON video_processing_stages
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,8 @@
+ALTER TABLE videos DROP COLUMN IF EXISTS processing_error;
+
+-- Probe depends on validate; without it, it would never be queued.
+UPDATE video_processing_stages SET depends_on = array_remove(depends_on, 'validate');
+DELETE FROM video_processing_stages WHERE kind = 'validate';
+ALTER TABLE video_processing_stages DROP CONSTRAINT IF EXISTS video_processing_stages_kind_check;
+ALTER TABLE video_processing_stages ADD CONSTRAINT video_processing_stages_kind_check
+    CHECK (kind IN ('probe', 'encode', 'package', 'thumbnails', 'publish'));
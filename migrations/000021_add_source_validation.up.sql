@@ -0,0 +1,8 @@
+-- Sources are vetted by a 'validate' stage before probe, and one it rejects
+-- fails its video with processing_error, the {code, message} its uploader is
+-- shown. It is NULL for a video that has not been rejected.
+ALTER TABLE video_processing_stages DROP CONSTRAINT IF EXISTS video_processing_stages_kind_check;
+ALTER TABLE video_processing_stages ADD CONSTRAINT video_processing_stages_kind_check
+    CHECK (kind IN ('validate', 'probe', 'encode', 'package', 'thumbnails', 'publish'));
+
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS processing_error JSONB;
@@ -0,0 +1,11 @@
+ALTER TABLE upload_sessions DROP COLUMN IF EXISTS normalize_audio;
+
+ALTER TABLE videos DROP COLUMN IF EXISTS audio_loudness;
+ALTER TABLE videos DROP COLUMN IF EXISTS normalize_audio;
+
+-- Nothing may wait on a loudness stage that is gone.
+UPDATE video_processing_stages SET depends_on = array_remove(depends_on, 'loudness');
+DELETE FROM video_processing_stages WHERE kind = 'loudness';
+ALTER TABLE video_processing_stages DROP CONSTRAINT IF EXISTS video_processing_stages_kind_check;
+ALTER TABLE video_processing_stages ADD CONSTRAINT video_processing_stages_kind_check
+    CHECK (kind IN ('validate', 'probe', 'encode', 'package', 'thumbnails', 'publish'));
@@ -0,0 +1,13 @@
+-- Audio is leveled to an EBU R128 target in two passes: a 'loudness' stage
+-- measures each source track, and the audio encodes apply what it measured.
+-- normalize_audio is the uploader's say in it, carried from the upload
+-- session to the video. audio_loudness is the default track's measurement,
+-- {integrated_lufs, true_peak_dbtp, range_lu, normalized, target_lufs}.
+ALTER TABLE video_processing_stages DROP CONSTRAINT IF EXISTS video_processing_stages_kind_check;
+ALTER TABLE video_processing_stages ADD CONSTRAINT video_processing_stages_kind_check
+    CHECK (kind IN ('validate', 'probe', 'encode', 'package', 'thumbnails', 'loudness', 'publish'));
+
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS normalize_audio BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS audio_loudness JSONB;
+
+ALTER TABLE upload_sessions ADD COLUMN IF NOT EXISTS normalize_audio BOOLEAN NOT NULL DEFAULT TRUE;
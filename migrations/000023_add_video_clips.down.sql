@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_videos_parent_id;
+
+ALTER TABLE videos DROP COLUMN IF EXISTS clip;
+ALTER TABLE videos DROP COLUMN IF EXISTS parent_id;
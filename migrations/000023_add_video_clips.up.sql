@@ -0,0 +1,7 @@
+-- A clip is a video cut from another: parent_id is the video it was cut from,
+-- and clip the {start, end} span of it, in seconds. Deleting the parent
+-- leaves its clips standing, unlinked.
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS parent_id UUID REFERENCES videos(id) ON DELETE SET NULL;
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS clip JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_videos_parent_id ON videos(parent_id) WHERE parent_id IS NOT NULL;
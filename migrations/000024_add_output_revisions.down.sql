@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_reprocess_batches_created_at;
+DROP TABLE IF EXISTS reprocess_batches;
+
+ALTER TABLE videos DROP COLUMN IF EXISTS output_revision;
@@ -0,0 +1,20 @@
+-- output_revision is the revision of a video's transcoded output being
+-- served. Revision 0 is the layout every video had before: transcoded/<id>/.
+-- A video processed again while it is published is written beside it under
+-- transcoded/<id>/r<n>/ and only takes its place when it is done, so
+-- viewers keep what they were watching until the replacement is ready.
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS output_revision INTEGER NOT NULL DEFAULT 0;
+
+-- A reprocess batch is a set of videos an admin queued to be processed
+-- again, kept so its progress can be followed. filter is what selected
+-- them, as it was asked for; video_ids what it matched at the time.
+CREATE TABLE IF NOT EXISTS reprocess_batches (
+    id UUID PRIMARY KEY,
+    filter JSONB NOT NULL,
+    video_ids UUID[] NOT NULL,
+    rate_per_minute INTEGER NOT NULL CHECK (rate_per_minute > 0),
+    requested_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_reprocess_batches_created_at ON reprocess_batches(created_at DESC);
@@ -0,0 +1,4 @@
+-- import_source is where a video created by import fetches its source from:
+-- {kind, source}, kind "url" or "drop". The source is only fetched once;
+-- the column stays so a failed fetch can be retried. NULL for uploads.
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS import_source JSONB;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS live_streams;
+-- Postgres cannot drop a value from an enum, so 'live' stays in video_status;
+-- no video is left holding it.
+UPDATE videos SET status = 'failed' WHERE status = 'live';
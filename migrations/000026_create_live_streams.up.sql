@@ -0,0 +1,21 @@
+-- A live video is one being broadcast: its HLS output grows while viewers
+-- watch, and it becomes ready once the broadcast ends. Adding an enum value
+-- cannot share a transaction with using it, and nothing here does.
+ALTER TYPE video_status ADD VALUE IF NOT EXISTS 'live';
+
+-- A live stream is one broadcast into one video. key_hash is the SHA-256 of
+-- the stream key its owner pushes with, which is shown to them once and never
+-- stored. A stream waits for its push, is live while it lasts, and has ended
+-- for good once it stops: pushing again needs a new stream.
+CREATE TABLE IF NOT EXISTS live_streams (
+    id UUID PRIMARY KEY,
+    video_id UUID NOT NULL UNIQUE REFERENCES videos(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    key_hash CHAR(64) NOT NULL,
+    status VARCHAR(10) NOT NULL DEFAULT 'waiting' CHECK (status IN ('waiting', 'live', 'ended')),
+    started_at TIMESTAMP WITH TIME ZONE,
+    ended_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_live_streams_user ON live_streams(user_id, created_at DESC);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_comments_event_offset;
+ALTER TABLE comments DROP COLUMN IF EXISTS event_offset;
+ALTER TABLE videos
+    DROP COLUMN IF EXISTS chapters,
+    DROP COLUMN IF EXISTS premiere_at;
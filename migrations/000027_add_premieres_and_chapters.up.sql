@@ -0,0 +1,13 @@
+-- A premiere plays a ready video out to its viewers as a live event would,
+-- from premiere_at; it stays set once the premiere is over. Chapters are the
+-- titled sections of a video, [{"start": seconds, "title": "..."}] in order.
+ALTER TABLE videos
+    ADD COLUMN premiere_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN chapters JSONB NOT NULL DEFAULT '[]';
+
+-- How far into the video's premiere a comment was posted, in seconds, for
+-- one posted during it. A premiere's chat is its comments in this order.
+ALTER TABLE comments ADD COLUMN event_offset DOUBLE PRECISION;
+
+CREATE INDEX idx_comments_event_offset ON comments(video_id, event_offset, created_at)
+    WHERE event_offset IS NOT NULL AND deleted_at IS NULL;
@@ -0,0 +1,6 @@
+-- A trim of a published video in progress: the span it keeps, and the source,
+-- duration and chapters of the output still being served, {range, file_path,
+-- mime_type, file_size, duration, chapters}. It is taken when the trim is
+-- asked for and cleared when the trimmed output is published, or put back
+-- should the trim fail for good. NULL when no trim is in progress.
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS trim_state JSONB;
@@ -0,0 +1,133 @@
+// Package framescore rates still frames of a video as thumbnail candidates.
+//
+// A frame is measured on its luma alone: how bright it is, how much its
+// brightness varies, and how much fine detail it holds. A frame taken in a
+// fade, on a title card of flat colour or through motion blur scores low; a
+// well exposed, sharp frame scores high. Comparing a frame with one taken
+// shortly before it tells a shot that has just changed from one that has
+// sat still, which lifts frames that show something new.
+package framescore
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Frames darker or brighter than these, or flatter, are never picked:
+// they are fades to black or white and blank slates.
+const (
+	minBrightness = 0.08
+	maxBrightness = 0.95
+	minContrast   = 0.03
+)
+
+// Half-saturation points of the sharpness, contrast and change terms: a
+// frame with this much of each earns half that term's weight.
+const (
+	sharpnessKnee = 0.03
+	contrastKnee  = 0.1
+	changeKnee    = 0.1
+)
+
+// Stats are the measurements of one frame, each between 0 and 1.
+type Stats struct {
+	// Brightness is the mean luma.
+	Brightness float64
+	// Contrast is the standard deviation of luma.
+	Contrast float64
+	// Sharpness is the mean magnitude of the luma's Laplacian, which blur
+	// and compression smear away.
+	Sharpness float64
+}
+
+// Measure takes the Stats of img.
+func Measure(img image.Image) Stats {
+	luma := lumaOf(img)
+	w, h := luma.w, luma.h
+	if w == 0 || h == 0 {
+		return Stats{}
+	}
+
+	var sum, sumSquares float64
+	for _, v := range luma.pix {
+		sum += v
+		sumSquares += v * v
+	}
+	n := float64(len(luma.pix))
+	mean := sum / n
+	variance := max(sumSquares/n-mean*mean, 0)
+
+	var laplacian float64
+	if w >= 3 && h >= 3 {
+		for y := 1; y < h-1; y++ {
+			for x := 1; x < w-1; x++ {
+				v := 4*luma.at(x, y) - luma.at(x-1, y) - luma.at(x+1, y) - luma.at(x, y-1) - luma.at(x, y+1)
+				laplacian += math.Abs(v)
+			}
+		}
+		laplacian /= float64((w - 2) * (h - 2))
+	}
+
+	return Stats{Brightness: mean, Contrast: math.Sqrt(variance), Sharpness: min(laplacian, 1)}
+}
+
+// Change is the mean luma difference between a and b over the area both
+// cover, between 0 for identical frames and 1.
+func Change(a, b image.Image) float64 {
+	la, lb := lumaOf(a), lumaOf(b)
+	w, h := min(la.w, lb.w), min(la.h, lb.h)
+	if w == 0 || h == 0 {
+		return 0
+	}
+	var diff float64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			diff += math.Abs(la.at(x, y) - lb.at(x, y))
+		}
+	}
+	return diff / float64(w*h)
+}
+
+// Score rates a frame with stats, which differs from the frame before it by
+// change, from 0 for a frame that must not be used to just under 1.
+func Score(stats Stats, change float64) float64 {
+	if stats.Brightness < minBrightness || stats.Brightness > maxBrightness || stats.Contrast < minContrast {
+		return 0
+	}
+	exposure := 1 - math.Abs(stats.Brightness-0.5)/0.5
+	sharpness := stats.Sharpness / (stats.Sharpness + sharpnessKnee)
+	contrast := stats.Contrast / (stats.Contrast + contrastKnee)
+	novelty := change / (change + changeKnee)
+	return 0.25*exposure + 0.4*sharpness + 0.2*contrast + 0.15*novelty
+}
+
+// luma is an image's luma plane, scaled to 0..1.
+type luma struct {
+	w, h int
+	pix  []float64
+}
+
+func (l luma) at(x, y int) float64 { return l.pix[y*l.w+x] }
+
+func lumaOf(img image.Image) luma {
+	bounds := img.Bounds()
+	l := luma{w: bounds.Dx(), h: bounds.Dy()}
+	l.pix = make([]float64, l.w*l.h)
+	i := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			// Decoded JPEG and grayscale PNG frames skip the conversion.
+			switch src := img.(type) {
+			case *image.Gray:
+				l.pix[i] = float64(src.GrayAt(x, y).Y) / 255
+			case *image.YCbCr:
+				l.pix[i] = float64(src.Y[src.YOffset(x, y)]) / 255
+			default:
+				l.pix[i] = float64(color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y) / 255
+			}
+			i++
+		}
+	}
+	return l
+}
@@ -0,0 +1,90 @@
+package framescore
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+func gray(w, h int, fill func(x, y int) uint8) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetGray(x, y, color.Gray{Y: fill(x, y)})
+		}
+	}
+	return img
+}
+
+func TestMeasure(t *testing.T) {
+	flat := Measure(gray(32, 18, func(int, int) uint8 { return 128 }))
+	if flat.Contrast != 0 || flat.Sharpness != 0 {
+		t.Errorf("flat frame = %+v, want no contrast or sharpness", flat)
+	}
+	if flat.Brightness < 0.49 || flat.Brightness > 0.51 {
+		t.Errorf("flat frame brightness = %v, want about 0.5", flat.Brightness)
+	}
+
+	checker := Measure(gray(32, 18, func(x, y int) uint8 {
+		if (x+y)%2 == 0 {
+			return 255
+		}
+		return 0
+	}))
+	if checker.Contrast < 0.49 || checker.Sharpness < 0.9 {
+		t.Errorf("checkerboard = %+v, want high contrast and sharpness", checker)
+	}
+
+	// A colour image is measured on its luma, like its grayscale twin.
+	rgba := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for i := range rgba.Pix {
+		rgba.Pix[i] = 255
+	}
+	if got := Measure(rgba).Brightness; got != 1 {
+		t.Errorf("white RGBA brightness = %v, want 1", got)
+	}
+}
+
+func TestChange(t *testing.T) {
+	a := gray(16, 9, func(x, _ int) uint8 { return uint8(x * 16) })
+	if got := Change(a, a); got != 0 {
+		t.Errorf("Change(a, a) = %v, want 0", got)
+	}
+	black := gray(16, 9, func(int, int) uint8 { return 0 })
+	white := gray(16, 9, func(int, int) uint8 { return 255 })
+	if got := Change(black, white); got != 1 {
+		t.Errorf("Change(black, white) = %v, want 1", got)
+	}
+	// Frames of different sizes compare over the area both cover.
+	if got := Change(black, gray(8, 4, func(int, int) uint8 { return 0 })); got != 0 {
+		t.Errorf("Change over the shared area = %v, want 0", got)
+	}
+}
+
+func TestScore(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	detailed := gray(64, 36, func(int, int) uint8 { return uint8(64 + rng.Intn(128)) })
+	smooth := gray(64, 36, func(x, _ int) uint8 { return uint8(64 + x*2) })
+	black := gray(64, 36, func(int, int) uint8 { return 4 })
+	slate := gray(64, 36, func(int, int) uint8 { return 128 })
+
+	if got := Score(Measure(black), 0); got != 0 {
+		t.Errorf("black frame scored %v, want 0", got)
+	}
+	if got := Score(Measure(slate), 0); got != 0 {
+		t.Errorf("flat slate scored %v, want 0", got)
+	}
+
+	sharp, soft := Score(Measure(detailed), 0), Score(Measure(smooth), 0)
+	if sharp <= soft {
+		t.Errorf("detailed frame scored %v, not above smooth frame's %v", sharp, soft)
+	}
+	if sharp >= 1 {
+		t.Errorf("score %v, want below 1", sharp)
+	}
+
+	if Score(Measure(detailed), 0.3) <= sharp {
+		t.Error("a frame after a scene change should outscore the same frame in a still shot")
+	}
+}
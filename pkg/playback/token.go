@@ -0,0 +1,141 @@
+// Package playback signs the tokens that let a player fetch a video's
+// playlists and segments without presenting who it is on every request.
+//
+// A token names the video it opens, the viewer it was issued to and when it
+// expires, and for a video being premiered when the premiere started, under
+// an HMAC-SHA256 of them all, so checking one needs the key and nothing
+// else. Native HLS players cannot attach an Authorization header
+// to the requests they make for segments, but they keep a URI's query string,
+// so a token travels there.
+package playback
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalid reports a token that is malformed, was not signed with this
+	// key, or was signed for another video.
+	ErrInvalid = errors.New("invalid playback token")
+	// ErrExpired reports a token that was valid until its expiry passed.
+	ErrExpired = errors.New("playback token expired")
+)
+
+// macSize is how much of the HMAC a token carries. 128 bits is beyond
+// guessing within any token's lifetime, and keeps segment URIs short.
+const macSize = 16
+
+// Claims is what a valid token says.
+type Claims struct {
+	// Viewer is the user the token was issued to, or uuid.Nil when it was
+	// issued to an anonymous viewer.
+	Viewer  uuid.UUID
+	Expires time.Time
+	// Playout is when the premiere the token was issued during started, or
+	// zero when it was not issued during one. The video is only played out
+	// to its holder as far as the premiere has got.
+	Playout time.Time
+}
+
+// Signer issues and checks playback tokens under one key.
+type Signer struct {
+	key []byte
+	now func() time.Time
+}
+
+// NewSigner returns a Signer using key. An empty key means a random one,
+// whose tokens are only good in the process that signed them.
+func NewSigner(key []byte) *Signer {
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			panic(fmt.Sprintf("playback: generating signing key: %v", err))
+		}
+	}
+	return &Signer{key: key, now: time.Now}
+}
+
+// Sign returns a token opening videoID to viewer until expires. The token is
+// safe in a URL's query string as it is.
+func (s *Signer) Sign(videoID, viewer uuid.UUID, expires time.Time) string {
+	return s.SignPlayout(videoID, viewer, expires, time.Time{})
+}
+
+// SignPlayout is Sign for a video whose premiere started at playout. A zero
+// playout signs the token Sign does.
+func (s *Signer) SignPlayout(videoID, viewer uuid.UUID, expires, playout time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	who := ""
+	if viewer != uuid.Nil {
+		who = base64.RawURLEncoding.EncodeToString(viewer[:])
+	}
+	if playout.IsZero() {
+		return exp + "." + who + "." + s.mac(videoID, who, exp)
+	}
+	start := strconv.FormatInt(playout.Unix(), 10)
+	return exp + "." + who + "." + start + "." + s.mac(videoID, who, exp, start)
+}
+
+// Verify checks that token was signed by s for videoID and has not expired,
+// and returns what it says.
+func (s *Signer) Verify(token string, videoID uuid.UUID) (Claims, error) {
+	// exp.who.mac, or exp.who.start.mac with a playout.
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 && len(parts) != 4 {
+		return Claims{}, ErrInvalid
+	}
+	exp, who, sig := parts[0], parts[1], parts[len(parts)-1]
+	fields := []string{who, exp}
+	if len(parts) == 4 {
+		fields = append(fields, parts[2])
+	}
+	// The MAC is compared before anything it covers is trusted.
+	if !hmac.Equal([]byte(sig), []byte(s.mac(videoID, fields...))) {
+		return Claims{}, ErrInvalid
+	}
+
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return Claims{}, ErrInvalid
+	}
+	claims := Claims{Expires: time.Unix(unix, 0)}
+	if len(parts) == 4 {
+		start, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			return Claims{}, ErrInvalid
+		}
+		claims.Playout = time.Unix(start, 0)
+	}
+	if who != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(who)
+		if err != nil {
+			return Claims{}, ErrInvalid
+		}
+		if claims.Viewer, err = uuid.FromBytes(raw); err != nil {
+			return Claims{}, ErrInvalid
+		}
+	}
+	if !s.now().Before(claims.Expires) {
+		return Claims{}, ErrExpired
+	}
+	return claims, nil
+}
+
+// mac authenticates a token's fields as they are written, who, exp and any
+// playout start, bound to videoID and to this use of the key, which also
+// signs other URLs.
+func (s *Signer) mac(videoID uuid.UUID, fields ...string) string {
+	m := hmac.New(sha256.New, s.key)
+	m.Write([]byte("playback\n" + videoID.String() + "\n" + strings.Join(fields, "\n")))
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil)[:macSize])
+}
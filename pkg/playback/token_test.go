@@ -0,0 +1,113 @@
+package playback
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var testKey = []byte("test-signing-key-at-least-32-characters")
+
+func TestSignAndVerify(t *testing.T) {
+	s := NewSigner(testKey)
+	video := uuid.New()
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	for _, viewer := range []uuid.UUID{uuid.New(), uuid.Nil} {
+		token := s.Sign(video, viewer, expires)
+		if url.QueryEscape(token) != token {
+			t.Errorf("token %q needs escaping in a query string", token)
+		}
+		claims, err := s.Verify(token, video)
+		if err != nil {
+			t.Fatalf("Verify(%q) = %v", token, err)
+		}
+		if claims.Viewer != viewer || !claims.Expires.Equal(expires) {
+			t.Errorf("claims = %+v, want viewer %s expiring %s", claims, viewer, expires)
+		}
+	}
+}
+
+func TestVerifyRefuses(t *testing.T) {
+	s := NewSigner(testKey)
+	video, viewer := uuid.New(), uuid.New()
+	expires := time.Now().Add(time.Hour)
+	token := s.Sign(video, viewer, expires)
+	exp, rest, _ := strings.Cut(token, ".")
+	// The same token with the first character of its viewer changed.
+	swapped := "A"
+	if rest[0] == 'A' {
+		swapped = "B"
+	}
+
+	tests := []struct {
+		name    string
+		signer  *Signer
+		token   string
+		video   uuid.UUID
+		wantErr error
+	}{
+		{"another video", s, token, uuid.New(), ErrInvalid},
+		{"another key", NewSigner([]byte("a-different-key-of-at-least-32-chars")), token, video, ErrInvalid},
+		{"a later expiry", s, "9" + exp + "." + rest, video, ErrInvalid},
+		{"another viewer", s, exp + "." + swapped + rest[1:], video, ErrInvalid},
+		{"no viewer", s, exp + ".." + rest[strings.IndexByte(rest, '.')+1:], video, ErrInvalid},
+		{"malformed", s, "not-a-token", video, ErrInvalid},
+		{"empty", s, "", video, ErrInvalid},
+		{"expired", s, s.Sign(video, viewer, time.Now().Add(-time.Second)), video, ErrExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.signer.Verify(tt.token, tt.video); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSignPlayout(t *testing.T) {
+	s := NewSigner(testKey)
+	video, viewer := uuid.New(), uuid.New()
+	expires := time.Now().Add(time.Hour)
+	playout := time.Now().Add(-10 * time.Minute).Truncate(time.Second)
+
+	token := s.SignPlayout(video, viewer, expires, playout)
+	if url.QueryEscape(token) != token {
+		t.Errorf("token %q needs escaping in a query string", token)
+	}
+	claims, err := s.Verify(token, video)
+	if err != nil {
+		t.Fatalf("Verify(%q) = %v", token, err)
+	}
+	if !claims.Playout.Equal(playout) || claims.Viewer != viewer {
+		t.Errorf("claims = %+v, want playout %s", claims, playout)
+	}
+	if claims, _ := s.Verify(s.Sign(video, viewer, expires), video); !claims.Playout.IsZero() {
+		t.Errorf("Sign's token claims a playout of %s", claims.Playout)
+	}
+
+	// The playout is what keeps a viewer behind the premiere, so neither
+	// dropping it nor changing it may verify.
+	parts := strings.Split(token, ".")
+	for name, forged := range map[string]string{
+		"playout dropped": parts[0] + "." + parts[1] + "." + parts[3],
+		"playout changed": parts[0] + "." + parts[1] + ".1" + parts[2] + "." + parts[3],
+		"extra field":     token + ".x",
+	} {
+		if _, err := s.Verify(forged, video); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: Verify = %v, want ErrInvalid", name, err)
+		}
+	}
+}
+
+func TestRandomKeys(t *testing.T) {
+	video := uuid.New()
+	token := NewSigner(nil).Sign(video, uuid.Nil, time.Now().Add(time.Hour))
+	if _, err := NewSigner(nil).Verify(token, video); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("a token from another random key verified: %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package validator
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -28,6 +29,35 @@ var allowedExtensions = map[string]bool{
 	".webm": true,
 }
 
+// allowedAudioExtensions are the files a dubbed audio track may arrive as:
+// the common audio formats, and the audio-only flavours of the containers
+// above.
+var allowedAudioExtensions = map[string]bool{
+	".aac":  true,
+	".m4a":  true,
+	".mp3":  true,
+	".wav":  true,
+	".flac": true,
+	".ogg":  true,
+	".opus": true,
+	".mka":  true,
+	".webm": true,
+}
+
+// allowedCaptionExtensions are the text track formats a caption may arrive
+// as. Either is converted to WebVTT before it is stored.
+var allowedCaptionExtensions = map[string]bool{
+	".srt": true,
+	".vtt": true,
+}
+
+// allowedImageExtensions are the formats a custom thumbnail may arrive as.
+var allowedImageExtensions = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+}
+
 // Container signatures, in the byte order they appear at the head of the file.
 var (
 	// magicEBML starts every Matroska and WebM file (.mkv, .webm).
@@ -38,61 +68,301 @@ var (
 	// magicFTYP is the ISO Base Media File Format brand box, shared by .mp4,
 	// .mov, and .m4v. It sits at offset 4, after a 4-byte box size.
 	magicFTYP = []byte{'f', 't', 'y', 'p'}
-)
+	// brandQuickTime is the major brand of a .mov file.
+	brandQuickTime = []byte{'q', 't', ' ', ' '}
+	// docTypeWebM is the DocType a WebM file's EBML header declares; a
+	// Matroska file declares "matroska".
+	docTypeWebM = []byte{0x42, 0x82, 0x84, 'w', 'e', 'b', 'm'}
 
-func ValidateVideoFile(file multipart.File, header *multipart.FileHeader, maxSize int64) error {
-	if header.Size > maxSize {
-		return fmt.Errorf("%w: file is %d bytes, maximum is %d bytes", ErrFileTooLarge, header.Size, maxSize)
-	}
+	// Audio-only signatures. WAVE is a RIFF form type like AVI; MP3 files
+	// usually open with an ID3 tag, and otherwise, like raw AAC in ADTS,
+	// with an MPEG frame sync.
+	magicWAVE = []byte{'W', 'A', 'V', 'E'}
+	magicID3  = []byte{'I', 'D', '3'}
+	magicFLAC = []byte{'f', 'L', 'a', 'C'}
+	magicOGG  = []byte{'O', 'g', 'g', 'S'}
 
-	if header.Size < 1024 {
-		return fmt.Errorf("%w: file is too small to be a valid video", ErrInvalidFormat)
-	}
+	// Image signatures: a JPEG opens with a start-of-image marker and the
+	// next marker's prefix, a PNG with its fixed eight-byte header.
+	magicJPEG = []byte{0xFF, 0xD8, 0xFF}
+	magicPNG  = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1A, '\n'}
+)
 
-	ext := strings.ToLower(filepath.Ext(header.Filename))
-	if !allowedExtensions[ext] {
-		return fmt.Errorf("%w: only mp4, mov, avi, mkv, webm are allowed", ErrInvalidFormat)
+func ValidateVideoFile(file multipart.File, header *multipart.FileHeader, maxSize int64) error {
+	if err := ValidateVideoUpload(header.Filename, header.Size, maxSize); err != nil {
+		return err
 	}
+	_, err := SniffVideoFile(file)
+	return err
+}
 
+// SniffVideoFile reads the head of file for a video container signature and
+// rewinds it for the caller.
+func SniffVideoFile(file multipart.File) (VideoContainer, error) {
 	buf := make([]byte, 512)
 	n, err := file.Read(buf)
 	if err != nil && err != io.EOF {
-		return fmt.Errorf("failed to read file header: %w", err)
+		return VideoContainer{}, fmt.Errorf("failed to read file header: %w", err)
 	}
 
 	if _, err := file.Seek(0, 0); err != nil {
-		return fmt.Errorf("failed to reset file pointer: %w", err)
+		return VideoContainer{}, fmt.Errorf("failed to reset file pointer: %w", err)
 	}
 
-	if !isVideoFile(buf[:n]) {
-		return fmt.Errorf("%w: file content does not match video format", ErrInvalidFormat)
+	return SniffVideoHeader(buf[:n])
+}
+
+// ValidateVideoUpload applies the checks ValidateVideoFile makes before reading
+// any content: size bounds and the extension allowlist. Resumable uploads run
+// it when the session is created, long before the first byte arrives.
+func ValidateVideoUpload(filename string, size, maxSize int64) error {
+	if size > maxSize {
+		return fmt.Errorf("%w: file is %d bytes, maximum is %d bytes", ErrFileTooLarge, size, maxSize)
 	}
 
+	if size < 1024 {
+		return fmt.Errorf("%w: file is too small to be a valid video", ErrInvalidFormat)
+	}
+
+	ext := strings.ToLower(filepath.Ext(filename))
+	if !allowedExtensions[ext] {
+		return fmt.Errorf("%w: only mp4, mov, avi, mkv, webm are allowed", ErrInvalidFormat)
+	}
 	return nil
 }
 
-// isVideoFile reports whether buf begins with a recognised video container
-// signature.
+// ValidateVideoHeader checks that buf, the first bytes of a file, carries a
+// recognised video container signature.
+func ValidateVideoHeader(buf []byte) error {
+	_, err := SniffVideoHeader(buf)
+	return err
+}
+
+// SniffVideoHeader identifies the video container buf, the first bytes of a
+// file, begins with, and fails as ValidateVideoHeader does when there is none.
+func SniffVideoHeader(buf []byte) (VideoContainer, error) {
+	container, ok := DetectVideoContainer(buf)
+	if !ok {
+		return VideoContainer{}, fmt.Errorf("%w: file content does not match video format", ErrInvalidFormat)
+	}
+	return container, nil
+}
+
+// VideoContainer is a container format a video may be uploaded in, as its
+// signature identifies it.
+type VideoContainer struct {
+	// MIMEType labels the raw file: what the bytes are, whatever the client
+	// declared.
+	MIMEType string
+	// Demuxer is the format ffprobe reports reading the container with. A
+	// file it reads with any other demuxer is not what its signature says.
+	Demuxer string
+}
+
+// The demuxers ffprobe names for each container family.
+const (
+	demuxerISOBMFF  = "mov,mp4,m4a,3gp,3g2,mj2"
+	demuxerMatroska = "matroska,webm"
+	demuxerAVI      = "avi"
+)
+
+// DetectVideoContainer identifies the video container buf, the first bytes of
+// a file, begins with.
 //
 // The ISO-BMFF check matches "ftyp" at offset 4 and ignores the four preceding
 // box-size bytes. The previous version required those bytes to be exactly
 // 00 00 00 18, which is only one of many legal box sizes — so most real-world
 // .mp4 files were rejected as corrupt. It also had no signature at all for .mov
 // (an ISO-BMFF format), even though .mov is in the extension allowlist, making
-// every .mov upload fail. Matroska (.mkv) shares the EBML signature with WebM.
+// every .mov upload fail. Matroska (.mkv) shares the EBML signature with WebM;
+// the EBML header's DocType tells them apart.
+func DetectVideoContainer(buf []byte) (VideoContainer, bool) {
+	switch {
+	case len(buf) >= 8 && bytes.Equal(buf[4:8], magicFTYP):
+		// The major brand follows the box type.
+		if len(buf) >= 12 && bytes.Equal(buf[8:12], brandQuickTime) {
+			return VideoContainer{MIMEType: "video/quicktime", Demuxer: demuxerISOBMFF}, true
+		}
+		return VideoContainer{MIMEType: "video/mp4", Demuxer: demuxerISOBMFF}, true
+	case bytes.HasPrefix(buf, magicEBML):
+		if bytes.Contains(buf[:min(len(buf), 64)], docTypeWebM) {
+			return VideoContainer{MIMEType: "video/webm", Demuxer: demuxerMatroska}, true
+		}
+		return VideoContainer{MIMEType: "video/x-matroska", Demuxer: demuxerMatroska}, true
+	case len(buf) >= 12 && bytes.HasPrefix(buf, magicRIFF) && bytes.Equal(buf[8:12], magicAVI):
+		return VideoContainer{MIMEType: "video/x-msvideo", Demuxer: demuxerAVI}, true
+	}
+	return VideoContainer{}, false
+}
+
+// IsISOBMFF reports whether c is MP4 or QuickTime, whose structure
+// ValidateISOBMFFBoxes can check.
+func (c VideoContainer) IsISOBMFF() bool {
+	return c.Demuxer == demuxerISOBMFF
+}
+
+// ValidateISOBMFFBoxes walks the top-level boxes of the ISO-BMFF file r, size
+// bytes long, and checks that they account for every byte of it. A demuxer
+// reads the boxes it knows and stops, so anything a box size does not cover,
+// such as an archive appended to make a polyglot, would otherwise pass
+// unseen. A box running past the end of the file means it was truncated.
+func ValidateISOBMFFBoxes(r io.ReaderAt, size int64) error {
+	header := make([]byte, 16)
+	for offset := int64(0); offset < size; {
+		if size-offset < 8 {
+			return fmt.Errorf("%w: %d stray bytes after the last box", ErrInvalidFormat, size-offset)
+		}
+		if _, err := r.ReadAt(header[:8], offset); err != nil {
+			return fmt.Errorf("reading box at offset %d: %w", offset, err)
+		}
+		if !isBoxType(header[4:8]) {
+			return fmt.Errorf("%w: no box at offset %d", ErrInvalidFormat, offset)
+		}
+
+		boxSize := int64(binary.BigEndian.Uint32(header[:4]))
+		switch boxSize {
+		case 0:
+			// The last box, running to the end of the file.
+			return nil
+		case 1:
+			if size-offset < 16 {
+				return fmt.Errorf("%w: box at offset %d is cut short", ErrCorruptVideo, offset)
+			}
+			if _, err := r.ReadAt(header[8:16], offset+8); err != nil {
+				return fmt.Errorf("reading box at offset %d: %w", offset, err)
+			}
+			largeSize := binary.BigEndian.Uint64(header[8:16])
+			if largeSize > uint64(size) {
+				return fmt.Errorf("%w: box at offset %d runs past the end of the file", ErrCorruptVideo, offset)
+			}
+			boxSize = int64(largeSize)
+			if boxSize < 16 {
+				return fmt.Errorf("%w: box at offset %d is %d bytes", ErrInvalidFormat, offset, boxSize)
+			}
+		default:
+			if boxSize < 8 {
+				return fmt.Errorf("%w: box at offset %d is %d bytes", ErrInvalidFormat, offset, boxSize)
+			}
+		}
+		if boxSize > size-offset {
+			return fmt.Errorf("%w: box at offset %d runs past the end of the file", ErrCorruptVideo, offset)
+		}
+		offset += boxSize
+	}
+	return nil
+}
+
+// isBoxType reports whether b can be a box type: four printable ASCII
+// characters, which is all the registered types and vendors' own use.
+func isBoxType(b []byte) bool {
+	for _, c := range b {
+		if c < 0x20 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// isVideoFile reports whether buf begins with a recognised video container
+// signature.
 func isVideoFile(buf []byte) bool {
-	if len(buf) >= 8 && bytes.Equal(buf[4:8], magicFTYP) {
-		return true // mp4, mov, m4v
+	_, ok := DetectVideoContainer(buf)
+	return ok
+}
+
+// ValidateAudioFile is ValidateVideoFile for a standalone audio track: the
+// same size bounds, an audio extension allowlist, and a sniff for an audio
+// container signature. The file is rewound for the caller.
+func ValidateAudioFile(file multipart.File, header *multipart.FileHeader, maxSize int64) error {
+	if header.Size > maxSize {
+		return fmt.Errorf("%w: file is %d bytes, maximum is %d bytes", ErrFileTooLarge, header.Size, maxSize)
 	}
-	if bytes.HasPrefix(buf, magicEBML) {
-		return true // mkv, webm
+	if header.Size < 1024 {
+		return fmt.Errorf("%w: file is too small to be a valid audio track", ErrInvalidFormat)
 	}
-	if len(buf) >= 12 && bytes.HasPrefix(buf, magicRIFF) && bytes.Equal(buf[8:12], magicAVI) {
-		return true // avi
+
+	ext := strings.ToLower(filepath.Ext(header.Filename))
+	if !allowedAudioExtensions[ext] {
+		return fmt.Errorf("%w: only aac, m4a, mp3, wav, flac, ogg, opus, mka, webm are allowed", ErrInvalidFormat)
+	}
+
+	buf := make([]byte, 512)
+	n, err := file.Read(buf)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read file header: %w", err)
+	}
+	if _, err := file.Seek(0, 0); err != nil {
+		return fmt.Errorf("failed to reset file pointer: %w", err)
+	}
+
+	if !isAudioFile(buf[:n]) {
+		return fmt.Errorf("%w: file content does not match an audio format", ErrInvalidFormat)
+	}
+	return nil
+}
+
+// isAudioFile reports whether buf begins with a recognised audio container
+// signature. ISO-BMFF and Matroska hold audio as readily as video, so their
+// signatures count too.
+func isAudioFile(buf []byte) bool {
+	switch {
+	case len(buf) >= 8 && bytes.Equal(buf[4:8], magicFTYP):
+		return true // m4a
+	case bytes.HasPrefix(buf, magicEBML):
+		return true // mka, webm
+	case len(buf) >= 12 && bytes.HasPrefix(buf, magicRIFF) && bytes.Equal(buf[8:12], magicWAVE):
+		return true // wav
+	case bytes.HasPrefix(buf, magicID3), bytes.HasPrefix(buf, magicFLAC), bytes.HasPrefix(buf, magicOGG):
+		return true // mp3, flac, ogg and opus
+	case len(buf) >= 2 && buf[0] == 0xFF && buf[1]&0xE0 == 0xE0:
+		return true // mp3 or aac frame sync
 	}
 	return false
 }
 
+// ValidateCaptionFile checks a caption file's name and size. Its content is
+// text and has no signature worth sniffing; parsing it is the real check.
+func ValidateCaptionFile(header *multipart.FileHeader, maxSize int64) error {
+	if header.Size > maxSize {
+		return fmt.Errorf("%w: file is %d bytes, maximum is %d bytes", ErrFileTooLarge, header.Size, maxSize)
+	}
+	if header.Size == 0 {
+		return fmt.Errorf("%w: file is empty", ErrInvalidFormat)
+	}
+	if !allowedCaptionExtensions[strings.ToLower(filepath.Ext(header.Filename))] {
+		return fmt.Errorf("%w: only srt and vtt are allowed", ErrInvalidFormat)
+	}
+	return nil
+}
+
+// ValidateImageFile checks an uploaded image's size and extension and sniffs
+// it for a JPEG or PNG signature. The file is rewound for the caller.
+func ValidateImageFile(file multipart.File, header *multipart.FileHeader, maxSize int64) error {
+	if header.Size > maxSize {
+		return fmt.Errorf("%w: file is %d bytes, maximum is %d bytes", ErrFileTooLarge, header.Size, maxSize)
+	}
+	if header.Size == 0 {
+		return fmt.Errorf("%w: file is empty", ErrInvalidFormat)
+	}
+	if !allowedImageExtensions[strings.ToLower(filepath.Ext(header.Filename))] {
+		return fmt.Errorf("%w: only jpg, jpeg and png are allowed", ErrInvalidFormat)
+	}
+
+	buf := make([]byte, 512)
+	n, err := file.Read(buf)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read file header: %w", err)
+	}
+	if _, err := file.Seek(0, 0); err != nil {
+		return fmt.Errorf("failed to reset file pointer: %w", err)
+	}
+
+	if !bytes.HasPrefix(buf[:n], magicJPEG) && !bytes.HasPrefix(buf[:n], magicPNG) {
+		return fmt.Errorf("%w: file content does not match an image format", ErrInvalidFormat)
+	}
+	return nil
+}
+
 func ValidateTitle(title string) error {
 	title = strings.TrimSpace(title)
 	if title == "" {
@@ -2,6 +2,7 @@ package validator
 
 import (
 	"bytes"
+	"encoding/binary"
 	"errors"
 	"mime/multipart"
 	"strings"
@@ -140,6 +141,79 @@ func TestIsVideoFile(t *testing.T) {
 	}
 }
 
+func TestDetectVideoContainer(t *testing.T) {
+	webm := []byte{0x1A, 0x45, 0xDF, 0xA3, 0x9F, 0x42, 0x86, 0x81, 0x01, 0x42, 0x82, 0x84, 'w', 'e', 'b', 'm'}
+	matroska := []byte{0x1A, 0x45, 0xDF, 0xA3, 0x9F, 0x42, 0x86, 0x81, 0x01, 0x42, 0x82, 0x88, 'm', 'a', 't', 'r', 'o', 's', 'k', 'a'}
+
+	tests := []struct {
+		name string
+		buf  []byte
+		want string
+	}{
+		{name: "mp4", buf: ftypHeader([]byte{0x00, 0x00, 0x00, 0x20}, "isom"), want: "video/mp4"},
+		{name: "mov", buf: ftypHeader([]byte{0x00, 0x00, 0x00, 0x14}, "qt  "), want: "video/quicktime"},
+		{name: "webm", buf: webm, want: "video/webm"},
+		{name: "matroska", buf: matroska, want: "video/x-matroska"},
+		{name: "avi", buf: []byte{'R', 'I', 'F', 'F', 0x24, 0x10, 0x00, 0x00, 'A', 'V', 'I', ' '}, want: "video/x-msvideo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := DetectVideoContainer(tt.buf)
+			if !ok {
+				t.Fatalf("DetectVideoContainer() found no container")
+			}
+			if got.MIMEType != tt.want {
+				t.Errorf("MIMEType = %q, want %q", got.MIMEType, tt.want)
+			}
+		})
+	}
+}
+
+// box builds an ISO-BMFF box of the given type around payload.
+func box(typ string, payload []byte) []byte {
+	out := binary.BigEndian.AppendUint32(nil, uint32(8+len(payload)))
+	return append(append(out, typ...), payload...)
+}
+
+func TestValidateISOBMFFBoxes(t *testing.T) {
+	ftyp := box("ftyp", []byte("isom\x00\x00\x02\x00isomiso2"))
+	moov := box("moov", make([]byte, 64))
+	mdat := box("mdat", make([]byte, 256))
+	concat := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+
+	// A 64-bit size: size field 1, then the real size after the type.
+	large := append([]byte{0, 0, 0, 1}, "mdat"...)
+	large = binary.BigEndian.AppendUint64(large, 16+32)
+	large = append(large, make([]byte, 32)...)
+
+	tests := []struct {
+		name    string
+		file    []byte
+		wantErr error
+	}{
+		{name: "well formed", file: concat(ftyp, moov, mdat)},
+		{name: "64-bit box size", file: concat(ftyp, moov, large)},
+		{name: "last box runs to the end", file: concat(ftyp, moov, []byte{0, 0, 0, 0}, []byte("mdat"), make([]byte, 100))},
+		{name: "archive appended", file: concat(ftyp, moov, mdat, []byte("PK\x03\x04"), make([]byte, 40)), wantErr: ErrInvalidFormat},
+		{name: "stray bytes", file: concat(ftyp, moov, mdat, []byte{0, 0, 0}), wantErr: ErrInvalidFormat},
+		{name: "undersized box", file: concat(ftyp, []byte{0, 0, 0, 4}, []byte("free")), wantErr: ErrInvalidFormat},
+		{name: "truncated", file: concat(ftyp, moov, mdat[:100]), wantErr: ErrCorruptVideo},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateISOBMFFBoxes(bytes.NewReader(tt.file), int64(len(tt.file)))
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("ValidateISOBMFFBoxes() unexpected error: %v", err)
+			case tt.wantErr != nil && !errors.Is(err, tt.wantErr):
+				t.Fatalf("ValidateISOBMFFBoxes() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestValidateVideoFile(t *testing.T) {
 	const maxSize = 10 * 1024 * 1024
 
@@ -239,6 +313,173 @@ func TestValidateVideoFile(t *testing.T) {
 	}
 }
 
+// TestValidateVideoUpload covers the content-free half of ValidateVideoFile on
+// its own, as resumable uploads call it with nothing but a declared name and
+// length.
+func TestValidateVideoUpload(t *testing.T) {
+	const maxSize = 10 * 1024 * 1024
+
+	tests := []struct {
+		name     string
+		filename string
+		size     int64
+		wantErr  error
+	}{
+		{name: "declared mp4 accepted", filename: "clip.mp4", size: 4096},
+		{name: "declared length over the limit rejected", filename: "clip.mp4", size: maxSize + 1, wantErr: ErrFileTooLarge},
+		{name: "declared length under the minimum rejected", filename: "clip.mp4", size: 10, wantErr: ErrInvalidFormat},
+		{name: "disallowed extension rejected", filename: "clip.gif", size: 4096, wantErr: ErrInvalidFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateVideoUpload(tt.filename, tt.size, maxSize)
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("ValidateVideoUpload() unexpected error: %v", err)
+			case tt.wantErr != nil && !errors.Is(err, tt.wantErr):
+				t.Fatalf("ValidateVideoUpload() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateAudioFile(t *testing.T) {
+	const maxSize = 10 * 1024 * 1024
+
+	m4a := pad(ftypHeader([]byte{0x00, 0x00, 0x00, 0x20}, "M4A "), 2048)
+	wav := pad([]byte{'R', 'I', 'F', 'F', 0x24, 0x10, 0x00, 0x00, 'W', 'A', 'V', 'E'}, 2048)
+	avi := pad([]byte{'R', 'I', 'F', 'F', 0x24, 0x10, 0x00, 0x00, 'A', 'V', 'I', ' '}, 2048)
+	mp3 := pad([]byte("ID3\x04\x00"), 2048)
+	adts := pad([]byte{0xFF, 0xF1, 0x50, 0x80}, 2048)
+	flac := pad([]byte("fLaC"), 2048)
+	ogg := pad([]byte("OggS"), 2048)
+	junk := pad([]byte("not audio, just some bytes"), 2048)
+
+	tests := []struct {
+		name     string
+		filename string
+		content  []byte
+		size     int64 // 0 means: use len(content)
+		wantErr  error
+	}{
+		{name: "m4a accepted", filename: "dub.m4a", content: m4a},
+		{name: "wav accepted", filename: "dub.wav", content: wav},
+		{name: "mp3 with ID3 tag accepted", filename: "dub.mp3", content: mp3},
+		{name: "raw aac accepted", filename: "dub.aac", content: adts},
+		{name: "flac accepted", filename: "dub.flac", content: flac},
+		{name: "opus accepted", filename: "dub.opus", content: ogg},
+		{name: "avi content under a wav name rejected", filename: "dub.wav", content: avi, wantErr: ErrInvalidFormat},
+		{name: "random bytes rejected", filename: "dub.mp3", content: junk, wantErr: ErrInvalidFormat},
+		{name: "video extension rejected", filename: "dub.mp4", content: m4a, wantErr: ErrInvalidFormat},
+		{name: "file too small rejected", filename: "dub.m4a", content: m4a[:512], wantErr: ErrInvalidFormat},
+		{name: "file too large rejected", filename: "dub.m4a", content: m4a, size: maxSize + 1, wantErr: ErrFileTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size := tt.size
+			if size == 0 {
+				size = int64(len(tt.content))
+			}
+
+			file := newFile(tt.content)
+			header := &multipart.FileHeader{Filename: tt.filename, Size: size}
+
+			err := ValidateAudioFile(file, header, maxSize)
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("ValidateAudioFile() unexpected error: %v", err)
+			case tt.wantErr != nil && !errors.Is(err, tt.wantErr):
+				t.Fatalf("ValidateAudioFile() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr == nil {
+				if pos, _ := file.Seek(0, 1); pos != 0 {
+					t.Errorf("file pointer = %d after validation, want 0", pos)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateCaptionFile(t *testing.T) {
+	const maxSize = 1024
+
+	tests := []struct {
+		name     string
+		filename string
+		size     int64
+		wantErr  error
+	}{
+		{name: "srt accepted", filename: "en.srt", size: 100},
+		{name: "vtt accepted", filename: "EN.VTT", size: 100},
+		{name: "other text format rejected", filename: "en.ass", size: 100, wantErr: ErrInvalidFormat},
+		{name: "empty file rejected", filename: "en.srt", size: 0, wantErr: ErrInvalidFormat},
+		{name: "file too large rejected", filename: "en.vtt", size: maxSize + 1, wantErr: ErrFileTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCaptionFile(&multipart.FileHeader{Filename: tt.filename, Size: tt.size}, maxSize)
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("ValidateCaptionFile() unexpected error: %v", err)
+			case tt.wantErr != nil && !errors.Is(err, tt.wantErr):
+				t.Fatalf("ValidateCaptionFile() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateImageFile(t *testing.T) {
+	const maxSize = 1024 * 1024
+
+	jpeg := pad([]byte{0xFF, 0xD8, 0xFF, 0xE0}, 2048)
+	png := pad([]byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1A, '\n'}, 2048)
+	gif := pad([]byte("GIF89a"), 2048)
+
+	tests := []struct {
+		name     string
+		filename string
+		content  []byte
+		size     int64 // 0 means: use len(content)
+		wantErr  error
+	}{
+		{name: "jpeg accepted", filename: "poster.jpg", content: jpeg},
+		{name: "jpeg extension accepted", filename: "poster.JPEG", content: jpeg},
+		{name: "png accepted", filename: "poster.png", content: png},
+		{name: "png content under a jpg name accepted", filename: "poster.jpg", content: png},
+		{name: "gif rejected", filename: "poster.gif", content: gif, wantErr: ErrInvalidFormat},
+		{name: "gif content under a png name rejected", filename: "poster.png", content: gif, wantErr: ErrInvalidFormat},
+		{name: "file too large rejected", filename: "poster.jpg", content: jpeg, size: maxSize + 1, wantErr: ErrFileTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size := tt.size
+			if size == 0 {
+				size = int64(len(tt.content))
+			}
+
+			file := newFile(tt.content)
+			err := ValidateImageFile(file, &multipart.FileHeader{Filename: tt.filename, Size: size}, maxSize)
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("ValidateImageFile() unexpected error: %v", err)
+			case tt.wantErr != nil && !errors.Is(err, tt.wantErr):
+				t.Fatalf("ValidateImageFile() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr == nil {
+				if pos, _ := file.Seek(0, 1); pos != 0 {
+					t.Errorf("file pointer = %d after validation, want 0", pos)
+				}
+			}
+		})
+	}
+}
+
 func TestValidateTitle(t *testing.T) {
 	tests := []struct {
 		name    string
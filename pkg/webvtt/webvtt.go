@@ -0,0 +1,254 @@
+// Package webvtt reads WebVTT and SubRip caption files and writes WebVTT.
+// It understands cue timing, identifiers and settings, which is all HLS
+// segmenting needs; cue text is carried through as written.
+package webvtt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoCues reports a file that parsed but holds nothing to show.
+var ErrNoCues = errors.New("file has no cues")
+
+// Cue is one timed piece of text.
+type Cue struct {
+	ID    string
+	Start time.Duration
+	End   time.Duration
+	// Settings are the WebVTT cue settings after the timing, such as
+	// "line:0 align:start". SubRip has none.
+	Settings string
+	Text     string
+}
+
+var (
+	// vttTimestamp is hh:mm:ss.ttt, with the hours optional.
+	vttTimestamp = regexp.MustCompile(`^(?:(\d{2,}):)?([0-5]\d):([0-5]\d)\.(\d{3})$`)
+	// srtTimestamp is hh:mm:ss,ttt, leniently: one-digit hours, fewer
+	// millisecond digits and a '.' are common in the wild.
+	srtTimestamp = regexp.MustCompile(`^(\d{1,3}):([0-5]?\d):([0-5]?\d)[,.](\d{1,3})$`)
+
+	// srtFont and srtOverride are SubRip markup WebVTT has no use for: font
+	// tags and the ASS override blocks some tools emit, such as {\an8}.
+	srtFont     = regexp.MustCompile(`(?i)</?font[^>]*>`)
+	srtOverride = regexp.MustCompile(`\{\\[^}]*\}`)
+)
+
+// ParseWebVTT parses a WebVTT file. NOTE, STYLE and REGION blocks are
+// skipped, as are cues that end before they start.
+func ParseWebVTT(data []byte) ([]Cue, error) {
+	blocks := splitBlocks(data)
+	if len(blocks) == 0 || !isSignature(blocks[0][0]) {
+		return nil, errors.New("missing WEBVTT signature")
+	}
+
+	var cues []Cue
+	for n, block := range blocks[1:] {
+		switch keyword, _, _ := strings.Cut(block[0], " "); keyword {
+		case "NOTE", "STYLE", "REGION":
+			continue
+		}
+
+		var id string
+		if !strings.Contains(block[0], "-->") {
+			id, block = block[0], block[1:]
+			if len(block) == 0 {
+				return nil, fmt.Errorf("block %d: identifier without a cue", n+1)
+			}
+		}
+
+		start, end, settings, err := parseTiming(block[0], parseVTTTimestamp)
+		if err != nil {
+			return nil, fmt.Errorf("block %d: %w", n+1, err)
+		}
+		if end <= start {
+			continue
+		}
+		cues = append(cues, Cue{
+			ID:       id,
+			Start:    start,
+			End:      end,
+			Settings: settings,
+			Text:     strings.Join(block[1:], "\n"),
+		})
+	}
+
+	if len(cues) == 0 {
+		return nil, ErrNoCues
+	}
+	return cues, nil
+}
+
+// ParseSRT parses a SubRip file into cues ready to write as WebVTT: font tags
+// and override blocks are dropped, and the <b>, <i> and <u> tags both formats
+// share are kept.
+func ParseSRT(data []byte) ([]Cue, error) {
+	var cues []Cue
+	for n, block := range splitBlocks(data) {
+		// The counter line is meant to be there, but some tools leave it
+		// out; the timing line is what matters.
+		if !strings.Contains(block[0], "-->") {
+			block = block[1:]
+			if len(block) == 0 {
+				return nil, fmt.Errorf("block %d: counter without a cue", n+1)
+			}
+		}
+
+		start, end, _, err := parseTiming(block[0], parseSRTTimestamp)
+		if err != nil {
+			return nil, fmt.Errorf("block %d: %w", n+1, err)
+		}
+		if end <= start {
+			continue
+		}
+
+		text := strings.Join(block[1:], "\n")
+		text = srtOverride.ReplaceAllString(srtFont.ReplaceAllString(text, ""), "")
+		// An arrow would read as a timing line to a WebVTT parser.
+		text = strings.ReplaceAll(text, "-->", "->")
+		cues = append(cues, Cue{Start: start, End: end, Text: strings.TrimSpace(text)})
+	}
+
+	if len(cues) == 0 {
+		return nil, ErrNoCues
+	}
+	return cues, nil
+}
+
+// Write writes cues as a WebVTT file. header lines, such as HLS's
+// X-TIMESTAMP-MAP, follow the signature.
+func Write(w io.Writer, header []string, cues []Cue) error {
+	var buf bytes.Buffer
+	buf.WriteString("WEBVTT\n")
+	for _, line := range header {
+		buf.WriteString(line + "\n")
+	}
+	for _, cue := range cues {
+		buf.WriteString("\n")
+		if cue.ID != "" {
+			buf.WriteString(cue.ID + "\n")
+		}
+		buf.WriteString(FormatTimestamp(cue.Start) + " --> " + FormatTimestamp(cue.End))
+		if cue.Settings != "" {
+			buf.WriteString(" " + cue.Settings)
+		}
+		buf.WriteString("\n")
+		if cue.Text != "" {
+			buf.WriteString(cue.Text + "\n")
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Between returns the cues showing at any moment in [from, to).
+func Between(cues []Cue, from, to time.Duration) []Cue {
+	var out []Cue
+	for _, cue := range cues {
+		if cue.Start < to && cue.End > from {
+			out = append(out, cue)
+		}
+	}
+	return out
+}
+
+// FormatTimestamp writes d as a WebVTT timestamp, hh:mm:ss.ttt.
+func FormatTimestamp(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3_600_000, ms/60_000%60, ms/1000%60, ms%1000)
+}
+
+// splitBlocks splits a caption file into its blank-line separated blocks of
+// lines, after dropping a byte order mark and normalizing line endings.
+func splitBlocks(data []byte) [][]string {
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	var blocks [][]string
+	var block []string
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			if len(block) > 0 {
+				blocks = append(blocks, block)
+				block = nil
+			}
+			continue
+		}
+		block = append(block, strings.TrimRight(line, " \t"))
+	}
+	if len(block) > 0 {
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+func isSignature(line string) bool {
+	rest, ok := strings.CutPrefix(line, "WEBVTT")
+	return ok && (rest == "" || rest[0] == ' ' || rest[0] == '\t')
+}
+
+func parseTiming(line string, parse func(string) (time.Duration, error)) (start, end time.Duration, settings string, err error) {
+	left, right, ok := strings.Cut(line, "-->")
+	if !ok {
+		return 0, 0, "", fmt.Errorf("expected a timing line, got %q", line)
+	}
+	if start, err = parse(strings.TrimSpace(left)); err != nil {
+		return 0, 0, "", err
+	}
+	fields := strings.Fields(right)
+	if len(fields) == 0 {
+		return 0, 0, "", fmt.Errorf("timing line %q has no end time", line)
+	}
+	if end, err = parse(fields[0]); err != nil {
+		return 0, 0, "", err
+	}
+	return start, end, strings.Join(fields[1:], " "), nil
+}
+
+func parseVTTTimestamp(s string) (time.Duration, error) {
+	m := vttTimestamp.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+	return timestamp(m[1], m[2], m[3], m[4]), nil
+}
+
+func parseSRTTimestamp(s string) (time.Duration, error) {
+	m := srtTimestamp.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+	// "1,5" is 500ms, not 5ms: the digits are a fraction.
+	return timestamp(m[1], m[2], m[3], (m[4] + "00")[:3]), nil
+}
+
+func timestamp(hours, minutes, seconds, millis string) time.Duration {
+	var d time.Duration
+	for _, part := range []struct {
+		value string
+		unit  time.Duration
+	}{
+		{hours, time.Hour},
+		{minutes, time.Minute},
+		{seconds, time.Second},
+		{millis, time.Millisecond},
+	} {
+		if part.value == "" {
+			continue
+		}
+		n, _ := strconv.Atoi(part.value)
+		d += time.Duration(n) * part.unit
+	}
+	return d
+}
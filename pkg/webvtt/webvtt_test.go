@@ -0,0 +1,159 @@
+package webvtt
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSRT(t *testing.T) {
+	src := "\ufeff1\r\n" +
+		"00:00:01,000 --> 00:00:04,500\r\n" +
+		"<font color=\"#ffff00\">Hello</font> <i>there</i>\r\n" +
+		"\r\n" +
+		"2\r\n" +
+		"0:01:02,5 --> 0:01:03,25 X1:10 X2:20 Y1:30 Y2:40\r\n" +
+		"{\\an8}Top --> line\r\n" +
+		"second line\r\n" +
+		"\r\n" +
+		"00:00:09,000 --> 00:00:09,000\r\n" +
+		"zero length\r\n"
+
+	cues, err := ParseSRT([]byte(src))
+	if err != nil {
+		t.Fatalf("ParseSRT: %v", err)
+	}
+	want := []Cue{
+		{Start: time.Second, End: 4500 * time.Millisecond, Text: "Hello <i>there</i>"},
+		{Start: time.Minute + 2500*time.Millisecond, End: time.Minute + 3250*time.Millisecond, Text: "Top -> line\nsecond line"},
+	}
+	if len(cues) != len(want) {
+		t.Fatalf("got %d cues, want %d: %+v", len(cues), len(want), cues)
+	}
+	for i := range want {
+		if cues[i] != want[i] {
+			t.Errorf("cue %d = %+v, want %+v", i, cues[i], want[i])
+		}
+	}
+}
+
+func TestParseWebVTT(t *testing.T) {
+	src := "WEBVTT - a title\n" +
+		"Kind: captions\n" +
+		"\n" +
+		"NOTE made by hand\n" +
+		"\n" +
+		"STYLE\n" +
+		"::cue { color: yellow }\n" +
+		"\n" +
+		"intro\n" +
+		"00:01.000 --> 00:02.000 line:0 align:start\n" +
+		"<v Roger>Hi\n" +
+		"\n" +
+		"01:00:00.000 --> 01:00:01.500\n" +
+		"[door slams]\n"
+
+	cues, err := ParseWebVTT([]byte(src))
+	if err != nil {
+		t.Fatalf("ParseWebVTT: %v", err)
+	}
+	want := []Cue{
+		{ID: "intro", Start: time.Second, End: 2 * time.Second, Settings: "line:0 align:start", Text: "<v Roger>Hi"},
+		{Start: time.Hour, End: time.Hour + 1500*time.Millisecond, Text: "[door slams]"},
+	}
+	if len(cues) != len(want) {
+		t.Fatalf("got %d cues, want %d: %+v", len(cues), len(want), cues)
+	}
+	for i := range want {
+		if cues[i] != want[i] {
+			t.Errorf("cue %d = %+v, want %+v", i, cues[i], want[i])
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		parse func([]byte) ([]Cue, error)
+		src   string
+	}{
+		{"vtt without signature", ParseWebVTT, "00:01.000 --> 00:02.000\nHi\n"},
+		{"vtt signature run on", ParseWebVTT, "WEBVTTX\n\n00:01.000 --> 00:02.000\nHi\n"},
+		{"vtt comma timestamp", ParseWebVTT, "WEBVTT\n\n00:00:01,000 --> 00:00:02,000\nHi\n"},
+		{"vtt bad minutes", ParseWebVTT, "WEBVTT\n\n00:61.000 --> 01:02.000\nHi\n"},
+		{"vtt identifier alone", ParseWebVTT, "WEBVTT\n\nintro\n"},
+		{"srt missing end", ParseSRT, "1\n00:00:01,000 -->\nHi\n"},
+		{"srt garbage", ParseSRT, "this is not\na caption file\n"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := tc.parse([]byte(tc.src)); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+
+	if _, err := ParseWebVTT([]byte("WEBVTT\n\nNOTE nothing here\n")); !errors.Is(err, ErrNoCues) {
+		t.Errorf("empty WebVTT: err = %v, want ErrNoCues", err)
+	}
+	if _, err := ParseSRT([]byte("")); !errors.Is(err, ErrNoCues) {
+		t.Errorf("empty SRT: err = %v, want ErrNoCues", err)
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	cues := []Cue{
+		{ID: "1", Start: 1500 * time.Millisecond, End: 3 * time.Second, Settings: "align:end", Text: "one\ntwo"},
+		{Start: 2*time.Hour + 3*time.Minute + 4*time.Second + 5*time.Millisecond, End: 2*time.Hour + 3*time.Minute + 6*time.Second, Text: "late"},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, []string{"X-TIMESTAMP-MAP=MPEGTS:126000,LOCAL:00:00:00.000"}, cues); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "WEBVTT\nX-TIMESTAMP-MAP=MPEGTS:126000,LOCAL:00:00:00.000\n\n1\n00:00:01.500 --> 00:00:03.000 align:end\n") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+	if !strings.Contains(out, "02:03:04.005 --> 02:03:06.000\nlate\n") {
+		t.Errorf("hours not written:\n%s", out)
+	}
+
+	parsed, err := ParseWebVTT(buf.Bytes())
+	if err != nil {
+		t.Fatalf("ParseWebVTT of written file: %v", err)
+	}
+	for i := range cues {
+		if parsed[i] != cues[i] {
+			t.Errorf("cue %d = %+v, want %+v", i, parsed[i], cues[i])
+		}
+	}
+}
+
+func TestBetween(t *testing.T) {
+	cues := []Cue{
+		{Start: 0, End: 2 * time.Second, Text: "a"},
+		{Start: 5 * time.Second, End: 7 * time.Second, Text: "b"},
+		{Start: 6 * time.Second, End: 13 * time.Second, Text: "c"},
+	}
+
+	for _, tc := range []struct {
+		from, to time.Duration
+		want     string
+	}{
+		{0, 6 * time.Second, "ab"},
+		{6 * time.Second, 12 * time.Second, "bc"},
+		{12 * time.Second, 18 * time.Second, "c"},
+		{2 * time.Second, 5 * time.Second, ""},
+	} {
+		var got string
+		for _, cue := range Between(cues, tc.from, tc.to) {
+			got += cue.Text
+		}
+		if got != tc.want {
+			t.Errorf("Between(%v, %v) = %q, want %q", tc.from, tc.to, got, tc.want)
+		}
+	}
+}
@@ -74,6 +74,8 @@ func statusBadgeClass(status domain.VideoStatus) string {
 	switch status {
 	case domain.VideoStatusReady:
 		return "inline-flex items-center px-2.5 py-0.5 rounded-full text-xs font-medium bg-green-600/30 text-green-200 border border-green-500/30"
+	case domain.VideoStatusLive:
+		return "inline-flex items-center px-2.5 py-0.5 rounded-full text-xs font-medium bg-pink-600/30 text-pink-200 border border-pink-500/30"
 	case domain.VideoStatusProcessing:
 		return "inline-flex items-center px-2.5 py-0.5 rounded-full text-xs font-medium bg-yellow-600/30 text-yellow-200 border border-yellow-500/30"
 	case domain.VideoStatusFailed:
@@ -82,3 +84,25 @@ func statusBadgeClass(status domain.VideoStatus) string {
 		return "inline-flex items-center px-2.5 py-0.5 rounded-full text-xs font-medium bg-gray-600/30 text-gray-200 border border-gray-500/30"
 	}
 }
+
+// captionLabel names a caption in the player's menu: its label, else its
+// language, marked CC when it describes sounds as well as dialogue.
+func captionLabel(caption *domain.Caption) string {
+	label := caption.Label
+	if label == "" {
+		label = caption.Language
+	}
+	if label == "" || label == domain.UndeterminedLanguage {
+		label = "Subtitles"
+	}
+	if caption.Kind == domain.CaptionCaptions {
+		label += " [CC]"
+	}
+	return label
+}
+
+// captionFileURL is where a caption's whole WebVTT file is served, beside
+// its segments.
+func captionFileURL(caption *domain.Caption) string {
+	return fmt.Sprintf("/api/videos/%s/hls/%s/captions.vtt", caption.VideoID, caption.Name)
+}
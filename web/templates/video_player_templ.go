@@ -13,7 +13,7 @@ import (
 	"github.com/Nuu-maan/video-streaming-service/internal/domain"
 )
 
-func VideoPlayerPage(video *domain.Video) templ.Component {
+func VideoPlayerPage(video *domain.Video, captions []*domain.Caption) templ.Component {
 	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
 		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
@@ -50,7 +50,7 @@ func VideoPlayerPage(video *domain.Video) templ.Component {
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			if video.Status == domain.VideoStatusReady && video.HLSReady {
+			if (video.Status == domain.VideoStatusReady || video.Status == domain.VideoStatusLive) && video.HLSReady {
 				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "<video id=\"video-player\" class=\"video-js vjs-big-play-centered\" controls preload=\"auto\" width=\"1280\" height=\"720\" data-setup=\"{}\" style=\"width: 100%; height: auto;\"><source src=\"")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
@@ -58,7 +58,7 @@ func VideoPlayerPage(video *domain.Video) templ.Component {
 				var templ_7745c5c3_Var3 string
 				templ_7745c5c3_Var3, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprintf("/api/videos/%s/hls/master.m3u8", video.ID.String()))
 				if templ_7745c5c3_Err != nil {
-					return templ.Error{Err: templ_7745c5c3_Err, FileName: `video_player.templ`, Line: 27, Col: 85}
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/video_player.templ`, Line: 27, Col: 85}
 				}
 				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var3))
 				if templ_7745c5c3_Err != nil {
@@ -76,56 +76,128 @@ func VideoPlayerPage(video *domain.Video) templ.Component {
 				var templ_7745c5c3_Var4 string
 				templ_7745c5c3_Var4, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprintf("/api/videos/%s/stream/720p", video.ID.String()))
 				if templ_7745c5c3_Err != nil {
-					return templ.Error{Err: templ_7745c5c3_Err, FileName: `video_player.templ`, Line: 44, Col: 81}
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/video_player.templ`, Line: 44, Col: 81}
 				}
 				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var4))
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, "\" type=\"video/mp4\"><p class=\"vjs-no-js text-white p-4\">To view this video please enable JavaScript</p></video>")
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, "\" type=\"video/mp4\"> ")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				for _, caption := range captions {
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 6, "<track kind=\"")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					var templ_7745c5c3_Var5 string
+					templ_7745c5c3_Var5, templ_7745c5c3_Err = templ.JoinStringErrs(string(caption.Kind))
+					if templ_7745c5c3_Err != nil {
+						return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/video_player.templ`, Line: 46, Col: 42}
+					}
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var5))
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, "\" srclang=\"")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					var templ_7745c5c3_Var6 string
+					templ_7745c5c3_Var6, templ_7745c5c3_Err = templ.JoinStringErrs(caption.Language)
+					if templ_7745c5c3_Err != nil {
+						return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/video_player.templ`, Line: 46, Col: 71}
+					}
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var6))
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 8, "\" label=\"")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					var templ_7745c5c3_Var7 string
+					templ_7745c5c3_Var7, templ_7745c5c3_Err = templ.JoinStringErrs(captionLabel(caption))
+					if templ_7745c5c3_Err != nil {
+						return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/video_player.templ`, Line: 46, Col: 103}
+					}
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var7))
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 9, "\" src=\"")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					var templ_7745c5c3_Var8 string
+					templ_7745c5c3_Var8, templ_7745c5c3_Err = templ.JoinStringErrs(captionFileURL(caption))
+					if templ_7745c5c3_Err != nil {
+						return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/video_player.templ`, Line: 46, Col: 135}
+					}
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var8))
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 10, "\"")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					if caption.IsDefault {
+						templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 11, " default")
+						if templ_7745c5c3_Err != nil {
+							return templ_7745c5c3_Err
+						}
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 12, ">")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+				}
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 13, "<p class=\"vjs-no-js text-white p-4\">To view this video please enable JavaScript</p></video>")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
 			} else {
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 6, "<div class=\"aspect-video bg-gradient-to-br from-purple-900/50 to-pink-900/50 flex items-center justify-center\"><div class=\"text-center p-8\"><svg class=\"mx-auto h-24 w-24 text-white/30 mb-4\" fill=\"currentColor\" viewBox=\"0 0 20 20\"><path d=\"M10 18a8 8 0 100-16 8 8 0 000 16zM9.555 7.168A1 1 0 008 8v4a1 1 0 001.555.832l3-2a1 1 0 000-1.664l-3-2z\"></path></svg> ")
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 14, "<div class=\"aspect-video bg-gradient-to-br from-purple-900/50 to-pink-900/50 flex items-center justify-center\"><div class=\"text-center p-8\"><svg class=\"mx-auto h-24 w-24 text-white/30 mb-4\" fill=\"currentColor\" viewBox=\"0 0 20 20\"><path d=\"M10 18a8 8 0 100-16 8 8 0 000 16zM9.555 7.168A1 1 0 008 8v4a1 1 0 001.555.832l3-2a1 1 0 000-1.664l-3-2z\"></path></svg> ")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
 				if video.Status == domain.VideoStatusProcessing {
-					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, "<p class=\"text-white/70 text-lg mb-2\">Video is processing...</p><p class=\"text-white/50 text-sm\">Please wait while we prepare your video for streaming</p>")
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 15, "<p class=\"text-white/70 text-lg mb-2\">Video is processing...</p><p class=\"text-white/50 text-sm\">Please wait while we prepare your video for streaming</p>")
 					if templ_7745c5c3_Err != nil {
 						return templ_7745c5c3_Err
 					}
 				} else if video.Status == domain.VideoStatusFailed {
-					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 8, "<p class=\"text-white/70 text-lg mb-2\">Video processing failed</p><p class=\"text-white/50 text-sm\">Please try uploading again</p>")
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 16, "<p class=\"text-white/70 text-lg mb-2\">Video processing failed</p><p class=\"text-white/50 text-sm\">Please try uploading again</p>")
 					if templ_7745c5c3_Err != nil {
 						return templ_7745c5c3_Err
 					}
 				} else {
-					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 9, "<p class=\"text-white/70 text-lg mb-2\">Video not yet ready</p><p class=\"text-white/50 text-sm\">The video is being prepared for streaming</p>")
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 17, "<p class=\"text-white/70 text-lg mb-2\">Video not yet ready</p><p class=\"text-white/50 text-sm\">The video is being prepared for streaming</p>")
 					if templ_7745c5c3_Err != nil {
 						return templ_7745c5c3_Err
 					}
 				}
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 10, "</div></div>")
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 18, "</div></div>")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
 			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 11, "</div><div class=\"p-8\"><div class=\"flex justify-between items-start mb-6\"><div class=\"flex-1\"><h1 class=\"text-3xl font-bold text-white mb-3\">")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 19, "</div><div class=\"p-8\"><div class=\"flex justify-between items-start mb-6\"><div class=\"flex-1\"><h1 class=\"text-3xl font-bold text-white mb-3\">")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			var templ_7745c5c3_Var5 string
-			templ_7745c5c3_Var5, templ_7745c5c3_Err = templ.JoinStringErrs(video.Title)
+			var templ_7745c5c3_Var9 string
+			templ_7745c5c3_Var9, templ_7745c5c3_Err = templ.JoinStringErrs(video.Title)
 			if templ_7745c5c3_Err != nil {
-				return templ.Error{Err: templ_7745c5c3_Err, FileName: `video_player.templ`, Line: 74, Col: 21}
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/video_player.templ`, Line: 77, Col: 21}
 			}
-			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var5))
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var9))
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 12, "</h1><div class=\"flex items-center gap-3\">")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 20, "</h1><div class=\"flex items-center gap-3\">")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
@@ -134,195 +206,289 @@ func VideoPlayerPage(video *domain.Video) templ.Component {
 				return templ_7745c5c3_Err
 			}
 			if video.HLSReady {
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 13, "<span class=\"text-xs bg-green-500/20 text-green-300 px-3 py-1 rounded-full border border-green-500/30\">HLS Enabled</span> ")
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 21, "<span class=\"text-xs bg-green-500/20 text-green-300 px-3 py-1 rounded-full border border-green-500/30\">HLS Enabled</span> ")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
 			}
 			if len(video.AvailableQualities) > 0 {
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 14, "<span class=\"text-xs bg-blue-500/20 text-blue-300 px-3 py-1 rounded-full border border-blue-500/30\">")
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 22, "<span class=\"text-xs bg-blue-500/20 text-blue-300 px-3 py-1 rounded-full border border-blue-500/30\">")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
-				var templ_7745c5c3_Var6 string
-				templ_7745c5c3_Var6, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprintf("%d Qualities", len(video.AvailableQualities)))
+				var templ_7745c5c3_Var10 string
+				templ_7745c5c3_Var10, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprintf("%d Qualities", len(video.AvailableQualities)))
 				if templ_7745c5c3_Err != nil {
-					return templ.Error{Err: templ_7745c5c3_Err, FileName: `video_player.templ`, Line: 85, Col: 70}
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/video_player.templ`, Line: 88, Col: 70}
 				}
-				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var6))
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var10))
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 15, "</span>")
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 23, "</span>")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
 			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 16, "</div></div><a href=\"/videos\" class=\"text-purple-400 hover:text-purple-300 transition text-sm font-medium flex items-center gap-2\"><svg class=\"w-4 h-4\" fill=\"currentColor\" viewBox=\"0 0 20 20\"><path fill-rule=\"evenodd\" d=\"M9.707 16.707a1 1 0 01-1.414 0l-6-6a1 1 0 010-1.414l6-6a1 1 0 011.414 1.414L5.414 9H17a1 1 0 110 2H5.414l4.293 4.293a1 1 0 010 1.414z\" clip-rule=\"evenodd\"></path></svg> Back to Videos</a></div>")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 24, "</div></div><a href=\"/videos\" class=\"text-purple-400 hover:text-purple-300 transition text-sm font-medium flex items-center gap-2\"><svg class=\"w-4 h-4\" fill=\"currentColor\" viewBox=\"0 0 20 20\"><path fill-rule=\"evenodd\" d=\"M9.707 16.707a1 1 0 01-1.414 0l-6-6a1 1 0 010-1.414l6-6a1 1 0 011.414 1.414L5.414 9H17a1 1 0 110 2H5.414l4.293 4.293a1 1 0 010 1.414z\" clip-rule=\"evenodd\"></path></svg> Back to Videos</a></div>")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
 			if video.Description != "" {
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 17, "<div class=\"mb-6\"><h3 class=\"text-sm font-medium text-gray-400 mb-2\">Description</h3><p class=\"text-gray-200\">")
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 25, "<div class=\"mb-6\"><h3 class=\"text-sm font-medium text-gray-400 mb-2\">Description</h3><p class=\"text-gray-200\">")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
-				var templ_7745c5c3_Var7 string
-				templ_7745c5c3_Var7, templ_7745c5c3_Err = templ.JoinStringErrs(video.Description)
+				var templ_7745c5c3_Var11 string
+				templ_7745c5c3_Var11, templ_7745c5c3_Err = templ.JoinStringErrs(video.Description)
 				if templ_7745c5c3_Err != nil {
-					return templ.Error{Err: templ_7745c5c3_Err, FileName: `video_player.templ`, Line: 104, Col: 51}
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/video_player.templ`, Line: 107, Col: 51}
 				}
-				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var7))
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var11))
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 18, "</p></div>")
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 26, "</p></div>")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
 			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 19, "<div class=\"grid grid-cols-2 md:grid-cols-4 gap-6 mb-6\">")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 27, "<div class=\"grid grid-cols-2 md:grid-cols-4 gap-6 mb-6\">")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
 			if video.Duration > 0 {
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 20, "<div><h3 class=\"text-sm font-medium text-gray-400 mb-1\">Duration</h3><p class=\"text-white font-semibold\">")
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 28, "<div><h3 class=\"text-sm font-medium text-gray-400 mb-1\">Duration</h3><p class=\"text-white font-semibold\">")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
-				var templ_7745c5c3_Var8 string
-				templ_7745c5c3_Var8, templ_7745c5c3_Err = templ.JoinStringErrs(formatDuration(video.Duration))
+				var templ_7745c5c3_Var12 string
+				templ_7745c5c3_Var12, templ_7745c5c3_Err = templ.JoinStringErrs(formatDuration(video.Duration))
 				if templ_7745c5c3_Err != nil {
-					return templ.Error{Err: templ_7745c5c3_Err, FileName: `video_player.templ`, Line: 112, Col: 76}
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/video_player.templ`, Line: 115, Col: 76}
 				}
-				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var8))
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var12))
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 21, "</p></div>")
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 29, "</p></div>")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
 			}
 			if video.OriginalResolution != "" {
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 22, "<div><h3 class=\"text-sm font-medium text-gray-400 mb-1\">Resolution</h3><p class=\"text-white font-semibold\">")
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 30, "<div><h3 class=\"text-sm font-medium text-gray-400 mb-1\">Resolution</h3><p class=\"text-white font-semibold\">")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
-				var templ_7745c5c3_Var9 string
-				templ_7745c5c3_Var9, templ_7745c5c3_Err = templ.JoinStringErrs(video.OriginalResolution)
+				var templ_7745c5c3_Var13 string
+				templ_7745c5c3_Var13, templ_7745c5c3_Err = templ.JoinStringErrs(video.OriginalResolution)
 				if templ_7745c5c3_Err != nil {
-					return templ.Error{Err: templ_7745c5c3_Err, FileName: `video_player.templ`, Line: 119, Col: 70}
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/video_player.templ`, Line: 122, Col: 70}
 				}
-				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var9))
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var13))
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 23, "</p></div>")
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 31, "</p></div>")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
 			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 24, "<div><h3 class=\"text-sm font-medium text-gray-400 mb-1\">File Size</h3><p class=\"text-white font-semibold\">")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 32, "<div><h3 class=\"text-sm font-medium text-gray-400 mb-1\">File Size</h3><p class=\"text-white font-semibold\">")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			var templ_7745c5c3_Var10 string
-			templ_7745c5c3_Var10, templ_7745c5c3_Err = templ.JoinStringErrs(formatFileSize(video.FileSize))
+			var templ_7745c5c3_Var14 string
+			templ_7745c5c3_Var14, templ_7745c5c3_Err = templ.JoinStringErrs(formatFileSize(video.FileSize))
 			if templ_7745c5c3_Err != nil {
-				return templ.Error{Err: templ_7745c5c3_Err, FileName: `video_player.templ`, Line: 125, Col: 75}
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/video_player.templ`, Line: 128, Col: 75}
 			}
-			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var10))
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var14))
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 25, "</p></div><div><h3 class=\"text-sm font-medium text-gray-400 mb-1\">Uploaded</h3><p class=\"text-white font-semibold\">")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 33, "</p></div><div><h3 class=\"text-sm font-medium text-gray-400 mb-1\">Uploaded</h3><p class=\"text-white font-semibold\">")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			var templ_7745c5c3_Var11 string
-			templ_7745c5c3_Var11, templ_7745c5c3_Err = templ.JoinStringErrs(video.CreatedAt.Format("Jan 2, 2006"))
+			var templ_7745c5c3_Var15 string
+			templ_7745c5c3_Var15, templ_7745c5c3_Err = templ.JoinStringErrs(video.CreatedAt.Format("Jan 2, 2006"))
 			if templ_7745c5c3_Err != nil {
-				return templ.Error{Err: templ_7745c5c3_Err, FileName: `video_player.templ`, Line: 130, Col: 82}
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/video_player.templ`, Line: 133, Col: 82}
 			}
-			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var11))
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var15))
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 26, "</p></div></div>")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 34, "</p></div></div>")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
 			if len(video.AvailableQualities) > 0 {
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 27, "<div class=\"mb-6\"><h3 class=\"text-sm font-medium text-gray-400 mb-2\">Available Qualities</h3><div class=\"flex flex-wrap gap-2\">")
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 35, "<div class=\"mb-6\"><h3 class=\"text-sm font-medium text-gray-400 mb-2\">Available Qualities</h3><div class=\"flex flex-wrap gap-2\">")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
 				for _, quality := range video.AvailableQualities {
-					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 28, "<span class=\"text-sm bg-purple-500/20 text-purple-300 px-3 py-1 rounded-full border border-purple-500/30\">")
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 36, "<span class=\"text-sm bg-purple-500/20 text-purple-300 px-3 py-1 rounded-full border border-purple-500/30\">")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					var templ_7745c5c3_Var16 string
+					templ_7745c5c3_Var16, templ_7745c5c3_Err = templ.JoinStringErrs(quality)
+					if templ_7745c5c3_Err != nil {
+						return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/video_player.templ`, Line: 143, Col: 19}
+					}
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var16))
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 37, "</span>")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+				}
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 38, "</div></div>")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+			if len(captions) > 0 {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 39, "<div class=\"mb-6\"><h3 class=\"text-sm font-medium text-gray-400 mb-2\">Subtitles</h3><div id=\"caption-selector\" class=\"flex flex-wrap gap-2\"><button type=\"button\" data-caption-index=\"-1\" class=\"text-sm bg-purple-500/20 text-purple-300 px-3 py-1 rounded-full border border-purple-500/30 hover:bg-purple-500/40 transition\">Off</button> ")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				for i, caption := range captions {
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 40, "<button type=\"button\" data-caption-index=\"")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					var templ_7745c5c3_Var17 string
+					templ_7745c5c3_Var17, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprint(i))
+					if templ_7745c5c3_Err != nil {
+						return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/video_player.templ`, Line: 160, Col: 44}
+					}
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var17))
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 41, "\" data-kind=\"")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					var templ_7745c5c3_Var18 string
+					templ_7745c5c3_Var18, templ_7745c5c3_Err = templ.JoinStringErrs(string(caption.Kind))
+					if templ_7745c5c3_Err != nil {
+						return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/video_player.templ`, Line: 161, Col: 42}
+					}
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var18))
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 42, "\" data-srclang=\"")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					var templ_7745c5c3_Var19 string
+					templ_7745c5c3_Var19, templ_7745c5c3_Err = templ.JoinStringErrs(caption.Language)
+					if templ_7745c5c3_Err != nil {
+						return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/video_player.templ`, Line: 162, Col: 41}
+					}
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var19))
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 43, "\" data-label=\"")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					var templ_7745c5c3_Var20 string
+					templ_7745c5c3_Var20, templ_7745c5c3_Err = templ.JoinStringErrs(captionLabel(caption))
+					if templ_7745c5c3_Err != nil {
+						return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/video_player.templ`, Line: 163, Col: 44}
+					}
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var20))
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 44, "\" data-src=\"")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					var templ_7745c5c3_Var21 string
+					templ_7745c5c3_Var21, templ_7745c5c3_Err = templ.JoinStringErrs(captionFileURL(caption))
+					if templ_7745c5c3_Err != nil {
+						return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/video_player.templ`, Line: 164, Col: 44}
+					}
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var21))
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 45, "\" class=\"text-sm bg-purple-500/20 text-purple-300 px-3 py-1 rounded-full border border-purple-500/30 hover:bg-purple-500/40 transition\">")
 					if templ_7745c5c3_Err != nil {
 						return templ_7745c5c3_Err
 					}
-					var templ_7745c5c3_Var12 string
-					templ_7745c5c3_Var12, templ_7745c5c3_Err = templ.JoinStringErrs(quality)
+					var templ_7745c5c3_Var22 string
+					templ_7745c5c3_Var22, templ_7745c5c3_Err = templ.JoinStringErrs(captionLabel(caption))
 					if templ_7745c5c3_Err != nil {
-						return templ.Error{Err: templ_7745c5c3_Err, FileName: `video_player.templ`, Line: 140, Col: 19}
+						return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/video_player.templ`, Line: 167, Col: 33}
 					}
-					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var12))
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var22))
 					if templ_7745c5c3_Err != nil {
 						return templ_7745c5c3_Err
 					}
-					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 29, "</span>")
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 46, "</button>")
 					if templ_7745c5c3_Err != nil {
 						return templ_7745c5c3_Err
 					}
 				}
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 30, "</div></div>")
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 47, "</div></div>")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
 			}
 			if video.Status == domain.VideoStatusProcessing {
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 31, "<div class=\"bg-yellow-600/20 border border-yellow-500/30 rounded-lg p-4\"><div class=\"flex items-center\"><svg class=\"animate-spin h-5 w-5 text-yellow-400 mr-3\" xmlns=\"http://www.w3.org/2000/svg\" fill=\"none\" viewBox=\"0 0 24 24\"><circle class=\"opacity-25\" cx=\"12\" cy=\"12\" r=\"10\" stroke=\"currentColor\" stroke-width=\"4\"></circle> <path class=\"opacity-75\" fill=\"currentColor\" d=\"M4 12a8 8 0 018-8V0C5.373 0 0 5.373 0 12h4zm2 5.291A7.962 7.962 0 014 12H0c0 3.042 1.135 5.824 3 7.938l3-2.647z\"></path></svg><div><p class=\"text-yellow-200 font-medium\">Processing video...</p>")
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 48, "<div class=\"bg-yellow-600/20 border border-yellow-500/30 rounded-lg p-4\"><div class=\"flex items-center\"><svg class=\"animate-spin h-5 w-5 text-yellow-400 mr-3\" xmlns=\"http://www.w3.org/2000/svg\" fill=\"none\" viewBox=\"0 0 24 24\"><circle class=\"opacity-25\" cx=\"12\" cy=\"12\" r=\"10\" stroke=\"currentColor\" stroke-width=\"4\"></circle> <path class=\"opacity-75\" fill=\"currentColor\" d=\"M4 12a8 8 0 018-8V0C5.373 0 0 5.373 0 12h4zm2 5.291A7.962 7.962 0 014 12H0c0 3.042 1.135 5.824 3 7.938l3-2.647z\"></path></svg><div><p class=\"text-yellow-200 font-medium\">Processing video...</p>")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
 				if video.TranscodingProgress > 0 {
-					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 32, "<p class=\"text-yellow-300 text-sm\">Progress: ")
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 49, "<p class=\"text-yellow-300 text-sm\">Progress: ")
 					if templ_7745c5c3_Err != nil {
 						return templ_7745c5c3_Err
 					}
-					var templ_7745c5c3_Var13 string
-					templ_7745c5c3_Var13, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprintf("%d%%", video.TranscodingProgress))
+					var templ_7745c5c3_Var23 string
+					templ_7745c5c3_Var23, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprintf("%d%%", video.TranscodingProgress))
 					if templ_7745c5c3_Err != nil {
-						return templ.Error{Err: templ_7745c5c3_Err, FileName: `video_player.templ`, Line: 157, Col: 103}
+						return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/video_player.templ`, Line: 184, Col: 103}
 					}
-					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var13))
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var23))
 					if templ_7745c5c3_Err != nil {
 						return templ_7745c5c3_Err
 					}
-					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 33, "</p>")
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 50, "</p>")
 					if templ_7745c5c3_Err != nil {
 						return templ_7745c5c3_Err
 					}
 				}
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 34, "</div></div></div>")
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 51, "</div></div></div>")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
 			}
 			if video.Status == domain.VideoStatusFailed {
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 35, "<div class=\"bg-red-600/20 border border-red-500/30 rounded-lg p-4\"><p class=\"text-red-200 font-medium\">Video processing failed</p><p class=\"text-red-300 text-sm mt-1\">Please try uploading again</p></div>")
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 52, "<div class=\"bg-red-600/20 border border-red-500/30 rounded-lg p-4\"><p class=\"text-red-200 font-medium\">Video processing failed</p><p class=\"text-red-300 text-sm mt-1\">Please try uploading again</p></div>")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
 			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 36, "</div></div></div>")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 53, "</div></div></div>")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
 			if video.Status == domain.VideoStatusReady {
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 37, "<script>\n\t\t\t\t(function() {\n\t\t\t\t\tconst player = videojs('video-player', {\n\t\t\t\t\t\tcontrols: true,\n\t\t\t\t\t\tautoplay: false,\n\t\t\t\t\t\tpreload: 'auto',\n\t\t\t\t\t\tfluid: true,\n\t\t\t\t\t\tplaybackRates: [0.5, 0.75, 1, 1.25, 1.5, 2],\n\t\t\t\t\t\tcontrolBar: {\n\t\t\t\t\t\t\tvolumePanel: {\n\t\t\t\t\t\t\t\tinline: false\n\t\t\t\t\t\t\t}\n\t\t\t\t\t\t}\n\t\t\t\t\t});\n\n\t\t\t\t\tplayer.on('loadedmetadata', function() {\n\t\t\t\t\t\tconsole.log('Video loaded, duration:', player.duration());\n\t\t\t\t\t});\n\n\t\t\t\t\tplayer.on('error', function(e) {\n\t\t\t\t\t\tconst error = player.error();\n\t\t\t\t\t\tconsole.error('Playback error:', error);\n\t\t\t\t\t\t\n\t\t\t\t\t\tif (error && error.code === 4) {\n\t\t\t\t\t\t\tconsole.log('HLS not supported, trying MP4 fallback...');\n\t\t\t\t\t\t\tconst videoId = window.location.pathname.split('/').pop();\n\t\t\t\t\t\t\tplayer.src({\n\t\t\t\t\t\t\t\tsrc: `/api/videos/${videoId}/stream/720p`,\n\t\t\t\t\t\t\t\ttype: 'video/mp4'\n\t\t\t\t\t\t\t});\n\t\t\t\t\t\t}\n\t\t\t\t\t});\n\n\t\t\t\t\tplayer.on('play', function() {\n\t\t\t\t\t\tconsole.log('Video started playing');\n\t\t\t\t\t});\n\n\t\t\t\t\tplayer.on('pause', function() {\n\t\t\t\t\t\tconsole.log('Video paused');\n\t\t\t\t\t});\n\n\t\t\t\t\tplayer.on('ended', function() {\n\t\t\t\t\t\tconsole.log('Video ended');\n\t\t\t\t\t});\n\t\t\t\t})();\n\t\t\t</script>")
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 54, "<script>\n\t\t\t\t(function() {\n\t\t\t\t\tconst player = videojs('video-player', {\n\t\t\t\t\t\tcontrols: true,\n\t\t\t\t\t\tautoplay: false,\n\t\t\t\t\t\tpreload: 'auto',\n\t\t\t\t\t\tfluid: true,\n\t\t\t\t\t\tplaybackRates: [0.5, 0.75, 1, 1.25, 1.5, 2],\n\t\t\t\t\t\t// Subtitles and alternate audio come from the master\n\t\t\t\t\t\t// playlist, which VHS reads and native HLS in most\n\t\t\t\t\t\t// browsers does not; Safari plays HLS, renditions and\n\t\t\t\t\t\t// all, by itself.\n\t\t\t\t\t\thtml5: {\n\t\t\t\t\t\t\tvhs: { overrideNative: !videojs.browser.IS_SAFARI },\n\t\t\t\t\t\t\tnativeTextTracks: videojs.browser.IS_SAFARI,\n\t\t\t\t\t\t\tnativeAudioTracks: videojs.browser.IS_SAFARI\n\t\t\t\t\t\t},\n\t\t\t\t\t\tcontrolBar: {\n\t\t\t\t\t\t\tvolumePanel: {\n\t\t\t\t\t\t\t\tinline: false\n\t\t\t\t\t\t\t}\n\t\t\t\t\t\t}\n\t\t\t\t\t});\n\n\t\t\t\t\tplayer.on('loadedmetadata', function() {\n\t\t\t\t\t\tconsole.log('Video loaded, duration:', player.duration());\n\t\t\t\t\t});\n\n\t\t\t\t\tplayer.on('error', function(e) {\n\t\t\t\t\t\tconst error = player.error();\n\t\t\t\t\t\tconsole.error('Playback error:', error);\n\t\t\t\t\t\t\n\t\t\t\t\t\tif (error && error.code === 4) {\n\t\t\t\t\t\t\tconsole.log('HLS not supported, trying MP4 fallback...');\n\t\t\t\t\t\t\tconst videoId = window.location.pathname.split('/').pop();\n\t\t\t\t\t\t\tplayer.src({\n\t\t\t\t\t\t\t\tsrc: `/api/videos/${videoId}/stream/720p`,\n\t\t\t\t\t\t\t\ttype: 'video/mp4'\n\t\t\t\t\t\t\t});\n\t\t\t\t\t\t\t// The MP4 has no subtitles of its own; the whole\n\t\t\t\t\t\t\t// WebVTT file of each caption stands in.\n\t\t\t\t\t\t\tcaptionButtons().forEach(function(button) {\n\t\t\t\t\t\t\t\tplayer.addRemoteTextTrack({\n\t\t\t\t\t\t\t\t\tkind: button.dataset.kind,\n\t\t\t\t\t\t\t\t\tsrclang: button.dataset.srclang,\n\t\t\t\t\t\t\t\t\tlabel: button.dataset.label,\n\t\t\t\t\t\t\t\t\tsrc: button.dataset.src\n\t\t\t\t\t\t\t\t}, false);\n\t\t\t\t\t\t\t});\n\t\t\t\t\t\t}\n\t\t\t\t\t});\n\n\t\t\t\t\tfunction captionButtons() {\n\t\t\t\t\t\treturn Array.from(document.querySelectorAll('#caption-selector [data-src]'));\n\t\t\t\t\t}\n\n\t\t\t\t\t// The selector picks by position: the player lists captions\n\t\t\t\t\t// in the order the master playlist does, which is the order\n\t\t\t\t\t// they are rendered here.\n\t\t\t\t\tdocument.querySelectorAll('#caption-selector button').forEach(function(button) {\n\t\t\t\t\t\tbutton.addEventListener('click', function() {\n\t\t\t\t\t\t\tconst wanted = parseInt(button.dataset.captionIndex, 10);\n\t\t\t\t\t\t\tconst tracks = player.textTracks();\n\t\t\t\t\t\t\tlet n = 0;\n\t\t\t\t\t\t\tfor (let i = 0; i < tracks.length; i++) {\n\t\t\t\t\t\t\t\tconst track = tracks[i];\n\t\t\t\t\t\t\t\tif (track.kind !== 'subtitles' && track.kind !== 'captions') {\n\t\t\t\t\t\t\t\t\tcontinue;\n\t\t\t\t\t\t\t\t}\n\t\t\t\t\t\t\t\ttrack.mode = n === wanted ? 'showing' : 'disabled';\n\t\t\t\t\t\t\t\tn++;\n\t\t\t\t\t\t\t}\n\t\t\t\t\t\t});\n\t\t\t\t\t});\n\n\t\t\t\t\t// Seek-bar previews. thumbnails.vtt maps time ranges to\n\t\t\t\t\t// tiles of the sprite sheets beside it; a video without\n\t\t\t\t\t// them answers 404 and keeps the plain seek bar.\n\t\t\t\t\tconst trickplayBase = `/api/videos/${window.location.pathname.split('/').pop()}/trickplay/`;\n\t\t\t\t\tfetch(trickplayBase + 'thumbnails.vtt')\n\t\t\t\t\t\t.then(function(res) { return res.ok ? res.text() : ''; })\n\t\t\t\t\t\t.then(function(text) {\n\t\t\t\t\t\t\tconst cues = parseTrickplay(text);\n\t\t\t\t\t\t\tif (cues.length === 0) {\n\t\t\t\t\t\t\t\treturn;\n\t\t\t\t\t\t\t}\n\t\t\t\t\t\t\tconst progress = player.controlBar.progressControl;\n\t\t\t\t\t\t\tconst preview = document.createElement('div');\n\t\t\t\t\t\t\tpreview.style.cssText = 'position:absolute;bottom:100%;display:none;pointer-events:none;' +\n\t\t\t\t\t\t\t\t'border:1px solid rgba(255,255,255,0.6);background-repeat:no-repeat;';\n\t\t\t\t\t\t\tprogress.el().style.position = 'relative';\n\t\t\t\t\t\t\tprogress.el().appendChild(preview);\n\n\t\t\t\t\t\t\tprogress.on('mousemove', function(event) {\n\t\t\t\t\t\t\t\tconst bar = progress.seekBar.el().getBoundingClientRect();\n\t\t\t\t\t\t\t\tconst fraction = Math.min(Math.max((event.clientX - bar.left) / bar.width, 0), 1);\n\t\t\t\t\t\t\t\tconst time = fraction * player.duration();\n\t\t\t\t\t\t\t\tconst cue = cues.find(function(c) { return time >= c.start && time < c.end; }) || cues[cues.length - 1];\n\t\t\t\t\t\t\t\tconst box = progress.el().getBoundingClientRect();\n\t\t\t\t\t\t\t\tconst left = Math.min(Math.max(event.clientX - box.left - cue.w / 2, 0), box.width - cue.w);\n\t\t\t\t\t\t\t\tpreview.style.width = cue.w + 'px';\n\t\t\t\t\t\t\t\tpreview.style.height = cue.h + 'px';\n\t\t\t\t\t\t\t\tpreview.style.left = left + 'px';\n\t\t\t\t\t\t\t\tpreview.style.backgroundImage = `url(${trickplayBase}${cue.file})`;\n\t\t\t\t\t\t\t\tpreview.style.backgroundPosition = `-${cue.x}px -${cue.y}px`;\n\t\t\t\t\t\t\t\tpreview.style.display = 'block';\n\t\t\t\t\t\t\t});\n\t\t\t\t\t\t\tprogress.on('mouseleave', function() {\n\t\t\t\t\t\t\t\tpreview.style.display = 'none';\n\t\t\t\t\t\t\t});\n\t\t\t\t\t\t})\n\t\t\t\t\t\t.catch(function() {});\n\n\t\t\t\t\t// parseTrickplay reads the cues of a thumbnails track, each\n\t\t\t\t\t// naming a sheet and a tile of it as #xywh=x,y,w,h.\n\t\t\t\t\tfunction parseTrickplay(text) {\n\t\t\t\t\t\tconst seconds = function(stamp) {\n\t\t\t\t\t\t\treturn stamp.split(':').reduce(function(total, part) { return total * 60 + parseFloat(part); }, 0);\n\t\t\t\t\t\t};\n\t\t\t\t\t\tconst cues = [];\n\t\t\t\t\t\ttext.split(/\\r?\\n\\r?\\n/).forEach(function(block) {\n\t\t\t\t\t\t\tconst lines = block.trim().split(/\\r?\\n/);\n\t\t\t\t\t\t\tconst timing = lines.findIndex(function(line) { return line.includes('-->'); });\n\t\t\t\t\t\t\tif (timing < 0 || !lines[timing + 1]) {\n\t\t\t\t\t\t\t\treturn;\n\t\t\t\t\t\t\t}\n\t\t\t\t\t\t\tconst times = lines[timing].split('-->');\n\t\t\t\t\t\t\tconst match = lines[timing + 1].match(/^(.+)#xywh=(\\d+),(\\d+),(\\d+),(\\d+)$/);\n\t\t\t\t\t\t\tif (!match) {\n\t\t\t\t\t\t\t\treturn;\n\t\t\t\t\t\t\t}\n\t\t\t\t\t\t\tcues.push({\n\t\t\t\t\t\t\t\tstart: seconds(times[0].trim()),\n\t\t\t\t\t\t\t\tend: seconds(times[1].trim().split(/\\s+/)[0]),\n\t\t\t\t\t\t\t\tfile: match[1],\n\t\t\t\t\t\t\t\tx: +match[2], y: +match[3], w: +match[4], h: +match[5]\n\t\t\t\t\t\t\t});\n\t\t\t\t\t\t});\n\t\t\t\t\t\treturn cues;\n\t\t\t\t\t}\n\n\t\t\t\t\tplayer.on('play', function() {\n\t\t\t\t\t\tconsole.log('Video started playing');\n\t\t\t\t\t});\n\n\t\t\t\t\tplayer.on('pause', function() {\n\t\t\t\t\t\tconsole.log('Video paused');\n\t\t\t\t\t});\n\n\t\t\t\t\tplayer.on('ended', function() {\n\t\t\t\t\t\tconsole.log('Video ended');\n\t\t\t\t\t});\n\t\t\t\t})();\n\t\t\t</script>")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}